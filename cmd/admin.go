@@ -59,6 +59,8 @@ var (
 			microcmdAuthUpdateLdapSimpleAuth,
 			microcmdAuthAddSMTP,
 			microcmdAuthUpdateSMTP,
+			microcmdAuthAddSAML,
+			microcmdAuthUpdateSAML,
 			microcmdAuthList,
 			microcmdAuthDelete,
 		},
@@ -0,0 +1,221 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/services/auth/source/saml"
+
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	samlCLIFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Value: "",
+			Usage: "Authentication name.",
+		},
+		&cli.StringFlag{
+			Name:  "metadata-url",
+			Value: "",
+			Usage: "URL of the identity provider metadata, downloaded whenever the source is saved",
+		},
+		&cli.StringFlag{
+			Name:  "metadata-file",
+			Value: "",
+			Usage: "File containing the identity provider metadata, when it is not downloaded from a URL",
+		},
+		&cli.StringFlag{
+			Name:  "entity-id",
+			Value: "",
+			Usage: "Entity ID of Forgejo as a service provider, defaults to the URL of its metadata",
+		},
+		&cli.StringFlag{
+			Name:  "username-attribute",
+			Value: "",
+			Usage: "Attribute holding the username, defaults to the NameID of the assertion",
+		},
+		&cli.StringFlag{
+			Name:  "email-attribute",
+			Value: "",
+			Usage: "Attribute holding the email address",
+		},
+		&cli.StringFlag{
+			Name:  "full-name-attribute",
+			Value: "",
+			Usage: "Attribute holding the full name",
+		},
+		&cli.StringFlag{
+			Name:  "group-attribute",
+			Value: "",
+			Usage: "Attribute providing group names for this source",
+		},
+		&cli.StringFlag{
+			Name:  "admin-group",
+			Value: "",
+			Usage: "Group for administrator users",
+		},
+		&cli.StringFlag{
+			Name:  "restricted-group",
+			Value: "",
+			Usage: "Group for restricted users",
+		},
+		&cli.StringFlag{
+			Name:  "group-team-map",
+			Value: "",
+			Usage: "JSON mapping between groups and org teams",
+		},
+		&cli.BoolFlag{
+			Name:  "group-team-map-removal",
+			Usage: "Activate automatic team membership removal depending on groups",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-local-2fa",
+			Usage: "Set to true to skip local 2fa for users authenticated by this source",
+		},
+		&cli.BoolFlag{
+			Name:  "active",
+			Usage: "This Authentication Source is Activated.",
+			Value: true,
+		},
+	}
+
+	microcmdAuthAddSAML = &cli.Command{
+		Name:   "add-saml",
+		Usage:  "Add new SAML authentication source",
+		Action: runAddSAML,
+		Flags:  samlCLIFlags,
+	}
+
+	microcmdAuthUpdateSAML = &cli.Command{
+		Name:   "update-saml",
+		Usage:  "Update existing SAML authentication source",
+		Action: runUpdateSAML,
+		Flags:  append(samlCLIFlags[:1], append([]cli.Flag{idFlag}, samlCLIFlags[1:]...)...),
+	}
+)
+
+func parseSAMLConfig(c *cli.Context, conf *saml.Source) error {
+	if c.IsSet("metadata-url") && c.IsSet("metadata-file") {
+		return errors.New("metadata-url and metadata-file cannot be both set")
+	}
+	if c.IsSet("metadata-url") {
+		conf.IdentityProviderMetadataURL = c.String("metadata-url")
+	}
+	if c.IsSet("metadata-file") {
+		metadata, err := os.ReadFile(c.String("metadata-file"))
+		if err != nil {
+			return err
+		}
+		conf.IdentityProviderMetadata = string(metadata)
+		conf.IdentityProviderMetadataURL = ""
+	}
+	if c.IsSet("entity-id") {
+		conf.ServiceProviderEntityID = c.String("entity-id")
+	}
+	if c.IsSet("username-attribute") {
+		conf.AttributeUsername = c.String("username-attribute")
+	}
+	if c.IsSet("email-attribute") {
+		conf.AttributeEmail = c.String("email-attribute")
+	}
+	if c.IsSet("full-name-attribute") {
+		conf.AttributeFullName = c.String("full-name-attribute")
+	}
+	if c.IsSet("group-attribute") {
+		conf.GroupAttribute = c.String("group-attribute")
+	}
+	if c.IsSet("admin-group") {
+		conf.AdminGroup = c.String("admin-group")
+	}
+	if c.IsSet("restricted-group") {
+		conf.RestrictedGroup = c.String("restricted-group")
+	}
+	if c.IsSet("group-team-map") {
+		conf.GroupTeamMap = c.String("group-team-map")
+	}
+	if c.IsSet("group-team-map-removal") {
+		conf.GroupTeamMapRemoval = c.Bool("group-team-map-removal")
+	}
+	if c.IsSet("skip-local-2fa") {
+		conf.SkipLocalTwoFA = c.Bool("skip-local-2fa")
+	}
+	return nil
+}
+
+func runAddSAML(c *cli.Context) error {
+	ctx, cancel := installSignals()
+	defer cancel()
+
+	if err := initDB(ctx); err != nil {
+		return err
+	}
+
+	if !c.IsSet("name") || len(c.String("name")) == 0 {
+		return errors.New("name must be set")
+	}
+	if !c.IsSet("metadata-url") && !c.IsSet("metadata-file") {
+		return errors.New("metadata-url or metadata-file must be set")
+	}
+
+	var samlConfig saml.Source
+	if err := parseSAMLConfig(c, &samlConfig); err != nil {
+		return err
+	}
+	if err := samlConfig.ImportIdentityProviderMetadata(ctx); err != nil {
+		return err
+	}
+
+	return auth_model.CreateSource(ctx, &auth_model.Source{
+		Type:     auth_model.SAML,
+		Name:     c.String("name"),
+		IsActive: c.Bool("active"),
+		Cfg:      &samlConfig,
+	})
+}
+
+func runUpdateSAML(c *cli.Context) error {
+	if !c.IsSet("id") {
+		return errors.New("--id flag is missing")
+	}
+
+	ctx, cancel := installSignals()
+	defer cancel()
+
+	if err := initDB(ctx); err != nil {
+		return err
+	}
+
+	source, err := auth_model.GetSourceByID(ctx, c.Int64("id"))
+	if err != nil {
+		return err
+	}
+	samlConfig, ok := source.Cfg.(*saml.Source)
+	if !ok {
+		return errors.New("the authentication source is not a SAML source")
+	}
+
+	if err := parseSAMLConfig(c, samlConfig); err != nil {
+		return err
+	}
+	if err := samlConfig.ImportIdentityProviderMetadata(ctx); err != nil {
+		return err
+	}
+
+	if c.IsSet("name") {
+		source.Name = c.String("name")
+	}
+
+	if c.IsSet("active") {
+		source.IsActive = c.Bool("active")
+	}
+
+	source.Cfg = samlConfig
+
+	return auth_model.UpdateSource(ctx, source)
+}
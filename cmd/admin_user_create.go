@@ -7,12 +7,13 @@ import (
 	"errors"
 	"fmt"
 
-	auth_model "code.gitea.io/gitea/models/auth"
 	"code.gitea.io/gitea/models/db"
 	user_model "code.gitea.io/gitea/models/user"
 	pwd "code.gitea.io/gitea/modules/auth/password"
 	"code.gitea.io/gitea/modules/optional"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	auth_service "code.gitea.io/gitea/services/auth"
 
 	"github.com/urfave/cli/v2"
 )
@@ -61,6 +62,10 @@ var microcmdUserCreate = &cli.Command{
 			Name:  "access-token",
 			Usage: "Generate access token for the user",
 		},
+		&cli.DurationFlag{
+			Name:  "expires-in",
+			Usage: "Duration after which the access token expires, required if the maximum lifetime of the tokens is limited",
+		},
 		&cli.BoolFlag{
 			Name:  "restricted",
 			Usage: "Make a restricted user account",
@@ -99,6 +104,11 @@ func runCreateUser(c *cli.Context) error {
 		return err
 	}
 
+	// fail before the user is created rather than when its token is
+	if c.Bool("access-token") && setting.AccessTokenMaxLifetime > 0 && !c.IsSet("expires-in") {
+		return fmt.Errorf("the access token must expire within %s, set the --expires-in flag", setting.AccessTokenMaxLifetime)
+	}
+
 	var password string
 	if c.IsSet("password") {
 		password = c.String("password")
@@ -158,12 +168,15 @@ func runCreateUser(c *cli.Context) error {
 	}
 
 	if c.Bool("access-token") {
-		t := &auth_model.AccessToken{
+		opts := &auth_service.AccessTokenOptions{
 			Name: "gitea-admin",
-			UID:  u.ID,
+		}
+		if c.IsSet("expires-in") {
+			opts.ExpiresUnix = timeutil.TimeStampNow().AddDuration(c.Duration("expires-in"))
 		}
 
-		if err := auth_model.NewAccessToken(ctx, t); err != nil {
+		t, err := auth_service.CreateAccessToken(ctx, nil, u, opts)
+		if err != nil {
 			return err
 		}
 
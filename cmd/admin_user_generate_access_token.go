@@ -9,6 +9,8 @@ import (
 
 	auth_model "code.gitea.io/gitea/models/auth"
 	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/timeutil"
+	auth_service "code.gitea.io/gitea/services/auth"
 
 	"github.com/urfave/cli/v2"
 )
@@ -37,6 +39,10 @@ var microcmdUserGenerateAccessToken = &cli.Command{
 			Value: "",
 			Usage: "Comma separated list of scopes to apply to access token",
 		},
+		&cli.DurationFlag{
+			Name:  "expires-in",
+			Usage: "Duration after which the access token expires, required if the maximum lifetime of the tokens is limited",
+		},
 	},
 	Action: runGenerateAccessToken,
 }
@@ -77,10 +83,18 @@ func runGenerateAccessToken(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("invalid access token scope provided: %w", err)
 	}
-	t.Scope = accessTokenScope
+
+	opts := &auth_service.AccessTokenOptions{
+		Name:  t.Name,
+		Scope: accessTokenScope,
+	}
+	if c.IsSet("expires-in") {
+		opts.ExpiresUnix = timeutil.TimeStampNow().AddDuration(c.Duration("expires-in"))
+	}
 
 	// create the token
-	if err := auth_model.NewAccessToken(ctx, t); err != nil {
+	t, err = auth_service.CreateAccessToken(ctx, nil, user, opts)
+	if err != nil {
 		return err
 	}
 
@@ -584,6 +584,7 @@ func DeleteOldActions(ctx context.Context, olderThan time.Duration) (err error)
 }
 
 // NotifyWatchers creates batch of actions for every watcher.
+// Afterwards the actions refer to the feed entries of their actioners.
 func NotifyWatchers(ctx context.Context, actions ...*Action) error {
 	var watchers []*repo_model.Watch
 	var repo *repo_model.Repository
@@ -625,6 +626,7 @@ func NotifyWatchers(ctx context.Context, actions ...*Action) error {
 		if _, err = e.Insert(act); err != nil {
 			return fmt.Errorf("insert new actioner: %w", err)
 		}
+		actionerActionID := act.ID
 
 		if repoChanged {
 			act.loadRepo(ctx)
@@ -699,6 +701,9 @@ func NotifyWatchers(ctx context.Context, actions ...*Action) error {
 				return fmt.Errorf("insert new action: %w", err)
 			}
 		}
+
+		act.ID = actionerActionID
+		act.UserID = act.ActUserID
 	}
 	return nil
 }
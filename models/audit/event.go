@@ -0,0 +1,179 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package audit
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/builder"
+)
+
+func init() {
+	db.RegisterModel(new(Event))
+}
+
+// Action is the kind of a recorded event
+type Action string
+
+const (
+	UserAccessTokenAdd    Action = "user_access_token_add"
+	UserAccessTokenRemove Action = "user_access_token_remove"
+	UserKeySSHAdd         Action = "user_key_ssh_add"
+	UserKeySSHRemove      Action = "user_key_ssh_remove"
+	UserDelete            Action = "user_delete"
+	UserImpersonation     Action = "user_impersonation"
+	UserAdmin             Action = "user_admin"
+	UserRestricted        Action = "user_restricted"
+
+	OrganizationDelete           Action = "organization_delete"
+	OrganizationMemberRemove     Action = "organization_member_remove"
+	OrganizationTeamAdd          Action = "organization_team_add"
+	OrganizationTeamUpdate       Action = "organization_team_update"
+	OrganizationTeamRemove       Action = "organization_team_remove"
+	OrganizationTeamMemberAdd    Action = "organization_team_member_add"
+	OrganizationTeamMemberRemove Action = "organization_team_member_remove"
+
+	RepositoryCollaboratorAdd        Action = "repository_collaborator_add"
+	RepositoryCollaboratorAccess     Action = "repository_collaborator_access"
+	RepositoryCollaboratorRemove     Action = "repository_collaborator_remove"
+	RepositoryBranchProtectionAdd    Action = "repository_branch_protection_add"
+	RepositoryBranchProtectionUpdate Action = "repository_branch_protection_update"
+	RepositoryBranchProtectionRemove Action = "repository_branch_protection_remove"
+	RepositoryDeployKeyAdd           Action = "repository_deploy_key_add"
+	RepositoryDeployKeyRemove        Action = "repository_deploy_key_remove"
+	RepositoryVisibility             Action = "repository_visibility"
+	RepositoryTransfer               Action = "repository_transfer"
+	RepositoryDelete                 Action = "repository_delete"
+)
+
+// Actions lists all known actions, in the order they are offered for filtering
+var Actions = []Action{
+	UserAccessTokenAdd,
+	UserAccessTokenRemove,
+	UserKeySSHAdd,
+	UserKeySSHRemove,
+	UserDelete,
+	UserImpersonation,
+	UserAdmin,
+	UserRestricted,
+	OrganizationDelete,
+	OrganizationMemberRemove,
+	OrganizationTeamAdd,
+	OrganizationTeamUpdate,
+	OrganizationTeamRemove,
+	OrganizationTeamMemberAdd,
+	OrganizationTeamMemberRemove,
+	RepositoryCollaboratorAdd,
+	RepositoryCollaboratorAccess,
+	RepositoryCollaboratorRemove,
+	RepositoryBranchProtectionAdd,
+	RepositoryBranchProtectionUpdate,
+	RepositoryBranchProtectionRemove,
+	RepositoryDeployKeyAdd,
+	RepositoryDeployKeyRemove,
+	RepositoryVisibility,
+	RepositoryTransfer,
+	RepositoryDelete,
+}
+
+// IsValid reports whether the action is a known one
+func (a Action) IsValid() bool {
+	for _, action := range Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetType is the kind of object an event acted on
+type TargetType string
+
+const (
+	TargetUser             TargetType = "user"
+	TargetOrganization     TargetType = "organization"
+	TargetTeam             TargetType = "team"
+	TargetRepository       TargetType = "repository"
+	TargetBranchProtection TargetType = "branch_protection"
+	TargetAccessToken      TargetType = "access_token"
+	TargetPublicKey        TargetType = "public_key"
+	TargetDeployKey        TargetType = "deploy_key"
+)
+
+// Event is a security relevant action, events are never updated nor deleted.
+// The names of the actor and the target are kept, so events stay readable once they are deleted.
+type Event struct {
+	ID        int64  `xorm:"pk autoincr"`
+	Action    Action `xorm:"VARCHAR(64) INDEX NOT NULL"`
+	ActorID   int64  `xorm:"INDEX NOT NULL"`
+	ActorName string `xorm:"NOT NULL"`
+	// OwnerID is the user or organization the event belongs to, 0 for instance wide events
+	OwnerID int64 `xorm:"INDEX NOT NULL DEFAULT 0"`
+	// RepoID is set for events in a repository
+	RepoID      int64      `xorm:"INDEX NOT NULL DEFAULT 0"`
+	TargetType  TargetType `xorm:"VARCHAR(32) INDEX NOT NULL"`
+	TargetID    int64      `xorm:"INDEX NOT NULL"`
+	TargetName  string     `xorm:"NOT NULL"`
+	Message     string     `xorm:"TEXT"`
+	IPAddress   string
+	CreatedUnix timeutil.TimeStamp `xorm:"created INDEX NOT NULL"`
+}
+
+func (Event) TableName() string {
+	return "forgejo_audit_event"
+}
+
+// InsertEvent appends an event to the audit log
+func InsertEvent(ctx context.Context, e *Event) error {
+	return db.Insert(ctx, e)
+}
+
+// FindEventsOptions filters the audit log, zero values do not filter
+type FindEventsOptions struct {
+	db.ListOptions
+	Action     Action
+	ActorID    int64
+	OwnerID    int64
+	RepoID     int64
+	TargetType TargetType
+	TargetID   int64
+	Since      timeutil.TimeStamp
+	Before     timeutil.TimeStamp
+}
+
+func (opts FindEventsOptions) ToConds() builder.Cond {
+	cond := builder.NewCond()
+	if opts.Action != "" {
+		cond = cond.And(builder.Eq{"action": opts.Action})
+	}
+	if opts.ActorID != 0 {
+		cond = cond.And(builder.Eq{"actor_id": opts.ActorID})
+	}
+	if opts.OwnerID != 0 {
+		cond = cond.And(builder.Eq{"owner_id": opts.OwnerID})
+	}
+	if opts.RepoID != 0 {
+		cond = cond.And(builder.Eq{"repo_id": opts.RepoID})
+	}
+	if opts.TargetType != "" {
+		cond = cond.And(builder.Eq{"target_type": opts.TargetType})
+	}
+	if opts.TargetID != 0 {
+		cond = cond.And(builder.Eq{"target_id": opts.TargetID})
+	}
+	if opts.Since != 0 {
+		cond = cond.And(builder.Gte{"created_unix": opts.Since})
+	}
+	if opts.Before != 0 {
+		cond = cond.And(builder.Lt{"created_unix": opts.Before})
+	}
+	return cond
+}
+
+func (opts FindEventsOptions) ToOrders() string {
+	return "created_unix DESC, id DESC"
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package audit_test
+
+import (
+	"testing"
+
+	audit_model "code.gitea.io/gitea/models/audit"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindEvents(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	insert := func(action audit_model.Action, actorID, ownerID int64, targetType audit_model.TargetType, targetID int64, created timeutil.TimeStamp) int64 {
+		e := &audit_model.Event{
+			Action:      action,
+			ActorID:     actorID,
+			ActorName:   "actor",
+			OwnerID:     ownerID,
+			TargetType:  targetType,
+			TargetID:    targetID,
+			TargetName:  "target",
+			CreatedUnix: created,
+		}
+		_, err := db.GetEngine(db.DefaultContext).NoAutoTime().Insert(e)
+		require.NoError(t, err)
+		return e.ID
+	}
+	token := insert(audit_model.UserAccessTokenAdd, 1, 1, audit_model.TargetAccessToken, 10, 1000)
+	team := insert(audit_model.OrganizationTeamAdd, 2, 3, audit_model.TargetTeam, 20, 2000)
+	member := insert(audit_model.OrganizationTeamMemberAdd, 1, 3, audit_model.TargetUser, 2, 3000)
+	deleted := insert(audit_model.UserDelete, 1, 0, audit_model.TargetUser, 5, 4000)
+
+	find := func(opts audit_model.FindEventsOptions) []int64 {
+		events, err := db.Find[audit_model.Event](db.DefaultContext, opts)
+		require.NoError(t, err)
+		ids := make([]int64, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []int64{deleted, member, team, token}, find(audit_model.FindEventsOptions{}))
+	assert.Equal(t, []int64{deleted, member, token}, find(audit_model.FindEventsOptions{ActorID: 1}))
+	assert.Equal(t, []int64{member, team}, find(audit_model.FindEventsOptions{OwnerID: 3}))
+	assert.Equal(t, []int64{team}, find(audit_model.FindEventsOptions{Action: audit_model.OrganizationTeamAdd}))
+	assert.Equal(t, []int64{deleted, member}, find(audit_model.FindEventsOptions{TargetType: audit_model.TargetUser}))
+	assert.Equal(t, []int64{member}, find(audit_model.FindEventsOptions{TargetType: audit_model.TargetUser, TargetID: 2}))
+	assert.Equal(t, []int64{member, team}, find(audit_model.FindEventsOptions{Since: 2000, Before: 4000}))
+	assert.Empty(t, find(audit_model.FindEventsOptions{OwnerID: 3, Action: audit_model.UserDelete}))
+}
+
+func TestActionIsValid(t *testing.T) {
+	assert.True(t, audit_model.RepositoryDelete.IsValid())
+	assert.False(t, audit_model.Action("repository_launch").IsValid())
+	assert.False(t, audit_model.Action("").IsValid())
+}
@@ -9,6 +9,7 @@ import (
 	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"time"
 
 	"code.gitea.io/gitea/models/db"
@@ -69,8 +70,15 @@ type AccessToken struct {
 	TokenLastEight string `xorm:"INDEX token_last_eight"`
 	Scope          AccessTokenScope
 
+	// OrgID restricts the token to the repositories of an organization
+	OrgID int64 `xorm:"NOT NULL DEFAULT 0"`
+	// RepoIDs restricts the token to a list of repositories
+	RepoIDs []int64 `xorm:"JSON TEXT"`
+
 	CreatedUnix       timeutil.TimeStamp `xorm:"INDEX created"`
-	UpdatedUnix       timeutil.TimeStamp `xorm:"INDEX updated"`
+	UpdatedUnix       timeutil.TimeStamp `xorm:"INDEX updated"` // also the last time the token was used
+	ExpiresUnix       timeutil.TimeStamp `xorm:"INDEX NOT NULL DEFAULT 0"`
+	ExpiryNotified    bool               `xorm:"NOT NULL DEFAULT false"`
 	HasRecentActivity bool               `xorm:"-"`
 	HasUsed           bool               `xorm:"-"`
 }
@@ -114,6 +122,24 @@ func NewAccessToken(ctx context.Context, t *AccessToken) error {
 	return err
 }
 
+// IsExpired returns whether the token has an expiry date which has passed
+func (t *AccessToken) IsExpired() bool {
+	return t.ExpiresUnix != 0 && t.ExpiresUnix <= timeutil.TimeStampNow()
+}
+
+// IsRepoRestricted returns whether the token is restricted to the repositories of an organization or to a list of repositories
+func (t *AccessToken) IsRepoRestricted() bool {
+	return t.OrgID != 0 || len(t.RepoIDs) > 0
+}
+
+// AllowsRepo returns whether the token can be used to access a repository
+func (t *AccessToken) AllowsRepo(repoID, ownerID int64) bool {
+	if !t.IsRepoRestricted() {
+		return true
+	}
+	return (t.OrgID != 0 && t.OrgID == ownerID) || slices.Contains(t.RepoIDs, repoID)
+}
+
 // DisplayPublicOnly whether to display this as a public-only token.
 func (t *AccessToken) DisplayPublicOnly() bool {
 	publicOnly, err := t.Scope.PublicOnly()
@@ -165,6 +191,9 @@ func GetAccessTokenBySHA(ctx context.Context, token string) (*AccessToken, error
 			return nil, err
 		}
 		if has {
+			if accessToken.IsExpired() {
+				return nil, ErrAccessTokenNotExist{token}
+			}
 			return accessToken, nil
 		}
 		successfulAccessTokenCache.Remove(token)
@@ -181,6 +210,9 @@ func GetAccessTokenBySHA(ctx context.Context, token string) (*AccessToken, error
 	for _, t := range tokens {
 		tempHash := HashToken(token, t.TokenSalt)
 		if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(tempHash)) == 1 {
+			if t.IsExpired() {
+				return nil, ErrAccessTokenNotExist{token}
+			}
 			if successfulAccessTokenCache != nil {
 				successfulAccessTokenCache.Add(token, t.ID)
 			}
@@ -216,12 +248,39 @@ func (opts ListAccessTokensOptions) ToOrders() string {
 	return "created_unix DESC"
 }
 
+// FindAccessTokensToNotifyExpiry returns the access tokens expiring before the given time whose owner has not been notified yet
+func FindAccessTokensToNotifyExpiry(ctx context.Context, before timeutil.TimeStamp) ([]*AccessToken, error) {
+	tokens := make([]*AccessToken, 0, 10)
+	return tokens, db.GetEngine(ctx).
+		Where("expires_unix > ? AND expires_unix <= ? AND expiry_notified = ?", timeutil.TimeStampNow(), before, false).
+		OrderBy("uid, expires_unix").
+		Find(&tokens)
+}
+
+// SetAccessTokenExpiryNotified records that the owner of an access token has been notified of its expiry
+func SetAccessTokenExpiryNotified(ctx context.Context, id int64) error {
+	_, err := db.GetEngine(ctx).ID(id).Cols("expiry_notified").NoAutoTime().Update(&AccessToken{ExpiryNotified: true})
+	return err
+}
+
 // UpdateAccessToken updates information of access token.
 func UpdateAccessToken(ctx context.Context, t *AccessToken) error {
 	_, err := db.GetEngine(ctx).ID(t.ID).AllCols().Update(t)
 	return err
 }
 
+// GetAccessTokenByID returns the access token of the user with the given ID.
+func GetAccessTokenByID(ctx context.Context, id, userID int64) (*AccessToken, error) {
+	t := &AccessToken{}
+	has, err := db.GetEngine(ctx).Where("id = ? AND uid = ?", id, userID).Get(t)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, ErrAccessTokenNotExist{}
+	}
+	return t, nil
+}
+
 // DeleteAccessTokenByID deletes access token by given ID.
 func DeleteAccessTokenByID(ctx context.Context, id, userID int64) error {
 	cnt, err := db.GetEngine(ctx).ID(id).Delete(&AccessToken{
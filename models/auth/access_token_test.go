@@ -9,6 +9,7 @@ import (
 	auth_model "code.gitea.io/gitea/models/auth"
 	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/timeutil"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -117,6 +118,21 @@ func TestUpdateAccessToken(t *testing.T) {
 	unittest.AssertExistsAndLoadBean(t, token)
 }
 
+func TestGetAccessTokenByID(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	token, err := auth_model.GetAccessTokenBySHA(db.DefaultContext, "4c6f36e6cf498e2a448662f915d932c09c5a146c")
+	require.NoError(t, err)
+
+	got, err := auth_model.GetAccessTokenByID(db.DefaultContext, token.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, token.Name, got.Name)
+
+	_, err = auth_model.GetAccessTokenByID(db.DefaultContext, token.ID, 2)
+	require.Error(t, err)
+	assert.True(t, auth_model.IsErrAccessTokenNotExist(err))
+}
+
 func TestDeleteAccessTokenByID(t *testing.T) {
 	require.NoError(t, unittest.PrepareTestDatabase())
 
@@ -131,3 +147,70 @@ func TestDeleteAccessTokenByID(t *testing.T) {
 	require.Error(t, err)
 	assert.True(t, auth_model.IsErrAccessTokenNotExist(err))
 }
+
+func TestAccessTokenAllowsRepo(t *testing.T) {
+	token := &auth_model.AccessToken{}
+	assert.False(t, token.IsRepoRestricted())
+	assert.True(t, token.AllowsRepo(1, 2))
+
+	token = &auth_model.AccessToken{RepoIDs: []int64{1, 4}}
+	assert.True(t, token.IsRepoRestricted())
+	assert.True(t, token.AllowsRepo(1, 2))
+	assert.True(t, token.AllowsRepo(4, 5))
+	assert.False(t, token.AllowsRepo(2, 2))
+
+	token = &auth_model.AccessToken{OrgID: 3}
+	assert.True(t, token.IsRepoRestricted())
+	assert.True(t, token.AllowsRepo(3, 3))
+	assert.False(t, token.AllowsRepo(1, 2))
+}
+
+func TestGetAccessTokenBySHAExpired(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	token := &auth_model.AccessToken{
+		UID:         2,
+		Name:        "Token expired",
+		ExpiresUnix: timeutil.TimeStampNow().Add(-60),
+	}
+	require.NoError(t, auth_model.NewAccessToken(db.DefaultContext, token))
+	assert.True(t, token.IsExpired())
+
+	_, err := auth_model.GetAccessTokenBySHA(db.DefaultContext, token.Token)
+	require.Error(t, err)
+	assert.True(t, auth_model.IsErrAccessTokenNotExist(err))
+
+	token = &auth_model.AccessToken{
+		UID:         2,
+		Name:        "Token expiring",
+		ExpiresUnix: timeutil.TimeStampNow().Add(3600),
+	}
+	require.NoError(t, auth_model.NewAccessToken(db.DefaultContext, token))
+	assert.False(t, token.IsExpired())
+
+	got, err := auth_model.GetAccessTokenBySHA(db.DefaultContext, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, got.ID)
+}
+
+func TestFindAccessTokensToNotifyExpiry(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	soon := &auth_model.AccessToken{UID: 2, Name: "Token soon", ExpiresUnix: timeutil.TimeStampNow().Add(3600)}
+	require.NoError(t, auth_model.NewAccessToken(db.DefaultContext, soon))
+	later := &auth_model.AccessToken{UID: 2, Name: "Token later", ExpiresUnix: timeutil.TimeStampNow().Add(30 * 24 * 3600)}
+	require.NoError(t, auth_model.NewAccessToken(db.DefaultContext, later))
+	expired := &auth_model.AccessToken{UID: 2, Name: "Token expired", ExpiresUnix: timeutil.TimeStampNow().Add(-60)}
+	require.NoError(t, auth_model.NewAccessToken(db.DefaultContext, expired))
+
+	tokens, err := auth_model.FindAccessTokensToNotifyExpiry(db.DefaultContext, timeutil.TimeStampNow().Add(7*24*3600))
+	require.NoError(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, soon.ID, tokens[0].ID)
+	}
+
+	require.NoError(t, auth_model.SetAccessTokenExpiryNotified(db.DefaultContext, soon.ID))
+	tokens, err = auth_model.FindAccessTokensToNotifyExpiry(db.DefaultContext, timeutil.TimeStampNow().Add(7*24*3600))
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+
+	"xorm.io/builder"
+)
+
+// userCodeChars are the characters of the user codes, without vowels to avoid forming words and
+// without the characters easily confused with each other, as recommended by RFC 8628 section 6.1
+const userCodeChars = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// OAuth2DeviceAuthorization is a device authorization request of the device authorization grant (RFC 8628).
+// The device polls the token endpoint with the device code, until the user enters the user code
+// in their browser and approves or denies the authorization.
+type OAuth2DeviceAuthorization struct {
+	ID              int64              `xorm:"pk autoincr"`
+	Application     *OAuth2Application `xorm:"-"`
+	ApplicationID   int64              `xorm:"INDEX"`
+	DeviceCode      string             `xorm:"INDEX unique"`
+	UserCode        string             `xorm:"INDEX unique"`
+	Scope           string             `xorm:"TEXT"`
+	GrantID         int64              `xorm:"NOT NULL DEFAULT 0"` // set once the user approved the authorization
+	Denied          bool               `xorm:"NOT NULL DEFAULT false"`
+	PollingInterval int64              // minimum number of seconds between two polls of the device
+	LastPolledUnix  timeutil.TimeStamp
+	ValidUntil      timeutil.TimeStamp `xorm:"INDEX"`
+	CreatedUnix     timeutil.TimeStamp `xorm:"created"`
+}
+
+// TableName provides the real table name
+func (OAuth2DeviceAuthorization) TableName() string {
+	return "forgejo_oauth2_device_authorization"
+}
+
+func init() {
+	db.RegisterModel(new(OAuth2DeviceAuthorization))
+}
+
+// NormalizeUserCode returns a user code as stored in the database, the users may enter it
+// in lower case and with or without the separator
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+// FormattedUserCode returns the user code as shown to the users, e.g. WDJB-MJHT
+func (d *OAuth2DeviceAuthorization) FormattedUserCode() string {
+	return d.UserCode[:userCodeLength/2] + "-" + d.UserCode[userCodeLength/2:]
+}
+
+// IsExpired returns whether the device and user codes can no longer be used
+func (d *OAuth2DeviceAuthorization) IsExpired() bool {
+	return d.ValidUntil <= timeutil.TimeStampNow()
+}
+
+// IsPending returns whether the user neither approved nor denied the authorization yet
+func (d *OAuth2DeviceAuthorization) IsPending() bool {
+	return d.GrantID == 0 && !d.Denied
+}
+
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := util.CryptoRandomInt(int64(len(userCodeChars)))
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeChars[n]
+	}
+	return string(code), nil
+}
+
+// CreateDeviceAuthorization creates a device authorization request for the application,
+// the expired requests of all the applications are deleted at the same time
+func (app *OAuth2Application) CreateDeviceAuthorization(ctx context.Context, scope string) (*OAuth2DeviceAuthorization, error) {
+	rBytes, err := util.CryptoRandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	d := &OAuth2DeviceAuthorization{
+		Application:   app,
+		ApplicationID: app.ID,
+		// Add a prefix to the base32, this is in order to make it easier
+		// for code scanners to grab sensitive tokens.
+		DeviceCode:      "gtd_" + base32Lower.EncodeToString(rBytes),
+		Scope:           scope,
+		PollingInterval: setting.OAuth2.DeviceCodePollingInterval,
+		ValidUntil:      timeutil.TimeStampNow().Add(setting.OAuth2.DeviceCodeExpirationTime),
+	}
+
+	return d, db.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := db.GetEngine(ctx).Where("valid_until <= ?", timeutil.TimeStampNow()).Delete(new(OAuth2DeviceAuthorization)); err != nil {
+			return err
+		}
+		for {
+			if d.UserCode, err = generateUserCode(); err != nil {
+				return err
+			}
+			exists, err := db.GetEngine(ctx).Exist(&OAuth2DeviceAuthorization{UserCode: d.UserCode})
+			if err != nil {
+				return err
+			} else if !exists {
+				break
+			}
+		}
+		return db.Insert(ctx, d)
+	})
+}
+
+func getOAuth2DeviceAuthorization(ctx context.Context, cond builder.Cond) (*OAuth2DeviceAuthorization, error) {
+	d := &OAuth2DeviceAuthorization{}
+	has, err := db.GetEngine(ctx).Where(cond).Get(d)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, util.NewNotExistErrorf("device authorization does not exist")
+	}
+	if d.Application, err = GetOAuth2ApplicationByID(ctx, d.ApplicationID); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetOAuth2DeviceAuthorizationByDeviceCode returns the device authorization request of a device code
+func GetOAuth2DeviceAuthorizationByDeviceCode(ctx context.Context, deviceCode string) (*OAuth2DeviceAuthorization, error) {
+	return getOAuth2DeviceAuthorization(ctx, builder.Eq{"device_code": deviceCode})
+}
+
+// GetPendingOAuth2DeviceAuthorizationByUserCode returns the device authorization request of a user code,
+// if it is neither expired nor already approved or denied
+func GetPendingOAuth2DeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*OAuth2DeviceAuthorization, error) {
+	return getOAuth2DeviceAuthorization(ctx, builder.Eq{"user_code": NormalizeUserCode(userCode), "grant_id": 0, "denied": false}.
+		And(builder.Gt{"valid_until": timeutil.TimeStampNow()}))
+}
+
+// Approve records that a user approved the authorization, with their grant of the application
+// which is created if needed and otherwise updated to the scope of the authorization.
+// It fails if the authorization was already approved or denied by a concurrent request.
+func (d *OAuth2DeviceAuthorization) Approve(ctx context.Context, userID int64) (*OAuth2Grant, error) {
+	var grant *OAuth2Grant
+	return grant, db.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if grant, err = d.Application.GetGrantByUserID(ctx, userID); err != nil {
+			return err
+		}
+		if grant == nil {
+			if grant, err = d.Application.CreateGrant(ctx, userID, d.Scope); err != nil {
+				return err
+			}
+		} else if grant.Scope != d.Scope {
+			grant.Scope = d.Scope
+			if _, err := db.GetEngine(ctx).ID(grant.ID).Cols("scope").Update(grant); err != nil {
+				return err
+			}
+		}
+		d.GrantID = grant.ID
+		return d.updatePending(ctx, "grant_id")
+	})
+}
+
+// Deny records that the user denied the authorization,
+// it fails if the authorization was already approved or denied by a concurrent request
+func (d *OAuth2DeviceAuthorization) Deny(ctx context.Context) error {
+	d.Denied = true
+	return d.updatePending(ctx, "denied")
+}
+
+func (d *OAuth2DeviceAuthorization) updatePending(ctx context.Context, col string) error {
+	updated, err := db.GetEngine(ctx).ID(d.ID).Where(builder.Eq{"grant_id": 0, "denied": false}).Cols(col).Update(d)
+	if err != nil {
+		return err
+	} else if updated == 0 {
+		return util.NewNotExistErrorf("device authorization is not pending")
+	}
+	return nil
+}
+
+// Poll records a poll of the device and returns whether it polled too quickly,
+// in which case its polling interval is increased by 5 seconds as required by RFC 8628 section 3.5
+func (d *OAuth2DeviceAuthorization) Poll(ctx context.Context) (bool, error) {
+	now := timeutil.TimeStampNow()
+	slowDown := d.LastPolledUnix != 0 && now < d.LastPolledUnix.Add(d.PollingInterval)
+	if slowDown {
+		d.PollingInterval += 5
+	}
+	d.LastPolledUnix = now
+	_, err := db.GetEngine(ctx).ID(d.ID).Cols("polling_interval", "last_polled_unix").Update(d)
+	return slowDown, err
+}
+
+// Invalidate deletes the device authorization so that its device code cannot be used again,
+// it fails if the device authorization was already deleted by a concurrent request
+func (d *OAuth2DeviceAuthorization) Invalidate(ctx context.Context) error {
+	deleted, err := db.GetEngine(ctx).ID(d.ID).NoAutoCondition().Delete(d)
+	if err != nil {
+		return err
+	} else if deleted == 0 {
+		return util.NewNotExistErrorf("device authorization does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeUserCode(t *testing.T) {
+	assert.Equal(t, "WDJBMJHT", auth_model.NormalizeUserCode("WDJB-MJHT"))
+	assert.Equal(t, "WDJBMJHT", auth_model.NormalizeUserCode("wdjb mjht"))
+	assert.Equal(t, "WDJBMJHT", auth_model.NormalizeUserCode("wdjbmjht"))
+}
+
+func TestCreateDeviceAuthorization(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	app := unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Application{ID: 1})
+
+	d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(d.DeviceCode, "gtd_"))
+	assert.Len(t, d.UserCode, 8)
+	assert.Regexp(t, "^[A-Z]{4}-[A-Z]{4}$", d.FormattedUserCode())
+	assert.True(t, d.IsPending())
+	assert.False(t, d.IsExpired())
+
+	loaded, err := auth_model.GetOAuth2DeviceAuthorizationByDeviceCode(db.DefaultContext, d.DeviceCode)
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, loaded.ID)
+	assert.Equal(t, app.ID, loaded.Application.ID)
+
+	loaded, err = auth_model.GetPendingOAuth2DeviceAuthorizationByUserCode(db.DefaultContext, strings.ToLower(d.FormattedUserCode()))
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, loaded.ID)
+
+	_, err = auth_model.GetOAuth2DeviceAuthorizationByDeviceCode(db.DefaultContext, "gtd_missing")
+	require.ErrorIs(t, err, util.ErrNotExist)
+}
+
+func TestCreateDeviceAuthorizationDeletesExpired(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	app := unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Application{ID: 1})
+
+	expired, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+	require.NoError(t, err)
+	_, err = db.GetEngine(db.DefaultContext).ID(expired.ID).Cols("valid_until").
+		Update(&auth_model.OAuth2DeviceAuthorization{ValidUntil: timeutil.TimeStampNow().Add(-60)})
+	require.NoError(t, err)
+
+	_, err = auth_model.GetPendingOAuth2DeviceAuthorizationByUserCode(db.DefaultContext, expired.UserCode)
+	require.ErrorIs(t, err, util.ErrNotExist)
+
+	_, err = app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+	require.NoError(t, err)
+	unittest.AssertNotExistsBean(t, &auth_model.OAuth2DeviceAuthorization{ID: expired.ID})
+}
+
+func TestOAuth2DeviceAuthorization_ApproveAndDeny(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	app := unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Application{ID: 1})
+
+	t.Run("NewGrant", func(t *testing.T) {
+		d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid profile")
+		require.NoError(t, err)
+		grant, err := d.Approve(db.DefaultContext, 4)
+		require.NoError(t, err)
+		unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Grant{ID: grant.ID, UserID: 4, ApplicationID: app.ID, Scope: "openid profile"})
+		unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2DeviceAuthorization{ID: d.ID, GrantID: grant.ID})
+		_, err = auth_model.GetPendingOAuth2DeviceAuthorizationByUserCode(db.DefaultContext, d.UserCode)
+		require.ErrorIs(t, err, util.ErrNotExist)
+	})
+
+	t.Run("ExistingGrantWithOtherScope", func(t *testing.T) {
+		d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid email")
+		require.NoError(t, err)
+		grant, err := d.Approve(db.DefaultContext, 1)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, grant.ID)
+		unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Grant{ID: 1, Scope: "openid email"})
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+		require.NoError(t, err)
+		require.NoError(t, d.Deny(db.DefaultContext))
+		loaded, err := auth_model.GetOAuth2DeviceAuthorizationByDeviceCode(db.DefaultContext, d.DeviceCode)
+		require.NoError(t, err)
+		assert.True(t, loaded.Denied)
+		assert.False(t, loaded.IsPending())
+		_, err = auth_model.GetPendingOAuth2DeviceAuthorizationByUserCode(db.DefaultContext, d.UserCode)
+		require.ErrorIs(t, err, util.ErrNotExist)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+		require.NoError(t, err)
+		// both requests loaded the pending authorization before either of them updated it
+		approving, err := auth_model.GetPendingOAuth2DeviceAuthorizationByUserCode(db.DefaultContext, d.UserCode)
+		require.NoError(t, err)
+		denying, err := auth_model.GetPendingOAuth2DeviceAuthorizationByUserCode(db.DefaultContext, d.UserCode)
+		require.NoError(t, err)
+
+		require.NoError(t, denying.Deny(db.DefaultContext))
+		_, err = approving.Approve(db.DefaultContext, 4)
+		require.ErrorIs(t, err, util.ErrNotExist)
+		unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2DeviceAuthorization{ID: d.ID, GrantID: 0, Denied: true})
+		require.ErrorIs(t, denying.Deny(db.DefaultContext), util.ErrNotExist)
+	})
+}
+
+func TestOAuth2DeviceAuthorization_Poll(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	app := unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Application{ID: 1})
+
+	d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+	require.NoError(t, err)
+	interval := d.PollingInterval
+
+	slowDown, err := d.Poll(db.DefaultContext)
+	require.NoError(t, err)
+	assert.False(t, slowDown)
+	assert.Equal(t, interval, d.PollingInterval)
+
+	slowDown, err = d.Poll(db.DefaultContext)
+	require.NoError(t, err)
+	assert.True(t, slowDown)
+	assert.Equal(t, interval+5, d.PollingInterval)
+	unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2DeviceAuthorization{ID: d.ID, PollingInterval: interval + 5})
+}
+
+func TestOAuth2DeviceAuthorization_Invalidate(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	app := unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Application{ID: 1})
+
+	d, err := app.CreateDeviceAuthorization(db.DefaultContext, "openid")
+	require.NoError(t, err)
+	require.NoError(t, d.Invalidate(db.DefaultContext))
+	unittest.AssertNotExistsBean(t, &auth_model.OAuth2DeviceAuthorization{ID: d.ID})
+	require.ErrorIs(t, d.Invalidate(db.DefaultContext), util.ErrNotExist)
+}
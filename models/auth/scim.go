@@ -0,0 +1,231 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+
+	"xorm.io/builder"
+)
+
+// SCIMToken authenticates the identity provider provisioning the users and the groups of an authentication source
+type SCIMToken struct {
+	ID             int64  `xorm:"pk autoincr"`
+	SourceID       int64  `xorm:"UNIQUE"`
+	TokenHash      string `xorm:"UNIQUE"` // sha256 of token
+	TokenSalt      string
+	TokenLastEight string             `xorm:"INDEX token_last_eight"`
+	CreatedUnix    timeutil.TimeStamp `xorm:"created"`
+}
+
+// TableName provides the real table name
+func (SCIMToken) TableName() string {
+	return "forgejo_scim_token"
+}
+
+// SCIMGroup is a group provisioned by the identity provider of an authentication source,
+// the groups of a user are mapped to organization teams by the group team map of the source
+type SCIMGroup struct {
+	ID          int64  `xorm:"pk autoincr"`
+	SourceID    int64  `xorm:"UNIQUE(s)"`
+	DisplayName string `xorm:"UNIQUE(s)"`
+	ExternalID  string
+	CreatedUnix timeutil.TimeStamp `xorm:"created"`
+	UpdatedUnix timeutil.TimeStamp `xorm:"updated"`
+}
+
+// TableName provides the real table name
+func (SCIMGroup) TableName() string {
+	return "forgejo_scim_group"
+}
+
+// SCIMGroupMember is the membership of a user in a SCIM group
+type SCIMGroupMember struct {
+	ID      int64 `xorm:"pk autoincr"`
+	GroupID int64 `xorm:"UNIQUE(s)"`
+	UserID  int64 `xorm:"UNIQUE(s) INDEX"`
+}
+
+// TableName provides the real table name
+func (SCIMGroupMember) TableName() string {
+	return "forgejo_scim_group_member"
+}
+
+func init() {
+	db.RegisterModel(new(SCIMToken))
+	db.RegisterModel(new(SCIMGroup))
+	db.RegisterModel(new(SCIMGroupMember))
+}
+
+// GenerateSCIMToken replaces the SCIM token of an authentication source and returns the new token
+func GenerateSCIMToken(ctx context.Context, sourceID int64) (string, error) {
+	salt, err := util.CryptoRandomString(10)
+	if err != nil {
+		return "", err
+	}
+	random, err := util.CryptoRandomBytes(20)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(random)
+	t := &SCIMToken{
+		SourceID:       sourceID,
+		TokenHash:      HashToken(token, salt),
+		TokenSalt:      salt,
+		TokenLastEight: token[len(token)-8:],
+	}
+	return token, db.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := db.GetEngine(ctx).Delete(&SCIMToken{SourceID: sourceID}); err != nil {
+			return err
+		}
+		return db.Insert(ctx, t)
+	})
+}
+
+// GetSCIMTokenBySourceID returns the SCIM token of an authentication source, without the token itself
+func GetSCIMTokenBySourceID(ctx context.Context, sourceID int64) (*SCIMToken, error) {
+	t := &SCIMToken{}
+	has, err := db.GetEngine(ctx).Where("source_id = ?", sourceID).Get(t)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, util.NewNotExistErrorf("authentication source %d has no SCIM token", sourceID)
+	}
+	return t, nil
+}
+
+// GetSourceBySCIMToken returns the authentication source a SCIM token belongs to
+func GetSourceBySCIMToken(ctx context.Context, token string) (*Source, error) {
+	if len(token) != 40 {
+		return nil, util.NewNotExistErrorf("invalid SCIM token")
+	}
+	var tokens []*SCIMToken
+	if err := db.GetEngine(ctx).Where("token_last_eight = ?", token[len(token)-8:]).Find(&tokens); err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(HashToken(token, t.TokenSalt))) == 1 {
+			return GetSourceByID(ctx, t.SourceID)
+		}
+	}
+	return nil, util.NewNotExistErrorf("invalid SCIM token")
+}
+
+// DeleteSCIMBySourceID deletes the SCIM token and the SCIM groups of an authentication source
+func DeleteSCIMBySourceID(ctx context.Context, sourceID int64) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := db.GetEngine(ctx).Delete(&SCIMToken{SourceID: sourceID}); err != nil {
+			return err
+		}
+		if _, err := db.GetEngine(ctx).In("group_id", builder.Select("id").From("forgejo_scim_group").Where(builder.Eq{"source_id": sourceID})).
+			Delete(new(SCIMGroupMember)); err != nil {
+			return err
+		}
+		_, err := db.GetEngine(ctx).Delete(&SCIMGroup{SourceID: sourceID})
+		return err
+	})
+}
+
+// FindSCIMGroupsOptions represents the options to find the SCIM groups of an authentication source
+type FindSCIMGroupsOptions struct {
+	SourceID    int64
+	DisplayName string
+	MemberID    int64
+}
+
+func (opts FindSCIMGroupsOptions) ToConds() builder.Cond {
+	cond := builder.Eq{"source_id": opts.SourceID}
+	if opts.DisplayName != "" {
+		cond["display_name"] = opts.DisplayName
+	}
+	if opts.MemberID != 0 {
+		return cond.And(builder.In("id", builder.Select("group_id").From("forgejo_scim_group_member").Where(builder.Eq{"user_id": opts.MemberID})))
+	}
+	return cond
+}
+
+// FindSCIMGroups returns a page of the SCIM groups of an authentication source, or all of them when paginator is nil,
+// and their total count
+func FindSCIMGroups(ctx context.Context, opts FindSCIMGroupsOptions, paginator db.Paginator) ([]*SCIMGroup, int64, error) {
+	sess := db.GetEngine(ctx).Where(opts.ToConds()).OrderBy("id")
+	if paginator != nil {
+		sess = db.SetSessionPagination(sess, paginator)
+	}
+	groups := make([]*SCIMGroup, 0, 10)
+	count, err := sess.FindAndCount(&groups)
+	return groups, count, err
+}
+
+// GetSCIMGroupByID returns a SCIM group of an authentication source
+func GetSCIMGroupByID(ctx context.Context, sourceID, id int64) (*SCIMGroup, error) {
+	g := &SCIMGroup{}
+	has, err := db.GetEngine(ctx).Where("id = ? AND source_id = ?", id, sourceID).Get(g)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, util.NewNotExistErrorf("SCIM group %d does not exist", id)
+	}
+	return g, nil
+}
+
+// CreateSCIMGroup creates a SCIM group, whose display name must be unique in the authentication source
+func CreateSCIMGroup(ctx context.Context, g *SCIMGroup) error {
+	exists, err := db.Exist[SCIMGroup](ctx, FindSCIMGroupsOptions{SourceID: g.SourceID, DisplayName: g.DisplayName}.ToConds())
+	if err != nil {
+		return err
+	} else if exists {
+		return util.NewAlreadyExistErrorf("SCIM group %q already exists", g.DisplayName)
+	}
+	return db.Insert(ctx, g)
+}
+
+// UpdateSCIMGroup updates the display name and the external ID of a SCIM group
+func UpdateSCIMGroup(ctx context.Context, g *SCIMGroup) error {
+	exists, err := db.Exist[SCIMGroup](ctx, FindSCIMGroupsOptions{SourceID: g.SourceID, DisplayName: g.DisplayName}.ToConds().And(builder.Neq{"id": g.ID}))
+	if err != nil {
+		return err
+	} else if exists {
+		return util.NewAlreadyExistErrorf("SCIM group %q already exists", g.DisplayName)
+	}
+	_, err = db.GetEngine(ctx).ID(g.ID).Cols("display_name", "external_id").Update(g)
+	return err
+}
+
+// DeleteSCIMGroup deletes a SCIM group and its memberships
+func DeleteSCIMGroup(ctx context.Context, g *SCIMGroup) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := db.GetEngine(ctx).Delete(&SCIMGroupMember{GroupID: g.ID}); err != nil {
+			return err
+		}
+		_, err := db.GetEngine(ctx).ID(g.ID).Delete(new(SCIMGroup))
+		return err
+	})
+}
+
+// GetSCIMGroupMemberIDs returns the IDs of the members of a SCIM group
+func GetSCIMGroupMemberIDs(ctx context.Context, groupID int64) ([]int64, error) {
+	ids := make([]int64, 0, 10)
+	return ids, db.GetEngine(ctx).Table("forgejo_scim_group_member").Where("group_id = ?", groupID).OrderBy("user_id").Cols("user_id").Find(&ids)
+}
+
+// AddSCIMGroupMember adds a user to a SCIM group, nothing happens if they are already a member
+func AddSCIMGroupMember(ctx context.Context, groupID, userID int64) error {
+	exists, err := db.GetEngine(ctx).Exist(&SCIMGroupMember{GroupID: groupID, UserID: userID})
+	if err != nil || exists {
+		return err
+	}
+	return db.Insert(ctx, &SCIMGroupMember{GroupID: groupID, UserID: userID})
+}
+
+// RemoveSCIMGroupMember removes a user from a SCIM group
+func RemoveSCIMGroupMember(ctx context.Context, groupID, userID int64) error {
+	_, err := db.GetEngine(ctx).Delete(&SCIMGroupMember{GroupID: groupID, UserID: userID})
+	return err
+}
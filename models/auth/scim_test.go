@@ -0,0 +1,116 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth_test
+
+import (
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createSCIMTestSource(t *testing.T) *auth_model.Source {
+	t.Helper()
+	auth_model.RegisterTypeConfig(auth_model.OAuth2, new(TestSource))
+	source := &auth_model.Source{
+		Type:     auth_model.OAuth2,
+		Name:     "SCIMTestSource",
+		IsActive: true,
+		Cfg:      &TestSource{Provider: "ConvertibleSourceName"},
+	}
+	require.NoError(t, auth_model.CreateSource(db.DefaultContext, source))
+	return source
+}
+
+func TestSCIMToken(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	source := createSCIMTestSource(t)
+
+	_, err := auth_model.GetSCIMTokenBySourceID(db.DefaultContext, source.ID)
+	require.ErrorIs(t, err, util.ErrNotExist)
+
+	token, err := auth_model.GenerateSCIMToken(db.DefaultContext, source.ID)
+	require.NoError(t, err)
+	assert.Len(t, token, 40)
+
+	found, err := auth_model.GetSourceBySCIMToken(db.DefaultContext, token)
+	require.NoError(t, err)
+	assert.Equal(t, source.ID, found.ID)
+
+	scimToken, err := auth_model.GetSCIMTokenBySourceID(db.DefaultContext, source.ID)
+	require.NoError(t, err)
+	assert.Equal(t, token[32:], scimToken.TokenLastEight)
+
+	regenerated, err := auth_model.GenerateSCIMToken(db.DefaultContext, source.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, regenerated)
+	_, err = auth_model.GetSourceBySCIMToken(db.DefaultContext, token)
+	require.ErrorIs(t, err, util.ErrNotExist)
+	_, err = auth_model.GetSourceBySCIMToken(db.DefaultContext, regenerated)
+	require.NoError(t, err)
+
+	_, err = auth_model.GetSourceBySCIMToken(db.DefaultContext, "too short")
+	require.ErrorIs(t, err, util.ErrNotExist)
+	unittest.AssertCount(t, &auth_model.SCIMToken{SourceID: source.ID}, 1)
+}
+
+func TestSCIMGroups(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	source := createSCIMTestSource(t)
+
+	developers := &auth_model.SCIMGroup{SourceID: source.ID, DisplayName: "developers"}
+	require.NoError(t, auth_model.CreateSCIMGroup(db.DefaultContext, developers))
+	admins := &auth_model.SCIMGroup{SourceID: source.ID, DisplayName: "admins"}
+	require.NoError(t, auth_model.CreateSCIMGroup(db.DefaultContext, admins))
+	require.ErrorIs(t, auth_model.CreateSCIMGroup(db.DefaultContext, &auth_model.SCIMGroup{SourceID: source.ID, DisplayName: "admins"}), util.ErrAlreadyExist)
+
+	admins.DisplayName = "developers"
+	require.ErrorIs(t, auth_model.UpdateSCIMGroup(db.DefaultContext, admins), util.ErrAlreadyExist)
+	admins.DisplayName = "administrators"
+	admins.ExternalID = "42"
+	require.NoError(t, auth_model.UpdateSCIMGroup(db.DefaultContext, admins))
+	unittest.AssertExistsAndLoadBean(t, &auth_model.SCIMGroup{ID: admins.ID, DisplayName: "administrators", ExternalID: "42"})
+
+	_, err := auth_model.GetSCIMGroupByID(db.DefaultContext, source.ID+1, admins.ID)
+	require.ErrorIs(t, err, util.ErrNotExist)
+
+	require.NoError(t, auth_model.AddSCIMGroupMember(db.DefaultContext, developers.ID, 2))
+	require.NoError(t, auth_model.AddSCIMGroupMember(db.DefaultContext, developers.ID, 4))
+	require.NoError(t, auth_model.AddSCIMGroupMember(db.DefaultContext, developers.ID, 2))
+	require.NoError(t, auth_model.AddSCIMGroupMember(db.DefaultContext, admins.ID, 2))
+	ids, err := auth_model.GetSCIMGroupMemberIDs(db.DefaultContext, developers.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 4}, ids)
+
+	groups, count, err := auth_model.FindSCIMGroups(db.DefaultContext, auth_model.FindSCIMGroupsOptions{SourceID: source.ID, MemberID: 2}, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	assert.Len(t, groups, 2)
+
+	groups, count, err = auth_model.FindSCIMGroups(db.DefaultContext, auth_model.FindSCIMGroupsOptions{SourceID: source.ID}, db.NewAbsoluteListOptions(1, 1))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	require.Len(t, groups, 1)
+	assert.Equal(t, admins.ID, groups[0].ID)
+
+	require.NoError(t, auth_model.RemoveSCIMGroupMember(db.DefaultContext, developers.ID, 2))
+	ids, err = auth_model.GetSCIMGroupMemberIDs(db.DefaultContext, developers.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4}, ids)
+
+	require.NoError(t, auth_model.DeleteSCIMGroup(db.DefaultContext, developers))
+	unittest.AssertNotExistsBean(t, &auth_model.SCIMGroupMember{GroupID: developers.ID})
+
+	_, err = auth_model.GenerateSCIMToken(db.DefaultContext, source.ID)
+	require.NoError(t, err)
+	require.NoError(t, auth_model.DeleteSCIMBySourceID(db.DefaultContext, source.ID))
+	unittest.AssertNotExistsBean(t, &auth_model.SCIMToken{SourceID: source.ID})
+	unittest.AssertNotExistsBean(t, &auth_model.SCIMGroup{ID: admins.ID})
+	unittest.AssertNotExistsBean(t, &auth_model.SCIMGroupMember{GroupID: admins.ID})
+}
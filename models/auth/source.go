@@ -34,6 +34,7 @@ const (
 	OAuth2      // 6
 	SSPI        // 7
 	Remote      // 8
+	SAML        // 9
 )
 
 // String returns the string name of the LoginType
@@ -55,6 +56,7 @@ var Names = map[Type]string{
 	OAuth2: "OAuth2",
 	SSPI:   "SPNEGO with SSPI",
 	Remote: "Remote",
+	SAML:   "SAML",
 }
 
 // Config represents login config as far as the db is concerned
@@ -88,6 +90,11 @@ type RegisterableSource interface {
 	UnregisterSource() error
 }
 
+// GroupTeamMapper configurations provide GetGroupTeamMap to map the groups of their users to organization teams
+type GroupTeamMapper interface {
+	GetGroupTeamMap() string
+}
+
 var registeredConfigs = map[Type]func() Config{}
 
 // RegisterTypeConfig register a config for a provided type
@@ -187,6 +194,11 @@ func (source *Source) IsRemote() bool {
 	return source.Type == Remote
 }
 
+// IsSAML returns true of this source is of the SAML type.
+func (source *Source) IsSAML() bool {
+	return source.Type == SAML
+}
+
 // HasTLS returns true of this source supports TLS.
 func (source *Source) HasTLS() bool {
 	hasTLSer, ok := source.Cfg.(HasTLSer)
@@ -0,0 +1,126 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgefed
+
+import (
+	"context"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+)
+
+func init() {
+	db.RegisterModel(new(Delivery))
+}
+
+const (
+	deliveryBackoffBase = time.Minute
+	deliveryBackoffMax  = 24 * time.Hour
+)
+
+// DeliveryBackoff returns how long to wait after the given number of failed attempts,
+// doubling from a minute up to a day
+func DeliveryBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	backoff := deliveryBackoffBase
+	for i := 1; i < failures && backoff < deliveryBackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, deliveryBackoffMax)
+}
+
+// Delivery is an activity waiting to be posted to the inbox of a remote actor
+type Delivery struct {
+	ID int64 `xorm:"pk autoincr"`
+	// FederationHostID is 0 for inboxes on hosts not known yet
+	FederationHostID int64  `xorm:"INDEX NOT NULL DEFAULT 0"`
+	InboxURI         string `xorm:"TEXT NOT NULL"`
+	// SignerID is the local user signing the request, the instance actor included
+	SignerID    int64              `xorm:"NOT NULL"`
+	Payload     string             `xorm:"LONGTEXT NOT NULL"`
+	Attempts    int                `xorm:"NOT NULL DEFAULT 0"`
+	LastError   string             `xorm:"TEXT"`
+	NextAttempt timeutil.TimeStamp `xorm:"INDEX NOT NULL"`
+	CreatedUnix timeutil.TimeStamp `xorm:"created NOT NULL"`
+}
+
+func (Delivery) TableName() string {
+	return "forgejo_federation_delivery"
+}
+
+// RecordFailure counts a failed attempt and schedules the next one
+func (d *Delivery) RecordFailure(now timeutil.TimeStamp, err error) {
+	d.Attempts++
+	d.LastError = err.Error()
+	d.NextAttempt = now.AddDuration(DeliveryBackoff(d.Attempts))
+}
+
+func CreateDelivery(ctx context.Context, d *Delivery) error {
+	_, err := db.GetEngine(ctx).Insert(d)
+	return err
+}
+
+func GetDelivery(ctx context.Context, id int64) (*Delivery, error) {
+	d := new(Delivery)
+	has, err := db.GetEngine(ctx).ID(id).Get(d)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, util.NewNotExistErrorf("delivery %d does not exist", id)
+	}
+	return d, nil
+}
+
+// UpdateDeliveryAttempt stores the outcome of a failed or postponed attempt
+func UpdateDeliveryAttempt(ctx context.Context, d *Delivery) error {
+	_, err := db.GetEngine(ctx).ID(d.ID).Cols("attempts", "last_error", "next_attempt").Update(d)
+	return err
+}
+
+func DeleteDelivery(ctx context.Context, id int64) error {
+	_, err := db.GetEngine(ctx).ID(id).Delete(new(Delivery))
+	return err
+}
+
+// DeleteDeliveriesByHost drops the deliveries waiting for a federation host
+func DeleteDeliveriesByHost(ctx context.Context, federationHostID int64) error {
+	_, err := db.GetEngine(ctx).Where("federation_host_id = ?", federationHostID).Delete(new(Delivery))
+	return err
+}
+
+// FindDueDeliveryIDs returns the deliveries to attempt at the given time, starting after lowerID
+func FindDueDeliveryIDs(ctx context.Context, now timeutil.TimeStamp, lowerID int64, limit int) ([]int64, error) {
+	ids := make([]int64, 0, limit)
+	return ids, db.GetEngine(ctx).Table("forgejo_federation_delivery").
+		Where("next_attempt <= ? AND id > ?", now, lowerID).
+		OrderBy("id").
+		Limit(limit).
+		Cols("id").
+		Find(&ids)
+}
+
+// CountPendingDeliveriesByHost returns the number of deliveries waiting for each federation host
+func CountPendingDeliveriesByHost(ctx context.Context) (map[int64]int64, error) {
+	type hostCount struct {
+		FederationHostID int64
+		Count            int64
+	}
+	counts := make([]hostCount, 0, 10)
+	if err := db.GetEngine(ctx).Table("forgejo_federation_delivery").
+		Select("federation_host_id, COUNT(*) AS count").
+		GroupBy("federation_host_id").
+		Find(&counts); err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		result[c.FederationHostID] = c.Count
+	}
+	return result, nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgefed
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryBackoff(t *testing.T) {
+	assert.Equal(t, time.Duration(0), DeliveryBackoff(0))
+	assert.Equal(t, time.Minute, DeliveryBackoff(1))
+	assert.Equal(t, 8*time.Minute, DeliveryBackoff(4))
+	assert.Equal(t, 24*time.Hour, DeliveryBackoff(12))
+	assert.Equal(t, 24*time.Hour, DeliveryBackoff(1000))
+}
+
+func TestDeliveryRecordFailure(t *testing.T) {
+	d := Delivery{}
+	d.RecordFailure(1000, errors.New("connection refused"))
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, "connection refused", d.LastError)
+	assert.EqualValues(t, 1060, d.NextAttempt)
+
+	d.RecordFailure(2000, errors.New("status 503"))
+	assert.Equal(t, 2, d.Attempts)
+	assert.EqualValues(t, 2120, d.NextAttempt)
+}
@@ -8,19 +8,71 @@ import (
 	"strings"
 	"time"
 
+	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/timeutil"
 	"code.gitea.io/gitea/modules/validation"
 )
 
+// FederationHostPolicy decides how an instance federates with a host
+type FederationHostPolicy int
+
+const (
+	// FederationHostPolicyNone federates with the host unless the allowlist mode is enabled
+	FederationHostPolicyNone FederationHostPolicy = iota
+	// FederationHostPolicyAllow federates with the host, also in allowlist mode
+	FederationHostPolicyAllow
+	// FederationHostPolicySilence accepts activities of the host but refuses content like issues and comments
+	FederationHostPolicySilence
+	// FederationHostPolicyBlock refuses any activity of the host and does not deliver to it
+	FederationHostPolicyBlock
+)
+
+// FederationHostPolicies lists the policies in the order they are offered to admins
+var FederationHostPolicies = []FederationHostPolicy{
+	FederationHostPolicyNone,
+	FederationHostPolicyAllow,
+	FederationHostPolicySilence,
+	FederationHostPolicyBlock,
+}
+
+func (policy FederationHostPolicy) String() string {
+	switch policy {
+	case FederationHostPolicyAllow:
+		return "allow"
+	case FederationHostPolicySilence:
+		return "silence"
+	case FederationHostPolicyBlock:
+		return "block"
+	default:
+		return "none"
+	}
+}
+
+// ParseFederationHostPolicy returns the policy of the given name
+func ParseFederationHostPolicy(name string) (FederationHostPolicy, error) {
+	for _, policy := range FederationHostPolicies {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return FederationHostPolicyNone, fmt.Errorf("unknown federation host policy %q", name)
+}
+
 // FederationHost data type
 // swagger:model
 type FederationHost struct {
-	ID             int64              `xorm:"pk autoincr"`
-	HostFqdn       string             `xorm:"host_fqdn UNIQUE INDEX VARCHAR(255) NOT NULL"`
-	NodeInfo       NodeInfo           `xorm:"extends NOT NULL"`
-	LatestActivity time.Time          `xorm:"NOT NULL"`
-	Created        timeutil.TimeStamp `xorm:"created"`
-	Updated        timeutil.TimeStamp `xorm:"updated"`
+	ID             int64     `xorm:"pk autoincr"`
+	HostFqdn       string    `xorm:"host_fqdn UNIQUE INDEX VARCHAR(255) NOT NULL"`
+	NodeInfo       NodeInfo  `xorm:"extends NOT NULL"`
+	LatestActivity time.Time `xorm:"NOT NULL"`
+	// SharedInboxURI receives activities addressed to several actors of the host
+	SharedInboxURI      string               `xorm:"TEXT"`
+	LastSuccess         timeutil.TimeStamp   `xorm:"NOT NULL DEFAULT 0"`
+	LastFailure         timeutil.TimeStamp   `xorm:"NOT NULL DEFAULT 0"`
+	ConsecutiveFailures int                  `xorm:"NOT NULL DEFAULT 0"`
+	Policy              FederationHostPolicy `xorm:"NOT NULL DEFAULT 0"`
+	Created             timeutil.TimeStamp   `xorm:"created"`
+	Updated             timeutil.TimeStamp   `xorm:"updated"`
 }
 
 // Factory function for FederationHost. Created struct is asserted to be valid.
@@ -40,7 +92,13 @@ func (host FederationHost) Validate() []string {
 	var result []string
 	result = append(result, validation.ValidateNotEmpty(host.HostFqdn, "HostFqdn")...)
 	result = append(result, validation.ValidateMaxLen(host.HostFqdn, 255, "HostFqdn")...)
-	result = append(result, host.NodeInfo.Validate()...)
+	// hosts an admin set a policy for before federating with them have no node info yet
+	if host.Policy == FederationHostPolicyNone || host.NodeInfo.SoftwareName != "" {
+		result = append(result, host.NodeInfo.Validate()...)
+	}
+	if host.Policy < FederationHostPolicyNone || host.Policy > FederationHostPolicyBlock {
+		result = append(result, fmt.Sprintf("Policy is unknown: %d", host.Policy))
+	}
 	if host.HostFqdn != strings.ToLower(host.HostFqdn) {
 		result = append(result, fmt.Sprintf("HostFqdn has to be lower case but was: %v", host.HostFqdn))
 	}
@@ -50,3 +108,46 @@ func (host FederationHost) Validate() []string {
 
 	return result
 }
+
+// RecordDeliverySuccess resets the failures of the host after an activity was delivered
+func (host *FederationHost) RecordDeliverySuccess(now timeutil.TimeStamp) {
+	host.LastSuccess = now
+	host.ConsecutiveFailures = 0
+}
+
+// RecordDeliveryFailure counts a delivery the host did not accept
+func (host *FederationHost) RecordDeliveryFailure(now timeutil.TimeStamp) {
+	host.LastFailure = now
+	host.ConsecutiveFailures++
+}
+
+// NextDeliveryAllowed returns the earliest time activities are delivered to the host again.
+// Hosts failing repeatedly are backed off.
+func (host FederationHost) NextDeliveryAllowed() timeutil.TimeStamp {
+	if host.ConsecutiveFailures == 0 {
+		return 0
+	}
+	return host.LastFailure.AddDuration(DeliveryBackoff(host.ConsecutiveFailures))
+}
+
+// IsHealthy reports whether the latest delivery to the host succeeded
+func (host FederationHost) IsHealthy() bool {
+	return host.ConsecutiveFailures == 0
+}
+
+// IsBlocked reports whether activities of the host are refused and none are delivered to it.
+// In allowlist mode, only hosts with a policy set by an admin are federated with.
+func (host FederationHost) IsBlocked() bool {
+	return host.Policy == FederationHostPolicyBlock ||
+		setting.Federation.AllowlistMode && host.Policy == FederationHostPolicyNone
+}
+
+// IsSilenced reports whether content created by actors of the host is refused
+func (host FederationHost) IsSilenced() bool {
+	return host.Policy == FederationHostPolicySilence
+}
+
+// HasNodeInfo is false for hosts an admin set a policy for before federating with them
+func (host FederationHost) HasNodeInfo() bool {
+	return host.NodeInfo.SoftwareName != ""
+}
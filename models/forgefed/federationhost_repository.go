@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/validation"
 )
 
@@ -56,6 +57,57 @@ func UpdateFederationHost(ctx context.Context, host *FederationHost) error {
 	if res, err := validation.IsValid(host); !res {
 		return err
 	}
-	_, err := db.GetEngine(ctx).ID(host.ID).Update(host)
+	// health and shared inbox are updated by the deliveries, the policy by admins
+	_, err := db.GetEngine(ctx).ID(host.ID).Omit("shared_inbox_uri", "last_success", "last_failure", "consecutive_failures", "policy").Update(host)
 	return err
 }
+
+// UpdateFederationHostHealth stores the outcome of the latest delivery to the host
+func UpdateFederationHostHealth(ctx context.Context, host *FederationHost) error {
+	_, err := db.GetEngine(ctx).ID(host.ID).Cols("last_success", "last_failure", "consecutive_failures").Update(host)
+	return err
+}
+
+// UpdateFederationHostSharedInbox stores the shared inbox advertised by actors of the host
+func UpdateFederationHostSharedInbox(ctx context.Context, host *FederationHost) error {
+	_, err := db.GetEngine(ctx).ID(host.ID).Cols("shared_inbox_uri").Update(host)
+	return err
+}
+
+// FindFederationHosts returns a page of the known federation hosts ordered by name, and their total number
+func FindFederationHosts(ctx context.Context, listOptions db.ListOptions) ([]*FederationHost, int64, error) {
+	sess := db.GetEngine(ctx).OrderBy("host_fqdn")
+	if listOptions.Page > 0 {
+		sess = db.SetSessionPagination(sess, &listOptions)
+	}
+	hosts := make([]*FederationHost, 0, listOptions.PageSize)
+	count, err := sess.FindAndCount(&hosts)
+	return hosts, count, err
+}
+
+// UpdateFederationHostPolicy stores the policy an admin set for the host
+func UpdateFederationHostPolicy(ctx context.Context, host *FederationHost) error {
+	if res, err := validation.IsValid(host); !res {
+		return err
+	}
+	_, err := db.GetEngine(ctx).ID(host.ID).Cols("policy").Update(host)
+	return err
+}
+
+func DeleteFederationHost(ctx context.Context, id int64) error {
+	_, err := db.GetEngine(ctx).ID(id).Delete(new(FederationHost))
+	return err
+}
+
+// IsFederationHostBlocked reports whether activities of the host are refused.
+// Hosts not known yet are blocked in allowlist mode.
+func IsFederationHostBlocked(ctx context.Context, fqdn string) (bool, error) {
+	host, err := FindFederationHostByFqdn(ctx, fqdn)
+	if err != nil {
+		return false, err
+	}
+	if host == nil {
+		return setting.Federation.AllowlistMode, nil
+	}
+	return host.IsBlocked(), nil
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
 	"code.gitea.io/gitea/modules/validation"
 )
 
@@ -76,3 +78,62 @@ func Test_FederationHostValidation(t *testing.T) {
 		t.Errorf("sut should be invalid: HostFqdn lower case")
 	}
 }
+
+func Test_FederationHostHealth(t *testing.T) {
+	sut := FederationHost{HostFqdn: "host.do.main"}
+	if !sut.IsHealthy() || sut.NextDeliveryAllowed() != 0 {
+		t.Errorf("host without failures should be healthy: %v", sut)
+	}
+
+	sut.RecordDeliveryFailure(1000)
+	sut.RecordDeliveryFailure(2000)
+	if sut.IsHealthy() || sut.ConsecutiveFailures != 2 {
+		t.Errorf("host should have 2 failures: %v", sut)
+	}
+	if next := sut.NextDeliveryAllowed(); next != 2000+120 {
+		t.Errorf("host should be backed off for two minutes but was until %v", next)
+	}
+
+	sut.RecordDeliverySuccess(3000)
+	if !sut.IsHealthy() || sut.LastSuccess != 3000 || sut.LastFailure != 2000 {
+		t.Errorf("host should be healthy again: %v", sut)
+	}
+}
+
+func Test_FederationHostPolicy(t *testing.T) {
+	for _, policy := range FederationHostPolicies {
+		parsed, err := ParseFederationHostPolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Errorf("policy %v should be parsed from its name but was %v: %v", policy, parsed, err)
+		}
+	}
+	if _, err := ParseFederationHostPolicy("defederate"); err == nil {
+		t.Errorf("unknown policy should not be parsed")
+	}
+
+	sut := FederationHost{HostFqdn: "host.do.main", Policy: FederationHostPolicyBlock}
+	if res, err := validation.IsValid(sut); !res {
+		t.Errorf("host with a policy but without node info should be valid: %v", err)
+	}
+	if !sut.IsBlocked() || sut.IsSilenced() || sut.HasNodeInfo() {
+		t.Errorf("host should be blocked: %v", sut)
+	}
+
+	sut = FederationHost{HostFqdn: "host.do.main", Policy: FederationHostPolicy(42)}
+	if res, _ := validation.IsValid(sut); res {
+		t.Errorf("sut should be invalid: unknown policy")
+	}
+
+	sut = FederationHost{HostFqdn: "host.do.main", NodeInfo: NodeInfo{SoftwareName: "forgejo"}}
+	if sut.IsBlocked() {
+		t.Errorf("host without policy should not be blocked: %v", sut)
+	}
+	defer test.MockVariableValue(&setting.Federation.AllowlistMode, true)()
+	if !sut.IsBlocked() {
+		t.Errorf("host without policy should be blocked in allowlist mode: %v", sut)
+	}
+	sut.Policy = FederationHostPolicySilence
+	if sut.IsBlocked() || !sut.IsSilenced() {
+		t.Errorf("silenced host should not be blocked in allowlist mode: %v", sut)
+	}
+}
@@ -17,12 +17,13 @@ type (
 )
 
 const (
-	ForgejoSourceType SoftwareNameType = "forgejo"
-	GiteaSourceType   SoftwareNameType = "gitea"
+	ForgejoSourceType  SoftwareNameType = "forgejo"
+	GiteaSourceType    SoftwareNameType = "gitea"
+	MastodonSourceType SoftwareNameType = "mastodon"
 )
 
 var KnownSourceTypes = []any{
-	ForgejoSourceType, GiteaSourceType,
+	ForgejoSourceType, GiteaSourceType, MastodonSourceType,
 }
 
 // ------------------------------------------------ NodeInfoWellKnown ------------------------------------------------
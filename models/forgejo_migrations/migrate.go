@@ -84,6 +84,34 @@ var migrations = []*Migration{
 	NewMigration("Add `legacy` to `web_authn_credential` table", AddLegacyToWebAuthnCredential),
 	// v23 -> v24
 	NewMigration("Add `delete_branch_after_merge` to `auto_merge` table", AddDeleteBranchAfterMergeToAutoMerge),
+	// v24 -> v25
+	NewMigration("Create the `forgejo_repo_maintenance` table", CreateRepoMaintenanceTable),
+	// v25 -> v26
+	NewMigration("Create the `forgejo_package_proxy` table", CreatePackageProxyTable),
+	// v26 -> v27
+	NewMigration("Create the `forgejo_package_advisory` and `forgejo_package_vulnerability` tables", CreatePackageVulnerabilityTables),
+	// v27 -> v28
+	NewMigration("Create the `forgejo_package_attestation` table", CreatePackageAttestationTable),
+	// v28 -> v29
+	NewMigration("Add `inbox_uri` to `federated_user` table", AddInboxURIToFederatedUser),
+	// v29 -> v30
+	NewMigration("Create the `forgejo_federated_issue` table", CreateFederatedIssueTable),
+	// v30 -> v31
+	NewMigration("Create the `forgejo_federation_delivery` table and add health to `federation_host`", AddFederationDeliveries),
+	// v31 -> v32
+	NewMigration("Add `policy` to `federation_host` table", AddPolicyToFederationHost),
+	// v32 -> v33
+	NewMigration("Create the `forgejo_federated_fork` table", CreateFederatedForkTable),
+	// v33 -> v34
+	NewMigration("Create the `forgejo_audit_event` table", CreateAuditEventTable),
+	// v34 -> v35
+	NewMigration("Create the `forgejo_scim_token`, `forgejo_scim_group` and `forgejo_scim_group_member` tables", CreateSCIMTables),
+	// v35 -> v36
+	NewMigration("Add `org_id`, `repo_ids`, `expires_unix` and `expiry_notified` to `access_token` table", AddRepoRestrictionAndExpiryToAccessToken),
+	// v36 -> v37
+	NewMigration("Create the `forgejo_oauth2_device_authorization` table", CreateOAuth2DeviceAuthorizationTable),
+	// v37 -> v38
+	NewMigration("Add `verified` to `forgejo_package_attestation` table", AddVerifiedToPackageAttestation),
 }
 
 // GetCurrentDBVersion returns the current Forgejo database version.
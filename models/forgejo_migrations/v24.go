@@ -0,0 +1,36 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type RepoMaintenance struct {
+	ID                int64  `xorm:"pk autoincr"`
+	RepoID            int64  `xorm:"UNIQUE NOT NULL"`
+	AllowedFilters    string `xorm:"TEXT"`
+	LooseObjects      int64
+	LooseSize         int64
+	Packs             int64
+	PackSize          int64
+	HasCommitGraph    bool               `xorm:"NOT NULL DEFAULT false"`
+	HasMultiPackIndex bool               `xorm:"NOT NULL DEFAULT false"`
+	LastTasks         string             `xorm:"TEXT"`
+	LastError         string             `xorm:"TEXT"`
+	LastRunUnix       timeutil.TimeStamp `xorm:"INDEX"`
+	CreatedUnix       timeutil.TimeStamp `xorm:"created"`
+	UpdatedUnix       timeutil.TimeStamp `xorm:"updated"`
+}
+
+func (RepoMaintenance) TableName() string {
+	return "forgejo_repo_maintenance"
+}
+
+// CreateRepoMaintenanceTable: create the table holding the git maintenance state of the repositories
+func CreateRepoMaintenanceTable(x *xorm.Engine) error {
+	return x.Sync(new(RepoMaintenance))
+}
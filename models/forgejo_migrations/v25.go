@@ -0,0 +1,31 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type PackageProxy struct {
+	ID                int64              `xorm:"pk autoincr"`
+	OwnerID           int64              `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	Type              string             `xorm:"UNIQUE(s) NOT NULL"`
+	Enabled           bool               `xorm:"NOT NULL DEFAULT false"`
+	URL               string             `xorm:"TEXT NOT NULL"`
+	Username          string             `xorm:"NOT NULL DEFAULT ''"`
+	PasswordEncrypted string             `xorm:"TEXT"`
+	CreatedUnix       timeutil.TimeStamp `xorm:"created NOT NULL DEFAULT 0"`
+	UpdatedUnix       timeutil.TimeStamp `xorm:"updated NOT NULL DEFAULT 0"`
+}
+
+func (PackageProxy) TableName() string {
+	return "forgejo_package_proxy"
+}
+
+// CreatePackageProxyTable: create the table holding the upstream registries proxied by the package owners
+func CreatePackageProxyTable(x *xorm.Engine) error {
+	return x.Sync(new(PackageProxy))
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type PackageAdvisory struct {
+	ID           int64              `xorm:"pk autoincr"`
+	AdvisoryID   string             `xorm:"UNIQUE(s) NOT NULL"`
+	Ecosystem    string             `xorm:"UNIQUE(s) INDEX(p) NOT NULL"`
+	PackageName  string             `xorm:"UNIQUE(s) INDEX(p) NOT NULL"`
+	Aliases      []string           `xorm:"JSON TEXT"`
+	Summary      string             `xorm:"TEXT"`
+	Severity     string             `xorm:"NOT NULL"`
+	AffectedJSON string             `xorm:"affected_json TEXT NOT NULL"`
+	ModifiedUnix timeutil.TimeStamp `xorm:"NOT NULL DEFAULT 0"`
+}
+
+func (PackageAdvisory) TableName() string {
+	return "forgejo_package_advisory"
+}
+
+type PackageVulnerability struct {
+	ID             int64              `xorm:"pk autoincr"`
+	VersionID      int64              `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	AdvisoryID     string             `xorm:"UNIQUE(s) NOT NULL"`
+	Ecosystem      string             `xorm:"NOT NULL"`
+	PackageName    string             `xorm:"UNIQUE(s) NOT NULL"`
+	PackageVersion string             `xorm:"NOT NULL"`
+	Severity       string             `xorm:"INDEX NOT NULL"`
+	Summary        string             `xorm:"TEXT"`
+	CreatedUnix    timeutil.TimeStamp `xorm:"created NOT NULL DEFAULT 0"`
+}
+
+func (PackageVulnerability) TableName() string {
+	return "forgejo_package_vulnerability"
+}
+
+// CreatePackageVulnerabilityTables: create the tables holding the imported advisories and the findings of the package versions
+func CreatePackageVulnerabilityTables(x *xorm.Engine) error {
+	return x.Sync(new(PackageAdvisory), new(PackageVulnerability))
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type PackageAttestation struct {
+	ID               int64              `xorm:"pk autoincr"`
+	VersionID        int64              `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	ContentSHA256    string             `xorm:"UNIQUE(s) NOT NULL"`
+	Content          string             `xorm:"LONGTEXT NOT NULL"`
+	Format           string             `xorm:"NOT NULL"`
+	PredicateType    string             `xorm:"TEXT NOT NULL"`
+	Source           string             `xorm:"NOT NULL"`
+	SignerIdentity   string             `xorm:"TEXT"`
+	SignerIssuer     string             `xorm:"TEXT"`
+	BuilderID        string             `xorm:"TEXT"`
+	SourceRepository string             `xorm:"TEXT"`
+	SourceRef        string             `xorm:"TEXT"`
+	CommitSHA        string             `xorm:"VARCHAR(64)"`
+	Workflow         string             `xorm:"TEXT"`
+	RunURL           string             `xorm:"TEXT"`
+	CreatorID        int64              `xorm:"NOT NULL DEFAULT 0"`
+	CreatedUnix      timeutil.TimeStamp `xorm:"created NOT NULL DEFAULT 0"`
+}
+
+func (PackageAttestation) TableName() string {
+	return "forgejo_package_attestation"
+}
+
+// CreatePackageAttestationTable: create the table holding the provenance and signature attestations of package versions
+func CreatePackageAttestationTable(x *xorm.Engine) error {
+	return x.Sync(new(PackageAttestation))
+}
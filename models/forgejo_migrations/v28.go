@@ -0,0 +1,15 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import "xorm.io/xorm"
+
+// AddInboxURIToFederatedUser: add the inbox of the remote actors followed by local users, the activities of the outbox are delivered to it
+func AddInboxURIToFederatedUser(x *xorm.Engine) error {
+	type FederatedUser struct {
+		ID       int64 `xorm:"pk autoincr"`
+		InboxURI string
+	}
+	return x.Sync(&FederatedUser{})
+}
@@ -0,0 +1,28 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type FederatedIssue struct {
+	ID               int64              `xorm:"pk autoincr"`
+	IssueID          int64              `xorm:"INDEX NOT NULL"`
+	CommentID        int64              `xorm:"NOT NULL DEFAULT 0"`
+	FederationHostID int64              `xorm:"NOT NULL"`
+	ObjectURI        string             `xorm:"VARCHAR(255) UNIQUE NOT NULL"`
+	CreatedUnix      timeutil.TimeStamp `xorm:"created NOT NULL"`
+}
+
+func (FederatedIssue) TableName() string {
+	return "forgejo_federated_issue"
+}
+
+// CreateFederatedIssueTable: create the table linking issues and comments to the ActivityPub objects they were created from
+func CreateFederatedIssueTable(x *xorm.Engine) error {
+	return x.Sync(new(FederatedIssue))
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type FederationDelivery struct {
+	ID               int64              `xorm:"pk autoincr"`
+	FederationHostID int64              `xorm:"INDEX NOT NULL DEFAULT 0"`
+	InboxURI         string             `xorm:"TEXT NOT NULL"`
+	SignerID         int64              `xorm:"NOT NULL"`
+	Payload          string             `xorm:"LONGTEXT NOT NULL"`
+	Attempts         int                `xorm:"NOT NULL DEFAULT 0"`
+	LastError        string             `xorm:"TEXT"`
+	NextAttempt      timeutil.TimeStamp `xorm:"INDEX NOT NULL"`
+	CreatedUnix      timeutil.TimeStamp `xorm:"created NOT NULL"`
+}
+
+func (FederationDelivery) TableName() string {
+	return "forgejo_federation_delivery"
+}
+
+// AddFederationDeliveries: create the table of pending ActivityPub deliveries and track the health of federation hosts
+func AddFederationDeliveries(x *xorm.Engine) error {
+	type FederationHost struct {
+		ID                  int64              `xorm:"pk autoincr"`
+		SharedInboxURI      string             `xorm:"TEXT"`
+		LastSuccess         timeutil.TimeStamp `xorm:"NOT NULL DEFAULT 0"`
+		LastFailure         timeutil.TimeStamp `xorm:"NOT NULL DEFAULT 0"`
+		ConsecutiveFailures int                `xorm:"NOT NULL DEFAULT 0"`
+	}
+	if err := x.Sync(new(FederationHost)); err != nil {
+		return err
+	}
+	return x.Sync(new(FederationDelivery))
+}
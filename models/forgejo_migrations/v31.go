@@ -0,0 +1,15 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import "xorm.io/xorm"
+
+// AddPolicyToFederationHost: add the policy of a federation host, which allows, silences or blocks it
+func AddPolicyToFederationHost(x *xorm.Engine) error {
+	type FederationHost struct {
+		ID     int64 `xorm:"pk autoincr"`
+		Policy int   `xorm:"NOT NULL DEFAULT 0"`
+	}
+	return x.Sync(&FederationHost{})
+}
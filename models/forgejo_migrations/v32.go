@@ -0,0 +1,24 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import "xorm.io/xorm"
+
+type FederatedFork struct {
+	ID               int64  `xorm:"pk autoincr"`
+	RepoID           int64  `xorm:"UNIQUE NOT NULL"`
+	ExternalID       string `xorm:"NOT NULL"`
+	FederationHostID int64  `xorm:"INDEX NOT NULL"`
+	URI              string `xorm:"TEXT NOT NULL"`
+	InboxURI         string `xorm:"TEXT NOT NULL"`
+}
+
+func (FederatedFork) TableName() string {
+	return "forgejo_federated_fork"
+}
+
+// CreateFederatedForkTable: create the table linking local forks to the federated repositories they were forked from
+func CreateFederatedForkTable(x *xorm.Engine) error {
+	return x.Sync(&FederatedFork{})
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type AuditEvent struct {
+	ID          int64  `xorm:"pk autoincr"`
+	Action      string `xorm:"VARCHAR(64) INDEX NOT NULL"`
+	ActorID     int64  `xorm:"INDEX NOT NULL"`
+	ActorName   string `xorm:"NOT NULL"`
+	OwnerID     int64  `xorm:"INDEX NOT NULL DEFAULT 0"`
+	RepoID      int64  `xorm:"INDEX NOT NULL DEFAULT 0"`
+	TargetType  string `xorm:"VARCHAR(32) INDEX NOT NULL"`
+	TargetID    int64  `xorm:"INDEX NOT NULL"`
+	TargetName  string `xorm:"NOT NULL"`
+	Message     string `xorm:"TEXT"`
+	IPAddress   string
+	CreatedUnix timeutil.TimeStamp `xorm:"created INDEX NOT NULL"`
+}
+
+func (AuditEvent) TableName() string {
+	return "forgejo_audit_event"
+}
+
+// CreateAuditEventTable: create the table of the audit log
+func CreateAuditEventTable(x *xorm.Engine) error {
+	return x.Sync(&AuditEvent{})
+}
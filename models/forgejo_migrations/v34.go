@@ -0,0 +1,51 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type SCIMToken struct {
+	ID             int64  `xorm:"pk autoincr"`
+	SourceID       int64  `xorm:"UNIQUE"`
+	TokenHash      string `xorm:"UNIQUE"`
+	TokenSalt      string
+	TokenLastEight string             `xorm:"INDEX token_last_eight"`
+	CreatedUnix    timeutil.TimeStamp `xorm:"created"`
+}
+
+func (SCIMToken) TableName() string {
+	return "forgejo_scim_token"
+}
+
+type SCIMGroup struct {
+	ID          int64  `xorm:"pk autoincr"`
+	SourceID    int64  `xorm:"UNIQUE(s)"`
+	DisplayName string `xorm:"UNIQUE(s)"`
+	ExternalID  string
+	CreatedUnix timeutil.TimeStamp `xorm:"created"`
+	UpdatedUnix timeutil.TimeStamp `xorm:"updated"`
+}
+
+func (SCIMGroup) TableName() string {
+	return "forgejo_scim_group"
+}
+
+type SCIMGroupMember struct {
+	ID      int64 `xorm:"pk autoincr"`
+	GroupID int64 `xorm:"UNIQUE(s)"`
+	UserID  int64 `xorm:"UNIQUE(s) INDEX"`
+}
+
+func (SCIMGroupMember) TableName() string {
+	return "forgejo_scim_group_member"
+}
+
+// CreateSCIMTables: create the tables of the SCIM provisioning API
+func CreateSCIMTables(x *xorm.Engine) error {
+	return x.Sync(&SCIMToken{}, &SCIMGroup{}, &SCIMGroupMember{})
+}
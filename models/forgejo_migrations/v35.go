@@ -0,0 +1,22 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+// AddRepoRestrictionAndExpiryToAccessToken: restrict access tokens to an organization or to repositories, and let them expire
+func AddRepoRestrictionAndExpiryToAccessToken(x *xorm.Engine) error {
+	type AccessToken struct {
+		ID             int64              `xorm:"pk autoincr"`
+		OrgID          int64              `xorm:"NOT NULL DEFAULT 0"`
+		RepoIDs        []int64            `xorm:"JSON TEXT"`
+		ExpiresUnix    timeutil.TimeStamp `xorm:"INDEX NOT NULL DEFAULT 0"`
+		ExpiryNotified bool               `xorm:"NOT NULL DEFAULT false"`
+	}
+	return x.Sync(&AccessToken{})
+}
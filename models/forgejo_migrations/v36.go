@@ -0,0 +1,33 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import (
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/xorm"
+)
+
+type OAuth2DeviceAuthorization struct {
+	ID              int64  `xorm:"pk autoincr"`
+	ApplicationID   int64  `xorm:"INDEX"`
+	DeviceCode      string `xorm:"INDEX unique"`
+	UserCode        string `xorm:"INDEX unique"`
+	Scope           string `xorm:"TEXT"`
+	GrantID         int64  `xorm:"NOT NULL DEFAULT 0"`
+	Denied          bool   `xorm:"NOT NULL DEFAULT false"`
+	PollingInterval int64
+	LastPolledUnix  timeutil.TimeStamp
+	ValidUntil      timeutil.TimeStamp `xorm:"INDEX"`
+	CreatedUnix     timeutil.TimeStamp `xorm:"created"`
+}
+
+func (OAuth2DeviceAuthorization) TableName() string {
+	return "forgejo_oauth2_device_authorization"
+}
+
+// CreateOAuth2DeviceAuthorizationTable: create the table of the pending device authorizations of the OAuth2 provider
+func CreateOAuth2DeviceAuthorizationTable(x *xorm.Engine) error {
+	return x.Sync(&OAuth2DeviceAuthorization{})
+}
@@ -0,0 +1,24 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgejo_migrations //nolint:revive
+
+import "xorm.io/xorm"
+
+type PackageAttestationVerified struct {
+	ID       int64 `xorm:"pk autoincr"`
+	Verified bool  `xorm:"NOT NULL DEFAULT false"`
+}
+
+func (PackageAttestationVerified) TableName() string {
+	return "forgejo_package_attestation"
+}
+
+// AddVerifiedToPackageAttestation: add `verified` to the package attestations, the provenance recorded by Forgejo Actions is verified
+func AddVerifiedToPackageAttestation(x *xorm.Engine) error {
+	if err := x.Sync(new(PackageAttestationVerified)); err != nil {
+		return err
+	}
+	_, err := x.Exec("UPDATE `forgejo_package_attestation` SET `verified` = ? WHERE `source` = ?", true, "actions")
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package issues
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// FederatedIssue links an issue, or a comment of it, to the ActivityPub object it was created from
+type FederatedIssue struct {
+	ID               int64              `xorm:"pk autoincr"`
+	IssueID          int64              `xorm:"INDEX NOT NULL"`
+	CommentID        int64              `xorm:"NOT NULL DEFAULT 0"`
+	FederationHostID int64              `xorm:"NOT NULL"`
+	ObjectURI        string             `xorm:"VARCHAR(255) UNIQUE NOT NULL"`
+	CreatedUnix      timeutil.TimeStamp `xorm:"created NOT NULL"`
+}
+
+func (FederatedIssue) TableName() string {
+	return "forgejo_federated_issue"
+}
+
+func init() {
+	db.RegisterModel(new(FederatedIssue))
+}
+
+// CreateFederatedIssue stores the object an issue or comment was created from
+func CreateFederatedIssue(ctx context.Context, fi *FederatedIssue) error {
+	return db.Insert(ctx, fi)
+}
+
+// ReserveFederatedIssue stores the object an issue will be created from before creating the issue,
+// so that an object delivered several times is only processed once. It returns false if the object
+// was already received.
+func ReserveFederatedIssue(ctx context.Context, fi *FederatedIssue) (bool, error) {
+	has, err := db.GetEngine(ctx).Exist(&FederatedIssue{ObjectURI: fi.ObjectURI})
+	if err != nil || has {
+		return false, err
+	}
+	if _, err := db.GetEngine(ctx).Insert(fi); err != nil {
+		// the object may have been reserved concurrently
+		if has, _ := db.GetEngine(ctx).Exist(&FederatedIssue{ObjectURI: fi.ObjectURI}); has {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// SetIssueID links a reserved object to the issue created from it
+func (fi *FederatedIssue) SetIssueID(ctx context.Context, issueID int64) error {
+	fi.IssueID = issueID
+	_, err := db.GetEngine(ctx).ID(fi.ID).Cols("issue_id").Update(fi)
+	return err
+}
+
+// DeleteFederatedIssueByID drops the link to an object
+func DeleteFederatedIssueByID(ctx context.Context, id int64) error {
+	_, err := db.GetEngine(ctx).ID(id).Delete(new(FederatedIssue))
+	return err
+}
+
+// GetFederatedIssueByID gets the link to an object
+func GetFederatedIssueByID(ctx context.Context, id int64) (*FederatedIssue, error) {
+	fi := &FederatedIssue{}
+	has, err := db.GetEngine(ctx).ID(id).Get(fi)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, util.NewNotExistErrorf("federated issue does not exist")
+	}
+	return fi, nil
+}
+
+// GetFederatedIssueByObjectURI gets the issue or comment created from the object
+func GetFederatedIssueByObjectURI(ctx context.Context, uri string) (*FederatedIssue, error) {
+	fi := &FederatedIssue{}
+	has, err := db.GetEngine(ctx).Where("object_uri = ?", uri).Get(fi)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, util.NewNotExistErrorf("federated issue does not exist")
+	}
+	return fi, nil
+}
+
+// GetFederatedIssueByIssueID gets the object an issue was created from
+func GetFederatedIssueByIssueID(ctx context.Context, issueID int64) (*FederatedIssue, error) {
+	fi := &FederatedIssue{}
+	has, err := db.GetEngine(ctx).Where("issue_id = ? AND comment_id = 0", issueID).Get(fi)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, util.NewNotExistErrorf("federated issue does not exist")
+	}
+	return fi, nil
+}
+
+// DeleteFederatedIssuesByHostID drops the links to the objects of a federation host
+func DeleteFederatedIssuesByHostID(ctx context.Context, federationHostID int64) error {
+	_, err := db.GetEngine(ctx).Where("federation_host_id = ?", federationHostID).Delete(new(FederatedIssue))
+	return err
+}
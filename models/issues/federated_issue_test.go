@@ -0,0 +1,40 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package issues_test
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/models/db"
+	issues_model "code.gitea.io/gitea/models/issues"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveFederatedIssue(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	uri := "https://example.com/api/v1/activitypub/repository-id/1/merge-requests/1"
+	fi := &issues_model.FederatedIssue{FederationHostID: 1, ObjectURI: uri}
+	reserved, err := issues_model.ReserveFederatedIssue(db.DefaultContext, fi)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// the object was already received
+	reserved, err = issues_model.ReserveFederatedIssue(db.DefaultContext, &issues_model.FederatedIssue{FederationHostID: 1, ObjectURI: uri})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	require.NoError(t, fi.SetIssueID(db.DefaultContext, 2))
+	loaded, err := issues_model.GetFederatedIssueByObjectURI(db.DefaultContext, uri)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, loaded.IssueID)
+
+	require.NoError(t, issues_model.DeleteFederatedIssueByID(db.DefaultContext, fi.ID))
+	_, err = issues_model.GetFederatedIssueByID(db.DefaultContext, fi.ID)
+	require.ErrorIs(t, err, util.ErrNotExist)
+}
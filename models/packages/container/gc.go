@@ -0,0 +1,123 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package container
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/packages"
+	container_module "code.gitea.io/gitea/modules/packages/container"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/builder"
+)
+
+// ImageManifest describes a manifest version of an image and the manifests it is linked to
+type ImageManifest struct {
+	VersionID   int64
+	Version     string
+	Digest      string
+	IsTagged    bool
+	References  []string
+	Subject     string
+	CreatedUnix timeutil.TimeStamp
+}
+
+// GetImages gets all container packages, optionally limited to the packages of an owner
+func GetImages(ctx context.Context, ownerID int64) ([]*packages.Package, error) {
+	cond := builder.Eq{
+		"type":        packages.TypeContainer,
+		"is_internal": false,
+	}
+	if ownerID != 0 {
+		cond["owner_id"] = ownerID
+	}
+
+	ps := make([]*packages.Package, 0, 10)
+	return ps, db.GetEngine(ctx).Where(cond).OrderBy("id ASC").Find(&ps)
+}
+
+// GetImageManifests gets all manifest versions of an image
+func GetImageManifests(ctx context.Context, packageID int64) ([]*ImageManifest, error) {
+	pvs := make([]*packages.PackageVersion, 0, 10)
+	if err := db.GetEngine(ctx).
+		Where("package_id = ? AND is_internal = ?", packageID, false).
+		OrderBy("id ASC").
+		Find(&pvs); err != nil {
+		return nil, err
+	}
+	if len(pvs) == 0 {
+		return nil, nil
+	}
+
+	manifests := make([]*ImageManifest, 0, len(pvs))
+	versionMap := make(map[int64]*ImageManifest, len(pvs))
+	for _, pv := range pvs {
+		im := &ImageManifest{
+			VersionID:   pv.ID,
+			Version:     pv.LowerVersion,
+			CreatedUnix: pv.CreatedUnix,
+		}
+		manifests = append(manifests, im)
+		versionMap[pv.ID] = im
+	}
+
+	var digests []struct {
+		VersionID int64
+		Value     string
+	}
+	if err := db.GetEngine(ctx).
+		Table("package_file").
+		Select("package_file.version_id, package_property.value").
+		Join("INNER", "package_version", "package_version.id = package_file.version_id").
+		Join("INNER", "package_property", "package_property.ref_id = package_file.id").
+		Where(builder.Eq{
+			"package_version.package_id": packageID,
+			"package_file.lower_name":    ManifestFilename,
+			"package_property.ref_type":  packages.PropertyTypeFile,
+			"package_property.name":      container_module.PropertyDigest,
+		}).
+		Find(&digests); err != nil {
+		return nil, err
+	}
+	for _, d := range digests {
+		if im, ok := versionMap[d.VersionID]; ok {
+			im.Digest = d.Value
+		}
+	}
+
+	pps := make([]*packages.PackageProperty, 0, 10)
+	if err := db.GetEngine(ctx).
+		Table("package_property").
+		Select("package_property.*").
+		Join("INNER", "package_version", "package_version.id = package_property.ref_id").
+		Where(builder.Eq{
+			"package_version.package_id": packageID,
+			"package_property.ref_type":  packages.PropertyTypeVersion,
+		}.And(builder.In("package_property.name",
+			container_module.PropertyManifestTagged,
+			container_module.PropertyManifestReference,
+			container_module.PropertyManifestSubject,
+		))).
+		Find(&pps); err != nil {
+		return nil, err
+	}
+	for _, pp := range pps {
+		im, ok := versionMap[pp.RefID]
+		if !ok {
+			continue
+		}
+		switch pp.Name {
+		case container_module.PropertyManifestTagged:
+			im.IsTagged = true
+		case container_module.PropertyManifestReference:
+			im.References = append(im.References, pp.Value)
+		case container_module.PropertyManifestSubject:
+			im.Subject = pp.Value
+		}
+	}
+
+	return manifests, nil
+}
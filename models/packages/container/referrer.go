@@ -0,0 +1,127 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package container
+
+import (
+	"context"
+	"strings"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	container_module "code.gitea.io/gitea/modules/packages/container"
+
+	"xorm.io/builder"
+)
+
+// Referrer is a manifest which refers to another manifest, like a signature or an SBOM of an image
+type Referrer struct {
+	Version      *packages.PackageVersion
+	Manifest     *packages.PackageFileDescriptor
+	Digest       string
+	MediaType    string
+	ArtifactType string
+	Annotations  map[string]string
+}
+
+// ReferrerSearchOptions are the options to search the referrers of a manifest
+type ReferrerSearchOptions struct {
+	OwnerID      int64
+	Image        string
+	Subject      string
+	ArtifactType string
+}
+
+func (opts *ReferrerSearchOptions) toConds() builder.Cond {
+	cond := builder.Eq{
+		"package.type":                packages.TypeContainer,
+		"package.owner_id":            opts.OwnerID,
+		"package.lower_name":          strings.ToLower(opts.Image),
+		"package_version.is_internal": false,
+		"package_file.lower_name":     ManifestFilename,
+	}.And(builder.In("package_version.id",
+		builder.Select("package_property.ref_id").
+			From("package_property").
+			Where(builder.Eq{
+				"package_property.ref_type": packages.PropertyTypeVersion,
+				"package_property.name":     container_module.PropertyManifestSubject,
+				"package_property.value":    opts.Subject,
+			}),
+	))
+
+	if opts.ArtifactType != "" {
+		cond = cond.And(builder.In("package_version.id",
+			builder.Select("package_property.ref_id").
+				From("package_property").
+				Where(builder.Eq{
+					"package_property.ref_type": packages.PropertyTypeVersion,
+					"package_property.name":     container_module.PropertyManifestArtifactType,
+					"package_property.value":    opts.ArtifactType,
+				}),
+		))
+	}
+
+	return cond
+}
+
+// GetReferrers gets all manifests of the image which refer to the subject.
+// Manifests with the same digest are returned only once.
+func GetReferrers(ctx context.Context, opts *ReferrerSearchOptions) ([]*Referrer, error) {
+	pfs := make([]*packages.PackageFile, 0, 10)
+	if err := db.GetEngine(ctx).
+		Join("INNER", "package_version", "package_version.id = package_file.version_id").
+		Join("INNER", "package", "package.id = package_version.package_id").
+		Where(opts.toConds()).
+		OrderBy("package_file.id ASC").
+		Find(&pfs); err != nil {
+		return nil, err
+	}
+
+	referrers := make([]*Referrer, 0, len(pfs))
+	seen := make(map[string]bool, len(pfs))
+	for _, pf := range pfs {
+		pfd, err := packages.GetPackageFileDescriptor(ctx, pf)
+		if err != nil {
+			return nil, err
+		}
+
+		d := pfd.Properties.GetByName(container_module.PropertyDigest)
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+
+		pv, err := packages.GetVersionByID(ctx, pf.VersionID)
+		if err != nil {
+			return nil, err
+		}
+
+		pvps, err := packages.GetProperties(ctx, packages.PropertyTypeVersion, pv.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		r := &Referrer{
+			Version:   pv,
+			Manifest:  pfd,
+			Digest:    d,
+			MediaType: pfd.Properties.GetByName(container_module.PropertyMediaType),
+		}
+		for _, pvp := range pvps {
+			switch pvp.Name {
+			case container_module.PropertyManifestArtifactType:
+				r.ArtifactType = pvp.Value
+			case container_module.PropertyManifestAnnotations:
+				if err := json.Unmarshal([]byte(pvp.Value), &r.Annotations); err != nil {
+					log.Error("Invalid annotations of package version %d: %v", pv.ID, err)
+				}
+			}
+		}
+
+		referrers = append(referrers, r)
+	}
+
+	return referrers, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package packages
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+)
+
+var (
+	ErrPackageAttestationExist    = util.NewAlreadyExistErrorf("package attestation already exists")
+	ErrPackageAttestationNotExist = util.NewNotExistErrorf("package attestation does not exist")
+)
+
+func init() {
+	db.RegisterModel(new(PackageAttestation))
+}
+
+const (
+	AttestationSourceUpload  = "upload"
+	AttestationSourceActions = "actions"
+)
+
+// PackageAttestation is a provenance or signature attestation of a package version
+type PackageAttestation struct {
+	ID               int64              `xorm:"pk autoincr"`
+	VersionID        int64              `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	ContentSHA256    string             `xorm:"UNIQUE(s) NOT NULL"`
+	Content          string             `xorm:"LONGTEXT NOT NULL"`
+	Format           string             `xorm:"NOT NULL"`
+	PredicateType    string             `xorm:"TEXT NOT NULL"`
+	Source           string             `xorm:"NOT NULL"`
+	Verified         bool               `xorm:"NOT NULL DEFAULT false"`
+	SignerIdentity   string             `xorm:"TEXT"`
+	SignerIssuer     string             `xorm:"TEXT"`
+	BuilderID        string             `xorm:"TEXT"`
+	SourceRepository string             `xorm:"TEXT"`
+	SourceRef        string             `xorm:"TEXT"`
+	CommitSHA        string             `xorm:"VARCHAR(64)"`
+	Workflow         string             `xorm:"TEXT"`
+	RunURL           string             `xorm:"TEXT"`
+	CreatorID        int64              `xorm:"NOT NULL DEFAULT 0"`
+	CreatedUnix      timeutil.TimeStamp `xorm:"created NOT NULL DEFAULT 0"`
+}
+
+// TableName provides the real table name
+func (PackageAttestation) TableName() string {
+	return "forgejo_package_attestation"
+}
+
+// IsSigned checks if the attestation was signed with the certificate of a signer
+func (pa *PackageAttestation) IsSigned() bool {
+	return pa.SignerIdentity != ""
+}
+
+// IsVerified checks if the provenance was recorded by this instance, or if the certificate of
+// the signer was issued by a certificate authority of the configured Sigstore trusted root
+func (pa *PackageAttestation) IsVerified() bool {
+	return pa.Verified
+}
+
+// InsertAttestation inserts an attestation, ErrPackageAttestationExist is returned if the content exists already for the version
+func InsertAttestation(ctx context.Context, pa *PackageAttestation) error {
+	has, err := db.GetEngine(ctx).Exist(&PackageAttestation{VersionID: pa.VersionID, ContentSHA256: pa.ContentSHA256})
+	if err != nil {
+		return err
+	}
+	if has {
+		return ErrPackageAttestationExist
+	}
+	return db.Insert(ctx, pa)
+}
+
+// GetAttestationByID gets an attestation of the package version
+func GetAttestationByID(ctx context.Context, versionID, id int64) (*PackageAttestation, error) {
+	pa := &PackageAttestation{}
+	has, err := db.GetEngine(ctx).Where("id = ? AND version_id = ?", id, versionID).Get(pa)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrPackageAttestationNotExist
+	}
+	return pa, nil
+}
+
+// GetAttestationsByVersionID gets all attestations of the package version
+func GetAttestationsByVersionID(ctx context.Context, versionID int64) ([]*PackageAttestation, error) {
+	pas := make([]*PackageAttestation, 0, 2)
+	return pas, db.GetEngine(ctx).Where("version_id = ?", versionID).Asc("id").Find(&pas)
+}
+
+// DeleteAttestationByID deletes an attestation
+func DeleteAttestationByID(ctx context.Context, id int64) error {
+	_, err := db.GetEngine(ctx).ID(id).Delete(&PackageAttestation{})
+	return err
+}
+
+// DeleteAttestationsByVersionID deletes all attestations of the package version
+func DeleteAttestationsByVersionID(ctx context.Context, versionID int64) error {
+	_, err := db.GetEngine(ctx).Where("version_id = ?", versionID).Delete(&PackageAttestation{})
+	return err
+}
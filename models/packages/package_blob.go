@@ -93,6 +93,20 @@ func FindExpiredUnreferencedBlobs(ctx context.Context, olderThan time.Duration)
 		Find(&pbs)
 }
 
+// FindUnreferencedBlobsByIDs gets all blobs of the given ids without associated files
+func FindUnreferencedBlobsByIDs(ctx context.Context, blobIDs []int64) ([]*PackageBlob, error) {
+	pbs := make([]*PackageBlob, 0, len(blobIDs))
+	if len(blobIDs) == 0 {
+		return pbs, nil
+	}
+	return pbs, db.GetEngine(ctx).
+		Table("package_blob").
+		Join("LEFT", "package_file", "package_file.blob_id = package_blob.id").
+		Where("package_file.id IS NULL").
+		In("package_blob.id", blobIDs).
+		Find(&pbs)
+}
+
 // DeleteBlobByID deletes a blob by id
 func DeleteBlobByID(ctx context.Context, blobID int64) error {
 	_, err := db.GetEngine(ctx).ID(blobID).Delete(&PackageBlob{})
@@ -0,0 +1,135 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package packages
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/secret"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// PropertyProxyUpstream is the name of the version property which marks a version as cached from an upstream registry,
+// its value is the url of the upstream registry
+const PropertyProxyUpstream = "proxy.upstream"
+
+// ProxyTypeList are the package types which can be proxied from an upstream registry
+var ProxyTypeList = []Type{
+	TypeContainer,
+	TypeMaven,
+	TypeNpm,
+	TypePyPI,
+}
+
+// IsProxyType returns true if the package type can be proxied from an upstream registry
+func IsProxyType(t Type) bool {
+	for _, pt := range ProxyTypeList {
+		if pt == t {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrPackageProxyNotExist = util.NewNotExistErrorf("package proxy does not exist")
+
+func init() {
+	db.RegisterModel(new(PackageProxy))
+}
+
+// PackageProxy describes the upstream registry used to fetch the packages of a type which do not exist for the owner
+type PackageProxy struct {
+	ID      int64  `xorm:"pk autoincr"`
+	OwnerID int64  `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	Type    Type   `xorm:"UNIQUE(s) NOT NULL"`
+	Enabled bool   `xorm:"NOT NULL DEFAULT false"`
+	URL     string `xorm:"TEXT NOT NULL"`
+
+	Username string `xorm:"NOT NULL DEFAULT ''"`
+	// PasswordEncrypted should be accessed using Password() and SetPassword()
+	PasswordEncrypted string `xorm:"TEXT"`
+
+	CreatedUnix timeutil.TimeStamp `xorm:"created NOT NULL DEFAULT 0"`
+	UpdatedUnix timeutil.TimeStamp `xorm:"updated NOT NULL DEFAULT 0"`
+}
+
+// TableName provides the real table name
+func (PackageProxy) TableName() string {
+	return "forgejo_package_proxy"
+}
+
+// Password returns the decrypted password used to authenticate against the upstream registry
+func (pp *PackageProxy) Password() (string, error) {
+	if pp.PasswordEncrypted == "" {
+		return "", nil
+	}
+	return secret.DecryptSecret(setting.SecretKey, pp.PasswordEncrypted)
+}
+
+// SetPassword encrypts the password used to authenticate against the upstream registry
+func (pp *PackageProxy) SetPassword(password string) (err error) {
+	if password == "" {
+		pp.PasswordEncrypted = ""
+		return nil
+	}
+	pp.PasswordEncrypted, err = secret.EncryptSecret(setting.SecretKey, password)
+	return err
+}
+
+func InsertProxy(ctx context.Context, pp *PackageProxy) (*PackageProxy, error) {
+	return pp, db.Insert(ctx, pp)
+}
+
+func GetProxyByID(ctx context.Context, id int64) (*PackageProxy, error) {
+	pp := &PackageProxy{}
+
+	has, err := db.GetEngine(ctx).ID(id).Get(pp)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrPackageProxyNotExist
+	}
+	return pp, nil
+}
+
+// GetEnabledProxyByOwnerAndType returns the enabled proxy of the owner for the package type
+func GetEnabledProxyByOwnerAndType(ctx context.Context, ownerID int64, packageType Type) (*PackageProxy, error) {
+	pp := &PackageProxy{}
+
+	has, err := db.GetEngine(ctx).
+		Where("owner_id = ? AND type = ? AND enabled = ?", ownerID, packageType, true).
+		Get(pp)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrPackageProxyNotExist
+	}
+	return pp, nil
+}
+
+func UpdateProxy(ctx context.Context, pp *PackageProxy) error {
+	_, err := db.GetEngine(ctx).ID(pp.ID).AllCols().Update(pp)
+	return err
+}
+
+func GetProxiesByOwner(ctx context.Context, ownerID int64) ([]*PackageProxy, error) {
+	pps := make([]*PackageProxy, 0, 4)
+	return pps, db.GetEngine(ctx).Where("owner_id = ?", ownerID).Asc("type").Find(&pps)
+}
+
+func DeleteProxyByID(ctx context.Context, id int64) error {
+	_, err := db.GetEngine(ctx).ID(id).Delete(&PackageProxy{})
+	return err
+}
+
+func HasOwnerProxyForPackageType(ctx context.Context, ownerID int64, packageType Type) (bool, error) {
+	return db.GetEngine(ctx).
+		Where("owner_id = ? AND type = ?", ownerID, packageType).
+		Exist(&PackageProxy{})
+}
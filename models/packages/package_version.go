@@ -346,3 +346,28 @@ func CountVersions(ctx context.Context, opts *PackageSearchOptions) (int64, erro
 		Join("INNER", "package", "package.id = package_version.package_id").
 		Count(new(PackageVersion))
 }
+
+// GetRepositoryVersionsByVersions gets the versions of the packages linked to the repository which match one of the version strings
+func GetRepositoryVersionsByVersions(ctx context.Context, repositoryID int64, versions []string) ([]*PackageVersion, error) {
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	lowerVersions := make([]string, 0, len(versions))
+	for _, v := range versions {
+		lowerVersions = append(lowerVersions, strings.ToLower(v))
+	}
+
+	pvs := make([]*PackageVersion, 0, 5)
+	return pvs, db.GetEngine(ctx).
+		Select("package_version.*").
+		Table("package_version").
+		Join("INNER", "package", "package.id = package_version.package_id").
+		Where(builder.Eq{
+			"package.repo_id":             repositoryID,
+			"package.is_internal":         false,
+			"package_version.is_internal": false,
+		}.And(builder.In("package_version.lower_version", lowerVersions))).
+		Asc("package.type", "package.lower_name").
+		Find(&pvs)
+}
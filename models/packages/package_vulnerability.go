@@ -0,0 +1,123 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package packages
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"xorm.io/builder"
+)
+
+func init() {
+	db.RegisterModel(new(PackageAdvisory))
+	db.RegisterModel(new(PackageVulnerability))
+}
+
+// PackageAdvisory is an imported advisory for a single package of an ecosystem.
+// An advisory affecting multiple packages is stored once per package.
+type PackageAdvisory struct {
+	ID           int64              `xorm:"pk autoincr"`
+	AdvisoryID   string             `xorm:"UNIQUE(s) NOT NULL"`
+	Ecosystem    string             `xorm:"UNIQUE(s) INDEX(p) NOT NULL"`
+	PackageName  string             `xorm:"UNIQUE(s) INDEX(p) NOT NULL"`
+	Aliases      []string           `xorm:"JSON TEXT"`
+	Summary      string             `xorm:"TEXT"`
+	Severity     string             `xorm:"NOT NULL"`
+	AffectedJSON string             `xorm:"affected_json TEXT NOT NULL"`
+	ModifiedUnix timeutil.TimeStamp `xorm:"NOT NULL DEFAULT 0"`
+}
+
+// TableName provides the real table name
+func (PackageAdvisory) TableName() string {
+	return "forgejo_package_advisory"
+}
+
+// PackageVulnerability is an advisory matching a package version or one of its dependencies
+type PackageVulnerability struct {
+	ID             int64              `xorm:"pk autoincr"`
+	VersionID      int64              `xorm:"UNIQUE(s) INDEX NOT NULL"`
+	AdvisoryID     string             `xorm:"UNIQUE(s) NOT NULL"`
+	Ecosystem      string             `xorm:"NOT NULL"`
+	PackageName    string             `xorm:"UNIQUE(s) NOT NULL"`
+	PackageVersion string             `xorm:"NOT NULL"`
+	Severity       string             `xorm:"INDEX NOT NULL"`
+	Summary        string             `xorm:"TEXT"`
+	CreatedUnix    timeutil.TimeStamp `xorm:"created NOT NULL DEFAULT 0"`
+}
+
+// TableName provides the real table name
+func (PackageVulnerability) TableName() string {
+	return "forgejo_package_vulnerability"
+}
+
+// ReplaceAdvisory replaces all stored entries of the advisory
+func ReplaceAdvisory(ctx context.Context, advisoryID string, pas []*PackageAdvisory) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if err := DeleteAdvisory(ctx, advisoryID); err != nil {
+			return err
+		}
+		if len(pas) == 0 {
+			return nil
+		}
+		return db.Insert(ctx, pas)
+	})
+}
+
+// DeleteAdvisory deletes all stored entries of the advisory
+func DeleteAdvisory(ctx context.Context, advisoryID string) error {
+	_, err := db.GetEngine(ctx).Where("advisory_id = ?", advisoryID).Delete(&PackageAdvisory{})
+	return err
+}
+
+// GetAdvisoriesByPackage gets the advisories affecting the package of the ecosystem
+func GetAdvisoriesByPackage(ctx context.Context, ecosystem, name string) ([]*PackageAdvisory, error) {
+	pas := make([]*PackageAdvisory, 0, 5)
+	return pas, db.GetEngine(ctx).
+		Where("ecosystem = ? AND package_name = ?", ecosystem, name).
+		Asc("advisory_id").
+		Find(&pas)
+}
+
+// CountAdvisories counts the distinct imported advisories
+func CountAdvisories(ctx context.Context) (int64, error) {
+	return db.GetEngine(ctx).Select("COUNT(DISTINCT advisory_id)").Table("forgejo_package_advisory").Count()
+}
+
+// ReplaceVulnerabilities replaces the findings of the package version
+func ReplaceVulnerabilities(ctx context.Context, versionID int64, pvs []*PackageVulnerability) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if err := DeleteVulnerabilitiesByVersionID(ctx, versionID); err != nil {
+			return err
+		}
+		if len(pvs) == 0 {
+			return nil
+		}
+		return db.Insert(ctx, pvs)
+	})
+}
+
+// GetVulnerabilitiesByVersionID gets the findings of the package version
+func GetVulnerabilitiesByVersionID(ctx context.Context, versionID int64) ([]*PackageVulnerability, error) {
+	pvs := make([]*PackageVulnerability, 0, 5)
+	if err := db.GetEngine(ctx).Where("version_id = ?", versionID).Asc("advisory_id", "package_name").Find(&pvs); err != nil {
+		return nil, err
+	}
+	return pvs, nil
+}
+
+// HasVulnerabilitiesWithSeverity checks if the package version has findings with one of the severities
+func HasVulnerabilitiesWithSeverity(ctx context.Context, versionID int64, severities ...string) (bool, error) {
+	return db.GetEngine(ctx).
+		Where(builder.Eq{"version_id": versionID}.And(builder.In("severity", severities))).
+		Exist(&PackageVulnerability{})
+}
+
+// DeleteVulnerabilitiesByVersionID deletes the findings of the package version
+func DeleteVulnerabilitiesByVersionID(ctx context.Context, versionID int64) error {
+	_, err := db.GetEngine(ctx).Where("version_id = ?", versionID).Delete(&PackageVulnerability{})
+	return err
+}
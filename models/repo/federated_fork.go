@@ -0,0 +1,46 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"code.gitea.io/gitea/modules/validation"
+)
+
+// FederatedFork links a local repository to the repository of a federated instance it was forked from
+type FederatedFork struct {
+	ID               int64  `xorm:"pk autoincr"`
+	RepoID           int64  `xorm:"UNIQUE NOT NULL"`
+	ExternalID       string `xorm:"NOT NULL"`
+	FederationHostID int64  `xorm:"INDEX NOT NULL"`
+	URI              string `xorm:"TEXT NOT NULL"`
+	InboxURI         string `xorm:"TEXT NOT NULL"`
+}
+
+func (FederatedFork) TableName() string {
+	return "forgejo_federated_fork"
+}
+
+func NewFederatedFork(repoID int64, externalID string, federationHostID int64, uri, inboxURI string) (FederatedFork, error) {
+	result := FederatedFork{
+		RepoID:           repoID,
+		ExternalID:       externalID,
+		FederationHostID: federationHostID,
+		URI:              uri,
+		InboxURI:         inboxURI,
+	}
+	if valid, err := validation.IsValid(result); !valid {
+		return FederatedFork{}, err
+	}
+	return result, nil
+}
+
+func (fork FederatedFork) Validate() []string {
+	var result []string
+	result = append(result, validation.ValidateNotEmpty(fork.RepoID, "RepoID")...)
+	result = append(result, validation.ValidateNotEmpty(fork.ExternalID, "ExternalID")...)
+	result = append(result, validation.ValidateNotEmpty(fork.FederationHostID, "FederationHostID")...)
+	result = append(result, validation.ValidateNotEmpty(fork.URI, "URI")...)
+	result = append(result, validation.ValidateNotEmpty(fork.InboxURI, "InboxURI")...)
+	return result
+}
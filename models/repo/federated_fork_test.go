@@ -0,0 +1,28 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/modules/validation"
+)
+
+func Test_FederatedForkValidation(t *testing.T) {
+	sut := FederatedFork{
+		RepoID:           12,
+		ExternalID:       "1",
+		FederationHostID: 1,
+		URI:              "http://localhost:3000/api/v1/activitypub/repository-id/1",
+		InboxURI:         "http://localhost:3000/api/v1/activitypub/repository-id/1/inbox",
+	}
+	if res, err := validation.IsValid(sut); !res {
+		t.Errorf("sut should be valid but was %q", err)
+	}
+
+	sut.InboxURI = ""
+	if res, _ := validation.IsValid(sut); res {
+		t.Errorf("sut should be invalid: InboxURI empty")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"context"
+	"strings"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/modules/timeutil"
+)
+
+// RepoMaintenance is the git maintenance state of a repository: the object counts measured by the last
+// maintenance run, the tasks it ran, and the object filters allowed for partial clones
+type RepoMaintenance struct { //revive:disable-line:exported
+	ID     int64 `xorm:"pk autoincr"`
+	RepoID int64 `xorm:"UNIQUE NOT NULL"`
+
+	// AllowedFilters is a comma separated list of the object filters allowed for partial clones,
+	// when empty the instance configuration applies
+	AllowedFilters string `xorm:"TEXT"`
+
+	LooseObjects      int64
+	LooseSize         int64
+	Packs             int64
+	PackSize          int64
+	HasCommitGraph    bool `xorm:"NOT NULL DEFAULT false"`
+	HasMultiPackIndex bool `xorm:"NOT NULL DEFAULT false"`
+
+	LastTasks   string             `xorm:"TEXT"`
+	LastError   string             `xorm:"TEXT"`
+	LastRunUnix timeutil.TimeStamp `xorm:"INDEX"`
+	CreatedUnix timeutil.TimeStamp `xorm:"created"`
+	UpdatedUnix timeutil.TimeStamp `xorm:"updated"`
+}
+
+func init() {
+	db.RegisterModel(new(RepoMaintenance))
+}
+
+// TableName provides the real table name
+func (RepoMaintenance) TableName() string {
+	return "forgejo_repo_maintenance"
+}
+
+// GetAllowedFilters returns the object filters allowed for partial clones, nil if they are not restricted
+func (m *RepoMaintenance) GetAllowedFilters() []string {
+	if m.AllowedFilters == "" {
+		return nil
+	}
+	return strings.Split(m.AllowedFilters, ",")
+}
+
+// SetAllowedFilters restricts the object filters allowed for partial clones, nil removes the restriction
+func (m *RepoMaintenance) SetAllowedFilters(filters []string) {
+	m.AllowedFilters = strings.Join(filters, ",")
+}
+
+// GetLastTasks returns the tasks run by the last maintenance
+func (m *RepoMaintenance) GetLastTasks() []string {
+	if m.LastTasks == "" {
+		return nil
+	}
+	return strings.Split(m.LastTasks, ",")
+}
+
+// GetRepoMaintenance returns the maintenance state of a repository, a new one if it was never maintained
+func GetRepoMaintenance(ctx context.Context, repoID int64) (*RepoMaintenance, error) {
+	m := &RepoMaintenance{RepoID: repoID}
+	if _, err := db.GetEngine(ctx).Where("repo_id = ?", repoID).Get(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SaveRepoMaintenance inserts or updates the maintenance state of a repository
+func SaveRepoMaintenance(ctx context.Context, m *RepoMaintenance) error {
+	if m.ID == 0 {
+		_, err := db.GetEngine(ctx).Insert(m)
+		return err
+	}
+	_, err := db.GetEngine(ctx).ID(m.ID).AllCols().Update(m)
+	return err
+}
@@ -11,6 +11,7 @@ import (
 
 func init() {
 	db.RegisterModel(new(FollowingRepo))
+	db.RegisterModel(new(FederatedFork))
 }
 
 func FindFollowingReposByRepoID(ctx context.Context, repoID int64) ([]*FollowingRepo, error) {
@@ -58,3 +59,36 @@ func StoreFollowingRepos(ctx context.Context, localRepoID int64, followingRepoLi
 	// Commit transaction
 	return committer.Commit()
 }
+
+// DeleteFollowingReposByHostID removes the repositories of a federation host from the following lists
+func DeleteFollowingReposByHostID(ctx context.Context, federationHostID int64) error {
+	_, err := db.GetEngine(ctx).Where("federation_host_id=?", federationHostID).Delete(FollowingRepo{})
+	return err
+}
+
+// GetFederatedForkByRepoID returns the federated repository the local repository was forked from, nil if it is no federated fork
+func GetFederatedForkByRepoID(ctx context.Context, repoID int64) (*FederatedFork, error) {
+	fork := new(FederatedFork)
+	has, err := db.GetEngine(ctx).Where("repo_id=?", repoID).Get(fork)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	if res, err := validation.IsValid(*fork); !res {
+		return nil, err
+	}
+	return fork, nil
+}
+
+func CreateFederatedFork(ctx context.Context, fork *FederatedFork) error {
+	if res, err := validation.IsValid(*fork); !res {
+		return err
+	}
+	return db.Insert(ctx, fork)
+}
+
+func DeleteFederatedFork(ctx context.Context, repoID int64) error {
+	_, err := db.GetEngine(ctx).Where("repo_id=?", repoID).Delete(FederatedFork{})
+	return err
+}
@@ -12,6 +12,7 @@ type FederatedUser struct {
 	UserID           int64  `xorm:"NOT NULL"`
 	ExternalID       string `xorm:"UNIQUE(federation_user_mapping) NOT NULL"`
 	FederationHostID int64  `xorm:"UNIQUE(federation_user_mapping) NOT NULL"`
+	InboxURI         string
 }
 
 func NewFederatedUser(userID int64, externalID string, federationHostID int64) (FederatedUser, error) {
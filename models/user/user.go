@@ -966,6 +966,8 @@ func GetPossibleUserByID(ctx context.Context, id int64) (*User, error) {
 		return NewGhostUser(), nil
 	case ActionsUserID:
 		return NewActionsUser(), nil
+	case APActorUserID:
+		return NewAPActorUser(), nil
 	case 0:
 		return nil, ErrUserNotExist{}
 	default:
@@ -1097,6 +1099,30 @@ func GetUsersBySource(ctx context.Context, s *auth.Source) ([]*User, error) {
 	return users, err
 }
 
+// FindUsersBySource returns a page of the users of a login source, optionally restricted to a login name
+func FindUsersBySource(ctx context.Context, s *auth.Source, loginName string, paginator db.Paginator) ([]*User, int64, error) {
+	cond := builder.Eq{"login_type": s.Type, "login_source": s.ID}
+	if loginName != "" {
+		cond["login_name"] = loginName
+	}
+	_, take := paginator.GetSkipTake()
+	users := make([]*User, 0, take)
+	count, err := db.SetSessionPagination(db.GetEngine(ctx).Where(cond).OrderBy("id"), paginator).FindAndCount(&users)
+	return users, count, err
+}
+
+// GetUserBySourceAndLoginName returns the user a login source knows by the given login name
+func GetUserBySourceAndLoginName(ctx context.Context, s *auth.Source, loginName string) (*User, error) {
+	u := new(User)
+	has, err := db.GetEngine(ctx).Where("login_type = ? AND login_source = ? AND login_name = ?", s.Type, s.ID, loginName).Get(u)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, ErrUserNotExist{Name: loginName}
+	}
+	return u, nil
+}
+
 // UserCommit represents a commit with validation of user.
 type UserCommit struct { //revive:disable-line:exported
 	User *User
@@ -77,7 +77,41 @@ func FindFederatedUser(ctx context.Context, externalID string,
 	return user, federatedUser, nil
 }
 
+// GetFederatedUserByUserID returns the federated user of a remote user, nil if the user is not federated
+func GetFederatedUserByUserID(ctx context.Context, userID int64) (*FederatedUser, error) {
+	federatedUser := new(FederatedUser)
+	has, err := db.GetEngine(ctx).Where("user_id=?", userID).Get(federatedUser)
+	if err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return federatedUser, nil
+}
+
 func DeleteFederatedUser(ctx context.Context, userID int64) error {
 	_, err := db.GetEngine(ctx).Delete(&FederatedUser{UserID: userID})
 	return err
 }
+
+func UpdateFederatedUserInboxURI(ctx context.Context, federatedUser *FederatedUser) error {
+	_, err := db.GetEngine(ctx).ID(federatedUser.ID).Cols("inbox_uri").Update(federatedUser)
+	return err
+}
+
+// FindFederatedFollowers returns the federated users following the local user
+func FindFederatedFollowers(ctx context.Context, userID int64) ([]*FederatedUser, error) {
+	federatedUsers := make([]*FederatedUser, 0, 10)
+	return federatedUsers, db.GetEngine(ctx).
+		Join("INNER", "follow", "follow.user_id = federated_user.user_id").
+		Where("follow.follow_id = ?", userID).
+		Find(&federatedUsers)
+}
+
+// FindFederatedUsersByHostID returns the users federated from a federation host
+func FindFederatedUsersByHostID(ctx context.Context, federationHostID int64) ([]*FederatedUser, error) {
+	federatedUsers := make([]*FederatedUser, 0, 10)
+	return federatedUsers, db.GetEngine(ctx).
+		Where("federation_host_id = ?", federationHostID).
+		Find(&federatedUsers)
+}
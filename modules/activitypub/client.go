@@ -18,6 +18,7 @@ import (
 	"time"
 
 	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/hostmatcher"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/proxy"
 	"code.gitea.io/gitea/modules/setting"
@@ -74,10 +75,8 @@ func NewClientFactory() (c *ClientFactory, err error) {
 
 	c = &ClientFactory{
 		client: &http.Client{
-			Transport: &http.Transport{
-				Proxy: proxy.Proxy(),
-			},
-			Timeout: 5 * time.Second,
+			Transport: NewTransport(),
+			Timeout:   5 * time.Second,
 		},
 		algs:        setting.HttpsigAlgs,
 		digestAlg:   httpsig.DigestAlgorithm(setting.Federation.DigestAlgorithm),
@@ -87,6 +86,15 @@ func NewClientFactory() (c *ClientFactory, err error) {
 	return c, err
 }
 
+// NewTransport returns the transport of the federation requests, which can only reach the allowed hosts
+func NewTransport() *http.Transport {
+	allowedHosts := hostmatcher.ParseHostMatchList("federation.ALLOWED_HOST_LIST", setting.Federation.AllowedHostList)
+	return &http.Transport{
+		Proxy:       proxy.Proxy(),
+		DialContext: hostmatcher.NewDialContext("federation", allowedHosts, nil, setting.Proxy.ProxyURLFixed),
+	}
+}
+
 type APClientFactory interface {
 	WithKeys(ctx context.Context, user *user_model.User, pubID string) (APClient, error)
 }
@@ -18,6 +18,7 @@ import (
 	user_model "code.gitea.io/gitea/models/user"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -109,6 +110,7 @@ func TestActivityPubSignedGet(t *testing.T) {
 */
 
 func TestActivityPubSignedPost(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	require.NoError(t, unittest.PrepareTestDatabase())
 	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
 	pubID := "https://example.com/pubID"
@@ -136,3 +138,24 @@ func TestActivityPubSignedPost(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, expected, string(body))
 }
+
+func TestActivityPubAllowedHosts(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+
+	requested := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer srv.Close()
+
+	// the default list only allows external hosts
+	cf, err := NewClientFactory()
+	require.NoError(t, err)
+	c, err := cf.WithKeys(db.DefaultContext, user, "https://example.com/pubID")
+	require.NoError(t, err)
+
+	_, err = c.Post([]byte("BODY"), srv.URL)
+	require.ErrorContains(t, err, "can only call allowed HTTP servers")
+	assert.False(t, requested)
+}
@@ -35,6 +35,21 @@ func GetKeyPair(ctx context.Context, user *user_model.User) (pub, priv string, e
 	return pub, priv, err
 }
 
+// RotateKeyPair function replaces a user's keys by a newly generated pair
+func RotateKeyPair(ctx context.Context, user *user_model.User) (pub string, err error) {
+	priv, pub, err := util.GenerateKeyPair(rsaBits)
+	if err != nil {
+		return "", err
+	}
+	if err = user_model.SetUserSetting(ctx, user.ID, user_model.UserActivityPubPrivPem, priv); err != nil {
+		return "", err
+	}
+	if err = user_model.SetUserSetting(ctx, user.ID, user_model.UserActivityPubPubPem, pub); err != nil {
+		return "", err
+	}
+	return pub, nil
+}
+
 // GetPublicKey function returns a user's public key
 func GetPublicKey(ctx context.Context, user *user_model.User) (pub string, err error) {
 	pub, _, err = GetKeyPair(ctx, user)
@@ -28,3 +28,19 @@ func TestUserSettings(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, priv, priv1)
 }
+
+func TestRotateKeyPair(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+	user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+	pub, priv, err := GetKeyPair(db.DefaultContext, user1)
+	require.NoError(t, err)
+
+	rotated, err := RotateKeyPair(db.DefaultContext, user1)
+	require.NoError(t, err)
+	assert.NotEqual(t, pub, rotated)
+
+	pub1, priv1, err := GetKeyPair(db.DefaultContext, user1)
+	require.NoError(t, err)
+	assert.Equal(t, rotated, pub1)
+	assert.NotEqual(t, priv, priv1)
+}
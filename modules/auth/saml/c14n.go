@@ -0,0 +1,131 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// canonicalize serializes an element with Exclusive XML Canonicalization
+// (https://www.w3.org/TR/xml-exc-c14n/), without comments.
+// The excluded element and its descendants are left out, which implements the enveloped signature transform.
+// The inclusive prefixes are rendered whenever they are in scope, "#default" stands for the default namespace.
+func canonicalize(e, excluded *element, inclusivePrefixes []string) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, e, excluded, inclusivePrefixes, map[string]string{})
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, e, excluded *element, inclusivePrefixes []string, rendered map[string]string) {
+	utilized := map[string]bool{e.Prefix: true}
+	for _, a := range e.Attrs {
+		if a.Prefix != "" && a.Prefix != "xml" {
+			utilized[a.Prefix] = true
+		}
+	}
+	for _, p := range inclusivePrefixes {
+		if p == "#default" {
+			p = ""
+		}
+		if _, ok := e.lookupNamespace(p); ok {
+			utilized[p] = true
+		}
+	}
+
+	prefixes := make([]string, 0, len(utilized))
+	for p := range utilized {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	scope := rendered
+	var decls []string
+	for _, p := range prefixes {
+		space, _ := e.lookupNamespace(p)
+		previous, ok := rendered[p]
+		if p == "" {
+			// an absent default namespace is the same as the empty one
+			if previous == space {
+				continue
+			}
+		} else if ok && previous == space {
+			continue
+		}
+		if len(decls) == 0 {
+			scope = make(map[string]string, len(rendered)+1)
+			for k, v := range rendered {
+				scope[k] = v
+			}
+		}
+		scope[p] = space
+		decls = append(decls, p)
+	}
+
+	buf.WriteByte('<')
+	writeQName(buf, e.Prefix, e.Local)
+	for _, p := range decls {
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + p + `="`)
+		}
+		escapeAttr(buf, scope[p])
+		buf.WriteByte('"')
+	}
+
+	attrs := make([]attr, len(e.Attrs))
+	copy(attrs, e.Attrs)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].Space != attrs[j].Space {
+			return attrs[i].Space < attrs[j].Space
+		}
+		return attrs[i].Local < attrs[j].Local
+	})
+	for _, a := range attrs {
+		buf.WriteByte(' ')
+		writeQName(buf, a.Prefix, a.Local)
+		buf.WriteString(`="`)
+		escapeAttr(buf, a.Value)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+
+	for _, c := range e.Children {
+		switch c := c.(type) {
+		case string:
+			escapeText(buf, c)
+		case *element:
+			if c != excluded {
+				writeCanonical(buf, c, excluded, inclusivePrefixes, scope)
+			}
+		}
+	}
+
+	buf.WriteString("</")
+	writeQName(buf, e.Prefix, e.Local)
+	buf.WriteByte('>')
+}
+
+func writeQName(buf *bytes.Buffer, prefix, local string) {
+	if prefix != "" {
+		buf.WriteString(prefix)
+		buf.WriteByte(':')
+	}
+	buf.WriteString(local)
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(buf *bytes.Buffer, s string) {
+	_, _ = textEscaper.WriteString(buf, s)
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	_, _ = attrEscaper.WriteString(buf, s)
+}
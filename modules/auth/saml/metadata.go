@@ -0,0 +1,146 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+const (
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+
+	bindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	bindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	nameIDFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+// IdentityProvider is what the service provider needs to know about an identity provider
+type IdentityProvider struct {
+	EntityID string
+	// SSOURL receives the authentication requests with the HTTP-Redirect binding
+	SSOURL       string
+	Certificates []*x509.Certificate
+}
+
+type metadataEntities struct {
+	XMLName  xml.Name
+	EntityID string             `xml:"entityID,attr"`
+	IDP      []metadataIDP      `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+	Entities []metadataEntities `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+}
+
+type metadataIDP struct {
+	KeyDescriptors []struct {
+		Use          string   `xml:"use,attr"`
+		Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SingleSignOnServices []struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+// ParseIdentityProviderMetadata reads the entity ID, the single sign-on URL and the signing certificates
+// from the metadata of an identity provider. The metadata can be an EntityDescriptor or an EntitiesDescriptor,
+// in which case the first identity provider is used.
+func ParseIdentityProviderMetadata(data []byte) (*IdentityProvider, error) {
+	var root metadataEntities
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	entity := findIdentityProvider(&root)
+	if entity == nil {
+		return nil, errors.New("the metadata does not describe an identity provider")
+	}
+
+	idp := &IdentityProvider{EntityID: entity.EntityID}
+	if idp.EntityID == "" {
+		return nil, errors.New("the identity provider has no entityID")
+	}
+	for _, sso := range entity.IDP[0].SingleSignOnServices {
+		if sso.Binding == bindingHTTPRedirect {
+			idp.SSOURL = sso.Location
+			break
+		}
+	}
+	if idp.SSOURL == "" {
+		return nil, errors.New("the identity provider has no single sign-on service with the HTTP-Redirect binding")
+	}
+	for _, kd := range entity.IDP[0].KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, c := range kd.Certificates {
+			der, err := decodeBase64(c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			idp.Certificates = append(idp.Certificates, cert)
+		}
+	}
+	if len(idp.Certificates) == 0 {
+		return nil, errors.New("the identity provider has no signing certificate")
+	}
+	return idp, nil
+}
+
+func findIdentityProvider(e *metadataEntities) *metadataEntities {
+	if e.XMLName.Space != nsMetadata {
+		return nil
+	}
+	if e.XMLName.Local == "EntityDescriptor" && len(e.IDP) > 0 {
+		return e
+	}
+	for i := range e.Entities {
+		if found := findIdentityProvider(&e.Entities[i]); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+type spMetadata struct {
+	XMLName  xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID string   `xml:"entityID,attr"`
+	SP       struct {
+		AuthnRequestsSigned        bool   `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool   `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string `xml:"protocolSupportEnumeration,attr"`
+		NameIDFormat               string `xml:"urn:oasis:names:tc:SAML:2.0:metadata NameIDFormat"`
+		AssertionConsumerService   struct {
+			Binding   string `xml:"Binding,attr"`
+			Location  string `xml:"Location,attr"`
+			Index     int    `xml:"index,attr"`
+			IsDefault bool   `xml:"isDefault,attr"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:metadata AssertionConsumerService"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SPSSODescriptor"`
+}
+
+// Metadata returns the metadata of the service provider, to be imported by the identity provider
+func (sp *ServiceProvider) Metadata() ([]byte, error) {
+	m := spMetadata{EntityID: sp.EntityID}
+	m.SP.WantAssertionsSigned = true
+	m.SP.ProtocolSupportEnumeration = nsProtocol
+	m.SP.NameIDFormat = nameIDFormatUnspecified
+	m.SP.AssertionConsumerService.Binding = bindingHTTPPost
+	m.SP.AssertionConsumerService.Location = sp.AcsURL
+	m.SP.AssertionConsumerService.Index = 1
+	m.SP.AssertionConsumerService.IsDefault = true
+
+	out, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
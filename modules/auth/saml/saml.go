@@ -0,0 +1,306 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+// Package saml implements the SAML 2.0 Web Browser SSO profile for a service provider:
+// authentication requests are sent with the HTTP-Redirect binding and responses are
+// received with the HTTP-POST binding. Responses or assertions must be signed,
+// encrypted assertions are not supported.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	statusSuccess               = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	subjectConfirmationBearer   = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	maxClockSkew                = 3 * time.Minute
+	maxEncodedResponseSize      = 1 << 20
+	authnRequestTimestampFormat = "2006-01-02T15:04:05Z"
+)
+
+// ServiceProvider is the local end of a SAML authentication
+type ServiceProvider struct {
+	EntityID string
+	// AcsURL is the assertion consumer service, receiving the responses with the HTTP-POST binding
+	AcsURL string
+	IDP    *IdentityProvider
+	// Now returns the current time, time.Now if nil
+	Now func() time.Time
+}
+
+// Assertion is the verified identity of a user
+type Assertion struct {
+	NameID string
+	// Attributes are indexed by both their name and their friendly name
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of an attribute
+func (a *Assertion) Attribute(name string) string {
+	if values := a.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (sp *ServiceProvider) now() time.Time {
+	if sp.Now != nil {
+		return sp.Now()
+	}
+	return time.Now()
+}
+
+type authnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	NameIDPolicy                struct {
+		Format      string `xml:"Format,attr"`
+		AllowCreate bool   `xml:"AllowCreate,attr"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+}
+
+// AuthnRequestURL returns the URL redirecting the user to the identity provider, and the ID of the request
+// which must be kept to validate the response.
+func (sp *ServiceProvider) AuthnRequestURL(relayState string) (string, string, error) {
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	req := authnRequest{
+		ID:                          "id-" + hex.EncodeToString(random),
+		Version:                     "2.0",
+		IssueInstant:                sp.now().UTC().Format(authnRequestTimestampFormat),
+		Destination:                 sp.IDP.SSOURL,
+		AssertionConsumerServiceURL: sp.AcsURL,
+		ProtocolBinding:             bindingHTTPPost,
+		Issuer:                      sp.EntityID,
+	}
+	req.NameIDPolicy.Format = nameIDFormatUnspecified
+	req.NameIDPolicy.AllowCreate = true
+
+	out, err := xml.Marshal(req)
+	if err != nil {
+		return "", "", err
+	}
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := w.Write(out); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(deflated.Bytes())}}
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	separator := "?"
+	if strings.Contains(sp.IDP.SSOURL, "?") {
+		separator = "&"
+	}
+	return sp.IDP.SSOURL + separator + query.Encode(), req.ID, nil
+}
+
+// ParseResponse validates a base64 encoded response to the request with the given ID and returns its assertion.
+func (sp *ServiceProvider) ParseResponse(encoded, requestID string) (*Assertion, error) {
+	if requestID == "" {
+		return nil, errors.New("there is no pending authentication request")
+	}
+	if len(encoded) > maxEncodedResponseSize {
+		return nil, errors.New("response is too large")
+	}
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response encoding: %w", err)
+	}
+	response, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if response.Space != nsProtocol || response.Local != "Response" {
+		return nil, errors.New("not a SAML response")
+	}
+
+	status, err := response.child(nsProtocol, "Status")
+	if err != nil {
+		return nil, err
+	}
+	statusCode, err := status.child(nsProtocol, "StatusCode")
+	if err != nil {
+		return nil, err
+	}
+	if code := statusCode.attr("Value"); code != statusSuccess {
+		message := ""
+		if m := status.children(nsProtocol, "StatusMessage"); len(m) > 0 {
+			message = m[0].text()
+		}
+		return nil, fmt.Errorf("authentication failed at the identity provider: %s %s", code, message)
+	}
+
+	if len(response.children(nsAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted assertions are not supported")
+	}
+	assertion, err := response.child(nsAssertion, "Assertion")
+	if err != nil {
+		return nil, err
+	}
+
+	responseSigned, err := sp.verify(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response signature: %w", err)
+	}
+	assertionSigned, err := sp.verify(assertion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion signature: %w", err)
+	}
+	if !responseSigned && !assertionSigned {
+		return nil, errors.New("neither the response nor the assertion is signed")
+	}
+	if responseSigned {
+		if dest := response.attr("Destination"); dest != "" && dest != sp.AcsURL {
+			return nil, fmt.Errorf("response is destined to %q", dest)
+		}
+		if irt := response.attr("InResponseTo"); irt != requestID {
+			return nil, errors.New("response does not answer the pending authentication request")
+		}
+	}
+
+	return sp.validateAssertion(assertion, requestID)
+}
+
+// verify checks the signature of an element, it returns false if the element is not signed
+func (sp *ServiceProvider) verify(e *element) (bool, error) {
+	err := verifyEnvelopedSignature(e, sp.IDP.Certificates)
+	if errors.Is(err, errNotSigned) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (sp *ServiceProvider) validateAssertion(assertion *element, requestID string) (*Assertion, error) {
+	now := sp.now()
+
+	issuer, err := assertion.child(nsAssertion, "Issuer")
+	if err != nil {
+		return nil, err
+	}
+	if issuer.text() != sp.IDP.EntityID {
+		return nil, fmt.Errorf("assertion is issued by %q", issuer.text())
+	}
+
+	conditions, err := assertion.child(nsAssertion, "Conditions")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValidity(conditions, now); err != nil {
+		return nil, err
+	}
+	for _, restriction := range conditions.children(nsAssertion, "AudienceRestriction") {
+		found := false
+		for _, audience := range restriction.children(nsAssertion, "Audience") {
+			if audience.text() == sp.EntityID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("assertion is not intended for this service provider")
+		}
+	}
+
+	subject, err := assertion.child(nsAssertion, "Subject")
+	if err != nil {
+		return nil, err
+	}
+	nameID, err := subject.child(nsAssertion, "NameID")
+	if err != nil {
+		return nil, err
+	}
+	if err := sp.checkSubjectConfirmation(subject, requestID, now); err != nil {
+		return nil, err
+	}
+
+	a := &Assertion{NameID: nameID.text(), Attributes: map[string][]string{}}
+	if a.NameID == "" {
+		return nil, errors.New("assertion has an empty NameID")
+	}
+	for _, statement := range assertion.children(nsAssertion, "AttributeStatement") {
+		for _, attribute := range statement.children(nsAssertion, "Attribute") {
+			var values []string
+			for _, value := range attribute.children(nsAssertion, "AttributeValue") {
+				values = append(values, value.text())
+			}
+			for _, name := range []string{attribute.attr("Name"), attribute.attr("FriendlyName")} {
+				if name != "" {
+					a.Attributes[name] = append(a.Attributes[name], values...)
+				}
+			}
+		}
+	}
+	return a, nil
+}
+
+// checkSubjectConfirmation requires a bearer confirmation answering the request, for this service provider
+func (sp *ServiceProvider) checkSubjectConfirmation(subject *element, requestID string, now time.Time) error {
+	for _, confirmation := range subject.children(nsAssertion, "SubjectConfirmation") {
+		if confirmation.attr("Method") != subjectConfirmationBearer {
+			continue
+		}
+		data, err := confirmation.child(nsAssertion, "SubjectConfirmationData")
+		if err != nil {
+			continue
+		}
+		if data.attr("Recipient") != sp.AcsURL || data.attr("InResponseTo") != requestID || data.attr("NotOnOrAfter") == "" {
+			continue
+		}
+		if checkValidity(data, now) != nil {
+			continue
+		}
+		return nil
+	}
+	return errors.New("assertion has no valid bearer subject confirmation for this request")
+}
+
+// checkValidity checks the NotBefore and NotOnOrAfter attributes of an element
+func checkValidity(e *element, now time.Time) error {
+	if notBefore := e.attr("NotBefore"); notBefore != "" {
+		t, err := time.Parse(time.RFC3339Nano, notBefore)
+		if err != nil {
+			return fmt.Errorf("invalid NotBefore: %w", err)
+		}
+		if now.Add(maxClockSkew).Before(t) {
+			return errors.New("assertion is not valid yet")
+		}
+	}
+	if notOnOrAfter := e.attr("NotOnOrAfter"); notOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339Nano, notOnOrAfter)
+		if err != nil {
+			return fmt.Errorf("invalid NotOnOrAfter: %w", err)
+		}
+		if !now.Add(-maxClockSkew).Before(t) {
+			return errors.New("assertion has expired")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		path     []string
+		prefixes []string
+		expected string
+	}{
+		{
+			name:     "unused namespaces are dropped",
+			input:    `<root xmlns="urn:a" xmlns:b="urn:b" xmlns:unused="urn:u"><b:child b:at="y" attr="x">t&amp;&lt;&gt;"</b:child><empty/></root>`,
+			expected: `<root xmlns="urn:a"><b:child xmlns:b="urn:b" attr="x" b:at="y">t&amp;&lt;&gt;"</b:child><empty></empty></root>`,
+		},
+		{
+			name:     "subset renders the namespaces in scope",
+			input:    `<root xmlns="urn:a" xmlns:b="urn:b"><b:child attr="x"><b:leaf/></b:child></root>`,
+			path:     []string{"child"},
+			expected: `<b:child xmlns:b="urn:b" attr="x"><b:leaf></b:leaf></b:child>`,
+		},
+		{
+			name:     "default namespace undeclaration",
+			input:    `<a xmlns="urn:a"><b xmlns=""><c/></b></a>`,
+			expected: `<a xmlns="urn:a"><b xmlns=""><c></c></b></a>`,
+		},
+		{
+			name:     "empty default namespace of a subset",
+			input:    `<a xmlns="urn:a"><b xmlns=""><c/></b></a>`,
+			path:     []string{"b"},
+			expected: `<b><c></c></b>`,
+		},
+		{
+			name:     "comments and declarations are dropped",
+			input:    "<?xml version=\"1.0\"?>\n<a><!-- comment -->x<![CDATA[<y>]]></a>",
+			expected: `<a>x&lt;y&gt;</a>`,
+		},
+		{
+			name:     "attribute escaping",
+			input:    `<a b="&quot;&#9;&#10;&amp;&lt;>"></a>`,
+			expected: `<a b="&quot;&#x9;&#xA;&amp;&lt;>"></a>`,
+		},
+		{
+			name:     "inclusive prefixes",
+			input:    `<r xmlns:xs="urn:xs" xmlns:xsi="urn:xsi"><v xsi:type="xs:string">s</v></r>`,
+			path:     []string{"v"},
+			prefixes: []string{"xs"},
+			expected: `<v xmlns:xs="urn:xs" xmlns:xsi="urn:xsi" xsi:type="xs:string">s</v>`,
+		},
+		{
+			name:     "attributes are sorted by namespace",
+			input:    `<a xmlns:z="urn:1" xmlns:y="urn:2" y:b="1" z:c="2" d="3"></a>`,
+			expected: `<a xmlns:y="urn:2" xmlns:z="urn:1" d="3" z:c="2" y:b="1"></a>`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := parseXML([]byte(c.input))
+			require.NoError(t, err)
+			for _, local := range c.path {
+				for _, child := range e.Children {
+					if child, ok := child.(*element); ok && child.Local == local {
+						e = child
+						break
+					}
+				}
+				require.Equal(t, local, e.Local)
+			}
+			assert.Equal(t, c.expected, string(canonicalize(e, nil, c.prefixes)))
+		})
+	}
+}
+
+func TestParseXMLRefusesDoctype(t *testing.T) {
+	_, err := parseXML([]byte(`<!DOCTYPE a [<!ENTITY b "c">]><a>&b;</a>`))
+	require.Error(t, err)
+}
+
+type testIdentityProvider struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestIdentityProvider(t *testing.T) *testIdentityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testIdentityProvider{key: key, cert: cert}
+}
+
+func (idp *testIdentityProvider) metadata() string {
+	return fmt.Sprintf(`<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata">
+  <md:EntityDescriptor entityID="https://sp.example.com/other">
+    <md:SPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol"/>
+  </md:EntityDescriptor>
+  <md:EntityDescriptor entityID="https://idp.example.com/metadata">
+    <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+      <md:KeyDescriptor use="encryption">
+        <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>invalid</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+      </md:KeyDescriptor>
+      <md:KeyDescriptor use="signing">
+        <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+%s
+        </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+      </md:KeyDescriptor>
+      <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+      <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso?tenant=1"/>
+    </md:IDPSSODescriptor>
+  </md:EntityDescriptor>
+</md:EntitiesDescriptor>`, base64.StdEncoding.EncodeToString(idp.cert.Raw))
+}
+
+// sign inserts an enveloped signature of the element with the given ID in place of the <!--sig:ID--> comment
+func (idp *testIdentityProvider) sign(t *testing.T, doc, id string, prefixes ...string) string {
+	root, err := parseXML([]byte(doc))
+	require.NoError(t, err)
+	e := findByID(root, id)
+	require.NotNil(t, e)
+
+	digest := sha256.Sum256(canonicalize(e, nil, prefixes))
+	inclusive := ""
+	if len(prefixes) > 0 {
+		inclusive = fmt.Sprintf(`<ec:InclusiveNamespaces xmlns:ec="%s" PrefixList="%s"/>`, algExcC14N, strings.Join(prefixes, " "))
+	}
+	signature := fmt.Sprintf(`<ds:Signature xmlns:ds="%s"><ds:SignedInfo><ds:CanonicalizationMethod Algorithm="%s"/><ds:SignatureMethod Algorithm="%s"/>`+
+		`<ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s">%s</ds:Transform></ds:Transforms>`+
+		`<ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference></ds:SignedInfo>`+
+		`<ds:SignatureValue></ds:SignatureValue><ds:KeyInfo><ds:X509Data><ds:X509Certificate>ignored</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`,
+		nsDSig, algExcC14N, algRSASHA256, id, algEnveloped, algExcC14N, inclusive, algSHA256, base64.StdEncoding.EncodeToString(digest[:]))
+	slot := "<!--sig:" + id + "-->"
+	require.Contains(t, doc, slot)
+	doc = strings.Replace(doc, slot, signature, 1)
+
+	root, err = parseXML([]byte(doc))
+	require.NoError(t, err)
+	signedInfo, err := findByID(root, id).children(nsDSig, "Signature")[0].child(nsDSig, "SignedInfo")
+	require.NoError(t, err)
+	hashed := sha256.Sum256(canonicalize(signedInfo, nil, nil))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+	return strings.Replace(doc, "<ds:SignatureValue></ds:SignatureValue>", "<ds:SignatureValue>"+base64.StdEncoding.EncodeToString(sig)+"</ds:SignatureValue>", 1)
+}
+
+func findByID(e *element, id string) *element {
+	if e.attr("ID") == id {
+		return e
+	}
+	for _, c := range e.Children {
+		if c, ok := c.(*element); ok {
+			if found := findByID(c, id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+type responseOptions struct {
+	requestID string
+	recipient string
+	audience  string
+	issuer    string
+	nameID    string
+	notAfter  time.Time
+	status    string
+}
+
+func testResponse(opts responseOptions) string {
+	return fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:xs="http://www.w3.org/2001/XMLSchema" ID="response" Version="2.0" Destination="https://sp.example.com/acs" InResponseTo="%[1]s">
+  <saml:Issuer>%[4]s</saml:Issuer><!--sig:response-->
+  <samlp:Status><samlp:StatusCode Value="%[7]s"/></samlp:Status>
+  <saml:Assertion ID="assertion" Version="2.0">
+    <saml:Issuer>%[4]s</saml:Issuer><!--sig:assertion-->
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified">%[5]s</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData InResponseTo="%[1]s" Recipient="%[2]s" NotOnOrAfter="%[6]s"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="%[8]s" NotOnOrAfter="%[6]s">
+      <saml:AudienceRestriction><saml:Audience>%[3]s</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="urn:oid:0.9.2342.19200300.100.1.3" FriendlyName="mail"><saml:AttributeValue xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">jane@example.com</saml:AttributeValue></saml:Attribute>
+      <saml:Attribute Name="groups"><saml:AttributeValue>developers</saml:AttributeValue><saml:AttributeValue>admins</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`, opts.requestID, opts.recipient, opts.audience, opts.issuer, opts.nameID,
+		opts.notAfter.UTC().Format(time.RFC3339), opts.status, opts.notAfter.Add(-10*time.Minute).UTC().Format(time.RFC3339))
+}
+
+func defaultResponseOptions() responseOptions {
+	return responseOptions{
+		requestID: "id-request",
+		recipient: "https://sp.example.com/acs",
+		audience:  "https://sp.example.com/metadata",
+		issuer:    "https://idp.example.com/metadata",
+		nameID:    "jane",
+		notAfter:  time.Now().Add(5 * time.Minute),
+		status:    statusSuccess,
+	}
+}
+
+func newTestServiceProvider(t *testing.T, idp *testIdentityProvider) *ServiceProvider {
+	metadata, err := ParseIdentityProviderMetadata([]byte(idp.metadata()))
+	require.NoError(t, err)
+	return &ServiceProvider{
+		EntityID: "https://sp.example.com/metadata",
+		AcsURL:   "https://sp.example.com/acs",
+		IDP:      metadata,
+	}
+}
+
+func encode(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestParseIdentityProviderMetadata(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	metadata, err := ParseIdentityProviderMetadata([]byte(idp.metadata()))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com/metadata", metadata.EntityID)
+	assert.Equal(t, "https://idp.example.com/sso?tenant=1", metadata.SSOURL)
+	require.Len(t, metadata.Certificates, 1)
+	assert.True(t, metadata.Certificates[0].Equal(idp.cert))
+
+	_, err = ParseIdentityProviderMetadata([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="sp"><md:SPSSODescriptor/></md:EntityDescriptor>`))
+	require.Error(t, err)
+}
+
+func TestServiceProviderMetadata(t *testing.T) {
+	sp := newTestServiceProvider(t, newTestIdentityProvider(t))
+	metadata, err := sp.Metadata()
+	require.NoError(t, err)
+	root, err := parseXML(metadata)
+	require.NoError(t, err)
+	assert.Equal(t, nsMetadata, root.Space)
+	assert.Equal(t, sp.EntityID, root.attr("entityID"))
+	descriptor, err := root.child(nsMetadata, "SPSSODescriptor")
+	require.NoError(t, err)
+	assert.Equal(t, "true", descriptor.attr("WantAssertionsSigned"))
+	acs, err := descriptor.child(nsMetadata, "AssertionConsumerService")
+	require.NoError(t, err)
+	assert.Equal(t, bindingHTTPPost, acs.attr("Binding"))
+	assert.Equal(t, sp.AcsURL, acs.attr("Location"))
+}
+
+func TestAuthnRequestURL(t *testing.T) {
+	sp := newTestServiceProvider(t, newTestIdentityProvider(t))
+	redirect, id, err := sp.AuthnRequestURL("state")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "id-"))
+
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", u.Host)
+	assert.Equal(t, "1", u.Query().Get("tenant"))
+	assert.Equal(t, "state", u.Query().Get("RelayState"))
+
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(t, err)
+	request, err := parseXML(inflated)
+	require.NoError(t, err)
+	assert.Equal(t, nsProtocol, request.Space)
+	assert.Equal(t, "AuthnRequest", request.Local)
+	assert.Equal(t, id, request.attr("ID"))
+	assert.Equal(t, sp.AcsURL, request.attr("AssertionConsumerServiceURL"))
+	issuer, err := request.child(nsAssertion, "Issuer")
+	require.NoError(t, err)
+	assert.Equal(t, sp.EntityID, issuer.text())
+}
+
+func TestParseResponse(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	sp := newTestServiceProvider(t, idp)
+
+	t.Run("SignedAssertion", func(t *testing.T) {
+		doc := idp.sign(t, testResponse(defaultResponseOptions()), "assertion")
+		a, err := sp.ParseResponse(encode(doc), "id-request")
+		require.NoError(t, err)
+		assert.Equal(t, "jane", a.NameID)
+		assert.Equal(t, "jane@example.com", a.Attribute("mail"))
+		assert.Equal(t, "jane@example.com", a.Attribute("urn:oid:0.9.2342.19200300.100.1.3"))
+		assert.Equal(t, []string{"developers", "admins"}, a.Attributes["groups"])
+	})
+
+	t.Run("SignedResponse", func(t *testing.T) {
+		doc := idp.sign(t, testResponse(defaultResponseOptions()), "response")
+		a, err := sp.ParseResponse(encode(doc), "id-request")
+		require.NoError(t, err)
+		assert.Equal(t, "jane", a.NameID)
+	})
+
+	t.Run("SignedBoth", func(t *testing.T) {
+		doc := idp.sign(t, testResponse(defaultResponseOptions()), "assertion", "xs")
+		doc = idp.sign(t, doc, "response")
+		_, err := sp.ParseResponse(encode(doc), "id-request")
+		require.NoError(t, err)
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		_, err := sp.ParseResponse(encode(testResponse(defaultResponseOptions())), "id-request")
+		require.ErrorContains(t, err, "neither the response nor the assertion is signed")
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		doc := idp.sign(t, testResponse(defaultResponseOptions()), "assertion")
+		doc = strings.Replace(doc, ">jane<", ">root<", 1)
+		_, err := sp.ParseResponse(encode(doc), "id-request")
+		require.ErrorContains(t, err, "digest")
+	})
+
+	t.Run("OtherKey", func(t *testing.T) {
+		doc := newTestIdentityProvider(t).sign(t, testResponse(defaultResponseOptions()), "assertion")
+		_, err := sp.ParseResponse(encode(doc), "id-request")
+		require.ErrorContains(t, err, "certificate")
+	})
+
+	t.Run("CommentInNameID", func(t *testing.T) {
+		opts := defaultResponseOptions()
+		opts.nameID = "jane<!---->.doe"
+		doc := idp.sign(t, testResponse(opts), "assertion")
+		a, err := sp.ParseResponse(encode(doc), "id-request")
+		require.NoError(t, err)
+		assert.Equal(t, "jane.doe", a.NameID)
+	})
+
+	t.Run("Wrapped", func(t *testing.T) {
+		// a forged assertion in place of the signed one, which is hidden in the extensions
+		signed := idp.sign(t, testResponse(defaultResponseOptions()), "assertion")
+		start := strings.Index(signed, "<saml:Assertion")
+		end := strings.Index(signed, "</saml:Assertion>") + len("</saml:Assertion>")
+		original := signed[start:end]
+		forged := strings.Replace(strings.Replace(original, ">jane<", ">root<", 1), `ID="assertion"`, `ID="forged"`, 1)
+		doc := signed[:start] + "<samlp:Extensions>" + original + "</samlp:Extensions>" + forged + signed[end:]
+		_, err := sp.ParseResponse(encode(doc), "id-request")
+		require.Error(t, err)
+
+		// or both assertions side by side
+		doc = signed[:start] + original + forged + signed[end:]
+		_, err = sp.ParseResponse(encode(doc), "id-request")
+		require.ErrorContains(t, err, "more than one Assertion")
+	})
+
+	for name, mutate := range map[string]func(*responseOptions){
+		"Expired":        func(o *responseOptions) { o.notAfter = time.Now().Add(-10 * time.Minute) },
+		"OtherAudience":  func(o *responseOptions) { o.audience = "https://other.example.com" },
+		"OtherRecipient": func(o *responseOptions) { o.recipient = "https://other.example.com/acs" },
+		"OtherRequest":   func(o *responseOptions) { o.requestID = "id-other" },
+		"OtherIssuer":    func(o *responseOptions) { o.issuer = "https://evil.example.com" },
+		"Failed":         func(o *responseOptions) { o.status = "urn:oasis:names:tc:SAML:2.0:status:Requester" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts := defaultResponseOptions()
+			mutate(&opts)
+			doc := idp.sign(t, testResponse(opts), "assertion")
+			_, err := sp.ParseResponse(encode(doc), "id-request")
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("NoPendingRequest", func(t *testing.T) {
+		doc := idp.sign(t, testResponse(defaultResponseOptions()), "assertion")
+		_, err := sp.ParseResponse(encode(doc), "")
+		require.Error(t, err)
+	})
+
+	t.Run("EncryptedAssertion", func(t *testing.T) {
+		doc := strings.Replace(testResponse(defaultResponseOptions()), "<samlp:Status>", "<saml:EncryptedAssertion/><samlp:Status>", 1)
+		_, err := sp.ParseResponse(encode(doc), "id-request")
+		require.ErrorContains(t, err, "encrypted assertions are not supported")
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// register the hashes used by the supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	nsDSig = "http://www.w3.org/2000/09/xmldsig#"
+
+	algExcC14N     = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnveloped   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algSHA256      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algSHA512      = "http://www.w3.org/2001/04/xmlenc#sha512"
+	algRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRSASHA512   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	algECDSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	algECDSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512"
+)
+
+var digestAlgorithms = map[string]crypto.Hash{
+	algSHA256: crypto.SHA256,
+	algSHA512: crypto.SHA512,
+}
+
+var signatureAlgorithms = map[string]crypto.Hash{
+	algRSASHA256:   crypto.SHA256,
+	algRSASHA512:   crypto.SHA512,
+	algECDSASHA256: crypto.SHA256,
+	algECDSASHA512: crypto.SHA512,
+}
+
+// errNotSigned is returned when an element has no signature
+var errNotSigned = errors.New("element is not signed")
+
+// verifyEnvelopedSignature checks the signature that is a direct child of the element.
+// The signature must reference the element itself by its ID and be made with the key of one of the certificates,
+// the KeyInfo of the signature is ignored. Only what this element contains is covered by the signature,
+// so callers must read the signed data from this element and nowhere else.
+func verifyEnvelopedSignature(e *element, certs []*x509.Certificate) error {
+	signatures := e.children(nsDSig, "Signature")
+	switch len(signatures) {
+	case 0:
+		return errNotSigned
+	case 1:
+	default:
+		return errors.New("more than one signature")
+	}
+	signature := signatures[0]
+
+	id := e.attr("ID")
+	if id == "" {
+		return errors.New("signed element has no ID")
+	}
+
+	signedInfo, err := signature.child(nsDSig, "SignedInfo")
+	if err != nil {
+		return err
+	}
+	c14nMethod, err := signedInfo.child(nsDSig, "CanonicalizationMethod")
+	if err != nil {
+		return err
+	}
+	if alg := c14nMethod.attr("Algorithm"); alg != algExcC14N {
+		return fmt.Errorf("unsupported canonicalization %q", alg)
+	}
+	signatureMethod, err := signedInfo.child(nsDSig, "SignatureMethod")
+	if err != nil {
+		return err
+	}
+	signatureAlg := signatureMethod.attr("Algorithm")
+	signatureHash, ok := signatureAlgorithms[signatureAlg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %q", signatureAlg)
+	}
+
+	reference, err := signedInfo.child(nsDSig, "Reference")
+	if err != nil {
+		return err
+	}
+	if reference.attr("URI") != "#"+id {
+		return errors.New("signature does not reference the signed element")
+	}
+	prefixes, err := referenceTransforms(reference)
+	if err != nil {
+		return err
+	}
+	digestMethod, err := reference.child(nsDSig, "DigestMethod")
+	if err != nil {
+		return err
+	}
+	digestHash, ok := digestAlgorithms[digestMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("unsupported digest algorithm %q", digestMethod.attr("Algorithm"))
+	}
+	digestValue, err := reference.child(nsDSig, "DigestValue")
+	if err != nil {
+		return err
+	}
+	expectedDigest, err := decodeBase64(digestValue.text())
+	if err != nil {
+		return fmt.Errorf("invalid digest value: %w", err)
+	}
+	h := digestHash.New()
+	h.Write(canonicalize(e, signature, prefixes))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expectedDigest) != 1 {
+		return errors.New("digest of the signed element does not match")
+	}
+
+	signatureValue, err := signature.child(nsDSig, "SignatureValue")
+	if err != nil {
+		return err
+	}
+	sig, err := decodeBase64(signatureValue.text())
+	if err != nil {
+		return fmt.Errorf("invalid signature value: %w", err)
+	}
+	h = signatureHash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	hashed := h.Sum(nil)
+	for _, cert := range certs {
+		if verifySignature(cert, signatureHash, hashed, sig) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match any certificate of the identity provider")
+}
+
+// referenceTransforms checks that the transforms are those of an enveloped signature
+// and returns the inclusive prefixes of the canonicalization
+func referenceTransforms(reference *element) ([]string, error) {
+	transforms, err := reference.child(nsDSig, "Transforms")
+	if err != nil {
+		return nil, err
+	}
+	var enveloped, c14n bool
+	var prefixes []string
+	for _, t := range transforms.children(nsDSig, "Transform") {
+		switch alg := t.attr("Algorithm"); alg {
+		case algEnveloped:
+			enveloped = true
+		case algExcC14N:
+			c14n = true
+			prefixes = inclusivePrefixes(t)
+		default:
+			return nil, fmt.Errorf("unsupported transform %q", alg)
+		}
+	}
+	if !enveloped || !c14n {
+		return nil, errors.New("signature is not an enveloped signature with exclusive canonicalization")
+	}
+	return prefixes, nil
+}
+
+// inclusivePrefixes returns the PrefixList of the InclusiveNamespaces parameter of a canonicalization
+func inclusivePrefixes(method *element) []string {
+	for _, c := range method.children(algExcC14N, "InclusiveNamespaces") {
+		return strings.Fields(c.attr("PrefixList"))
+	}
+	return nil
+}
+
+func verifySignature(cert *x509.Certificate, hash crypto.Hash, hashed, sig []byte) bool {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, hash, hashed, sig) == nil
+	case *ecdsa.PublicKey:
+		// XML signatures hold the concatenation of r and s
+		if len(sig) == 0 || len(sig)%2 != 0 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		return ecdsa.Verify(pub, hashed, r, s)
+	}
+	return false
+}
+
+// decodeBase64 decodes base64 that may be wrapped on several lines
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	nsXML       = "http://www.w3.org/XML/1998/namespace"
+	maxXMLDepth = 64
+)
+
+// element is a node of a parsed XML document. Unlike encoding/xml it keeps the
+// namespace prefixes, which are needed to canonicalize signed elements.
+type element struct {
+	Prefix string
+	Local  string
+	// Space is the resolved namespace of the element
+	Space    string
+	Attrs    []attr
+	Children []any // *element or string
+	// nsDecls are the namespaces declared on this element, the default namespace has the empty prefix
+	nsDecls map[string]string
+	parent  *element
+}
+
+type attr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+// parseXML parses a document into a tree of elements.
+// Comments and processing instructions are dropped, DTDs are refused.
+func parseXML(data []byte) (*element, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var root, cur *element
+	depth := 0
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if cur == nil && root != nil {
+				return nil, errors.New("more than one root element")
+			}
+			depth++
+			if depth > maxXMLDepth {
+				return nil, errors.New("document is nested too deeply")
+			}
+			e, err := newElement(t, cur)
+			if err != nil {
+				return nil, err
+			}
+			if cur == nil {
+				root = e
+			} else {
+				cur.Children = append(cur.Children, e)
+			}
+			cur = e
+		case xml.EndElement:
+			if cur == nil || cur.Prefix != t.Name.Space || cur.Local != t.Name.Local {
+				return nil, fmt.Errorf("unexpected end element %s", t.Name.Local)
+			}
+			depth--
+			cur = cur.parent
+		case xml.CharData:
+			if cur == nil {
+				if len(bytes.TrimSpace(t)) != 0 {
+					return nil, errors.New("text outside of the root element")
+				}
+				continue
+			}
+			cur.Children = append(cur.Children, string(t))
+		case xml.Directive:
+			return nil, errors.New("document type definitions are not allowed")
+		}
+	}
+	if root == nil {
+		return nil, errors.New("empty document")
+	}
+	if cur != nil {
+		return nil, errors.New("unexpected end of document")
+	}
+	return root, nil
+}
+
+func newElement(t xml.StartElement, parent *element) (*element, error) {
+	e := &element{
+		Prefix:  t.Name.Space,
+		Local:   t.Name.Local,
+		nsDecls: map[string]string{},
+		parent:  parent,
+	}
+	for _, a := range t.Attr {
+		switch {
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			e.nsDecls[""] = a.Value
+		case a.Name.Space == "xmlns":
+			e.nsDecls[a.Name.Local] = a.Value
+		default:
+			e.Attrs = append(e.Attrs, attr{Prefix: a.Name.Space, Local: a.Name.Local, Value: a.Value})
+		}
+	}
+
+	space, ok := e.lookupNamespace(e.Prefix)
+	if !ok && e.Prefix != "" {
+		return nil, fmt.Errorf("undeclared namespace prefix %q", e.Prefix)
+	}
+	e.Space = space
+	for i := range e.Attrs {
+		if e.Attrs[i].Prefix == "" {
+			continue
+		}
+		space, ok := e.lookupNamespace(e.Attrs[i].Prefix)
+		if !ok {
+			return nil, fmt.Errorf("undeclared namespace prefix %q", e.Attrs[i].Prefix)
+		}
+		e.Attrs[i].Space = space
+	}
+	return e, nil
+}
+
+// lookupNamespace returns the namespace bound to a prefix in the scope of the element
+func (e *element) lookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return nsXML, true
+	}
+	for n := e; n != nil; n = n.parent {
+		if space, ok := n.nsDecls[prefix]; ok {
+			return space, true
+		}
+	}
+	return "", false
+}
+
+// attr returns the value of an attribute without namespace
+func (e *element) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Space == "" && a.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// children returns the child elements with the given namespace and name
+func (e *element) children(space, local string) []*element {
+	var found []*element
+	for _, c := range e.Children {
+		if c, ok := c.(*element); ok && c.Space == space && c.Local == local {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// child returns the only child element with the given namespace and name
+func (e *element) child(space, local string) (*element, error) {
+	found := e.children(space, local)
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("missing %s element in %s", local, e.Local)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("more than one %s element in %s", local, e.Local)
+	}
+}
+
+// text returns all the text of the element. The text of every child node is
+// joined, so a comment cannot be used to truncate a signed value.
+func (e *element) text() string {
+	var sb strings.Builder
+	for _, c := range e.Children {
+		switch c := c.(type) {
+		case string:
+			sb.WriteString(c)
+		case *element:
+			sb.WriteString(c.text())
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
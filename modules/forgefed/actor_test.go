@@ -106,7 +106,7 @@ func TestPersonIdValidation(t *testing.T) {
 	sut.Host = "an.other.host"
 	sut.Port = ""
 	sut.UnvalidatedInput = "https://an.other.host/api/v1/activitypub/user-id/1"
-	if sut.Validate()[0] != "Value forgejox is not contained in allowed values [forgejo gitea]" {
+	if sut.Validate()[0] != "Value forgejox is not contained in allowed values [forgejo gitea mastodon]" {
 		t.Errorf("validation error expected but was: %v\n", sut.Validate()[0])
 	}
 }
@@ -123,6 +123,21 @@ func TestWebfingerId(t *testing.T) {
 	}
 }
 
+func TestMastodonPersonId(t *testing.T) {
+	sut, err := NewPersonID("https://mastodon.social/users/alice", "mastodon")
+	if err != nil {
+		t.Errorf("mastodon person uris should be valid: %v", err)
+	}
+	if sut.ID != "alice" || sut.Path != "users" {
+		t.Errorf("wrong person id: %v", sut)
+	}
+
+	_, err = NewPersonID("https://mastodon.social/api/v1/activitypub/user-id/1", "mastodon")
+	if err == nil {
+		t.Errorf("forgejo paths are not mastodon person uris")
+	}
+}
+
 func TestShouldThrowErrorOnInvalidInput(t *testing.T) {
 	var err any
 	// TODO: remove after test
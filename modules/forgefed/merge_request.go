@@ -0,0 +1,187 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgefed
+
+import (
+	"strings"
+
+	"code.gitea.io/gitea/modules/validation"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/valyala/fastjson"
+)
+
+const (
+	BranchType ap.ActivityVocabularyType = "Branch"
+
+	branchRefPrefix = "refs/heads/"
+)
+
+// ForgeBranch is a branch of the repository given as its context
+type ForgeBranch struct {
+	ap.Object
+	// Ref is the full git reference of the branch
+	Ref string `jsonld:"ref"`
+}
+
+func NewForgeBranch(repositoryIRI, name string) ForgeBranch {
+	branch := ForgeBranch{Object: *ap.ObjectNew(BranchType), Ref: branchRefPrefix + name}
+	branch.Context = ap.IRI(repositoryIRI)
+	branch.Name = ap.DefaultNaturalLanguageValue(name)
+	return branch
+}
+
+// BranchName returns the name of the branch without the refs/heads/ prefix
+func (branch ForgeBranch) BranchName() string {
+	return strings.TrimPrefix(branch.Ref, branchRefPrefix)
+}
+
+func (branch ForgeBranch) MarshalJSON() ([]byte, error) {
+	b, err := branch.Object.MarshalJSON()
+	if len(b) == 0 || err != nil {
+		return nil, err
+	}
+
+	b = b[:len(b)-1]
+	ap.JSONWriteStringProp(&b, "ref", branch.Ref)
+	ap.JSONWrite(&b, '}')
+	return b, nil
+}
+
+func loadForgeBranch(val *fastjson.Value) (ForgeBranch, error) {
+	branch := ForgeBranch{}
+	if val == nil {
+		return branch, nil
+	}
+	if err := ap.JSONLoadObject(val, &branch.Object); err != nil {
+		return branch, err
+	}
+	branch.Ref = string(val.GetStringBytes("ref"))
+	return branch, nil
+}
+
+func (branch ForgeBranch) Validate() []string {
+	var result []string
+	result = append(result, validation.ValidateOneOf(string(branch.Type), []any{string(BranchType)}, "type")...)
+	if branch.Context == nil {
+		result = append(result, "Branch context should not be nil.")
+	}
+	if !strings.HasPrefix(branch.Ref, branchRefPrefix) {
+		result = append(result, "Branch ref has to start with "+branchRefPrefix)
+	}
+	result = append(result, validation.ValidateNotEmpty(branch.BranchName(), "ref")...)
+	return result
+}
+
+// ForgeMergeRequest is a Ticket asking to merge the origin branch into the target branch.
+// The branches are attached to the ticket as an Offer.
+type ForgeMergeRequest struct {
+	ForgeTicket
+	Origin ForgeBranch
+	Target ForgeBranch
+}
+
+func (mr ForgeMergeRequest) MarshalJSON() ([]byte, error) {
+	object := mr.Object
+	attachment := ap.ActivityNew("", ap.OfferType, nil)
+	attachment.Origin = &mr.Origin
+	attachment.Target = &mr.Target
+	object.Attachment = attachment
+	return object.MarshalJSON()
+}
+
+func (mr ForgeMergeRequest) Validate() []string {
+	result := mr.ForgeTicket.Validate()
+	result = append(result, mr.Origin.Validate()...)
+	result = append(result, mr.Target.Validate()...)
+	if mr.Context != nil && mr.Target.Context != nil && mr.Context.GetLink() != mr.Target.Context.GetLink() {
+		result = append(result, "Target branch has to be in the repository of the ticket.")
+	}
+	return result
+}
+
+// ForgeOffer activity offers a merge request to a repository
+type ForgeOffer struct {
+	ap.Activity
+}
+
+func NewForgeOffer(actorIRI string, mr ForgeMergeRequest) (ForgeOffer, error) {
+	result := ForgeOffer{}
+	result.Type = ap.OfferType
+	result.Actor = ap.IRI(actorIRI)
+	result.Object = &mr
+	result.Target = mr.Context
+	if valid, err := validation.IsValid(result); !valid {
+		return ForgeOffer{}, err
+	}
+	return result, nil
+}
+
+func (offer ForgeOffer) MarshalJSON() ([]byte, error) {
+	return offer.Activity.MarshalJSON()
+}
+
+// UnmarshalJSON loads the activity, merge requests are not known to the activitypub package and are loaded by hand
+func (offer *ForgeOffer) UnmarshalJSON(data []byte) error {
+	p := fastjson.Parser{}
+	val, err := p.ParseBytes(data)
+	if err != nil {
+		return err
+	}
+	if err := ap.JSONLoadActivity(val, &offer.Activity); err != nil {
+		return err
+	}
+
+	object := val.Get("object")
+	if object == nil || ap.JSONGetType(object) != TicketType {
+		return nil
+	}
+	mr := &ForgeMergeRequest{}
+	if err := ap.JSONLoadObject(object, &mr.Object); err != nil {
+		return err
+	}
+	mr.Attachment = nil
+	if attachment := object.Get("attachment"); attachment != nil && ap.JSONGetType(attachment) == ap.OfferType {
+		if mr.Origin, err = loadForgeBranch(attachment.Get("origin")); err != nil {
+			return err
+		}
+		if mr.Target, err = loadForgeBranch(attachment.Get("target")); err != nil {
+			return err
+		}
+	}
+	offer.Object = mr
+	return nil
+}
+
+// MergeRequest returns the offered merge request, nil if something else was offered
+func (offer ForgeOffer) MergeRequest() *ForgeMergeRequest {
+	mr, _ := offer.Object.(*ForgeMergeRequest)
+	return mr
+}
+
+func (offer ForgeOffer) Validate() []string {
+	var result []string
+	result = append(result, validation.ValidateOneOf(string(offer.Type), []any{string(ap.OfferType)}, "type")...)
+	if offer.Actor == nil {
+		result = append(result, "Actor should not be nil.")
+	} else {
+		result = append(result, validation.ValidateNotEmpty(offer.Actor.GetID().String(), "actor")...)
+	}
+	if offer.Target == nil {
+		result = append(result, "Target should not be nil.")
+	}
+
+	mr := offer.MergeRequest()
+	if mr == nil {
+		return append(result, "Object has to be a merge request Ticket.")
+	}
+	result = append(result, mr.Validate()...)
+	if mr.AttributedTo != nil && offer.Actor != nil && mr.AttributedTo.GetLink() != offer.Actor.GetLink() {
+		result = append(result, "Ticket has to be attributed to the actor.")
+	}
+	if offer.Target != nil && mr.Context != nil && offer.Target.GetLink() != mr.Context.GetLink() {
+		result = append(result, "Ticket has to be offered to its repository.")
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgefed
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/modules/validation"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMergeRequest() ForgeMergeRequest {
+	mr := ForgeMergeRequest{
+		ForgeTicket: ForgeTicket{Object: *ap.ObjectNew(TicketType)},
+		Origin:      NewForgeBranch("https://a.example/api/v1/activitypub/repository-id/2", "fix"),
+		Target:      NewForgeBranch("https://b.example/api/v1/activitypub/repository-id/1", "main"),
+	}
+	mr.ID = "https://a.example/api/v1/activitypub/repository-id/2/merge-requests/1"
+	mr.AttributedTo = ap.IRI("https://a.example/api/v1/activitypub/user-id/1")
+	mr.Context = ap.IRI("https://b.example/api/v1/activitypub/repository-id/1")
+	mr.Summary = ap.DefaultNaturalLanguageValue("Fix the frobnicator")
+	return mr
+}
+
+func Test_OfferMergeRequestMarshalJSON(t *testing.T) {
+	offer, err := NewForgeOffer("https://a.example/api/v1/activitypub/user-id/1", newTestMergeRequest())
+	require.NoError(t, err)
+
+	data, err := offer.MarshalJSON()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"ref":"refs/heads/fix"`)
+
+	got := ForgeOffer{}
+	require.NoError(t, got.UnmarshalJSON(data))
+	valid, err := validation.IsValid(got)
+	assert.True(t, valid, err)
+
+	mr := got.MergeRequest()
+	require.NotNil(t, mr)
+	assert.Equal(t, "Fix the frobnicator", mr.Title())
+	assert.Equal(t, "fix", mr.Origin.BranchName())
+	assert.Equal(t, "https://a.example/api/v1/activitypub/repository-id/2", mr.Origin.Context.GetLink().String())
+	assert.Equal(t, "main", mr.Target.BranchName())
+	assert.Equal(t, "https://b.example/api/v1/activitypub/repository-id/1", got.Target.GetLink().String())
+}
+
+func Test_OfferMergeRequestValidation(t *testing.T) {
+	actor := "https://a.example/api/v1/activitypub/user-id/1"
+
+	mr := newTestMergeRequest()
+	mr.Target = NewForgeBranch("https://c.example/api/v1/activitypub/repository-id/1", "main")
+	_, err := NewForgeOffer(actor, mr)
+	require.Error(t, err)
+
+	mr = newTestMergeRequest()
+	mr.Origin.Ref = "refs/tags/v1.0"
+	_, err = NewForgeOffer(actor, mr)
+	require.Error(t, err)
+
+	mr = newTestMergeRequest()
+	mr.AttributedTo = ap.IRI("https://a.example/api/v1/activitypub/user-id/2")
+	_, err = NewForgeOffer(actor, mr)
+	require.Error(t, err)
+
+	offer := ForgeOffer{}
+	require.NoError(t, offer.UnmarshalJSON([]byte(`{"type":"Offer","actor":"`+actor+`","object":{"type":"Note","content":"Hi"}}`)))
+	assert.Nil(t, offer.MergeRequest())
+	valid, _ := validation.IsValid(offer)
+	assert.False(t, valid)
+}
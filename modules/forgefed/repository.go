@@ -23,6 +23,8 @@ type Repository struct {
 	Forks ap.Item `jsonld:"forks,omitempty"`
 	// ForkedFrom Identifies the repository which this repository was created as a fork
 	ForkedFrom ap.Item `jsonld:"forkedFrom,omitempty"`
+	// CloneURI is the location the repository can be cloned from
+	CloneURI ap.Item `jsonld:"cloneUri,omitempty"`
 }
 
 // RepositoryNew initializes a Repository type actor
@@ -49,6 +51,9 @@ func (r Repository) MarshalJSON() ([]byte, error) {
 	if r.ForkedFrom != nil {
 		ap.JSONWriteItemProp(&b, "forkedFrom", r.ForkedFrom)
 	}
+	if r.CloneURI != nil {
+		ap.JSONWriteItemProp(&b, "cloneUri", r.CloneURI)
+	}
 	ap.JSONWrite(&b, '}')
 	return b, nil
 }
@@ -63,6 +68,7 @@ func JSONLoadRepository(val *fastjson.Value, r *Repository) error {
 	r.Team = ap.JSONGetItem(val, "team")
 	r.Forks = ap.JSONGetItem(val, "forks")
 	r.ForkedFrom = ap.JSONGetItem(val, "forkedFrom")
+	r.CloneURI = ap.JSONGetItem(val, "cloneUri")
 	return nil
 }
 
@@ -76,6 +76,15 @@ func Test_RepositoryMarshalJSON(t *testing.T) {
 			},
 			want: []byte(`{"id":"https://example.com/1","team":[{"id":"https://example.com/1"},{"id":"https://example.com/2"}]}`),
 		},
+		"with CloneURI": {
+			item: Repository{
+				CloneURI: ap.IRI("https://example.com/owner/repo.git"),
+				Actor: ap.Actor{
+					ID: "https://example.com/1",
+				},
+			},
+			want: []byte(`{"id":"https://example.com/1","cloneUri":"https://example.com/owner/repo.git"}`),
+		},
 	}
 
 	for name, tt := range tests {
@@ -125,6 +134,16 @@ func Test_RepositoryUnmarshalJSON(t *testing.T) {
 				},
 			},
 		},
+		"with CloneURI": {
+			data: []byte(`{"id":"https://example.com/1","type":"Repository","cloneUri":"https://example.com/owner/repo.git"}`),
+			want: &Repository{
+				Actor: ap.Actor{
+					ID:   "https://example.com/1",
+					Type: RepositoryType,
+				},
+				CloneURI: ap.IRI("https://example.com/owner/repo.git"),
+			},
+		},
 	}
 
 	for name, tt := range tests {
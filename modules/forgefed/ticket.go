@@ -0,0 +1,143 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgefed
+
+import (
+	"strings"
+
+	"code.gitea.io/gitea/modules/validation"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/valyala/fastjson"
+)
+
+const (
+	TicketType  ap.ActivityVocabularyType = "Ticket"
+	ResolveType ap.ActivityVocabularyType = "Resolve"
+)
+
+// ForgeTicket is an issue of a repository
+type ForgeTicket struct {
+	ap.Object
+}
+
+// Title of the ticket, ForgeFed uses the summary for it
+func (ticket ForgeTicket) Title() string {
+	if title := ticket.Summary.String(); title != "" {
+		return title
+	}
+	return ticket.Name.String()
+}
+
+// Body returns the markdown source of an object if provided, otherwise its content
+func Body(object *ap.Object) string {
+	if len(object.Source.Content) > 0 && (object.Source.MediaType == "" || strings.HasPrefix(string(object.Source.MediaType), "text/markdown")) {
+		return object.Source.Content.String()
+	}
+	return object.Content.String()
+}
+
+func (ticket ForgeTicket) Validate() []string {
+	var result []string
+	result = append(result, validation.ValidateOneOf(string(ticket.Type), []any{string(TicketType)}, "type")...)
+	result = append(result, validation.ValidateNotEmpty(ticket.ID.String(), "id")...)
+	result = append(result, validation.ValidateNotEmpty(ticket.Title(), "summary")...)
+	if ticket.AttributedTo == nil {
+		result = append(result, "AttributedTo should not be nil.")
+	}
+	if ticket.Context == nil {
+		result = append(result, "Context should not be nil.")
+	}
+	return result
+}
+
+// ForgeCreate activity creates a Ticket, or a Note commenting on a Ticket
+type ForgeCreate struct {
+	ap.Activity
+}
+
+func NewForgeCreate(actorIRI string, object ap.Item) (ForgeCreate, error) {
+	result := ForgeCreate{}
+	result.Type = ap.CreateType
+	result.Actor = ap.IRI(actorIRI)
+	result.Object = object
+	if valid, err := validation.IsValid(result); !valid {
+		return ForgeCreate{}, err
+	}
+	return result, nil
+}
+
+func (create ForgeCreate) MarshalJSON() ([]byte, error) {
+	return create.Activity.MarshalJSON()
+}
+
+// UnmarshalJSON loads the activity, Tickets are not known to the activitypub package and are loaded as objects
+func (create *ForgeCreate) UnmarshalJSON(data []byte) error {
+	p := fastjson.Parser{}
+	val, err := p.ParseBytes(data)
+	if err != nil {
+		return err
+	}
+	if err := ap.JSONLoadActivity(val, &create.Activity); err != nil {
+		return err
+	}
+	if object := val.Get("object"); object != nil && ap.JSONGetType(object) == TicketType {
+		ticket := &ap.Object{}
+		if err := ap.JSONLoadObject(object, ticket); err != nil {
+			return err
+		}
+		create.Object = ticket
+	}
+	return nil
+}
+
+// Ticket returns the created Ticket, nil if another object was created
+func (create ForgeCreate) Ticket() *ForgeTicket {
+	object, err := ap.ToObject(create.Object)
+	if err != nil || object.Type != TicketType {
+		return nil
+	}
+	return &ForgeTicket{Object: *object}
+}
+
+// Note returns the created Note, nil if another object was created
+func (create ForgeCreate) Note() *ap.Object {
+	object, err := ap.ToObject(create.Object)
+	if err != nil || object.Type != ap.NoteType {
+		return nil
+	}
+	return object
+}
+
+func (create ForgeCreate) Validate() []string {
+	var result []string
+	result = append(result, validation.ValidateOneOf(string(create.Type), []any{string(ap.CreateType)}, "type")...)
+	if create.Actor == nil {
+		result = append(result, "Actor should not be nil.")
+	} else {
+		result = append(result, validation.ValidateNotEmpty(create.Actor.GetID().String(), "actor")...)
+	}
+
+	switch {
+	case create.Ticket() != nil:
+		ticket := create.Ticket()
+		result = append(result, ticket.Validate()...)
+		if ticket.AttributedTo != nil && create.Actor != nil && ticket.AttributedTo.GetLink() != create.Actor.GetLink() {
+			result = append(result, "Ticket has to be attributed to the actor.")
+		}
+	case create.Note() != nil:
+		note := create.Note()
+		result = append(result, validation.ValidateNotEmpty(note.ID.String(), "id")...)
+		result = append(result, validation.ValidateNotEmpty(Body(note), "content")...)
+		if note.InReplyTo == nil && note.Context == nil {
+			result = append(result, "Note has to reply to a Ticket.")
+		}
+		if note.AttributedTo == nil || create.Actor != nil && note.AttributedTo.GetLink() != create.Actor.GetLink() {
+			result = append(result, "Note has to be attributed to the actor.")
+		}
+	default:
+		result = append(result, "Object has to be a Ticket or a Note.")
+	}
+	return result
+}
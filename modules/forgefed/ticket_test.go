@@ -0,0 +1,56 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package forgefed
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/modules/validation"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CreateTicketUnmarshalJSON(t *testing.T) {
+	data := []byte(`{"type":"Create","actor":"https://a.example/api/v1/activitypub/user-id/1",` +
+		`"object":{"type":"Ticket","id":"https://a.example/tickets/1","attributedTo":"https://a.example/api/v1/activitypub/user-id/1",` +
+		`"context":"https://b.example/api/v1/activitypub/repository-id/1","summary":"Nothing happens",` +
+		`"content":"<p>Clicking does <em>nothing</em></p>","source":{"mediaType":"text/markdown","content":"Clicking does *nothing*"}}}`)
+
+	create := ForgeCreate{}
+	require.NoError(t, create.UnmarshalJSON(data))
+	valid, err := validation.IsValid(create)
+	assert.True(t, valid, err)
+
+	ticket := create.Ticket()
+	require.NotNil(t, ticket)
+	assert.Nil(t, create.Note())
+	assert.Equal(t, "https://a.example/tickets/1", ticket.ID.String())
+	assert.Equal(t, "Nothing happens", ticket.Title())
+	assert.Equal(t, "Clicking does *nothing*", Body(&ticket.Object))
+	assert.Equal(t, "https://b.example/api/v1/activitypub/repository-id/1", ticket.Context.GetLink().String())
+}
+
+func Test_CreateNoteValidation(t *testing.T) {
+	actor := "https://a.example/api/v1/activitypub/user-id/1"
+
+	note := ap.ObjectNew(ap.NoteType)
+	note.ID = "https://a.example/notes/1"
+	note.AttributedTo = ap.IRI(actor)
+	note.Content = ap.DefaultNaturalLanguageValue("I can reproduce it")
+	note.InReplyTo = ap.IRI("https://a.example/tickets/1")
+
+	create, err := NewForgeCreate(actor, note)
+	require.NoError(t, err)
+	assert.Nil(t, create.Ticket())
+	assert.Equal(t, "I can reproduce it", Body(create.Note()))
+
+	note.AttributedTo = ap.IRI("https://a.example/api/v1/activitypub/user-id/2")
+	_, err = NewForgeCreate(actor, note)
+	require.Error(t, err)
+
+	_, err = NewForgeCreate(actor, ap.ObjectNew(ap.ImageType))
+	require.Error(t, err)
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/util"
+)
+
+// ObjectStats are the object counts of a repository, as reported by `git count-objects -v`
+type ObjectStats struct {
+	LooseObjects int64
+	LooseSize    int64 // in bytes
+	InPack       int64
+	Packs        int64
+	PackSize     int64 // in bytes
+	Garbage      int64
+}
+
+// CountObjects measures the loose objects and the packs of a repository
+func CountObjects(ctx context.Context, repoPath string) (*ObjectStats, error) {
+	stdout, _, err := NewCommand(ctx, "count-objects", "-v").RunStdString(&RunOpts{Dir: repoPath})
+	if err != nil {
+		return nil, fmt.Errorf("unable to count objects of '%s': %w", repoPath, err)
+	}
+	return parseCountObjects(stdout)
+}
+
+func parseCountObjects(stdout string) (*ObjectStats, error) {
+	stats := &ObjectStats{}
+	scanner := bufio.NewScanner(strings.NewReader(stdout))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s in count-objects output: %w", key, err)
+		}
+		switch key {
+		case "count":
+			stats.LooseObjects = n
+		case "size":
+			stats.LooseSize = n * 1024
+		case "in-pack":
+			stats.InPack = n
+		case "packs":
+			stats.Packs = n
+		case "size-pack":
+			stats.PackSize = n * 1024
+		case "garbage":
+			stats.Garbage = n
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// HasCommitGraph returns whether the repository has a commit-graph, either as a single file or as a chain
+func HasCommitGraph(repoPath string) bool {
+	for _, name := range []string{"commit-graph", filepath.Join("commit-graphs", "commit-graph-chain")} {
+		if exist, _ := util.IsExist(filepath.Join(repoPath, "objects", "info", name)); exist {
+			return true
+		}
+	}
+	return false
+}
+
+// HasMultiPackIndex returns whether the packs of the repository are indexed by a multi-pack-index
+func HasMultiPackIndex(repoPath string) bool {
+	exist, _ := util.IsExist(filepath.Join(repoPath, "objects", "pack", "multi-pack-index"))
+	return exist
+}
+
+// RepackGeometric repacks the repository so that the sizes of the packs form a geometric progression,
+// only the smallest packs and the loose objects are rewritten. A multi-pack-index with a reachability bitmap
+// is written along the new packs when git supports it (v2.34), older versions write no bitmap.
+// this requires git v2.32 to be installed
+func RepackGeometric(ctx context.Context, repoPath string, timeout time.Duration) error {
+	if err := CheckGitVersionAtLeast("2.32"); err != nil {
+		return err
+	}
+	cmd := NewCommand(ctx, "repack", "-d", "-l", "--geometric=2")
+	if CheckGitVersionAtLeast("2.34") == nil {
+		cmd.AddArguments("--write-midx", "--write-bitmap-index")
+	}
+	cmd.SetDescription("Repository geometric repack: " + repoPath)
+	if _, stderr, err := cmd.RunStdString(&RunOpts{Dir: repoPath, Timeout: timeout}); err != nil {
+		return fmt.Errorf("unable to repack '%s': %w - %s", repoPath, err, stderr)
+	}
+	return nil
+}
+
+// WriteMultiPackIndex writes a multi-pack-index, with a reachability bitmap when git supports it
+// this requires git v2.21 to be installed
+func WriteMultiPackIndex(ctx context.Context, repoPath string, timeout time.Duration) error {
+	if err := CheckGitVersionAtLeast("2.21"); err != nil {
+		return err
+	}
+	cmd := NewCommand(ctx, "multi-pack-index", "write")
+	if CheckGitVersionAtLeast("2.34") == nil {
+		cmd.AddArguments("--bitmap")
+	}
+	if _, stderr, err := cmd.RunStdString(&RunOpts{Dir: repoPath, Timeout: timeout}); err != nil {
+		return fmt.Errorf("unable to write multi-pack-index for '%s': %w - %s", repoPath, err, stderr)
+	}
+	return nil
+}
+
+// PruneLooseObjects removes the unreachable loose objects older than expire, an approxidate like "2.weeks.ago"
+func PruneLooseObjects(ctx context.Context, repoPath, expire string, timeout time.Duration) error {
+	cmd := NewCommand(ctx, "prune").AddOptionFormat("--expire=%s", expire)
+	if _, stderr, err := cmd.RunStdString(&RunOpts{Dir: repoPath, Timeout: timeout}); err != nil {
+		return fmt.Errorf("unable to prune loose objects of '%s': %w - %s", repoPath, err, stderr)
+	}
+	return nil
+}
+
+// UploadPackFilters are the object filters which can be restricted per repository for partial clones
+var UploadPackFilters = []string{"blob:none", "blob:limit", "tree", "sparse:oid", "object:type", "combine"}
+
+// IsValidUploadPackFilter returns whether filter is one of the known object filters
+func IsValidUploadPackFilter(filter string) bool {
+	for _, f := range UploadPackFilters {
+		if f == filter {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUploadPackFilters restricts the object filters allowed for partial clones of the repository,
+// an empty list removes the restriction so that the instance configuration applies.
+// this requires git v2.29 to be installed
+func SetUploadPackFilters(ctx context.Context, repoPath string, filters []string) error {
+	for _, filter := range filters {
+		if !IsValidUploadPackFilter(filter) {
+			return fmt.Errorf("unknown object filter %q", filter)
+		}
+	}
+	if len(filters) > 0 {
+		if err := CheckGitVersionAtLeast("2.29"); err != nil {
+			return err
+		}
+	}
+
+	// the filters are subsections, so the keys are removed one by one rather than with the section
+	stdout, _, err := NewCommand(ctx, "config", "--local", "--name-only", "--get-regexp", `^uploadpackfilter\.`).RunStdString(&RunOpts{Dir: repoPath})
+	if err != nil && !IsErrorExitCode(err, 1) {
+		return fmt.Errorf("unable to get the allowed filters of '%s': %w", repoPath, err)
+	}
+	for _, key := range strings.Fields(stdout) {
+		if _, _, err := NewCommand(ctx, "config", "--local", "--unset-all").AddDynamicArguments(key).RunStdString(&RunOpts{Dir: repoPath}); err != nil {
+			return fmt.Errorf("unable to reset the allowed filters of '%s': %w", repoPath, err)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+
+	if _, _, err := NewCommand(ctx, "config", "--local", "uploadpackfilter.allow", "false").RunStdString(&RunOpts{Dir: repoPath}); err != nil {
+		return fmt.Errorf("unable to restrict the allowed filters of '%s': %w", repoPath, err)
+	}
+	for _, filter := range filters {
+		if _, _, err := NewCommand(ctx, "config", "--local").AddDynamicArguments("uploadpackfilter."+filter+".allow", "true").RunStdString(&RunOpts{Dir: repoPath}); err != nil {
+			return fmt.Errorf("unable to allow filter %s for '%s': %w", filter, repoPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCountObjects(t *testing.T) {
+	stats, err := parseCountObjects(`count: 12
+size: 48
+in-pack: 1024
+packs: 3
+size-pack: 2048
+prune-packable: 0
+garbage: 1
+size-garbage: 4
+`)
+	require.NoError(t, err)
+	assert.Equal(t, &ObjectStats{
+		LooseObjects: 12,
+		LooseSize:    48 * 1024,
+		InPack:       1024,
+		Packs:        3,
+		PackSize:     2048 * 1024,
+		Garbage:      1,
+	}, stats)
+
+	_, err = parseCountObjects("count: many\n")
+	require.Error(t, err)
+}
+
+func TestSetUploadPackFilters(t *testing.T) {
+	if CheckGitVersionAtLeast("2.29") != nil {
+		t.Skip("git too old")
+	}
+	tmpDir := t.TempDir()
+	require.NoError(t, InitRepository(DefaultContext, tmpDir, true, Sha1ObjectFormat.Name()))
+
+	getFilters := func() string {
+		stdout, _, err := NewCommand(DefaultContext, "config", "--local", "--get-regexp", `^uploadpackfilter\.`).RunStdString(&RunOpts{Dir: tmpDir})
+		if IsErrorExitCode(err, 1) {
+			return ""
+		}
+		require.NoError(t, err)
+		return stdout
+	}
+
+	require.NoError(t, SetUploadPackFilters(DefaultContext, tmpDir, []string{"blob:none", "tree"}))
+	assert.Equal(t, "uploadpackfilter.allow false\nuploadpackfilter.blob:none.allow true\nuploadpackfilter.tree.allow true\n", getFilters())
+
+	require.NoError(t, SetUploadPackFilters(DefaultContext, tmpDir, []string{"blob:limit"}))
+	assert.Equal(t, "uploadpackfilter.allow false\nuploadpackfilter.blob:limit.allow true\n", getFilters())
+
+	require.Error(t, SetUploadPackFilters(DefaultContext, tmpDir, []string{"unknown"}))
+	assert.Equal(t, "uploadpackfilter.allow false\nuploadpackfilter.blob:limit.allow true\n", getFilters())
+
+	require.NoError(t, SetUploadPackFilters(DefaultContext, tmpDir, nil))
+	assert.Empty(t, getFilters())
+}
+
+func TestCountObjects(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, InitRepository(DefaultContext, tmpDir, true, Sha1ObjectFormat.Name()))
+
+	stats, err := CountObjects(DefaultContext, tmpDir)
+	require.NoError(t, err)
+	assert.Equal(t, &ObjectStats{}, stats)
+	assert.False(t, HasCommitGraph(tmpDir))
+	assert.False(t, HasMultiPackIndex(tmpDir))
+}
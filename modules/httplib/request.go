@@ -100,9 +100,12 @@ func (r *Request) Param(key, value string) *Request {
 }
 
 // Body adds request raw body.
-// it supports string and []byte.
+// it supports string, []byte and io.Reader, which is streamed with an unknown length.
 func (r *Request) Body(data any) *Request {
 	switch t := data.(type) {
+	case io.Reader:
+		r.req.Body = io.NopCloser(t)
+		r.req.ContentLength = -1
 	case string:
 		bf := bytes.NewBufferString(t)
 		r.req.Body = io.NopCloser(bf)
@@ -0,0 +1,74 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package lfstransfer
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"code.gitea.io/gitea/modules/lfs"
+)
+
+// Operations of the git-lfs-transfer command
+const (
+	OperationUpload   = "upload"
+	OperationDownload = "download"
+)
+
+// BatchItem is the state of an object requested in a batch
+type BatchItem struct {
+	lfs.Pointer
+	Present bool
+}
+
+// Lock is a lock of a path of the repository
+type Lock struct {
+	ID        string
+	Path      string
+	LockedAt  time.Time
+	OwnerName string
+}
+
+// ListLocksOptions are the filters and the paging of the locks to list
+type ListLocksOptions struct {
+	Path    string
+	ID      string
+	Cursor  string
+	Limit   int
+	Refname string
+}
+
+// StatusError is an error of the backend reported to the client with its status code
+type StatusError struct {
+	Code    int
+	Message string
+	// Lock is the conflicting lock when a lock cannot be created
+	Lock *Lock
+}
+
+func (err *StatusError) Error() string {
+	if err.Message == "" {
+		return http.StatusText(err.Code)
+	}
+	return err.Message
+}
+
+// Backend gives access to the LFS objects and locks of a repository
+type Backend interface {
+	// Batch returns whether the objects are present on the server
+	Batch(operation, refname string, pointers []lfs.Pointer) ([]*BatchItem, error)
+	// Upload stores the content of an object
+	Upload(pointer lfs.Pointer, content io.Reader) error
+	// Verify checks that an object was uploaded
+	Verify(pointer lfs.Pointer) error
+	// Download returns the content of an object and its size
+	Download(oid string) (io.ReadCloser, int64, error)
+	// CreateLock locks a path, a StatusError with the conflicting lock is returned if it is already locked
+	CreateLock(path, refname string) (*Lock, error)
+	// ListLocks returns the locks and the cursor of the next page, if any
+	ListLocks(opts ListLocksOptions) ([]*Lock, string, error)
+	// Unlock removes a lock, force allows to remove the locks of other users
+	Unlock(id, refname string, force bool) (*Lock, error)
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package lfstransfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"code.gitea.io/gitea/modules/httplib"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/private"
+	api "code.gitea.io/gitea/modules/structs"
+)
+
+// localBackend is a Backend using the LFS server of the local instance, so that the LFS meta objects,
+// the locks and the quotas are handled the same way whatever the protocol used by the client.
+type localBackend struct {
+	ctx           context.Context
+	ownerName     string
+	repoName      string
+	authorization string
+}
+
+// NewLocalBackend returns a Backend for a repository of the local instance, with requests authorized by the LFS token
+func NewLocalBackend(ctx context.Context, ownerName, repoName, authorization string) Backend {
+	return &localBackend{
+		ctx:           ctx,
+		ownerName:     ownerName,
+		repoName:      repoName,
+		authorization: authorization,
+	}
+}
+
+func (b *localBackend) request(method, path string) *httplib.Request {
+	return private.NewLFSRequest(b.ctx, b.ownerName, b.repoName, b.authorization, method, path)
+}
+
+// requestJSON sends the body as JSON and decodes the response into result if the status is one of the expected ones
+func (b *localBackend) requestJSON(method, path string, body, result any, expected ...int) (int, error) {
+	req := b.request(method, path)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		req.Header("Content-Type", lfs.MediaType).Body(data)
+	}
+	resp, err := req.Response()
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			if result == nil {
+				return resp.StatusCode, nil
+			}
+			return resp.StatusCode, json.NewDecoder(resp.Body).Decode(result)
+		}
+	}
+	return resp.StatusCode, responseError(resp)
+}
+
+// responseError converts an error response of the LFS server to a StatusError
+func responseError(resp *http.Response) error {
+	var body api.LFSLockError
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	err := &StatusError{Code: resp.StatusCode, Message: body.Message}
+	if body.Lock != nil {
+		err.Lock = toLock(body.Lock)
+	}
+	if err.Code >= http.StatusInternalServerError {
+		return fmt.Errorf("LFS server responded with status %d: %s", resp.StatusCode, body.Message)
+	}
+	return err
+}
+
+func toLock(lock *api.LFSLock) *Lock {
+	result := &Lock{
+		ID:       lock.ID,
+		Path:     lock.Path,
+		LockedAt: lock.LockedAt,
+	}
+	if lock.Owner != nil {
+		result.OwnerName = lock.Owner.Name
+	}
+	return result
+}
+
+func (b *localBackend) Batch(operation, refname string, pointers []lfs.Pointer) ([]*BatchItem, error) {
+	req := &lfs.BatchRequest{
+		Operation: operation,
+		Transfers: []string{"basic"},
+		Objects:   pointers,
+	}
+	if refname != "" {
+		req.Ref = &lfs.Reference{Name: refname}
+	}
+	var resp lfs.BatchResponse
+	if _, err := b.requestJSON(http.MethodPost, "objects/batch", req, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	items := make([]*BatchItem, 0, len(resp.Objects))
+	for _, object := range resp.Objects {
+		item := &BatchItem{Pointer: object.Pointer}
+		if object.Error == nil {
+			if operation == OperationUpload {
+				// objects already stored are returned without an upload action
+				item.Present = object.Actions["upload"] == nil
+			} else {
+				item.Present = object.Actions["download"] != nil
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (b *localBackend) Upload(pointer lfs.Pointer, content io.Reader) error {
+	resp, err := b.request(http.MethodPut, fmt.Sprintf("objects/%s/%d", pointer.Oid, pointer.Size)).
+		Header("Content-Type", "application/octet-stream").
+		SetReadWriteTimeout(0).
+		Body(content).
+		Response()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (b *localBackend) Verify(pointer lfs.Pointer) error {
+	_, err := b.requestJSON(http.MethodPost, "verify", pointer, nil, http.StatusOK)
+	return err
+}
+
+func (b *localBackend) Download(oid string) (io.ReadCloser, int64, error) {
+	resp, err := b.request(http.MethodGet, "objects/"+oid).
+		SetReadWriteTimeout(0).
+		Response()
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, responseError(resp)
+	}
+	if resp.ContentLength < 0 {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("unknown size of LFS object %s", oid)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (b *localBackend) CreateLock(path, refname string) (*Lock, error) {
+	var resp api.LFSLockResponse
+	if _, err := b.requestJSON(http.MethodPost, "locks", &api.LFSLockRequest{Path: path}, &resp, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return toLock(resp.Lock), nil
+}
+
+func (b *localBackend) ListLocks(opts ListLocksOptions) ([]*Lock, string, error) {
+	req := b.request(http.MethodGet, "locks")
+	if opts.Path != "" {
+		req.Param("path", opts.Path)
+	}
+	if opts.ID != "" {
+		req.Param("id", opts.ID)
+	}
+	if opts.Cursor != "" {
+		req.Param("cursor", opts.Cursor)
+	}
+	if opts.Limit > 0 {
+		req.Param("limit", strconv.Itoa(opts.Limit))
+	}
+	resp, err := req.Response()
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", responseError(resp)
+	}
+
+	var list api.LFSLockList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", err
+	}
+	locks := make([]*Lock, 0, len(list.Locks))
+	for _, lock := range list.Locks {
+		locks = append(locks, toLock(lock))
+	}
+	return locks, list.Next, nil
+}
+
+func (b *localBackend) Unlock(id, refname string, force bool) (*Lock, error) {
+	var resp api.LFSLockResponse
+	if _, err := b.requestJSON(http.MethodPost, "locks/"+url.PathEscape(id)+"/unlock", &api.LFSLockDeleteRequest{Force: force}, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return toLock(resp.Lock), nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package lfstransfer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pktMaxLength is the maximum length of a packet, including its 4 bytes header
+	pktMaxLength = 65520
+	pktMaxData   = pktMaxLength - 4
+)
+
+type pktType int
+
+const (
+	pktData pktType = iota
+	pktFlush
+	pktDelim
+)
+
+var errUnexpectedPacket = errors.New("unexpected packet")
+
+// pktReader reads the pkt-line framing used by git and the git-lfs-transfer protocol
+type pktReader struct {
+	r *bufio.Reader
+}
+
+func newPktReader(r io.Reader) *pktReader {
+	return &pktReader{r: bufio.NewReader(r)}
+}
+
+// readPacket reads the next packet, the returned data is only set for data packets
+func (p *pktReader) readPacket() (pktType, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(p.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid packet length %q: %w", header[:], err)
+	}
+	switch {
+	case length == 0:
+		return pktFlush, nil, nil
+	case length == 1:
+		return pktDelim, nil, nil
+	case length < 4 || length > pktMaxLength:
+		return 0, nil, fmt.Errorf("invalid packet length %d", length)
+	}
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return 0, nil, err
+	}
+	return pktData, data, nil
+}
+
+// readLine reads the next packet, with the trailing newline removed for text packets
+func (p *pktReader) readLine() (pktType, string, error) {
+	typ, data, err := p.readPacket()
+	return typ, strings.TrimSuffix(string(data), "\n"), err
+}
+
+// readSection reads text packets until a delimiter or flush packet and returns which one ended the section
+func (p *pktReader) readSection() ([]string, pktType, error) {
+	var lines []string
+	for {
+		typ, line, err := p.readLine()
+		if err != nil {
+			return nil, 0, err
+		}
+		if typ != pktData {
+			return lines, typ, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// dataReader returns a reader of the content of the data packets up to the next flush packet
+func (p *pktReader) dataReader() io.Reader {
+	return &pktDataReader{p: p}
+}
+
+type pktDataReader struct {
+	p    *pktReader
+	buf  []byte
+	done bool
+}
+
+func (r *pktDataReader) Read(b []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		typ, data, err := r.p.readPacket()
+		if err != nil {
+			return 0, err
+		}
+		switch typ {
+		case pktFlush:
+			r.done = true
+		case pktDelim:
+			return 0, errUnexpectedPacket
+		default:
+			r.buf = data
+		}
+	}
+	n := copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// pktWriter writes pkt-line framed packets, they are buffered until flush is called
+type pktWriter struct {
+	w *bufio.Writer
+}
+
+func newPktWriter(w io.Writer) *pktWriter {
+	return &pktWriter{w: bufio.NewWriter(w)}
+}
+
+// writePacket writes data in as many packets as needed
+func (p *pktWriter) writePacket(data []byte) error {
+	for len(data) > 0 {
+		n := min(len(data), pktMaxData)
+		if _, err := fmt.Fprintf(p.w, "%04x", n+4); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (p *pktWriter) writeLine(line string) error {
+	return p.writePacket([]byte(line + "\n"))
+}
+
+func (p *pktWriter) writeFlush() error {
+	_, err := p.w.WriteString("0000")
+	return err
+}
+
+func (p *pktWriter) writeDelim() error {
+	_, err := p.w.WriteString("0001")
+	return err
+}
+
+// Write writes b as data packets, so that the writer can be used as the destination of a copy
+func (p *pktWriter) Write(b []byte) (int, error) {
+	if err := p.writePacket(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (p *pktWriter) flush() error {
+	return p.w.Flush()
+}
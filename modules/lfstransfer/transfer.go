@@ -0,0 +1,425 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+// Package lfstransfer implements the server side of the git-lfs-transfer protocol, which transfers
+// LFS objects and manages locks over SSH without the HTTP LFS API.
+// https://github.com/git-lfs/git-lfs/blob/main/docs/proposals/ssh_adapter.md
+package lfstransfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/log"
+)
+
+// transfer is a git-lfs-transfer session of a user for an operation
+type transfer struct {
+	r         *pktReader
+	w         *pktWriter
+	operation string
+	userName  string
+	backend   Backend
+}
+
+// Serve runs the git-lfs-transfer protocol on r and w for the operation until the client quits.
+// userName is the name of the user using the session, to tell its locks from the ones of other users.
+func Serve(r io.Reader, w io.Writer, operation, userName string, backend Backend) error {
+	if operation != OperationUpload && operation != OperationDownload {
+		return fmt.Errorf("unknown operation %q", operation)
+	}
+	t := &transfer{
+		r:         newPktReader(r),
+		w:         newPktWriter(w),
+		operation: operation,
+		userName:  userName,
+		backend:   backend,
+	}
+
+	// advertise the capabilities of the server
+	if err := t.w.writeLine("version=1"); err != nil {
+		return err
+	}
+	if err := t.w.writeFlush(); err != nil {
+		return err
+	}
+	if err := t.w.flush(); err != nil {
+		return err
+	}
+
+	for {
+		typ, line, err := t.r.readLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if typ != pktData {
+			continue
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+		if command == "quit" {
+			if _, _, err := t.r.readSection(); err != nil {
+				return err
+			}
+			return t.writeStatus(http.StatusOK, nil, nil)
+		}
+		if err := t.handle(command, arg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle processes a command, it only returns the errors of the connection
+func (t *transfer) handle(command, arg string) error {
+	switch command {
+	case "version":
+		return t.handleVersion(arg)
+	case "batch":
+		return t.handleBatch()
+	case "put-object":
+		return t.handlePutObject(arg)
+	case "verify-object":
+		return t.handleVerifyObject(arg)
+	case "get-object":
+		return t.handleGetObject(arg)
+	case "lock":
+		return t.handleLock()
+	case "list-lock":
+		return t.handleListLock()
+	case "unlock":
+		return t.handleUnlock(arg)
+	}
+
+	if err := t.skipRequest(); err != nil {
+		return err
+	}
+	return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "unknown command " + command})
+}
+
+// readArgs reads the arguments of a request, given as key=value lines
+func (t *transfer) readArgs() (map[string]string, pktType, error) {
+	lines, end, err := t.r.readSection()
+	if err != nil {
+		return nil, 0, err
+	}
+	args := make(map[string]string, len(lines))
+	for _, line := range lines {
+		key, value, _ := strings.Cut(line, "=")
+		args[key] = value
+	}
+	return args, end, nil
+}
+
+// skipRequest discards the rest of a request up to its final flush packet
+func (t *transfer) skipRequest() error {
+	for {
+		typ, _, err := t.r.readPacket()
+		if err != nil {
+			return err
+		}
+		if typ == pktFlush {
+			return nil
+		}
+	}
+}
+
+// writeStatus writes a response, data lines are preceded by a delimiter when present
+func (t *transfer) writeStatus(code int, args, data []string) error {
+	if err := t.w.writeLine(fmt.Sprintf("status %03d", code)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := t.w.writeLine(arg); err != nil {
+			return err
+		}
+	}
+	if data != nil {
+		if err := t.w.writeDelim(); err != nil {
+			return err
+		}
+		for _, line := range data {
+			if err := t.w.writeLine(line); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.w.writeFlush(); err != nil {
+		return err
+	}
+	return t.w.flush()
+}
+
+// writeError writes the error of a request, errors of the backend which are not a StatusError are internal errors
+func (t *transfer) writeError(err error) error {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		log.Error("git-lfs-transfer: %v", err)
+		statusErr = &StatusError{Code: http.StatusInternalServerError, Message: "internal server error"}
+	}
+	var args []string
+	if statusErr.Lock != nil {
+		args = lockArgs(statusErr.Lock)
+	}
+	return t.writeStatus(statusErr.Code, args, []string{statusErr.Error()})
+}
+
+func (t *transfer) handleVersion(version string) error {
+	if err := t.skipRequest(); err != nil {
+		return err
+	}
+	if version != "1" {
+		return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "unsupported version " + version})
+	}
+	return t.writeStatus(http.StatusOK, nil, nil)
+}
+
+func (t *transfer) handleBatch() error {
+	args, end, err := t.readArgs()
+	if err != nil {
+		return err
+	}
+	var lines []string
+	if end == pktDelim {
+		if lines, end, err = t.r.readSection(); err != nil {
+			return err
+		}
+	}
+	if end != pktFlush {
+		if err := t.skipRequest(); err != nil {
+			return err
+		}
+		return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "malformed batch request"})
+	}
+	if algo, ok := args["hash-algo"]; ok && algo != "sha256" {
+		return t.writeError(&StatusError{Code: http.StatusConflict, Message: "unsupported hash algorithm " + algo})
+	}
+
+	pointers := make([]lfs.Pointer, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "malformed object " + line})
+		}
+		pointer, err := parsePointer(fields[0], fields[1])
+		if err != nil {
+			return t.writeError(err)
+		}
+		pointers = append(pointers, pointer)
+	}
+
+	items, err := t.backend.Batch(t.operation, args["refname"], pointers)
+	if err != nil {
+		return t.writeError(err)
+	}
+	data := make([]string, 0, len(items))
+	for _, item := range items {
+		action := "noop"
+		if t.operation == OperationUpload && !item.Present {
+			action = "upload"
+		} else if t.operation == OperationDownload && item.Present {
+			action = "download"
+		}
+		data = append(data, fmt.Sprintf("%s %d %s", item.Oid, item.Size, action))
+	}
+	return t.writeStatus(http.StatusOK, nil, data)
+}
+
+func (t *transfer) handlePutObject(oid string) error {
+	args, end, err := t.readArgs()
+	if err != nil {
+		return err
+	}
+	if end != pktDelim {
+		return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "missing object content"})
+	}
+	content := t.r.dataReader()
+
+	pointer, err := parsePointer(oid, args["size"])
+	if err == nil && t.operation != OperationUpload {
+		err = &StatusError{Code: http.StatusForbidden, Message: "objects can only be uploaded by an upload operation"}
+	}
+	if err == nil {
+		err = t.backend.Upload(pointer, content)
+	}
+	// the content must be consumed up to its flush packet whatever happened
+	if _, copyErr := io.Copy(io.Discard, content); copyErr != nil {
+		return copyErr
+	}
+	if err != nil {
+		return t.writeError(err)
+	}
+	return t.writeStatus(http.StatusOK, nil, nil)
+}
+
+func (t *transfer) handleVerifyObject(oid string) error {
+	args, end, err := t.readArgs()
+	if err != nil {
+		return err
+	}
+	if end != pktFlush {
+		if err := t.skipRequest(); err != nil {
+			return err
+		}
+	}
+	pointer, err := parsePointer(oid, args["size"])
+	if err != nil {
+		return t.writeError(err)
+	}
+	if err := t.backend.Verify(pointer); err != nil {
+		return t.writeError(err)
+	}
+	return t.writeStatus(http.StatusOK, nil, nil)
+}
+
+func (t *transfer) handleGetObject(oid string) error {
+	if err := t.skipRequest(); err != nil {
+		return err
+	}
+	if !(lfs.Pointer{Oid: oid}).IsValid() {
+		return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "invalid object id " + oid})
+	}
+
+	content, size, err := t.backend.Download(oid)
+	if err != nil {
+		return t.writeError(err)
+	}
+	defer content.Close()
+
+	if err := t.w.writeLine(fmt.Sprintf("status %03d", http.StatusOK)); err != nil {
+		return err
+	}
+	if err := t.w.writeLine("size=" + strconv.FormatInt(size, 10)); err != nil {
+		return err
+	}
+	if err := t.w.writeDelim(); err != nil {
+		return err
+	}
+	if _, err := io.Copy(t.w, content); err != nil {
+		return err
+	}
+	if err := t.w.writeFlush(); err != nil {
+		return err
+	}
+	return t.w.flush()
+}
+
+func (t *transfer) handleLock() error {
+	args, end, err := t.readArgs()
+	if err != nil {
+		return err
+	}
+	if end != pktFlush {
+		if err := t.skipRequest(); err != nil {
+			return err
+		}
+	}
+	if t.operation != OperationUpload {
+		return t.writeError(&StatusError{Code: http.StatusForbidden, Message: "locks can only be created by an upload operation"})
+	}
+	if args["path"] == "" {
+		return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "missing path"})
+	}
+
+	lock, err := t.backend.CreateLock(args["path"], args["refname"])
+	if err != nil {
+		return t.writeError(err)
+	}
+	return t.writeStatus(http.StatusCreated, lockArgs(lock), nil)
+}
+
+func (t *transfer) handleListLock() error {
+	args, end, err := t.readArgs()
+	if err != nil {
+		return err
+	}
+	if end != pktFlush {
+		if err := t.skipRequest(); err != nil {
+			return err
+		}
+	}
+	opts := ListLocksOptions{
+		Path:    args["path"],
+		ID:      args["id"],
+		Cursor:  args["cursor"],
+		Refname: args["refname"],
+	}
+	if limit, ok := args["limit"]; ok {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit < 0 {
+			return t.writeError(&StatusError{Code: http.StatusBadRequest, Message: "invalid limit " + limit})
+		}
+	}
+
+	locks, next, err := t.backend.ListLocks(opts)
+	if err != nil {
+		return t.writeError(err)
+	}
+
+	var respArgs []string
+	if next != "" {
+		respArgs = append(respArgs, "next-cursor="+next)
+	}
+	data := make([]string, 0, len(locks)*4)
+	for _, lock := range locks {
+		data = append(data,
+			"lock "+lock.ID,
+			fmt.Sprintf("path %s %s", lock.ID, lock.Path),
+			fmt.Sprintf("locked-at %s %s", lock.ID, lock.LockedAt.UTC().Format(time.RFC3339)),
+			fmt.Sprintf("ownername %s %s", lock.ID, lock.OwnerName),
+		)
+		if t.operation == OperationUpload {
+			owner := "theirs"
+			if lock.OwnerName == t.userName {
+				owner = "ours"
+			}
+			data = append(data, fmt.Sprintf("owner %s %s", lock.ID, owner))
+		}
+	}
+	return t.writeStatus(http.StatusOK, respArgs, data)
+}
+
+func (t *transfer) handleUnlock(id string) error {
+	args, end, err := t.readArgs()
+	if err != nil {
+		return err
+	}
+	if end != pktFlush {
+		if err := t.skipRequest(); err != nil {
+			return err
+		}
+	}
+	if t.operation != OperationUpload {
+		return t.writeError(&StatusError{Code: http.StatusForbidden, Message: "locks can only be removed by an upload operation"})
+	}
+
+	lock, err := t.backend.Unlock(id, args["refname"], args["force"] == "true")
+	if err != nil {
+		return t.writeError(err)
+	}
+	return t.writeStatus(http.StatusOK, lockArgs(lock), nil)
+}
+
+func lockArgs(lock *Lock) []string {
+	return []string{
+		"id=" + lock.ID,
+		"path=" + lock.Path,
+		"locked-at=" + lock.LockedAt.UTC().Format(time.RFC3339),
+		"ownername=" + lock.OwnerName,
+	}
+}
+
+func parsePointer(oid, size string) (lfs.Pointer, error) {
+	pointer := lfs.Pointer{Oid: oid}
+	var err error
+	if pointer.Size, err = strconv.ParseInt(size, 10, 64); err != nil || !pointer.IsValid() {
+		return pointer, &StatusError{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid object %s of size %q", oid, size)}
+	}
+	return pointer, nil
+}
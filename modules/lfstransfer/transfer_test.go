@@ -0,0 +1,198 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package lfstransfer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/modules/lfs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryBackend struct {
+	objects map[string][]byte
+	locks   []*Lock
+}
+
+func (b *memoryBackend) Batch(operation, refname string, pointers []lfs.Pointer) ([]*BatchItem, error) {
+	items := make([]*BatchItem, 0, len(pointers))
+	for _, p := range pointers {
+		_, ok := b.objects[p.Oid]
+		items = append(items, &BatchItem{Pointer: p, Present: ok})
+	}
+	return items, nil
+}
+
+func (b *memoryBackend) Upload(pointer lfs.Pointer, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	b.objects[pointer.Oid] = data
+	return nil
+}
+
+func (b *memoryBackend) Verify(pointer lfs.Pointer) error {
+	if data, ok := b.objects[pointer.Oid]; !ok || int64(len(data)) != pointer.Size {
+		return &StatusError{Code: http.StatusNotFound}
+	}
+	return nil
+}
+
+func (b *memoryBackend) Download(oid string) (io.ReadCloser, int64, error) {
+	data, ok := b.objects[oid]
+	if !ok {
+		return nil, 0, &StatusError{Code: http.StatusNotFound}
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (b *memoryBackend) CreateLock(path, refname string) (*Lock, error) {
+	for _, lock := range b.locks {
+		if lock.Path == path {
+			return nil, &StatusError{Code: http.StatusConflict, Message: "already locked", Lock: lock}
+		}
+	}
+	lock := &Lock{ID: "1", Path: path, LockedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), OwnerName: "user2"}
+	b.locks = append(b.locks, lock)
+	return lock, nil
+}
+
+func (b *memoryBackend) ListLocks(opts ListLocksOptions) ([]*Lock, string, error) {
+	return b.locks, "", nil
+}
+
+func (b *memoryBackend) Unlock(id, refname string, force bool) (*Lock, error) {
+	for i, lock := range b.locks {
+		if lock.ID == id {
+			b.locks = append(b.locks[:i], b.locks[i+1:]...)
+			return lock, nil
+		}
+	}
+	return nil, &StatusError{Code: http.StatusNotFound}
+}
+
+// request builds the packets of a request, sections are separated by delimiters
+func request(sections ...[]string) []byte {
+	var buf bytes.Buffer
+	w := newPktWriter(&buf)
+	for i, section := range sections {
+		if i > 0 {
+			_ = w.writeDelim()
+		}
+		for _, line := range section {
+			_ = w.writeLine(line)
+		}
+	}
+	_ = w.writeFlush()
+	_ = w.flush()
+	return buf.Bytes()
+}
+
+// readResponses splits the output of a session into responses, delimiters are returned as "--"
+func readResponses(t *testing.T, output []byte) [][]string {
+	r := newPktReader(bytes.NewReader(output))
+	var responses [][]string
+	var current []string
+	for {
+		typ, line, err := r.readLine()
+		if err == io.EOF {
+			return responses
+		}
+		require.NoError(t, err)
+		switch typ {
+		case pktFlush:
+			responses = append(responses, current)
+			current = nil
+		case pktDelim:
+			current = append(current, "--")
+		default:
+			current = append(current, line)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	content := "hello world\n"
+	oid := "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+	missing := strings.Repeat("0", 64)
+	backend := &memoryBackend{objects: map[string][]byte{}}
+
+	var input bytes.Buffer
+	input.Write(request([]string{"version 1"}))
+	input.Write(request([]string{"batch", "hash-algo=sha256"}, []string{oid + " 12", missing + " 3"}))
+	// the line written in the request adds the final newline of the content
+	input.Write(request([]string{"put-object " + oid, "size=12"}, []string{strings.TrimSuffix(content, "\n")}))
+	input.Write(request([]string{"verify-object " + oid, "size=12"}))
+	input.Write(request([]string{"get-object " + oid}))
+	input.Write(request([]string{"lock", "path=a.bin"}))
+	input.Write(request([]string{"lock", "path=a.bin"}))
+	input.Write(request([]string{"list-lock"}))
+	input.Write(request([]string{"unknown"}))
+	input.Write(request([]string{"quit"}))
+
+	var output bytes.Buffer
+	require.NoError(t, Serve(&input, &output, OperationUpload, "user2", backend))
+
+	responses := readResponses(t, output.Bytes())
+	require.Len(t, responses, 11)
+	assert.Equal(t, []string{"version=1"}, responses[0])
+	assert.Equal(t, []string{"status 200"}, responses[1])
+	assert.Equal(t, []string{"status 200", "--", oid + " 12 upload", missing + " 3 upload"}, responses[2])
+	assert.Equal(t, []string{"status 200"}, responses[3])
+	assert.Equal(t, []byte(content), backend.objects[oid])
+	assert.Equal(t, []string{"status 200"}, responses[4])
+	assert.Equal(t, []string{"status 200", "size=12", "--", "hello world"}, responses[5])
+	lockArgs := []string{"id=1", "path=a.bin", "locked-at=2024-01-02T03:04:05Z", "ownername=user2"}
+	assert.Equal(t, append([]string{"status 201"}, lockArgs...), responses[6])
+	assert.Equal(t, append(append([]string{"status 409"}, lockArgs...), "--", "already locked"), responses[7])
+	assert.Equal(t, []string{
+		"status 200", "--",
+		"lock 1", "path 1 a.bin", "locked-at 1 2024-01-02T03:04:05Z", "ownername 1 user2", "owner 1 ours",
+	}, responses[8])
+	assert.Equal(t, []string{"status 400", "--", "unknown command unknown"}, responses[9])
+	assert.Equal(t, []string{"status 200"}, responses[10])
+}
+
+func TestServeDownload(t *testing.T) {
+	oid := "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+	backend := &memoryBackend{objects: map[string][]byte{oid: []byte("hello world\n")}}
+
+	var input bytes.Buffer
+	input.Write(request([]string{"batch"}, []string{oid + " 12"}))
+	input.Write(request([]string{"put-object " + oid, "size=12"}, []string{"hello world"}))
+	input.Write(request([]string{"get-object " + strings.Repeat("1", 64)}))
+	input.Write(request([]string{"lock", "path=a.bin"}))
+
+	var output bytes.Buffer
+	require.NoError(t, Serve(&input, &output, OperationDownload, "user2", backend))
+
+	responses := readResponses(t, output.Bytes())
+	require.Len(t, responses, 5)
+	assert.Equal(t, []string{"status 200", "--", oid + " 12 download"}, responses[1])
+	assert.Equal(t, "status 403", responses[2][0])
+	assert.Equal(t, []string{"status 404", "--", "Not Found"}, responses[3])
+	assert.Equal(t, "status 403", responses[4][0])
+}
+
+func TestPktDataReader(t *testing.T) {
+	var buf bytes.Buffer
+	w := newPktWriter(&buf)
+	data := bytes.Repeat([]byte("0123456789"), pktMaxData/5)
+	require.NoError(t, w.writePacket(data))
+	require.NoError(t, w.writeFlush())
+	require.NoError(t, w.flush())
+
+	r := newPktReader(&buf)
+	read, err := io.ReadAll(r.dataReader())
+	require.NoError(t, err)
+	assert.Equal(t, data, read)
+}
@@ -0,0 +1,11 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package log
+
+type WriterSyslogOption struct {
+	// Network and Addr of the syslog daemon, the local one is used if they are empty
+	Network string
+	Addr    string
+	Tag     string
+}
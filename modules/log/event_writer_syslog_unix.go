@@ -0,0 +1,60 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"io"
+	"log/syslog"
+)
+
+type eventWriterSyslog struct {
+	*EventWriterBaseImpl
+}
+
+var _ EventWriter = (*eventWriterSyslog)(nil)
+
+func NewEventWriterSyslog(writerName string, writerMode WriterMode) EventWriter {
+	w := &eventWriterSyslog{EventWriterBaseImpl: NewEventWriterBase(writerName, "syslog", writerMode)}
+	opt := writerMode.WriterOption.(WriterSyslogOption)
+	w.OutputWriteCloser = &syslogWriter{opt: opt}
+	return w
+}
+
+func init() {
+	RegisterEventWriter("syslog", NewEventWriterSyslog)
+}
+
+// syslogWriter connects to the daemon on the first message, so a daemon started late is not an error
+type syslogWriter struct {
+	opt         WriterSyslogOption
+	innerWriter io.WriteCloser
+}
+
+var _ io.WriteCloser = (*syslogWriter)(nil)
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	if s.innerWriter == nil {
+		w, err := syslog.Dial(s.opt.Network, s.opt.Addr, syslog.LOG_INFO|syslog.LOG_DAEMON, s.opt.Tag)
+		if err != nil {
+			return 0, err
+		}
+		s.innerWriter = w
+	}
+	n, err := s.innerWriter.Write(p)
+	if err != nil {
+		// reconnect on the next message
+		_ = s.innerWriter.Close()
+		s.innerWriter = nil
+	}
+	return n, err
+}
+
+func (s *syslogWriter) Close() error {
+	if s.innerWriter != nil {
+		return s.innerWriter.Close()
+	}
+	return nil
+}
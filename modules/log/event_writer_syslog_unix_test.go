@@ -0,0 +1,40 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogLogger(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	logger := NewLoggerWithWriters(context.Background(), "test", NewEventWriterSyslog("test-syslog", WriterMode{
+		Level:        INFO,
+		Flags:        FlagsFromBits(0),
+		WriterOption: WriterSyslogOption{Network: "unixgram", Addr: addr, Tag: "forgejo-test"},
+	}))
+	defer logger.Close()
+
+	logger.SendLogEvent(&Event{Level: INFO, MsgSimpleText: "TEST MSG", Time: time.Now()})
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(10*time.Second)))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Contains(t, string(buf[:n]), "forgejo-test[")
+	assert.Contains(t, string(buf[:n]), "TEST MSG")
+}
@@ -0,0 +1,259 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// https://github.com/in-toto/attestation/tree/main/spec
+// https://github.com/secure-systems-lab/dsse/blob/master/protocol.md
+// https://github.com/sigstore/protobuf-specs/blob/main/protos/sigstore_bundle.proto
+
+const (
+	FormatStatement      = "in-toto"
+	FormatSigstoreBundle = "sigstore-bundle"
+
+	PayloadTypeInToto = "application/vnd.in-toto+json"
+
+	StatementTypeV01 = "https://in-toto.io/Statement/v0.1"
+	StatementTypeV1  = "https://in-toto.io/Statement/v1"
+
+	PredicateTypeSLSAProvenanceV02 = "https://slsa.dev/provenance/v0.2"
+	PredicateTypeSLSAProvenanceV1  = "https://slsa.dev/provenance/v1"
+
+	mediaTypeSigstoreBundlePrefix = "application/vnd.dev.sigstore.bundle"
+)
+
+// MaxSize is the maximum size of an attestation
+const MaxSize = 4 << 20
+
+var (
+	ErrInvalidAttestation          = util.NewInvalidArgumentErrorf("attestation is invalid")
+	ErrUnsupportedFormat           = util.NewInvalidArgumentErrorf("attestation format is not supported")
+	ErrMissingVerificationMaterial = util.NewInvalidArgumentErrorf("attestation has no certificate to verify the signature")
+	ErrInvalidSignature            = util.NewInvalidArgumentErrorf("attestation signature is invalid")
+)
+
+// Statement is an in-toto statement
+type Statement struct {
+	Type          string         `json:"_type"`
+	Subject       []*Subject     `json:"subject"`
+	PredicateType string         `json:"predicateType"`
+	Predicate     map[string]any `json:"predicate,omitempty"`
+}
+
+// Subject is an artifact the statement is about
+type Subject struct {
+	Name   string            `json:"name,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Envelope is a DSSE envelope
+type Envelope struct {
+	PayloadType string       `json:"payloadType"`
+	Payload     string       `json:"payload"`
+	Signatures  []*Signature `json:"signatures"`
+}
+
+// Signature is a signature of a DSSE envelope
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// Bundle is a Sigstore bundle with a DSSE envelope
+type Bundle struct {
+	MediaType            string                `json:"mediaType"`
+	VerificationMaterial *VerificationMaterial `json:"verificationMaterial"`
+	DSSEEnvelope         *Envelope             `json:"dsseEnvelope"`
+}
+
+// VerificationMaterial contains the certificate of the signer
+type VerificationMaterial struct {
+	Certificate *struct {
+		RawBytes string `json:"rawBytes"`
+	} `json:"certificate,omitempty"`
+	X509CertificateChain *struct {
+		Certificates []*struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificates"`
+	} `json:"x509CertificateChain,omitempty"`
+	TlogEntries []*TransparencyLogEntry `json:"tlogEntries,omitempty"`
+}
+
+// Attestation is a parsed attestation
+type Attestation struct {
+	Format    string
+	Statement *Statement
+	// Signer is the identity claimed by the certificate of a bundle
+	Signer     *Identity
+	Provenance *Provenance
+
+	// the signed payload and the verification material of a bundle, see TrustedRoot.Verify
+	payload     []byte
+	certificate *x509.Certificate
+	logEntries  []*TransparencyLogEntry
+}
+
+// Parse parses an in-toto statement or a Sigstore bundle.
+// The signature of a bundle is verified against the certificate of the bundle only, anyone can
+// create a bundle claiming any signer identity until it is verified with TrustedRoot.Verify.
+func Parse(content []byte) (*Attestation, error) {
+	var probe struct {
+		Type      string `json:"_type"`
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(content, &probe); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+
+	var a *Attestation
+	switch {
+	case strings.HasPrefix(probe.MediaType, mediaTypeSigstoreBundlePrefix):
+		var b Bundle
+		if err := json.Unmarshal(content, &b); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+		}
+		if b.DSSEEnvelope == nil {
+			return nil, ErrUnsupportedFormat
+		}
+
+		cert, err := b.VerificationMaterial.certificate()
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := b.DSSEEnvelope.Verify(cert)
+		if err != nil {
+			return nil, err
+		}
+		payload, _ := base64.StdEncoding.DecodeString(b.DSSEEnvelope.Payload)
+
+		a = &Attestation{
+			Format:      FormatSigstoreBundle,
+			Statement:   s,
+			Signer:      IdentityFromCertificate(cert),
+			payload:     payload,
+			certificate: cert,
+			logEntries:  b.VerificationMaterial.TlogEntries,
+		}
+	case probe.Type != "":
+		s, err := parseStatement(content)
+		if err != nil {
+			return nil, err
+		}
+
+		a = &Attestation{
+			Format:    FormatStatement,
+			Statement: s,
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	a.Provenance = ParseProvenance(a.Statement)
+	if a.Signer != nil {
+		a.Provenance.fillFromIdentity(a.Signer)
+	}
+	return a, nil
+}
+
+func parseStatement(content []byte) (*Statement, error) {
+	var s Statement
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	if s.Type != StatementTypeV1 && s.Type != StatementTypeV01 {
+		return nil, fmt.Errorf("%w: unknown statement type %q", ErrInvalidAttestation, s.Type)
+	}
+	if len(s.Subject) == 0 || s.PredicateType == "" {
+		return nil, fmt.Errorf("%w: statement has no subject or predicate type", ErrInvalidAttestation)
+	}
+	return &s, nil
+}
+
+func (vm *VerificationMaterial) certificate() (*x509.Certificate, error) {
+	if vm == nil {
+		return nil, ErrMissingVerificationMaterial
+	}
+
+	var raw string
+	if vm.Certificate != nil {
+		raw = vm.Certificate.RawBytes
+	} else if vm.X509CertificateChain != nil && len(vm.X509CertificateChain.Certificates) > 0 {
+		raw = vm.X509CertificateChain.Certificates[0].RawBytes
+	}
+	if raw == "" {
+		return nil, ErrMissingVerificationMaterial
+	}
+
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	return cert, nil
+}
+
+// PAE returns the pre-authentication encoding of a DSSE payload which is the signed message
+func PAE(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// Verify checks that one of the signatures of the envelope is made with the key of the certificate
+// and returns the contained statement
+func (e *Envelope) Verify(cert *x509.Certificate) (*Statement, error) {
+	if e.PayloadType != PayloadTypeInToto {
+		return nil, fmt.Errorf("%w: unknown payload type %q", ErrUnsupportedFormat, e.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+
+	message := PAE(e.PayloadType, payload)
+
+	verified := false
+	for _, s := range e.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if err := verifySignature(cert.PublicKey, message, sig); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	return parseStatement(payload)
+}
+
+// Digests returns all digests of the subjects in the form algorithm:hex
+func (s *Statement) Digests() []string {
+	digests := make([]string, 0, len(s.Subject))
+	for _, subject := range s.Subject {
+		for algorithm, digest := range subject.Digest {
+			digests = append(digests, strings.ToLower(algorithm)+":"+strings.ToLower(digest))
+		}
+	}
+	return digests
+}
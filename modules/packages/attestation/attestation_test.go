@@ -0,0 +1,166 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/modules/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const statementV1 = `{
+  "_type": "https://in-toto.io/Statement/v1",
+  "subject": [{"name": "test-1.0.0.tgz", "digest": {"sha256": "ABCDEF"}}],
+  "predicateType": "https://slsa.dev/provenance/v1",
+  "predicate": {
+    "buildDefinition": {
+      "buildType": "https://example.com/workflow@v1",
+      "externalParameters": {"workflow": {"ref": "refs/heads/main", "repository": "https://example.com/owner/repo", "path": ".forgejo/workflows/release.yml"}},
+      "resolvedDependencies": [{"uri": "git+https://example.com/owner/repo@refs/heads/main", "digest": {"gitCommit": "0123456789abcdef"}}]
+    },
+    "runDetails": {
+      "builder": {"id": "https://example.com/builder"},
+      "metadata": {"invocationId": "https://example.com/owner/repo/actions/runs/1"}
+    }
+  }
+}`
+
+func TestParseStatement(t *testing.T) {
+	a, err := Parse([]byte(statementV1))
+	require.NoError(t, err)
+	assert.Equal(t, FormatStatement, a.Format)
+	assert.Nil(t, a.Signer)
+	assert.Equal(t, []string{"sha256:abcdef"}, a.Statement.Digests())
+	assert.Equal(t, &Provenance{
+		BuilderID:        "https://example.com/builder",
+		SourceRepository: "https://example.com/owner/repo",
+		SourceRef:        "refs/heads/main",
+		CommitSHA:        "0123456789abcdef",
+		Workflow:         ".forgejo/workflows/release.yml",
+		RunURL:           "https://example.com/owner/repo/actions/runs/1",
+	}, a.Provenance)
+
+	a, err = Parse([]byte(`{
+		"_type": "https://in-toto.io/Statement/v0.1",
+		"subject": [{"name": "a", "digest": {"sha512": "00"}}],
+		"predicateType": "https://slsa.dev/provenance/v0.2",
+		"predicate": {
+			"builder": {"id": "https://example.com/builder"},
+			"invocation": {"configSource": {"uri": "git+https://example.com/owner/repo@refs/tags/v1", "digest": {"sha1": "fedcba"}, "entryPoint": "build.yml"}},
+			"metadata": {"buildInvocationId": "42"}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Provenance{
+		BuilderID:        "https://example.com/builder",
+		SourceRepository: "https://example.com/owner/repo",
+		SourceRef:        "refs/tags/v1",
+		CommitSHA:        "fedcba",
+		Workflow:         "build.yml",
+		RunURL:           "42",
+	}, a.Provenance)
+
+	for _, content := range []string{
+		`invalid`,
+		`{}`,
+		`{"_type": "unknown", "subject": [{"digest": {"sha256": "00"}}], "predicateType": "x"}`,
+		`{"_type": "https://in-toto.io/Statement/v1", "predicateType": "x"}`,
+	} {
+		_, err := Parse([]byte(content))
+		assert.Error(t, err, content)
+	}
+}
+
+func createCertificate(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der := func(s string) []byte {
+		b, err := asn1.MarshalWithParams(s, "utf8")
+		require.NoError(t, err)
+		return b
+	}
+
+	san, _ := url.Parse("https://example.com/owner/repo/.forgejo/workflows/release.yml@refs/heads/main")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+		URIs:         []*url.URL{san},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidIssuerV2, Value: der("https://example.com/oidc")},
+			{Id: oidSourceRepositoryURI, Value: der("https://example.com/owner/repo")},
+			{Id: oidSourceRepositoryDigest, Value: der("0123456789abcdef")},
+		},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return key, cert
+}
+
+func createBundle(t *testing.T, key *ecdsa.PrivateKey, cert []byte, statement string) []byte {
+	digest := sha256.Sum256(PAE(PayloadTypeInToto, []byte(statement)))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	bundle := map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+		"verificationMaterial": map[string]any{
+			"certificate": map[string]any{"rawBytes": base64.StdEncoding.EncodeToString(cert)},
+		},
+		"dsseEnvelope": map[string]any{
+			"payloadType": PayloadTypeInToto,
+			"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+			"signatures":  []map[string]any{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+		},
+	}
+	content, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	return content
+}
+
+func TestParseBundle(t *testing.T) {
+	key, cert := createCertificate(t)
+
+	a, err := Parse(createBundle(t, key, cert, statementV1))
+	require.NoError(t, err)
+	assert.Equal(t, FormatSigstoreBundle, a.Format)
+	assert.Equal(t, &Identity{
+		Subject:          "https://example.com/owner/repo/.forgejo/workflows/release.yml@refs/heads/main",
+		Issuer:           "https://example.com/oidc",
+		SourceRepository: "https://example.com/owner/repo",
+		SourceDigest:     "0123456789abcdef",
+	}, a.Signer)
+	assert.Equal(t, "0123456789abcdef", a.Provenance.CommitSHA)
+
+	otherKey, _ := createCertificate(t)
+	_, err = Parse(createBundle(t, otherKey, cert, statementV1))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = Parse([]byte(`{"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json", "dsseEnvelope": {"payloadType": "application/vnd.in-toto+json", "payload": ""}}`))
+	assert.ErrorIs(t, err, ErrMissingVerificationMaterial)
+
+	_, err = Parse([]byte(`{"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json", "messageSignature": {}}`))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestPAE(t *testing.T) {
+	// https://github.com/secure-systems-lab/dsse/blob/master/protocol.md#test-case
+	assert.Equal(t, "DSSEv1 29 http://example.com/HelloWorld 11 hello world", string(PAE("http://example.com/HelloWorld", []byte("hello world"))))
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+)
+
+// The certificate extensions of Fulcio, the Sigstore certificate authority
+// https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md
+var (
+	oidIssuerV1               = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidGithubWorkflowSHA      = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 3}
+	oidIssuerV2               = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	oidBuildSignerURI         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 9}
+	oidSourceRepositoryURI    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}
+	oidSourceRepositoryDigest = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 13}
+	oidRunInvocationURI       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 21}
+)
+
+// Identity is the identity of a signer as recorded in its certificate
+type Identity struct {
+	Subject          string
+	Issuer           string
+	BuildSignerURI   string
+	SourceRepository string
+	SourceDigest     string
+	RunInvocationURI string
+}
+
+// IdentityFromCertificate extracts the identity of a signing certificate
+func IdentityFromCertificate(cert *x509.Certificate) *Identity {
+	id := &Identity{}
+	if len(cert.URIs) > 0 {
+		id.Subject = cert.URIs[0].String()
+	} else if len(cert.EmailAddresses) > 0 {
+		id.Subject = cert.EmailAddresses[0]
+	} else {
+		id.Subject = cert.Subject.CommonName
+	}
+
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV1):
+			if id.Issuer == "" {
+				id.Issuer = string(ext.Value)
+			}
+		case ext.Id.Equal(oidIssuerV2):
+			id.Issuer = derString(ext.Value)
+		case ext.Id.Equal(oidGithubWorkflowSHA):
+			if id.SourceDigest == "" {
+				id.SourceDigest = string(ext.Value)
+			}
+		case ext.Id.Equal(oidSourceRepositoryDigest):
+			id.SourceDigest = derString(ext.Value)
+		case ext.Id.Equal(oidBuildSignerURI):
+			id.BuildSignerURI = derString(ext.Value)
+		case ext.Id.Equal(oidSourceRepositoryURI):
+			id.SourceRepository = derString(ext.Value)
+		case ext.Id.Equal(oidRunInvocationURI):
+			id.RunInvocationURI = derString(ext.Value)
+		}
+	}
+	return id
+}
+
+func derString(value []byte) string {
+	var s string
+	if _, err := asn1.UnmarshalWithParams(value, &s, "utf8"); err != nil {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"strings"
+
+	"code.gitea.io/gitea/modules/json"
+)
+
+// Provenance describes how and from which sources an artifact was built
+type Provenance struct {
+	BuilderID        string
+	SourceRepository string
+	SourceRef        string
+	CommitSHA        string
+	Workflow         string
+	RunURL           string
+}
+
+// https://slsa.dev/spec/v1.0/provenance
+type slsaProvenanceV1 struct {
+	BuildDefinition struct {
+		ExternalParameters struct {
+			Workflow struct {
+				Ref        string `json:"ref"`
+				Repository string `json:"repository"`
+				Path       string `json:"path"`
+			} `json:"workflow"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []struct {
+			URI    string            `json:"uri"`
+			Digest map[string]string `json:"digest"`
+		} `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			InvocationID string `json:"invocationId"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// https://slsa.dev/spec/v0.2/provenance
+type slsaProvenanceV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Invocation struct {
+		ConfigSource struct {
+			URI        string            `json:"uri"`
+			Digest     map[string]string `json:"digest"`
+			EntryPoint string            `json:"entryPoint"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildInvocationID string `json:"buildInvocationId"`
+	} `json:"metadata"`
+}
+
+// ParseProvenance extracts the build information of a SLSA provenance statement.
+// Other predicate types result in an empty provenance.
+func ParseProvenance(s *Statement) *Provenance {
+	p := &Provenance{}
+
+	predicate, err := json.Marshal(s.Predicate)
+	if err != nil {
+		return p
+	}
+
+	switch s.PredicateType {
+	case PredicateTypeSLSAProvenanceV1:
+		var v1 slsaProvenanceV1
+		if err := json.Unmarshal(predicate, &v1); err != nil {
+			return p
+		}
+		w := v1.BuildDefinition.ExternalParameters.Workflow
+		p.BuilderID = v1.RunDetails.Builder.ID
+		p.SourceRepository = w.Repository
+		p.SourceRef = w.Ref
+		p.Workflow = w.Path
+		p.RunURL = v1.RunDetails.Metadata.InvocationID
+		for _, d := range v1.BuildDefinition.ResolvedDependencies {
+			if commit := gitCommit(d.Digest); commit != "" {
+				p.CommitSHA = commit
+				if p.SourceRepository == "" {
+					p.SourceRepository, p.SourceRef = splitGitURI(d.URI)
+				}
+				break
+			}
+		}
+	case PredicateTypeSLSAProvenanceV02:
+		var v02 slsaProvenanceV02
+		if err := json.Unmarshal(predicate, &v02); err != nil {
+			return p
+		}
+		cs := v02.Invocation.ConfigSource
+		p.BuilderID = v02.Builder.ID
+		p.SourceRepository, p.SourceRef = splitGitURI(cs.URI)
+		p.CommitSHA = gitCommit(cs.Digest)
+		p.Workflow = cs.EntryPoint
+		p.RunURL = v02.Metadata.BuildInvocationID
+	}
+	return p
+}
+
+func gitCommit(digest map[string]string) string {
+	for _, algorithm := range []string{"gitCommit", "sha1"} {
+		if v, ok := digest[algorithm]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitGitURI splits an URI like git+https://example.com/owner/repo@refs/heads/main
+func splitGitURI(uri string) (string, string) {
+	uri = strings.TrimPrefix(uri, "git+")
+	repository, ref, _ := strings.Cut(uri, "@")
+	return repository, ref
+}
+
+func (p *Provenance) fillFromIdentity(id *Identity) {
+	if p.BuilderID == "" {
+		p.BuilderID = id.BuildSignerURI
+	}
+	if p.SourceRepository == "" {
+		p.SourceRepository = id.SourceRepository
+	}
+	if p.CommitSHA == "" {
+		p.CommitSHA = id.SourceDigest
+	}
+	if p.RunURL == "" {
+		p.RunURL = id.RunInvocationURI
+	}
+}
+
+// IsEmpty checks if no build information is available
+func (p *Provenance) IsEmpty() bool {
+	return p.BuilderID == "" && p.SourceRepository == "" && p.CommitSHA == "" && p.Workflow == "" && p.RunURL == ""
+}
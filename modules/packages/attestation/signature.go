@@ -0,0 +1,54 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+)
+
+var errUnsupportedKey = errors.New("unsupported key type")
+
+func verifySignature(publicKey any, message, sig []byte) error {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		var digest []byte
+		switch key.Curve {
+		case elliptic.P384():
+			h := sha512.Sum384(message)
+			digest = h[:]
+		case elliptic.P521():
+			h := sha512.Sum512(message)
+			digest = h[:]
+		default:
+			h := sha256.Sum256(message)
+			digest = h[:]
+		}
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err == nil {
+			return nil
+		}
+		if err := rsa.VerifyPSS(key, crypto.SHA256, digest[:], sig, nil); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return errUnsupportedKey
+}
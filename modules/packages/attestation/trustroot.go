@@ -0,0 +1,344 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"time"
+
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// https://github.com/sigstore/protobuf-specs/blob/main/protos/sigstore_trustroot.proto
+// https://github.com/sigstore/protobuf-specs/blob/main/protos/sigstore_rekor.proto
+
+var (
+	ErrInvalidTrustedRoot = util.NewInvalidArgumentErrorf("sigstore trusted root is invalid")
+	ErrUntrustedSignature = util.NewInvalidArgumentErrorf("attestation is not signed with a certificate of a trusted sigstore certificate authority")
+)
+
+// TrustedRoot contains the certificate authorities and transparency logs a Sigstore bundle is verified against
+type TrustedRoot struct {
+	authorities []*certificateAuthority
+	logs        []*transparencyLog
+}
+
+type certificateAuthority struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	validFor      validity
+}
+
+type transparencyLog struct {
+	keyID     []byte
+	publicKey crypto.PublicKey
+	validFor  validity
+}
+
+// validity is the period a key or certificate authority is trusted for, a zero bound is unbounded
+type validity struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (v validity) contains(t time.Time) bool {
+	return (v.Start.IsZero() || !t.Before(v.Start)) && (v.End.IsZero() || !t.After(v.End))
+}
+
+type rawBytes struct {
+	RawBytes string `json:"rawBytes"`
+}
+
+// ParseTrustedRoot parses a trusted root in the JSON format of the Sigstore protobuf specs,
+// like the trusted_root.json distributed by the Sigstore TUF repository
+func ParseTrustedRoot(content []byte) (*TrustedRoot, error) {
+	var raw struct {
+		CertificateAuthorities []struct {
+			CertChain struct {
+				Certificates []*rawBytes `json:"certificates"`
+			} `json:"certChain"`
+			ValidFor validity `json:"validFor"`
+		} `json:"certificateAuthorities"`
+		Tlogs []struct {
+			PublicKey struct {
+				RawBytes string   `json:"rawBytes"`
+				ValidFor validity `json:"validFor"`
+			} `json:"publicKey"`
+			LogID struct {
+				KeyID string `json:"keyId"`
+			} `json:"logId"`
+		} `json:"tlogs"`
+	}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrustedRoot, err)
+	}
+
+	tr := &TrustedRoot{}
+	for _, ca := range raw.CertificateAuthorities {
+		// the chain is ordered from the issuing certificate to the root certificate
+		certs := ca.CertChain.Certificates
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("%w: certificate authority has no certificate", ErrInvalidTrustedRoot)
+		}
+		authority := &certificateAuthority{
+			roots:         x509.NewCertPool(),
+			intermediates: x509.NewCertPool(),
+			validFor:      ca.ValidFor,
+		}
+		for i, c := range certs {
+			cert, err := parseCertificate(c.RawBytes)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidTrustedRoot, err)
+			}
+			if i == len(certs)-1 {
+				authority.roots.AddCert(cert)
+			} else {
+				authority.intermediates.AddCert(cert)
+			}
+		}
+		tr.authorities = append(tr.authorities, authority)
+	}
+
+	for _, tlog := range raw.Tlogs {
+		der, err := base64.StdEncoding.DecodeString(tlog.PublicKey.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTrustedRoot, err)
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTrustedRoot, err)
+		}
+		keyID, err := base64.StdEncoding.DecodeString(tlog.LogID.KeyID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTrustedRoot, err)
+		}
+		if len(keyID) == 0 {
+			// the log id of Rekor is the hash of its public key
+			sum := sha256.Sum256(der)
+			keyID = sum[:]
+		}
+		tr.logs = append(tr.logs, &transparencyLog{
+			keyID:     keyID,
+			publicKey: publicKey,
+			validFor:  tlog.PublicKey.ValidFor,
+		})
+	}
+
+	if len(tr.authorities) == 0 || len(tr.logs) == 0 {
+		return nil, fmt.Errorf("%w: a certificate authority and a transparency log are required", ErrInvalidTrustedRoot)
+	}
+	return tr, nil
+}
+
+func parseCertificate(raw string) (*x509.Certificate, error) {
+	der, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// TransparencyLogEntry is the proof that a signature was recorded in a transparency log
+type TransparencyLogEntry struct {
+	LogIndex protoInt64 `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   protoInt64 `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// protoInt64 is an int64 which the protobuf JSON mapping encodes as string
+type protoInt64 int64
+
+func (i *protoInt64) UnmarshalJSON(b []byte) error {
+	v, err := strconv.ParseInt(string(bytes.Trim(b, `"`)), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = protoInt64(v)
+	return nil
+}
+
+// Verify checks that the certificate of a signed attestation was issued by a certificate authority
+// of the trusted root at the time the signature was recorded in one of its transparency logs.
+// The time is proven by the signed entry timestamp of the log, and the log entry must be about
+// the payload and the certificate of the attestation.
+func (tr *TrustedRoot) Verify(a *Attestation) error {
+	if a.certificate == nil {
+		return ErrUntrustedSignature
+	}
+
+	var lastErr error
+	for _, entry := range a.logEntries {
+		integratedTime, err := tr.verifyLogEntry(entry, a)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := tr.verifyCertificate(a.certificate, integratedTime); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	if lastErr == nil {
+		return fmt.Errorf("%w: the signature is not recorded in a transparency log", ErrUntrustedSignature)
+	}
+	return lastErr
+}
+
+func (tr *TrustedRoot) verifyLogEntry(entry *TransparencyLogEntry, a *Attestation) (time.Time, error) {
+	keyID, err := base64.StdEncoding.DecodeString(entry.LogID.KeyID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	body, err := base64.StdEncoding.DecodeString(entry.CanonicalizedBody)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+	if entry.InclusionPromise == nil {
+		return time.Time{}, fmt.Errorf("%w: the transparency log entry has no inclusion promise", ErrUntrustedSignature)
+	}
+	set, err := base64.StdEncoding.DecodeString(entry.InclusionPromise.SignedEntryTimestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+
+	integratedTime := time.Unix(int64(entry.IntegratedTime), 0)
+
+	var tlog *transparencyLog
+	for _, l := range tr.logs {
+		if bytes.Equal(l.keyID, keyID) && l.validFor.contains(integratedTime) {
+			tlog = l
+			break
+		}
+	}
+	if tlog == nil {
+		return time.Time{}, fmt.Errorf("%w: the transparency log is not trusted", ErrUntrustedSignature)
+	}
+
+	if err := verifySignature(tlog.publicKey, signedEntryTimestampPayload(entry, body, keyID), set); err != nil {
+		return time.Time{}, fmt.Errorf("%w: the signed entry timestamp is invalid", ErrUntrustedSignature)
+	}
+
+	if err := a.matchesLogEntry(body); err != nil {
+		return time.Time{}, err
+	}
+	return integratedTime, nil
+}
+
+// signedEntryTimestampPayload returns the canonical JSON the transparency log signs to promise the inclusion of an entry
+func signedEntryTimestampPayload(entry *TransparencyLogEntry, body, keyID []byte) []byte {
+	// the fields are in the lexical order of their names as required by RFC 8785
+	payload, _ := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: int64(entry.IntegratedTime),
+		LogID:          hex.EncodeToString(keyID),
+		LogIndex:       int64(entry.LogIndex),
+	})
+	return payload
+}
+
+// rekorHash is a digest in a Rekor entry
+type rekorHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// matchesLogEntry checks that a Rekor "dsse" or "intoto" entry records the payload and the certificate of the attestation
+func (a *Attestation) matchesLogEntry(body []byte) error {
+	var entry struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			// dsse entries
+			PayloadHash *rekorHash `json:"payloadHash"`
+			Signatures  []struct {
+				Verifier string `json:"verifier"`
+			} `json:"signatures"`
+			// intoto entries
+			Content *struct {
+				PayloadHash *rekorHash `json:"payloadHash"`
+				Envelope    struct {
+					Signatures []struct {
+						PublicKey string `json:"publicKey"`
+					} `json:"signatures"`
+				} `json:"envelope"`
+			} `json:"content"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAttestation, err)
+	}
+
+	var payloadHash *rekorHash
+	var verifiers []string
+	switch entry.Kind {
+	case "dsse":
+		payloadHash = entry.Spec.PayloadHash
+		for _, s := range entry.Spec.Signatures {
+			verifiers = append(verifiers, s.Verifier)
+		}
+	case "intoto":
+		if entry.Spec.Content != nil {
+			payloadHash = entry.Spec.Content.PayloadHash
+			for _, s := range entry.Spec.Content.Envelope.Signatures {
+				verifiers = append(verifiers, s.PublicKey)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown transparency log entry kind %q", ErrUnsupportedFormat, entry.Kind)
+	}
+
+	sum := sha256.Sum256(a.payload)
+	if payloadHash == nil || payloadHash.Algorithm != "sha256" || payloadHash.Value != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("%w: the transparency log entry is about another payload", ErrUntrustedSignature)
+	}
+
+	for _, verifier := range verifiers {
+		// the certificates are PEM encoded
+		raw, err := base64.StdEncoding.DecodeString(verifier)
+		if err != nil {
+			continue
+		}
+		if block, _ := pem.Decode(raw); block != nil && bytes.Equal(block.Bytes, a.certificate.Raw) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the transparency log entry is about another certificate", ErrUntrustedSignature)
+}
+
+func (tr *TrustedRoot) verifyCertificate(cert *x509.Certificate, at time.Time) error {
+	for _, ca := range tr.authorities {
+		if !ca.validFor.contains(at) {
+			continue
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         ca.roots,
+			Intermediates: ca.intermediates,
+			CurrentTime:   at,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the certificate is not issued by a trusted certificate authority", ErrUntrustedSignature)
+}
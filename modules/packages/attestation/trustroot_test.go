@@ -0,0 +1,232 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/modules/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSigstore is a certificate authority and a transparency log like Fulcio and Rekor
+type testSigstore struct {
+	caKey    *ecdsa.PrivateKey
+	caCert   *x509.Certificate
+	rekorKey *ecdsa.PrivateKey
+	logID    []byte
+}
+
+func newTestSigstore(t *testing.T) *testSigstore {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-fulcio"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	require.NoError(t, err)
+	logID := sha256.Sum256(rekorDER)
+
+	return &testSigstore{caKey: caKey, caCert: caCert, rekorKey: rekorKey, logID: logID[:]}
+}
+
+func (s *testSigstore) trustedRoot(t *testing.T) []byte {
+	rekorDER, err := x509.MarshalPKIXPublicKey(&s.rekorKey.PublicKey)
+	require.NoError(t, err)
+
+	content, err := json.Marshal(map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+		"tlogs": []map[string]any{{
+			"baseUrl":       "https://rekor.example.com",
+			"hashAlgorithm": "SHA2_256",
+			"publicKey": map[string]any{
+				"rawBytes":   base64.StdEncoding.EncodeToString(rekorDER),
+				"keyDetails": "PKIX_ECDSA_P256_SHA_256",
+				"validFor":   map[string]any{"start": time.Now().Add(-24 * time.Hour).Format(time.RFC3339)},
+			},
+			"logId": map[string]any{"keyId": base64.StdEncoding.EncodeToString(s.logID)},
+		}},
+		"certificateAuthorities": []map[string]any{{
+			"subject":   map[string]any{"commonName": "test-fulcio"},
+			"uri":       "https://fulcio.example.com",
+			"certChain": map[string]any{"certificates": []map[string]any{{"rawBytes": base64.StdEncoding.EncodeToString(s.caCert.Raw)}}},
+			"validFor":  map[string]any{"start": time.Now().Add(-24 * time.Hour).Format(time.RFC3339)},
+		}},
+	})
+	require.NoError(t, err)
+	return content
+}
+
+// issue creates a short lived signing certificate valid at the given time
+func (s *testSigstore) issue(t *testing.T, at time.Time) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	san, _ := url.Parse("https://example.com/owner/repo/.forgejo/workflows/release.yml@refs/heads/main")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(at.UnixNano()),
+		NotBefore:    at.Add(-time.Minute),
+		NotAfter:     at.Add(10 * time.Minute),
+		URIs:         []*url.URL{san},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, s.caCert, &key.PublicKey, s.caKey)
+	require.NoError(t, err)
+	return key, cert
+}
+
+// logEntry records a signature in the transparency log and returns the entry of a bundle
+func (s *testSigstore) logEntry(t *testing.T, cert []byte, payload string, at time.Time) map[string]any {
+	payloadHash := sha256.Sum256([]byte(payload))
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]any{
+			"payloadHash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+			"signatures": []map[string]any{{
+				"verifier": base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	entry := &TransparencyLogEntry{
+		LogIndex:       42,
+		IntegratedTime: protoInt64(at.Unix()),
+	}
+	digest := sha256.Sum256(signedEntryTimestampPayload(entry, body, s.logID))
+	set, err := ecdsa.SignASN1(rand.Reader, s.rekorKey, digest[:])
+	require.NoError(t, err)
+
+	return map[string]any{
+		"logIndex":          "42",
+		"logId":             map[string]any{"keyId": base64.StdEncoding.EncodeToString(s.logID)},
+		"kindVersion":       map[string]any{"kind": "dsse", "version": "0.0.1"},
+		"integratedTime":    strconv.FormatInt(at.Unix(), 10),
+		"inclusionPromise":  map[string]any{"signedEntryTimestamp": base64.StdEncoding.EncodeToString(set)},
+		"canonicalizedBody": base64.StdEncoding.EncodeToString(body),
+	}
+}
+
+func createLoggedBundle(t *testing.T, key *ecdsa.PrivateKey, cert []byte, statement string, entries ...map[string]any) []byte {
+	var bundle map[string]any
+	require.NoError(t, json.Unmarshal(createBundle(t, key, cert, statement), &bundle))
+	bundle["verificationMaterial"].(map[string]any)["tlogEntries"] = entries
+	content, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	return content
+}
+
+func TestTrustedRootVerify(t *testing.T) {
+	sigstore := newTestSigstore(t)
+	trustedRoot, err := ParseTrustedRoot(sigstore.trustedRoot(t))
+	require.NoError(t, err)
+
+	// the certificate has expired since it was used, the signature was recorded while it was valid
+	signedAt := time.Now().Add(-time.Hour)
+	key, cert := sigstore.issue(t, signedAt)
+
+	verify := func(t *testing.T, content []byte) error {
+		t.Helper()
+		a, err := Parse(content)
+		require.NoError(t, err)
+		return trustedRoot.Verify(a)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		require.NoError(t, verify(t, createLoggedBundle(t, key, cert, statementV1, sigstore.logEntry(t, cert, statementV1, signedAt))))
+	})
+
+	t.Run("NotLogged", func(t *testing.T) {
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1)), ErrUntrustedSignature)
+	})
+
+	t.Run("UntrustedCertificateAuthority", func(t *testing.T) {
+		other := newTestSigstore(t)
+		other.rekorKey, other.logID = sigstore.rekorKey, sigstore.logID
+		key, cert := other.issue(t, signedAt)
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, other.logEntry(t, cert, statementV1, signedAt))), ErrUntrustedSignature)
+	})
+
+	t.Run("UntrustedLog", func(t *testing.T) {
+		other := newTestSigstore(t)
+		other.caKey, other.caCert = sigstore.caKey, sigstore.caCert
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, other.logEntry(t, cert, statementV1, signedAt))), ErrUntrustedSignature)
+
+		// a log of another key cannot claim the id of the trusted log
+		other.logID = sigstore.logID
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, other.logEntry(t, cert, statementV1, signedAt))), ErrUntrustedSignature)
+	})
+
+	t.Run("TamperedIntegratedTime", func(t *testing.T) {
+		entry := sigstore.logEntry(t, cert, statementV1, signedAt)
+		entry["integratedTime"] = strconv.FormatInt(signedAt.Add(time.Minute).Unix(), 10)
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, entry)), ErrUntrustedSignature)
+	})
+
+	t.Run("CertificateNotValidWhenLogged", func(t *testing.T) {
+		entry := sigstore.logEntry(t, cert, statementV1, time.Now())
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, entry)), ErrUntrustedSignature)
+	})
+
+	t.Run("EntryOfAnotherPayload", func(t *testing.T) {
+		entry := sigstore.logEntry(t, cert, "another payload", signedAt)
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, entry)), ErrUntrustedSignature)
+	})
+
+	t.Run("EntryOfAnotherCertificate", func(t *testing.T) {
+		_, otherCert := sigstore.issue(t, signedAt)
+		entry := sigstore.logEntry(t, otherCert, statementV1, signedAt)
+		require.ErrorIs(t, verify(t, createLoggedBundle(t, key, cert, statementV1, entry)), ErrUntrustedSignature)
+	})
+
+	t.Run("Statement", func(t *testing.T) {
+		require.ErrorIs(t, verify(t, []byte(statementV1)), ErrUntrustedSignature)
+	})
+}
+
+func TestParseTrustedRoot(t *testing.T) {
+	_, err := ParseTrustedRoot(newTestSigstore(t).trustedRoot(t))
+	require.NoError(t, err)
+
+	for _, content := range []string{
+		`invalid`,
+		`{}`,
+		`{"certificateAuthorities": [{"certChain": {"certificates": []}}]}`,
+		`{"certificateAuthorities": [{"certChain": {"certificates": [{"rawBytes": "AAAA"}]}}]}`,
+		`{"tlogs": [{"publicKey": {"rawBytes": "AAAA"}}]}`,
+	} {
+		_, err := ParseTrustedRoot([]byte(content))
+		assert.ErrorIs(t, err, ErrInvalidTrustedRoot, content)
+	}
+}
@@ -16,11 +16,14 @@ import (
 )
 
 const (
-	PropertyRepository        = "container.repository"
-	PropertyDigest            = "container.digest"
-	PropertyMediaType         = "container.mediatype"
-	PropertyManifestTagged    = "container.manifest.tagged"
-	PropertyManifestReference = "container.manifest.reference"
+	PropertyRepository           = "container.repository"
+	PropertyDigest               = "container.digest"
+	PropertyMediaType            = "container.mediatype"
+	PropertyManifestTagged       = "container.manifest.tagged"
+	PropertyManifestReference    = "container.manifest.reference"
+	PropertyManifestSubject      = "container.manifest.subject"
+	PropertyManifestArtifactType = "container.manifest.artifact_type"
+	PropertyManifestAnnotations  = "container.manifest.annotations"
 
 	DefaultPlatform = "linux/amd64"
 
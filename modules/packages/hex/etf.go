@@ -0,0 +1,290 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The Hex API speaks the Erlang external term format, the client decodes it with binary_to_term/2
+// https://www.erlang.org/doc/apps/erts/erl_ext_dist.html
+
+// ContentTypeTerm is the content type of the Erlang external term format
+const ContentTypeTerm = "application/vnd.hex+erlang"
+
+const (
+	etfVersion       = 131
+	etfSmallInteger  = 97
+	etfInteger       = 98
+	etfSmallTuple    = 104
+	etfNil           = 106
+	etfString        = 107
+	etfList          = 108
+	etfBinary        = 109
+	etfSmallBig      = 110
+	etfMap           = 116
+	etfAtomUTF8      = 118
+	etfSmallAtomUTF8 = 119
+	etfAtom          = 100
+	etfSmallAtom     = 115
+
+	maxTermDepth = 32
+)
+
+var errInvalidTerm = errors.New("invalid external term")
+
+// MarshalTerm encodes the value in the external term format.
+// Strings are encoded as binaries, nil as the atom nil and maps with sorted keys.
+func MarshalTerm(v any) ([]byte, error) {
+	return appendTerm([]byte{etfVersion}, v)
+}
+
+func appendTerm(b []byte, v any) ([]byte, error) {
+	var err error
+	switch t := v.(type) {
+	case nil:
+		return appendAtom(b, "nil"), nil
+	case bool:
+		if t {
+			return appendAtom(b, "true"), nil
+		}
+		return appendAtom(b, "false"), nil
+	case Atom:
+		return appendAtom(b, string(t)), nil
+	case string:
+		b = append(b, etfBinary)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		return append(b, t...), nil
+	case int:
+		return appendInteger(b, int64(t)), nil
+	case int64:
+		return appendInteger(b, t), nil
+	case []string:
+		l := make([]any, 0, len(t))
+		for _, s := range t {
+			l = append(l, s)
+		}
+		return appendTerm(b, l)
+	case []any:
+		if len(t) == 0 {
+			return append(b, etfNil), nil
+		}
+		b = append(b, etfList)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		for _, e := range t {
+			if b, err = appendTerm(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, etfNil), nil
+	case map[string]string:
+		m := make(map[string]any, len(t))
+		for k, v := range t {
+			m[k] = v
+		}
+		return appendTerm(b, m)
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = append(b, etfMap)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		for _, k := range keys {
+			if b, err = appendTerm(b, k); err != nil {
+				return nil, err
+			}
+			if b, err = appendTerm(b, t[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unsupported term type %T", v)
+}
+
+func appendAtom(b []byte, name string) []byte {
+	b = append(b, etfSmallAtomUTF8, byte(len(name)))
+	return append(b, name...)
+}
+
+func appendInteger(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= math.MaxUint8:
+		return append(b, etfSmallInteger, byte(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		b = append(b, etfInteger)
+		return binary.BigEndian.AppendUint32(b, uint32(int32(n)))
+	}
+
+	sign := byte(0)
+	u := uint64(n)
+	if n < 0 {
+		sign = 1
+		u = uint64(-n)
+	}
+	digits := make([]byte, 0, 8)
+	for ; u > 0; u >>= 8 {
+		digits = append(digits, byte(u))
+	}
+	b = append(b, etfSmallBig, byte(len(digits)), sign)
+	return append(b, digits...)
+}
+
+// UnmarshalTerm decodes a value in the external term format. Binaries are returned as string,
+// atoms as Atom, lists as []any, tuples as Tuple and maps with binary or atom keys as map[string]any.
+func UnmarshalTerm(data []byte) (any, error) {
+	if len(data) == 0 || data[0] != etfVersion {
+		return nil, errInvalidTerm
+	}
+	v, rest, err := decodeTerm(data[1:], 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errInvalidTerm
+	}
+	return v, nil
+}
+
+func decodeTerm(b []byte, depth int) (any, []byte, error) {
+	if len(b) == 0 || depth > maxTermDepth {
+		return nil, nil, errInvalidTerm
+	}
+
+	tag, b := b[0], b[1:]
+	switch tag {
+	case etfSmallInteger:
+		if len(b) < 1 {
+			return nil, nil, errInvalidTerm
+		}
+		return int64(b[0]), b[1:], nil
+	case etfInteger:
+		if len(b) < 4 {
+			return nil, nil, errInvalidTerm
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), b[4:], nil
+	case etfSmallBig:
+		if len(b) < 2 || b[0] > 8 || len(b) < 2+int(b[0]) {
+			return nil, nil, errInvalidTerm
+		}
+		var u uint64
+		for i := int(b[0]) - 1; i >= 0; i-- {
+			u = u<<8 | uint64(b[2+i])
+		}
+		if u > math.MaxInt64 {
+			return nil, nil, errInvalidTerm
+		}
+		n := int64(u)
+		if b[1] == 1 {
+			n = -n
+		}
+		return n, b[2+int(b[0]):], nil
+	case etfSmallAtomUTF8, etfSmallAtom:
+		if len(b) < 1 || len(b) < 1+int(b[0]) {
+			return nil, nil, errInvalidTerm
+		}
+		return Atom(b[1 : 1+int(b[0])]), b[1+int(b[0]):], nil
+	case etfAtomUTF8, etfAtom:
+		if len(b) < 2 {
+			return nil, nil, errInvalidTerm
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n {
+			return nil, nil, errInvalidTerm
+		}
+		return Atom(b[2 : 2+n]), b[2+n:], nil
+	case etfBinary, etfString:
+		var n int
+		if tag == etfBinary {
+			if len(b) < 4 {
+				return nil, nil, errInvalidTerm
+			}
+			n, b = int(binary.BigEndian.Uint32(b)), b[4:]
+		} else {
+			if len(b) < 2 {
+				return nil, nil, errInvalidTerm
+			}
+			n, b = int(binary.BigEndian.Uint16(b)), b[2:]
+		}
+		if n < 0 || len(b) < n {
+			return nil, nil, errInvalidTerm
+		}
+		return string(b[:n]), b[n:], nil
+	case etfNil:
+		return []any{}, b, nil
+	case etfSmallTuple:
+		if len(b) < 1 {
+			return nil, nil, errInvalidTerm
+		}
+		n := int(b[0])
+		b = b[1:]
+		t := make(Tuple, 0, n)
+		for i := 0; i < n; i++ {
+			var e any
+			var err error
+			if e, b, err = decodeTerm(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			t = append(t, e)
+		}
+		return t, b, nil
+	case etfList:
+		if len(b) < 4 {
+			return nil, nil, errInvalidTerm
+		}
+		n := int(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		if n < 0 || n > len(b) {
+			return nil, nil, errInvalidTerm
+		}
+		l := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			var e any
+			var err error
+			if e, b, err = decodeTerm(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			l = append(l, e)
+		}
+		// Only proper lists are supported
+		if len(b) < 1 || b[0] != etfNil {
+			return nil, nil, errInvalidTerm
+		}
+		return l, b[1:], nil
+	case etfMap:
+		if len(b) < 4 {
+			return nil, nil, errInvalidTerm
+		}
+		n := int(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		if n < 0 || n > len(b) {
+			return nil, nil, errInvalidTerm
+		}
+		m := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			var k, v any
+			var err error
+			if k, b, err = decodeTerm(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			if v, b, err = decodeTerm(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			key := termString(k)
+			if key == "" {
+				return nil, nil, errInvalidTerm
+			}
+			m[key] = v
+		}
+		return m, b, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported external term tag %d", tag)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalTerm(t *testing.T) {
+	data, err := MarshalTerm(map[string]any{"ok": true, "n": 1})
+	require.NoError(t, err)
+	// #{<<"n">> => 1, <<"ok">> => true}
+	assert.Equal(t, []byte{131, 116, 0, 0, 0, 2, 109, 0, 0, 0, 1, 'n', 97, 1, 109, 0, 0, 0, 2, 'o', 'k', 119, 4, 't', 'r', 'u', 'e'}, data)
+
+	value := map[string]any{
+		"name":     "test",
+		"versions": []any{"1.0.0", "1.0.1"},
+		"empty":    []any{},
+		"missing":  nil,
+		"count":    int64(-3),
+		"large":    int64(1 << 40),
+		"meta":     map[string]any{"licenses": []any{"MIT"}},
+	}
+
+	data, err = MarshalTerm(value)
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalTerm(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"name":     "test",
+		"versions": []any{"1.0.0", "1.0.1"},
+		"empty":    []any{},
+		"missing":  Atom("nil"),
+		"count":    int64(-3),
+		"large":    int64(1 << 40),
+		"meta":     map[string]any{"licenses": []any{"MIT"}},
+	}, decoded)
+}
+
+func TestUnmarshalTerm(t *testing.T) {
+	// #{reason => <<"security">>, <<"message">> => "msg"} with a tuple
+	data := []byte{131, 116, 0, 0, 0, 3,
+		119, 6, 'r', 'e', 'a', 's', 'o', 'n', 109, 0, 0, 0, 8, 's', 'e', 'c', 'u', 'r', 'i', 't', 'y',
+		109, 0, 0, 0, 7, 'm', 'e', 's', 's', 'a', 'g', 'e', 107, 0, 3, 'm', 's', 'g',
+		119, 1, 't', 104, 2, 97, 1, 106,
+	}
+	v, err := UnmarshalTerm(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"reason": "security", "message": "msg", "t": Tuple{int64(1), []any{}}}, v)
+
+	for _, invalid := range [][]byte{
+		{},
+		{130, 106},
+		{131, 109, 0, 0, 0, 5, 'a'},
+		{131, 108, 0, 0, 0, 1, 97, 1, 97, 2},
+		{131, 106, 106},
+	} {
+		_, err := UnmarshalTerm(invalid)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/validation"
+
+	"github.com/hashicorp/go-version"
+)
+
+const (
+	PropertyRetirement = "hex.retirement"
+
+	SettingKeyPrivate = "hex.key.private"
+	SettingKeyPublic  = "hex.key.public"
+
+	tarballVersion = "3"
+
+	maxMetadataSize = 1 << 20
+)
+
+var (
+	ErrMissingFile       = util.NewInvalidArgumentErrorf("tarball is missing a required file")
+	ErrUnsupportedFormat = util.NewInvalidArgumentErrorf("tarball format version is not supported")
+	ErrInvalidChecksum   = util.NewInvalidArgumentErrorf("tarball checksum is invalid")
+	ErrInvalidMetadata   = util.NewInvalidArgumentErrorf("package metadata is invalid")
+	ErrInvalidName       = util.NewInvalidArgumentErrorf("package name is invalid")
+	ErrInvalidVersion    = util.NewInvalidArgumentErrorf("package version is invalid")
+)
+
+// https://hex.pm/docs/publish#package-names
+var namePattern = regexp.MustCompile(`\A[a-z][a-z0-9_]{0,127}\z`)
+
+// RetirementReasons lists the reasons accepted when retiring a release, in the order of the registry enum
+var RetirementReasons = []string{"other", "invalid", "security", "deprecated", "renamed"}
+
+// Package represents a Hex package
+type Package struct {
+	Name     string
+	Version  string
+	Metadata *Metadata
+}
+
+// Metadata represents the metadata of a Hex package
+type Metadata struct {
+	App           string            `json:"app,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Licenses      []string          `json:"licenses,omitempty"`
+	Links         map[string]string `json:"links,omitempty"`
+	BuildTools    []string          `json:"build_tools,omitempty"`
+	Elixir        string            `json:"elixir,omitempty"`
+	Requirements  []*Requirement    `json:"requirements,omitempty"`
+	InnerChecksum string            `json:"inner_checksum"`
+}
+
+// Requirement represents a dependency of a Hex package
+type Requirement struct {
+	Name        string `json:"name"`
+	App         string `json:"app,omitempty"`
+	Requirement string `json:"requirement"`
+	Optional    bool   `json:"optional,omitempty"`
+	Repository  string `json:"repository,omitempty"`
+}
+
+// Retirement represents the retirement status of a release
+type Retirement struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+}
+
+// IsValidName checks if the name is a valid package name
+func IsValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// ParsePackage parses the outer tarball of a package
+// https://github.com/hexpm/specifications/blob/main/package_tarball.md
+func ParsePackage(r io.Reader) (*Package, error) {
+	tr := tar.NewReader(r)
+
+	var formatVersion, checksum, metadata []byte
+	var innerChecksum string
+
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hd.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		switch hd.Name {
+		case "VERSION":
+			formatVersion, err = io.ReadAll(io.LimitReader(tr, 16))
+		case "CHECKSUM":
+			checksum, err = io.ReadAll(io.LimitReader(tr, 128))
+		case "metadata.config":
+			if hd.Size > maxMetadataSize {
+				return nil, ErrInvalidMetadata
+			}
+			metadata, err = io.ReadAll(tr)
+		case "contents.tar.gz":
+			// The inner checksum covers the files in this order, which is the one the tarballs are built with
+			if formatVersion == nil || metadata == nil {
+				return nil, ErrInvalidChecksum
+			}
+			h := sha256.New()
+			h.Write(formatVersion)
+			h.Write(metadata)
+			if _, err := io.Copy(h, tr); err != nil {
+				return nil, err
+			}
+			innerChecksum = hex.EncodeToString(h.Sum(nil))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if formatVersion == nil || metadata == nil || innerChecksum == "" {
+		return nil, ErrMissingFile
+	}
+	if string(bytes.TrimSpace(formatVersion)) != tarballVersion {
+		return nil, ErrUnsupportedFormat
+	}
+	if checksum != nil && !strings.EqualFold(string(bytes.TrimSpace(checksum)), innerChecksum) {
+		return nil, ErrInvalidChecksum
+	}
+
+	p, err := ParseMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	p.Metadata.InnerChecksum = innerChecksum
+	return p, nil
+}
+
+// ParseMetadata parses the metadata.config file of a package
+// https://github.com/hexpm/specifications/blob/main/package_metadata.md
+func ParseMetadata(data []byte) (*Package, error) {
+	terms, err := ParseTerms(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+
+	props := make(map[string]any, len(terms))
+	for _, term := range terms {
+		t, ok := term.(Tuple)
+		if !ok || len(t) != 2 {
+			return nil, ErrInvalidMetadata
+		}
+		key, ok := t[0].(string)
+		if !ok {
+			return nil, ErrInvalidMetadata
+		}
+		props[key] = t[1]
+	}
+
+	p := &Package{
+		Name:    termString(props["name"]),
+		Version: termString(props["version"]),
+		Metadata: &Metadata{
+			App:         termString(props["app"]),
+			Description: termString(props["description"]),
+			Licenses:    termStrings(props["licenses"]),
+			BuildTools:  termStrings(props["build_tools"]),
+			Elixir:      termString(props["elixir"]),
+		},
+	}
+
+	if !IsValidName(p.Name) {
+		return nil, ErrInvalidName
+	}
+	if v, err := version.NewSemver(p.Version); err != nil || v.String() != p.Version {
+		return nil, ErrInvalidVersion
+	}
+	if p.Metadata.App == "" {
+		p.Metadata.App = p.Name
+	}
+
+	if links := termProps(props["links"]); len(links) > 0 {
+		p.Metadata.Links = make(map[string]string, len(links))
+		for name, link := range links {
+			if l := termString(link); validation.IsValidURL(l) {
+				p.Metadata.Links[name] = l
+			}
+		}
+	}
+
+	p.Metadata.Requirements, err = parseRequirements(props["requirements"])
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// parseRequirements supports the list of proplists with a name of the older tarballs
+// and the map from name to properties of the newer ones
+func parseRequirements(v any) ([]*Requirement, error) {
+	var entries []any
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		entries = t
+	case map[string]any:
+		for name, props := range t {
+			entries = append(entries, Tuple{name, props})
+		}
+	default:
+		return nil, ErrInvalidMetadata
+	}
+
+	requirements := make([]*Requirement, 0, len(entries))
+	for _, entry := range entries {
+		var name string
+		var props map[string]any
+		if t, ok := entry.(Tuple); ok && len(t) == 2 {
+			name = termString(t[0])
+			props = termProps(t[1])
+		} else {
+			props = termProps(entry)
+			name = termString(props["name"])
+		}
+
+		r := &Requirement{
+			Name:        name,
+			App:         termString(props["app"]),
+			Requirement: termString(props["requirement"]),
+			Optional:    props["optional"] == Atom("true"),
+			Repository:  termString(props["repository"]),
+		}
+		if !IsValidName(r.Name) || r.Requirement == "" {
+			return nil, fmt.Errorf("%w: invalid requirement %q", ErrInvalidMetadata, r.Name)
+		}
+		if r.App == r.Name {
+			r.App = ""
+		}
+		requirements = append(requirements, r)
+	}
+	sort.Slice(requirements, func(i, j int) bool {
+		return requirements[i].Name < requirements[j].Name
+	})
+	return requirements, nil
+}
+
+func termString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case Atom:
+		if t == "nil" {
+			return ""
+		}
+		return string(t)
+	}
+	return ""
+}
+
+func termStrings(v any) []string {
+	l, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	s := make([]string, 0, len(l))
+	for _, e := range l {
+		if str := termString(e); str != "" {
+			s = append(s, str)
+		}
+	}
+	return s
+}
+
+// termProps converts a map or a list of key-value tuples
+func termProps(v any) map[string]any {
+	switch t := v.(type) {
+	case map[string]any:
+		return t
+	case []any:
+		props := make(map[string]any, len(t))
+		for _, e := range t {
+			if kv, ok := e.(Tuple); ok && len(kv) == 2 {
+				props[termString(kv[0])] = kv[1]
+			}
+		}
+		return props
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	packageName        = "test_package"
+	packageVersion     = "1.0.1"
+	packageDescription = "Test Description"
+)
+
+const metadataConfig = `{<<"app">>,<<"test_app">>}.
+{<<"build_tools">>,[<<"mix">>]}.
+{<<"description">>,<<"Test Description">>}.
+{<<"elixir">>,<<"~> 1.15">>}.
+{<<"files">>,[<<"lib">>,<<"lib/test.ex">>,<<"mix.exs">>]}.
+{<<"licenses">>,[<<"MIT">>]}.
+{<<"links">>,[{<<"Forgejo">>,<<"https://forgejo.org/">>},{<<"Invalid">>,<<"not a link">>}]}.
+{<<"name">>,<<"test_package">>}.
+{<<"requirements">>,
+ [[{<<"name">>,<<"jason">>},
+   {<<"app">>,<<"jason">>},
+   {<<"optional">>,false},
+   {<<"requirement">>,<<"~> 1.4">>},
+   {<<"repository">>,<<"hexpm">>}],
+  [{<<"name">>,<<"dep">>},
+   {<<"app">>,<<"dep_app">>},
+   {<<"optional">>,true},
+   {<<"requirement">>,<<">= 0.0.0">>},
+   {<<"repository">>,<<"user2">>}]]}.
+{<<"version">>,<<"1.0.1">>}.
+`
+
+func createTarball(t *testing.T, files map[string]string, order ...string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range order {
+		content := files[name]
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o600,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func createPackage(t *testing.T, metadata string) []byte {
+	contents := "contents"
+
+	h := sha256.Sum256([]byte("3" + metadata + contents))
+
+	return createTarball(t, map[string]string{
+		"VERSION":         "3",
+		"CHECKSUM":        strings.ToUpper(hex.EncodeToString(h[:])),
+		"metadata.config": metadata,
+		"contents.tar.gz": contents,
+	}, "VERSION", "CHECKSUM", "metadata.config", "contents.tar.gz")
+}
+
+func TestParsePackage(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		p, err := ParsePackage(bytes.NewReader(createPackage(t, metadataConfig)))
+		require.NoError(t, err)
+		require.NotNil(t, p)
+
+		assert.Equal(t, packageName, p.Name)
+		assert.Equal(t, packageVersion, p.Version)
+		assert.Equal(t, "test_app", p.Metadata.App)
+		assert.Equal(t, packageDescription, p.Metadata.Description)
+		assert.Equal(t, []string{"MIT"}, p.Metadata.Licenses)
+		assert.Equal(t, []string{"mix"}, p.Metadata.BuildTools)
+		assert.Equal(t, "~> 1.15", p.Metadata.Elixir)
+		assert.Equal(t, map[string]string{"Forgejo": "https://forgejo.org/"}, p.Metadata.Links)
+
+		h := sha256.Sum256([]byte("3" + metadataConfig + "contents"))
+		assert.Equal(t, hex.EncodeToString(h[:]), p.Metadata.InnerChecksum)
+
+		require.Len(t, p.Metadata.Requirements, 2)
+		assert.Equal(t, &Requirement{Name: "dep", App: "dep_app", Requirement: ">= 0.0.0", Optional: true, Repository: "user2"}, p.Metadata.Requirements[0])
+		assert.Equal(t, &Requirement{Name: "jason", Requirement: "~> 1.4", Repository: "hexpm"}, p.Metadata.Requirements[1])
+	})
+
+	t.Run("RequirementsMap", func(t *testing.T) {
+		metadata := `{<<"name">>,<<"test_package">>}.
+{<<"version">>,<<"1.0.1">>}.
+{<<"requirements">>,[{<<"jason">>,[{<<"app">>,<<"jason">>},{<<"optional">>,false},{<<"requirement">>,<<"~> 1.4">>}]}]}.
+`
+		p, err := ParsePackage(bytes.NewReader(createPackage(t, metadata)))
+		require.NoError(t, err)
+		assert.Equal(t, packageName, p.Metadata.App)
+		require.Len(t, p.Metadata.Requirements, 1)
+		assert.Equal(t, &Requirement{Name: "jason", Requirement: "~> 1.4"}, p.Metadata.Requirements[0])
+	})
+
+	t.Run("InvalidChecksum", func(t *testing.T) {
+		data := createTarball(t, map[string]string{
+			"VERSION":         "3",
+			"CHECKSUM":        strings.Repeat("A", 64),
+			"metadata.config": metadataConfig,
+			"contents.tar.gz": "contents",
+		}, "VERSION", "CHECKSUM", "metadata.config", "contents.tar.gz")
+
+		p, err := ParsePackage(bytes.NewReader(data))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrInvalidChecksum)
+	})
+
+	t.Run("UnsupportedFormat", func(t *testing.T) {
+		data := createTarball(t, map[string]string{
+			"VERSION":         "2",
+			"metadata.config": metadataConfig,
+			"contents.tar.gz": "contents",
+		}, "VERSION", "metadata.config", "contents.tar.gz")
+
+		p, err := ParsePackage(bytes.NewReader(data))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		data := createTarball(t, map[string]string{
+			"VERSION":         "3",
+			"metadata.config": metadataConfig,
+		}, "VERSION", "metadata.config")
+
+		p, err := ParsePackage(bytes.NewReader(data))
+		assert.Nil(t, p)
+		assert.ErrorIs(t, err, ErrMissingFile)
+	})
+
+	t.Run("InvalidNameOrVersion", func(t *testing.T) {
+		for metadata, expected := range map[string]error{
+			`{<<"name">>,<<"Test">>}. {<<"version">>,<<"1.0.1">>}.`:        ErrInvalidName,
+			`{<<"name">>,<<"test">>}. {<<"version">>,<<"1.0">>}.`:          ErrInvalidVersion,
+			`{<<"name">>,<<"test">>}. {<<"version">>,<<"1.0.1">>}`:         ErrInvalidMetadata,
+			`{<<"name">>,<<"test">>}. {<<"version">>,<<"1.0.1">>}. [1,2].`: ErrInvalidMetadata,
+		} {
+			p, err := ParsePackage(bytes.NewReader(createPackage(t, metadata)))
+			assert.Nil(t, p)
+			assert.ErrorIs(t, err, expected, metadata)
+		}
+	})
+}
+
+func TestParseTerms(t *testing.T) {
+	terms, err := ParseTerms([]byte(`% comment
+{<<"a">>, <<"ü"/utf8>>, "str\"ing", 'quoted atom', atom, -42, [], #{<<"k">> => [1, 2]}, <<104,105>>}.
+`))
+	require.NoError(t, err)
+	require.Len(t, terms, 1)
+	assert.Equal(t, Tuple{"a", "ü", `str"ing`, Atom("quoted atom"), Atom("atom"), int64(-42), []any{}, map[string]any{"k": []any{int64(1), int64(2)}}, "hi"}, terms[0])
+
+	for _, invalid := range []string{`{1,2`, `<<"a">`, `"unterminated`, `#{1 => 2}.`, `{a b}.`} {
+		_, err := ParseTerms([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The registry resources are gzipped protobuf messages wrapped in a signed message
+// https://github.com/hexpm/specifications/blob/main/registry-v2.md
+
+// NamesPackage is an entry of the /names resource
+type NamesPackage struct {
+	Name      string
+	UpdatedAt time.Time
+}
+
+// VersionsPackage is an entry of the /versions resource
+type VersionsPackage struct {
+	Name     string
+	Versions []string
+	// Retired contains the indexes of the retired versions
+	Retired []int32
+}
+
+// Release is an entry of the /packages/<name> resource
+type Release struct {
+	Version       string
+	InnerChecksum []byte
+	OuterChecksum []byte
+	Dependencies  []*Requirement
+	Retirement    *Retirement
+}
+
+// EncodeNames encodes the names of the packages of the repository
+func EncodeNames(repository string, packages []*NamesPackage) []byte {
+	var b []byte
+	for _, p := range packages {
+		var pb []byte
+		pb = appendString(pb, 1, p.Name)
+		if !p.UpdatedAt.IsZero() {
+			var ts []byte
+			ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(p.UpdatedAt.Unix()))
+			pb = appendBytes(pb, 2, ts)
+		}
+		b = appendBytes(b, 1, pb)
+	}
+	return appendString(b, 2, repository)
+}
+
+// EncodeVersions encodes the versions of the packages of the repository
+func EncodeVersions(repository string, packages []*VersionsPackage) []byte {
+	var b []byte
+	for _, p := range packages {
+		var pb []byte
+		pb = appendString(pb, 1, p.Name)
+		for _, v := range p.Versions {
+			pb = appendString(pb, 2, v)
+		}
+		if len(p.Retired) > 0 {
+			var packed []byte
+			for _, i := range p.Retired {
+				packed = protowire.AppendVarint(packed, uint64(i))
+			}
+			pb = appendBytes(pb, 3, packed)
+		}
+		b = appendBytes(b, 1, pb)
+	}
+	return appendString(b, 2, repository)
+}
+
+// EncodePackage encodes the releases of a package of the repository.
+// The repository of dependencies in the same repository is omitted.
+func EncodePackage(repository, name string, releases []*Release) []byte {
+	var b []byte
+	for _, r := range releases {
+		var rb []byte
+		rb = appendString(rb, 1, r.Version)
+		rb = appendBytes(rb, 2, r.InnerChecksum)
+		for _, d := range r.Dependencies {
+			var db []byte
+			db = appendString(db, 1, d.Name)
+			db = appendString(db, 2, d.Requirement)
+			if d.Optional {
+				db = protowire.AppendTag(db, 3, protowire.VarintType)
+				db = protowire.AppendVarint(db, 1)
+			}
+			if d.App != "" {
+				db = appendString(db, 4, d.App)
+			}
+			if d.Repository != "" && d.Repository != repository {
+				db = appendString(db, 5, d.Repository)
+			}
+			rb = appendBytes(rb, 3, db)
+		}
+		if r.Retirement != nil {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(max(slices.Index(RetirementReasons, r.Retirement.Reason), 0)))
+			if r.Retirement.Message != "" {
+				sb = appendString(sb, 2, r.Retirement.Message)
+			}
+			rb = appendBytes(rb, 4, sb)
+		}
+		if len(r.OuterChecksum) > 0 {
+			rb = appendBytes(rb, 5, r.OuterChecksum)
+		}
+		b = appendBytes(b, 1, rb)
+	}
+	b = appendString(b, 2, name)
+	return appendString(b, 3, repository)
+}
+
+// EncodeSigned wraps the payload with its signature and compresses the result
+func EncodeSigned(payload, signature []byte) ([]byte, error) {
+	var b []byte
+	b = appendBytes(b, 1, payload)
+	b = appendBytes(b, 2, signature)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
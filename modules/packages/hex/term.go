@@ -0,0 +1,300 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Atom is an Erlang atom
+type Atom string
+
+// Tuple is an Erlang tuple
+type Tuple []any
+
+// termParser reads Erlang terms in the text format of file:consult/1, which is used by metadata.config.
+// Binaries and strings are returned as string, lists as []any and maps as map[string]any.
+type termParser struct {
+	data []byte
+	pos  int
+}
+
+// ParseTerms parses the dot terminated terms of the text
+func ParseTerms(data []byte) ([]any, error) {
+	p := &termParser{data: data}
+
+	terms := make([]any, 0, 16)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return terms, nil
+		}
+
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect('.'); err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+}
+
+func (p *termParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid term at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *termParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *termParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *termParser) consume(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(string(p.data[p.pos:]), s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *termParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *termParser) parseTerm() (any, error) {
+	switch c := p.peek(); {
+	case c == '{':
+		p.pos++
+		elements, err := p.parseSequence('}')
+		if err != nil {
+			return nil, err
+		}
+		return Tuple(elements), nil
+	case c == '[':
+		p.pos++
+		return p.parseSequence(']')
+	case c == '#':
+		return p.parseMap()
+	case c == '<':
+		return p.parseBinary()
+	case c == '"':
+		return p.parseString('"')
+	case c == '\'':
+		s, err := p.parseString('\'')
+		if err != nil {
+			return nil, err
+		}
+		return Atom(s), nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseInteger()
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.data) && isAtomChar(p.data[p.pos]) {
+			p.pos++
+		}
+		return Atom(p.data[start:p.pos]), nil
+	case c == 0:
+		return nil, p.errorf("unexpected end")
+	default:
+		return nil, p.errorf("unexpected character %q", c)
+	}
+}
+
+func isAtomChar(c byte) bool {
+	return c == '_' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *termParser) parseSequence(end byte) ([]any, error) {
+	elements := make([]any, 0, 4)
+	if p.peek() == end {
+		p.pos++
+		return elements, nil
+	}
+	for {
+		element, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case end:
+			p.pos++
+			return elements, nil
+		default:
+			return nil, p.errorf("expected ',' or %q", end)
+		}
+	}
+}
+
+func (p *termParser) parseMap() (any, error) {
+	if !p.consume("#{") {
+		return nil, p.errorf("expected map")
+	}
+
+	m := make(map[string]any)
+	if p.peek() == '}' {
+		p.pos++
+		return m, nil
+	}
+	for {
+		key, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume("=>") {
+			return nil, p.errorf("expected '=>'")
+		}
+		value, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		switch k := key.(type) {
+		case string:
+			m[k] = value
+		case Atom:
+			m[string(k)] = value
+		default:
+			return nil, p.errorf("unsupported map key")
+		}
+
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return m, nil
+		default:
+			return nil, p.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+// parseBinary parses a binary built from string and byte segments, for example <<"name"/utf8>>
+func (p *termParser) parseBinary() (any, error) {
+	if !p.consume("<<") {
+		return nil, p.errorf("expected binary")
+	}
+	if p.consume(">>") {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	for {
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseString('"')
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(s)
+		case c >= '0' && c <= '9':
+			n, err := p.parseInteger()
+			if err != nil {
+				return nil, err
+			}
+			if n.(int64) > 255 {
+				return nil, p.errorf("binary segment out of range")
+			}
+			sb.WriteByte(byte(n.(int64)))
+		default:
+			return nil, p.errorf("unsupported binary segment")
+		}
+
+		if p.consume("/") {
+			for p.pos < len(p.data) && isAtomChar(p.data[p.pos]) {
+				p.pos++
+			}
+		}
+
+		if p.consume(">>") {
+			return sb.String(), nil
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *termParser) parseString(quote byte) (string, error) {
+	p.pos++ // opening quote
+
+	var sb strings.Builder
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.pos >= len(p.data) {
+				return "", p.errorf("unterminated string")
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 's':
+				sb.WriteByte(' ')
+			case 'e':
+				sb.WriteByte(0x1b)
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *termParser) parseInteger() (any, error) {
+	start := p.pos
+	if p.data[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.ParseInt(string(p.data[start:p.pos]), 10, 64)
+	if err != nil {
+		return nil, p.errorf("invalid integer")
+	}
+	return n, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package nix
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Nix uses its own base32 alphabet which omits the letters e, o, t and u
+// https://github.com/NixOS/nix/blob/master/src/libutil/hash.cc
+const nix32Alphabet = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// EncodeNix32 encodes the data with the Nix base32 alphabet
+func EncodeNix32(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	n := (len(data)*8-1)/5 + 1
+	var sb strings.Builder
+	sb.Grow(n)
+	for i := n - 1; i >= 0; i-- {
+		b := i * 5
+		j, k := b/8, uint(b%8)
+		c := data[j] >> k
+		if j+1 < len(data) {
+			c |= data[j+1] << (8 - k)
+		}
+		sb.WriteByte(nix32Alphabet[c&0x1f])
+	}
+	return sb.String()
+}
+
+// DecodeNix32 decodes a Nix base32 string into size bytes
+func DecodeNix32(s string, size int) ([]byte, bool) {
+	if len(s) != (size*8-1)/5+1 {
+		return nil, false
+	}
+
+	data := make([]byte, size)
+	for n := 0; n < len(s); n++ {
+		digit := strings.IndexByte(nix32Alphabet, s[len(s)-n-1])
+		if digit < 0 {
+			return nil, false
+		}
+		b := n * 5
+		i, j := b/8, uint(b%8)
+		data[i] |= byte(digit << j)
+		if carry := byte(digit >> (8 - j)); i+1 < size {
+			data[i+1] |= carry
+		} else if carry != 0 {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// NormalizeHash converts a SHA256 hash in the base16, Nix base32 or SRI form to sha256:<nix32>
+func NormalizeHash(s string) (string, error) {
+	var data []byte
+	if value, ok := strings.CutPrefix(s, "sha256-"); ok {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(value); err != nil || len(data) != sha256.Size {
+			return "", ErrInvalidHash
+		}
+	} else if value, ok := strings.CutPrefix(s, "sha256:"); ok {
+		switch len(value) {
+		case sha256.Size * 2:
+			var err error
+			if data, err = hex.DecodeString(value); err != nil {
+				return "", ErrInvalidHash
+			}
+		default:
+			if data, ok = DecodeNix32(value, sha256.Size); !ok {
+				return "", ErrInvalidHash
+			}
+		}
+	} else {
+		return "", ErrInvalidHash
+	}
+	return "sha256:" + EncodeNix32(data), nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package nix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"code.gitea.io/gitea/modules/util"
+)
+
+const (
+	SettingKeyPrivate = "nix.key.private"
+	SettingKeyPublic  = "nix.key.public"
+
+	// UploadPackage and UploadVersion name the internal package which holds NAR files until their narinfo is uploaded
+	UploadPackage = "_upload"
+	UploadVersion = "_nar"
+
+	StoreDir = "/nix/store"
+
+	maxNarInfoSize = 1 << 20
+)
+
+var (
+	ErrInvalidNarInfo   = util.NewInvalidArgumentErrorf("narinfo is invalid")
+	ErrInvalidStorePath = util.NewInvalidArgumentErrorf("store path is invalid")
+	ErrInvalidHash      = util.NewInvalidArgumentErrorf("hash is invalid")
+)
+
+var (
+	// https://github.com/NixOS/nix/blob/master/src/libstore/path.cc
+	storePathNamePattern = regexp.MustCompile(`\A[a-zA-Z0-9+\-_?=][a-zA-Z0-9+\-._?=]{0,210}\z`)
+	storePathHashPattern = regexp.MustCompile(`\A[0-9a-df-np-sv-z]{32}\z`)
+	narFilenamePattern   = regexp.MustCompile(`\A([0-9a-df-np-sv-z]{52})\.nar(\.[a-z0-9]+)?\z`)
+)
+
+// NarInfo describes a store path in the binary cache
+// https://github.com/NixOS/nix/blob/master/src/libstore/nar-info.cc
+type NarInfo struct {
+	StorePath   string   `json:"store_path"`
+	URL         string   `json:"url"`
+	Compression string   `json:"compression,omitempty"`
+	FileHash    string   `json:"file_hash,omitempty"`
+	FileSize    int64    `json:"file_size,omitempty"`
+	NarHash     string   `json:"nar_hash"`
+	NarSize     int64    `json:"nar_size"`
+	References  []string `json:"references,omitempty"`
+	Deriver     string   `json:"deriver,omitempty"`
+	System      string   `json:"system,omitempty"`
+	Sigs        []string `json:"sigs,omitempty"`
+	CA          string   `json:"ca,omitempty"`
+}
+
+// IsValidStorePathHash checks if the value is the hash part of a store path
+func IsValidStorePathHash(hash string) bool {
+	return storePathHashPattern.MatchString(hash)
+}
+
+// ParseNarFilename returns the file hash of a NAR file name like <hash>.nar.xz
+func ParseNarFilename(filename string) (string, bool) {
+	m := narFilenamePattern.FindStringSubmatch(filename)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// SplitStorePath splits a store path or its base name into the hash and the name part
+func SplitStorePath(p string) (string, string, error) {
+	base := strings.TrimPrefix(p, StoreDir+"/")
+	if strings.Contains(base, "/") {
+		return "", "", ErrInvalidStorePath
+	}
+
+	hash, name, ok := strings.Cut(base, "-")
+	if !ok || !storePathHashPattern.MatchString(hash) || !storePathNamePattern.MatchString(name) {
+		return "", "", ErrInvalidStorePath
+	}
+	return hash, name, nil
+}
+
+// ParseNarInfo parses a narinfo file. The hashes are normalized to the sha256:<nix32> form.
+func ParseNarInfo(r io.Reader) (*NarInfo, error) {
+	ni := &NarInfo{}
+
+	var hasReferences bool
+	scanner := bufio.NewScanner(io.LimitReader(r, maxNarInfoSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			// Nix writes "References: " with a trailing space, tolerate its absence
+			if key, ok = strings.CutSuffix(line, ":"); !ok {
+				return nil, ErrInvalidNarInfo
+			}
+		}
+
+		var err error
+		switch key {
+		case "StorePath":
+			ni.StorePath = value
+		case "URL":
+			ni.URL = value
+		case "Compression":
+			ni.Compression = value
+		case "FileHash":
+			ni.FileHash, err = NormalizeHash(value)
+		case "FileSize":
+			ni.FileSize, err = strconv.ParseInt(value, 10, 64)
+		case "NarHash":
+			ni.NarHash, err = NormalizeHash(value)
+		case "NarSize":
+			ni.NarSize, err = strconv.ParseInt(value, 10, 64)
+		case "References":
+			hasReferences = true
+			ni.References = strings.Fields(value)
+		case "Deriver":
+			if value != "unknown-deriver" {
+				ni.Deriver = value
+			}
+		case "System":
+			ni.System = value
+		case "Sig":
+			ni.Sigs = append(ni.Sigs, value)
+		case "CA":
+			ni.CA = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidNarInfo, key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if ni.StorePath == "" || ni.URL == "" || ni.NarHash == "" || ni.NarSize <= 0 || !hasReferences {
+		return nil, ErrInvalidNarInfo
+	}
+	if _, _, err := SplitStorePath(ni.StorePath); err != nil {
+		return nil, err
+	}
+	for _, ref := range ni.References {
+		if _, _, err := SplitStorePath(ref); err != nil {
+			return nil, err
+		}
+	}
+	if ni.Deriver != "" {
+		if _, _, err := SplitStorePath(ni.Deriver); err != nil {
+			return nil, err
+		}
+	}
+	if ni.FileSize < 0 {
+		return nil, ErrInvalidNarInfo
+	}
+
+	return ni, nil
+}
+
+// Fingerprint returns the data which gets signed for the store path
+func (ni *NarInfo) Fingerprint() string {
+	refs := make([]string, 0, len(ni.References))
+	for _, ref := range ni.References {
+		refs = append(refs, StoreDir+"/"+ref)
+	}
+	return fmt.Sprintf("1;%s;%s;%d;%s", ni.StorePath, ni.NarHash, ni.NarSize, strings.Join(refs, ","))
+}
+
+// WriteTo writes the narinfo in its text format
+func (ni *NarInfo) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "StorePath: %s\n", ni.StorePath)
+	fmt.Fprintf(&sb, "URL: %s\n", ni.URL)
+	if ni.Compression != "" {
+		fmt.Fprintf(&sb, "Compression: %s\n", ni.Compression)
+	}
+	if ni.FileHash != "" {
+		fmt.Fprintf(&sb, "FileHash: %s\n", ni.FileHash)
+	}
+	if ni.FileSize != 0 {
+		fmt.Fprintf(&sb, "FileSize: %d\n", ni.FileSize)
+	}
+	fmt.Fprintf(&sb, "NarHash: %s\n", ni.NarHash)
+	fmt.Fprintf(&sb, "NarSize: %d\n", ni.NarSize)
+	fmt.Fprintf(&sb, "References: %s\n", strings.Join(ni.References, " "))
+	if ni.Deriver != "" {
+		fmt.Fprintf(&sb, "Deriver: %s\n", ni.Deriver)
+	}
+	if ni.System != "" {
+		fmt.Fprintf(&sb, "System: %s\n", ni.System)
+	}
+	for _, sig := range ni.Sigs {
+		fmt.Fprintf(&sb, "Sig: %s\n", sig)
+	}
+	if ni.CA != "" {
+		fmt.Fprintf(&sb, "CA: %s\n", ni.CA)
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package nix
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	storePath  = "/nix/store/0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-hello-2.12.1"
+	narHash    = "sha256:1b8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s"
+	narInfoRaw = `StorePath: /nix/store/0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-hello-2.12.1
+URL: nar/1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar.xz
+Compression: xz
+FileHash: sha256:1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3
+FileSize: 50088
+NarHash: sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad
+NarSize: 226560
+References: 0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-hello-2.12.1 3n58xw4373jp0ljirf06d8077j15pc4j-glibc-2.37-8
+Deriver: 8l3hq5lzq6r1xfs7v4ssqr8qg0j0zq7y-hello-2.12.1.drv
+System: x86_64-linux
+Sig: cache.nixos.org-1:abc
+`
+)
+
+func TestNix32(t *testing.T) {
+	sum := sha256.Sum256([]byte("abc"))
+	assert.Equal(t, "1b8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s", EncodeNix32(sum[:]))
+
+	data, ok := DecodeNix32("1b8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s", sha256.Size)
+	assert.True(t, ok)
+	assert.Equal(t, sum[:], data)
+
+	_, ok = DecodeNix32("1b8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5", sha256.Size)
+	assert.False(t, ok)
+	_, ok = DecodeNix32("eb8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s", sha256.Size)
+	assert.False(t, ok)
+	// The first digit may only carry a single bit for 32 bytes
+	_, ok = DecodeNix32("zb8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s", sha256.Size)
+	assert.False(t, ok)
+}
+
+func TestNormalizeHash(t *testing.T) {
+	for _, h := range []string{
+		narHash,
+		"sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sha256-ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=",
+	} {
+		n, err := NormalizeHash(h)
+		require.NoError(t, err, h)
+		assert.Equal(t, narHash, n)
+	}
+
+	for _, h := range []string{"", "md5:abc", "sha256:xyz", "sha256-abc"} {
+		_, err := NormalizeHash(h)
+		assert.ErrorIs(t, err, ErrInvalidHash, h)
+	}
+}
+
+func TestParseNarInfo(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		ni, err := ParseNarInfo(strings.NewReader(narInfoRaw))
+		require.NoError(t, err)
+		assert.Equal(t, storePath, ni.StorePath)
+		assert.Equal(t, "nar/1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar.xz", ni.URL)
+		assert.Equal(t, "xz", ni.Compression)
+		assert.EqualValues(t, 50088, ni.FileSize)
+		assert.Equal(t, narHash, ni.NarHash)
+		assert.EqualValues(t, 226560, ni.NarSize)
+		assert.Equal(t, []string{"0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-hello-2.12.1", "3n58xw4373jp0ljirf06d8077j15pc4j-glibc-2.37-8"}, ni.References)
+		assert.Equal(t, "8l3hq5lzq6r1xfs7v4ssqr8qg0j0zq7y-hello-2.12.1.drv", ni.Deriver)
+		assert.Equal(t, "x86_64-linux", ni.System)
+		assert.Equal(t, []string{"cache.nixos.org-1:abc"}, ni.Sigs)
+
+		assert.Equal(t, "1;"+storePath+";"+narHash+";226560;"+storePath+",/nix/store/3n58xw4373jp0ljirf06d8077j15pc4j-glibc-2.37-8", ni.Fingerprint())
+
+		var sb strings.Builder
+		_, err = ni.WriteTo(&sb)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Replace(narInfoRaw, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", "1b8m03r63zqhnjf7l5wnldhh7c134ap5vpj0850ymkq1iyzicy5s", 1), sb.String())
+	})
+
+	t.Run("NoReferences", func(t *testing.T) {
+		ni, err := ParseNarInfo(strings.NewReader("StorePath: " + storePath + "\nURL: nar/a.nar\nNarHash: " + narHash + "\nNarSize: 1\nReferences: \n"))
+		require.NoError(t, err)
+		assert.Empty(t, ni.References)
+		assert.Equal(t, "1;"+storePath+";"+narHash+";1;", ni.Fingerprint())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, raw := range []string{
+			"",
+			"invalid",
+			strings.Replace(narInfoRaw, "References: ", "References: invalid ", 1),
+			strings.Replace(narInfoRaw, "StorePath: /nix/store/", "StorePath: /other/", 1),
+			strings.Replace(narInfoRaw, "NarSize: 226560", "NarSize: abc", 1),
+			strings.Replace(narInfoRaw, "NarHash: sha256:", "NarHash: md5:", 1),
+			strings.Replace(narInfoRaw, "URL: nar/1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar.xz\n", "", 1),
+		} {
+			_, err := ParseNarInfo(strings.NewReader(raw))
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestSplitStorePath(t *testing.T) {
+	hash, name, err := SplitStorePath(storePath)
+	require.NoError(t, err)
+	assert.Equal(t, "0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl", hash)
+	assert.Equal(t, "hello-2.12.1", name)
+
+	for _, p := range []string{"", "hello", "/nix/store/0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl", "/nix/store/0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-.hidden", "/nix/store/0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-a/b", "/nix/store/ec0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl-hello"} {
+		_, _, err := SplitStorePath(p)
+		assert.ErrorIs(t, err, ErrInvalidStorePath, p)
+	}
+}
+
+func TestParseNarFilename(t *testing.T) {
+	hash, ok := ParseNarFilename("1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar.xz")
+	assert.True(t, ok)
+	assert.Equal(t, "1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3", hash)
+
+	_, ok = ParseNarFilename("1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar")
+	assert.True(t, ok)
+
+	for _, f := range []string{"", "a.nar", "1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.tar", "../1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar"} {
+		_, ok = ParseNarFilename(f)
+		assert.False(t, ok, f)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	priv, pub, err := GenerateKeyPair("forgejo.example.com-user")
+	require.NoError(t, err)
+	assert.Equal(t, "forgejo.example.com-user", KeyName(priv))
+	assert.Equal(t, "forgejo.example.com-user", KeyName(pub))
+
+	ni, err := ParseNarInfo(strings.NewReader(narInfoRaw))
+	require.NoError(t, err)
+	assert.False(t, Verify(pub, ni))
+
+	sig, err := Sign(priv, ni)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sig, "forgejo.example.com-user:"))
+
+	ni.Sigs = append(ni.Sigs, sig)
+	assert.True(t, Verify(pub, ni))
+
+	ni.NarSize++
+	assert.False(t, Verify(pub, ni))
+
+	_, err = Sign("invalid", ni)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package nix
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("key is invalid")
+
+// GenerateKeyPair creates an ed25519 key pair in the <name>:<base64> format used by nix-store --generate-binary-cache-key
+func GenerateKeyPair(name string) (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return name + ":" + base64.StdEncoding.EncodeToString(priv), name + ":" + base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// Sign signs the fingerprint of the narinfo with the private key and returns the value of the Sig field
+func Sign(privateKey string, ni *NarInfo) (string, error) {
+	name, priv, err := parseKey(privateKey, ed25519.PrivateKeySize)
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(priv), []byte(ni.Fingerprint()))
+	return name + ":" + base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks if the narinfo carries a valid signature of the public key
+func Verify(publicKey string, ni *NarInfo) bool {
+	name, pub, err := parseKey(publicKey, ed25519.PublicKeySize)
+	if err != nil {
+		return false
+	}
+	for _, s := range ni.Sigs {
+		sigName, value, ok := strings.Cut(s, ":")
+		if !ok || sigName != name {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(value)
+		if err == nil && ed25519.Verify(ed25519.PublicKey(pub), []byte(ni.Fingerprint()), sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyName returns the name part of a key or signature
+func KeyName(key string) string {
+	name, _, _ := strings.Cut(key, ":")
+	return name
+}
+
+func parseKey(key string, size int) (string, []byte, error) {
+	name, value, ok := strings.Cut(key, ":")
+	if !ok || name == "" {
+		return "", nil, ErrInvalidKey
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(data) != size {
+		return "", nil, ErrInvalidKey
+	}
+	return name, data, nil
+}
@@ -5,7 +5,10 @@ package npm
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"testing"
@@ -300,3 +303,68 @@ func TestParsePackage(t *testing.T) {
 		assert.Equal(t, repository.URL, p.Metadata.Repository.URL)
 	})
 }
+
+func TestParseUpstreamPackage(t *testing.T) {
+	data := []byte("package content")
+	sha1Hash := sha1.Sum(data)
+	sha512Hash := sha512.Sum512(data)
+	otherSHA1Hash := sha1.Sum([]byte("other content"))
+	otherSHA512Hash := sha512.Sum512([]byte("other content"))
+
+	createMetadata := func() *PackageMetadataVersion {
+		return &PackageMetadataVersion{
+			Name:        "@scope/test-package",
+			Version:     "1.0.1",
+			Description: "Test Description",
+			Author:      User{Name: "KN4CK3R"},
+			Dependencies: map[string]string{
+				"package": "1.2.0",
+			},
+		}
+	}
+
+	t.Run("Integrity", func(t *testing.T) {
+		meta := createMetadata()
+		meta.Dist.Integrity = "sha512-" + base64.StdEncoding.EncodeToString(sha512Hash[:])
+
+		p, err := ParseUpstreamPackage(meta, sha1Hash[:], sha512Hash[:])
+		require.NoError(t, err)
+		assert.Equal(t, "@scope/test-package", p.Name)
+		assert.Equal(t, "1.0.1", p.Version)
+		assert.Equal(t, "test-package-1.0.1.tgz", p.Filename)
+		assert.Equal(t, "@scope", p.Metadata.Scope)
+		assert.Equal(t, "test-package", p.Metadata.Name)
+		assert.Equal(t, "Test Description", p.Metadata.Description)
+		assert.Equal(t, "1.2.0", p.Metadata.Dependencies["package"])
+
+		_, err = ParseUpstreamPackage(meta, otherSHA1Hash[:], otherSHA512Hash[:])
+		require.ErrorIs(t, err, ErrInvalidIntegrity)
+	})
+
+	t.Run("UnsupportedIntegrity", func(t *testing.T) {
+		meta := createMetadata()
+		meta.Dist.Integrity = "md5-" + base64.StdEncoding.EncodeToString([]byte("content"))
+
+		_, err := ParseUpstreamPackage(meta, sha1Hash[:], sha512Hash[:])
+		require.ErrorIs(t, err, ErrInvalidIntegrity)
+	})
+
+	t.Run("Shasum", func(t *testing.T) {
+		meta := createMetadata()
+		meta.Dist.Shasum = hex.EncodeToString(sha1Hash[:])
+
+		_, err := ParseUpstreamPackage(meta, sha1Hash[:], sha512Hash[:])
+		require.NoError(t, err)
+
+		_, err = ParseUpstreamPackage(meta, otherSHA1Hash[:], otherSHA512Hash[:])
+		require.ErrorIs(t, err, ErrInvalidIntegrity)
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		meta := createMetadata()
+		meta.Version = "invalid"
+
+		_, err := ParseUpstreamPackage(meta, sha1Hash[:], sha512Hash[:])
+		require.ErrorIs(t, err, ErrInvalidPackageVersion)
+	})
+}
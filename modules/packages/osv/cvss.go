@@ -0,0 +1,86 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package osv
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+var ErrInvalidVector = errors.New("invalid CVSS vector")
+
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"S":  {"U": 0, "C": 0},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// CVSS3BaseScore calculates the base score of a CVSS v3.0 or v3.1 vector
+// https://www.first.org/cvss/v3.1/specification-document#7-4-Metric-Values
+func CVSS3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || (parts[0] != "CVSS:3.0" && parts[0] != "CVSS:3.1") {
+		return 0, ErrInvalidVector
+	}
+
+	metrics := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		name, value, ok := strings.Cut(p, ":")
+		if !ok {
+			return 0, ErrInvalidVector
+		}
+		metrics[name] = value
+	}
+
+	w := make(map[string]float64, len(cvss3Weights))
+	for name, values := range cvss3Weights {
+		v, ok := values[metrics[name]]
+		if !ok {
+			return 0, ErrInvalidVector
+		}
+		w[name] = v
+	}
+
+	changed := metrics["S"] == "C"
+	if changed {
+		switch metrics["PR"] {
+		case "L":
+			w["PR"] = 0.68
+		case "H":
+			w["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp returns the smallest number with one decimal which is equal or higher than the input
+func roundUp(v float64) float64 {
+	i := int64(math.Round(v * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
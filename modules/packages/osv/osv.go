@@ -0,0 +1,253 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package osv
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// The Open Source Vulnerability format
+// https://ossf.github.io/osv-schema/
+
+const (
+	EcosystemCratesIO = "crates.io"
+	EcosystemGo       = "Go"
+	EcosystemMaven    = "Maven"
+	EcosystemNpm      = "npm"
+	EcosystemPyPI     = "PyPI"
+)
+
+// Ecosystems lists the ecosystems packages are matched against
+var Ecosystems = []string{EcosystemCratesIO, EcosystemGo, EcosystemMaven, EcosystemNpm, EcosystemPyPI}
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityModerate = "moderate"
+	SeverityLow      = "low"
+	SeverityUnknown  = "unknown"
+)
+
+// SeverityRank orders the severities, a higher rank is more severe
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityModerate:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+const maxEntrySize = 10 << 20
+
+var ErrInvalidEntry = util.NewInvalidArgumentErrorf("advisory is invalid")
+
+// Entry is an advisory
+type Entry struct {
+	ID               string         `json:"id"`
+	Modified         time.Time      `json:"modified"`
+	Published        time.Time      `json:"published"`
+	Withdrawn        *time.Time     `json:"withdrawn,omitempty"`
+	Aliases          []string       `json:"aliases,omitempty"`
+	Summary          string         `json:"summary,omitempty"`
+	Details          string         `json:"details,omitempty"`
+	Severity         []Severity     `json:"severity,omitempty"`
+	Affected         []*Affected    `json:"affected,omitempty"`
+	DatabaseSpecific map[string]any `json:"database_specific,omitempty"`
+}
+
+// Severity is a score of an advisory in a scoring system like CVSS
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected describes the affected versions of a package
+type Affected struct {
+	Package  Package    `json:"package"`
+	Severity []Severity `json:"severity,omitempty"`
+	Ranges   []*Range   `json:"ranges,omitempty"`
+	Versions []string   `json:"versions,omitempty"`
+}
+
+// Package identifies a package in an ecosystem
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+// Range is a list of events which introduce or fix the vulnerability
+type Range struct {
+	Type   string   `json:"type"`
+	Events []*Event `json:"events"`
+}
+
+// Event is a single version of a range, only one of the fields is set
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// ParseEntry parses an advisory
+func ParseEntry(r io.Reader) (*Entry, error) {
+	var e Entry
+	if err := json.NewDecoder(io.LimitReader(r, maxEntrySize)).Decode(&e); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+	}
+	if e.ID == "" {
+		return nil, ErrInvalidEntry
+	}
+	return &e, nil
+}
+
+// SeverityLevel returns the severity of the advisory for the affected package.
+// The severity assigned by the database is preferred over the CVSS vectors.
+func (e *Entry) SeverityLevel(a *Affected) string {
+	if s, ok := e.DatabaseSpecific["severity"].(string); ok {
+		switch strings.ToLower(s) {
+		case "critical":
+			return SeverityCritical
+		case "high":
+			return SeverityHigh
+		case "moderate", "medium":
+			return SeverityModerate
+		case "low":
+			return SeverityLow
+		}
+	}
+
+	severities := e.Severity
+	if a != nil && len(a.Severity) > 0 {
+		severities = a.Severity
+	}
+	for _, s := range severities {
+		if s.Type != "CVSS_V3" {
+			continue
+		}
+		if score, err := CVSS3BaseScore(s.Score); err == nil {
+			switch {
+			case score >= 9:
+				return SeverityCritical
+			case score >= 7:
+				return SeverityHigh
+			case score >= 4:
+				return SeverityModerate
+			case score > 0:
+				return SeverityLow
+			}
+		}
+	}
+	return SeverityUnknown
+}
+
+// IsAffected checks if the version of the package is affected
+func (a *Affected) IsAffected(version string) bool {
+	if version == "" {
+		return false
+	}
+	if slices.Contains(a.Versions, version) {
+		return true
+	}
+	for _, r := range a.Ranges {
+		if r.Type != "GIT" && r.contains(version) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Range) contains(version string) bool {
+	events := slices.Clone(r.Events)
+	slices.SortStableFunc(events, func(a, b *Event) int {
+		return CompareVersions(a.version(), b.version())
+	})
+
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || CompareVersions(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if CompareVersions(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if CompareVersions(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		case e.Limit != "":
+			if e.Limit != "*" && CompareVersions(version, e.Limit) >= 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func (e *Event) version() string {
+	switch {
+	case e.Introduced != "":
+		if e.Introduced == "0" {
+			return ""
+		}
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	}
+	return e.Limit
+}
+
+var pypiNameReplacer = regexp.MustCompile(`[-_.]+`)
+
+// NormalizeName normalizes a package name so that the names of advisories and packages can be compared
+func NormalizeName(ecosystem, name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if ecosystem == EcosystemPyPI {
+		// https://peps.python.org/pep-0503/#normalized-names
+		name = pypiNameReplacer.ReplaceAllString(name, "-")
+	}
+	return name
+}
+
+var versionPattern = regexp.MustCompile(`v?[0-9]+(?:\.[0-9]+)*(?:[-+.]?[A-Za-z][0-9A-Za-z.]*)?`)
+
+// LowestVersion returns the lowest version allowed by a version requirement like ^1.2.3, >=2.0,<3 or [1.0,2.0).
+// Upper bounds and excluded versions are skipped. Wildcards which match any version return an empty string.
+func LowestVersion(requirement string) string {
+	prev := 0
+	for _, loc := range versionPattern.FindAllStringIndex(requirement, -1) {
+		op := strings.TrimSpace(requirement[prev:loc[0]])
+		next := strings.TrimSpace(requirement[loc[1]:])
+		prev = loc[1]
+
+		if strings.HasSuffix(op, "<") || strings.HasSuffix(op, "<=") || strings.HasSuffix(op, "!=") {
+			continue
+		}
+		// The upper bound of a Maven range like (,1.0]
+		if (strings.HasPrefix(next, ")") || strings.HasPrefix(next, "]")) && strings.HasSuffix(op, ",") {
+			continue
+		}
+		return requirement[loc[0]:loc[1]]
+	}
+	return ""
+}
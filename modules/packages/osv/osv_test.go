@@ -0,0 +1,169 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package osv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const entryContent = `{
+  "id": "GHSA-xxxx-yyyy-zzzz",
+  "modified": "2024-01-02T03:04:05Z",
+  "published": "2024-01-01T00:00:00Z",
+  "aliases": ["CVE-2024-0001"],
+  "summary": "Prototype pollution",
+  "affected": [
+    {
+      "package": {"ecosystem": "npm", "name": "left-pad"},
+      "ranges": [
+        {"type": "SEMVER", "events": [{"introduced": "2.0.0"}, {"fixed": "2.3.1"}, {"introduced": "0"}, {"fixed": "1.4.0"}]}
+      ]
+    },
+    {
+      "package": {"ecosystem": "PyPI", "name": "Left_Pad"},
+      "ranges": [
+        {"type": "ECOSYSTEM", "events": [{"introduced": "1.0"}, {"last_affected": "1.2.post1"}]}
+      ],
+      "versions": ["0.9b1"]
+    }
+  ],
+  "database_specific": {"severity": "MODERATE"}
+}`
+
+func TestParseEntry(t *testing.T) {
+	e, err := ParseEntry(strings.NewReader(entryContent))
+	require.NoError(t, err)
+	assert.Equal(t, "GHSA-xxxx-yyyy-zzzz", e.ID)
+	assert.Equal(t, []string{"CVE-2024-0001"}, e.Aliases)
+	require.Len(t, e.Affected, 2)
+	assert.Equal(t, SeverityModerate, e.SeverityLevel(e.Affected[0]))
+
+	npm := e.Affected[0]
+	for v, affected := range map[string]bool{
+		"0.1.0":       true,
+		"1.3.9":       true,
+		"1.4.0":       false,
+		"1.9.0":       false,
+		"2.0.0-beta1": false,
+		"2.0.0":       true,
+		"2.3.0":       true,
+		"2.3.1":       false,
+		"3.0.0":       false,
+		"":            false,
+	} {
+		assert.Equal(t, affected, npm.IsAffected(v), v)
+	}
+
+	pypi := e.Affected[1]
+	for v, affected := range map[string]bool{
+		"0.9b1":     true,
+		"0.9":       false,
+		"1.0":       true,
+		"1.2":       true,
+		"1.2.post1": true,
+		"1.2.post2": false,
+		"1.3":       false,
+	} {
+		assert.Equal(t, affected, pypi.IsAffected(v), v)
+	}
+
+	_, err = ParseEntry(strings.NewReader(`{"summary": "missing id"}`))
+	assert.ErrorIs(t, err, ErrInvalidEntry)
+	_, err = ParseEntry(strings.NewReader(`invalid`))
+	assert.ErrorIs(t, err, ErrInvalidEntry)
+}
+
+func TestSeverityLevel(t *testing.T) {
+	e := &Entry{
+		Severity: []Severity{{Type: "CVSS_V3", Score: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}},
+	}
+	assert.Equal(t, SeverityCritical, e.SeverityLevel(nil))
+
+	a := &Affected{Severity: []Severity{{Type: "CVSS_V3", Score: "CVSS:3.1/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N"}}}
+	assert.Equal(t, SeverityLow, e.SeverityLevel(a))
+
+	assert.Equal(t, SeverityUnknown, (&Entry{}).SeverityLevel(nil))
+	assert.Equal(t, SeverityHigh, (&Entry{DatabaseSpecific: map[string]any{"severity": "HIGH"}}).SeverityLevel(nil))
+
+	assert.Greater(t, SeverityRank(SeverityCritical), SeverityRank(SeverityHigh))
+	assert.Greater(t, SeverityRank(SeverityLow), SeverityRank(SeverityUnknown))
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	for vector, score := range map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10,
+		"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:L/I:L/A:N": 6.4,
+		"CVSS:3.0/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N": 3.1,
+		"CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:N/I:N/A:N": 0,
+	} {
+		s, err := CVSS3BaseScore(vector)
+		require.NoError(t, err, vector)
+		assert.InDelta(t, score, s, 0.001, vector)
+	}
+
+	for _, vector := range []string{"", "CVSS:2.0/AV:N", "CVSS:3.1/AV:N/AC:L", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"} {
+		_, err := CVSS3BaseScore(vector)
+		assert.ErrorIs(t, err, ErrInvalidVector, vector)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		cmp  int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.2", -1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0-SNAPSHOT", "1.0", -1},
+		{"1.0.post1", "1.0", 1},
+		{"1.0.post1", "1.0.1", -1},
+		{"2.0.0+build.5", "2.0.0", 0},
+		{"1.0.0-next", "1.0.0-rc1", 1},
+		{"1.0.0-next", "1.0.0", -1},
+		{"99999999999999999999.0", "1.0", 1},
+	} {
+		assert.Equal(t, c.cmp, CompareVersions(c.a, c.b), "%s <=> %s", c.a, c.b)
+		assert.Equal(t, -c.cmp, CompareVersions(c.b, c.a), "%s <=> %s", c.b, c.a)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "left-pad", NormalizeName(EcosystemPyPI, "Left_._Pad"))
+	assert.Equal(t, "@scope/left_pad", NormalizeName(EcosystemNpm, "@Scope/left_pad"))
+	assert.Equal(t, "org.example:lib", NormalizeName(EcosystemMaven, "org.example:lib"))
+}
+
+func TestLowestVersion(t *testing.T) {
+	for requirement, version := range map[string]string{
+		"1.2.3":           "1.2.3",
+		"^1.2.3":          "1.2.3",
+		"~> 1.4":          "1.4",
+		">=2.0,<3":        "2.0",
+		"[1.0,2.0)":       "1.0",
+		"=0.5.0-beta.1":   "0.5.0-beta.1",
+		"v1.2.0":          "v1.2.0",
+		"*":               "",
+		"latest":          "",
+		">= 1.0.0 < 2.0":  "1.0.0",
+		"<3,>=1.21.1":     "1.21.1",
+		"!=1.5.7,>=1.5.6": "1.5.6",
+		"[1.5]":           "1.5",
+		"(,1.0]":          "",
+		"<2.0":            "",
+		"(>=2.20.0)":      "2.20.0",
+	} {
+		assert.Equal(t, version, LowestVersion(requirement), requirement)
+	}
+}
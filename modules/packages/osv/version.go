@@ -0,0 +1,125 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package osv
+
+import (
+	"cmp"
+	"math/big"
+	"strings"
+)
+
+// The ranks of version qualifiers, qualifiers which are not listed rank as pre-releases
+var qualifierRanks = map[string]int{
+	"dev":       0,
+	"snapshot":  0,
+	"alpha":     1,
+	"a":         1,
+	"beta":      2,
+	"b":         2,
+	"milestone": 3,
+	"m":         3,
+	"rc":        4,
+	"c":         4,
+	"cr":        4,
+	"pre":       4,
+	"preview":   4,
+	"":          6,
+	"final":     6,
+	"ga":        6,
+	"release":   6,
+	"post":      7,
+	"p":         7,
+	"sp":        7,
+	"patch":     7,
+}
+
+const unknownQualifierRank = 5
+
+type versionToken struct {
+	number *big.Int
+	word   string
+}
+
+func tokenizeVersion(v string) []versionToken {
+	v = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(v), "v"))
+	// Build metadata does not take part in the ordering
+	v, _, _ = strings.Cut(v, "+")
+
+	var tokens []versionToken
+	start := 0
+	flush := func(end int) {
+		if start < end {
+			s := v[start:end]
+			if n, ok := new(big.Int).SetString(s, 10); ok {
+				tokens = append(tokens, versionToken{number: n})
+			} else {
+				tokens = append(tokens, versionToken{word: s})
+			}
+		}
+		start = end
+	}
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '.' || c == '-' || c == '_' || c == '~':
+			flush(i)
+			start = i + 1
+		case i > start && isDigit(c) != isDigit(v[i-1]):
+			flush(i)
+		}
+	}
+	flush(len(v))
+	return tokens
+}
+
+func qualifierRank(word string) int {
+	if r, ok := qualifierRanks[word]; ok {
+		return r
+	}
+	return unknownQualifierRank
+}
+
+// CompareVersions compares two versions of any of the supported ecosystems.
+// Numeric parts are compared as numbers, qualifiers like alpha, rc or post are ordered relative to the release.
+func CompareVersions(a, b string) int {
+	ta, tb := tokenizeVersion(a), tokenizeVersion(b)
+	for i := 0; i < max(len(ta), len(tb)); i++ {
+		var x, y *versionToken
+		if i < len(ta) {
+			x = &ta[i]
+		}
+		if i < len(tb) {
+			y = &tb[i]
+		}
+		if c := compareTokens(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareTokens(x, y *versionToken) int {
+	switch {
+	case x == nil && y == nil:
+		return 0
+	case x == nil:
+		return -compareTokens(y, nil)
+	case y == nil:
+		if x.number != nil {
+			return x.number.Sign()
+		}
+		return cmp.Compare(qualifierRank(x.word), qualifierRank(""))
+	case x.number != nil && y.number != nil:
+		return x.number.Cmp(y.number)
+	case x.number != nil:
+		return 1
+	case y.number != nil:
+		return -1
+	}
+	if c := cmp.Compare(qualifierRank(x.word), qualifierRank(y.word)); c != 0 {
+		return c
+	}
+	return strings.Compare(x.word, y.word)
+}
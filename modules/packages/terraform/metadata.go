@@ -0,0 +1,177 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package terraform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"code.gitea.io/gitea/modules/util"
+)
+
+// The registry hosts modules, whose package name is "<name>/<system>", and providers, whose package name is their type
+const (
+	KindModule   = "module"
+	KindProvider = "provider"
+
+	PropertyOS   = "terraform.os"
+	PropertyArch = "terraform.arch"
+
+	SettingKeyPrivate = "terraform.key.private"
+	SettingKeyPublic  = "terraform.key.public"
+
+	// ModuleArchiveFilename is the name of the file of a module version
+	ModuleArchiveFilename = "module.tar.gz"
+
+	// DefaultProtocol is the plugin protocol assumed for the providers uploaded without protocols
+	DefaultProtocol = "5.0"
+
+	maxReadmeSize = 64 * 1024
+)
+
+var (
+	ErrInvalidName      = util.NewInvalidArgumentErrorf("package name is invalid")
+	ErrInvalidSystem    = util.NewInvalidArgumentErrorf("module system is invalid")
+	ErrInvalidPlatform  = util.NewInvalidArgumentErrorf("provider platform is invalid")
+	ErrInvalidProtocol  = util.NewInvalidArgumentErrorf("provider protocol is invalid")
+	ErrProtocolMismatch = util.NewInvalidArgumentErrorf("provider protocols differ from the ones of the other platforms")
+	ErrInvalidArchive   = util.NewInvalidArgumentErrorf("archive is invalid")
+)
+
+var (
+	// https://developer.hashicorp.com/terraform/internals/module-registry-protocol#module-addresses
+	namePattern     = regexp.MustCompile(`\A[0-9A-Za-z](?:[0-9A-Za-z_-]{0,62}[0-9A-Za-z])?\z`)
+	systemPattern   = regexp.MustCompile(`\A[0-9a-z]{1,64}\z`)
+	platformPattern = regexp.MustCompile(`\A[0-9a-z_]{1,32}\z`)
+	protocolPattern = regexp.MustCompile(`\A[0-9]+\.[0-9]+\z`)
+)
+
+// Metadata represents the metadata of a Terraform module or provider version
+type Metadata struct {
+	Kind        string   `json:"kind"`
+	Description string   `json:"description,omitempty"`
+	Readme      string   `json:"readme,omitempty"`
+	Protocols   []string `json:"protocols,omitempty"`
+}
+
+// ModulePackageName validates the name and the system of a module and returns its package name
+func ModulePackageName(name, system string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	if !systemPattern.MatchString(system) {
+		return "", ErrInvalidSystem
+	}
+	return name + "/" + system, nil
+}
+
+// IsValidProviderType checks the type of a provider, which is its package name
+func IsValidProviderType(providerType string) bool {
+	return namePattern.MatchString(providerType) && !strings.Contains(providerType, "_")
+}
+
+// IsValidPlatform checks the operating system and the architecture of a provider build
+func IsValidPlatform(os, arch string) bool {
+	return platformPattern.MatchString(os) && platformPattern.MatchString(arch)
+}
+
+// ParseProtocols parses a comma separated list of plugin protocol versions like "5.0,6.0"
+func ParseProtocols(s string) ([]string, error) {
+	if s == "" {
+		return []string{DefaultProtocol}, nil
+	}
+	protocols := strings.Split(s, ",")
+	for i, p := range protocols {
+		protocols[i] = strings.TrimSpace(p)
+		if !protocolPattern.MatchString(protocols[i]) {
+			return nil, ErrInvalidProtocol
+		}
+	}
+	return protocols, nil
+}
+
+// SameProtocols checks whether two lists of plugin protocol versions contain the same versions, in any order
+func SameProtocols(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// ProviderFilename returns the name of the archive of a provider build, as expected by Terraform
+func ProviderFilename(providerType, version, os, arch string) string {
+	return fmt.Sprintf("terraform-provider-%s_%s_%s_%s.zip", providerType, version, os, arch)
+}
+
+// ParseModuleArchive checks that a module archive is a gzipped tarball and extracts its readme
+func ParseModuleArchive(r io.Reader) (*Metadata, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gzr.Close()
+
+	m := &Metadata{Kind: KindModule}
+
+	tr := tar.NewReader(gzr)
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		if hd.Typeflag != tar.TypeReg || !strings.EqualFold(path.Clean(hd.Name), "README.md") {
+			continue
+		}
+
+		readme, err := io.ReadAll(io.LimitReader(tr, maxReadmeSize))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		m.Readme = string(readme)
+		m.Description = firstParagraphLine(m.Readme)
+	}
+
+	return m, nil
+}
+
+// firstParagraphLine returns the first line of the readme which is neither a heading nor a badge
+func firstParagraphLine(readme string) string {
+	scanner := bufio.NewScanner(strings.NewReader(readme))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[![") || strings.HasPrefix(line, "![") {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// ParseProviderArchive checks that a provider archive is a zip file containing the provider executable
+func ParseProviderArchive(r io.ReaderAt, size int64, providerType string) (*Metadata, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	prefix := "terraform-provider-" + providerType
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && strings.HasPrefix(path.Base(f.Name), prefix) {
+			return &Metadata{Kind: KindProvider}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: the archive does not contain a %s executable", ErrInvalidArchive, prefix)
+}
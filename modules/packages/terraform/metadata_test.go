@@ -0,0 +1,124 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package terraform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModulePackageName(t *testing.T) {
+	name, err := ModulePackageName("consul", "aws")
+	require.NoError(t, err)
+	assert.Equal(t, "consul/aws", name)
+
+	_, err = ModulePackageName("-consul", "aws")
+	require.ErrorIs(t, err, ErrInvalidName)
+	_, err = ModulePackageName("consul", "AWS")
+	require.ErrorIs(t, err, ErrInvalidSystem)
+	_, err = ModulePackageName("consul", "a/b")
+	require.ErrorIs(t, err, ErrInvalidSystem)
+}
+
+func TestValidation(t *testing.T) {
+	assert.True(t, IsValidProviderType("random"))
+	assert.True(t, IsValidProviderType("my-provider"))
+	assert.False(t, IsValidProviderType("my_provider"))
+	assert.False(t, IsValidProviderType("a/b"))
+
+	assert.True(t, IsValidPlatform("linux", "amd64"))
+	assert.False(t, IsValidPlatform("Linux", "amd64"))
+	assert.False(t, IsValidPlatform("linux", "../amd64"))
+
+	assert.Equal(t, "terraform-provider-random_1.2.0_linux_amd64.zip", ProviderFilename("random", "1.2.0", "linux", "amd64"))
+}
+
+func TestParseProtocols(t *testing.T) {
+	protocols, err := ParseProtocols("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{DefaultProtocol}, protocols)
+
+	protocols, err = ParseProtocols("5.0, 6.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5.0", "6.0"}, protocols)
+
+	_, err = ParseProtocols("5")
+	require.ErrorIs(t, err, ErrInvalidProtocol)
+}
+
+func TestSameProtocols(t *testing.T) {
+	assert.True(t, SameProtocols([]string{"5.0", "6.0"}, []string{"6.0", "5.0"}))
+	assert.True(t, SameProtocols([]string{"5.0"}, []string{"5.0", "5.0"}))
+	assert.False(t, SameProtocols([]string{"5.0", "6.0"}, []string{"6.0"}))
+}
+
+func TestParseModuleArchive(t *testing.T) {
+	createArchive := func(files map[string]string) io.Reader {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		for filename, content := range files {
+			hdr := &tar.Header{
+				Name: filename,
+				Mode: 0o600,
+				Size: int64(len(content)),
+			}
+			tw.WriteHeader(hdr)
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		zw.Close()
+		return &buf
+	}
+
+	t.Run("InvalidArchive", func(t *testing.T) {
+		_, err := ParseModuleArchive(bytes.NewReader([]byte("not a tarball")))
+		require.ErrorIs(t, err, ErrInvalidArchive)
+	})
+
+	t.Run("MissingReadme", func(t *testing.T) {
+		m, err := ParseModuleArchive(createArchive(map[string]string{"main.tf": ""}))
+		require.NoError(t, err)
+		assert.Equal(t, KindModule, m.Kind)
+		assert.Empty(t, m.Readme)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		readme := "# Consul\n\n[![badge](https://example.com/badge.svg)](https://example.com)\n\nDeploys a Consul cluster.\n\nMore details."
+		m, err := ParseModuleArchive(createArchive(map[string]string{"main.tf": "", "./README.md": readme}))
+		require.NoError(t, err)
+		assert.Equal(t, readme, m.Readme)
+		assert.Equal(t, "Deploys a Consul cluster.", m.Description)
+	})
+}
+
+func TestParseProviderArchive(t *testing.T) {
+	createArchive := func(filename string) *bytes.Reader {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(filename)
+		w.Write([]byte("binary"))
+		zw.Close()
+		return bytes.NewReader(buf.Bytes())
+	}
+
+	r := createArchive("terraform-provider-random_v1.2.0")
+	m, err := ParseProviderArchive(r, r.Size(), "random")
+	require.NoError(t, err)
+	assert.Equal(t, KindProvider, m.Kind)
+
+	r = createArchive("terraform-provider-other_v1.2.0")
+	_, err = ParseProviderArchive(r, r.Size(), "random")
+	require.ErrorIs(t, err, ErrInvalidArchive)
+
+	_, err = ParseProviderArchive(bytes.NewReader([]byte("not a zip")), 9, "random")
+	require.ErrorIs(t, err, ErrInvalidArchive)
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package private
+
+import (
+	"context"
+	"net/url"
+
+	"code.gitea.io/gitea/modules/httplib"
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/setting"
+)
+
+// NewLFSRequest returns a request to the LFS server of a repository of the local instance.
+// Unlike the other internal requests, it is authorized with the given LFS token instead of the internal token.
+func NewLFSRequest(ctx context.Context, ownerName, repoName, authorization, method, path string) *httplib.Request {
+	reqURL := setting.LocalURL + url.PathEscape(ownerName) + "/" + url.PathEscape(repoName) + ".git/info/lfs/" + path
+	return newInternalRequest(ctx, reqURL, method).
+		Header("Authorization", authorization).
+		Header("Accept", lfs.MediaType)
+}
@@ -40,7 +40,7 @@ func TestGetDirectorySize(t *testing.T) {
 	repo, err := repo_model.GetRepositoryByID(db.DefaultContext, 1)
 	require.NoError(t, err)
 
-	size, err := getDirectorySize(repo.RepoPath())
+	size, err := GetDirectorySize(repo.RepoPath())
 	require.NoError(t, err)
 	assert.EqualValues(t, size, repo.Size)
 }
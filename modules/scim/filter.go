@@ -0,0 +1,88 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"strings"
+)
+
+// Filter is an equality filter on an attribute, the only kind of filter identity providers
+// use to look up the resources they provision
+type Filter struct {
+	// Attribute is the lowercased name of the filtered attribute
+	Attribute string
+	Value     string
+}
+
+// ParseFilter parses a filter of the form `attribute eq "value"`
+func ParseFilter(filter string) (*Filter, error) {
+	attribute, rest, ok := strings.Cut(strings.TrimSpace(filter), " ")
+	if !ok {
+		return nil, invalidErrorf(ErrorTypeInvalidFilter, "invalid filter %q", filter)
+	}
+	operator, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(operator, "eq") {
+		return nil, invalidErrorf(ErrorTypeInvalidFilter, "unsupported filter %q, only the eq operator is supported", filter)
+	}
+	value, err := parseFilterValue(strings.TrimSpace(value))
+	if err != nil {
+		return nil, invalidErrorf(ErrorTypeInvalidFilter, "invalid value in filter %q", filter)
+	}
+	return &Filter{Attribute: normalizeAttribute(attribute), Value: value}, nil
+}
+
+func parseFilterValue(value string) (string, error) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", invalidErrorf(ErrorTypeInvalidFilter, "the value %s is not a string", value)
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`), `\\`, `\`), nil
+}
+
+// normalizeAttribute lowercases the name of an attribute and strips the URN of the core schemas
+func normalizeAttribute(attribute string) string {
+	attribute = strings.ToLower(attribute)
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		attribute = strings.TrimPrefix(attribute, strings.ToLower(schema)+":")
+	}
+	return attribute
+}
+
+// path is the target of a patch operation, such as `name.givenName` or `members[value eq "2"]`
+type path struct {
+	attribute    string
+	filter       *Filter
+	subAttribute string
+}
+
+func parsePath(p string) (*path, error) {
+	result := &path{}
+	if i := strings.IndexByte(p, '['); i >= 0 {
+		end := strings.LastIndexByte(p, ']')
+		if end < i {
+			return nil, invalidErrorf(ErrorTypeInvalidPath, "invalid path %q", p)
+		}
+		filter, err := ParseFilter(p[i+1 : end])
+		if err != nil {
+			return nil, invalidErrorf(ErrorTypeInvalidPath, "invalid filter in path %q", p)
+		}
+		result.filter = filter
+		result.attribute = normalizeAttribute(p[:i])
+		rest := p[end+1:]
+		if rest != "" {
+			if rest[0] != '.' {
+				return nil, invalidErrorf(ErrorTypeInvalidPath, "invalid path %q", p)
+			}
+			result.subAttribute = strings.ToLower(rest[1:])
+		}
+		return result, nil
+	}
+	attribute := normalizeAttribute(p)
+	// the URN of a schema contains dots, the sub-attribute is only after the last colon
+	if i := strings.LastIndexByte(attribute, '.'); i > strings.LastIndexByte(attribute, ':') {
+		result.attribute, result.subAttribute = attribute[:i], attribute[i+1:]
+	} else {
+		result.attribute = attribute
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	for _, c := range []struct {
+		filter    string
+		attribute string
+		value     string
+	}{
+		{`userName eq "alice"`, "username", "alice"},
+		{`  displayName   EQ  "Team \"A\""  `, "displayname", `Team "A"`},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "alice@example.com"`, "username", "alice@example.com"},
+		{`externalId eq ""`, "externalid", ""},
+	} {
+		t.Run(c.filter, func(t *testing.T) {
+			filter, err := ParseFilter(c.filter)
+			require.NoError(t, err)
+			assert.Equal(t, c.attribute, filter.Attribute)
+			assert.Equal(t, c.value, filter.Value)
+		})
+	}
+
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName sw "al"`,
+		`userName eq alice`,
+		`userName eq "alice" and active eq true`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			_, err := ParseFilter(filter)
+			var invalid *InvalidError
+			require.ErrorAs(t, err, &invalid)
+			assert.Equal(t, ErrorTypeInvalidFilter, invalid.ScimType)
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	for _, c := range []struct {
+		path         string
+		attribute    string
+		subAttribute string
+		filter       *Filter
+	}{
+		{"active", "active", "", nil},
+		{"name.givenName", "name", "givenname", nil},
+		{"urn:ietf:params:scim:schemas:core:2.0:User:name.familyName", "name", "familyname", nil},
+		{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:user:department", "", nil},
+		{`emails[type eq "work"].value`, "emails", "value", &Filter{Attribute: "type", Value: "work"}},
+		{`members[value eq "12"]`, "members", "", &Filter{Attribute: "value", Value: "12"}},
+	} {
+		t.Run(c.path, func(t *testing.T) {
+			p, err := parsePath(c.path)
+			require.NoError(t, err)
+			assert.Equal(t, c.attribute, p.attribute)
+			assert.Equal(t, c.subAttribute, p.subAttribute)
+			assert.Equal(t, c.filter, p.filter)
+		})
+	}
+
+	for _, p := range []string{`members]value eq "12"[`, `members[value eq 12]`, `emails[type eq "work"]value`} {
+		t.Run(p, func(t *testing.T) {
+			_, err := parsePath(p)
+			assert.Error(t, err)
+		})
+	}
+}
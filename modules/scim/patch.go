@@ -0,0 +1,293 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+)
+
+type patchFunc func(op string, p *path, value any) error
+
+// applyOperations calls patch for each attribute modified by the operations, operations without a path
+// modify the attributes of their value
+func applyOperations(ops []Operation, patch patchFunc) error {
+	for _, operation := range ops {
+		op := strings.ToLower(operation.Op)
+		if op != opAdd && op != opReplace && op != opRemove {
+			return invalidErrorf(ErrorTypeInvalidSyntax, "unsupported operation %q", operation.Op)
+		}
+		if operation.Path != "" {
+			p, err := parsePath(operation.Path)
+			if err != nil {
+				return err
+			}
+			if err := patch(op, p, operation.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if op == opRemove {
+			return invalidErrorf(ErrorTypeInvalidPath, "a remove operation requires a path")
+		}
+		attributes, ok := operation.Value.(map[string]any)
+		if !ok {
+			return invalidErrorf(ErrorTypeInvalidValue, "an operation without a path requires an object value")
+		}
+		for attribute, value := range attributes {
+			p, err := parsePath(attribute)
+			if err != nil {
+				return err
+			}
+			if err := patch(op, p, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyUserPatch applies the operations of a patch to a user, the attributes Forgejo does not store are ignored
+func ApplyUserPatch(u *User, ops []Operation) error {
+	return applyOperations(ops, func(op string, p *path, value any) error {
+		return patchUser(u, op, p, value)
+	})
+}
+
+func patchUser(u *User, op string, p *path, value any) error {
+	var err error
+	switch p.attribute {
+	case "username":
+		u.UserName, err = stringValue(op, value)
+	case "externalid":
+		u.ExternalID, err = stringValue(op, value)
+	case "displayname":
+		u.DisplayName, err = stringValue(op, value)
+	case "active":
+		if op == opRemove {
+			u.Active = nil
+			return nil
+		}
+		var active bool
+		if active, err = boolValue(value); err == nil {
+			u.Active = &active
+		}
+	case "name":
+		return patchName(u, op, p, value)
+	case "emails":
+		return patchEmails(u, op, p, value)
+	}
+	return err
+}
+
+func patchName(u *User, op string, p *path, value any) error {
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+	if p.subAttribute == "" {
+		if op == opRemove {
+			u.Name = nil
+			return nil
+		}
+		attributes, ok := value.(map[string]any)
+		if !ok {
+			return invalidErrorf(ErrorTypeInvalidValue, "the name must be an object")
+		}
+		if op == opReplace {
+			u.Name = &Name{}
+		}
+		for attribute, value := range attributes {
+			if err := patchName(u, op, &path{attribute: "name", subAttribute: strings.ToLower(attribute)}, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	switch p.subAttribute {
+	case "formatted":
+		u.Name.Formatted, err = stringValue(op, value)
+	case "givenname":
+		u.Name.GivenName, err = stringValue(op, value)
+	case "familyname":
+		u.Name.FamilyName, err = stringValue(op, value)
+	}
+	return err
+}
+
+func patchEmails(u *User, op string, p *path, value any) error {
+	if p.filter == nil {
+		if op == opRemove {
+			u.Emails = nil
+			return nil
+		}
+		emails, err := multiValues(value)
+		if err != nil {
+			return err
+		}
+		if op == opReplace {
+			u.Emails = emails
+		} else {
+			u.Emails = append(u.Emails, emails...)
+		}
+		return nil
+	}
+
+	i := slices.IndexFunc(u.Emails, func(email MultiValue) bool {
+		return matchMultiValue(email, p.filter)
+	})
+	if op == opRemove {
+		if i >= 0 {
+			u.Emails = slices.Delete(u.Emails, i, i+1)
+		}
+		return nil
+	}
+	if p.subAttribute != "" && p.subAttribute != "value" {
+		return nil
+	}
+	email, err := stringValue(op, value)
+	if err != nil {
+		return err
+	}
+	if i >= 0 {
+		u.Emails[i].Value = email
+	} else if p.filter.Attribute == "type" {
+		u.Emails = append(u.Emails, MultiValue{Value: email, Type: p.filter.Value})
+	}
+	return nil
+}
+
+// ApplyGroupPatch applies the operations of a patch to a group, the attributes Forgejo does not store are ignored
+func ApplyGroupPatch(g *Group, ops []Operation) error {
+	return applyOperations(ops, func(op string, p *path, value any) error {
+		return patchGroup(g, op, p, value)
+	})
+}
+
+func patchGroup(g *Group, op string, p *path, value any) error {
+	var err error
+	switch p.attribute {
+	case "displayname":
+		g.DisplayName, err = stringValue(op, value)
+	case "externalid":
+		g.ExternalID, err = stringValue(op, value)
+	case "members":
+		return patchMembers(g, op, p, value)
+	}
+	return err
+}
+
+func patchMembers(g *Group, op string, p *path, value any) error {
+	if p.filter != nil {
+		if op != opRemove {
+			return invalidErrorf(ErrorTypeInvalidPath, "members can only be added or replaced without a filter")
+		}
+		g.Members = slices.DeleteFunc(g.Members, func(member MultiValue) bool {
+			return matchMultiValue(member, p.filter)
+		})
+		return nil
+	}
+	if op == opRemove && value == nil {
+		g.Members = nil
+		return nil
+	}
+	members, err := multiValues(value)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case opReplace:
+		g.Members = members
+	case opAdd:
+		for _, member := range members {
+			if !slices.ContainsFunc(g.Members, func(m MultiValue) bool { return m.Value == member.Value }) {
+				g.Members = append(g.Members, member)
+			}
+		}
+	case opRemove:
+		g.Members = slices.DeleteFunc(g.Members, func(m MultiValue) bool {
+			return slices.ContainsFunc(members, func(member MultiValue) bool { return m.Value == member.Value })
+		})
+	}
+	return nil
+}
+
+func matchMultiValue(v MultiValue, filter *Filter) bool {
+	switch filter.Attribute {
+	case "value":
+		return v.Value == filter.Value
+	case "type":
+		return strings.EqualFold(v.Type, filter.Value)
+	case "primary":
+		return strconv.FormatBool(v.Primary) == strings.ToLower(filter.Value)
+	}
+	return false
+}
+
+func stringValue(op string, value any) (string, error) {
+	if op == opRemove || value == nil {
+		return "", nil
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", invalidErrorf(ErrorTypeInvalidValue, "%v is not a string", value)
+}
+
+// boolValue also accepts the strings some identity providers send instead of booleans
+func boolValue(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(strings.ToLower(v)); err == nil {
+			return b, nil
+		}
+	}
+	return false, invalidErrorf(ErrorTypeInvalidValue, "%v is not a boolean", value)
+}
+
+// multiValues reads a multi-valued attribute, a single item is accepted as well
+func multiValues(value any) ([]MultiValue, error) {
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	values := make([]MultiValue, 0, len(items))
+	for _, item := range items {
+		attributes, ok := item.(map[string]any)
+		if !ok {
+			return nil, invalidErrorf(ErrorTypeInvalidValue, "%v is not a multi-valued attribute", value)
+		}
+		var v MultiValue
+		for attribute, value := range attributes {
+			var err error
+			switch strings.ToLower(attribute) {
+			case "value":
+				v.Value, err = stringValue(opAdd, value)
+			case "display":
+				v.Display, err = stringValue(opAdd, value)
+			case "type":
+				v.Type, err = stringValue(opAdd, value)
+			case "primary":
+				v.Primary, err = boolValue(value)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
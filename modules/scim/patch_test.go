@@ -0,0 +1,99 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/modules/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parsePatch(t *testing.T, patch string) []Operation {
+	t.Helper()
+	var p PatchOp
+	require.NoError(t, json.Unmarshal([]byte(patch), &p))
+	return p.Operations
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	active := true
+	u := &User{
+		UserName: "alice",
+		Name:     &Name{GivenName: "Alice", FamilyName: "Liddell"},
+		Active:   &active,
+		Emails:   []MultiValue{{Value: "alice@example.com", Type: "work", Primary: true}},
+	}
+
+	require.NoError(t, ApplyUserPatch(u, parsePatch(t, `{"Operations": [
+		{"op": "Replace", "path": "name.givenName", "value": "Alicia"},
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "alicia@example.com"},
+		{"op": "add", "path": "emails[type eq \"home\"].value", "value": "alicia@example.org"},
+		{"op": "replace", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", "value": "R&D"},
+		{"op": "replace", "value": {"displayName": "Alicia L.", "active": "False"}}
+	]}`)))
+	assert.Equal(t, "alice", u.UserName)
+	assert.Equal(t, "Alicia", u.Name.GivenName)
+	assert.Equal(t, "Liddell", u.Name.FamilyName)
+	assert.Equal(t, "Alicia L.", u.FullName())
+	assert.False(t, u.IsActive())
+	assert.Equal(t, []MultiValue{
+		{Value: "alicia@example.com", Type: "work", Primary: true},
+		{Value: "alicia@example.org", Type: "home"},
+	}, u.Emails)
+	assert.Equal(t, "alicia@example.com", u.PrimaryEmail())
+
+	require.NoError(t, ApplyUserPatch(u, parsePatch(t, `{"Operations": [
+		{"op": "remove", "path": "displayName"},
+		{"op": "remove", "path": "emails[type eq \"work\"]"},
+		{"op": "replace", "path": "active", "value": true}
+	]}`)))
+	assert.Equal(t, "Alicia Liddell", u.FullName())
+	assert.True(t, u.IsActive())
+	assert.Equal(t, "alicia@example.org", u.PrimaryEmail())
+
+	for _, patch := range []string{
+		`{"Operations": [{"op": "move", "path": "userName", "value": "bob"}]}`,
+		`{"Operations": [{"op": "remove"}]}`,
+		`{"Operations": [{"op": "replace", "value": "bob"}]}`,
+		`{"Operations": [{"op": "replace", "path": "active", "value": "maybe"}]}`,
+		`{"Operations": [{"op": "replace", "path": "userName", "value": {"a": "b"}}]}`,
+	} {
+		t.Run(patch, func(t *testing.T) {
+			var invalid *InvalidError
+			assert.ErrorAs(t, ApplyUserPatch(u, parsePatch(t, patch)), &invalid)
+		})
+	}
+}
+
+func TestApplyGroupPatch(t *testing.T) {
+	g := &Group{DisplayName: "developers", Members: []MultiValue{{Value: "1"}, {Value: "2"}}}
+
+	require.NoError(t, ApplyGroupPatch(g, parsePatch(t, `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "2"}, {"value": "3"}]},
+		{"op": "remove", "path": "members[value eq \"1\"]"},
+		{"op": "replace", "value": {"displayName": "Developers", "externalId": "abc"}}
+	]}`)))
+	assert.Equal(t, "Developers", g.DisplayName)
+	assert.Equal(t, "abc", g.ExternalID)
+	assert.Equal(t, []MultiValue{{Value: "2"}, {Value: "3"}}, g.Members)
+
+	require.NoError(t, ApplyGroupPatch(g, parsePatch(t, `{"Operations": [
+		{"op": "remove", "path": "members", "value": [{"value": "3"}]},
+		{"op": "add", "path": "members", "value": {"value": "4"}}
+	]}`)))
+	assert.Equal(t, []MultiValue{{Value: "2"}, {Value: "4"}}, g.Members)
+
+	require.NoError(t, ApplyGroupPatch(g, parsePatch(t, `{"Operations": [{"op": "replace", "path": "members", "value": [{"value": "5"}]}]}`)))
+	assert.Equal(t, []MultiValue{{Value: "5"}}, g.Members)
+
+	require.NoError(t, ApplyGroupPatch(g, parsePatch(t, `{"Operations": [{"op": "remove", "path": "members"}]}`)))
+	assert.Empty(t, g.Members)
+
+	var invalid *InvalidError
+	require.ErrorAs(t, ApplyGroupPatch(g, parsePatch(t, `{"Operations": [{"op": "add", "path": "members[value eq \"1\"]", "value": {"value": "1"}}]}`)), &invalid)
+	assert.Equal(t, ErrorTypeInvalidPath, invalid.ScimType)
+}
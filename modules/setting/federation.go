@@ -13,16 +13,24 @@ import (
 var (
 	Federation = struct {
 		Enabled             bool
+		AllowlistMode       bool
 		ShareUserStatistics bool
 		MaxSize             int64
+		MaxDeliveryAttempts int
+		MaxMergeRequestSize int64
+		AllowedHostList     string
 		Algorithms          []string
 		DigestAlgorithm     string
 		GetHeaders          []string
 		PostHeaders         []string
 	}{
 		Enabled:             false,
+		AllowlistMode:       false,
 		ShareUserStatistics: true,
 		MaxSize:             4,
+		MaxDeliveryAttempts: 8,
+		MaxMergeRequestSize: 100,
+		AllowedHostList:     "external",
 		Algorithms:          []string{"rsa-sha256", "rsa-sha512", "ed25519"},
 		DigestAlgorithm:     "SHA-256",
 		GetHeaders:          []string{"(request-target)", "Date", "Host"},
@@ -43,6 +51,7 @@ func loadFederationFrom(rootCfg ConfigProvider) {
 
 	// Get MaxSize in bytes instead of MiB
 	Federation.MaxSize = 1 << 20 * Federation.MaxSize
+	Federation.MaxMergeRequestSize = 1 << 20 * Federation.MaxMergeRequestSize
 
 	HttpsigAlgs = make([]httpsig.Algorithm, len(Federation.Algorithms))
 	for i, alg := range Federation.Algorithms {
@@ -39,6 +39,12 @@ var Git = struct {
 		GC      int `ini:"GC"`
 		Grep    int
 	} `ini:"git.timeout"`
+	Maintenance struct {
+		LooseObjectsThreshold int64
+		PacksThreshold        int64
+		PruneExpire           string
+		Timeout               time.Duration
+	} `ini:"git.maintenance"`
 }{
 	DisableDiffHighlight:      false,
 	MaxGitDiffLines:           1000,
@@ -70,6 +76,17 @@ var Git = struct {
 		GC:      60,
 		Grep:    2,
 	},
+	Maintenance: struct {
+		LooseObjectsThreshold int64
+		PacksThreshold        int64
+		PruneExpire           string
+		Timeout               time.Duration
+	}{
+		LooseObjectsThreshold: 1000,
+		PacksThreshold:        10,
+		PruneExpire:           "2.weeks.ago",
+		Timeout:               time.Hour,
+	},
 }
 
 type GitConfigType struct {
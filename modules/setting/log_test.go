@@ -62,6 +62,9 @@ func TestLogConfigDefault(t *testing.T) {
 	dump = manager.GetLogger("access").DumpWriters()
 	require.JSONEq(t, "{}", toJSON(dump))
 
+	dump = manager.GetLogger("audit").DumpWriters()
+	require.JSONEq(t, "{}", toJSON(dump))
+
 	dump = manager.GetLogger("router").DumpWriters()
 	require.JSONEq(t, writerDump, toJSON(dump))
 
@@ -384,3 +387,39 @@ COMPRESSION_LEVEL = 4
 	expected = strings.ReplaceAll(expected, "$FILENAME-1", tempPath("file-xxx.log"))
 	require.JSONEq(t, expected, toJSON(dump))
 }
+
+func TestLogConfigAuditSyslog(t *testing.T) {
+	manager, managerClose := initLoggersByConfig(t, `
+[log]
+logger.audit.MODE = syslog
+
+[log.syslog]
+NETWORK = udp
+ADDR = 127.0.0.1:514
+TAG = forgejo-audit
+`)
+	defer managerClose()
+
+	writerDump := `
+{
+	"syslog.audit": {
+		"BufferLen": 10000,
+		"Colorize": false,
+		"Expression": "",
+		"Flags": "none",
+		"Level": "info",
+		"Prefix": "",
+		"StacktraceLevel": "none",
+		"WriterOption": {
+			"Addr": "127.0.0.1:514",
+			"Network": "udp",
+			"Tag": "forgejo-audit"
+		},
+		"WriterType": "syslog"
+	}
+}
+`
+
+	dump := manager.GetLogger("audit").DumpWriters()
+	require.JSONEq(t, writerDump, toJSON(dump))
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/dustin/go-humanize"
 )
@@ -36,7 +37,9 @@ var (
 		LimitSizeGeneric      int64
 		LimitSizeGo           int64
 		LimitSizeHelm         int64
+		LimitSizeHex          int64
 		LimitSizeMaven        int64
+		LimitSizeNix          int64
 		LimitSizeNpm          int64
 		LimitSizeNuGet        int64
 		LimitSizePub          int64
@@ -44,11 +47,20 @@ var (
 		LimitSizeRpm          int64
 		LimitSizeRubyGems     int64
 		LimitSizeSwift        int64
+		LimitSizeTerraform    int64
 		LimitSizeVagrant      int64
 		DefaultRPMSignEnabled bool
+
+		ProxyAllowedHostList string
+		ProxyTimeout         time.Duration
+
+		BlockCriticalVulnerabilities bool
+		AttestationTrustedRoot       string
 	}{
 		Enabled:              true,
 		LimitTotalOwnerCount: -1,
+		ProxyAllowedHostList: "external",
+		ProxyTimeout:         5 * time.Minute,
 	}
 )
 
@@ -96,7 +108,9 @@ func loadPackagesFrom(rootCfg ConfigProvider) (err error) {
 	Packages.LimitSizeGeneric = mustBytes(sec, "LIMIT_SIZE_GENERIC")
 	Packages.LimitSizeGo = mustBytes(sec, "LIMIT_SIZE_GO")
 	Packages.LimitSizeHelm = mustBytes(sec, "LIMIT_SIZE_HELM")
+	Packages.LimitSizeHex = mustBytes(sec, "LIMIT_SIZE_HEX")
 	Packages.LimitSizeMaven = mustBytes(sec, "LIMIT_SIZE_MAVEN")
+	Packages.LimitSizeNix = mustBytes(sec, "LIMIT_SIZE_NIX")
 	Packages.LimitSizeNpm = mustBytes(sec, "LIMIT_SIZE_NPM")
 	Packages.LimitSizeNuGet = mustBytes(sec, "LIMIT_SIZE_NUGET")
 	Packages.LimitSizePub = mustBytes(sec, "LIMIT_SIZE_PUB")
@@ -104,8 +118,16 @@ func loadPackagesFrom(rootCfg ConfigProvider) (err error) {
 	Packages.LimitSizeRpm = mustBytes(sec, "LIMIT_SIZE_RPM")
 	Packages.LimitSizeRubyGems = mustBytes(sec, "LIMIT_SIZE_RUBYGEMS")
 	Packages.LimitSizeSwift = mustBytes(sec, "LIMIT_SIZE_SWIFT")
+	Packages.LimitSizeTerraform = mustBytes(sec, "LIMIT_SIZE_TERRAFORM")
 	Packages.LimitSizeVagrant = mustBytes(sec, "LIMIT_SIZE_VAGRANT")
 	Packages.DefaultRPMSignEnabled = sec.Key("DEFAULT_RPM_SIGN_ENABLED").MustBool(false)
+	Packages.ProxyAllowedHostList = sec.Key("PROXY_ALLOWED_HOST_LIST").MustString("external")
+	Packages.ProxyTimeout = sec.Key("PROXY_TIMEOUT").MustDuration(5 * time.Minute)
+	Packages.BlockCriticalVulnerabilities = sec.Key("BLOCK_CRITICAL_VULNERABILITIES").MustBool(false)
+	Packages.AttestationTrustedRoot = sec.Key("ATTESTATION_TRUSTED_ROOT").MustString("")
+	if Packages.AttestationTrustedRoot != "" && !filepath.IsAbs(Packages.AttestationTrustedRoot) {
+		Packages.AttestationTrustedRoot = filepath.Join(CustomPath, Packages.AttestationTrustedRoot)
+	}
 	return nil
 }
 
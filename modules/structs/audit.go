@@ -0,0 +1,29 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package structs
+
+import "time"
+
+// AuditEvent is a security relevant action recorded in the audit log
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	// the kind of action
+	Action    string `json:"action"`
+	ActorID   int64  `json:"actor_id"`
+	ActorName string `json:"actor_name"`
+	// the user or organization the event belongs to, 0 for instance wide events
+	OwnerID int64 `json:"owner_id"`
+	// the repository the event belongs to, 0 if none
+	RepoID int64 `json:"repo_id"`
+	// the kind of object the action was applied to
+	//
+	// enum: ["user", "organization", "team", "repository", "branch_protection", "access_token", "public_key", "deploy_key"]
+	TargetType string `json:"target_type"`
+	TargetID   int64  `json:"target_id"`
+	TargetName string `json:"target_name"`
+	Message    string `json:"message"`
+	IPAddress  string `json:"ip_address"`
+	// swagger:strfmt date-time
+	Created time.Time `json:"created"`
+}
@@ -10,3 +10,13 @@ type CreateForkOption struct {
 	// name of the forked repository
 	Name *string `json:"name"`
 }
+
+// CreateFederatedForkOption options for forking a repository of another federated instance
+type CreateFederatedForkOption struct {
+	// ActivityPub id of the repository to fork
+	Repository string `json:"repository" binding:"Required"`
+	// organization name, if forking into an organization
+	Organization *string `json:"organization"`
+	// name of the forked repository, defaults to the name of the federated repository
+	Name *string `json:"name"`
+}
@@ -31,3 +31,39 @@ type PackageFile struct {
 	HashSHA256 string `json:"sha256"`
 	HashSHA512 string `json:"sha512"`
 }
+
+// PackageVulnerability represents an advisory affecting a package version or one of its dependencies
+type PackageVulnerability struct {
+	AdvisoryID string `json:"advisory_id"`
+	Summary    string `json:"summary"`
+	// enum: critical,high,moderate,low,unknown
+	Severity       string `json:"severity"`
+	Ecosystem      string `json:"ecosystem"`
+	PackageName    string `json:"package_name"`
+	PackageVersion string `json:"package_version"`
+	URL            string `json:"url"`
+}
+
+// PackageAttestation represents a provenance or signature attestation of a package version
+type PackageAttestation struct {
+	ID int64 `json:"id"`
+	// enum: in-toto,sigstore-bundle
+	Format        string `json:"format"`
+	PredicateType string `json:"predicate_type"`
+	// enum: upload,actions
+	Source string `json:"source"`
+	// Verified is true if the provenance was recorded by this instance, or if the certificate of the
+	// signer was verified against the Sigstore trusted root configured by the administrator
+	Verified         bool   `json:"verified"`
+	Signed           bool   `json:"signed"`
+	SignerIdentity   string `json:"signer_identity,omitempty"`
+	SignerIssuer     string `json:"signer_issuer,omitempty"`
+	BuilderID        string `json:"builder_id,omitempty"`
+	SourceRepository string `json:"source_repository,omitempty"`
+	SourceRef        string `json:"source_ref,omitempty"`
+	CommitSHA        string `json:"commit_sha,omitempty"`
+	Workflow         string `json:"workflow,omitempty"`
+	RunURL           string `json:"run_url,omitempty"`
+	// swagger:strfmt date-time
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -89,6 +89,16 @@ type CreatePullRequestOption struct {
 	Deadline *time.Time `json:"due_date"`
 }
 
+// CreateFederatedPullRequestOption options when offering a pull request to the federated repository a fork was created from
+type CreateFederatedPullRequestOption struct {
+	// branch of the fork
+	Head string `json:"head" binding:"Required"`
+	// branch of the federated repository
+	Base  string `json:"base" binding:"Required"`
+	Title string `json:"title" binding:"Required"`
+	Body  string `json:"body"`
+}
+
 // EditPullRequestOption options when modify pull request
 type EditPullRequestOption struct {
 	Title     string   `json:"title"`
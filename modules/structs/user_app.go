@@ -16,6 +16,14 @@ type AccessToken struct {
 	Token          string   `json:"sha1"`
 	TokenLastEight string   `json:"token_last_eight"`
 	Scopes         []string `json:"scopes"`
+	// swagger:strfmt date-time
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// swagger:strfmt date-time
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// Organization the token is restricted to
+	Organization string `json:"organization,omitempty"`
+	// Repositories the token is restricted to, as owner/name
+	Repositories []string `json:"repositories,omitempty"`
 }
 
 // AccessTokenList represents a list of API access token.
@@ -27,6 +35,13 @@ type CreateAccessTokenOption struct {
 	// required: true
 	Name   string   `json:"name" binding:"Required"`
 	Scopes []string `json:"scopes"`
+	// Expiry date of the token, required when the administrator set a maximum lifetime for the tokens
+	// swagger:strfmt date-time
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Organization to restrict the token to, the token can then only access its repositories
+	Organization string `json:"organization"`
+	// Repositories to restrict the token to, as owner/name
+	Repositories []string `json:"repositories"`
 }
 
 // CreateOAuth2ApplicationOptions holds options to create an oauth2 application
@@ -4,6 +4,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 )
@@ -12,3 +13,14 @@ import (
 func IsAPIPath(req *http.Request) bool {
 	return strings.HasPrefix(req.URL.Path, "/api/")
 }
+
+type requestContextKeyType struct{}
+
+// RequestContextKey is the key of the request handled by a web or API context
+var RequestContextKey any = requestContextKeyType{}
+
+// GetRequest returns the request being handled, nil if the context does not belong to a request
+func GetRequest(ctx context.Context) *http.Request {
+	req, _ := ctx.Value(RequestContextKey).(*http.Request)
+	return req
+}
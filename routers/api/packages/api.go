@@ -27,7 +27,9 @@ import (
 	"code.gitea.io/gitea/routers/api/packages/generic"
 	"code.gitea.io/gitea/routers/api/packages/goproxy"
 	"code.gitea.io/gitea/routers/api/packages/helm"
+	"code.gitea.io/gitea/routers/api/packages/hex"
 	"code.gitea.io/gitea/routers/api/packages/maven"
+	"code.gitea.io/gitea/routers/api/packages/nix"
 	"code.gitea.io/gitea/routers/api/packages/npm"
 	"code.gitea.io/gitea/routers/api/packages/nuget"
 	"code.gitea.io/gitea/routers/api/packages/pub"
@@ -35,6 +37,7 @@ import (
 	"code.gitea.io/gitea/routers/api/packages/rpm"
 	"code.gitea.io/gitea/routers/api/packages/rubygems"
 	"code.gitea.io/gitea/routers/api/packages/swift"
+	"code.gitea.io/gitea/routers/api/packages/terraform"
 	"code.gitea.io/gitea/routers/api/packages/vagrant"
 	"code.gitea.io/gitea/services/auth"
 	"code.gitea.io/gitea/services/context"
@@ -136,8 +139,22 @@ func CommonRoutes() *web.Route {
 		&nuget.Auth{},
 		&conan.Auth{},
 		&chef.Auth{},
+		&hex.Auth{},
 	})
 
+	// Terraform resolves the registry from the service discovery of the instance, and
+	// gives the owner as the namespace of the modules and providers
+	r.Group("/-/terraform", func() {
+		r.Group("/modules/v1/{username}/{name}/{system}", func() {
+			r.Get("/versions", terraform.ListModuleVersions)
+			r.Get("/{version}/download", terraform.DownloadModuleVersion)
+		})
+		r.Group("/providers/v1/{username}/{type}", func() {
+			r.Get("/versions", terraform.ListProviderVersions)
+			r.Get("/{version}/download/{os}/{arch}", terraform.ProviderPackage)
+		})
+	}, context.UserAssignmentWeb(), context.PackageAssignment(), reqPackageAccess(perm.AccessModeRead))
+
 	r.Group("/{username}", func() {
 		r.Group("/alpine", func() {
 			r.Get("/key", alpine.GetRepositoryKey)
@@ -422,6 +439,25 @@ func CommonRoutes() *web.Route {
 					r.Delete("/{name}/{version}/{architecture}", debian.DeletePackageFile)
 				}, reqPackageAccess(perm.AccessModeWrite))
 			})
+			r.Group("/-", func() {
+				r.Get("/snapshots", debian.ListSnapshots)
+				r.Group("/snapshots/{snapshot}", func() {
+					r.Group("", func() {
+						r.Put("", debian.CreateSnapshot)
+						r.Delete("", debian.DeleteSnapshot)
+					}, reqPackageAccess(perm.AccessModeWrite))
+					r.Group("/dists/{distribution}", func() {
+						r.Get("/{filename}", debian.GetRepositoryFile)
+						r.Get("/by-hash/{algorithm}/{hash}", debian.GetRepositoryFileByHash)
+						r.Group("/{component}/{architecture}", func() {
+							r.Get("/{filename}", debian.GetRepositoryFile)
+							r.Get("/by-hash/{algorithm}/{hash}", debian.GetRepositoryFileByHash)
+						})
+					})
+					r.Get("/pool/{distribution}/{component}/{name}_{version}_{architecture}.deb", debian.DownloadSnapshotPackageFile)
+				})
+				r.Post("/promote", reqPackageAccess(perm.AccessModeWrite), debian.Promote)
+			})
 		}, reqPackageAccess(perm.AccessModeRead))
 		r.Group("/go", func() {
 			r.Put("/upload", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), goproxy.UploadPackage)
@@ -498,11 +534,47 @@ func CommonRoutes() *web.Route {
 			r.Get("/{filename}", helm.DownloadPackageFile)
 			r.Post("/api/charts", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), helm.UploadPackage)
 		}, reqPackageAccess(perm.AccessModeRead))
+		r.Group("/hex", func() {
+			r.Get("/public_key", hex.RepositoryPublicKey)
+			r.Get("/names", hex.RepositoryNames)
+			r.Get("/versions", hex.RepositoryVersions)
+			r.Get("/packages/{name}", hex.RepositoryPackage)
+			r.Get("/tarballs/{filename}", hex.DownloadPackageFile)
+			r.Group("/api", func() {
+				r.Post("/publish", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), hex.UploadPackage)
+				r.Group("/packages/{name}", func() {
+					r.Get("", hex.PackageInfo)
+					r.Post("/releases", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), hex.UploadPackage)
+					r.Group("/releases/{version}", func() {
+						r.Get("", hex.ReleaseInfo)
+						r.Delete("", reqPackageAccess(perm.AccessModeWrite), hex.DeleteRelease)
+						r.Post("/retire", reqPackageAccess(perm.AccessModeWrite), hex.RetireRelease)
+						r.Delete("/retire", reqPackageAccess(perm.AccessModeWrite), hex.UnretireRelease)
+					})
+				})
+			})
+		}, reqPackageAccess(perm.AccessModeRead))
 		r.Group("/maven", func() {
 			r.Put("/*", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), maven.UploadPackageFile)
 			r.Get("/*", maven.DownloadPackageFile)
 			r.Head("/*", maven.ProvidePackageFileHeader)
 		}, reqPackageAccess(perm.AccessModeRead))
+		r.Group("/nix", func() {
+			r.Get("/nix-cache-info", nix.CacheInfo)
+			r.Head("/nix-cache-info", nix.CacheInfo)
+			r.Get("/public-key", nix.PublicKey)
+			r.Group("/nar/{filename}", func() {
+				r.Get("", nix.DownloadNar)
+				r.Head("", nix.CheckNar)
+				r.Put("", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), nix.UploadNar)
+			})
+			r.Group("/{filename}", func() {
+				r.Get("", nix.GetNarInfo)
+				r.Head("", nix.GetNarInfo)
+				r.Put("", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), nix.UploadNarInfo)
+				r.Delete("", reqPackageAccess(perm.AccessModeWrite), nix.DeleteNarInfo)
+			})
+		}, reqPackageAccess(perm.AccessModeRead))
 		r.Group("/nuget", func() {
 			r.Group("", func() { // Needs to be unauthenticated for the NuGet client.
 				r.Get("/", nuget.ServiceIndexV2)
@@ -609,6 +681,23 @@ func CommonRoutes() *web.Route {
 				r.Head("", rpm.GetRepositoryKey)
 				r.Get("", rpm.GetRepositoryKey)
 			})
+			r.Group("/-", func() {
+				r.Get("/snapshots", rpm.ListSnapshots)
+				r.Group("/snapshots/{snapshot}", func() {
+					r.Group("", func() {
+						r.Put("", rpm.CreateSnapshot)
+						r.Delete("", rpm.DeleteSnapshot)
+					}, reqPackageAccess(perm.AccessModeWrite))
+					r.Get("/snapshot.repo", rpm.GetSnapshotRepositoryConfig)
+					r.Head("/repodata/{filename}", rpm.CheckRepositoryFileExistence)
+					r.Get("/repodata/{filename}", rpm.GetRepositoryFile)
+					r.Group("/package/{name}/{version}/{architecture}", func() {
+						r.Methods("HEAD,GET", "", rpm.DownloadSnapshotPackageFile)
+						r.Methods("HEAD,GET", "/{filename}", rpm.DownloadSnapshotPackageFile)
+					})
+				})
+				r.Post("/promote", reqPackageAccess(perm.AccessModeWrite), rpm.Promote)
+			})
 
 			var (
 				repoPattern     = regexp.MustCompile(`\A(.*?)\.repo\z`)
@@ -727,6 +816,22 @@ func CommonRoutes() *web.Route {
 			})
 			r.Get("/identifiers", swift.CheckAcceptMediaType(swift.AcceptJSON), swift.LookupPackageIdentifiers)
 		}, reqPackageAccess(perm.AccessModeRead))
+		r.Group("/terraform", func() {
+			r.Group("/modules/{name}/{system}/{version}", func() {
+				r.Get("", terraform.DownloadModuleArchive)
+				r.Put("", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), terraform.UploadModule)
+				r.Delete("", reqPackageAccess(perm.AccessModeWrite), terraform.DeleteModuleVersion)
+			})
+			r.Group("/providers/{type}/{version}", func() {
+				r.Delete("", reqPackageAccess(perm.AccessModeWrite), terraform.DeleteProviderVersion)
+				r.Get("/SHA256SUMS", terraform.ProviderSHASums)
+				r.Get("/SHA256SUMS.sig", terraform.ProviderSHASumsSignature)
+				r.Group("/{os}/{arch}", func() {
+					r.Get("", terraform.DownloadProviderFile)
+					r.Put("", reqPackageAccess(perm.AccessModeWrite), enforcePackagesQuota(), terraform.UploadProvider)
+				})
+			})
+		}, reqPackageAccess(perm.AccessModeRead))
 		r.Group("/vagrant", func() {
 			r.Group("/authenticate", func() {
 				r.Get("", vagrant.CheckAuthenticate)
@@ -787,12 +892,14 @@ func ContainerRoutes() *web.Route {
 				r.Delete("", reqPackageAccess(perm.AccessModeWrite), container.DeleteManifest)
 			})
 			r.Get("/tags/list", container.GetTagList)
+			r.Get("/referrers/{digest}", container.GetReferrers)
 		}, container.VerifyImageName)
 
 		var (
 			blobsUploadsPattern = regexp.MustCompile(`\A(.+)/blobs/uploads/([a-zA-Z0-9-_.=]+)\z`)
 			blobsPattern        = regexp.MustCompile(`\A(.+)/blobs/([^/]+)\z`)
 			manifestsPattern    = regexp.MustCompile(`\A(.+)/manifests/([^/]+)\z`)
+			referrersPattern    = regexp.MustCompile(`\A(.+)/referrers/([^/]+)\z`)
 		)
 
 		// Manual mapping of routes because {image} can contain slashes which chi does not support
@@ -908,6 +1015,20 @@ func ContainerRoutes() *web.Route {
 				return
 			}
 
+			m = referrersPattern.FindStringSubmatch(path)
+			if len(m) == 3 && isGet {
+				ctx.SetParams("image", m[1])
+				container.VerifyImageName(ctx)
+				if ctx.Written() {
+					return
+				}
+
+				ctx.SetParams("digest", m[2])
+
+				container.GetReferrers(ctx)
+				return
+			}
+
 			ctx.Status(http.StatusNotFound)
 		})
 	}, container.ReqContainerAccess, context.UserAssignmentWeb(), context.PackageAssignment(), reqPackageAccess(perm.AccessModeRead))
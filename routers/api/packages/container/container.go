@@ -30,6 +30,8 @@ import (
 	container_service "code.gitea.io/gitea/services/packages/container"
 
 	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // maximum size of a container manifest
@@ -49,6 +51,8 @@ type containerHeaders struct {
 	Location      string
 	ContentType   string
 	ContentLength int64
+	Subject       string
+	Filters       string
 }
 
 // https://github.com/opencontainers/distribution-spec/blob/main/spec.md#legacy-docker-support-http-headers
@@ -72,6 +76,12 @@ func setResponseHeaders(resp http.ResponseWriter, h *containerHeaders) {
 		resp.Header().Set("Docker-Content-Digest", h.ContentDigest)
 		resp.Header().Set("ETag", fmt.Sprintf(`"%s"`, h.ContentDigest))
 	}
+	if h.Subject != "" {
+		resp.Header().Set("OCI-Subject", h.Subject)
+	}
+	if h.Filters != "" {
+		resp.Header().Set("OCI-Filters-Applied", h.Filters)
+	}
 	resp.Header().Set("Docker-Distribution-Api-Version", "registry/2.0")
 	resp.WriteHeader(h.Status)
 }
@@ -588,6 +598,7 @@ func UploadManifest(ctx *context.Context) {
 	setResponseHeaders(ctx.Resp, &containerHeaders{
 		Location:      fmt.Sprintf("/v2/%s/%s/manifests/%s", ctx.Package.Owner.LowerName, mci.Image, reference),
 		ContentDigest: digest,
+		Subject:       mci.Properties[container_module.PropertyManifestSubject],
 		Status:        http.StatusCreated,
 	})
 }
@@ -618,7 +629,20 @@ func getManifestFromContext(ctx *context.Context) (*packages_model.PackageFileDe
 		return nil, err
 	}
 
-	return workaroundGetContainerBlob(ctx, opts)
+	pfd, err := workaroundGetContainerBlob(ctx, opts)
+	if err == container_model.ErrContainerBlobNotExist {
+		cached, cacheErr := cacheUpstreamManifest(ctx, opts)
+		if cacheErr != nil {
+			if errors.Is(cacheErr, util.ErrNotExist) {
+				return nil, container_model.ErrContainerBlobNotExist
+			}
+			return nil, cacheErr
+		}
+		if cached {
+			pfd, err = workaroundGetContainerBlob(ctx, opts)
+		}
+	}
+	return pfd, err
 }
 
 // https://github.com/opencontainers/distribution-spec/blob/main/spec.md#checking-if-content-exists-in-the-registry
@@ -766,6 +790,66 @@ func GetTagList(ctx *context.Context) {
 	})
 }
 
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers
+func GetReferrers(ctx *context.Context) {
+	image := ctx.Params("image")
+	subject := ctx.Params("digest")
+
+	if digest.Digest(subject).Validate() != nil {
+		apiErrorDefined(ctx, errDigestInvalid)
+		return
+	}
+
+	if _, err := packages_model.GetPackageByName(ctx, ctx.Package.Owner.ID, packages_model.TypeContainer, image); err != nil {
+		if err == packages_model.ErrPackageNotExist {
+			apiErrorDefined(ctx, errNameUnknown)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	artifactType := ctx.FormTrim("artifactType")
+
+	referrers, err := container_model.GetReferrers(ctx, &container_model.ReferrerSearchOptions{
+		OwnerID:      ctx.Package.Owner.ID,
+		Image:        image,
+		Subject:      subject,
+		ArtifactType: artifactType,
+	})
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	index := &oci.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: oci.MediaTypeImageIndex,
+		Manifests: make([]oci.Descriptor, 0, len(referrers)),
+	}
+	for _, r := range referrers {
+		index.Manifests = append(index.Manifests, oci.Descriptor{
+			MediaType:    r.MediaType,
+			ArtifactType: r.ArtifactType,
+			Digest:       digest.Digest(r.Digest),
+			Size:         r.Manifest.Blob.Size,
+			Annotations:  r.Annotations,
+		})
+	}
+
+	headers := &containerHeaders{
+		ContentType: oci.MediaTypeImageIndex,
+		Status:      http.StatusOK,
+	}
+	if artifactType != "" {
+		headers.Filters = "artifactType"
+	}
+	setResponseHeaders(ctx.Resp, headers)
+	if err := json.NewEncoder(ctx.Resp).Encode(index); err != nil {
+		log.Error("JSON encode: %v", err)
+	}
+}
+
 // FIXME: Workaround to be removed in v1.20
 // https://github.com/go-gitea/gitea/issues/19586
 func workaroundGetContainerBlob(ctx *context.Context, opts *container_model.BlobSearchOptions) (*packages_model.PackageFileDescriptor, error) {
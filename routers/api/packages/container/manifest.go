@@ -87,6 +87,13 @@ func processImageManifest(ctx context.Context, mci *manifestCreationInfo, buf *p
 		if err := json.NewDecoder(buf).Decode(&manifest); err != nil {
 			return err
 		}
+		artifactType := manifest.ArtifactType
+		if artifactType == "" {
+			artifactType = manifest.Config.MediaType
+		}
+		if err := setReferrerProperties(mci, manifest.Subject, artifactType, manifest.Annotations); err != nil {
+			return err
+		}
 
 		if _, err := buf.Seek(0, io.SeekStart); err != nil {
 			return err
@@ -204,6 +211,9 @@ func processImageManifestIndex(ctx context.Context, mci *manifestCreationInfo, b
 		if err := json.NewDecoder(buf).Decode(&index); err != nil {
 			return err
 		}
+		if err := setReferrerProperties(mci, index.Subject, index.ArtifactType, index.Annotations); err != nil {
+			return err
+		}
 
 		if _, err := buf.Seek(0, io.SeekStart); err != nil {
 			return err
@@ -300,6 +310,33 @@ func processImageManifestIndex(ctx context.Context, mci *manifestCreationInfo, b
 	return manifestDigest, nil
 }
 
+// setReferrerProperties remembers the manifest the uploaded manifest refers to
+// and the information needed to list it as referrer
+func setReferrerProperties(mci *manifestCreationInfo, subject *oci.Descriptor, artifactType string, annotations map[string]string) error {
+	if subject == nil || subject.Digest == "" {
+		return nil
+	}
+	if subject.Digest.Validate() != nil {
+		return errManifestInvalid.WithMessage("Subject digest is invalid")
+	}
+
+	if mci.Properties == nil {
+		mci.Properties = make(map[string]string)
+	}
+	mci.Properties[container_module.PropertyManifestSubject] = string(subject.Digest)
+	if artifactType != "" {
+		mci.Properties[container_module.PropertyManifestArtifactType] = artifactType
+	}
+	if len(annotations) > 0 {
+		annotationsJSON, err := json.Marshal(annotations)
+		if err != nil {
+			return err
+		}
+		mci.Properties[container_module.PropertyManifestAnnotations] = string(annotationsJSON)
+	}
+	return nil
+}
+
 func notifyPackageCreate(ctx context.Context, doer *user_model.User, pv *packages_model.PackageVersion) error {
 	pd, err := packages_model.GetPackageDescriptor(ctx, pv)
 	if err != nil {
@@ -386,6 +423,12 @@ func createPackageAndVersion(ctx context.Context, mci *manifestCreationInfo, met
 			return nil, err
 		}
 	}
+	for name, value := range mci.Properties {
+		if _, err := packages_model.InsertProperty(ctx, packages_model.PropertyTypeVersion, pv.ID, name, value); err != nil {
+			log.Error("Error setting package version property: %v", err)
+			return nil, err
+		}
+	}
 
 	return pv, nil
 }
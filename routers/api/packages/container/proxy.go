@@ -0,0 +1,171 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package container
+
+import (
+	"fmt"
+	"io"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	container_model "code.gitea.io/gitea/models/packages/container"
+	"code.gitea.io/gitea/modules/json"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	proxy_service "code.gitea.io/gitea/services/packages/proxy"
+
+	digest "github.com/opencontainers/go-digest"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var upstreamManifestMediaTypes = []string{
+	oci.MediaTypeImageManifest,
+	oci.MediaTypeImageIndex,
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// cacheUpstreamManifest fetches the manifest and the blobs it references from the upstream registry and stores them.
+// It returns false if the owner did not configure an upstream registry.
+func cacheUpstreamManifest(ctx *context.Context, opts *container_model.BlobSearchOptions) (bool, error) {
+	client, err := proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypeContainer)
+	if err != nil || client == nil {
+		return false, err
+	}
+
+	reference := opts.Tag
+	if reference == "" {
+		reference = opts.Digest
+	}
+
+	defer client.Lock(opts.Image + "/" + reference)()
+
+	// The manifest could have been cached by a concurrent request
+	if _, err := workaroundGetContainerBlob(ctx, opts); err == nil {
+		return true, nil
+	}
+
+	return true, cacheManifest(ctx, client, opts.Image, reference, opts.Tag != "")
+}
+
+func cacheManifest(ctx *context.Context, client *proxy_service.Client, image, reference string, isTagged bool) error {
+	resp, err := client.Get(ctx, client.URL(fmt.Sprintf("v2/%s/manifests/%s", image, reference)), upstreamManifestMediaTypes...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	maxSize := maxManifestSize + 1
+	buf, err := packages_module.CreateHashedBufferFromReaderWithSize(&io.LimitedReader{R: resp.Body, N: int64(maxSize)}, maxSize)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+
+	if buf.Size() > maxManifestSize {
+		return errManifestInvalid.WithMessage("Manifest exceeds maximum size")
+	}
+	if !isTagged && digestFromHashSummer(buf) != reference {
+		return errDigestInvalid
+	}
+
+	var manifest struct {
+		MediaType string           `json:"mediaType"`
+		Config    oci.Descriptor   `json:"config"`
+		Layers    []oci.Descriptor `json:"layers"`
+		Manifests []oci.Descriptor `json:"manifests"`
+	}
+	if err := json.NewDecoder(buf).Decode(&manifest); err != nil {
+		return err
+	}
+	if _, err := buf.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	mediaType := resp.Header.Get("Content-Type")
+	if !isValidMediaType(mediaType) {
+		mediaType = manifest.MediaType
+	}
+
+	if isImageIndexMediaType(mediaType) {
+		for _, m := range manifest.Manifests {
+			_, err := container_model.GetContainerBlob(ctx, &container_model.BlobSearchOptions{
+				OwnerID:    ctx.Package.Owner.ID,
+				Image:      image,
+				Digest:     string(m.Digest),
+				IsManifest: true,
+			})
+			if err == nil {
+				continue
+			} else if err != container_model.ErrContainerBlobNotExist {
+				return err
+			}
+
+			if err := cacheManifest(ctx, client, image, string(m.Digest), false); err != nil {
+				return err
+			}
+		}
+	} else if isImageManifestMediaType(mediaType) {
+		for _, desc := range append([]oci.Descriptor{manifest.Config}, manifest.Layers...) {
+			if err := cacheBlob(ctx, client, image, desc.Digest); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = processManifest(ctx, &manifestCreationInfo{
+		MediaType: mediaType,
+		Owner:     ctx.Package.Owner,
+		Creator:   ctx.Package.Owner,
+		Image:     image,
+		Reference: reference,
+		IsTagged:  isTagged,
+		Properties: map[string]string{
+			packages_model.PropertyProxyUpstream: client.Proxy.URL,
+		},
+	}, buf)
+	return err
+}
+
+func cacheBlob(ctx *context.Context, client *proxy_service.Client, image string, d digest.Digest) error {
+	if d.Validate() != nil {
+		return errDigestInvalid
+	}
+
+	_, err := container_model.GetContainerBlob(ctx, &container_model.BlobSearchOptions{
+		OwnerID: ctx.Package.Owner.ID,
+		Image:   image,
+		Digest:  string(d),
+	})
+	if err == nil {
+		return nil
+	} else if err != container_model.ErrContainerBlobNotExist {
+		return err
+	}
+
+	resp, err := client.Get(ctx, client.URL(fmt.Sprintf("v2/%s/blobs/%s", image, d)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	buf, err := packages_module.CreateHashedBufferFromReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+
+	if digestFromHashSummer(buf) != string(d) {
+		return errDigestInvalid
+	}
+
+	_, err = saveAsPackageBlob(ctx, buf, &packages_service.PackageCreationInfo{
+		PackageInfo: packages_service.PackageInfo{
+			Owner: ctx.Package.Owner,
+			Name:  image,
+		},
+		Creator: ctx.Package.Owner,
+	})
+	return err
+}
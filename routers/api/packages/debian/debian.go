@@ -42,12 +42,25 @@ func GetRepositoryKey(ctx *context.Context) {
 	})
 }
 
+// getRepositoryVersion gets the package version holding the repository files of a snapshot or of the live repository
+func getRepositoryVersion(ctx *context.Context) (*packages_model.PackageVersion, error) {
+	if snapshot := ctx.Params("snapshot"); snapshot != "" {
+		_, pv, err := debian_service.GetSnapshot(ctx, ctx.Package.Owner.ID, snapshot)
+		return pv, err
+	}
+	return debian_service.GetOrCreateRepositoryVersion(ctx, ctx.Package.Owner.ID)
+}
+
 // https://wiki.debian.org/DebianRepository/Format#A.22Release.22_files
 // https://wiki.debian.org/DebianRepository/Format#A.22Packages.22_Indices
 func GetRepositoryFile(ctx *context.Context) {
-	pv, err := debian_service.GetOrCreateRepositoryVersion(ctx, ctx.Package.Owner.ID)
+	pv, err := getRepositoryVersion(ctx)
 	if err != nil {
-		apiError(ctx, http.StatusInternalServerError, err)
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
@@ -81,9 +94,13 @@ func GetRepositoryFile(ctx *context.Context) {
 
 // https://wiki.debian.org/DebianRepository/Format#indices_acquisition_via_hashsums_.28by-hash.29
 func GetRepositoryFileByHash(ctx *context.Context) {
-	pv, err := debian_service.GetOrCreateRepositoryVersion(ctx, ctx.Package.Owner.ID)
+	pv, err := getRepositoryVersion(ctx)
 	if err != nil {
-		apiError(ctx, http.StatusInternalServerError, err)
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
@@ -0,0 +1,155 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package debian
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/routers/api/packages/helper"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	debian_service "code.gitea.io/gitea/services/packages/debian"
+)
+
+// Snapshot is a frozen distribution of the repository
+type Snapshot struct {
+	Name         string    `json:"name"`
+	Distribution string    `json:"distribution"`
+	Created      time.Time `json:"created"`
+}
+
+func toSnapshot(s *packages_service.Snapshot) *Snapshot {
+	return &Snapshot{
+		Name:         s.Name,
+		Distribution: s.Source,
+		Created:      s.CreatedUnix.AsLocalTime(),
+	}
+}
+
+// PromoteOptions are the options to promote package versions to another distribution
+type PromoteOptions struct {
+	From     string                           `json:"from"`
+	To       string                           `json:"to"`
+	Packages []*debian_service.PromotePackage `json:"packages"`
+}
+
+// ListSnapshots lists all snapshots of the repository
+func ListSnapshots(ctx *context.Context) {
+	snapshots, err := debian_service.GetSnapshots(ctx, ctx.Package.Owner.ID)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := make([]*Snapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		result = append(result, toSnapshot(s))
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// CreateSnapshot freezes the current state of a distribution
+func CreateSnapshot(ctx *context.Context) {
+	distribution := ctx.FormTrim("distribution")
+	if distribution == "" {
+		apiError(ctx, http.StatusBadRequest, "distribution is required")
+		return
+	}
+
+	s, err := debian_service.CreateSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot"), distribution)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidArgument):
+			apiError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, util.ErrAlreadyExist):
+			apiError(ctx, http.StatusConflict, err)
+		case errors.Is(err, util.ErrNotExist):
+			apiError(ctx, http.StatusNotFound, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, toSnapshot(s))
+}
+
+// DeleteSnapshot deletes a snapshot
+func DeleteSnapshot(ctx *context.Context) {
+	if err := debian_service.DeleteSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot")); err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// DownloadSnapshotPackageFile serves a package file listed in the index files of a snapshot
+func DownloadSnapshotPackageFile(ctx *context.Context) {
+	_, pv, err := debian_service.GetSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageVersion(
+		ctx,
+		pv,
+		&packages_service.PackageFileInfo{
+			Filename:     fmt.Sprintf("%s_%s_%s.deb", ctx.Params("name"), ctx.Params("version"), ctx.Params("architecture")),
+			CompositeKey: fmt.Sprintf("%s|%s", ctx.Params("distribution"), ctx.Params("component")),
+		},
+	)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	helper.ServePackageFile(ctx, s, u, pf, &context.ServeHeaderOptions{
+		ContentType:  "application/vnd.debian.binary-package",
+		Filename:     pf.Name,
+		LastModified: pf.CreatedUnix.AsLocalTime(),
+	})
+}
+
+// Promote adds package versions of a distribution to another distribution
+func Promote(ctx *context.Context) {
+	var opts PromoteOptions
+	if err := json.NewDecoder(ctx.Req.Body).Decode(&opts); err != nil {
+		apiError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := debian_service.Promote(ctx, ctx.Package.Owner.ID, opts.From, opts.To, opts.Packages); err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidArgument):
+			apiError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, util.ErrNotExist):
+			apiError(ctx, http.StatusNotFound, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -13,6 +13,7 @@ import (
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/services/context"
+	packages_vulnerability_service "code.gitea.io/gitea/services/packages/vulnerability"
 )
 
 // LogAndProcessError logs an error and calls a custom callback with the processed error message.
@@ -42,6 +43,20 @@ func LogAndProcessError(ctx *context.Context, status int, obj any, cb func(strin
 // Serves the content of the package file
 // If the url is set it will redirect the request, otherwise the content is copied to the response.
 func ServePackageFile(ctx *context.Context, s io.ReadSeekCloser, u *url.URL, pf *packages_model.PackageFile, forceOpts ...*context.ServeHeaderOptions) {
+	if blocked, err := packages_vulnerability_service.IsDownloadBlocked(ctx, pf.VersionID); err != nil || blocked {
+		if s != nil {
+			s.Close()
+		}
+		if err != nil {
+			LogAndProcessError(ctx, http.StatusInternalServerError, err, func(message string) {
+				ctx.PlainText(http.StatusInternalServerError, message)
+			})
+			return
+		}
+		ctx.PlainText(http.StatusForbidden, "the package version is affected by a critical vulnerability")
+		return
+	}
+
 	if u != nil {
 		ctx.Redirect(u.String())
 		return
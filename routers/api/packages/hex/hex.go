@@ -0,0 +1,462 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/json"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	hex_module "code.gitea.io/gitea/modules/packages/hex"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/routers/api/packages/helper"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	hex_service "code.gitea.io/gitea/services/packages/hex"
+)
+
+func apiError(ctx *context.Context, status int, obj any) {
+	helper.LogAndProcessError(ctx, status, obj, func(message string) {
+		respond(ctx, status, map[string]any{
+			"status":  status,
+			"message": message,
+		})
+	})
+}
+
+// respond sends the value in the external term format requested by the Hex clients, or as JSON
+func respond(ctx *context.Context, status int, v map[string]any) {
+	if !strings.Contains(ctx.Req.Header.Get("Accept"), hex_module.ContentTypeTerm) {
+		ctx.JSON(status, v)
+		return
+	}
+
+	data, err := hex_module.MarshalTerm(v)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "MarshalTerm", err.Error())
+		return
+	}
+
+	ctx.Resp.Header().Set("Content-Type", hex_module.ContentTypeTerm)
+	ctx.Status(status)
+	_, _ = ctx.Resp.Write(data)
+}
+
+func serveRegistryResource(ctx *context.Context, data []byte, err error) {
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if data == nil {
+		apiError(ctx, http.StatusNotFound, nil)
+		return
+	}
+
+	ctx.Resp.Header().Set("Content-Type", "application/octet-stream")
+	ctx.Status(http.StatusOK)
+	_, _ = ctx.Resp.Write(data)
+}
+
+func apiURL(ctx *context.Context) string {
+	return fmt.Sprintf("%sapi/packages/%s/hex/api", setting.AppURL, ctx.Package.Owner.Name)
+}
+
+func formatTime(pv *packages_model.PackageVersion) string {
+	return pv.CreatedUnix.AsTime().UTC().Format(time.RFC3339)
+}
+
+// RepositoryPublicKey serves the public key the registry resources are signed with
+func RepositoryPublicKey(ctx *context.Context) {
+	_, pub, err := hex_service.GetOrCreateKeyPair(ctx, ctx.Package.Owner.ID)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.PlainText(http.StatusOK, pub)
+}
+
+// RepositoryNames serves the names of all packages
+// https://github.com/hexpm/specifications/blob/main/endpoints.md#repository
+func RepositoryNames(ctx *context.Context) {
+	data, err := hex_service.BuildNames(ctx, ctx.Package.Owner)
+	serveRegistryResource(ctx, data, err)
+}
+
+// RepositoryVersions serves the versions of all packages
+func RepositoryVersions(ctx *context.Context) {
+	data, err := hex_service.BuildVersions(ctx, ctx.Package.Owner)
+	serveRegistryResource(ctx, data, err)
+}
+
+// RepositoryPackage serves the releases of a package
+func RepositoryPackage(ctx *context.Context) {
+	data, err := hex_service.BuildPackage(ctx, ctx.Package.Owner, ctx.Params("name"))
+	serveRegistryResource(ctx, data, err)
+}
+
+// DownloadPackageFile serves the tarball of a release
+func DownloadPackageFile(ctx *context.Context) {
+	filename := ctx.Params("filename")
+
+	// Package names can't contain a dash, versions can
+	name, version, ok := strings.Cut(strings.TrimSuffix(filename, ".tar"), "-")
+	if !ok || !strings.HasSuffix(filename, ".tar") {
+		apiError(ctx, http.StatusNotFound, nil)
+		return
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(
+		ctx,
+		&packages_service.PackageInfo{
+			Owner:       ctx.Package.Owner,
+			PackageType: packages_model.TypeHex,
+			Name:        name,
+			Version:     version,
+		},
+		&packages_service.PackageFileInfo{
+			Filename: filename,
+		},
+	)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	helper.ServePackageFile(ctx, s, u, pf)
+}
+
+// UploadPackage publishes a release, an existing release is only overwritten if requested
+// https://github.com/hexpm/specifications/blob/main/apiary.apib
+func UploadPackage(ctx *context.Context) {
+	upload, needToClose, err := ctx.UploadStream()
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if needToClose {
+		defer upload.Close()
+	}
+
+	buf, err := packages_module.CreateHashedBufferFromReader(upload)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer buf.Close()
+
+	hp, err := hex_module.ParsePackage(buf)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			apiError(ctx, http.StatusUnprocessableEntity, err)
+		} else {
+			apiError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+	if name := ctx.Params("name"); name != "" && name != hp.Name {
+		apiError(ctx, http.StatusUnprocessableEntity, "package name does not match the url")
+		return
+	}
+
+	if _, err := buf.Seek(0, io.SeekStart); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if ctx.FormBool("replace") {
+		pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeHex, hp.Name, hp.Version)
+		if err == nil {
+			err = packages_service.RemovePackageVersion(ctx, ctx.Doer, pv)
+		}
+		if err != nil && !errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	pv, _, err := packages_service.CreatePackageAndAddFile(
+		ctx,
+		&packages_service.PackageCreationInfo{
+			PackageInfo: packages_service.PackageInfo{
+				Owner:       ctx.Package.Owner,
+				PackageType: packages_model.TypeHex,
+				Name:        hp.Name,
+				Version:     hp.Version,
+			},
+			SemverCompatible: true,
+			Creator:          ctx.Doer,
+			Metadata:         hp.Metadata,
+		},
+		&packages_service.PackageFileCreationInfo{
+			PackageFileInfo: packages_service.PackageFileInfo{
+				Filename: fmt.Sprintf("%s-%s.tar", hp.Name, hp.Version),
+			},
+			Creator: ctx.Doer,
+			Data:    buf,
+			IsLead:  true,
+		},
+	)
+	if err != nil {
+		switch err {
+		case packages_model.ErrDuplicatePackageVersion:
+			apiError(ctx, http.StatusConflict, "release already exists, use --replace to overwrite it")
+		case packages_service.ErrQuotaTotalCount, packages_service.ErrQuotaTypeSize, packages_service.ErrQuotaTotalSize:
+			apiError(ctx, http.StatusForbidden, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	pd, err := packages_model.GetPackageDescriptor(ctx, pv)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	respond(ctx, http.StatusCreated, releaseInfo(ctx, pd))
+}
+
+func releaseInfo(ctx *context.Context, pd *packages_model.PackageDescriptor) map[string]any {
+	metadata := pd.Metadata.(*hex_module.Metadata)
+
+	requirements := make(map[string]any, len(metadata.Requirements))
+	for _, r := range metadata.Requirements {
+		app := r.App
+		if app == "" {
+			app = r.Name
+		}
+		requirements[r.Name] = map[string]any{
+			"app":         app,
+			"optional":    r.Optional,
+			"requirement": r.Requirement,
+		}
+	}
+
+	var retirement any
+	if r := hex_service.GetRetirement(pd); r != nil {
+		retirement = map[string]any{
+			"reason":  r.Reason,
+			"message": r.Message,
+		}
+	}
+
+	checksum := ""
+	if len(pd.Files) > 0 {
+		checksum = pd.Files[0].Blob.HashSHA256
+	}
+
+	return map[string]any{
+		"version":     pd.Version.Version,
+		"checksum":    checksum,
+		"has_docs":    false,
+		"downloads":   pd.Version.DownloadCount,
+		"inserted_at": formatTime(pd.Version),
+		"updated_at":  formatTime(pd.Version),
+		"url":         fmt.Sprintf("%s/packages/%s/releases/%s", apiURL(ctx), pd.Package.Name, pd.Version.Version),
+		"package_url": fmt.Sprintf("%s/packages/%s", apiURL(ctx), pd.Package.Name),
+		"html_url":    pd.VersionHTMLURL(),
+		"meta": map[string]any{
+			"app":         metadata.App,
+			"build_tools": metadata.BuildTools,
+			"elixir":      metadata.Elixir,
+		},
+		"requirements": requirements,
+		"retirement":   retirement,
+	}
+}
+
+// PackageInfo describes a package and its releases
+func PackageInfo(ctx *context.Context) {
+	pds, err := hex_service.GetPackageVersions(ctx, ctx.Package.Owner.ID, ctx.Params("name"))
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if len(pds) == 0 {
+		apiError(ctx, http.StatusNotFound, packages_model.ErrPackageNotExist)
+		return
+	}
+
+	latest := pds[len(pds)-1]
+	metadata := latest.Metadata.(*hex_module.Metadata)
+
+	releases := make([]any, 0, len(pds))
+	retirements := make(map[string]any)
+	for i := len(pds) - 1; i >= 0; i-- {
+		pd := pds[i]
+		releases = append(releases, map[string]any{
+			"version":     pd.Version.Version,
+			"has_docs":    false,
+			"inserted_at": formatTime(pd.Version),
+			"url":         fmt.Sprintf("%s/packages/%s/releases/%s", apiURL(ctx), pd.Package.Name, pd.Version.Version),
+		})
+		if r := hex_service.GetRetirement(pd); r != nil {
+			retirements[pd.Version.Version] = map[string]any{
+				"reason":  r.Reason,
+				"message": r.Message,
+			}
+		}
+	}
+
+	links := make(map[string]any, len(metadata.Links))
+	for name, link := range metadata.Links {
+		links[name] = link
+	}
+
+	respond(ctx, http.StatusOK, map[string]any{
+		"name":        latest.Package.Name,
+		"repository":  hex_service.RepositoryName(ctx.Package.Owner),
+		"url":         fmt.Sprintf("%s/packages/%s", apiURL(ctx), latest.Package.Name),
+		"html_url":    latest.PackageHTMLURL(),
+		"inserted_at": formatTime(pds[0].Version),
+		"updated_at":  formatTime(latest.Version),
+		"meta": map[string]any{
+			"description": metadata.Description,
+			"licenses":    metadata.Licenses,
+			"links":       links,
+		},
+		"releases":    releases,
+		"retirements": retirements,
+	})
+}
+
+func getPackageDescriptor(ctx *context.Context) (*packages_model.PackageDescriptor, bool) {
+	pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeHex, ctx.Params("name"), ctx.Params("version"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return nil, false
+	}
+
+	pd, err := packages_model.GetPackageDescriptor(ctx, pv)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return pd, true
+}
+
+// ReleaseInfo describes a release
+func ReleaseInfo(ctx *context.Context) {
+	pd, ok := getPackageDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	respond(ctx, http.StatusOK, releaseInfo(ctx, pd))
+}
+
+// DeleteRelease removes a release
+func DeleteRelease(ctx *context.Context) {
+	pd, ok := getPackageDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	if err := packages_service.RemovePackageVersion(ctx, ctx.Doer, pd.Version); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// RetireRelease marks a release as retired, the clients warn about retired releases
+func RetireRelease(ctx *context.Context) {
+	pd, ok := getPackageDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	body, err := readRequestBody(ctx)
+	if err != nil {
+		apiError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	reason, _ := body["reason"].(string)
+	message, _ := body["message"].(string)
+	if !slices.Contains(hex_module.RetirementReasons, reason) {
+		apiError(ctx, http.StatusUnprocessableEntity, "invalid retirement reason")
+		return
+	}
+
+	value, err := json.Marshal(&hex_module.Retirement{
+		Reason:  reason,
+		Message: message,
+	})
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := packages_model.DeletePropertyByName(ctx, packages_model.PropertyTypeVersion, pd.Version.ID, hex_module.PropertyRetirement); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if _, err := packages_model.InsertProperty(ctx, packages_model.PropertyTypeVersion, pd.Version.ID, hex_module.PropertyRetirement, string(value)); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// UnretireRelease removes the retirement of a release
+func UnretireRelease(ctx *context.Context) {
+	pd, ok := getPackageDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	if err := packages_model.DeletePropertyByName(ctx, packages_model.PropertyTypeVersion, pd.Version.ID, hex_module.PropertyRetirement); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// readRequestBody decodes the body the clients send in the external term format, or as JSON
+func readRequestBody(ctx *context.Context) (map[string]any, error) {
+	data, err := io.ReadAll(io.LimitReader(ctx.Req.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(ctx.Req.Header.Get("Content-Type"), hex_module.ContentTypeTerm) {
+		v, err := hex_module.UnmarshalTerm(data)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, errors.New("request body is not a map")
+		}
+		return m, nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
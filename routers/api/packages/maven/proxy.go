@@ -0,0 +1,164 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package maven
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/container"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	maven_module "code.gitea.io/gitea/modules/packages/maven"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	proxy_service "code.gitea.io/gitea/services/packages/proxy"
+)
+
+// upstreamPath returns the path of the file in the upstream repository
+func upstreamPath(params parameters, filename string) string {
+	path := strings.ReplaceAll(params.GroupID, ".", "/") + "/" + params.ArtifactID
+	if params.Version != "" {
+		path += "/" + params.Version
+	}
+	return path + "/" + filename
+}
+
+// getUpstreamMetadata returns the metadata of the package in the upstream repository,
+// nil if the owner did not configure an upstream repository or the package does not exist there
+func getUpstreamMetadata(ctx *context.Context, params parameters) (*MetadataResponse, error) {
+	client, err := proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypeMaven)
+	if err != nil || client == nil {
+		return nil, err
+	}
+
+	resp, err := client.Get(ctx, client.URL(upstreamPath(params, mavenMetadataFile)))
+	if err != nil {
+		if !errors.Is(err, util.ErrNotExist) {
+			log.Warn("Unable to fetch Maven metadata of %s:%s from upstream repository %s: %v", params.GroupID, params.ArtifactID, client.Proxy.URL, err)
+		}
+		return nil, nil
+	}
+	defer resp.Body.Close()
+
+	var metadata MetadataResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&metadata); err != nil {
+		log.Warn("Invalid Maven metadata of %s:%s in upstream repository %s: %v", params.GroupID, params.ArtifactID, client.Proxy.URL, err)
+		return nil, nil
+	}
+	return &metadata, nil
+}
+
+// mergeMetadataResponses adds the local versions missing in the upstream repository to the upstream metadata.
+// The local versions are considered newer than the upstream ones.
+func mergeMetadataResponses(upstream, local *MetadataResponse) *MetadataResponse {
+	if local != nil {
+		versions := make(container.Set[string])
+		versions.AddMultiple(upstream.Version...)
+		for _, v := range local.Version {
+			if versions.Add(v) {
+				upstream.Version = append(upstream.Version, v)
+			}
+		}
+	}
+
+	if len(upstream.Version) > 0 {
+		upstream.Latest = upstream.Version[len(upstream.Version)-1]
+		for i := len(upstream.Version) - 1; i >= 0; i-- {
+			if !strings.HasSuffix(upstream.Version[i], "-SNAPSHOT") {
+				upstream.Release = upstream.Version[i]
+				break
+			}
+		}
+	}
+	return upstream
+}
+
+// cacheUpstreamFile fetches the file from the upstream repository and stores it.
+// It returns false if the owner did not configure an upstream repository.
+func cacheUpstreamFile(ctx *context.Context, params parameters, filename string) (bool, error) {
+	client, err := proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypeMaven)
+	if err != nil || client == nil {
+		return false, err
+	}
+
+	packageName := params.GroupID + "-" + params.ArtifactID
+
+	defer client.Lock(packageName + "/" + params.Version + "/" + filename)()
+
+	// The file could have been cached by a concurrent request
+	pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeMaven, packageName, params.Version)
+	if err != nil && err != packages_model.ErrPackageNotExist {
+		return true, err
+	}
+	if pv != nil {
+		if _, err := packages_model.GetFileForVersionByName(ctx, pv.ID, filename, packages_model.EmptyFileKey); err == nil {
+			return true, nil
+		}
+	}
+
+	resp, err := client.Get(ctx, client.URL(upstreamPath(params, filename)))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := packages_module.CreateHashedBufferFromReader(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	defer buf.Close()
+
+	pvci := &packages_service.PackageCreationInfo{
+		PackageInfo: packages_service.PackageInfo{
+			Owner:       ctx.Package.Owner,
+			PackageType: packages_model.TypeMaven,
+			Name:        packageName,
+			Version:     params.Version,
+		},
+		Creator: ctx.Package.Owner,
+	}
+	pfci := &packages_service.PackageFileCreationInfo{
+		PackageFileInfo: packages_service.PackageFileInfo{
+			Filename: filename,
+		},
+		Creator: ctx.Package.Owner,
+		Data:    buf,
+	}
+
+	if strings.HasSuffix(strings.ToLower(filename), extensionPom) {
+		pfci.IsLead = true
+
+		metadata, err := maven_module.ParsePackageMetaData(buf)
+		if err != nil {
+			log.Warn("Invalid pom %s of %s in upstream repository %s: %v", filename, packageName, client.Proxy.URL, err)
+		} else if metadata != nil {
+			pvci.Metadata = metadata
+
+			// The version could have been created by another file of the package
+			if pv != nil {
+				raw, err := json.Marshal(metadata)
+				if err != nil {
+					return true, err
+				}
+				pv.MetadataJSON = string(raw)
+				if err := packages_model.UpdateVersion(ctx, pv); err != nil {
+					return true, err
+				}
+			}
+		}
+
+		if _, err := buf.Seek(0, io.SeekStart); err != nil {
+			return true, err
+		}
+	}
+
+	_, err = client.Cache(ctx, pvci, pfci)
+	return true, err
+}
@@ -0,0 +1,240 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package nix
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	nix_module "code.gitea.io/gitea/modules/packages/nix"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/routers/api/packages/helper"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	nix_service "code.gitea.io/gitea/services/packages/nix"
+)
+
+// The protocol is implemented by the HTTP binary cache store of Nix
+// https://github.com/NixOS/nix/blob/master/src/libstore/http-binary-cache-store.cc
+
+func apiError(ctx *context.Context, status int, obj any) {
+	helper.LogAndProcessError(ctx, status, obj, func(message string) {
+		ctx.PlainText(status, message)
+	})
+}
+
+func serveText(ctx *context.Context, contentType, text string) {
+	ctx.Resp.Header().Set("Content-Type", contentType)
+	ctx.Status(http.StatusOK)
+	_, _ = ctx.Resp.Write([]byte(text))
+}
+
+// CacheInfo serves the properties of the binary cache
+func CacheInfo(ctx *context.Context) {
+	serveText(ctx, "text/x-nix-cache-info", fmt.Sprintf("StoreDir: %s\nWantMassQuery: 1\nPriority: 50\n", nix_module.StoreDir))
+}
+
+// PublicKey serves the public key the narinfo files are signed with
+func PublicKey(ctx *context.Context) {
+	_, pub, err := nix_service.GetOrCreateKeyPair(ctx, ctx.Package.Owner)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.PlainText(http.StatusOK, pub)
+}
+
+func storePathHash(ctx *context.Context) (string, bool) {
+	hash, ok := strings.CutSuffix(ctx.Params("filename"), ".narinfo")
+	if !ok || !nix_module.IsValidStorePathHash(hash) {
+		apiError(ctx, http.StatusNotFound, nil)
+		return "", false
+	}
+	return hash, true
+}
+
+func getStorePath(ctx *context.Context) (*packages_model.PackageDescriptor, bool) {
+	hash, ok := storePathHash(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	pv, err := nix_service.GetStorePath(ctx, ctx.Package.Owner.ID, hash)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return nil, false
+	}
+
+	pd, err := packages_model.GetPackageDescriptor(ctx, pv)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return pd, true
+}
+
+// GetNarInfo serves the narinfo of a store path signed with the key of the owner
+func GetNarInfo(ctx *context.Context) {
+	pd, ok := getStorePath(ctx)
+	if !ok {
+		return
+	}
+
+	ni, err := nix_service.SignNarInfo(ctx, ctx.Package.Owner, pd)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	var sb strings.Builder
+	if _, err := ni.WriteTo(&sb); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	serveText(ctx, "text/x-nix-narinfo", sb.String())
+}
+
+// UploadNarInfo creates the store path described by the narinfo
+func UploadNarInfo(ctx *context.Context) {
+	hash, ok := storePathHash(ctx)
+	if !ok {
+		return
+	}
+
+	ni, err := nix_module.ParseNarInfo(ctx.Req.Body)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			apiError(ctx, http.StatusBadRequest, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	if h, _, _ := nix_module.SplitStorePath(ni.StorePath); h != hash {
+		apiError(ctx, http.StatusBadRequest, "store path does not match the file name")
+		return
+	}
+
+	if _, err := nix_service.CreateStorePath(ctx, ctx.Doer, ctx.Package.Owner, ni); err != nil {
+		switch {
+		case errors.Is(err, packages_model.ErrDuplicatePackageVersion):
+			// Concurrent uploads of the same store path are expected, the first one wins
+			ctx.Status(http.StatusOK)
+		case errors.Is(err, util.ErrInvalidArgument):
+			apiError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, packages_service.ErrQuotaTotalCount), errors.Is(err, packages_service.ErrQuotaTypeSize), errors.Is(err, packages_service.ErrQuotaTotalSize):
+			apiError(ctx, http.StatusForbidden, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusCreated)
+}
+
+// DeleteNarInfo removes a store path
+func DeleteNarInfo(ctx *context.Context) {
+	pd, ok := getStorePath(ctx)
+	if !ok {
+		return
+	}
+
+	if err := packages_service.RemovePackageVersion(ctx, ctx.Doer, pd.Version); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func getNarFile(ctx *context.Context) (*packages_model.PackageFile, bool) {
+	pf, err := nix_service.GetNarFile(ctx, ctx.Package.Owner.ID, ctx.Params("filename"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return nil, false
+	}
+	return pf, true
+}
+
+// CheckNar reports if a NAR file exists, Nix skips uploading it in that case
+func CheckNar(ctx *context.Context) {
+	pf, ok := getNarFile(ctx)
+	if !ok {
+		return
+	}
+
+	pb, err := packages_model.GetBlobByID(ctx, pf.BlobID)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Resp.Header().Set("Content-Length", strconv.FormatInt(pb.Size, 10))
+	ctx.Status(http.StatusOK)
+}
+
+// DownloadNar serves a NAR file
+func DownloadNar(ctx *context.Context) {
+	pf, ok := getNarFile(ctx)
+	if !ok {
+		return
+	}
+
+	s, u, _, err := packages_service.GetPackageFileStream(ctx, pf)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	helper.ServePackageFile(ctx, s, u, pf)
+}
+
+// UploadNar stores a NAR file until the narinfo referencing it is uploaded
+func UploadNar(ctx *context.Context) {
+	upload, needToClose, err := ctx.UploadStream()
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if needToClose {
+		defer upload.Close()
+	}
+
+	buf, err := packages_module.CreateHashedBufferFromReader(upload)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer buf.Close()
+
+	if err := nix_service.UploadNar(ctx, ctx.Doer, ctx.Package.Owner, ctx.Params("filename"), buf); err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidArgument):
+			apiError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, packages_service.ErrQuotaTotalCount), errors.Is(err, packages_service.ErrQuotaTypeSize), errors.Is(err, packages_service.ErrQuotaTotalSize):
+			apiError(ctx, http.StatusForbidden, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusCreated)
+}
@@ -60,21 +60,33 @@ func PackageMetadata(ctx *context.Context) {
 		apiError(ctx, http.StatusInternalServerError, err)
 		return
 	}
-	if len(pvs) == 0 {
-		apiError(ctx, http.StatusNotFound, err)
-		return
-	}
 
-	pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+	upstream, err := getUpstreamMetadata(ctx, packageName)
 	if err != nil {
 		apiError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	resp := createPackageMetadataResponse(
-		setting.AppURL+"api/packages/"+ctx.Package.Owner.Name+"/npm",
-		pds,
-	)
+	if len(pvs) == 0 && upstream == nil {
+		apiError(ctx, http.StatusNotFound, err)
+		return
+	}
+
+	registryURL := setting.AppURL + "api/packages/" + ctx.Package.Owner.Name + "/npm"
+
+	var resp *npm_module.PackageMetadata
+	if len(pvs) > 0 {
+		pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+		if err != nil {
+			apiError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp = createPackageMetadataResponse(registryURL, pds)
+	}
+	if upstream != nil {
+		resp = mergeUpstreamMetadata(registryURL, upstream, resp)
+	}
 
 	ctx.JSON(http.StatusOK, resp)
 }
@@ -85,18 +97,31 @@ func DownloadPackageFile(ctx *context.Context) {
 	packageVersion := ctx.Params("version")
 	filename := ctx.Params("filename")
 
-	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(
-		ctx,
-		&packages_service.PackageInfo{
-			Owner:       ctx.Package.Owner,
-			PackageType: packages_model.TypeNpm,
-			Name:        packageName,
-			Version:     packageVersion,
-		},
-		&packages_service.PackageFileInfo{
-			Filename: filename,
-		},
-	)
+	pi := &packages_service.PackageInfo{
+		Owner:       ctx.Package.Owner,
+		PackageType: packages_model.TypeNpm,
+		Name:        packageName,
+		Version:     packageVersion,
+	}
+	pfi := &packages_service.PackageFileInfo{
+		Filename: filename,
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(ctx, pi, pfi)
+	if err == packages_model.ErrPackageNotExist {
+		cached, cacheErr := cacheUpstreamVersion(ctx, packageName, packageVersion, filename)
+		if cacheErr != nil {
+			if errors.Is(cacheErr, util.ErrNotExist) {
+				apiError(ctx, http.StatusNotFound, cacheErr)
+			} else {
+				apiError(ctx, http.StatusBadGateway, cacheErr)
+			}
+			return
+		}
+		if cached {
+			s, u, pf, err = packages_service.GetFileStreamByPackageNameAndVersion(ctx, pi, pfi)
+		}
+	}
 	if err != nil {
 		if err == packages_model.ErrPackageNotExist || err == packages_model.ErrPackageFileNotExist {
 			apiError(ctx, http.StatusNotFound, err)
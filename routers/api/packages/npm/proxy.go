@@ -0,0 +1,186 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package npm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	npm_module "code.gitea.io/gitea/modules/packages/npm"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	proxy_service "code.gitea.io/gitea/services/packages/proxy"
+)
+
+// The metadata of popular packages lists thousands of versions
+const maxUpstreamMetadataSize = 256 << 20
+
+// fetchUpstreamMetadata returns the metadata of the package in the upstream registry
+func fetchUpstreamMetadata(ctx *context.Context, client *proxy_service.Client, packageName string) (*npm_module.PackageMetadata, error) {
+	// Scoped packages are requested as @scope%2Fname
+	resp, err := client.Get(ctx, client.URL(url.PathEscape(packageName)), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var metadata npm_module.PackageMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxUpstreamMetadataSize)).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	if metadata.Name != packageName {
+		return nil, fmt.Errorf("upstream registry returned package %q instead of %q", metadata.Name, packageName)
+	}
+	return &metadata, nil
+}
+
+// getUpstreamMetadata returns the metadata of the package in the upstream registry,
+// nil if the owner did not configure an upstream registry or the package does not exist there.
+// A failing upstream registry does not break the local packages.
+func getUpstreamMetadata(ctx *context.Context, packageName string) (*npm_module.PackageMetadata, error) {
+	client, err := proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypeNpm)
+	if err != nil || client == nil {
+		return nil, err
+	}
+
+	metadata, err := fetchUpstreamMetadata(ctx, client, packageName)
+	if err != nil {
+		if !errors.Is(err, util.ErrNotExist) {
+			log.Warn("Unable to fetch npm package %s from upstream registry %s: %v", packageName, client.Proxy.URL, err)
+		}
+		return nil, nil
+	}
+	return metadata, nil
+}
+
+// tarballFilename returns the filename of the tarball of an upstream version
+func tarballFilename(pmv *npm_module.PackageMetadataVersion) string {
+	tarball := pmv.Dist.Tarball
+	if i := strings.IndexAny(tarball, "?#"); i != -1 {
+		tarball = tarball[:i]
+	}
+	return strings.ToLower(tarball[strings.LastIndex(tarball, "/")+1:])
+}
+
+// mergeUpstreamMetadata adds the upstream versions to the local metadata. The tarballs of the upstream versions
+// are served by this registry, the local versions and tags take precedence over the upstream ones.
+func mergeUpstreamMetadata(registryURL string, upstream, local *npm_module.PackageMetadata) *npm_module.PackageMetadata {
+	for v, pmv := range upstream.Versions {
+		if pmv == nil || pmv.Dist.Tarball == "" {
+			delete(upstream.Versions, v)
+			continue
+		}
+		pmv.Dist.Tarball = fmt.Sprintf("%s/%s/-/%s/%s", registryURL, url.QueryEscape(upstream.Name), url.PathEscape(pmv.Version), url.PathEscape(tarballFilename(pmv)))
+	}
+
+	if local == nil {
+		return upstream
+	}
+
+	if upstream.Versions == nil {
+		upstream.Versions = make(map[string]*npm_module.PackageMetadataVersion, len(local.Versions))
+	}
+	for v, pmv := range local.Versions {
+		upstream.Versions[v] = pmv
+	}
+	if upstream.DistTags == nil {
+		upstream.DistTags = make(map[string]string, len(local.DistTags))
+	}
+	for tag, v := range local.DistTags {
+		upstream.DistTags[tag] = v
+	}
+	return upstream
+}
+
+// cacheUpstreamVersion fetches the tarball of the version from the upstream registry and stores it.
+// It returns false if the owner did not configure an upstream registry.
+func cacheUpstreamVersion(ctx *context.Context, packageName, packageVersion, filename string) (bool, error) {
+	client, err := proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypeNpm)
+	if err != nil || client == nil {
+		return false, err
+	}
+
+	defer client.Lock(packageName + "/" + packageVersion)()
+
+	// The version could have been cached by a concurrent request
+	if _, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeNpm, packageName, packageVersion); err == nil {
+		return true, nil
+	}
+
+	metadata, err := fetchUpstreamMetadata(ctx, client, packageName)
+	if err != nil {
+		return true, err
+	}
+
+	var pmv *npm_module.PackageMetadataVersion
+	for _, v := range metadata.Versions {
+		if v != nil && v.Version == packageVersion {
+			pmv = v
+			break
+		}
+	}
+	if pmv == nil || tarballFilename(pmv) != strings.ToLower(filename) {
+		return true, proxy_service.ErrUpstreamNotExist
+	}
+
+	resp, err := client.Get(ctx, pmv.Dist.Tarball)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	// the tarball is streamed to a buffer backed by a file and cut at the size limit of the npm packages
+	var body io.Reader = resp.Body
+	if setting.Packages.LimitSizeNpm > -1 {
+		body = io.LimitReader(resp.Body, setting.Packages.LimitSizeNpm+1)
+	}
+	buf, err := packages_module.CreateHashedBufferFromReader(body)
+	if err != nil {
+		return true, err
+	}
+	defer buf.Close()
+
+	if setting.Packages.LimitSizeNpm > -1 && buf.Size() > setting.Packages.LimitSizeNpm {
+		return true, packages_service.ErrQuotaTypeSize
+	}
+
+	_, hashSHA1, _, hashSHA512 := buf.Sums()
+	npmPackage, err := npm_module.ParseUpstreamPackage(pmv, hashSHA1, hashSHA512)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = client.Cache(
+		ctx,
+		&packages_service.PackageCreationInfo{
+			PackageInfo: packages_service.PackageInfo{
+				Owner:       ctx.Package.Owner,
+				PackageType: packages_model.TypeNpm,
+				Name:        npmPackage.Name,
+				Version:     npmPackage.Version,
+			},
+			SemverCompatible: true,
+			Creator:          ctx.Package.Owner,
+			Metadata:         npmPackage.Metadata,
+		},
+		&packages_service.PackageFileCreationInfo{
+			PackageFileInfo: packages_service.PackageFileInfo{
+				Filename: strings.ToLower(filename),
+			},
+			Creator: ctx.Package.Owner,
+			Data:    buf,
+			IsLead:  true,
+		},
+	)
+	return true, err
+}
@@ -31,6 +31,10 @@ func (a *Auth) Verify(req *http.Request, w http.ResponseWriter, store auth.DataS
 		}
 		return nil, nil
 	}
+	// packages are not accessible to a token restricted to repositories
+	if token.IsRepoRestricted() {
+		return nil, nil
+	}
 
 	u, err := user_model.GetUserByID(req.Context(), token.UID)
 	if err != nil {
@@ -0,0 +1,198 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package pypi
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	pypi_module "code.gitea.io/gitea/modules/packages/pypi"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	proxy_service "code.gitea.io/gitea/services/packages/proxy"
+
+	"golang.org/x/net/html"
+)
+
+var distributionExtensions = []string{".whl", ".tar.gz", ".zip", ".tar.bz2"}
+
+// upstreamFile is a distribution file listed by the simple index of the upstream registry
+type upstreamFile struct {
+	Filename       string
+	Version        string
+	URL            string
+	SHA256         string
+	RequiresPython string
+}
+
+// fetchUpstreamFiles returns the files of the package listed by the simple index of the upstream registry
+// https://peps.python.org/pep-0503/
+func fetchUpstreamFiles(ctx *context.Context, client *proxy_service.Client, packageName string) ([]*upstreamFile, error) {
+	indexURL := client.URL("simple/" + strings.ToLower(packageName) + "/")
+
+	resp, err := client.Get(ctx, indexURL, "text/html")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	files := parseSimpleIndex(io.LimitReader(resp.Body, 32<<20), packageName)
+	for _, f := range files {
+		if f.URL, err = client.ResolveURL(indexURL, f.URL); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// parseSimpleIndex extracts the links to the distribution files of the package from a simple index page
+func parseSimpleIndex(r io.Reader, packageName string) []*upstreamFile {
+	files := make([]*upstreamFile, 0, 10)
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return files
+		case html.StartTagToken:
+			t := z.Token()
+			if t.Data != "a" {
+				continue
+			}
+
+			f := &upstreamFile{}
+			for _, attr := range t.Attr {
+				switch attr.Key {
+				case "href":
+					f.URL = attr.Val
+				case "data-requires-python":
+					f.RequiresPython = attr.Val
+				}
+			}
+			if f.URL == "" {
+				continue
+			}
+
+			link, fragment, _ := strings.Cut(f.URL, "#")
+			if hash, ok := strings.CutPrefix(fragment, "sha256="); ok {
+				f.SHA256 = strings.ToLower(hash)
+			}
+			f.Filename = link[strings.LastIndex(link, "/")+1:]
+			if i := strings.IndexAny(f.Filename, "?"); i != -1 {
+				f.Filename = f.Filename[:i]
+			}
+
+			version, ok := versionFromFilename(packageName, f.Filename)
+			if !ok {
+				continue
+			}
+			f.Version = version
+
+			files = append(files, f)
+		}
+	}
+}
+
+// versionFromFilename extracts the version from the name of a wheel or a source distribution
+// https://packaging.python.org/en/latest/specifications/binary-distribution-format/#file-name-convention
+func versionFromFilename(packageName, filename string) (string, bool) {
+	base := ""
+	for _, ext := range distributionExtensions {
+		if strings.HasSuffix(strings.ToLower(filename), ext) {
+			base = filename[:len(filename)-len(ext)]
+			break
+		}
+	}
+	// The separators of the name can be normalized differently in the filename, but it keeps its length
+	if len(base) <= len(packageName)+1 ||
+		!strings.EqualFold(normalizer.Replace(base[:len(packageName)]), packageName) ||
+		base[len(packageName)] != '-' {
+		return "", false
+	}
+
+	version := base[len(packageName)+1:]
+	if strings.HasSuffix(strings.ToLower(filename), ".whl") {
+		version, _, _ = strings.Cut(version, "-")
+	}
+	if !versionMatcher.MatchString(version) {
+		return "", false
+	}
+	return version, true
+}
+
+// cacheUpstreamFile fetches the file from the upstream registry and stores it
+func cacheUpstreamFile(ctx *context.Context, client *proxy_service.Client, packageName, packageVersion, filename string) error {
+	defer client.Lock(packageName + "/" + filename)()
+
+	// The file could have been cached by a concurrent request
+	if pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypePyPI, packageName, packageVersion); err == nil {
+		if _, err := packages_model.GetFileForVersionByName(ctx, pv.ID, filename, packages_model.EmptyFileKey); err == nil {
+			return nil
+		}
+	}
+
+	files, err := fetchUpstreamFiles(ctx, client, packageName)
+	if err != nil {
+		return err
+	}
+
+	var file *upstreamFile
+	for _, f := range files {
+		if f.Filename == filename && f.Version == packageVersion {
+			file = f
+			break
+		}
+	}
+	if file == nil {
+		return proxy_service.ErrUpstreamNotExist
+	}
+
+	resp, err := client.Get(ctx, file.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	buf, err := packages_module.CreateHashedBufferFromReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+
+	if file.SHA256 != "" {
+		_, _, hashSHA256, _ := buf.Sums()
+		if hex.EncodeToString(hashSHA256) != file.SHA256 {
+			return util.NewInvalidArgumentErrorf("hash mismatch of upstream file %s", filename)
+		}
+	}
+
+	_, err = client.Cache(
+		ctx,
+		&packages_service.PackageCreationInfo{
+			PackageInfo: packages_service.PackageInfo{
+				Owner:       ctx.Package.Owner,
+				PackageType: packages_model.TypePyPI,
+				Name:        packageName,
+				Version:     packageVersion,
+			},
+			Creator: ctx.Package.Owner,
+			Metadata: &pypi_module.Metadata{
+				RequiresPython: file.RequiresPython,
+			},
+		},
+		&packages_service.PackageFileCreationInfo{
+			PackageFileInfo: packages_service.PackageFileInfo{
+				Filename: filename,
+			},
+			Creator: ctx.Package.Owner,
+			Data:    buf,
+			IsLead:  true,
+		},
+	)
+	return err
+}
@@ -5,6 +5,7 @@ package pypi
 
 import (
 	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
 	"regexp"
@@ -12,13 +13,17 @@ import (
 	"strings"
 
 	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/container"
+	"code.gitea.io/gitea/modules/log"
 	packages_module "code.gitea.io/gitea/modules/packages"
 	pypi_module "code.gitea.io/gitea/modules/packages/pypi"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/modules/validation"
 	"code.gitea.io/gitea/routers/api/packages/helper"
 	"code.gitea.io/gitea/services/context"
 	packages_service "code.gitea.io/gitea/services/packages"
+	proxy_service "code.gitea.io/gitea/services/packages/proxy"
 )
 
 // https://peps.python.org/pep-0426/#name
@@ -52,46 +57,110 @@ func PackageMetadata(ctx *context.Context) {
 		apiError(ctx, http.StatusInternalServerError, err)
 		return
 	}
-	if len(pvs) == 0 {
-		apiError(ctx, http.StatusNotFound, err)
+
+	pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+	upstreamFiles, err := getUpstreamFiles(ctx, packageName, pds)
 	if err != nil {
 		apiError(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
+	if len(pds) == 0 && len(upstreamFiles) == 0 {
+		apiError(ctx, http.StatusNotFound, packages_model.ErrPackageNotExist)
+		return
+	}
+
 	// sort package descriptors by version to mimic PyPI format
 	sort.Slice(pds, func(i, j int) bool {
 		return strings.Compare(pds[i].Version.Version, pds[j].Version.Version) < 0
 	})
 
 	ctx.Data["RegistryURL"] = setting.AppURL + "api/packages/" + ctx.Package.Owner.Name + "/pypi"
-	ctx.Data["PackageDescriptor"] = pds[0]
+	ctx.Data["PackageName"] = packageName
+	ctx.Data["PackageLowerName"] = strings.ToLower(packageName)
+	if len(pds) > 0 {
+		ctx.Data["PackageName"] = pds[0].Package.Name
+	}
 	ctx.Data["PackageDescriptors"] = pds
+	ctx.Data["UpstreamFiles"] = upstreamFiles
 	ctx.HTML(http.StatusOK, "api/packages/pypi/simple")
 }
 
+// getUpstreamFiles returns the files of the upstream registry which are not stored locally,
+// if the owner configured an upstream registry. A failing upstream registry does not break the local index.
+func getUpstreamFiles(ctx *context.Context, packageName string, pds []*packages_model.PackageDescriptor) ([]*upstreamFile, error) {
+	client, err := proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypePyPI)
+	if err != nil || client == nil {
+		return nil, err
+	}
+
+	files, err := fetchUpstreamFiles(ctx, client, packageName)
+	if err != nil {
+		if !errors.Is(err, util.ErrNotExist) {
+			log.Warn("Unable to fetch PyPI package %s from upstream registry %s: %v", packageName, client.Proxy.URL, err)
+		}
+		return nil, nil
+	}
+
+	local := make(container.Set[string])
+	for _, pd := range pds {
+		for _, pfd := range pd.Files {
+			local.Add(pfd.File.LowerName)
+		}
+	}
+
+	upstreamFiles := make([]*upstreamFile, 0, len(files))
+	for _, f := range files {
+		if !local.Contains(strings.ToLower(f.Filename)) {
+			upstreamFiles = append(upstreamFiles, f)
+		}
+	}
+	return upstreamFiles, nil
+}
+
 // DownloadPackageFile serves the content of a package
 func DownloadPackageFile(ctx *context.Context) {
 	packageName := normalizer.Replace(ctx.Params("id"))
 	packageVersion := ctx.Params("version")
 	filename := ctx.Params("filename")
 
-	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(
-		ctx,
-		&packages_service.PackageInfo{
-			Owner:       ctx.Package.Owner,
-			PackageType: packages_model.TypePyPI,
-			Name:        packageName,
-			Version:     packageVersion,
-		},
-		&packages_service.PackageFileInfo{
-			Filename: filename,
-		},
-	)
+	pi := &packages_service.PackageInfo{
+		Owner:       ctx.Package.Owner,
+		PackageType: packages_model.TypePyPI,
+		Name:        packageName,
+		Version:     packageVersion,
+	}
+	pfi := &packages_service.PackageFileInfo{
+		Filename: filename,
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(ctx, pi, pfi)
+	if err == packages_model.ErrPackageNotExist || err == packages_model.ErrPackageFileNotExist {
+		var client *proxy_service.Client
+		client, err = proxy_service.GetClient(ctx, ctx.Package.Owner, packages_model.TypePyPI)
+		if err != nil {
+			apiError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		if client == nil || !isValidNameAndVersion(packageName, packageVersion) {
+			apiError(ctx, http.StatusNotFound, packages_model.ErrPackageFileNotExist)
+			return
+		}
+		if err := cacheUpstreamFile(ctx, client, packageName, packageVersion, filename); err != nil {
+			if errors.Is(err, util.ErrNotExist) {
+				apiError(ctx, http.StatusNotFound, err)
+			} else {
+				apiError(ctx, http.StatusBadGateway, err)
+			}
+			return
+		}
+		s, u, pf, err = packages_service.GetFileStreamByPackageNameAndVersion(ctx, pi, pfi)
+	}
 	if err != nil {
 		if err == packages_model.ErrPackageNotExist || err == packages_model.ErrPackageFileNotExist {
 			apiError(ctx, http.StatusNotFound, err)
@@ -144,6 +213,20 @@ func UploadPackageFile(ctx *context.Context) {
 		projectURL = ""
 	}
 
+	// project_urls entries have the form "label, url"
+	var projectURLs map[string]string
+	for _, entry := range ctx.Req.Form["project_urls"] {
+		label, u, ok := strings.Cut(entry, ",")
+		u = strings.TrimSpace(u)
+		if !ok || !validation.IsValidURL(u) {
+			continue
+		}
+		if projectURLs == nil {
+			projectURLs = make(map[string]string)
+		}
+		projectURLs[strings.TrimSpace(label)] = u
+	}
+
 	_, _, err = packages_service.CreatePackageOrAddFileToExisting(
 		ctx,
 		&packages_service.PackageCreationInfo{
@@ -161,8 +244,10 @@ func UploadPackageFile(ctx *context.Context) {
 				LongDescription: ctx.Req.FormValue("long_description"),
 				Summary:         ctx.Req.FormValue("summary"),
 				ProjectURL:      projectURL,
+				ProjectURLs:     projectURLs,
 				License:         ctx.Req.FormValue("license"),
 				RequiresPython:  ctx.Req.FormValue("requires_python"),
+				RequiresDist:    ctx.Req.Form["requires_dist"],
 			},
 		},
 		&packages_service.PackageFileCreationInfo{
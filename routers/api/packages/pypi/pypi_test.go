@@ -4,6 +4,7 @@
 package pypi
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,3 +37,55 @@ func TestIsValidNameAndVersion(t *testing.T) {
 	assert.False(t, isValidNameAndVersion("test-name", "1.0.1aa"))
 	assert.False(t, isValidNameAndVersion("test-name", "1.0.0-alpha.beta"))
 }
+
+func TestVersionFromFilename(t *testing.T) {
+	cases := []struct {
+		PackageName string
+		Filename    string
+		Version     string
+	}{
+		{"requests", "requests-2.31.0.tar.gz", "2.31.0"},
+		{"requests", "requests-2.31.0-py3-none-any.whl", "2.31.0"},
+		{"typing-extensions", "typing_extensions-4.9.0-py3-none-any.whl", "4.9.0"},
+		{"typing-extensions", "typing_extensions-4.9.0.tar.gz", "4.9.0"},
+		{"django", "Django-5.0.1-py3-none-any.whl", "5.0.1"},
+		{"test-name", "test-name-1.0.1a1.zip", "1.0.1a1"},
+		{"test-name", "test-name-1.0.0-1-py3-none-any.whl", "1.0.0"},
+	}
+	for _, c := range cases {
+		version, ok := versionFromFilename(c.PackageName, c.Filename)
+		assert.True(t, ok, c.Filename)
+		assert.Equal(t, c.Version, version, c.Filename)
+	}
+
+	for _, filename := range []string{"requests-2.31.0.exe", "other-2.31.0.tar.gz", "requests.tar.gz", "requests-invalid.tar.gz"} {
+		_, ok := versionFromFilename("requests", filename)
+		assert.False(t, ok, filename)
+	}
+}
+
+func TestParseSimpleIndex(t *testing.T) {
+	index := `<!DOCTYPE html>
+<html>
+	<body>
+		<h1>Links for test-name</h1>
+		<a href="../../packages/test_name-1.0.0-py3-none-any.whl#sha256=ABCDEF" data-requires-python="&gt;=3.8">test_name-1.0.0-py3-none-any.whl</a><br/>
+		<a href="https://files.example.com/test-name-1.1.0.tar.gz">test-name-1.1.0.tar.gz</a><br/>
+		<a href="https://files.example.com/test-name-1.1.0.exe">test-name-1.1.0.exe</a><br/>
+		<a>no link</a>
+	</body>
+</html>`
+
+	files := parseSimpleIndex(strings.NewReader(index), "test-name")
+	assert.Len(t, files, 2)
+
+	assert.Equal(t, "test_name-1.0.0-py3-none-any.whl", files[0].Filename)
+	assert.Equal(t, "1.0.0", files[0].Version)
+	assert.Equal(t, "abcdef", files[0].SHA256)
+	assert.Equal(t, ">=3.8", files[0].RequiresPython)
+	assert.Equal(t, "../../packages/test_name-1.0.0-py3-none-any.whl#sha256=ABCDEF", files[0].URL)
+
+	assert.Equal(t, "test-name-1.1.0.tar.gz", files[1].Filename)
+	assert.Equal(t, "1.1.0", files[1].Version)
+	assert.Empty(t, files[1].SHA256)
+}
@@ -64,14 +64,32 @@ func GetRepositoryKey(ctx *context.Context) {
 	})
 }
 
-func CheckRepositoryFileExistence(ctx *context.Context) {
+// getRepositoryVersion gets the package version holding the repository files and the group of a snapshot or of the live repository
+func getRepositoryVersion(ctx *context.Context) (*packages_model.PackageVersion, string, error) {
+	if snapshot := ctx.Params("snapshot"); snapshot != "" {
+		s, pv, err := rpm_service.GetSnapshot(ctx, ctx.Package.Owner.ID, snapshot)
+		if err != nil {
+			return nil, "", err
+		}
+		return pv, s.Source, nil
+	}
+
 	pv, err := rpm_service.GetOrCreateRepositoryVersion(ctx, ctx.Package.Owner.ID)
+	return pv, ctx.Params("group"), err
+}
+
+func CheckRepositoryFileExistence(ctx *context.Context) {
+	pv, group, err := getRepositoryVersion(ctx)
 	if err != nil {
-		apiError(ctx, http.StatusInternalServerError, err)
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.Status(http.StatusNotFound)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
-	pf, err := packages_model.GetFileForVersionByName(ctx, pv.ID, ctx.Params("filename"), ctx.Params("group"))
+	pf, err := packages_model.GetFileForVersionByName(ctx, pv.ID, ctx.Params("filename"), group)
 	if err != nil {
 		if errors.Is(err, util.ErrNotExist) {
 			ctx.Status(http.StatusNotFound)
@@ -90,9 +108,13 @@ func CheckRepositoryFileExistence(ctx *context.Context) {
 
 // Gets a pre-generated repository metadata file
 func GetRepositoryFile(ctx *context.Context) {
-	pv, err := rpm_service.GetOrCreateRepositoryVersion(ctx, ctx.Package.Owner.ID)
+	pv, group, err := getRepositoryVersion(ctx)
 	if err != nil {
-		apiError(ctx, http.StatusInternalServerError, err)
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
@@ -101,7 +123,7 @@ func GetRepositoryFile(ctx *context.Context) {
 		pv,
 		&packages_service.PackageFileInfo{
 			Filename:     ctx.Params("filename"),
-			CompositeKey: ctx.Params("group"),
+			CompositeKey: group,
 		},
 	)
 	if err != nil {
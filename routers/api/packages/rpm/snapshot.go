@@ -0,0 +1,174 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package rpm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/routers/api/packages/helper"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	rpm_service "code.gitea.io/gitea/services/packages/rpm"
+)
+
+// Snapshot is a frozen group of the repository
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Group   string    `json:"group"`
+	Created time.Time `json:"created"`
+}
+
+func toSnapshot(s *packages_service.Snapshot) *Snapshot {
+	return &Snapshot{
+		Name:    s.Name,
+		Group:   s.Source,
+		Created: s.CreatedUnix.AsLocalTime(),
+	}
+}
+
+// PromoteOptions are the options to promote package versions to another group
+type PromoteOptions struct {
+	From     string                        `json:"from"`
+	To       string                        `json:"to"`
+	Packages []*rpm_service.PromotePackage `json:"packages"`
+}
+
+// ListSnapshots lists all snapshots of the repository
+func ListSnapshots(ctx *context.Context) {
+	snapshots, err := rpm_service.GetSnapshots(ctx, ctx.Package.Owner.ID)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := make([]*Snapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		result = append(result, toSnapshot(s))
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// CreateSnapshot freezes the current state of a group
+func CreateSnapshot(ctx *context.Context) {
+	group := strings.Trim(ctx.FormTrim("group"), "/")
+
+	s, err := rpm_service.CreateSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot"), group)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidArgument):
+			apiError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, util.ErrAlreadyExist):
+			apiError(ctx, http.StatusConflict, err)
+		case errors.Is(err, util.ErrNotExist):
+			apiError(ctx, http.StatusNotFound, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, toSnapshot(s))
+}
+
+// DeleteSnapshot deletes a snapshot
+func DeleteSnapshot(ctx *context.Context) {
+	if err := rpm_service.DeleteSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot")); err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// GetSnapshotRepositoryConfig serves the dnf configuration pinned to a snapshot
+func GetSnapshotRepositoryConfig(ctx *context.Context) {
+	s, _, err := rpm_service.GetSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	url := fmt.Sprintf("%sapi/packages/%s/rpm", setting.AppURL, ctx.Package.Owner.Name)
+
+	ctx.PlainText(http.StatusOK, `[gitea-`+ctx.Package.Owner.LowerName+`-snapshot-`+s.Name+`]
+name=`+ctx.Package.Owner.Name+` - `+setting.AppName+` - `+s.Name+`
+baseurl=`+url+`/-/snapshots/`+s.Name+`
+enabled=1
+gpgcheck=1
+gpgkey=`+url+`/repository.key`)
+}
+
+// DownloadSnapshotPackageFile serves a package file listed in the metadata files of a snapshot
+func DownloadSnapshotPackageFile(ctx *context.Context) {
+	snapshot, pv, err := rpm_service.GetSnapshot(ctx, ctx.Package.Owner.ID, ctx.Params("snapshot"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageVersion(
+		ctx,
+		pv,
+		&packages_service.PackageFileInfo{
+			Filename:     fmt.Sprintf("%s-%s.%s.rpm", ctx.Params("name"), ctx.Params("version"), ctx.Params("architecture")),
+			CompositeKey: snapshot.Source,
+		},
+	)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	helper.ServePackageFile(ctx, s, u, pf)
+}
+
+// Promote adds package versions of a group to another group
+func Promote(ctx *context.Context) {
+	var opts PromoteOptions
+	if err := json.NewDecoder(ctx.Req.Body).Decode(&opts); err != nil {
+		apiError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	from := strings.Trim(opts.From, "/")
+	to := strings.Trim(opts.To, "/")
+
+	if err := rpm_service.Promote(ctx, ctx.Package.Owner.ID, from, to, opts.Packages); err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidArgument):
+			apiError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, util.ErrNotExist):
+			apiError(ctx, http.StatusNotFound, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
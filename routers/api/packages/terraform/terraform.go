@@ -0,0 +1,628 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/json"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	terraform_module "code.gitea.io/gitea/modules/packages/terraform"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/routers/api/packages/helper"
+	"code.gitea.io/gitea/services/context"
+	packages_service "code.gitea.io/gitea/services/packages"
+	terraform_service "code.gitea.io/gitea/services/packages/terraform"
+
+	"github.com/hashicorp/go-version"
+)
+
+func apiError(ctx *context.Context, status int, obj any) {
+	helper.LogAndProcessError(ctx, status, obj, func(message string) {
+		ctx.JSON(status, struct {
+			Errors []string `json:"errors"`
+		}{
+			Errors: []string{
+				message,
+			},
+		})
+	})
+}
+
+func packageURL(ctx *context.Context) string {
+	return fmt.Sprintf("%sapi/packages/%s/terraform", setting.AppURL, url.PathEscape(ctx.Package.Owner.Name))
+}
+
+// getPackageDescriptors returns the versions of a package sorted from the oldest to the newest
+func getPackageDescriptors(ctx *context.Context, name string) ([]*packages_model.PackageDescriptor, error) {
+	pvs, err := packages_model.GetVersionsByPackageName(ctx, ctx.Package.Owner.ID, packages_model.TypeTerraform, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(pvs) == 0 {
+		return nil, packages_model.ErrPackageNotExist
+	}
+
+	pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pds, func(i, j int) bool {
+		return pds[i].SemVer.LessThan(pds[j].SemVer)
+	})
+	return pds, nil
+}
+
+func modulePackageName(ctx *context.Context) (string, bool) {
+	name, err := terraform_module.ModulePackageName(ctx.Params("name"), ctx.Params("system"))
+	if err != nil {
+		apiError(ctx, http.StatusBadRequest, err)
+		return "", false
+	}
+	return name, true
+}
+
+func providerType(ctx *context.Context) (string, bool) {
+	providerType := ctx.Params("type")
+	if !terraform_module.IsValidProviderType(providerType) {
+		apiError(ctx, http.StatusBadRequest, terraform_module.ErrInvalidName)
+		return "", false
+	}
+	return providerType, true
+}
+
+func validVersion(ctx *context.Context) (string, bool) {
+	v := ctx.Params("version")
+	if _, err := version.NewSemver(v); err != nil {
+		apiError(ctx, http.StatusBadRequest, err)
+		return "", false
+	}
+	return v, true
+}
+
+type moduleVersion struct {
+	Version string `json:"version"`
+}
+
+type moduleVersions struct {
+	Versions []*moduleVersion `json:"versions"`
+}
+
+// ListModuleVersions lists the versions of a module
+// https://developer.hashicorp.com/terraform/internals/module-registry-protocol#list-available-versions-for-a-specific-module
+func ListModuleVersions(ctx *context.Context) {
+	name, ok := modulePackageName(ctx)
+	if !ok {
+		return
+	}
+
+	pds, err := getPackageDescriptors(ctx, name)
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	versions := make([]*moduleVersion, 0, len(pds))
+	for _, pd := range pds {
+		versions = append(versions, &moduleVersion{Version: pd.Version.Version})
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Modules []*moduleVersions `json:"modules"`
+	}{
+		Modules: []*moduleVersions{{Versions: versions}},
+	})
+}
+
+// DownloadModuleVersion returns the location of the archive of a module version
+// https://developer.hashicorp.com/terraform/internals/module-registry-protocol#download-source-code-for-a-specific-module-version
+func DownloadModuleVersion(ctx *context.Context) {
+	name, ok := modulePackageName(ctx)
+	if !ok {
+		return
+	}
+
+	pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeTerraform, name, ctx.Params("version"))
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the archive query parameter tells Terraform how to extract the downloaded file
+	ctx.Resp.Header().Set("X-Terraform-Get", fmt.Sprintf("%s/modules/%s/%s/%s?archive=tar.gz",
+		packageURL(ctx), url.PathEscape(ctx.Params("name")), url.PathEscape(ctx.Params("system")), url.PathEscape(pv.Version)))
+	ctx.Status(http.StatusNoContent)
+}
+
+// DownloadModuleArchive serves the archive of a module version
+func DownloadModuleArchive(ctx *context.Context) {
+	name, ok := modulePackageName(ctx)
+	if !ok {
+		return
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(
+		ctx,
+		&packages_service.PackageInfo{
+			Owner:       ctx.Package.Owner,
+			PackageType: packages_model.TypeTerraform,
+			Name:        name,
+			Version:     ctx.Params("version"),
+		},
+		&packages_service.PackageFileInfo{
+			Filename: terraform_module.ModuleArchiveFilename,
+		},
+	)
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) || errors.Is(err, packages_model.ErrPackageFileNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	helper.ServePackageFile(ctx, s, u, pf)
+}
+
+// UploadModule creates a module version from a gzipped tarball of its sources
+func UploadModule(ctx *context.Context) {
+	name, ok := modulePackageName(ctx)
+	if !ok {
+		return
+	}
+	moduleVersion, ok := validVersion(ctx)
+	if !ok {
+		return
+	}
+
+	upload, needsClose, err := ctx.UploadStream()
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if needsClose {
+		defer upload.Close()
+	}
+
+	buf, err := packages_module.CreateHashedBufferFromReader(upload)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer buf.Close()
+
+	metadata, err := terraform_module.ParseModuleArchive(buf)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			apiError(ctx, http.StatusBadRequest, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if _, err := buf.Seek(0, io.SeekStart); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, _, err = packages_service.CreatePackageAndAddFile(
+		ctx,
+		&packages_service.PackageCreationInfo{
+			PackageInfo: packages_service.PackageInfo{
+				Owner:       ctx.Package.Owner,
+				PackageType: packages_model.TypeTerraform,
+				Name:        name,
+				Version:     moduleVersion,
+			},
+			SemverCompatible: true,
+			Creator:          ctx.Doer,
+			Metadata:         metadata,
+		},
+		&packages_service.PackageFileCreationInfo{
+			PackageFileInfo: packages_service.PackageFileInfo{
+				Filename: terraform_module.ModuleArchiveFilename,
+			},
+			Creator: ctx.Doer,
+			Data:    buf,
+			IsLead:  true,
+		},
+	)
+	if err != nil {
+		switch err {
+		case packages_model.ErrDuplicatePackageVersion:
+			apiError(ctx, http.StatusConflict, err)
+		case packages_service.ErrQuotaTotalCount, packages_service.ErrQuotaTypeSize, packages_service.ErrQuotaTotalSize:
+			apiError(ctx, http.StatusForbidden, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusCreated)
+}
+
+// DeleteModuleVersion deletes a module version
+func DeleteModuleVersion(ctx *context.Context) {
+	name, ok := modulePackageName(ctx)
+	if !ok {
+		return
+	}
+
+	deletePackageVersion(ctx, name)
+}
+
+func deletePackageVersion(ctx *context.Context, name string) {
+	err := packages_service.RemovePackageVersionByNameAndVersion(
+		ctx,
+		ctx.Doer,
+		&packages_service.PackageInfo{
+			Owner:       ctx.Package.Owner,
+			PackageType: packages_model.TypeTerraform,
+			Name:        name,
+			Version:     ctx.Params("version"),
+		},
+	)
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type providerPlatform struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+}
+
+type providerVersion struct {
+	Version   string              `json:"version"`
+	Protocols []string            `json:"protocols"`
+	Platforms []*providerPlatform `json:"platforms"`
+}
+
+// ListProviderVersions lists the versions of a provider and their platforms
+// https://developer.hashicorp.com/terraform/internals/provider-registry-protocol#list-available-versions
+func ListProviderVersions(ctx *context.Context) {
+	providerType, ok := providerType(ctx)
+	if !ok {
+		return
+	}
+
+	pds, err := getPackageDescriptors(ctx, providerType)
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	versions := make([]*providerVersion, 0, len(pds))
+	for _, pd := range pds {
+		platforms := make([]*providerPlatform, 0, len(pd.Files))
+		for _, pfd := range pd.Files {
+			platforms = append(platforms, &providerPlatform{
+				OS:   pfd.Properties.GetByName(terraform_module.PropertyOS),
+				Arch: pfd.Properties.GetByName(terraform_module.PropertyArch),
+			})
+		}
+		versions = append(versions, &providerVersion{
+			Version:   pd.Version.Version,
+			Protocols: pd.Metadata.(*terraform_module.Metadata).Protocols,
+			Platforms: platforms,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Versions []*providerVersion `json:"versions"`
+	}{
+		Versions: versions,
+	})
+}
+
+type gpgPublicKey struct {
+	KeyID      string `json:"key_id"`
+	ASCIIArmor string `json:"ascii_armor"`
+}
+
+type providerPackage struct {
+	Protocols           []string `json:"protocols"`
+	OS                  string   `json:"os"`
+	Arch                string   `json:"arch"`
+	Filename            string   `json:"filename"`
+	DownloadURL         string   `json:"download_url"`
+	SHASumsURL          string   `json:"shasums_url"`
+	SHASumsSignatureURL string   `json:"shasums_signature_url"`
+	SHASum              string   `json:"shasum"`
+	SigningKeys         struct {
+		GPGPublicKeys []*gpgPublicKey `json:"gpg_public_keys"`
+	} `json:"signing_keys"`
+}
+
+// getProviderDescriptor returns the descriptor of a provider version
+func getProviderDescriptor(ctx *context.Context) (*packages_model.PackageDescriptor, bool) {
+	providerType, ok := providerType(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeTerraform, providerType, ctx.Params("version"))
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return nil, false
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	pd, err := packages_model.GetPackageDescriptor(ctx, pv)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return pd, true
+}
+
+// ProviderPackage returns the download location, the checksums and the signing key of a provider build
+// https://developer.hashicorp.com/terraform/internals/provider-registry-protocol#find-a-provider-package
+func ProviderPackage(ctx *context.Context) {
+	pd, ok := getProviderDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	filename := terraform_module.ProviderFilename(pd.Package.Name, pd.Version.Version, ctx.Params("os"), ctx.Params("arch"))
+	var pfd *packages_model.PackageFileDescriptor
+	for _, f := range pd.Files {
+		if f.File.Name == filename {
+			pfd = f
+			break
+		}
+	}
+	if pfd == nil {
+		apiError(ctx, http.StatusNotFound, packages_model.ErrPackageFileNotExist)
+		return
+	}
+
+	_, pub, err := terraform_service.GetOrCreateKeyPair(ctx, ctx.Package.Owner.ID)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	keyID, err := terraform_service.KeyID(pub)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	versionURL := fmt.Sprintf("%s/providers/%s/%s", packageURL(ctx), url.PathEscape(pd.Package.Name), url.PathEscape(pd.Version.Version))
+
+	resp := &providerPackage{
+		Protocols:           pd.Metadata.(*terraform_module.Metadata).Protocols,
+		OS:                  pfd.Properties.GetByName(terraform_module.PropertyOS),
+		Arch:                pfd.Properties.GetByName(terraform_module.PropertyArch),
+		Filename:            filename,
+		DownloadURL:         fmt.Sprintf("%s/%s/%s", versionURL, url.PathEscape(ctx.Params("os")), url.PathEscape(ctx.Params("arch"))),
+		SHASumsURL:          versionURL + "/SHA256SUMS",
+		SHASumsSignatureURL: versionURL + "/SHA256SUMS.sig",
+		SHASum:              pfd.Blob.HashSHA256,
+	}
+	resp.SigningKeys.GPGPublicKeys = []*gpgPublicKey{{KeyID: keyID, ASCIIArmor: pub}}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// ProviderSHASums serves the checksums of the builds of a provider version
+func ProviderSHASums(ctx *context.Context) {
+	pd, ok := getProviderDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.PlainTextBytes(http.StatusOK, terraform_service.BuildSHASums(pd.Files))
+}
+
+// ProviderSHASumsSignature serves the detached signature of the checksums of a provider version
+func ProviderSHASumsSignature(ctx *context.Context) {
+	pd, ok := getProviderDescriptor(ctx)
+	if !ok {
+		return
+	}
+
+	priv, _, err := terraform_service.GetOrCreateKeyPair(ctx, ctx.Package.Owner.ID)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	signature, err := terraform_service.SignSHASums(priv, terraform_service.BuildSHASums(pd.Files))
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Resp.Header().Set("Content-Type", "application/octet-stream")
+	ctx.Status(http.StatusOK)
+	_, _ = ctx.Resp.Write(signature)
+}
+
+// DownloadProviderFile serves the archive of a provider build
+func DownloadProviderFile(ctx *context.Context) {
+	providerType, ok := providerType(ctx)
+	if !ok {
+		return
+	}
+
+	s, u, pf, err := packages_service.GetFileStreamByPackageNameAndVersion(
+		ctx,
+		&packages_service.PackageInfo{
+			Owner:       ctx.Package.Owner,
+			PackageType: packages_model.TypeTerraform,
+			Name:        providerType,
+			Version:     ctx.Params("version"),
+		},
+		&packages_service.PackageFileInfo{
+			Filename: terraform_module.ProviderFilename(providerType, ctx.Params("version"), ctx.Params("os"), ctx.Params("arch")),
+		},
+	)
+	if err != nil {
+		if errors.Is(err, packages_model.ErrPackageNotExist) || errors.Is(err, packages_model.ErrPackageFileNotExist) {
+			apiError(ctx, http.StatusNotFound, err)
+			return
+		}
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	helper.ServePackageFile(ctx, s, u, pf)
+}
+
+// UploadProvider adds the zip archive of a provider build for a platform to a provider version
+func UploadProvider(ctx *context.Context) {
+	providerType, ok := providerType(ctx)
+	if !ok {
+		return
+	}
+	providerVersion, ok := validVersion(ctx)
+	if !ok {
+		return
+	}
+	os, arch := ctx.Params("os"), ctx.Params("arch")
+	if !terraform_module.IsValidPlatform(os, arch) {
+		apiError(ctx, http.StatusBadRequest, terraform_module.ErrInvalidPlatform)
+		return
+	}
+	protocols, err := terraform_module.ParseProtocols(ctx.FormTrim("protocols"))
+	if err != nil {
+		apiError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	upload, needsClose, err := ctx.UploadStream()
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if needsClose {
+		defer upload.Close()
+	}
+
+	buf, err := packages_module.CreateHashedBufferFromReader(upload)
+	if err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	defer buf.Close()
+
+	metadata, err := terraform_module.ParseProviderArchive(buf, buf.Size(), providerType)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			apiError(ctx, http.StatusBadRequest, err)
+		} else {
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	metadata.Protocols = protocols
+
+	// the protocols are those of the version, all the platforms of a version must have the same ones
+	pv, err := packages_model.GetVersionByNameAndVersion(ctx, ctx.Package.Owner.ID, packages_model.TypeTerraform, providerType, providerVersion)
+	if err != nil && !errors.Is(err, packages_model.ErrPackageNotExist) {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	if pv != nil {
+		existing := &terraform_module.Metadata{}
+		if err := json.Unmarshal([]byte(pv.MetadataJSON), existing); err != nil {
+			apiError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+		if ctx.FormTrim("protocols") == "" {
+			metadata.Protocols = existing.Protocols
+		} else if !terraform_module.SameProtocols(existing.Protocols, metadata.Protocols) {
+			apiError(ctx, http.StatusBadRequest, terraform_module.ErrProtocolMismatch)
+			return
+		}
+	}
+
+	if _, err := buf.Seek(0, io.SeekStart); err != nil {
+		apiError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, _, err = packages_service.CreatePackageOrAddFileToExisting(
+		ctx,
+		&packages_service.PackageCreationInfo{
+			PackageInfo: packages_service.PackageInfo{
+				Owner:       ctx.Package.Owner,
+				PackageType: packages_model.TypeTerraform,
+				Name:        providerType,
+				Version:     providerVersion,
+			},
+			SemverCompatible: true,
+			Creator:          ctx.Doer,
+			Metadata:         metadata,
+		},
+		&packages_service.PackageFileCreationInfo{
+			PackageFileInfo: packages_service.PackageFileInfo{
+				Filename: terraform_module.ProviderFilename(providerType, providerVersion, os, arch),
+			},
+			Creator: ctx.Doer,
+			Data:    buf,
+			IsLead:  pv == nil,
+			Properties: map[string]string{
+				terraform_module.PropertyOS:   os,
+				terraform_module.PropertyArch: arch,
+			},
+		},
+	)
+	if err != nil {
+		switch err {
+		case packages_model.ErrDuplicatePackageFile:
+			apiError(ctx, http.StatusConflict, err)
+		case packages_service.ErrQuotaTotalCount, packages_service.ErrQuotaTypeSize, packages_service.ErrQuotaTotalSize:
+			apiError(ctx, http.StatusForbidden, err)
+		default:
+			apiError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusCreated)
+}
+
+// DeleteProviderVersion deletes a provider version with the builds of all its platforms
+func DeleteProviderVersion(ctx *context.Context) {
+	providerType, ok := providerType(ctx)
+	if !ok {
+		return
+	}
+
+	deletePackageVersion(ctx, providerType)
+}
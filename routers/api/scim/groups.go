@@ -0,0 +1,92 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"net/http"
+	"strings"
+
+	scim_module "code.gitea.io/gitea/modules/scim"
+	"code.gitea.io/gitea/services/context"
+	scim_service "code.gitea.io/gitea/services/scim"
+)
+
+// withMembers returns whether the members of the groups are requested, identity providers exclude them
+// when they only look groups up
+func withMembers(ctx *context.APIContext) bool {
+	return !strings.Contains(strings.ToLower(ctx.FormString("excludedAttributes")), "members")
+}
+
+// ListGroups lists the SCIM groups of the authentication source
+func ListGroups(ctx *context.APIContext) {
+	startIndex, count := pagination(ctx)
+	list, err := scim_service.FindGroups(ctx, getSource(ctx), ctx.FormString("filter"), startIndex, count, withMembers(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, list)
+}
+
+// GetGroup returns a SCIM group of the authentication source
+func GetGroup(ctx *context.APIContext) {
+	g, err := scim_service.GetGroup(ctx, getSource(ctx), ctx.Params("id"), withMembers(ctx))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, g)
+}
+
+// CreateGroup creates a SCIM group in the authentication source
+func CreateGroup(ctx *context.APIContext) {
+	var sg scim_module.Group
+	if !decode(ctx, &sg) {
+		return
+	}
+	g, err := scim_service.CreateGroup(ctx, getSource(ctx), &sg)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Resp.Header().Set("Location", g.Meta.Location)
+	writeJSON(ctx, http.StatusCreated, g)
+}
+
+// ReplaceGroup replaces the attributes and the members of a SCIM group of the authentication source
+func ReplaceGroup(ctx *context.APIContext) {
+	var sg scim_module.Group
+	if !decode(ctx, &sg) {
+		return
+	}
+	g, err := scim_service.ReplaceGroup(ctx, getSource(ctx), ctx.Params("id"), &sg)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, g)
+}
+
+// PatchGroup modifies the attributes and the members of a SCIM group of the authentication source
+func PatchGroup(ctx *context.APIContext) {
+	var patch scim_module.PatchOp
+	if !decode(ctx, &patch) {
+		return
+	}
+	g, err := scim_service.PatchGroup(ctx, getSource(ctx), ctx.Params("id"), patch.Operations)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, g)
+}
+
+// DeleteGroup deletes a SCIM group of the authentication source, its members leave the teams it is mapped to
+func DeleteGroup(ctx *context.APIContext) {
+	if err := scim_service.DeleteGroup(ctx, getSource(ctx), ctx.Params("id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+// Package scim implements the SCIM 2.0 API identity providers use to provision the users and the groups
+// of an authentication source. It is authenticated by the SCIM token of the source.
+package scim
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	scim_module "code.gitea.io/gitea/modules/scim"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/web"
+	"code.gitea.io/gitea/services/context"
+)
+
+const sourceKey = "SCIMSource"
+
+// Routes returns the routes of the SCIM API
+func Routes() *web.Route {
+	m := web.NewRoute()
+
+	m.Use(context.APIContexter())
+	m.Use(verifyToken)
+
+	m.Group("/Users", func() {
+		m.Get("", ListUsers)
+		m.Post("", CreateUser)
+		m.Group("/{id}", func() {
+			m.Get("", GetUser)
+			m.Put("", ReplaceUser)
+			m.Patch("", PatchUser)
+			m.Delete("", DeleteUser)
+		})
+	})
+	m.Group("/Groups", func() {
+		m.Get("", ListGroups)
+		m.Post("", CreateGroup)
+		m.Group("/{id}", func() {
+			m.Get("", GetGroup)
+			m.Put("", ReplaceGroup)
+			m.Patch("", PatchGroup)
+			m.Delete("", DeleteGroup)
+		})
+	})
+
+	return m
+}
+
+// verifyToken authenticates the identity provider by the bearer SCIM token of an active authentication source
+func verifyToken(ctx *context.APIContext) {
+	token, ok := strings.CutPrefix(ctx.Req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		ctx.Resp.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+		writeError(ctx, http.StatusUnauthorized, "", "a bearer token is required")
+		return
+	}
+	source, err := auth_model.GetSourceBySCIMToken(ctx, strings.TrimSpace(token))
+	if err != nil {
+		if !errors.Is(err, util.ErrNotExist) {
+			log.Error("GetSourceBySCIMToken: %v", err)
+		}
+		writeError(ctx, http.StatusUnauthorized, "", "invalid token")
+		return
+	}
+	if !source.IsActive {
+		writeError(ctx, http.StatusForbidden, "", "the authentication source is not active")
+		return
+	}
+	ctx.Data[sourceKey] = source
+}
+
+func getSource(ctx *context.APIContext) *auth_model.Source {
+	return ctx.Data[sourceKey].(*auth_model.Source)
+}
+
+func writeJSON(ctx *context.APIContext, status int, content any) {
+	ctx.Resp.Header().Set("Content-Type", scim_module.ContentType+";charset=utf-8")
+	ctx.Resp.WriteHeader(status)
+	if err := json.NewEncoder(ctx.Resp).Encode(content); err != nil {
+		log.Error("Render SCIM JSON failed: %v", err)
+	}
+}
+
+func writeError(ctx *context.APIContext, status int, scimType, detail string) {
+	writeJSON(ctx, status, scim_module.NewError(status, scimType, detail))
+}
+
+// handleError responds to a request failing with err
+func handleError(ctx *context.APIContext, err error) {
+	var invalid *scim_module.InvalidError
+	switch {
+	case errors.As(err, &invalid):
+		writeJSON(ctx, http.StatusBadRequest, invalid.Response())
+	case errors.Is(err, util.ErrNotExist):
+		writeError(ctx, http.StatusNotFound, "", err.Error())
+	case errors.Is(err, util.ErrAlreadyExist):
+		writeError(ctx, http.StatusConflict, scim_module.ErrorTypeUniqueness, err.Error())
+	case errors.Is(err, util.ErrInvalidArgument):
+		writeError(ctx, http.StatusBadRequest, scim_module.ErrorTypeInvalidValue, err.Error())
+	default:
+		log.Error("SCIM %s %s: %v", ctx.Req.Method, ctx.Req.URL.Path, err)
+		writeError(ctx, http.StatusInternalServerError, "", "internal server error")
+	}
+}
+
+// decode reads the body of a request, and responds with an error when it is not valid
+func decode(ctx *context.APIContext, v any) bool {
+	if err := json.NewDecoder(ctx.Req.Body).Decode(v); err != nil {
+		writeError(ctx, http.StatusBadRequest, scim_module.ErrorTypeInvalidSyntax, err.Error())
+		return false
+	}
+	return true
+}
+
+// pagination returns the 1-based index of the first resource to list and their count,
+// which is at most MAX_RESPONSE_ITEMS like in the API
+func pagination(ctx *context.APIContext) (int, int) {
+	startIndex := max(ctx.FormInt("startIndex"), 1)
+	count := ctx.FormInt("count")
+	if count <= 0 {
+		count = setting.API.DefaultPagingNum
+	}
+	return startIndex, min(count, setting.API.MaxResponseItems)
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"net/http"
+
+	scim_module "code.gitea.io/gitea/modules/scim"
+	"code.gitea.io/gitea/services/context"
+	scim_service "code.gitea.io/gitea/services/scim"
+)
+
+// ListUsers lists the users provisioned in the authentication source
+func ListUsers(ctx *context.APIContext) {
+	startIndex, count := pagination(ctx)
+	list, err := scim_service.FindUsers(ctx, getSource(ctx), ctx.FormString("filter"), startIndex, count)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, list)
+}
+
+// GetUser returns a user provisioned in the authentication source
+func GetUser(ctx *context.APIContext) {
+	u, err := scim_service.GetUser(ctx, getSource(ctx), ctx.Params("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, u)
+}
+
+// CreateUser provisions a user in the authentication source
+func CreateUser(ctx *context.APIContext) {
+	var su scim_module.User
+	if !decode(ctx, &su) {
+		return
+	}
+	u, err := scim_service.CreateUser(ctx, getSource(ctx), &su)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Resp.Header().Set("Location", u.Meta.Location)
+	writeJSON(ctx, http.StatusCreated, u)
+}
+
+// ReplaceUser replaces the attributes of a user provisioned in the authentication source
+func ReplaceUser(ctx *context.APIContext) {
+	var su scim_module.User
+	if !decode(ctx, &su) {
+		return
+	}
+	u, err := scim_service.ReplaceUser(ctx, getSource(ctx), ctx.Params("id"), &su)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, u)
+}
+
+// PatchUser modifies the attributes of a user provisioned in the authentication source
+func PatchUser(ctx *context.APIContext) {
+	var patch scim_module.PatchOp
+	if !decode(ctx, &patch) {
+		return
+	}
+	u, err := scim_service.PatchUser(ctx, getSource(ctx), ctx.Params("id"), patch.Operations)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeJSON(ctx, http.StatusOK, u)
+}
+
+// DeleteUser deprovisions a user of the authentication source, who is prohibited from signing in but not deleted
+func DeleteUser(ctx *context.APIContext) {
+	if err := scim_service.DeprovisionUser(ctx, getSource(ctx), ctx.Params("id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -4,14 +4,18 @@
 package activitypub
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
 	"code.gitea.io/gitea/modules/activitypub"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/federation"
 
 	ap "github.com/go-ap/activitypub"
 	"github.com/go-ap/jsonld"
@@ -102,5 +106,113 @@ func PersonInbox(ctx *context.APIContext) {
 	//   "204":
 	//     "$ref": "#/responses/empty"
 
+	body, err := io.ReadAll(io.LimitReader(ctx.Req.Body, setting.Federation.MaxSize))
+	if err != nil {
+		ctx.ServerError("ReadAll", err)
+		return
+	}
+
+	signerID, err := signerActorID(ctx)
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, "signerActorID", err)
+		return
+	}
+
+	httpStatus, title, err := federation.ProcessPersonInbox(ctx, ctx.ContextUser, signerID, body)
+	if err != nil {
+		ctx.Error(httpStatus, title, err)
+		return
+	}
 	ctx.Status(http.StatusNoContent)
 }
+
+// PersonOutbox function returns the public activities of a user
+func PersonOutbox(ctx *context.APIContext) {
+	// swagger:operation GET /activitypub/user-id/{user-id}/outbox activitypub activitypubPersonOutbox
+	// ---
+	// summary: Returns the public activities of a user
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: user-id
+	//   in: path
+	//   description: user ID of the user
+	//   type: integer
+	//   required: true
+	// - name: page
+	//   in: query
+	//   description: page number of the collection, the collection itself is returned if omitted
+	//   type: integer
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ActivityPub"
+
+	link := fmt.Sprintf("%s/api/v1/activitypub/user-id/%d/outbox", strings.TrimSuffix(setting.AppURL, "/"), ctx.ContextUser.ID)
+	page := ctx.FormInt("page")
+
+	actions, count, err := federation.GetOutboxActions(ctx, ctx.ContextUser, max(page, 1))
+	if err != nil {
+		ctx.ServerError("GetOutboxActions", err)
+		return
+	}
+
+	outbox := ap.OrderedCollectionNew(ap.IRI(link))
+	outbox.TotalItems = uint(count)
+	outbox.First = ap.IRI(link + "?page=1")
+	if page < 1 {
+		response(ctx, outbox)
+		return
+	}
+
+	outboxPage := ap.OrderedCollectionPageNew(outbox)
+	outboxPage.ID = ap.IRI(fmt.Sprintf("%s?page=%d", link, page))
+	if page > 1 {
+		outboxPage.Prev = ap.IRI(fmt.Sprintf("%s?page=%d", link, page-1))
+	}
+	if int64(page*setting.API.DefaultPagingNum) < count {
+		outboxPage.Next = ap.IRI(fmt.Sprintf("%s?page=%d", link, page+1))
+	}
+	for _, act := range actions {
+		if err := outboxPage.OrderedItems.Append(federation.ActionToActivity(ctx, act)); err != nil {
+			ctx.ServerError("Append", err)
+			return
+		}
+	}
+	response(ctx, outboxPage)
+}
+
+// PersonOutboxActivity function returns a public activity of a user
+func PersonOutboxActivity(ctx *context.APIContext) {
+	// swagger:operation GET /activitypub/user-id/{user-id}/outbox/{activity-id} activitypub activitypubPersonOutboxActivity
+	// ---
+	// summary: Returns a public activity of a user
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: user-id
+	//   in: path
+	//   description: user ID of the user
+	//   type: integer
+	//   required: true
+	// - name: activity-id
+	//   in: path
+	//   description: ID of the activity
+	//   type: integer
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ActivityPub"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+
+	act, err := federation.GetOutboxAction(ctx, ctx.ContextUser, ctx.ParamsInt64("activity-id"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.NotFound()
+		} else {
+			ctx.ServerError("GetOutboxAction", err)
+		}
+		return
+	}
+	response(ctx, federation.ActionToActivity(ctx, act))
+}
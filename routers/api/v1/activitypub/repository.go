@@ -5,13 +5,15 @@ package activitypub
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unit"
 	"code.gitea.io/gitea/modules/forgefed"
-	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
-	"code.gitea.io/gitea/modules/web"
 	"code.gitea.io/gitea/services/context"
 	"code.gitea.io/gitea/services/federation"
 
@@ -44,10 +46,26 @@ func Repository(ctx *context.APIContext) {
 		ctx.Error(http.StatusInternalServerError, "Set Name", err)
 		return
 	}
+	if ctx.Repo.Repository.Description != "" {
+		repo.Summary = ap.DefaultNaturalLanguageValue(ctx.Repo.Repository.Description)
+	}
+	repo.Inbox = ap.IRI(link + "/inbox")
+	if !ctx.Repo.Repository.IsPrivate {
+		repo.CloneURI = ap.IRI(ctx.Repo.Repository.CloneLink().HTTPS)
+	}
+
+	federatedFork, err := repo_model.GetFederatedForkByRepoID(ctx, ctx.Repo.Repository.ID)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "GetFederatedForkByRepoID", err)
+		return
+	}
+	if federatedFork != nil {
+		repo.ForkedFrom = ap.IRI(federatedFork.URI)
+	}
 	response(ctx, repo)
 }
 
-// PersonInbox function handles the incoming data for a repository inbox
+// RepositoryInbox function handles the incoming data for a repository inbox
 func RepositoryInbox(ctx *context.APIContext) {
 	// swagger:operation POST /activitypub/repository-id/{repository-id}/inbox activitypub activitypubRepositoryInbox
 	// ---
@@ -68,13 +86,79 @@ func RepositoryInbox(ctx *context.APIContext) {
 	//   "204":
 	//     "$ref": "#/responses/empty"
 
-	repository := ctx.Repo.Repository
-	log.Info("RepositoryInbox: repo: %v", repository)
+	body, err := io.ReadAll(io.LimitReader(ctx.Req.Body, setting.Federation.MaxSize))
+	if err != nil {
+		ctx.ServerError("ReadAll", err)
+		return
+	}
+
+	signerID, err := signerActorID(ctx)
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, "signerActorID", err)
+		return
+	}
 
-	form := web.GetForm(ctx)
-	httpStatus, title, err := federation.ProcessLikeActivity(ctx, form, repository.ID)
+	httpStatus, title, err := federation.ProcessRepositoryInbox(ctx, ctx.Repo.Repository, signerID, body)
 	if err != nil {
 		ctx.Error(httpStatus, title, err)
+		return
 	}
 	ctx.Status(http.StatusNoContent)
 }
+
+// RepositoryTicket function returns the Ticket of an issue or a pull request
+func RepositoryTicket(ctx *context.APIContext) {
+	// swagger:operation GET /activitypub/repository-id/{repository-id}/issues/{index} activitypub activitypubRepositoryTicket
+	// ---
+	// summary: Returns the Ticket of an issue or a pull request
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: repository-id
+	//   in: path
+	//   description: repository ID of the repo
+	//   type: integer
+	//   required: true
+	// - name: index
+	//   in: path
+	//   description: index of the issue
+	//   type: integer
+	//   format: int64
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/ActivityPub"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+
+	repository := ctx.Repo.Repository
+	if repository.IsPrivate {
+		ctx.NotFound()
+		return
+	}
+
+	issue, err := issues_model.GetIssueByIndex(ctx, repository.ID, ctx.ParamsInt64(":index"))
+	if err != nil {
+		if issues_model.IsErrIssueNotExist(err) {
+			ctx.NotFound()
+		} else {
+			ctx.Error(http.StatusInternalServerError, "GetIssueByIndex", err)
+		}
+		return
+	}
+	unitType := unit.TypeIssues
+	if issue.IsPull {
+		unitType = unit.TypePullRequests
+	}
+	if !repository.UnitEnabled(ctx, unitType) {
+		ctx.NotFound()
+		return
+	}
+
+	ticket, err := federation.IssueToTicket(ctx, repository, issue)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "IssueToTicket", err)
+		return
+	}
+	response(ctx, ticket)
+}
@@ -4,14 +4,25 @@
 package activitypub
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
+	"time"
 
+	"code.gitea.io/gitea/models/forgefed"
 	"code.gitea.io/gitea/modules/activitypub"
 	"code.gitea.io/gitea/modules/httplib"
 	"code.gitea.io/gitea/modules/log"
@@ -45,6 +56,7 @@ func fetch(iri *url.URL) (b []byte, err error) {
 	req := httplib.NewRequest(iri.String(), http.MethodGet)
 	req.Header("Accept", activitypub.ActivityStreamsContentType)
 	req.Header("User-Agent", "Gitea/"+setting.AppVer)
+	req.SetTransport(activitypub.NewTransport())
 	resp, err := req.Response()
 	if err != nil {
 		return nil, err
@@ -58,6 +70,69 @@ func fetch(iri *url.URL) (b []byte, err error) {
 	return b, err
 }
 
+// signatureMaxClockSkew is how far the Date of a signed request may be from the current time
+const signatureMaxClockSkew = 5 * time.Minute
+
+// signedHeaders returns the lower cased names of the headers covered by the signature of a request
+func signedHeaders(r *http.Request) []string {
+	signature := r.Header.Get("Signature")
+	if signature == "" {
+		signature, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Signature ")
+	}
+	for _, param := range strings.Split(signature, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && name == "headers" {
+			return strings.Fields(strings.ToLower(strings.Trim(value, `"`)))
+		}
+	}
+	// without a list of headers, only the Date is signed
+	return []string{"date"}
+}
+
+// verifySignedRequest makes sure a signature cannot be replayed: the Date and the Digest of the body must
+// be signed, the Date must be recent and the Digest must match the body, which is restored for the handler.
+func verifySignedRequest(r *http.Request) error {
+	headers := signedHeaders(r)
+	for _, required := range []string{httpsig.RequestTarget, "date", "digest"} {
+		if !slices.Contains(headers, required) {
+			return fmt.Errorf("the %s header is not signed", required)
+		}
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("invalid Date header: %w", err)
+	}
+	if skew := time.Since(date).Abs(); skew > signatureMaxClockSkew {
+		return fmt.Errorf("the Date header is %s away from the current time", skew)
+	}
+
+	algorithm, expected, ok := strings.Cut(r.Header.Get("Digest"), "=")
+	if !ok {
+		return errors.New("invalid Digest header")
+	}
+	var h hash.Hash
+	switch strings.ToUpper(algorithm) {
+	case "SHA-256":
+		h = sha256.New()
+	case "SHA-512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, setting.Federation.MaxSize))
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	h.Write(body)
+	actual := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) != 1 {
+		return errors.New("the Digest header does not match the body")
+	}
+	return nil
+}
+
 func verifyHTTPSignatures(ctx *gitea_context.APIContext) (authenticated bool, err error) {
 	r := ctx.Req
 
@@ -66,6 +141,9 @@ func verifyHTTPSignatures(ctx *gitea_context.APIContext) (authenticated bool, er
 	if err != nil {
 		return false, err
 	}
+	if err := verifySignedRequest(r); err != nil {
+		return false, err
+	}
 	ID := v.KeyId()
 	idIRI, err := url.Parse(ID)
 	if err != nil {
@@ -86,10 +164,50 @@ func verifyHTTPSignatures(ctx *gitea_context.APIContext) (authenticated bool, er
 	return authenticated, err
 }
 
+// signerActorID returns the actor owning the key the request was signed with
+func signerActorID(ctx *gitea_context.APIContext) (string, error) {
+	v, err := httpsig.NewVerifier(ctx.Req)
+	if err != nil {
+		return "", err
+	}
+	keyID, err := url.Parse(v.KeyId())
+	if err != nil {
+		return "", err
+	}
+	keyID.Fragment = ""
+	return keyID.String(), nil
+}
+
+// isSignerBlocked checks the host of the signing key against the federation host policies.
+// It runs before the key is fetched so blocked instances are never contacted.
+func isSignerBlocked(ctx *gitea_context.APIContext) (bool, error) {
+	v, err := httpsig.NewVerifier(ctx.Req)
+	if err != nil {
+		return false, err
+	}
+	keyID, err := url.Parse(v.KeyId())
+	if err != nil {
+		return false, err
+	}
+	appURL, err := url.Parse(setting.AppURL)
+	if err != nil {
+		return false, err
+	}
+	if keyID.Hostname() == appURL.Hostname() {
+		return false, nil
+	}
+	return forgefed.IsFederationHostBlocked(ctx, keyID.Hostname())
+}
+
 // ReqHTTPSignature function
 func ReqHTTPSignature() func(ctx *gitea_context.APIContext) {
 	return func(ctx *gitea_context.APIContext) {
-		if authenticated, err := verifyHTTPSignatures(ctx); err != nil {
+		if blocked, err := isSignerBlocked(ctx); err != nil {
+			log.Warn("isSignerBlocked failed: %v", err)
+			ctx.Error(http.StatusBadRequest, "reqSignature", "request signature verification failed")
+		} else if blocked {
+			ctx.Error(http.StatusForbidden, "reqSignature", "federation with this instance is blocked")
+		} else if authenticated, err := verifyHTTPSignatures(ctx); err != nil {
 			log.Warn("verifyHttpSignatures failed: %v", err)
 			ctx.Error(http.StatusBadRequest, "reqSignature", "request signature verification failed")
 		} else if !authenticated {
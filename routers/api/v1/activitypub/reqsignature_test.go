@@ -0,0 +1,73 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+
+	"github.com/go-fed/httpsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignedRequest(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.MaxSize, int64(1<<20))()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	const body = `{"type":"Follow"}`
+	newSignedRequest := func(t *testing.T, date time.Time, headers []string) *http.Request {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/api/v1/activitypub/user-id/2/inbox", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+		req.Header.Set("Host", req.URL.Host)
+		signer, _, err := httpsig.NewSigner([]httpsig.Algorithm{httpsig.RSA_SHA256}, httpsig.DigestSha256, headers, httpsig.Signature, 60)
+		require.NoError(t, err)
+		require.NoError(t, signer.SignRequest(priv, "https://remote.example/api/v1/activitypub/user-id/1#main-key", req, []byte(body)))
+		return req
+	}
+	postHeaders := []string{httpsig.RequestTarget, "Date", "Host", "Digest"}
+
+	t.Run("Valid", func(t *testing.T) {
+		req := newSignedRequest(t, time.Now(), postHeaders)
+		require.NoError(t, verifySignedRequest(req))
+
+		// the body is still readable by the inbox
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(b))
+	})
+
+	t.Run("TamperedBody", func(t *testing.T) {
+		req := newSignedRequest(t, time.Now(), postHeaders)
+		req.Body = io.NopCloser(strings.NewReader(`{"type":"Delete"}`))
+		require.ErrorContains(t, verifySignedRequest(req), "does not match the body")
+	})
+
+	t.Run("DigestNotSigned", func(t *testing.T) {
+		req := newSignedRequest(t, time.Now(), []string{httpsig.RequestTarget, "Date", "Host"})
+		require.ErrorContains(t, verifySignedRequest(req), "digest header is not signed")
+	})
+
+	t.Run("DateNotSigned", func(t *testing.T) {
+		req := newSignedRequest(t, time.Now(), []string{httpsig.RequestTarget, "Host", "Digest"})
+		require.ErrorContains(t, verifySignedRequest(req), "date header is not signed")
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		req := newSignedRequest(t, time.Now().Add(-time.Hour), postHeaders)
+		require.ErrorContains(t, verifySignedRequest(req), "away from the current time")
+	})
+}
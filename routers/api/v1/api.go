@@ -72,6 +72,7 @@ import (
 	"strings"
 
 	actions_model "code.gitea.io/gitea/models/actions"
+	audit_model "code.gitea.io/gitea/models/audit"
 	auth_model "code.gitea.io/gitea/models/auth"
 	issues_model "code.gitea.io/gitea/models/issues"
 	"code.gitea.io/gitea/models/organization"
@@ -81,7 +82,6 @@ import (
 	repo_model "code.gitea.io/gitea/models/repo"
 	"code.gitea.io/gitea/models/unit"
 	user_model "code.gitea.io/gitea/models/user"
-	"code.gitea.io/gitea/modules/forgefed"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
 	api "code.gitea.io/gitea/modules/structs"
@@ -97,6 +97,7 @@ import (
 	"code.gitea.io/gitea/routers/api/v1/settings"
 	"code.gitea.io/gitea/routers/api/v1/user"
 	"code.gitea.io/gitea/services/actions"
+	audit_service "code.gitea.io/gitea/services/audit"
 	"code.gitea.io/gitea/services/auth"
 	"code.gitea.io/gitea/services/context"
 	"code.gitea.io/gitea/services/forms"
@@ -104,6 +105,7 @@ import (
 	_ "code.gitea.io/gitea/routers/api/v1/swagger" // for swagger generation
 
 	"code.forgejo.org/go-chi/binding"
+	"github.com/go-chi/chi/v5"
 )
 
 func sudo() func(ctx *context.APIContext) {
@@ -125,6 +127,7 @@ func sudo() func(ctx *context.APIContext) {
 					return
 				}
 				log.Trace("Sudo from (%s) to: %s", ctx.Doer.Name, user.Name)
+				audit_service.Record(ctx, audit_model.UserImpersonation, ctx.Doer, nil, user, "Impersonated %s for %s %s.", user.Name, ctx.Req.Method, ctx.Req.URL.Path)
 				ctx.Doer = user
 			} else {
 				ctx.JSON(http.StatusForbidden, map[string]string{
@@ -190,6 +193,11 @@ func repoAssignment() func(ctx *context.APIContext) {
 		repo.Owner = owner
 		ctx.Repo.Repository = repo
 
+		if !context.IsRepoAllowedByToken(ctx.Data, repo) {
+			ctx.NotFound()
+			return
+		}
+
 		if ctx.Doer != nil && ctx.Doer.ID == user_model.ActionsUserID {
 			taskID := ctx.Data["ActionsTaskID"].(int64)
 			task, err := actions_model.GetTaskByID(ctx, taskID)
@@ -364,6 +372,14 @@ func tokenRequiresScopes(requiredScopeCategories ...auth_model.AccessTokenScopeC
 			return
 		}
 
+		// a token restricted to repositories can only be used on the routes of a repository,
+		// the repository itself is checked by repoAssignment
+		if t, ok := ctx.Data["ApiToken"].(*auth_model.AccessToken); ok && t.IsRepoRestricted() &&
+			!strings.Contains(chi.RouteContext(ctx.Req.Context()).RoutePattern(), "/repos/{username}/{reponame}") {
+			ctx.Error(http.StatusForbidden, "tokenRequiresScope", "token is restricted to repositories")
+			return
+		}
+
 		ctx.Data["requiredScopeCategories"] = requiredScopeCategories
 
 		// check if scope only applies to public resources
@@ -841,10 +857,14 @@ func Routes() *web.Route {
 				m.Group("/user/{username}", func() {
 					m.Get("", activitypub.Person)
 					m.Post("/inbox", activitypub.ReqHTTPSignature(), activitypub.PersonInbox)
+					m.Get("/outbox", activitypub.PersonOutbox)
+					m.Get("/outbox/{activity-id}", activitypub.PersonOutboxActivity)
 				}, context.UserAssignmentAPI(), checkTokenPublicOnly())
 				m.Group("/user-id/{user-id}", func() {
 					m.Get("", activitypub.Person)
 					m.Post("/inbox", activitypub.ReqHTTPSignature(), activitypub.PersonInbox)
+					m.Get("/outbox", activitypub.PersonOutbox)
+					m.Get("/outbox/{activity-id}", activitypub.PersonOutboxActivity)
 				}, context.UserIDAssignmentAPI(), checkTokenPublicOnly())
 				m.Group("/actor", func() {
 					m.Get("", activitypub.Actor)
@@ -852,10 +872,8 @@ func Routes() *web.Route {
 				})
 				m.Group("/repository-id/{repository-id}", func() {
 					m.Get("", activitypub.Repository)
-					m.Post("/inbox",
-						bind(forgefed.ForgeLike{}),
-						// TODO: activitypub.ReqHTTPSignature(),
-						activitypub.RepositoryInbox)
+					m.Post("/inbox", activitypub.ReqHTTPSignature(), activitypub.RepositoryInbox)
+					m.Get("/issues/{index}", activitypub.RepositoryTicket)
 				}, context.RepositoryIDAssignmentAPI())
 			}, tokenRequiresScopes(auth_model.AccessTokenScopeCategoryActivityPub))
 		}
@@ -1076,6 +1094,9 @@ func Routes() *web.Route {
 
 			// (repo scope)
 			m.Post("/migrate", reqToken(), bind(api.MigrateRepoOptions{}), repo.Migrate)
+			if setting.Federation.Enabled {
+				m.Post("/federated-fork", reqToken(), bind(api.CreateFederatedForkOption{}), repo.CreateFederatedFork)
+			}
 
 			m.Group("/{username}/{reponame}", func() {
 				m.Get("/compare/*", reqRepoReader(unit.TypeCode), repo.CompareDiff)
@@ -1251,6 +1272,9 @@ func Routes() *web.Route {
 				}, reqAdmin(), reqToken())
 
 				m.Get("/editorconfig/{filename}", context.ReferencesGitRepo(), context.RepoRefForAPI, reqRepoReader(unit.TypeCode), repo.GetEditorconfig)
+				if setting.Federation.Enabled {
+					m.Post("/federated-pulls", reqToken(), reqRepoReader(unit.TypeCode), mustNotBeArchived, bind(api.CreateFederatedPullRequestOption{}), repo.CreateFederatedPullRequest)
+				}
 				m.Group("/pulls", func() {
 					m.Combo("").Get(repo.ListPullRequests).
 						Post(reqToken(), mustNotBeArchived, bind(api.CreatePullRequestOption{}), repo.CreatePullRequest)
@@ -1260,6 +1284,7 @@ func Routes() *web.Route {
 							Patch(reqToken(), bind(api.EditPullRequestOption{}), repo.EditPullRequest)
 						m.Get(".{diffType:diff|patch}", repo.DownloadPullDiffOrPatch)
 						m.Post("/update", reqToken(), context.EnforceQuotaAPI(quota_model.LimitSubjectSizeGitAll, context.QuotaTargetRepo), repo.UpdatePullRequest)
+						m.Post("/revert", reqToken(), reqRepoWriter(unit.TypeCode), mustNotBeArchived, context.EnforceQuotaAPI(quota_model.LimitSubjectSizeGitAll, context.QuotaTargetRepo), repo.RevertPullRequest)
 						m.Get("/commits", repo.GetPullRequestCommits)
 						m.Get("/files", repo.GetPullRequestFiles)
 						m.Combo("/merge").Get(repo.IsPullRequestMerged).
@@ -1480,6 +1505,13 @@ func Routes() *web.Route {
 				m.Get("", reqToken(), packages.GetPackage)
 				m.Delete("", reqToken(), reqPackageAccess(perm.AccessModeWrite), packages.DeletePackage)
 				m.Get("/files", reqToken(), packages.ListPackageFiles)
+				m.Get("/vulnerabilities", reqToken(), packages.ListPackageVulnerabilities)
+				m.Group("/attestations", func() {
+					m.Combo("").Get(packages.ListPackageAttestations).
+						Post(reqPackageAccess(perm.AccessModeWrite), packages.CreatePackageAttestation)
+					m.Combo("/{id}").Get(packages.GetPackageAttestation).
+						Delete(reqPackageAccess(perm.AccessModeWrite), packages.DeletePackageAttestation)
+				}, reqToken())
 			})
 			m.Get("/", reqToken(), packages.ListPackages)
 		}, tokenRequiresScopes(auth_model.AccessTokenScopeCategoryPackage), context.UserAssignmentAPI(), context.PackageAssignmentAPI(), reqPackageAccess(perm.AccessModeRead), checkTokenPublicOnly())
@@ -1496,6 +1528,7 @@ func Routes() *web.Route {
 			m.Combo("").Get(org.Get).
 				Patch(reqToken(), reqOrgOwnership(), bind(api.EditOrgOption{}), org.Edit).
 				Delete(reqToken(), reqOrgOwnership(), org.Delete)
+			m.Get("/audit-events", reqToken(), reqOrgOwnership(), org.ListAuditEvents)
 			m.Combo("/repos").Get(user.ListOrgRepos).
 				Post(reqToken(), bind(api.CreateRepoOption{}), context.EnforceQuotaAPI(quota_model.LimitSubjectSizeReposAll, context.QuotaTargetOrg), repo.CreateOrgRepo)
 			m.Group("/members", func() {
@@ -1579,6 +1612,7 @@ func Routes() *web.Route {
 		}, tokenRequiresScopes(auth_model.AccessTokenScopeCategoryOrganization), orgAssignment(false, true), reqToken(), reqTeamMembership(), checkTokenPublicOnly())
 
 		m.Group("/admin", func() {
+			m.Get("/audit-events", admin.ListAuditEvents)
 			m.Group("/cron", func() {
 				m.Get("", admin.ListCronTasks)
 				m.Post("/{task}", admin.PostCronTask)
@@ -4,15 +4,21 @@
 package packages
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"code.gitea.io/gitea/models/packages"
 	"code.gitea.io/gitea/modules/optional"
+	attestation_module "code.gitea.io/gitea/modules/packages/attestation"
 	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/routers/api/v1/utils"
 	"code.gitea.io/gitea/services/context"
 	"code.gitea.io/gitea/services/convert"
 	packages_service "code.gitea.io/gitea/services/packages"
+	packages_attestation_service "code.gitea.io/gitea/services/packages/attestation"
+	packages_vulnerability_service "code.gitea.io/gitea/services/packages/vulnerability"
 )
 
 // ListPackages gets all packages of an owner
@@ -40,7 +46,7 @@ func ListPackages(ctx *context.APIContext) {
 	//   in: query
 	//   description: package type filter
 	//   type: string
-	//   enum: [alpine, cargo, chef, composer, conan, conda, container, cran, debian, generic, go, helm, maven, npm, nuget, pub, pypi, rpm, rubygems, swift, vagrant]
+	//   enum: [alpine, cargo, chef, composer, conan, conda, container, cran, debian, generic, go, helm, hex, maven, nix, npm, nuget, pub, pypi, rpm, rubygems, swift, terraform, vagrant]
 	// - name: q
 	//   in: query
 	//   description: name filter
@@ -213,3 +219,284 @@ func ListPackageFiles(ctx *context.APIContext) {
 
 	ctx.JSON(http.StatusOK, apiPackageFiles)
 }
+
+// ListPackageVulnerabilities gets the known vulnerabilities of a package
+func ListPackageVulnerabilities(ctx *context.APIContext) {
+	// swagger:operation GET /packages/{owner}/{type}/{name}/{version}/vulnerabilities package listPackageVulnerabilities
+	// ---
+	// summary: Gets the known vulnerabilities of a package and its dependencies
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the package
+	//   type: string
+	//   required: true
+	// - name: type
+	//   in: path
+	//   description: type of the package
+	//   type: string
+	//   required: true
+	// - name: name
+	//   in: path
+	//   description: name of the package
+	//   type: string
+	//   required: true
+	// - name: version
+	//   in: path
+	//   description: version of the package
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/PackageVulnerabilityList"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+
+	pvs, err := packages_vulnerability_service.GetVulnerabilities(ctx, ctx.Package.Descriptor.Version.ID)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "GetVulnerabilities", err)
+		return
+	}
+
+	apiVulnerabilities := make([]*api.PackageVulnerability, 0, len(pvs))
+	for _, pv := range pvs {
+		apiVulnerabilities = append(apiVulnerabilities, convert.ToPackageVulnerability(pv))
+	}
+
+	ctx.JSON(http.StatusOK, apiVulnerabilities)
+}
+
+// ListPackageAttestations gets the attestations of a package
+func ListPackageAttestations(ctx *context.APIContext) {
+	// swagger:operation GET /packages/{owner}/{type}/{name}/{version}/attestations package listPackageAttestations
+	// ---
+	// summary: Gets the provenance and signature attestations of a package
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the package
+	//   type: string
+	//   required: true
+	// - name: type
+	//   in: path
+	//   description: type of the package
+	//   type: string
+	//   required: true
+	// - name: name
+	//   in: path
+	//   description: name of the package
+	//   type: string
+	//   required: true
+	// - name: version
+	//   in: path
+	//   description: version of the package
+	//   type: string
+	//   required: true
+	// responses:
+	//   "200":
+	//     "$ref": "#/responses/PackageAttestationList"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+
+	pas, err := packages.GetAttestationsByVersionID(ctx, ctx.Package.Descriptor.Version.ID)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "GetAttestationsByVersionID", err)
+		return
+	}
+
+	apiAttestations := make([]*api.PackageAttestation, 0, len(pas))
+	for _, pa := range pas {
+		apiAttestations = append(apiAttestations, convert.ToPackageAttestation(pa))
+	}
+
+	ctx.JSON(http.StatusOK, apiAttestations)
+}
+
+// CreatePackageAttestation adds an attestation to a package
+func CreatePackageAttestation(ctx *context.APIContext) {
+	// swagger:operation POST /packages/{owner}/{type}/{name}/{version}/attestations package createPackageAttestation
+	// ---
+	// summary: Adds a signed provenance attestation (Sigstore bundle) to a package
+	// consumes:
+	// - application/json
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the package
+	//   type: string
+	//   required: true
+	// - name: type
+	//   in: path
+	//   description: type of the package
+	//   type: string
+	//   required: true
+	// - name: name
+	//   in: path
+	//   description: name of the package
+	//   type: string
+	//   required: true
+	// - name: version
+	//   in: path
+	//   description: version of the package
+	//   type: string
+	//   required: true
+	// - name: body
+	//   in: body
+	//   description: Sigstore bundle containing a DSSE envelope with an in-toto statement about a file of the package
+	//   schema:
+	//     type: object
+	//   required: true
+	// responses:
+	//   "201":
+	//     "$ref": "#/responses/PackageAttestation"
+	//   "400":
+	//     "$ref": "#/responses/error"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+	//   "409":
+	//     "$ref": "#/responses/error"
+	//   "413":
+	//     "$ref": "#/responses/error"
+
+	content, err := io.ReadAll(io.LimitReader(ctx.Req.Body, attestation_module.MaxSize+1))
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "ReadAll", err)
+		return
+	}
+	if len(content) > attestation_module.MaxSize {
+		ctx.Error(http.StatusRequestEntityTooLarge, "", "attestation is too large")
+		return
+	}
+
+	pa, err := packages_attestation_service.Create(ctx, ctx.Doer, ctx.Package.Descriptor, content)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidArgument):
+			ctx.Error(http.StatusBadRequest, "", err)
+		case errors.Is(err, packages.ErrPackageAttestationExist):
+			ctx.Error(http.StatusConflict, "", err)
+		default:
+			ctx.Error(http.StatusInternalServerError, "Create", err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, convert.ToPackageAttestation(pa))
+}
+
+// GetPackageAttestation gets the content of an attestation of a package
+func GetPackageAttestation(ctx *context.APIContext) {
+	// swagger:operation GET /packages/{owner}/{type}/{name}/{version}/attestations/{id} package getPackageAttestation
+	// ---
+	// summary: Gets the content of an attestation of a package
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the package
+	//   type: string
+	//   required: true
+	// - name: type
+	//   in: path
+	//   description: type of the package
+	//   type: string
+	//   required: true
+	// - name: name
+	//   in: path
+	//   description: name of the package
+	//   type: string
+	//   required: true
+	// - name: version
+	//   in: path
+	//   description: version of the package
+	//   type: string
+	//   required: true
+	// - name: id
+	//   in: path
+	//   description: id of the attestation
+	//   type: integer
+	//   format: int64
+	//   required: true
+	// responses:
+	//   "200":
+	//     description: the in-toto statement or Sigstore bundle
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+
+	pa, err := packages.GetAttestationByID(ctx, ctx.Package.Descriptor.Version.ID, ctx.ParamsInt64("id"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.NotFound()
+		} else {
+			ctx.Error(http.StatusInternalServerError, "GetAttestationByID", err)
+		}
+		return
+	}
+
+	ctx.Resp.Header().Set("Content-Type", "application/json")
+	ctx.Resp.WriteHeader(http.StatusOK)
+	_, _ = ctx.Resp.Write([]byte(pa.Content))
+}
+
+// DeletePackageAttestation deletes an attestation of a package
+func DeletePackageAttestation(ctx *context.APIContext) {
+	// swagger:operation DELETE /packages/{owner}/{type}/{name}/{version}/attestations/{id} package deletePackageAttestation
+	// ---
+	// summary: Deletes an attestation of a package
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the package
+	//   type: string
+	//   required: true
+	// - name: type
+	//   in: path
+	//   description: type of the package
+	//   type: string
+	//   required: true
+	// - name: name
+	//   in: path
+	//   description: name of the package
+	//   type: string
+	//   required: true
+	// - name: version
+	//   in: path
+	//   description: version of the package
+	//   type: string
+	//   required: true
+	// - name: id
+	//   in: path
+	//   description: id of the attestation
+	//   type: integer
+	//   format: int64
+	//   required: true
+	// responses:
+	//   "204":
+	//     "$ref": "#/responses/empty"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+
+	pa, err := packages.GetAttestationByID(ctx, ctx.Package.Descriptor.Version.ID, ctx.ParamsInt64("id"))
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.NotFound()
+		} else {
+			ctx.Error(http.StatusInternalServerError, "GetAttestationByID", err)
+		}
+		return
+	}
+
+	if err := packages.DeleteAttestationByID(ctx, pa.ID); err != nil {
+		ctx.Error(http.StatusInternalServerError, "DeleteAttestationByID", err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/models/organization"
 	"code.gitea.io/gitea/models/perm"
 	access_model "code.gitea.io/gitea/models/perm/access"
@@ -113,28 +114,9 @@ func CreateFork(ctx *context.APIContext) {
 
 	form := web.GetForm(ctx).(*api.CreateForkOption)
 	repo := ctx.Repo.Repository
-	var forker *user_model.User // user/org that will own the fork
-	if form.Organization == nil {
-		forker = ctx.Doer
-	} else {
-		org, err := organization.GetOrgByName(ctx, *form.Organization)
-		if err != nil {
-			if organization.IsErrOrgNotExist(err) {
-				ctx.Error(http.StatusUnprocessableEntity, "", err)
-			} else {
-				ctx.Error(http.StatusInternalServerError, "GetOrgByName", err)
-			}
-			return
-		}
-		isMember, err := org.IsOrgMember(ctx, ctx.Doer.ID)
-		if err != nil {
-			ctx.Error(http.StatusInternalServerError, "IsOrgMember", err)
-			return
-		} else if !isMember {
-			ctx.Error(http.StatusForbidden, "isMemberNot", fmt.Sprintf("User is no Member of Organisation '%s'", org.Name))
-			return
-		}
-		forker = org.AsUser()
+	forker := getForker(ctx, form.Organization)
+	if ctx.Written() {
+		return
 	}
 
 	if !ctx.CheckQuota(quota_model.LimitSubjectSizeReposAll, forker.ID, forker.Name) {
@@ -165,3 +147,90 @@ func CreateFork(ctx *context.APIContext) {
 	// TODO change back to 201
 	ctx.JSON(http.StatusAccepted, convert.ToRepo(ctx, fork, access_model.Permission{AccessMode: perm.AccessModeOwner}))
 }
+
+// getForker returns the user or organization that will own a fork
+func getForker(ctx *context.APIContext, orgName *string) *user_model.User {
+	if orgName == nil {
+		return ctx.Doer
+	}
+	org, err := organization.GetOrgByName(ctx, *orgName)
+	if err != nil {
+		if organization.IsErrOrgNotExist(err) {
+			ctx.Error(http.StatusUnprocessableEntity, "", err)
+		} else {
+			ctx.Error(http.StatusInternalServerError, "GetOrgByName", err)
+		}
+		return nil
+	}
+	isMember, err := org.IsOrgMember(ctx, ctx.Doer.ID)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, "IsOrgMember", err)
+		return nil
+	} else if !isMember {
+		ctx.Error(http.StatusForbidden, "isMemberNot", fmt.Sprintf("User is no Member of Organisation '%s'", org.Name))
+		return nil
+	}
+	return org.AsUser()
+}
+
+// CreateFederatedFork forks a repository of another federated instance
+func CreateFederatedFork(ctx *context.APIContext) {
+	// swagger:operation POST /repos/federated-fork repository createFederatedFork
+	// ---
+	// summary: Fork a repository of another federated instance
+	// consumes:
+	// - application/json
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: body
+	//   in: body
+	//   schema:
+	//     "$ref": "#/definitions/CreateFederatedForkOption"
+	// responses:
+	//   "202":
+	//     "$ref": "#/responses/Repository"
+	//   "403":
+	//     "$ref": "#/responses/forbidden"
+	//   "409":
+	//     description: The repository with the same name already exists.
+	//   "413":
+	//     "$ref": "#/responses/quotaExceeded"
+	//   "422":
+	//     "$ref": "#/responses/validationError"
+
+	form := web.GetForm(ctx).(*api.CreateFederatedForkOption)
+	forker := getForker(ctx, form.Organization)
+	if ctx.Written() {
+		return
+	}
+
+	if !ctx.CheckQuota(quota_model.LimitSubjectSizeReposAll, forker.ID, forker.Name) {
+		return
+	}
+
+	var name string
+	if form.Name != nil {
+		name = *form.Name
+	}
+
+	fork, err := repo_service.ForkFederatedRepository(ctx, ctx.Doer, forker, repo_service.ForkFederatedRepoOptions{
+		URI:  form.Repository,
+		Name: name,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrAlreadyExist) || repo_model.IsErrReachLimitOfRepo(err):
+			ctx.Error(http.StatusConflict, "ForkFederatedRepository", err)
+		case errors.Is(err, util.ErrPermissionDenied):
+			ctx.Error(http.StatusForbidden, "ForkFederatedRepository", err)
+		case errors.Is(err, util.ErrInvalidArgument) || db.IsErrNameReserved(err) || db.IsErrNamePatternNotAllowed(err) || db.IsErrNameCharsNotAllowed(err):
+			ctx.Error(http.StatusUnprocessableEntity, "ForkFederatedRepository", err)
+		default:
+			ctx.Error(http.StatusInternalServerError, "ForkFederatedRepository", err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, convert.ToRepo(ctx, fork, access_model.Permission{AccessMode: perm.AccessModeOwner}))
+}
@@ -1310,6 +1310,72 @@ func UpdatePullRequest(ctx *context.APIContext) {
 	ctx.Status(http.StatusOK)
 }
 
+// RevertPullRequest create a pull request reverting the changes of a merged pull request
+func RevertPullRequest(ctx *context.APIContext) {
+	// swagger:operation POST /repos/{owner}/{repo}/pulls/{index}/revert repository repoRevertPullRequest
+	// ---
+	// summary: Create a pull request reverting the changes of a merged pull request
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the repo
+	//   type: string
+	//   required: true
+	// - name: repo
+	//   in: path
+	//   description: name of the repo
+	//   type: string
+	//   required: true
+	// - name: index
+	//   in: path
+	//   description: index of the pull request to revert
+	//   type: integer
+	//   format: int64
+	//   required: true
+	// responses:
+	//   "201":
+	//     "$ref": "#/responses/PullRequest"
+	//   "403":
+	//     "$ref": "#/responses/forbidden"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+	//   "409":
+	//     "$ref": "#/responses/error"
+	//   "413":
+	//     "$ref": "#/responses/quotaExceeded"
+	//   "422":
+	//     "$ref": "#/responses/validationError"
+
+	pr, err := issues_model.GetPullRequestByIndex(ctx, ctx.Repo.Repository.ID, ctx.ParamsInt64(":index"))
+	if err != nil {
+		if issues_model.IsErrPullRequestNotExist(err) {
+			ctx.NotFound()
+		} else {
+			ctx.Error(http.StatusInternalServerError, "GetPullRequestByIndex", err)
+		}
+		return
+	}
+
+	revertPR, err := repo_service.RevertPullRequest(ctx, ctx.Doer, pr)
+	if err != nil {
+		switch {
+		case repo_service.IsErrPullRequestNotMerged(err):
+			ctx.Error(http.StatusUnprocessableEntity, "RevertPullRequest", err)
+		case models.IsErrMergeConflicts(err):
+			ctx.Error(http.StatusConflict, "RevertPullRequest", "revert failed because of conflict")
+		case git.IsErrPushRejected(err):
+			ctx.Error(http.StatusConflict, "RevertPullRequest", "push was rejected")
+		default:
+			ctx.Error(http.StatusInternalServerError, "RevertPullRequest", err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, convert.ToAPIPullRequest(ctx, revertPR, ctx.Doer))
+}
+
 // MergePullRequest cancel an auto merge scheduled for a given PullRequest by index
 func CancelScheduledAutoMerge(ctx *context.APIContext) {
 	// swagger:operation DELETE /repos/{owner}/{repo}/pulls/{index}/merge repository repoCancelScheduledAutoMerge
@@ -0,0 +1,69 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"errors"
+	"net/http"
+
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/web"
+	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/federation"
+)
+
+// CreateFederatedPullRequest offers a pull request to the federated repository the repository was forked from
+func CreateFederatedPullRequest(ctx *context.APIContext) {
+	// swagger:operation POST /repos/{owner}/{repo}/federated-pulls repository repoCreateFederatedPullRequest
+	// ---
+	// summary: Offer a pull request to the federated repository the repository was forked from
+	// description: The pull request is delivered to the other instance in the background.
+	// consumes:
+	// - application/json
+	// produces:
+	// - application/json
+	// parameters:
+	// - name: owner
+	//   in: path
+	//   description: owner of the fork
+	//   type: string
+	//   required: true
+	// - name: repo
+	//   in: path
+	//   description: name of the fork
+	//   type: string
+	//   required: true
+	// - name: body
+	//   in: body
+	//   schema:
+	//     "$ref": "#/definitions/CreateFederatedPullRequestOption"
+	// responses:
+	//   "202":
+	//     "$ref": "#/responses/empty"
+	//   "403":
+	//     "$ref": "#/responses/forbidden"
+	//   "404":
+	//     "$ref": "#/responses/notFound"
+	//   "422":
+	//     "$ref": "#/responses/validationError"
+
+	form := web.GetForm(ctx).(*api.CreateFederatedPullRequestOption)
+
+	if _, err := federation.SendMergeRequest(ctx, ctx.Doer, ctx.Repo.Repository, form.Head, form.Base, form.Title, form.Body); err != nil {
+		switch {
+		case errors.Is(err, util.ErrNotExist):
+			ctx.NotFound(err)
+		case errors.Is(err, util.ErrPermissionDenied):
+			ctx.Error(http.StatusForbidden, "SendMergeRequest", err)
+		case errors.Is(err, util.ErrInvalidArgument):
+			ctx.Error(http.StatusUnprocessableEntity, "SendMergeRequest", err)
+		default:
+			ctx.Error(http.StatusInternalServerError, "SendMergeRequest", err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
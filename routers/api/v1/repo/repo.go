@@ -765,7 +765,7 @@ func updateBasicProperties(ctx *context.APIContext, opts api.EditRepoOption) err
 		repo.WikiBranch = *opts.WikiBranch
 	}
 
-	if err := repo_service.UpdateRepository(ctx, repo, visibilityChanged); err != nil {
+	if err := repo_service.UpdateRepositorySettings(ctx, ctx.Doer, repo, visibilityChanged); err != nil {
 		ctx.Error(http.StatusInternalServerError, "UpdateRepository", err)
 		return err
 	}
@@ -87,6 +87,8 @@ type swaggerParameterBodies struct {
 	// in:body
 	CreatePullRequestOption api.CreatePullRequestOption
 	// in:body
+	CreateFederatedPullRequestOption api.CreateFederatedPullRequestOption
+	// in:body
 	EditPullRequestOption api.EditPullRequestOption
 	// in:body
 	MergePullRequestOption forms.MergePullRequestForm
@@ -105,6 +107,8 @@ type swaggerParameterBodies struct {
 	// in:body
 	CreateForkOption api.CreateForkOption
 	// in:body
+	CreateFederatedForkOption api.CreateFederatedForkOption
+	// in:body
 	GenerateRepoOption api.GenerateRepoOption
 
 	// in:body
@@ -14,8 +14,11 @@ import (
 	auth_model "code.gitea.io/gitea/models/auth"
 	"code.gitea.io/gitea/models/db"
 	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/modules/web"
 	"code.gitea.io/gitea/routers/api/v1/utils"
+	auth_service "code.gitea.io/gitea/services/auth"
 	"code.gitea.io/gitea/services/context"
 	"code.gitea.io/gitea/services/convert"
 )
@@ -57,11 +60,10 @@ func ListAccessTokens(ctx *context.APIContext) {
 
 	apiTokens := make([]*api.AccessToken, len(tokens))
 	for i := range tokens {
-		apiTokens[i] = &api.AccessToken{
-			ID:             tokens[i].ID,
-			Name:           tokens[i].Name,
-			TokenLastEight: tokens[i].TokenLastEight,
-			Scopes:         tokens[i].Scope.StringSlice(),
+		apiTokens[i], err = convert.ToAccessToken(ctx, tokens[i])
+		if err != nil {
+			ctx.InternalServerError(err)
+			return
 		}
 	}
 
@@ -122,19 +124,32 @@ func CreateAccessToken(ctx *context.APIContext) {
 		ctx.Error(http.StatusBadRequest, "AccessTokenScope", "access token must have a scope")
 		return
 	}
-	t.Scope = scope
 
-	if err := auth_model.NewAccessToken(ctx, t); err != nil {
-		ctx.Error(http.StatusInternalServerError, "NewAccessToken", err)
+	opts := &auth_service.AccessTokenOptions{
+		Name:         form.Name,
+		Scope:        scope,
+		Organization: form.Organization,
+		Repositories: form.Repositories,
+	}
+	if form.ExpiresAt != nil {
+		opts.ExpiresUnix = timeutil.TimeStamp(form.ExpiresAt.Unix())
+	}
+	t, err = auth_service.CreateAccessToken(ctx, ctx.Doer, ctx.ContextUser, opts)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			ctx.Error(http.StatusBadRequest, "CreateAccessToken", err)
+		} else {
+			ctx.Error(http.StatusInternalServerError, "CreateAccessToken", err)
+		}
 		return
 	}
-	ctx.JSON(http.StatusCreated, &api.AccessToken{
-		Name:           t.Name,
-		Token:          t.Token,
-		ID:             t.ID,
-		TokenLastEight: t.TokenLastEight,
-		Scopes:         t.Scope.StringSlice(),
-	})
+	apiToken, err := convert.ToAccessToken(ctx, t)
+	if err != nil {
+		ctx.InternalServerError(err)
+		return
+	}
+	apiToken.Token = t.Token
+	ctx.JSON(http.StatusCreated, apiToken)
 }
 
 // DeleteAccessToken delete access tokens
@@ -194,7 +209,7 @@ func DeleteAccessToken(ctx *context.APIContext) {
 		return
 	}
 
-	if err := auth_model.DeleteAccessTokenByID(ctx, tokenID, ctx.ContextUser.ID); err != nil {
+	if err := auth_service.DeleteAccessToken(ctx, ctx.Doer, ctx.ContextUser, tokenID); err != nil {
 		if auth_model.IsErrAccessTokenNotExist(err) {
 			ctx.NotFound()
 		} else {
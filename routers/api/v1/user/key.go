@@ -210,7 +210,11 @@ func CreateUserPublicKey(ctx *context.APIContext, form api.CreateKeyOption, uid
 		return
 	}
 
-	key, err := asymkey_model.AddPublicKey(ctx, uid, form.Title, content, 0)
+	owner := ctx.Doer
+	if uid != ctx.Doer.ID {
+		owner = ctx.ContextUser
+	}
+	key, err := asymkey_service.AddPublicKey(ctx, ctx.Doer, owner, form.Title, content)
 	if err != nil {
 		repo.HandleAddKeyError(ctx, err)
 		return
@@ -29,6 +29,7 @@ import (
 	actions_router "code.gitea.io/gitea/routers/api/actions"
 	forgejo "code.gitea.io/gitea/routers/api/forgejo/v1"
 	packages_router "code.gitea.io/gitea/routers/api/packages"
+	scim_router "code.gitea.io/gitea/routers/api/scim"
 	apiv1 "code.gitea.io/gitea/routers/api/v1"
 	"code.gitea.io/gitea/routers/common"
 	"code.gitea.io/gitea/routers/private"
@@ -38,6 +39,7 @@ import (
 	"code.gitea.io/gitea/services/auth/source/oauth2"
 	"code.gitea.io/gitea/services/automerge"
 	"code.gitea.io/gitea/services/cron"
+	federation_service "code.gitea.io/gitea/services/federation"
 	feed_service "code.gitea.io/gitea/services/feed"
 	indexer_service "code.gitea.io/gitea/services/indexer"
 	"code.gitea.io/gitea/services/mailer"
@@ -45,6 +47,9 @@ import (
 	markup_service "code.gitea.io/gitea/services/markup"
 	repo_migrations "code.gitea.io/gitea/services/migrations"
 	mirror_service "code.gitea.io/gitea/services/mirror"
+	packages_attestation_service "code.gitea.io/gitea/services/packages/attestation"
+	packages_repolink_service "code.gitea.io/gitea/services/packages/repolink"
+	packages_vulnerability_service "code.gitea.io/gitea/services/packages/vulnerability"
 	pull_service "code.gitea.io/gitea/services/pull"
 	release_service "code.gitea.io/gitea/services/release"
 	repo_service "code.gitea.io/gitea/services/repository"
@@ -122,7 +127,11 @@ func InitWebInstalled(ctx context.Context) {
 	mailer.NewContext(ctx)
 	mustInit(cache.Init)
 	mustInit(feed_service.Init)
+	mustInit(federation_service.Init)
 	mustInit(uinotification.Init)
+	mustInit(packages_vulnerability_service.Init)
+	mustInit(packages_attestation_service.Init)
+	mustInit(packages_repolink_service.Init)
 	mustInitCtx(ctx, archiver.Init)
 
 	highlight.NewContext()
@@ -181,6 +190,7 @@ func NormalRoutes() *web.Route {
 	r.Mount("/api/v1", apiv1.Routes())
 	r.Mount("/api/forgejo/v1", forgejo.Routes())
 	r.Mount("/api/internal", private.Routes())
+	r.Mount("/scim/v2", scim_router.Routes())
 
 	r.Post("/-/fetch-redirect", common.FetchRedirectDelegate)
 
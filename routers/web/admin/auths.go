@@ -24,6 +24,7 @@ import (
 	"code.gitea.io/gitea/services/auth/source/ldap"
 	"code.gitea.io/gitea/services/auth/source/oauth2"
 	pam_service "code.gitea.io/gitea/services/auth/source/pam"
+	"code.gitea.io/gitea/services/auth/source/saml"
 	"code.gitea.io/gitea/services/auth/source/smtp"
 	"code.gitea.io/gitea/services/auth/source/sspi"
 	"code.gitea.io/gitea/services/context"
@@ -71,6 +72,7 @@ var (
 			{auth.SMTP.String(), auth.SMTP},
 			{auth.OAuth2.String(), auth.OAuth2},
 			{auth.SSPI.String(), auth.SSPI},
+			{auth.SAML.String(), auth.SAML},
 		}
 		if pam.Supported {
 			items = append(items, dropdownItem{auth.Names[auth.PAM], auth.PAM})
@@ -232,6 +234,28 @@ func parseSSPIConfig(ctx *context.Context, form forms.AuthenticationForm) (*sspi
 	}, nil
 }
 
+func parseSAMLConfig(ctx *context.Context, form forms.AuthenticationForm) (*saml.Source, error) {
+	config := &saml.Source{
+		IdentityProviderMetadata:    form.SAMLMetadata,
+		IdentityProviderMetadataURL: form.SAMLMetadataURL,
+		ServiceProviderEntityID:     form.SAMLServiceProviderEntityID,
+		AttributeUsername:           form.SAMLAttributeUsername,
+		AttributeEmail:              form.SAMLAttributeEmail,
+		AttributeFullName:           form.SAMLAttributeFullName,
+		GroupAttribute:              form.SAMLGroupAttribute,
+		AdminGroup:                  form.SAMLAdminGroup,
+		RestrictedGroup:             form.SAMLRestrictedGroup,
+		GroupTeamMap:                form.SAMLGroupTeamMap,
+		GroupTeamMapRemoval:         form.SAMLGroupTeamMapRemoval,
+		SkipLocalTwoFA:              form.SkipLocalTwoFA,
+	}
+	if err := config.ImportIdentityProviderMetadata(ctx); err != nil {
+		ctx.Data["Err_SAMLMetadata"] = true
+		return nil, errors.New(ctx.Locale.TrString("admin.auths.saml_invalid_metadata", err))
+	}
+	return config, nil
+}
+
 // NewAuthSourcePost response for adding an auth source
 func NewAuthSourcePost(ctx *context.Context) {
 	form := *web.GetForm(ctx).(*forms.AuthenticationForm)
@@ -291,6 +315,13 @@ func NewAuthSourcePost(ctx *context.Context) {
 			ctx.RenderWithErr(ctx.Tr("admin.auths.login_source_of_type_exist"), tplAuthNew, form)
 			return
 		}
+	case auth.SAML:
+		var err error
+		config, err = parseSAMLConfig(ctx, form)
+		if err != nil {
+			ctx.RenderWithErr(err.Error(), tplAuthNew, form)
+			return
+		}
 	default:
 		ctx.Error(http.StatusBadRequest)
 		return
@@ -345,6 +376,9 @@ func EditAuthSource(ctx *context.Context) {
 	}
 	ctx.Data["Source"] = source
 	ctx.Data["HasTLS"] = source.HasTLS()
+	if !setSCIMData(ctx, source) {
+		return
+	}
 
 	if source.IsOAuth2() {
 		type Named interface {
@@ -379,6 +413,9 @@ func EditAuthSourcePost(ctx *context.Context) {
 	}
 	ctx.Data["Source"] = source
 	ctx.Data["HasTLS"] = source.HasTLS()
+	if !setSCIMData(ctx, source) {
+		return
+	}
 
 	if ctx.HasError() {
 		ctx.HTML(http.StatusOK, tplAuthEdit)
@@ -413,6 +450,12 @@ func EditAuthSourcePost(ctx *context.Context) {
 			ctx.RenderWithErr(err.Error(), tplAuthEdit, form)
 			return
 		}
+	case auth.SAML:
+		config, err = parseSAMLConfig(ctx, form)
+		if err != nil {
+			ctx.RenderWithErr(err.Error(), tplAuthEdit, form)
+			return
+		}
 	default:
 		ctx.Error(http.StatusBadRequest)
 		return
@@ -441,6 +484,38 @@ func EditAuthSourcePost(ctx *context.Context) {
 	ctx.Redirect(setting.AppSubURL + "/admin/auths/" + strconv.FormatInt(form.ID, 10))
 }
 
+// setSCIMData sets the URL of the SCIM API and the SCIM token of the source, if it has one
+func setSCIMData(ctx *context.Context, source *auth.Source) bool {
+	ctx.Data["SCIMURL"] = setting.AppURL + "scim/v2"
+	token, err := auth.GetSCIMTokenBySourceID(ctx, source.ID)
+	if err != nil && !errors.Is(err, util.ErrNotExist) {
+		ctx.ServerError("GetSCIMTokenBySourceID", err)
+		return false
+	}
+	ctx.Data["SCIMToken"] = token
+	return true
+}
+
+// GenerateSCIMToken replaces the SCIM token of an auth source, the new token is only shown once
+func GenerateSCIMToken(ctx *context.Context) {
+	source, err := auth.GetSourceByID(ctx, ctx.ParamsInt64(":authid"))
+	if err != nil {
+		ctx.ServerError("auth.GetSourceByID", err)
+		return
+	}
+
+	token, err := auth.GenerateSCIMToken(ctx, source.ID)
+	if err != nil {
+		ctx.ServerError("GenerateSCIMToken", err)
+		return
+	}
+	log.Trace("SCIM token of authentication %d generated by admin(%s)", source.ID, ctx.Doer.Name)
+
+	ctx.Flash.Success(ctx.Tr("admin.auths.scim_token_generated"))
+	ctx.Flash.Info(token)
+	ctx.Redirect(setting.AppSubURL + "/admin/auths/" + strconv.FormatInt(source.ID, 10))
+}
+
 // DeleteAuthSource response for deleting an auth source
 func DeleteAuthSource(ctx *context.Context) {
 	source, err := auth.GetSourceByID(ctx, ctx.ParamsInt64(":authid"))
@@ -0,0 +1,116 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/forgefed"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	federation_service "code.gitea.io/gitea/services/federation"
+	user_service "code.gitea.io/gitea/services/user"
+)
+
+const (
+	tplFederation base.TplName = "admin/federation"
+)
+
+// Federation shows the federated instances and the health of the deliveries to them
+func Federation(ctx *context.Context) {
+	ctx.Data["Title"] = ctx.Tr("admin.federation")
+	ctx.Data["PageIsAdminFederation"] = true
+
+	page := ctx.FormInt("page")
+	if page <= 1 {
+		page = 1
+	}
+
+	hosts, total, err := forgefed.FindFederationHosts(ctx, db.ListOptions{
+		Page:     page,
+		PageSize: setting.UI.Admin.NoticePagingNum,
+	})
+	if err != nil {
+		ctx.ServerError("FindFederationHosts", err)
+		return
+	}
+	pending, err := forgefed.CountPendingDeliveriesByHost(ctx)
+	if err != nil {
+		ctx.ServerError("CountPendingDeliveriesByHost", err)
+		return
+	}
+
+	ctx.Data["Hosts"] = hosts
+	ctx.Data["Policies"] = forgefed.FederationHostPolicies
+	ctx.Data["AllowlistMode"] = setting.Federation.AllowlistMode
+	ctx.Data["PendingDeliveries"] = pending
+	ctx.Data["Total"] = total
+	ctx.Data["Page"] = context.NewPagination(int(total), setting.UI.Admin.NoticePagingNum, page, 5)
+
+	ctx.HTML(http.StatusOK, tplFederation)
+}
+
+// RotateInstanceKey replaces the key the instance actor signs requests with
+func RotateInstanceKey(ctx *context.Context) {
+	if err := federation_service.RotateKey(ctx, user_model.NewAPActorUser()); err != nil {
+		ctx.ServerError("RotateKey", err)
+		return
+	}
+
+	ctx.Flash.Success(ctx.Tr("admin.federation.instance_key.rotate.success"))
+	ctx.Redirect(setting.AppSubURL + "/admin/federation")
+}
+
+// SetFederationHostPolicy allows, silences or blocks a host, which may not be federated with yet
+func SetFederationHostPolicy(ctx *context.Context) {
+	policy, err := forgefed.ParseFederationHostPolicy(ctx.FormString("policy"))
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	host := ctx.FormString("host")
+	if _, err := federation_service.SetFederationHostPolicy(ctx, host, policy); err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			ctx.Flash.Error(ctx.Tr("admin.federation.host.invalid", host))
+			ctx.Redirect(setting.AppSubURL + "/admin/federation")
+			return
+		}
+		ctx.ServerError("SetFederationHostPolicy", err)
+		return
+	}
+
+	log.Trace("Federation policy of %s set to %s by admin (%s)", host, policy, ctx.Doer.Name)
+	ctx.Flash.Success(ctx.Tr("admin.federation.policy.success", host))
+	ctx.Redirect(setting.AppSubURL + "/admin/federation")
+}
+
+// PurgeFederationHost defederates from a blocked host and deletes the users and content federated from it
+func PurgeFederationHost(ctx *context.Context) {
+	host, err := forgefed.GetFederationHost(ctx, ctx.ParamsInt64(":id"))
+	if err != nil {
+		ctx.NotFound("GetFederationHost", err)
+		return
+	}
+	if !host.IsBlocked() {
+		ctx.Flash.Error(ctx.Tr("admin.federation.purge.not_blocked", host.HostFqdn))
+		ctx.Redirect(setting.AppSubURL + "/admin/federation")
+		return
+	}
+
+	if err := user_service.PurgeFederationHost(ctx, ctx.Doer, host); err != nil {
+		ctx.ServerError("PurgeFederationHost", err)
+		return
+	}
+
+	log.Trace("Federation host %s purged by admin (%s)", host.HostFqdn, ctx.Doer.Name)
+	ctx.Flash.Success(ctx.Tr("admin.federation.purge.success", host.HostFqdn))
+	ctx.Redirect(setting.AppSubURL + "/admin/federation")
+}
@@ -4,6 +4,7 @@
 package admin
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 	"time"
@@ -13,9 +14,13 @@ import (
 	"code.gitea.io/gitea/modules/base"
 	"code.gitea.io/gitea/modules/optional"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/cron"
 	packages_service "code.gitea.io/gitea/services/packages"
 	packages_cleanup_service "code.gitea.io/gitea/services/packages/cleanup"
+	container_service "code.gitea.io/gitea/services/packages/container"
+	packages_vulnerability_service "code.gitea.io/gitea/services/packages/vulnerability"
 )
 
 const (
@@ -65,6 +70,12 @@ func Packages(ctx *context.Context) {
 		return
 	}
 
+	advisoryCount, err := packages_model.CountAdvisories(ctx)
+	if err != nil {
+		ctx.ServerError("CountAdvisories", err)
+		return
+	}
+
 	ctx.Data["Title"] = ctx.Tr("packages.title")
 	ctx.Data["PageIsAdminPackages"] = true
 	ctx.Data["Query"] = query
@@ -75,6 +86,7 @@ func Packages(ctx *context.Context) {
 	ctx.Data["TotalCount"] = total
 	ctx.Data["TotalBlobSize"] = totalBlobSize - totalUnreferencedBlobSize
 	ctx.Data["TotalUnreferencedBlobSize"] = totalUnreferencedBlobSize
+	ctx.Data["AdvisoryCount"] = advisoryCount
 
 	pager := context.NewPagination(int(total), setting.UI.PackagesPagingNum, page, 5)
 	pager.AddParamString("q", query)
@@ -111,3 +123,57 @@ func CleanupExpiredData(ctx *context.Context) {
 	ctx.Flash.Success(ctx.Tr("admin.packages.cleanup.success"))
 	ctx.Redirect(setting.AppSubURL + "/admin/packages")
 }
+
+// CollectContainerGarbage removes unreachable container manifests and their blobs or reports them in a dry-run
+func CollectContainerGarbage(ctx *context.Context) {
+	olderThan := 24 * time.Hour
+	if task := cron.GetTask("cleanup_packages"); task != nil {
+		if config, ok := task.GetConfig().(*cron.OlderThanConfig); ok {
+			olderThan = config.OlderThan
+		}
+	}
+
+	dryRun := ctx.FormBool("dry_run")
+
+	result, err := container_service.CollectGarbage(ctx, 0, olderThan, dryRun)
+	if err != nil {
+		ctx.ServerError("CollectGarbage", err)
+		return
+	}
+
+	if dryRun {
+		ctx.Flash.Info(ctx.Tr("admin.packages.container_gc.dry_run_result", result.Manifests, result.Blobs, base.FileSize(result.Size)))
+	} else {
+		ctx.Flash.Success(ctx.Tr("admin.packages.container_gc.success", result.Manifests, result.Blobs, base.FileSize(result.Size)))
+	}
+	ctx.Redirect(setting.AppSubURL + "/admin/packages")
+}
+
+// ImportAdvisories imports an archive of OSV advisories and rescans the packages
+func ImportAdvisories(ctx *context.Context) {
+	file, header, err := ctx.Req.FormFile("file")
+	if err != nil {
+		ctx.Flash.Error(ctx.Tr("admin.packages.advisories.import.invalid"))
+		ctx.Redirect(setting.AppSubURL + "/admin/packages")
+		return
+	}
+	defer file.Close()
+
+	count, err := packages_vulnerability_service.ImportArchive(ctx, file, header.Size)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			ctx.Flash.Error(ctx.Tr("admin.packages.advisories.import.invalid"))
+			ctx.Redirect(setting.AppSubURL + "/admin/packages")
+			return
+		}
+		ctx.ServerError("ImportArchive", err)
+		return
+	}
+
+	if task := cron.GetTask("scan_package_vulnerabilities"); task != nil {
+		go task.RunWithUser(ctx.Doer, nil)
+	}
+
+	ctx.Flash.Success(ctx.Tr("admin.packages.advisories.import.success", count))
+	ctx.Redirect(setting.AppSubURL + "/admin/packages")
+}
@@ -447,7 +447,7 @@ func EditUserPost(ctx *context.Context) {
 		Language:                optional.Some(form.Language),
 	}
 
-	if err := user_service.UpdateUser(ctx, u, opts); err != nil {
+	if err := user_service.UpdateUser(ctx, ctx.Doer, u, opts); err != nil {
 		if models.IsErrDeleteLastAdminUser(err) {
 			ctx.RenderWithErr(ctx.Tr("auth.last_admin"), tplUserEdit, &form)
 		} else {
@@ -501,7 +501,7 @@ func DeleteUser(ctx *context.Context) {
 		return
 	}
 
-	if err = user_service.DeleteUser(ctx, u, ctx.FormBool("purge")); err != nil {
+	if err = user_service.DeleteUser(ctx, ctx.Doer, u, ctx.FormBool("purge")); err != nil {
 		switch {
 		case models.IsErrUserOwnRepos(err):
 			ctx.Flash.Error(ctx.Tr("admin.users.still_own_repo"))
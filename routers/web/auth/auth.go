@@ -30,6 +30,7 @@ import (
 	"code.gitea.io/gitea/modules/web/middleware"
 	auth_service "code.gitea.io/gitea/services/auth"
 	"code.gitea.io/gitea/services/auth/source/oauth2"
+	"code.gitea.io/gitea/services/auth/source/saml"
 	"code.gitea.io/gitea/services/context"
 	"code.gitea.io/gitea/services/externalaccount"
 	"code.gitea.io/gitea/services/forms"
@@ -122,7 +123,7 @@ func resetLocale(ctx *context.Context, u *user_model.User) error {
 		opts := &user_service.UpdateOptions{
 			Language: optional.Some(ctx.Locale.Language()),
 		}
-		if err := user_service.UpdateUser(ctx, u, opts); err != nil {
+		if err := user_service.UpdateUser(ctx, u, u, opts); err != nil {
 			return err
 		}
 	}
@@ -187,7 +188,13 @@ func SignIn(ctx *context.Context) {
 		ctx.ServerError("UserSignIn", err)
 		return
 	}
+	samlSources, err := saml.GetActiveSources(ctx)
+	if err != nil {
+		ctx.ServerError("UserSignIn", err)
+		return
+	}
 	ctx.Data["OAuth2Providers"] = oauth2Providers
+	ctx.Data["SAMLSources"] = samlSources
 	ctx.Data["Title"] = ctx.Tr("sign_in")
 	ctx.Data["SignInLink"] = setting.AppSubURL + "/user/login"
 	ctx.Data["PageIsSignIn"] = true
@@ -210,7 +217,13 @@ func SignInPost(ctx *context.Context) {
 		ctx.ServerError("UserSignIn", err)
 		return
 	}
+	samlSources, err := saml.GetActiveSources(ctx)
+	if err != nil {
+		ctx.ServerError("UserSignIn", err)
+		return
+	}
 	ctx.Data["OAuth2Providers"] = oauth2Providers
+	ctx.Data["SAMLSources"] = samlSources
 	ctx.Data["Title"] = ctx.Tr("sign_in")
 	ctx.Data["SignInLink"] = setting.AppSubURL + "/user/login"
 	ctx.Data["PageIsSignIn"] = true
@@ -260,11 +273,14 @@ func SignInPost(ctx *context.Context) {
 		return
 	}
 
-	// Now handle 2FA:
+	handleSignInWithTwoFactor(ctx, source, u, form.Remember)
+}
 
+// handleSignInWithTwoFactor signs in a user authenticated by a source, unless they must still pass a second factor
+func handleSignInWithTwoFactor(ctx *context.Context, source *auth.Source, u *user_model.User, remember bool) {
 	// First of all if the source can skip local two fa we're done
 	if skipper, ok := source.Cfg.(auth_service.LocalTwoFASkipper); ok && skipper.IsSkipLocalTwoFA() {
-		handleSignIn(ctx, u, form.Remember)
+		handleSignIn(ctx, u, remember)
 		return
 	}
 
@@ -285,14 +301,14 @@ func SignInPost(ctx *context.Context) {
 
 	if !hasTOTPtwofa && !hasWebAuthnTwofa {
 		// No two factor auth configured we can sign in the user
-		handleSignIn(ctx, u, form.Remember)
+		handleSignIn(ctx, u, remember)
 		return
 	}
 
 	updates := map[string]any{
 		// User will need to use 2FA TOTP or WebAuthn, save data
 		"twofaUid":      u.ID,
-		"twofaRemember": form.Remember,
+		"twofaRemember": remember,
 	}
 	if hasTOTPtwofa {
 		// User will need to use WebAuthn, save data
@@ -352,7 +368,7 @@ func handleSignInFull(ctx *context.Context, u *user_model.User, remember, obeyRe
 		opts := &user_service.UpdateOptions{
 			Language: optional.Some(ctx.Locale.Language()),
 		}
-		if err := user_service.UpdateUser(ctx, u, opts); err != nil {
+		if err := user_service.UpdateUser(ctx, u, u, opts); err != nil {
 			ctx.ServerError("UpdateUser Language", fmt.Errorf("Error updating user language [user: %d, locale: %s]", u.ID, ctx.Locale.Language()))
 			return setting.AppSubURL + "/"
 		}
@@ -368,7 +384,7 @@ func handleSignInFull(ctx *context.Context, u *user_model.User, remember, obeyRe
 	ctx.Csrf.DeleteCookie(ctx)
 
 	// Register last login
-	if err := user_service.UpdateUser(ctx, u, &user_service.UpdateOptions{SetLastLogin: true}); err != nil {
+	if err := user_service.UpdateUser(ctx, u, u, &user_service.UpdateOptions{SetLastLogin: true}); err != nil {
 		ctx.ServerError("UpdateUser", err)
 		return setting.AppSubURL + "/"
 	}
@@ -429,8 +445,14 @@ func SignUp(ctx *context.Context) {
 		ctx.ServerError("UserSignUp", err)
 		return
 	}
+	samlSources, err := saml.GetActiveSources(ctx)
+	if err != nil {
+		ctx.ServerError("UserSignUp", err)
+		return
+	}
 
 	ctx.Data["OAuth2Providers"] = oauth2Providers
+	ctx.Data["SAMLSources"] = samlSources
 	context.SetCaptchaData(ctx)
 
 	ctx.Data["PageIsSignUp"] = true
@@ -458,8 +480,14 @@ func SignUpPost(ctx *context.Context) {
 		ctx.ServerError("UserSignUp", err)
 		return
 	}
+	samlSources, err := saml.GetActiveSources(ctx)
+	if err != nil {
+		ctx.ServerError("UserSignUp", err)
+		return
+	}
 
 	ctx.Data["OAuth2Providers"] = oauth2Providers
+	ctx.Data["SAMLSources"] = samlSources
 	context.SetCaptchaData(ctx)
 
 	ctx.Data["PageIsSignUp"] = true
@@ -611,7 +639,7 @@ func handleUserCreated(ctx *context.Context, u *user_model.User, gothUser *goth.
 			IsAdmin:      optional.Some(true),
 			SetLastLogin: true,
 		}
-		if err := user_service.UpdateUser(ctx, u, opts); err != nil {
+		if err := user_service.UpdateUser(ctx, nil, u, opts); err != nil {
 			ctx.ServerError("UpdateUser", err)
 			return false
 		}
@@ -815,7 +843,7 @@ func handleAccountActivation(ctx *context.Context, user *user_model.User) {
 		return
 	}
 
-	if err := user_service.UpdateUser(ctx, user, &user_service.UpdateOptions{SetLastLogin: true}); err != nil {
+	if err := user_service.UpdateUser(ctx, user, user, &user_service.UpdateOptions{SetLastLogin: true}); err != nil {
 		ctx.ServerError("UpdateUser", err)
 		return
 	}
@@ -0,0 +1,275 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"code.gitea.io/gitea/models/auth"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/web"
+	"code.gitea.io/gitea/services/auth/source/oauth2"
+	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/forms"
+)
+
+const (
+	tplDeviceCode  base.TplName = "user/auth/device_code"
+	tplDeviceGrant base.TplName = "user/auth/device_grant"
+)
+
+// deviceCodeGrantType is the grant type of the device authorization grant, specified in RFC 8628 section 3.4
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceAuthorizationResponse represents a successful device authorization response, specified in RFC 8628 section 3.2
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// getDeviceClient returns the application of a client using the device authorization grant,
+// confidential clients must authenticate with their secret
+func getDeviceClient(ctx *context.Context, clientID, clientSecret string) (*auth.OAuth2Application, *AccessTokenError) {
+	app, err := auth.GetOAuth2ApplicationByClientID(ctx, clientID)
+	if err != nil {
+		return nil, &AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeInvalidClient,
+			ErrorDescription: fmt.Sprintf("cannot load client with client id: %q", clientID),
+		}
+	}
+	if app.ConfidentialClient && !app.ValidateClientSecret([]byte(clientSecret)) {
+		errorDescription := "invalid client secret"
+		if clientSecret == "" {
+			errorDescription = "invalid empty client secret"
+		}
+		return nil, &AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeInvalidClient,
+			ErrorDescription: errorDescription,
+		}
+	}
+	return app, nil
+}
+
+// DeviceAuthorizationOAuth manages the device authorization requests by the client
+func DeviceAuthorizationOAuth(ctx *context.Context) {
+	form := *web.GetForm(ctx).(*forms.DeviceAuthorizationForm)
+	if acErr := fillClientCredentialsFromBasicAuth(ctx, &form.ClientID, &form.ClientSecret); acErr != nil {
+		handleAccessTokenError(ctx, *acErr)
+		return
+	}
+
+	app, acErr := getDeviceClient(ctx, form.ClientID, form.ClientSecret)
+	if acErr != nil {
+		handleAccessTokenError(ctx, *acErr)
+		return
+	}
+
+	d, err := app.CreateDeviceAuthorization(ctx, form.Scope)
+	if err != nil {
+		log.Error("Unable to create device authorization: %v", err)
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+
+	verificationURI := setting.AppURL + "login/device"
+	ctx.JSON(http.StatusOK, &DeviceAuthorizationResponse{
+		DeviceCode:              d.DeviceCode,
+		UserCode:                d.FormattedUserCode(),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(d.FormattedUserCode()),
+		ExpiresIn:               setting.OAuth2.DeviceCodeExpirationTime,
+		Interval:                d.PollingInterval,
+	})
+}
+
+func handleDeviceCode(ctx *context.Context, form forms.AccessTokenForm, serverKey, clientKey oauth2.JWTSigningKey) {
+	app, acErr := getDeviceClient(ctx, form.ClientID, form.ClientSecret)
+	if acErr != nil {
+		handleAccessTokenError(ctx, *acErr)
+		return
+	}
+
+	d, err := auth.GetOAuth2DeviceAuthorizationByDeviceCode(ctx, form.DeviceCode)
+	if err != nil || d.ApplicationID != app.ID {
+		if err != nil && !errors.Is(err, util.ErrNotExist) {
+			log.Error("Unable to get device authorization: %v", err)
+		}
+		handleAccessTokenError(ctx, AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeInvalidGrant,
+			ErrorDescription: "invalid device code",
+		})
+		return
+	}
+
+	if d.IsExpired() {
+		handleAccessTokenError(ctx, AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeExpiredToken,
+			ErrorDescription: "the device code has expired",
+		})
+		return
+	}
+	if d.Denied {
+		if err := d.Invalidate(ctx); err != nil && !errors.Is(err, util.ErrNotExist) {
+			log.Error("Unable to invalidate device authorization: %v", err)
+		}
+		handleAccessTokenError(ctx, AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeAccessDenied,
+			ErrorDescription: "the request is denied",
+		})
+		return
+	}
+	if d.IsPending() {
+		slowDown, err := d.Poll(ctx)
+		if err != nil {
+			log.Error("Unable to update device authorization: %v", err)
+			ctx.Error(http.StatusInternalServerError)
+			return
+		}
+		if slowDown {
+			handleAccessTokenError(ctx, AccessTokenError{
+				ErrorCode:        AccessTokenErrorCodeSlowDown,
+				ErrorDescription: fmt.Sprintf("the polling interval is now %d seconds", d.PollingInterval),
+			})
+			return
+		}
+		handleAccessTokenError(ctx, AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeAuthorizationPending,
+			ErrorDescription: "the user has not yet approved the request",
+		})
+		return
+	}
+
+	grant, err := auth.GetOAuth2GrantByID(ctx, d.GrantID)
+	if err != nil || grant == nil {
+		handleAccessTokenError(ctx, AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeInvalidGrant,
+			ErrorDescription: "grant does not exist",
+		})
+		return
+	}
+	// remove the device authorization from database to deny duplicate usage
+	if err := d.Invalidate(ctx); err != nil {
+		handleAccessTokenError(ctx, AccessTokenError{
+			ErrorCode:        AccessTokenErrorCodeInvalidGrant,
+			ErrorDescription: "invalid device code",
+		})
+		return
+	}
+	resp, tokenErr := newAccessTokenResponse(ctx, grant, serverKey, clientKey)
+	if tokenErr != nil {
+		handleAccessTokenError(ctx, *tokenErr)
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// DeviceCode renders the page where the user enters the user code shown by a device
+func DeviceCode(ctx *context.Context) {
+	ctx.Data["Title"] = ctx.Tr("auth.device_title")
+	ctx.Data["user_code"] = ctx.FormString("user_code")
+	ctx.HTML(http.StatusOK, tplDeviceCode)
+}
+
+// DeviceCodePost shows the application requesting access for the user code entered by the user
+func DeviceCodePost(ctx *context.Context) {
+	form := web.GetForm(ctx).(*forms.DeviceUserCodeForm)
+	ctx.Data["Title"] = ctx.Tr("auth.device_title")
+
+	if ctx.HasError() {
+		ctx.HTML(http.StatusOK, tplDeviceCode)
+		return
+	}
+
+	d, err := auth.GetPendingOAuth2DeviceAuthorizationByUserCode(ctx, form.UserCode)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.Data["Err_UserCode"] = true
+			ctx.RenderWithErr(ctx.Tr("auth.device_code_invalid"), tplDeviceCode, form)
+			return
+		}
+		ctx.ServerError("GetPendingOAuth2DeviceAuthorizationByUserCode", err)
+		return
+	}
+
+	var creator *user_model.User
+	if d.Application.UID != 0 {
+		creator, err = user_model.GetUserByID(ctx, d.Application.UID)
+		if err != nil {
+			ctx.ServerError("GetUserByID", err)
+			return
+		}
+	}
+
+	ctx.Data["Title"] = ctx.Tr("auth.device_authorize_title", d.Application.Name)
+	ctx.Data["Application"] = d.Application
+	ctx.Data["Scope"] = d.Scope
+	ctx.Data["UserCode"] = d.FormattedUserCode()
+	if creator != nil {
+		ctx.Data["ApplicationCreatorLinkHTML"] = template.HTML(fmt.Sprintf(`<a href="%s">@%s</a>`, html.EscapeString(creator.HomeLink()), html.EscapeString(creator.Name)))
+	} else {
+		ctx.Data["ApplicationCreatorLinkHTML"] = template.HTML(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(setting.AppSubURL+"/"), html.EscapeString(setting.AppName)))
+	}
+	ctx.HTML(http.StatusOK, tplDeviceGrant)
+}
+
+// DeviceGrantPost manages the post request submitted when a user approves or denies the access of a device
+func DeviceGrantPost(ctx *context.Context) {
+	form := web.GetForm(ctx).(*forms.DeviceGrantForm)
+	if ctx.HasError() {
+		ctx.Flash.Error(ctx.GetErrMsg())
+		ctx.Redirect(setting.AppSubURL + "/login/device")
+		return
+	}
+
+	d, err := auth.GetPendingOAuth2DeviceAuthorizationByUserCode(ctx, form.UserCode)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.Flash.Error(ctx.Tr("auth.device_code_invalid"))
+			ctx.Redirect(setting.AppSubURL + "/login/device")
+			return
+		}
+		ctx.ServerError("GetPendingOAuth2DeviceAuthorizationByUserCode", err)
+		return
+	}
+
+	if !form.Granted {
+		if err := d.Deny(ctx); err != nil {
+			if errors.Is(err, util.ErrNotExist) {
+				ctx.Flash.Error(ctx.Tr("auth.device_code_invalid"))
+				ctx.Redirect(setting.AppSubURL + "/login/device")
+				return
+			}
+			ctx.ServerError("Deny", err)
+			return
+		}
+		ctx.Flash.Info(ctx.Tr("auth.device_denied", d.Application.Name))
+		ctx.Redirect(setting.AppSubURL + "/login/device")
+		return
+	}
+
+	if _, err := d.Approve(ctx, ctx.Doer.ID); err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			ctx.Flash.Error(ctx.Tr("auth.device_code_invalid"))
+			ctx.Redirect(setting.AppSubURL + "/login/device")
+			return
+		}
+		ctx.ServerError("Approve", err)
+		return
+	}
+	ctx.Flash.Success(ctx.Tr("auth.device_authorized", d.Application.Name))
+	ctx.Redirect(setting.AppSubURL + "/login/device")
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"net/http"
+
+	"code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/cache"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/validation"
+	"code.gitea.io/gitea/modules/web/middleware"
+	"code.gitea.io/gitea/services/auth/source/saml"
+	"code.gitea.io/gitea/services/context"
+)
+
+const (
+	samlRequestIDSession = "samlRequestID"
+	samlSourceIDSession  = "samlSourceID"
+	samlResponseCache    = "saml_response_"
+	// the identity provider posts its response right after the user authenticated
+	samlResponseCacheTimeout = 5 * 60
+)
+
+// getActiveSAMLSource returns the active SAML source named by the provider parameter, or renders a not found page
+func getActiveSAMLSource(ctx *context.Context) (*auth.Source, *saml.Source) {
+	source, err := auth.GetSourceByName(ctx, ctx.Params(":provider"))
+	if err != nil {
+		if auth.IsErrSourceNotExist(err) {
+			ctx.NotFound("GetSourceByName", err)
+		} else {
+			ctx.ServerError("GetSourceByName", err)
+		}
+		return nil, nil
+	}
+	samlSource, ok := source.Cfg.(*saml.Source)
+	if !ok || !source.IsActive {
+		ctx.NotFound("GetSourceByName", nil)
+		return nil, nil
+	}
+	return source, samlSource
+}
+
+// SAMLMetadata returns the metadata of Forgejo as the service provider of a SAML source
+func SAMLMetadata(ctx *context.Context) {
+	_, samlSource := getActiveSAMLSource(ctx)
+	if ctx.Written() {
+		return
+	}
+	sp, err := samlSource.ServiceProvider()
+	if err != nil {
+		ctx.ServerError("ServiceProvider", err)
+		return
+	}
+	metadata, err := sp.Metadata()
+	if err != nil {
+		ctx.ServerError("Metadata", err)
+		return
+	}
+	ctx.Resp.Header().Set("Content-Type", "application/samlmetadata+xml")
+	ctx.Resp.WriteHeader(http.StatusOK)
+	_, _ = ctx.Resp.Write(metadata)
+}
+
+// SignInSAML redirects the user to the identity provider of a SAML source
+func SignInSAML(ctx *context.Context) {
+	source, samlSource := getActiveSAMLSource(ctx)
+	if ctx.Written() {
+		return
+	}
+
+	redirectTo := ctx.FormString("redirect_to")
+	if len(redirectTo) > 0 {
+		middleware.SetRedirectToCookie(ctx.Resp, redirectTo)
+	}
+
+	sp, err := samlSource.ServiceProvider()
+	if err != nil {
+		ctx.ServerError("ServiceProvider", err)
+		return
+	}
+	location, requestID, err := sp.AuthnRequestURL("")
+	if err != nil {
+		ctx.ServerError("AuthnRequestURL", err)
+		return
+	}
+	if err := ctx.Session.Set(samlRequestIDSession, requestID); err != nil {
+		ctx.ServerError("Session.Set", err)
+		return
+	}
+	if err := ctx.Session.Set(samlSourceIDSession, source.ID); err != nil {
+		ctx.ServerError("Session.Set", err)
+		return
+	}
+	// the identity provider is another site, ctx.Redirect would drop a newly created session
+	http.Redirect(ctx.Resp, ctx.Req, location, http.StatusFound)
+}
+
+// SignInSAMLPost receives the response of the identity provider. It comes from another site, without the session
+// cookie, so the response is kept aside and the browser is sent back with a same-site request to consume it.
+func SignInSAMLPost(ctx *context.Context) {
+	source, _ := getActiveSAMLSource(ctx)
+	if ctx.Written() {
+		return
+	}
+	response := ctx.Req.PostFormValue("SAMLResponse")
+	if response == "" {
+		ctx.Error(http.StatusBadRequest, "missing SAMLResponse")
+		return
+	}
+	key, err := util.CryptoRandomString(40)
+	if err != nil {
+		ctx.ServerError("CryptoRandomString", err)
+		return
+	}
+	if err := cache.GetCache().Put(samlResponseCache+key, response, samlResponseCacheTimeout); err != nil {
+		ctx.ServerError("Cache.Put", err)
+		return
+	}
+	ctx.Redirect(saml.AcsURL(source.Name)+"?key="+key, http.StatusSeeOther)
+}
+
+// SignInSAMLCallback validates the response of the identity provider and signs the user in
+func SignInSAMLCallback(ctx *context.Context) {
+	source, samlSource := getActiveSAMLSource(ctx)
+	if ctx.Written() {
+		return
+	}
+
+	key := samlResponseCache + ctx.FormString("key")
+	response, _ := cache.GetCache().Get(key).(string)
+	if response != "" {
+		if err := cache.GetCache().Delete(key); err != nil {
+			ctx.ServerError("Cache.Delete", err)
+			return
+		}
+	}
+
+	// the request can only be answered once
+	requestID, _ := ctx.Session.Get(samlRequestIDSession).(string)
+	sourceID, _ := ctx.Session.Get(samlSourceIDSession).(int64)
+	_ = ctx.Session.Delete(samlRequestIDSession)
+	_ = ctx.Session.Delete(samlSourceIDSession)
+	if sourceID != source.ID {
+		requestID = ""
+	}
+
+	sp, err := samlSource.ServiceProvider()
+	if err != nil {
+		ctx.ServerError("ServiceProvider", err)
+		return
+	}
+	assertion, err := sp.ParseResponse(response, requestID)
+	if err != nil {
+		log.Info("Failed SAML authentication from %s with %s: %v", ctx.RemoteAddr(), source.Name, err)
+		ctx.Flash.Error(ctx.Tr("auth.saml.signin.error"))
+		ctx.Redirect(setting.AppSubURL + "/user/login")
+		return
+	}
+
+	u, err := samlSource.SignIn(ctx, assertion)
+	if err != nil {
+		switch {
+		case user_model.IsErrUserProhibitLogin(err), user_model.IsErrUserInactive(err):
+			log.Info("Failed authentication attempt for %s from %s: %v", assertion.NameID, ctx.RemoteAddr(), err)
+			ctx.Data["Title"] = ctx.Tr("auth.prohibit_login")
+			ctx.HTML(http.StatusOK, "user/auth/prohibit_login")
+		case user_model.IsErrUserNotExist(err):
+			ctx.Flash.Error(ctx.Tr("auth.saml.signin.not_registered"))
+			ctx.Redirect(setting.AppSubURL + "/user/login")
+		case user_model.IsErrUserAlreadyExist(err), user_model.IsErrEmailAlreadyUsed(err),
+			db.IsErrNameReserved(err), db.IsErrNamePatternNotAllowed(err), db.IsErrNameCharsNotAllowed(err),
+			validation.IsErrEmailCharIsNotSupported(err), validation.IsErrEmailInvalid(err):
+			log.Info("Unable to create the user %s authenticated by %s: %v", assertion.NameID, source.Name, err)
+			ctx.Flash.Error(ctx.Tr("auth.saml.signin.cannot_create_user"))
+			ctx.Redirect(setting.AppSubURL + "/user/login")
+		default:
+			ctx.ServerError("SignIn", err)
+		}
+		return
+	}
+
+	handleSignInWithTwoFactor(ctx, source, u, false)
+}
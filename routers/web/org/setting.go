@@ -115,7 +115,7 @@ func SettingsPost(ctx *context.Context) {
 
 	visibilityChanged := org.Visibility != form.Visibility
 
-	if err := user_service.UpdateUser(ctx, org.AsUser(), opts); err != nil {
+	if err := user_service.UpdateUser(ctx, ctx.Doer, org.AsUser(), opts); err != nil {
 		ctx.ServerError("UpdateUser", err)
 		return
 	}
@@ -178,7 +178,7 @@ func SettingsDelete(ctx *context.Context) {
 			return
 		}
 
-		if err := org_service.DeleteOrganization(ctx, ctx.Org.Organization, false); err != nil {
+		if err := org_service.DeleteOrganization(ctx, ctx.Doer, ctx.Org.Organization, false); err != nil {
 			if models.IsErrUserOwnRepos(err) {
 				ctx.Flash.Error(ctx.Tr("form.org_still_own_repo"))
 				ctx.Redirect(ctx.Org.OrgLink + "/settings/delete")
@@ -2007,6 +2007,14 @@ func ViewIssue(ctx *context.Context) {
 			ctx.ServerError("GetScheduledMergeByPullID", err)
 			return
 		}
+
+		if !pull.HasMerged && !issue.IsClosed {
+			ctx.Data["PullRequestStack"], err = pull_service.GetPullRequestStack(ctx, pull)
+			if err != nil {
+				ctx.ServerError("GetPullRequestStack", err)
+				return
+			}
+		}
 	}
 
 	// Get Dependencies
@@ -1686,7 +1686,7 @@ func CleanUpPullRequest(ctx *context.Context) {
 func deleteBranch(ctx *context.Context, pr *issues_model.PullRequest, gitRepo *git.Repository) {
 	fullBranchName := pr.HeadRepo.FullName() + ":" + pr.HeadBranch
 
-	if err := pull_service.RetargetChildrenOnMerge(ctx, ctx.Doer, pr); err != nil {
+	if err := pull_service.RetargetChildrenOnMerge(ctx, ctx.Doer, pr, ""); err != nil {
 		ctx.Flash.Error(ctx.Tr("repo.branch.deletion_failed", fullBranchName))
 		return
 	}
@@ -1842,3 +1842,35 @@ func SetAllowEdits(ctx *context.Context) {
 		"allow_maintainer_edit": pr.AllowMaintainerEdit,
 	})
 }
+
+// RevertPullRequest opens a pull request reverting the changes of a merged pull request
+func RevertPullRequest(ctx *context.Context) {
+	issue, ok := getPullInfo(ctx)
+	if !ok {
+		return
+	}
+	if !issue.PullRequest.HasMerged {
+		ctx.NotFound("RevertPullRequest", nil)
+		return
+	}
+
+	revertPR, err := repo_service.RevertPullRequest(ctx, ctx.Doer, issue.PullRequest)
+	if err != nil {
+		switch {
+		case models.IsErrMergeConflicts(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.revert_conflicts"))
+		case repo_service.IsErrPullRequestNotMerged(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.revert_not_merged"))
+		case git.IsErrPushRejected(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.push_rejected_no_message"))
+		default:
+			ctx.ServerError("RevertPullRequest", err)
+			return
+		}
+		ctx.Redirect(issue.Link())
+		return
+	}
+
+	ctx.Flash.Success(ctx.Tr("repo.pulls.revert_created"))
+	ctx.Redirect(revertPR.Issue.Link())
+}
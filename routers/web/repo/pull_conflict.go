@@ -0,0 +1,185 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repo
+
+import (
+	"fmt"
+	"net/http"
+
+	"code.gitea.io/gitea/models"
+	issues_model "code.gitea.io/gitea/models/issues"
+	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/web"
+	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/forms"
+	pull_service "code.gitea.io/gitea/services/pull"
+)
+
+const tplPullConflicts base.TplName = "repo/pulls/conflicts"
+
+// Resolution modes of a conflicted file in the conflict editor
+const (
+	conflictModeHunks  = "hunks"
+	conflictModeEdit   = "edit"
+	conflictModeOurs   = "ours"
+	conflictModeTheirs = "theirs"
+)
+
+// getPullForConflicts loads the pull request and checks that the doer may resolve its conflicts
+func getPullForConflicts(ctx *context.Context) *issues_model.Issue {
+	issue, ok := getPullInfo(ctx)
+	if !ok {
+		return nil
+	}
+	if issue.IsClosed || issue.PullRequest.HasMerged || issue.PullRequest.Flow == issues_model.PullRequestFlowAGit {
+		ctx.NotFound("ResolvePullConflicts", nil)
+		return nil
+	}
+	if err := issue.PullRequest.LoadBaseRepo(ctx); err != nil {
+		ctx.ServerError("LoadBaseRepo", err)
+		return nil
+	}
+
+	// resolving the conflicts updates the head branch by merging the base branch into it
+	allowedUpdateByMerge, _, err := pull_service.IsUserAllowedToUpdate(ctx, issue.PullRequest, ctx.Doer)
+	if err != nil {
+		ctx.ServerError("IsUserAllowedToUpdate", err)
+		return nil
+	}
+	if !allowedUpdateByMerge {
+		ctx.Flash.Error(ctx.Tr("repo.pulls.update_not_allowed"))
+		ctx.Redirect(issue.Link())
+		return nil
+	}
+	return issue
+}
+
+func defaultConflictResolutionMessage(pr *issues_model.PullRequest) string {
+	return fmt.Sprintf("Merge branch '%s' into %s", pr.BaseBranch, pr.HeadBranch)
+}
+
+// ViewPullConflicts renders the editor to resolve the conflicts of a pull request
+func ViewPullConflicts(ctx *context.Context) {
+	ctx.Data["PageIsPullList"] = true
+	ctx.Data["PageIsPullConflicts"] = true
+
+	issue := getPullForConflicts(ctx)
+	if ctx.Written() {
+		return
+	}
+	pull := issue.PullRequest
+
+	if prInfo := PrepareViewPullInfo(ctx, issue); ctx.Written() {
+		return
+	} else if prInfo == nil {
+		ctx.NotFound("ViewPullConflicts", nil)
+		return
+	}
+
+	conflicts, err := pull_service.GetConflicts(ctx, pull, ctx.Doer)
+	if err != nil {
+		ctx.ServerError("GetConflicts", err)
+		return
+	}
+
+	ctx.Data["Conflicts"] = conflicts
+	ctx.Data["ConflictsMessage"] = defaultConflictResolutionMessage(pull)
+	ctx.Data["HasIssuesOrPullsWritePermission"] = ctx.Repo.CanWriteIssuesOrPulls(issue.IsPull)
+	ctx.Data["IsIssuePoster"] = ctx.IsSigned && issue.IsPoster(ctx.Doer.ID)
+
+	ctx.HTML(http.StatusOK, tplPullConflicts)
+}
+
+// ResolvePullConflicts commits the resolution of the conflicts of a pull request to its head branch
+func ResolvePullConflicts(ctx *context.Context) {
+	form := web.GetForm(ctx).(*forms.ResolvePullConflictsForm)
+
+	issue := getPullForConflicts(ctx)
+	if ctx.Written() {
+		return
+	}
+	pull := issue.PullRequest
+	conflictsLink := issue.Link() + "/conflicts"
+
+	conflicts, err := pull_service.GetConflicts(ctx, pull, ctx.Doer)
+	if err != nil {
+		ctx.ServerError("GetConflicts", err)
+		return
+	}
+	if conflicts.HeadCommitID != form.HeadCommitID || conflicts.BaseCommitID != form.BaseCommitID {
+		ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.outdated"))
+		ctx.Redirect(conflictsLink)
+		return
+	}
+
+	resolutions := make([]*pull_service.ConflictResolution, 0, len(conflicts.Files))
+	for i := range conflicts.Files {
+		path := ctx.FormString(fmt.Sprintf("path_%d", i))
+		file := conflicts.GetFile(path)
+		if file == nil {
+			ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.outdated"))
+			ctx.Redirect(conflictsLink)
+			return
+		}
+
+		resolution := &pull_service.ConflictResolution{Path: file.Path}
+		switch mode := ctx.FormString(fmt.Sprintf("mode_%d", i)); mode {
+		case conflictModeOurs:
+			resolution.Side = pull_service.ConflictSideOurs
+		case conflictModeTheirs:
+			resolution.Side = pull_service.ConflictSideTheirs
+		case conflictModeEdit:
+			resolution.Content = ctx.FormString(fmt.Sprintf("content_%d", i))
+			if pull_service.HasConflictMarkers(resolution.Content) {
+				ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.markers_left", file.Path))
+				ctx.Redirect(conflictsLink)
+				return
+			}
+		case conflictModeHunks:
+			choices := make([]pull_service.ConflictSide, 0, file.NumConflicts())
+			for j := 0; j < file.NumConflicts(); j++ {
+				choices = append(choices, pull_service.ConflictSide(ctx.FormString(fmt.Sprintf("hunk_%d_%d", i, j))))
+			}
+			if resolution.Content, err = file.Resolve(choices); err != nil {
+				ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.unresolved", file.Path))
+				ctx.Redirect(conflictsLink)
+				return
+			}
+		default:
+			ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.unresolved", file.Path))
+			ctx.Redirect(conflictsLink)
+			return
+		}
+		resolutions = append(resolutions, resolution)
+	}
+
+	message := form.Message
+	if message == "" {
+		message = defaultConflictResolutionMessage(pull)
+	}
+
+	if err := pull_service.ResolveConflicts(ctx, pull, ctx.Doer, form.HeadCommitID, form.BaseCommitID, resolutions, message); err != nil {
+		switch {
+		case models.IsErrSHADoesNotMatch(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.outdated"))
+			ctx.Redirect(conflictsLink)
+		case pull_service.IsErrUnresolvedConflicts(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.unresolved", err.(pull_service.ErrUnresolvedConflicts).Paths[0]))
+			ctx.Redirect(conflictsLink)
+		case git.IsErrPushOutOfDate(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.conflicts.outdated"))
+			ctx.Redirect(conflictsLink)
+		case git.IsErrPushRejected(err):
+			ctx.Flash.Error(ctx.Tr("repo.pulls.push_rejected_no_message"))
+			ctx.Redirect(conflictsLink)
+		default:
+			ctx.ServerError("ResolveConflicts", err)
+		}
+		return
+	}
+
+	ctx.Flash.Success(ctx.Tr("repo.pulls.conflicts.resolved"))
+	ctx.Redirect(issue.Link())
+}
@@ -14,6 +14,7 @@ import (
 	"code.gitea.io/gitea/models/asymkey"
 	"code.gitea.io/gitea/models/db"
 	git_model "code.gitea.io/gitea/models/git"
+	packages_model "code.gitea.io/gitea/models/packages"
 	repo_model "code.gitea.io/gitea/models/repo"
 	"code.gitea.io/gitea/models/unit"
 	user_model "code.gitea.io/gitea/models/user"
@@ -76,6 +77,39 @@ type ReleaseInfo struct {
 	Release        *repo_model.Release
 	CommitStatus   *git_model.CommitStatus
 	CommitStatuses []*git_model.CommitStatus
+	Packages       []*packages_model.PackageDescriptor
+}
+
+// releasePackageVersions returns the package versions matching the tag of a release, with and without a "v" prefix
+func releasePackageVersions(r *repo_model.Release) []string {
+	tag := strings.ToLower(r.TagName)
+	if trimmed := strings.TrimPrefix(tag, "v"); trimmed != tag && trimmed != "" {
+		return []string{tag, trimmed}
+	}
+	return []string{tag}
+}
+
+// getReleasePackages gets the versions of packages linked to the repository which were published for the releases
+func getReleasePackages(ctx *context.Context, releases []*repo_model.Release) (map[string][]*packages_model.PackageDescriptor, error) {
+	versions := make([]string, 0, len(releases))
+	for _, r := range releases {
+		versions = append(versions, releasePackageVersions(r)...)
+	}
+
+	pvs, err := packages_model.GetRepositoryVersionsByVersions(ctx, ctx.Repo.Repository.ID, versions)
+	if err != nil {
+		return nil, err
+	}
+	pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]*packages_model.PackageDescriptor, len(pds))
+	for _, pd := range pds {
+		result[pd.Version.LowerVersion] = append(result[pd.Version.LowerVersion], pd)
+	}
+	return result, nil
 }
 
 func getReleaseInfos(ctx *context.Context, opts *repo_model.FindReleasesOptions) ([]*ReleaseInfo, error) {
@@ -102,6 +136,14 @@ func getReleaseInfos(ctx *context.Context, opts *repo_model.FindReleasesOptions)
 
 	canReadActions := ctx.Repo.CanRead(unit.TypeActions)
 
+	var releasePackages map[string][]*packages_model.PackageDescriptor
+	if setting.Packages.Enabled && ctx.Repo.CanRead(unit.TypePackages) {
+		releasePackages, err = getReleasePackages(ctx, releases)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	releaseInfos := make([]*ReleaseInfo, 0, len(releases))
 	for _, r := range releases {
 		if r.Publisher, ok = cacheUsers[r.PublisherID]; !ok {
@@ -142,6 +184,9 @@ func getReleaseInfos(ctx *context.Context, opts *repo_model.FindReleasesOptions)
 		info := &ReleaseInfo{
 			Release: r,
 		}
+		for _, v := range releasePackageVersions(r) {
+			info.Packages = append(info.Packages, releasePackages[v]...)
+		}
 
 		if canReadActions {
 			statuses, _, err := git_model.GetLatestCommitStatus(ctx, r.Repo.ID, r.Sha1, db.ListOptionsAll)
@@ -0,0 +1,74 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package setting
+
+import (
+	"errors"
+	"net/http"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/base"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/services/context"
+	repo_service "code.gitea.io/gitea/services/repository"
+)
+
+const tplMaintenance base.TplName = "repo/settings/maintenance"
+
+// Maintenance shows the git maintenance state of the repository
+func Maintenance(ctx *context.Context) {
+	ctx.Data["Title"] = ctx.Tr("repo.settings.maintenance")
+	ctx.Data["PageIsSettingsMaintenance"] = true
+
+	m, err := repo_model.GetRepoMaintenance(ctx, ctx.Repo.Repository.ID)
+	if err != nil {
+		ctx.ServerError("GetRepoMaintenance", err)
+		return
+	}
+	allowed := make(map[string]bool)
+	for _, filter := range m.GetAllowedFilters() {
+		allowed[filter] = true
+	}
+
+	status, err := repo_service.GetRepoMaintenanceStatus(ctx.Repo.Repository)
+	if err != nil {
+		ctx.ServerError("GetRepoMaintenanceStatus", err)
+		return
+	}
+
+	ctx.Data["Maintenance"] = m
+	ctx.Data["MaintenanceStatus"] = status
+	ctx.Data["UploadPackFilters"] = git.UploadPackFilters
+	ctx.Data["AllowedFilters"] = allowed
+	ctx.Data["PartialCloneDisabled"] = setting.Git.DisablePartialClone
+	ctx.HTML(http.StatusOK, tplMaintenance)
+}
+
+// MaintenanceFiltersPost restricts the object filters allowed for partial clones of the repository
+func MaintenanceFiltersPost(ctx *context.Context) {
+	if err := repo_service.SetRepoAllowedFilters(ctx, ctx.Repo.Repository, ctx.FormStrings("filters")); err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			ctx.Flash.Error(ctx.Tr("repo.settings.maintenance.filters_invalid"))
+			ctx.Redirect(ctx.Repo.RepoLink + "/settings/maintenance")
+			return
+		}
+		ctx.ServerError("SetRepoAllowedFilters", err)
+		return
+	}
+
+	ctx.Flash.Success(ctx.Tr("repo.settings.update_settings_success"))
+	ctx.Redirect(ctx.Repo.RepoLink + "/settings/maintenance")
+}
+
+// MaintenanceRunPost queues the maintenance tasks which can improve the repository
+func MaintenanceRunPost(ctx *context.Context) {
+	if err := repo_service.QueueRepoMaintenance(ctx.Repo.Repository); err != nil {
+		ctx.ServerError("QueueRepoMaintenance", err)
+		return
+	}
+	ctx.Flash.Info(ctx.Tr("repo.settings.maintenance.run_queued"))
+	ctx.Redirect(ctx.Repo.RepoLink + "/settings/maintenance")
+}
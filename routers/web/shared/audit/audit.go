@@ -0,0 +1,76 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package audit
+
+import (
+	"time"
+
+	audit_model "code.gitea.io/gitea/models/audit"
+	"code.gitea.io/gitea/models/db"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/services/context"
+)
+
+// SetAuditEventsContext loads a page of the audit events of an owner, or of the whole instance if ownerID is 0.
+// The events are filtered by the action, actor, since and until query parameters, the dates are inclusive.
+func SetAuditEventsContext(ctx *context.Context, ownerID int64) {
+	page := ctx.FormInt("page")
+	if page <= 1 {
+		page = 1
+	}
+	opts := &audit_model.FindEventsOptions{
+		ListOptions: db.ListOptions{Page: page, PageSize: setting.UI.Admin.NoticePagingNum},
+		Action:      audit_model.Action(ctx.FormTrim("action")),
+		OwnerID:     ownerID,
+	}
+	if !opts.Action.IsValid() {
+		opts.Action = ""
+	}
+
+	if since, err := time.ParseInLocation("2006-01-02", ctx.FormTrim("since"), setting.DefaultUILocation); err == nil {
+		opts.Since = timeutil.TimeStamp(since.Unix())
+	}
+	if until, err := time.ParseInLocation("2006-01-02", ctx.FormTrim("until"), setting.DefaultUILocation); err == nil {
+		opts.Before = timeutil.TimeStamp(until.AddDate(0, 0, 1).Unix())
+	}
+
+	var events []*audit_model.Event
+	var total int64
+	actor := ctx.FormTrim("actor")
+	if actor != "" {
+		u, err := user_model.GetUserByName(ctx, actor)
+		if err != nil && !user_model.IsErrUserNotExist(err) {
+			ctx.ServerError("GetUserByName", err)
+			return
+		}
+		if u != nil {
+			opts.ActorID = u.ID
+		}
+	}
+	// an unknown actor has no events
+	if actor == "" || opts.ActorID != 0 {
+		var err error
+		events, total, err = db.FindAndCount[audit_model.Event](ctx, opts)
+		if err != nil {
+			ctx.ServerError("FindAuditEvents", err)
+			return
+		}
+	}
+	ctx.Data["Events"] = events
+	ctx.Data["Total"] = total
+	ctx.Data["Actions"] = audit_model.Actions
+	ctx.Data["Action"] = string(opts.Action)
+	ctx.Data["Actor"] = actor
+	ctx.Data["Since"] = ctx.FormTrim("since")
+	ctx.Data["Until"] = ctx.FormTrim("until")
+
+	pager := context.NewPagination(int(total), opts.PageSize, page, 5)
+	pager.AddParamString("action", string(opts.Action))
+	pager.AddParamString("actor", actor)
+	pager.AddParamString("since", ctx.FormTrim("since"))
+	pager.AddParamString("until", ctx.FormTrim("until"))
+	ctx.Data["Page"] = pager
+}
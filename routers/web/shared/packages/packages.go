@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"code.gitea.io/gitea/models/db"
@@ -22,6 +23,7 @@ import (
 	"code.gitea.io/gitea/services/forms"
 	cargo_service "code.gitea.io/gitea/services/packages/cargo"
 	container_service "code.gitea.io/gitea/services/packages/container"
+	proxy_service "code.gitea.io/gitea/services/packages/proxy"
 )
 
 func SetPackagesContext(ctx *context.Context, owner *user_model.User) {
@@ -33,6 +35,14 @@ func SetPackagesContext(ctx *context.Context, owner *user_model.User) {
 
 	ctx.Data["CleanupRules"] = pcrs
 
+	pps, err := packages_model.GetProxiesByOwner(ctx, owner.ID)
+	if err != nil {
+		ctx.ServerError("GetProxiesByOwner", err)
+		return
+	}
+
+	ctx.Data["PackageProxies"] = pps
+
 	ctx.Data["CargoIndexExists"], err = repo_model.IsRepositoryModelExist(ctx, owner, cargo_service.IndexRepositoryName)
 	if err != nil {
 		ctx.ServerError("IsRepositoryModelExist", err)
@@ -235,6 +245,145 @@ func getCleanupRuleByContext(ctx *context.Context, owner *user_model.User) *pack
 	return nil
 }
 
+func SetProxyAddContext(ctx *context.Context) {
+	setProxyEditContext(ctx, nil)
+}
+
+func SetProxyEditContext(ctx *context.Context, owner *user_model.User) {
+	pp := getProxyByContext(ctx, owner)
+	if pp == nil {
+		return
+	}
+
+	setProxyEditContext(ctx, pp)
+}
+
+func setProxyEditContext(ctx *context.Context, pp *packages_model.PackageProxy) {
+	ctx.Data["IsEditProxy"] = pp != nil
+
+	if pp == nil {
+		pp = &packages_model.PackageProxy{Enabled: true}
+	}
+	ctx.Data["PackageProxy"] = pp
+	ctx.Data["AvailableTypes"] = packages_model.ProxyTypeList
+}
+
+func PerformProxyAddPost(ctx *context.Context, owner *user_model.User, redirectURL string, template base.TplName) {
+	performProxyEditPost(ctx, owner, nil, redirectURL, template)
+}
+
+func PerformProxyEditPost(ctx *context.Context, owner *user_model.User, redirectURL string, template base.TplName) {
+	pp := getProxyByContext(ctx, owner)
+	if pp == nil {
+		return
+	}
+
+	form := web.GetForm(ctx).(*forms.PackageProxyForm)
+
+	if form.Action == "remove" {
+		if err := packages_model.DeleteProxyByID(ctx, pp.ID); err != nil {
+			ctx.ServerError("DeleteProxyByID", err)
+			return
+		}
+
+		ctx.Flash.Success(ctx.Tr("packages.owner.settings.proxies.success.delete"))
+		ctx.Redirect(redirectURL)
+	} else {
+		performProxyEditPost(ctx, owner, pp, redirectURL, template)
+	}
+}
+
+func performProxyEditPost(ctx *context.Context, owner *user_model.User, pp *packages_model.PackageProxy, redirectURL string, template base.TplName) {
+	isEditProxy := pp != nil
+
+	if pp == nil {
+		pp = &packages_model.PackageProxy{}
+	}
+
+	form := web.GetForm(ctx).(*forms.PackageProxyForm)
+
+	pp.Enabled = form.Enabled
+	pp.OwnerID = owner.ID
+	pp.URL = strings.TrimSuffix(strings.TrimSpace(form.URL), "/")
+	pp.Username = form.Username
+	if !isEditProxy {
+		pp.Type = packages_model.Type(form.Type)
+	}
+
+	ctx.Data["IsEditProxy"] = isEditProxy
+	ctx.Data["PackageProxy"] = pp
+	ctx.Data["AvailableTypes"] = packages_model.ProxyTypeList
+
+	if ctx.HasError() {
+		ctx.HTML(http.StatusOK, template)
+		return
+	}
+
+	if err := proxy_service.ValidateUpstreamURL(pp.URL); err != nil {
+		ctx.Data["Err_URL"] = true
+		ctx.RenderWithErr(ctx.Tr("packages.owner.settings.proxies.url.invalid"), template, form)
+		return
+	}
+
+	// An empty password keeps the stored one, unless the username was removed
+	if form.Password != "" || pp.Username == "" {
+		if err := pp.SetPassword(form.Password); err != nil {
+			ctx.ServerError("SetPassword", err)
+			return
+		}
+	}
+
+	if isEditProxy {
+		if err := packages_model.UpdateProxy(ctx, pp); err != nil {
+			ctx.ServerError("UpdateProxy", err)
+			return
+		}
+	} else {
+		if has, err := packages_model.HasOwnerProxyForPackageType(ctx, owner.ID, pp.Type); err != nil {
+			ctx.ServerError("HasOwnerProxyForPackageType", err)
+			return
+		} else if has {
+			ctx.Data["Err_Type"] = true
+			ctx.RenderWithErr(ctx.Tr("packages.owner.settings.proxies.type.exists"), template, form)
+			return
+		}
+
+		var err error
+		if pp, err = packages_model.InsertProxy(ctx, pp); err != nil {
+			ctx.ServerError("InsertProxy", err)
+			return
+		}
+	}
+
+	ctx.Flash.Success(ctx.Tr("packages.owner.settings.proxies.success.update"))
+	ctx.Redirect(fmt.Sprintf("%s/proxies/%d", redirectURL, pp.ID))
+}
+
+func getProxyByContext(ctx *context.Context, owner *user_model.User) *packages_model.PackageProxy {
+	id := ctx.FormInt64("id")
+	if id == 0 {
+		id = ctx.ParamsInt64("id")
+	}
+
+	pp, err := packages_model.GetProxyByID(ctx, id)
+	if err != nil {
+		if err == packages_model.ErrPackageProxyNotExist {
+			ctx.NotFound("", err)
+		} else {
+			ctx.ServerError("GetProxyByID", err)
+		}
+		return nil
+	}
+
+	if pp.OwnerID == owner.ID {
+		return pp
+	}
+
+	ctx.NotFound("", fmt.Errorf("PackageProxy[%v] not associated to owner %v", id, owner))
+
+	return nil
+}
+
 func InitializeCargoIndex(ctx *context.Context, owner *user_model.User) {
 	err := cargo_service.InitializeIndexRepository(ctx, owner, owner)
 	if err != nil {
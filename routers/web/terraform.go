@@ -0,0 +1,25 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"net/http"
+
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/services/context"
+)
+
+type terraformServices struct {
+	ModulesV1   string `json:"modules.v1"`
+	ProvidersV1 string `json:"providers.v1"`
+}
+
+// TerraformServiceDiscovery returns the locations of the Terraform module and provider registries
+// https://developer.hashicorp.com/terraform/internals/remote-service-discovery
+func TerraformServiceDiscovery(ctx *context.Context) {
+	ctx.JSON(http.StatusOK, &terraformServices{
+		ModulesV1:   setting.AppSubURL + "/api/packages/-/terraform/modules/v1/",
+		ProvidersV1: setting.AppSubURL + "/api/packages/-/terraform/providers/v1/",
+	})
+}
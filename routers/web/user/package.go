@@ -20,6 +20,7 @@ import (
 	"code.gitea.io/gitea/modules/optional"
 	alpine_module "code.gitea.io/gitea/modules/packages/alpine"
 	arch_model "code.gitea.io/gitea/modules/packages/arch"
+	container_module "code.gitea.io/gitea/modules/packages/container"
 	debian_module "code.gitea.io/gitea/modules/packages/debian"
 	rpm_module "code.gitea.io/gitea/modules/packages/rpm"
 	"code.gitea.io/gitea/modules/setting"
@@ -30,6 +31,8 @@ import (
 	"code.gitea.io/gitea/services/context"
 	"code.gitea.io/gitea/services/forms"
 	packages_service "code.gitea.io/gitea/services/packages"
+	nix_service "code.gitea.io/gitea/services/packages/nix"
+	packages_vulnerability_service "code.gitea.io/gitea/services/packages/vulnerability"
 )
 
 const (
@@ -181,6 +184,23 @@ func ViewPackageVersion(ctx *context.Context) {
 	switch pd.Package.Type {
 	case packages_model.TypeContainer:
 		ctx.Data["RegistryHost"] = setting.Packages.RegistryHost
+
+		for _, f := range pd.Files {
+			if f.File.LowerName != container_model.ManifestFilename {
+				continue
+			}
+			referrers, err := container_model.GetReferrers(ctx, &container_model.ReferrerSearchOptions{
+				OwnerID: pd.Owner.ID,
+				Image:   pd.Package.LowerName,
+				Subject: f.Properties.GetByName(container_module.PropertyDigest),
+			})
+			if err != nil {
+				ctx.ServerError("GetReferrers", err)
+				return
+			}
+			ctx.Data["ContainerReferrers"] = referrers
+		}
+		ctx.Data["ContainerSubject"] = pd.VersionProperties.GetByName(container_module.PropertyManifestSubject)
 	case packages_model.TypeAlpine:
 		branches := make(container.Set[string])
 		repositories := make(container.Set[string])
@@ -252,12 +272,41 @@ func ViewPackageVersion(ctx *context.Context) {
 
 		ctx.Data["Groups"] = util.Sorted(groups.Values())
 		ctx.Data["Architectures"] = util.Sorted(architectures.Values())
+	case packages_model.TypeNix:
+		_, pub, err := nix_service.GetOrCreateKeyPair(ctx, pd.Owner)
+		if err != nil {
+			ctx.ServerError("GetOrCreateKeyPair", err)
+			return
+		}
+		ctx.Data["NixPublicKey"] = pub
+	}
+
+	attestations, err := packages_model.GetAttestationsByVersionID(ctx, pd.Version.ID)
+	if err != nil {
+		ctx.ServerError("GetAttestationsByVersionID", err)
+		return
+	}
+	ctx.Data["Attestations"] = attestations
+
+	if packages_vulnerability_service.IsSupportedType(pd.Package.Type) {
+		vulnerabilities, err := packages_vulnerability_service.GetVulnerabilities(ctx, pd.Version.ID)
+		if err != nil {
+			ctx.ServerError("GetVulnerabilities", err)
+			return
+		}
+		ctx.Data["Vulnerabilities"] = vulnerabilities
+
+		blocked, err := packages_vulnerability_service.IsDownloadBlocked(ctx, pd.Version.ID)
+		if err != nil {
+			ctx.ServerError("IsDownloadBlocked", err)
+			return
+		}
+		ctx.Data["VulnerabilitiesBlockDownload"] = blocked
 	}
 
 	var (
 		total int64
 		pvs   []*packages_model.PackageVersion
-		err   error
 	)
 	switch pd.Package.Type {
 	case packages_model.TypeContainer:
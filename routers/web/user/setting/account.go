@@ -183,7 +183,7 @@ func EmailPost(ctx *context.Context) {
 		opts := &user.UpdateOptions{
 			EmailNotificationsPreference: optional.Some(preference),
 		}
-		if err := user.UpdateUser(ctx, ctx.Doer, opts); err != nil {
+		if err := user.UpdateUser(ctx, ctx.Doer, ctx.Doer, opts); err != nil {
 			log.Error("Set Email Notifications failed: %v", err)
 			ctx.ServerError("UpdateUser", err)
 			return
@@ -291,7 +291,7 @@ func DeleteAccount(ctx *context.Context) {
 		return
 	}
 
-	if err := user.DeleteUser(ctx, ctx.Doer, false); err != nil {
+	if err := user.DeleteUser(ctx, ctx.Doer, ctx.Doer, false); err != nil {
 		switch {
 		case models.IsErrUserOwnRepos(err):
 			ctx.Flash.Error(ctx.Tr("form.still_own_repo"))
@@ -5,14 +5,22 @@
 package setting
 
 import (
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	auth_model "code.gitea.io/gitea/models/auth"
 	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/modules/base"
 	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/modules/web"
+	auth_service "code.gitea.io/gitea/services/auth"
 	"code.gitea.io/gitea/services/context"
+	"code.gitea.io/gitea/services/convert"
 	"code.gitea.io/gitea/services/forms"
 )
 
@@ -49,9 +57,8 @@ func ApplicationsPost(ctx *context.Context) {
 		return
 	}
 	t := &auth_model.AccessToken{
-		UID:   ctx.Doer.ID,
-		Name:  form.Name,
-		Scope: scope,
+		UID:  ctx.Doer.ID,
+		Name: form.Name,
 	}
 
 	exist, err := auth_model.AccessTokenByNameExists(ctx, t)
@@ -65,8 +72,29 @@ func ApplicationsPost(ctx *context.Context) {
 		return
 	}
 
-	if err := auth_model.NewAccessToken(ctx, t); err != nil {
-		ctx.ServerError("NewAccessToken", err)
+	opts := &auth_service.AccessTokenOptions{
+		Name:         form.Name,
+		Scope:        scope,
+		Organization: strings.TrimSpace(form.Organization),
+		Repositories: form.GetRepositories(),
+	}
+	if form.ExpiresAt != "" {
+		expiresAt, err := time.ParseInLocation("2006-01-02", form.ExpiresAt, time.Local)
+		if err != nil {
+			ctx.Flash.Error(ctx.Tr("settings.generate_token_invalid", err.Error()))
+			ctx.Redirect(setting.AppSubURL + "/user/settings/applications")
+			return
+		}
+		opts.ExpiresUnix = timeutil.TimeStamp(expiresAt.Unix())
+	}
+	t, err = auth_service.CreateAccessToken(ctx, ctx.Doer, ctx.Doer, opts)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			ctx.Flash.Error(ctx.Tr("settings.generate_token_invalid", err.Error()))
+			ctx.Redirect(setting.AppSubURL + "/user/settings/applications")
+			return
+		}
+		ctx.ServerError("CreateAccessToken", err)
 		return
 	}
 
@@ -78,7 +106,7 @@ func ApplicationsPost(ctx *context.Context) {
 
 // DeleteApplication response for delete user access token
 func DeleteApplication(ctx *context.Context) {
-	if err := auth_model.DeleteAccessTokenByID(ctx, ctx.FormInt64("id"), ctx.Doer.ID); err != nil {
+	if err := auth_service.DeleteAccessToken(ctx, ctx.Doer, ctx.Doer, ctx.FormInt64("id")); err != nil {
 		ctx.Flash.Error("DeleteAccessTokenByID: " + err.Error())
 	} else {
 		ctx.Flash.Success(ctx.Tr("settings.delete_token_success"))
@@ -95,6 +123,18 @@ func loadApplicationsData(ctx *context.Context) {
 		return
 	}
 	ctx.Data["Tokens"] = tokens
+	tokenInfos := make(map[int64]*api.AccessToken, len(tokens))
+	for _, t := range tokens {
+		if tokenInfos[t.ID], err = convert.ToAccessToken(ctx, t); err != nil {
+			ctx.ServerError("ToAccessToken", err)
+			return
+		}
+	}
+	ctx.Data["TokenInfos"] = tokenInfos
+	ctx.Data["AccessTokenMinExpiry"] = time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	if setting.AccessTokenMaxLifetime > 0 {
+		ctx.Data["AccessTokenMaxExpiry"] = time.Now().Add(setting.AccessTokenMaxLifetime).Format("2006-01-02")
+	}
 	ctx.Data["EnableOAuth2"] = setting.OAuth2.Enabled
 	ctx.Data["IsAdmin"] = ctx.Doer.IsAdmin
 	if setting.OAuth2.Enabled {
@@ -102,7 +102,7 @@ func ProfilePost(ctx *context.Context) {
 		Visibility:          optional.Some(form.Visibility),
 		KeepActivityPrivate: optional.Some(form.KeepActivityPrivate),
 	}
-	if err := user_service.UpdateUser(ctx, ctx.Doer, opts); err != nil {
+	if err := user_service.UpdateUser(ctx, ctx.Doer, ctx.Doer, opts); err != nil {
 		ctx.ServerError("UpdateUser", err)
 		return
 	}
@@ -361,7 +361,7 @@ func UpdateUIThemePost(ctx *context.Context) {
 	opts := &user_service.UpdateOptions{
 		Theme: optional.Some(form.Theme),
 	}
-	if err := user_service.UpdateUser(ctx, ctx.Doer, opts); err != nil {
+	if err := user_service.UpdateUser(ctx, ctx.Doer, ctx.Doer, opts); err != nil {
 		ctx.Flash.Error(ctx.Tr("settings.theme_update_error"))
 	} else {
 		ctx.Flash.Success(ctx.Tr("settings.theme_update_success"))
@@ -387,7 +387,7 @@ func UpdateUserLang(ctx *context.Context) {
 	opts := &user_service.UpdateOptions{
 		Language: optional.Some(form.Language),
 	}
-	if err := user_service.UpdateUser(ctx, ctx.Doer, opts); err != nil {
+	if err := user_service.UpdateUser(ctx, ctx.Doer, ctx.Doer, opts); err != nil {
 		ctx.ServerError("UpdateUser", err)
 		return
 	}
@@ -409,7 +409,7 @@ func UpdateUserHints(ctx *context.Context) {
 	opts := &user_service.UpdateOptions{
 		EnableRepoUnitHints: optional.Some(form.EnableRepoUnitHints),
 	}
-	if err := user_service.UpdateUser(ctx, ctx.Doer, opts); err != nil {
+	if err := user_service.UpdateUser(ctx, ctx.Doer, ctx.Doer, opts); err != nil {
 		ctx.ServerError("UpdateUser", err)
 		return
 	}
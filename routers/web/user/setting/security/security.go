@@ -16,6 +16,7 @@ import (
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/services/auth/source/oauth2"
 	"code.gitea.io/gitea/services/context"
+	federation_service "code.gitea.io/gitea/services/federation"
 )
 
 const (
@@ -145,4 +146,16 @@ func loadSecurityData(ctx *context.Context) {
 		return
 	}
 	ctx.Data["OpenIDs"] = openid
+	ctx.Data["EnableFederation"] = setting.Federation.Enabled
+}
+
+// RotateFederationKey replaces the key activities of the user are signed with
+func RotateFederationKey(ctx *context.Context) {
+	if err := federation_service.RotateKey(ctx, ctx.Doer); err != nil {
+		ctx.ServerError("RotateKey", err)
+		return
+	}
+
+	ctx.Flash.Success(ctx.Tr("settings.federation_key.rotate.success"))
+	ctx.Redirect(setting.AppSubURL + "/user/settings/security")
 }
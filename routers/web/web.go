@@ -447,6 +447,7 @@ func registerRoutes(m *web.Route) {
 		m.Get("/change-password", func(ctx *context.Context) {
 			ctx.Redirect(setting.AppSubURL + "/user/settings/account")
 		})
+		m.Get("/terraform.json", packagesEnabled, TerraformServiceDiscovery)
 		m.Methods("GET, HEAD", "/*", public.FileHandlerFunc())
 	}, optionsCorsHandler())
 
@@ -526,6 +527,13 @@ func registerRoutes(m *web.Route) {
 	m.Methods("POST, OPTIONS", "/login/oauth/access_token", optionsCorsHandler(), web.Bind(forms.AccessTokenForm{}), ignSignInAndCsrf, auth.AccessTokenOAuth)
 	m.Methods("GET, OPTIONS", "/login/oauth/keys", optionsCorsHandler(), ignSignInAndCsrf, auth.OIDCKeys)
 	m.Methods("POST, OPTIONS", "/login/oauth/introspect", optionsCorsHandler(), web.Bind(forms.IntrospectTokenForm{}), ignSignInAndCsrf, auth.IntrospectOAuth)
+	m.Methods("POST, OPTIONS", "/login/oauth/device_authorization", optionsCorsHandler(), web.Bind(forms.DeviceAuthorizationForm{}), ignSignInAndCsrf, auth.DeviceAuthorizationOAuth)
+
+	m.Group("/login/device", func() {
+		m.Get("", auth.DeviceCode)
+		m.Post("", web.Bind(forms.DeviceUserCodeForm{}), auth.DeviceCodePost)
+		m.Post("/grant", web.Bind(forms.DeviceGrantForm{}), auth.DeviceGrantPost)
+	}, reqSignIn)
 
 	m.Group("/user/settings", func() {
 		m.Get("", user_setting.Profile)
@@ -566,6 +574,7 @@ func registerRoutes(m *web.Route) {
 				m.Post("/toggle_visibility", security.ToggleOpenIDVisibility)
 			}, openIDSignInEnabled)
 			m.Post("/account_link", linkAccountEnabled, security.DeleteAccountLink)
+			m.Post("/federation_key", federationEnabled, security.RotateFederationKey)
 		})
 		m.Group("/applications/oauth2", func() {
 			m.Get("/{id}", user_setting.OAuth2ApplicationShow)
@@ -594,6 +603,16 @@ func registerRoutes(m *web.Route) {
 					m.Get("/preview", user_setting.PackagesRulePreview)
 				})
 			})
+			m.Group("/proxies", func() {
+				m.Group("/add", func() {
+					m.Get("", user_setting.PackagesProxyAdd)
+					m.Post("", web.Bind(forms.PackageProxyForm{}), user_setting.PackagesProxyAddPost)
+				})
+				m.Group("/{id}", func() {
+					m.Get("", user_setting.PackagesProxyEdit)
+					m.Post("", web.Bind(forms.PackageProxyForm{}), user_setting.PackagesProxyEditPost)
+				})
+			})
 			m.Group("/cargo", func() {
 				m.Post("/initialize", user_setting.InitializeCargoIndex)
 				m.Post("/rebuild", user_setting.RebuildCargoIndex)
@@ -647,6 +666,13 @@ func registerRoutes(m *web.Route) {
 			m.Get("/{provider}", auth.SignInOAuth)
 			m.Get("/{provider}/callback", auth.SignInOAuthCallback)
 		})
+		m.Group("/saml/{provider}", func() {
+			m.Get("", auth.SignInSAML)
+			m.Get("/metadata", auth.SAMLMetadata)
+			m.Combo("/acs").
+				Get(auth.SignInSAMLCallback).
+				Post(ignSignInAndCsrf, auth.SignInSAMLPost)
+		})
 	})
 	// ***** END: User *****
 
@@ -716,6 +742,8 @@ func registerRoutes(m *web.Route) {
 			m.Get("", admin.Packages)
 			m.Post("/delete", admin.DeletePackageVersion)
 			m.Post("/cleanup", admin.CleanupExpiredData)
+			m.Post("/advisories", admin.ImportAdvisories)
+			m.Post("/container-gc", admin.CollectContainerGarbage)
 		}, packagesEnabled)
 
 		m.Group("/hooks", func() {
@@ -739,8 +767,18 @@ func registerRoutes(m *web.Route) {
 			m.Combo("/{authid}").Get(admin.EditAuthSource).
 				Post(web.Bind(forms.AuthenticationForm{}), admin.EditAuthSourcePost)
 			m.Post("/{authid}/delete", admin.DeleteAuthSource)
+			m.Post("/{authid}/scim_token", admin.GenerateSCIMToken)
 		})
 
+		m.Group("/federation", func() {
+			m.Get("", admin.Federation)
+			m.Post("/rotate-key", admin.RotateInstanceKey)
+			m.Post("/policy", admin.SetFederationHostPolicy)
+			m.Post("/{id}/purge", admin.PurgeFederationHost)
+		}, federationEnabled)
+
+		m.Get("/audit", admin.Audit)
+
 		m.Group("/notices", func() {
 			m.Get("", admin.Notices)
 			m.Post("/delete", admin.DeleteNotices)
@@ -767,7 +805,7 @@ func registerRoutes(m *web.Route) {
 			addSettingsRunnersRoutes()
 			addSettingsVariablesRoutes()
 		})
-	}, adminReq, ctxDataSet("EnableOAuth2", setting.OAuth2.Enabled, "EnablePackages", setting.Packages.Enabled))
+	}, adminReq, ctxDataSet("EnableOAuth2", setting.OAuth2.Enabled, "EnablePackages", setting.Packages.Enabled, "EnableFederation", setting.Federation.Enabled))
 	// ***** END: Admin *****
 
 	m.Group("", func() {
@@ -919,6 +957,8 @@ func registerRoutes(m *web.Route) {
 
 				m.Methods("GET,POST", "/delete", org.SettingsDelete)
 
+				m.Get("/audit", org_setting.Audit)
+
 				m.Group("/blocked_users", func() {
 					m.Get("", org_setting.BlockedUsers)
 					m.Post("/block", org_setting.BlockedUsersBlock)
@@ -938,6 +978,16 @@ func registerRoutes(m *web.Route) {
 							m.Get("/preview", org.PackagesRulePreview)
 						})
 					})
+					m.Group("/proxies", func() {
+						m.Group("/add", func() {
+							m.Get("", org.PackagesProxyAdd)
+							m.Post("", web.Bind(forms.PackageProxyForm{}), org.PackagesProxyAddPost)
+						})
+						m.Group("/{id}", func() {
+							m.Get("", org.PackagesProxyEdit)
+							m.Post("", web.Bind(forms.PackageProxyForm{}), org.PackagesProxyEditPost)
+						})
+					})
 					m.Group("/cargo", func() {
 						m.Post("/initialize", org.InitializeCargoIndex)
 						m.Post("/rebuild", org.RebuildCargoIndex)
@@ -1084,6 +1134,12 @@ func registerRoutes(m *web.Route) {
 				m.Post("/delete", repo_setting.DeleteDeployKey)
 			})
 
+			m.Group("/maintenance", func() {
+				m.Get("", repo_setting.Maintenance)
+				m.Post("/filters", repo_setting.MaintenanceFiltersPost)
+				m.Post("/run", context.RepoMustNotBeArchived(), repo_setting.MaintenanceRunPost)
+			}, repo.MustBeNotEmpty)
+
 			m.Group("/lfs", func() {
 				m.Get("/", repo_setting.LFSFiles)
 				m.Get("/show/{oid}", repo_setting.LFSFileGet)
@@ -1502,6 +1558,9 @@ func registerRoutes(m *web.Route) {
 			m.Post("/merge", context.RepoMustNotBeArchived(), web.Bind(forms.MergePullRequestForm{}), context.EnforceQuotaWeb(quota_model.LimitSubjectSizeGitAll, context.QuotaTargetRepo), repo.MergePullRequest)
 			m.Post("/cancel_auto_merge", context.RepoMustNotBeArchived(), repo.CancelAutoMergePullRequest)
 			m.Post("/update", repo.UpdatePullRequest)
+			m.Combo("/conflicts", reqSignIn, context.RepoMustNotBeArchived()).Get(repo.ViewPullConflicts).
+				Post(web.Bind(forms.ResolvePullConflictsForm{}), context.EnforceQuotaWeb(quota_model.LimitSubjectSizeGitAll, context.QuotaTargetRepo), repo.ResolvePullConflicts)
+			m.Post("/revert", reqRepoCodeWriter, context.RepoMustNotBeArchived(), context.EnforceQuotaWeb(quota_model.LimitSubjectSizeGitAll, context.QuotaTargetRepo), repo.RevertPullRequest)
 			m.Post("/set_allow_maintainer_edit", web.Bind(forms.UpdateAllowEditsForm{}), repo.SetAllowEdits)
 			m.Post("/cleanup", context.RepoMustNotBeArchived(), context.RepoRef(), repo.CleanUpPullRequest)
 			m.Group("/files", func() {
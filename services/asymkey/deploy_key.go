@@ -8,12 +8,34 @@ import (
 
 	"code.gitea.io/gitea/models"
 	asymkey_model "code.gitea.io/gitea/models/asymkey"
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
 	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	audit_service "code.gitea.io/gitea/services/audit"
 )
 
+// AddDeployKey adds a deploy key to a repository.
+func AddDeployKey(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, name, content string, readOnly bool) (*asymkey_model.DeployKey, error) {
+	key, err := asymkey_model.AddDeployKey(ctx, repo.ID, name, content, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	audit_service.Record(ctx, audit_model.RepositoryDeployKeyAdd, doer, repo, key, "Added deploy key %s with %s access to %s.", key.Name, key.Mode, repo.FullName())
+	return key, nil
+}
+
 // DeleteDeployKey deletes deploy key from its repository authorized_keys file if needed.
 func DeleteDeployKey(ctx context.Context, doer *user_model.User, id int64) error {
+	key, err := asymkey_model.GetDeployKeyByID(ctx, id)
+	if err != nil {
+		if asymkey_model.IsErrDeployKeyNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
 	dbCtx, committer, err := db.TxContext(ctx)
 	if err != nil {
 		return err
@@ -27,5 +49,11 @@ func DeleteDeployKey(ctx context.Context, doer *user_model.User, id int64) error
 		return err
 	}
 
+	if repo, err := repo_model.GetRepositoryByID(ctx, key.RepoID); err != nil {
+		log.Error("GetRepositoryByID: %v", err)
+	} else {
+		audit_service.Record(ctx, audit_model.RepositoryDeployKeyRemove, doer, repo, key, "Removed deploy key %s from %s.", key.Name, repo.FullName())
+	}
+
 	return asymkey_model.RewriteAllPublicKeys(ctx)
 }
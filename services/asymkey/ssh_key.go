@@ -7,10 +7,22 @@ import (
 	"context"
 
 	asymkey_model "code.gitea.io/gitea/models/asymkey"
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
 	user_model "code.gitea.io/gitea/models/user"
+	audit_service "code.gitea.io/gitea/services/audit"
 )
 
+// AddPublicKey adds a SSH key to the account of the owner.
+func AddPublicKey(ctx context.Context, doer, owner *user_model.User, name, content string) (*asymkey_model.PublicKey, error) {
+	key, err := asymkey_model.AddPublicKey(ctx, owner.ID, name, content, 0)
+	if err != nil {
+		return nil, err
+	}
+	audit_service.Record(ctx, audit_model.UserKeySSHAdd, doer, owner, key, "Added SSH key %s (%s).", key.Name, key.Fingerprint)
+	return key, nil
+}
+
 // DeletePublicKey deletes SSH key information both in database and authorized_keys file.
 func DeletePublicKey(ctx context.Context, doer *user_model.User, id int64) (err error) {
 	key, err := asymkey_model.GetPublicKeyByID(ctx, id)
@@ -27,6 +39,13 @@ func DeletePublicKey(ctx context.Context, doer *user_model.User, id int64) (err
 		}
 	}
 
+	owner := doer
+	if key.OwnerID != doer.ID {
+		if owner, err = user_model.GetUserByID(ctx, key.OwnerID); err != nil {
+			return err
+		}
+	}
+
 	dbCtx, committer, err := db.TxContext(ctx)
 	if err != nil {
 		return err
@@ -42,6 +61,8 @@ func DeletePublicKey(ctx context.Context, doer *user_model.User, id int64) (err
 	}
 	committer.Close()
 
+	audit_service.Record(ctx, audit_model.UserKeySSHRemove, doer, owner, key, "Removed SSH key %s (%s).", key.Name, key.Fingerprint)
+
 	if key.Type == asymkey_model.KeyTypePrincipal {
 		return asymkey_model.RewriteAllPrincipalKeys(ctx)
 	}
@@ -0,0 +1,154 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	asymkey_model "code.gitea.io/gitea/models/asymkey"
+	audit_model "code.gitea.io/gitea/models/audit"
+	auth_model "code.gitea.io/gitea/models/auth"
+	git_model "code.gitea.io/gitea/models/git"
+	"code.gitea.io/gitea/models/organization"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/web/middleware"
+)
+
+// Record appends an event to the audit log and streams it to the "audit" logger if it is enabled.
+// The scope is the user, organization or repository the event belongs to, nil for instance wide events.
+// Failures are logged, they never fail the recorded action.
+func Record(ctx context.Context, action audit_model.Action, doer *user_model.User, scope, target any, format string, v ...any) {
+	e := &audit_model.Event{
+		Action:    action,
+		Message:   fmt.Sprintf(format, v...),
+		IPAddress: remoteAddress(ctx),
+	}
+	if doer != nil {
+		e.ActorID = doer.ID
+		e.ActorName = doer.Name
+	}
+
+	switch s := scope.(type) {
+	case nil:
+	case *user_model.User:
+		e.OwnerID = s.ID
+	case *organization.Organization:
+		e.OwnerID = s.ID
+	case *repo_model.Repository:
+		e.OwnerID = s.OwnerID
+		e.RepoID = s.ID
+	default:
+		log.Error("audit.Record: unsupported scope %T for %s", scope, action)
+		return
+	}
+
+	switch t := target.(type) {
+	case *user_model.User:
+		e.TargetType = audit_model.TargetUser
+		if t.IsOrganization() {
+			e.TargetType = audit_model.TargetOrganization
+		}
+		e.TargetID = t.ID
+		e.TargetName = t.Name
+	case *organization.Organization:
+		e.TargetType = audit_model.TargetOrganization
+		e.TargetID = t.ID
+		e.TargetName = t.Name
+	case *organization.Team:
+		e.TargetType = audit_model.TargetTeam
+		e.TargetID = t.ID
+		e.TargetName = t.Name
+	case *repo_model.Repository:
+		e.TargetType = audit_model.TargetRepository
+		e.TargetID = t.ID
+		e.TargetName = t.FullName()
+	case *git_model.ProtectedBranch:
+		e.TargetType = audit_model.TargetBranchProtection
+		e.TargetID = t.ID
+		e.TargetName = t.RuleName
+	case *auth_model.AccessToken:
+		e.TargetType = audit_model.TargetAccessToken
+		e.TargetID = t.ID
+		e.TargetName = t.Name
+	case *asymkey_model.PublicKey:
+		e.TargetType = audit_model.TargetPublicKey
+		e.TargetID = t.ID
+		e.TargetName = t.Name
+	case *asymkey_model.DeployKey:
+		e.TargetType = audit_model.TargetDeployKey
+		e.TargetID = t.ID
+		e.TargetName = t.Name
+	default:
+		log.Error("audit.Record: unsupported target %T for %s", target, action)
+		return
+	}
+
+	if err := audit_model.InsertEvent(ctx, e); err != nil {
+		log.Error("audit.Record: unable to store %s by %s on %s %q: %v", action, e.ActorName, e.TargetType, e.TargetName, err)
+	}
+
+	if setting.IsAuditLogEnabled() {
+		streamEvent(e)
+	}
+}
+
+// logEvent is the format of the events streamed to the "audit" logger
+type logEvent struct {
+	Time       time.Time              `json:"time"`
+	Action     audit_model.Action     `json:"action"`
+	ActorID    int64                  `json:"actor_id"`
+	ActorName  string                 `json:"actor_name"`
+	OwnerID    int64                  `json:"owner_id,omitempty"`
+	RepoID     int64                  `json:"repo_id,omitempty"`
+	TargetType audit_model.TargetType `json:"target_type"`
+	TargetID   int64                  `json:"target_id"`
+	TargetName string                 `json:"target_name"`
+	Message    string                 `json:"message"`
+	IPAddress  string                 `json:"ip_address,omitempty"`
+}
+
+func streamEvent(e *audit_model.Event) {
+	created := time.Now()
+	if e.CreatedUnix != 0 {
+		created = e.CreatedUnix.AsTime()
+	}
+	line, err := json.Marshal(logEvent{
+		Time:       created.UTC(),
+		Action:     e.Action,
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		OwnerID:    e.OwnerID,
+		RepoID:     e.RepoID,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		TargetName: e.TargetName,
+		Message:    e.Message,
+		IPAddress:  e.IPAddress,
+	})
+	if err != nil {
+		log.Error("audit.Record: unable to marshal event: %v", err)
+		return
+	}
+	log.GetLogger("audit").Info("%s", line)
+}
+
+// remoteAddress returns the address of the client whose request triggered the event, without port
+func remoteAddress(ctx context.Context) string {
+	req := middleware.GetRequest(ctx)
+	if req == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package auth
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	audit_model "code.gitea.io/gitea/models/audit"
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/organization"
+	access_model "code.gitea.io/gitea/models/perm/access"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+	audit_service "code.gitea.io/gitea/services/audit"
+	"code.gitea.io/gitea/services/mailer"
+)
+
+// repoRestrictedScopes are the only scopes a token restricted to repositories can have
+var repoRestrictedScopes = []auth_model.AccessTokenScope{
+	auth_model.AccessTokenScopeReadRepository,
+	auth_model.AccessTokenScopeWriteRepository,
+	auth_model.AccessTokenScopeReadIssue,
+	auth_model.AccessTokenScopeWriteIssue,
+}
+
+// AccessTokenOptions are the options of a new personal access token
+type AccessTokenOptions struct {
+	Name        string
+	Scope       auth_model.AccessTokenScope
+	ExpiresUnix timeutil.TimeStamp
+	// Organization restricts the token to the repositories of an organization the user is a member of
+	Organization string
+	// Repositories restricts the token to a list of "owner/name" repositories the user can access
+	Repositories []string
+}
+
+// CreateAccessToken creates a personal access token of a user on behalf of the doer, nil when the
+// token is created from the command line. The expiry date must comply with the maximum lifetime of
+// the tokens, and a token restricted to repositories can only have the repository and issue scopes.
+func CreateAccessToken(ctx context.Context, doer, u *user_model.User, opts *AccessTokenOptions) (*auth_model.AccessToken, error) {
+	if opts.ExpiresUnix != 0 && opts.ExpiresUnix <= timeutil.TimeStampNow() {
+		return nil, util.NewInvalidArgumentErrorf("the expiry date must be in the future")
+	}
+	if setting.AccessTokenMaxLifetime > 0 {
+		if opts.ExpiresUnix == 0 || opts.ExpiresUnix > timeutil.TimeStampNow().AddDuration(setting.AccessTokenMaxLifetime) {
+			return nil, util.NewInvalidArgumentErrorf("the token must expire within %s", setting.AccessTokenMaxLifetime)
+		}
+	}
+
+	t := &auth_model.AccessToken{
+		UID:         u.ID,
+		Name:        opts.Name,
+		Scope:       opts.Scope,
+		ExpiresUnix: opts.ExpiresUnix,
+	}
+
+	if opts.Organization != "" && len(opts.Repositories) > 0 {
+		return nil, util.NewInvalidArgumentErrorf("a token cannot be restricted to both an organization and a list of repositories")
+	}
+	if opts.Organization != "" {
+		org, err := organization.GetOrgByName(ctx, opts.Organization)
+		if err != nil {
+			if organization.IsErrOrgNotExist(err) {
+				return nil, util.NewInvalidArgumentErrorf("organization %q does not exist", opts.Organization)
+			}
+			return nil, err
+		}
+		isMember, err := org.IsOrgMember(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		} else if !isMember {
+			return nil, util.NewInvalidArgumentErrorf("organization %q does not exist", opts.Organization)
+		}
+		t.OrgID = org.ID
+	}
+	for _, fullName := range opts.Repositories {
+		ownerName, repoName, ok := strings.Cut(strings.TrimSpace(fullName), "/")
+		if !ok {
+			return nil, util.NewInvalidArgumentErrorf("repository %q is not of the form owner/name", fullName)
+		}
+		repo, err := repo_model.GetRepositoryByOwnerAndName(ctx, ownerName, repoName)
+		if err != nil {
+			if repo_model.IsErrRepoNotExist(err) {
+				return nil, util.NewInvalidArgumentErrorf("repository %q does not exist", fullName)
+			}
+			return nil, err
+		}
+		perm, err := access_model.GetUserRepoPermission(ctx, repo, u)
+		if err != nil {
+			return nil, err
+		} else if !perm.HasAccess() {
+			return nil, util.NewInvalidArgumentErrorf("repository %q does not exist", fullName)
+		}
+		if !slices.Contains(t.RepoIDs, repo.ID) {
+			t.RepoIDs = append(t.RepoIDs, repo.ID)
+		}
+	}
+
+	if t.IsRepoRestricted() {
+		for _, scope := range t.Scope.StringSlice() {
+			if !slices.Contains(repoRestrictedScopes, auth_model.AccessTokenScope(scope)) {
+				return nil, util.NewInvalidArgumentErrorf("a token restricted to repositories can only have the repository and issue scopes")
+			}
+		}
+	}
+
+	if err := auth_model.NewAccessToken(ctx, t); err != nil {
+		return nil, err
+	}
+	audit_service.Record(ctx, audit_model.UserAccessTokenAdd, doer, u, t, "Created access token %s.", t.Name)
+	return t, nil
+}
+
+// DeleteAccessToken deletes a personal access token of a user on behalf of the doer
+func DeleteAccessToken(ctx context.Context, doer, u *user_model.User, id int64) error {
+	t, err := auth_model.GetAccessTokenByID(ctx, id, u.ID)
+	if err != nil {
+		return err
+	}
+	if err := auth_model.DeleteAccessTokenByID(ctx, t.ID, u.ID); err != nil {
+		return err
+	}
+	audit_service.Record(ctx, audit_model.UserAccessTokenRemove, doer, u, t, "Removed access token %s.", t.Name)
+	return nil
+}
+
+// NotifyExpiringAccessTokens informs the owners of the access tokens expiring within the given duration,
+// each token is only notified once
+func NotifyExpiringAccessTokens(ctx context.Context, within time.Duration) error {
+	tokens, err := auth_model.FindAccessTokensToNotifyExpiry(ctx, timeutil.TimeStampNow().AddDuration(within))
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		select {
+		case <-ctx.Done():
+			return db.ErrCancelledf("before notifying the expiry of access token %d", t.ID)
+		default:
+		}
+		u, err := user_model.GetUserByID(ctx, t.UID)
+		if err != nil {
+			if user_model.IsErrUserNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := mailer.SendAccessTokenExpiry(ctx, u, t); err != nil {
+			return err
+		}
+		if err := auth_model.SetAccessTokenExpiryNotified(ctx, t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
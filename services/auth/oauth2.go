@@ -129,8 +129,9 @@ func parseToken(req *http.Request) (string, bool) {
 }
 
 // userIDFromToken returns the user id corresponding to the OAuth token.
-// It will set 'IsApiToken' to true if the token is an API token and
-// set 'ApiTokenScope' to the scope of the access token
+// It will set 'IsApiToken' to true if the token is an API token,
+// set 'ApiTokenScope' to the scope of the access token and
+// set 'ApiToken' to the personal access token
 func (o *OAuth2) userIDFromToken(ctx context.Context, tokenSHA string, store DataStore) int64 {
 	// Let's see if token is valid.
 	if strings.Contains(tokenSHA, ".") {
@@ -170,6 +171,7 @@ func (o *OAuth2) userIDFromToken(ctx context.Context, tokenSHA string, store Dat
 	}
 	store.GetData()["IsApiToken"] = true
 	store.GetData()["ApiTokenScope"] = t.Scope
+	store.GetData()["ApiToken"] = t
 	return t.UID
 }
 
@@ -194,6 +196,11 @@ func (o *OAuth2) Verify(req *http.Request, w http.ResponseWriter, store DataStor
 	if id <= 0 && id != -2 { // -2 means actions, so we need to allow it.
 		return nil, user_model.ErrUserNotExist{}
 	}
+	// A token restricted to repositories can only be used on the paths of a repository
+	if t, ok := store.GetData()["ApiToken"].(*auth_model.AccessToken); ok && t.IsRepoRestricted() &&
+		(isAttachmentDownload(req) || isAuthenticatedTokenRequest(req)) {
+		return nil, user_model.ErrUserNotExist{}
+	}
 	log.Trace("OAuth2 Authorization: Found token for user[%d]", id)
 
 	user, err := user_model.GetPossibleUserByID(req.Context(), id)
@@ -22,6 +22,7 @@ type sourceInterface interface {
 	auth_model.HasTLSer
 	auth_model.UseTLSer
 	auth_model.SourceSettable
+	auth_model.GroupTeamMapper
 }
 
 var _ (sourceInterface) = &ldap.Source{}
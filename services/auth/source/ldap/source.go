@@ -116,6 +116,11 @@ func (source *Source) SetAuthSource(authSource *auth.Source) {
 	source.authSource = authSource
 }
 
+// GetGroupTeamMap returns the JSON mapping between the groups of the users of this source and organization teams
+func (source *Source) GetGroupTeamMap() string {
+	return source.GroupTeamMap
+}
+
 func init() {
 	auth.RegisterTypeConfig(auth.LDAP, &Source{})
 	auth.RegisterTypeConfig(auth.DLDAP, &Source{})
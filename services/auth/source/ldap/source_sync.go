@@ -173,7 +173,7 @@ func (source *Source) Sync(ctx context.Context, updateExisting bool) error {
 					opts.IsRestricted = optional.Some(su.IsRestricted)
 				}
 
-				if err := user_service.UpdateUser(ctx, usr, opts); err != nil {
+				if err := user_service.UpdateUser(ctx, nil, usr, opts); err != nil {
 					log.Error("SyncExternalUsers[%s]: Error updating user %s: %v", source.authSource.Name, usr.Name, err)
 				}
 
@@ -223,7 +223,7 @@ func (source *Source) Sync(ctx context.Context, updateExisting bool) error {
 			opts := &user_service.UpdateOptions{
 				IsActive: optional.Some(false),
 			}
-			if err := user_service.UpdateUser(ctx, usr, opts); err != nil {
+			if err := user_service.UpdateUser(ctx, nil, usr, opts); err != nil {
 				log.Error("SyncExternalUsers[%s]: Error deactivating user %s: %v", source.authSource.Name, usr.Name, err)
 			}
 		}
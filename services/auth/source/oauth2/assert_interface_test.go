@@ -17,6 +17,7 @@ type sourceInterface interface {
 	auth_model.SourceSettable
 	auth_model.RegisterableSource
 	auth.PasswordAuthenticator
+	auth_model.GroupTeamMapper
 }
 
 var _ (sourceInterface) = &oauth2.Source{}
@@ -0,0 +1,62 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	saml_module "code.gitea.io/gitea/modules/auth/saml"
+	"code.gitea.io/gitea/modules/proxy"
+)
+
+const maxMetadataSize = 1 << 20
+
+// ImportIdentityProviderMetadata downloads the metadata of the identity provider when it has a URL,
+// and checks that the metadata describes an identity provider usable by Forgejo
+func (source *Source) ImportIdentityProviderMetadata(ctx context.Context) error {
+	if source.IdentityProviderMetadataURL != "" {
+		metadata, err := fetchMetadata(ctx, source.IdentityProviderMetadataURL)
+		if err != nil {
+			return fmt.Errorf("unable to download the metadata: %w", err)
+		}
+		source.IdentityProviderMetadata = string(metadata)
+	}
+	if source.IdentityProviderMetadata == "" {
+		return errors.New("the metadata of the identity provider is missing")
+	}
+	_, err := saml_module.ParseIdentityProviderMetadata([]byte(source.IdentityProviderMetadata))
+	return err
+}
+
+func fetchMetadata(ctx context.Context, metadataURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{Proxy: proxy.Proxy()},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	metadata, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) > maxMetadataSize {
+		return nil, errors.New("the metadata is too large")
+	}
+	return metadata, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"context"
+	"net/url"
+
+	"code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	saml_module "code.gitea.io/gitea/modules/auth/saml"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/optional"
+	"code.gitea.io/gitea/modules/setting"
+)
+
+// Source holds configuration for the SAML login source.
+type Source struct {
+	// IdentityProviderMetadata is the XML metadata of the identity provider
+	IdentityProviderMetadata string
+	// IdentityProviderMetadataURL is where the metadata was imported from, if it was
+	IdentityProviderMetadataURL string `json:",omitempty"`
+	// ServiceProviderEntityID overrides the entity ID of Forgejo, which defaults to its metadata URL
+	ServiceProviderEntityID string `json:",omitempty"`
+
+	// The attributes of the assertion holding the user details, the username defaults to the NameID
+	AttributeUsername string
+	AttributeEmail    string
+	AttributeFullName string
+
+	GroupAttribute      string
+	AdminGroup          string
+	RestrictedGroup     string
+	GroupTeamMap        string
+	GroupTeamMapRemoval bool
+	SkipLocalTwoFA      bool `json:",omitempty"`
+
+	// reference to the authSource
+	authSource *auth.Source
+}
+
+// FromDB fills up a SAMLConfig from serialized format.
+func (source *Source) FromDB(bs []byte) error {
+	return json.UnmarshalHandleDoubleEncode(bs, &source)
+}
+
+// ToDB exports a SAMLConfig to a serialized format.
+func (source *Source) ToDB() ([]byte, error) {
+	return json.Marshal(source)
+}
+
+// SetAuthSource sets the related AuthSource
+func (source *Source) SetAuthSource(authSource *auth.Source) {
+	source.authSource = authSource
+}
+
+// IsSkipLocalTwoFA returns if this source should skip local 2fa for password authentication
+func (source *Source) IsSkipLocalTwoFA() bool {
+	return source.SkipLocalTwoFA
+}
+
+// GetGroupTeamMap returns the JSON mapping between the groups of the users of this source and organization teams
+func (source *Source) GetGroupTeamMap() string {
+	return source.GroupTeamMap
+}
+
+// MetadataURL returns the URL of the metadata of Forgejo as a service provider of this source
+func MetadataURL(sourceName string) string {
+	return setting.AppURL + "user/saml/" + url.PathEscape(sourceName) + "/metadata"
+}
+
+// AcsURL returns the URL where the identity provider posts its responses for this source
+func AcsURL(sourceName string) string {
+	return setting.AppURL + "user/saml/" + url.PathEscape(sourceName) + "/acs"
+}
+
+// ServiceProvider returns the service provider of this source
+func (source *Source) ServiceProvider() (*saml_module.ServiceProvider, error) {
+	idp, err := saml_module.ParseIdentityProviderMetadata([]byte(source.IdentityProviderMetadata))
+	if err != nil {
+		return nil, err
+	}
+	entityID := source.ServiceProviderEntityID
+	if entityID == "" {
+		entityID = MetadataURL(source.authSource.Name)
+	}
+	return &saml_module.ServiceProvider{
+		EntityID: entityID,
+		AcsURL:   AcsURL(source.authSource.Name),
+		IDP:      idp,
+	}, nil
+}
+
+// GetActiveSources returns the active SAML sources, to be offered on the sign in page
+func GetActiveSources(ctx context.Context) ([]*auth.Source, error) {
+	return db.Find[auth.Source](ctx, auth.FindSourcesOptions{
+		IsActive:  optional.Some(true),
+		LoginType: auth.SAML,
+	})
+}
+
+func init() {
+	auth.RegisterTypeConfig(auth.SAML, &Source{})
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package saml
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	user_model "code.gitea.io/gitea/models/user"
+	auth_module "code.gitea.io/gitea/modules/auth"
+	saml_module "code.gitea.io/gitea/modules/auth/saml"
+	"code.gitea.io/gitea/modules/container"
+	"code.gitea.io/gitea/modules/optional"
+	"code.gitea.io/gitea/modules/setting"
+	source_service "code.gitea.io/gitea/services/auth/source"
+	user_service "code.gitea.io/gitea/services/user"
+)
+
+// SignIn returns the user identified by a verified assertion, creating them unless only internal registration is allowed.
+// The admin and restricted flags and the team memberships of the user follow the groups of the assertion.
+func (source *Source) SignIn(ctx context.Context, assertion *saml_module.Assertion) (*user_model.User, error) {
+	groups := container.Set[string]{}
+	if source.GroupAttribute != "" {
+		groups.AddMultiple(assertion.Attributes[source.GroupAttribute]...)
+	}
+	var isAdmin, isRestricted optional.Option[bool]
+	if source.GroupAttribute != "" && source.AdminGroup != "" {
+		isAdmin = optional.Some(groups.Contains(source.AdminGroup))
+	}
+	if source.GroupAttribute != "" && source.RestrictedGroup != "" {
+		isRestricted = optional.Some(!isAdmin.ValueOrDefault(false) && groups.Contains(source.RestrictedGroup))
+	}
+
+	user, err := user_model.GetUserBySourceAndLoginName(ctx, source.authSource, assertion.NameID)
+	if err != nil && !user_model.IsErrUserNotExist(err) {
+		return nil, err
+	}
+
+	if user != nil {
+		if user.ProhibitLogin {
+			return nil, user_model.ErrUserProhibitLogin{UID: user.ID, Name: user.Name}
+		}
+		if !user.IsActive {
+			return nil, user_model.ErrUserInactive{UID: user.ID, Name: user.Name}
+		}
+		opts := &user_service.UpdateOptions{}
+		if isAdmin.Has() && user.IsAdmin != isAdmin.Value() {
+			opts.IsAdmin = isAdmin
+		}
+		if isRestricted.Has() && user.IsRestricted != isRestricted.Value() {
+			opts.IsRestricted = isRestricted
+		}
+		if opts.IsAdmin.Has() || opts.IsRestricted.Has() {
+			if err := user_service.UpdateUser(ctx, nil, user, opts); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if setting.Service.AllowOnlyInternalRegistration {
+			return nil, err
+		}
+		username := assertion.NameID
+		if source.AttributeUsername != "" {
+			username = assertion.Attribute(source.AttributeUsername)
+		}
+		if username == "" {
+			return nil, fmt.Errorf("the assertion has no %q attribute", source.AttributeUsername)
+		}
+		email := assertion.Attribute(source.AttributeEmail)
+		if email == "" {
+			email = fmt.Sprintf("%s@localhost.local", username)
+		}
+		user = &user_model.User{
+			LowerName:   strings.ToLower(username),
+			Name:        username,
+			FullName:    assertion.Attribute(source.AttributeFullName),
+			Email:       email,
+			LoginType:   source.authSource.Type,
+			LoginSource: source.authSource.ID,
+			LoginName:   assertion.NameID,
+			IsAdmin:     isAdmin.ValueOrDefault(false),
+		}
+		overwriteDefault := &user_model.CreateUserOverwriteOptions{
+			IsRestricted: isRestricted,
+			IsActive:     optional.Some(true),
+		}
+		if err := user_model.CreateUser(ctx, user, overwriteDefault); err != nil {
+			return nil, err
+		}
+	}
+
+	if source.GroupTeamMap != "" || source.GroupTeamMapRemoval {
+		groupTeamMapping, err := auth_module.UnmarshalGroupTeamMapping(source.GroupTeamMap)
+		if err != nil {
+			return user, err
+		}
+		if err := source_service.SyncGroupsToTeams(ctx, user, groups, groupTeamMapping, source.GroupTeamMapRemoval); err != nil {
+			return user, err
+		}
+	}
+
+	return user, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 
+	actions_model "code.gitea.io/gitea/models/actions"
 	"code.gitea.io/gitea/models/organization"
 	packages_model "code.gitea.io/gitea/models/packages"
 	"code.gitea.io/gitea/models/perm"
@@ -101,7 +102,10 @@ func determineAccessMode(ctx *Base, pkg *Package, doer *user_model.User) (perm.A
 		return perm.AccessModeNone, nil
 	}
 
-	// TODO: ActionUser permission check
+	if doer != nil && doer.IsActions() {
+		return determineActionsAccessMode(ctx, pkg)
+	}
+
 	accessMode := perm.AccessModeNone
 	if pkg.Owner.IsOrganization() {
 		org := organization.OrgFromUser(pkg.Owner)
@@ -163,3 +167,32 @@ func PackageContexter() func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// determineActionsAccessMode grants an Actions task write access to the packages of the owner of its repository.
+// Tasks of pull requests from forks can only read.
+func determineActionsAccessMode(ctx *Base, pkg *Package) (perm.AccessMode, error) {
+	accessMode := perm.AccessModeNone
+	if pkg.Owner.Visibility == structs.VisibleTypePublic {
+		accessMode = perm.AccessModeRead
+	}
+
+	if ctx.Data["IsActionsToken"] != true {
+		return accessMode, nil
+	}
+	taskID, ok := ctx.Data["ActionsTaskID"].(int64)
+	if !ok {
+		return accessMode, nil
+	}
+
+	task, err := actions_model.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return accessMode, err
+	}
+	if task.OwnerID != pkg.Owner.ID {
+		return accessMode, nil
+	}
+	if task.IsForkPullRequest {
+		return perm.AccessModeRead, nil
+	}
+	return perm.AccessModeWrite, nil
+}
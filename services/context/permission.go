@@ -10,6 +10,7 @@ import (
 	repo_model "code.gitea.io/gitea/models/repo"
 	"code.gitea.io/gitea/models/unit"
 	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/web/middleware"
 )
 
 // RequireRepoAdmin returns a middleware for requiring repository admin permission
@@ -111,12 +112,24 @@ func RequireRepoReaderOr(unitTypes ...unit.Type) func(ctx *Context) {
 	}
 }
 
+// IsRepoAllowedByToken returns whether the personal access token the request is authenticated with, if any,
+// is allowed to access a repository
+func IsRepoAllowedByToken(data middleware.ContextData, repo *repo_model.Repository) bool {
+	t, ok := data["ApiToken"].(*auth_model.AccessToken)
+	return !ok || t.AllowsRepo(repo.ID, repo.OwnerID)
+}
+
 // CheckRepoScopedToken check whether personal access token has repo scope
 func CheckRepoScopedToken(ctx *Context, repo *repo_model.Repository, level auth_model.AccessTokenScopeLevel) {
 	if !ctx.IsBasicAuth || ctx.Data["IsApiToken"] != true {
 		return
 	}
 
+	if !IsRepoAllowedByToken(ctx.Data, repo) {
+		ctx.Error(http.StatusForbidden)
+		return
+	}
+
 	scope, ok := ctx.Data["ApiTokenScope"].(auth_model.AccessTokenScope)
 	if ok { // it's a personal access token but not oauth2 token
 		var scopeMatched bool
@@ -370,6 +370,11 @@ func repoAssignment(ctx *Context, repo *repo_model.Repository) {
 		return
 	}
 
+	if !IsRepoAllowedByToken(ctx.Data, repo) {
+		ctx.NotFound("token not allowed", nil)
+		return
+	}
+
 	ctx.Repo.Permission, err = access_model.GetUserRepoPermission(ctx, repo, ctx.Doer)
 	if err != nil {
 		ctx.ServerError("GetUserRepoPermission", err)
@@ -0,0 +1,27 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package convert
+
+import (
+	audit_model "code.gitea.io/gitea/models/audit"
+	api "code.gitea.io/gitea/modules/structs"
+)
+
+// ToAuditEvent converts an audit event to its API format
+func ToAuditEvent(e *audit_model.Event) *api.AuditEvent {
+	return &api.AuditEvent{
+		ID:         e.ID,
+		Action:     string(e.Action),
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		OwnerID:    e.OwnerID,
+		RepoID:     e.RepoID,
+		TargetType: string(e.TargetType),
+		TargetID:   e.TargetID,
+		TargetName: e.TargetName,
+		Message:    e.Message,
+		IPAddress:  e.IPAddress,
+		Created:    e.CreatedUnix.AsTime(),
+	}
+}
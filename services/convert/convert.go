@@ -461,6 +461,41 @@ func ToOAuth2Application(app *auth.OAuth2Application) *api.OAuth2Application {
 	}
 }
 
+// ToAccessToken convert an auth.AccessToken to api.AccessToken, without the token itself
+func ToAccessToken(ctx context.Context, t *auth.AccessToken) (*api.AccessToken, error) {
+	apiToken := &api.AccessToken{
+		ID:             t.ID,
+		Name:           t.Name,
+		TokenLastEight: t.TokenLastEight,
+		Scopes:         t.Scope.StringSlice(),
+	}
+	if t.ExpiresUnix != 0 {
+		apiToken.ExpiresAt = t.ExpiresUnix.AsTimePtr()
+	}
+	if t.HasUsed {
+		apiToken.LastUsedAt = t.UpdatedUnix.AsTimePtr()
+	}
+	if t.OrgID != 0 {
+		org, err := user_model.GetUserByID(ctx, t.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		apiToken.Organization = org.Name
+	}
+	if len(t.RepoIDs) > 0 {
+		repos, err := repo_model.GetRepositoriesMapByIDs(ctx, t.RepoIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range t.RepoIDs {
+			if repo, ok := repos[id]; ok {
+				apiToken.Repositories = append(apiToken.Repositories, repo.FullName())
+			}
+		}
+	}
+	return apiToken, nil
+}
+
 // ToLFSLock convert a LFSLock to api.LFSLock
 func ToLFSLock(ctx context.Context, l *git_model.LFSLock) *api.LFSLock {
 	u, err := user_model.GetUserByID(ctx, l.OwnerID)
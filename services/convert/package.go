@@ -5,6 +5,7 @@ package convert
 
 import (
 	"context"
+	"net/url"
 
 	"code.gitea.io/gitea/models/packages"
 	access_model "code.gitea.io/gitea/models/perm/access"
@@ -51,3 +52,37 @@ func ToPackageFile(pfd *packages.PackageFileDescriptor) *api.PackageFile {
 		HashSHA512: pfd.Blob.HashSHA512,
 	}
 }
+
+// ToPackageVulnerability converts packages.PackageVulnerability to api.PackageVulnerability
+func ToPackageVulnerability(pv *packages.PackageVulnerability) *api.PackageVulnerability {
+	return &api.PackageVulnerability{
+		AdvisoryID:     pv.AdvisoryID,
+		Summary:        pv.Summary,
+		Severity:       pv.Severity,
+		Ecosystem:      pv.Ecosystem,
+		PackageName:    pv.PackageName,
+		PackageVersion: pv.PackageVersion,
+		URL:            "https://osv.dev/vulnerability/" + url.PathEscape(pv.AdvisoryID),
+	}
+}
+
+// ToPackageAttestation convert a packages.PackageAttestation to api.PackageAttestation
+func ToPackageAttestation(pa *packages.PackageAttestation) *api.PackageAttestation {
+	return &api.PackageAttestation{
+		ID:               pa.ID,
+		Format:           pa.Format,
+		PredicateType:    pa.PredicateType,
+		Source:           pa.Source,
+		Verified:         pa.IsVerified(),
+		Signed:           pa.IsSigned(),
+		SignerIdentity:   pa.SignerIdentity,
+		SignerIssuer:     pa.SignerIssuer,
+		BuilderID:        pa.BuilderID,
+		SourceRepository: pa.SourceRepository,
+		SourceRef:        pa.SourceRef,
+		CommitSHA:        pa.CommitSHA,
+		Workflow:         pa.Workflow,
+		RunURL:           pa.RunURL,
+		CreatedAt:        pa.CreatedUnix.AsTime(),
+	}
+}
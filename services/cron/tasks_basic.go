@@ -14,9 +14,11 @@ import (
 	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/services/auth"
+	federation_service "code.gitea.io/gitea/services/federation"
 	"code.gitea.io/gitea/services/migrations"
 	mirror_service "code.gitea.io/gitea/services/mirror"
 	packages_cleanup_service "code.gitea.io/gitea/services/packages/cleanup"
+	packages_vulnerability_service "code.gitea.io/gitea/services/packages/vulnerability"
 	repo_service "code.gitea.io/gitea/services/repository"
 	archiver_service "code.gitea.io/gitea/services/repository/archiver"
 )
@@ -156,6 +158,26 @@ func registerCleanupPackages() {
 	})
 }
 
+func registerScanPackageVulnerabilities() {
+	RegisterTaskFatal("scan_package_vulnerabilities", &BaseConfig{
+		Enabled:    true,
+		RunAtStart: false,
+		Schedule:   "@midnight",
+	}, func(ctx context.Context, _ *user_model.User, _ Config) error {
+		return packages_vulnerability_service.ScanAll(ctx)
+	})
+}
+
+func registerRetryFederationDeliveries() {
+	RegisterTaskFatal("retry_federation_deliveries", &BaseConfig{
+		Enabled:    true,
+		RunAtStart: true,
+		Schedule:   "@every 1m",
+	}, func(ctx context.Context, _ *user_model.User, _ Config) error {
+		return federation_service.EnqueueDueDeliveries(ctx)
+	})
+}
+
 func initBasicTasks() {
 	if setting.Mirror.Enabled {
 		registerUpdateMirrorTask()
@@ -169,7 +191,11 @@ func initBasicTasks() {
 		registerUpdateMigrationPosterID()
 	}
 	registerCleanupHookTaskTable()
+	if setting.Federation.Enabled {
+		registerRetryFederationDeliveries()
+	}
 	if setting.Packages.Enabled {
 		registerCleanupPackages()
+		registerScanPackageVulnerabilities()
 	}
 }
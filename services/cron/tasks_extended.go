@@ -15,6 +15,7 @@ import (
 	issue_indexer "code.gitea.io/gitea/modules/indexer/issues"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/updatechecker"
+	auth_service "code.gitea.io/gitea/services/auth"
 	repo_service "code.gitea.io/gitea/services/repository"
 	archiver_service "code.gitea.io/gitea/services/repository/archiver"
 	user_service "code.gitea.io/gitea/services/user"
@@ -65,6 +66,17 @@ func registerGarbageCollectRepositories() {
 	})
 }
 
+func registerMaintainRepositories() {
+	// the incremental maintenance replaces git_gc_repos, both should not be enabled
+	RegisterTaskFatal("git_maintenance_repos", &BaseConfig{
+		Enabled:    false,
+		RunAtStart: false,
+		Schedule:   "@every 24h",
+	}, func(ctx context.Context, _ *user_model.User, _ Config) error {
+		return repo_service.MaintainRepos(ctx, repo_service.DefaultMaintenanceOptions())
+	})
+}
+
 func registerRewriteAllPublicKeys() {
 	RegisterTaskFatal("resync_all_sshkeys", &BaseConfig{
 		Enabled:    false,
@@ -225,10 +237,29 @@ func registerRebuildIssueIndexer() {
 	})
 }
 
+func registerNotifyExpiringAccessTokens() {
+	type NotifyExpiringAccessTokensConfig struct {
+		BaseConfig
+		NotifyBefore time.Duration
+	}
+	RegisterTaskFatal("notify_expiring_access_tokens", &NotifyExpiringAccessTokensConfig{
+		BaseConfig: BaseConfig{
+			Enabled:    true,
+			RunAtStart: false,
+			Schedule:   "@every 24h",
+		},
+		NotifyBefore: 7 * 24 * time.Hour,
+	}, func(ctx context.Context, _ *user_model.User, config Config) error {
+		notifyConfig := config.(*NotifyExpiringAccessTokensConfig)
+		return auth_service.NotifyExpiringAccessTokens(ctx, notifyConfig.NotifyBefore)
+	})
+}
+
 func initExtendedTasks() {
 	registerDeleteInactiveUsers()
 	registerDeleteRepositoryArchives()
 	registerGarbageCollectRepositories()
+	registerMaintainRepositories()
 	registerRewriteAllPublicKeys()
 	registerRewriteAllPrincipalKeys()
 	registerRepositoryUpdateHook()
@@ -240,4 +271,5 @@ func initExtendedTasks() {
 	registerDeleteOldSystemNotices()
 	registerGCLFS()
 	registerRebuildIssueIndexer()
+	registerNotifyExpiringAccessTokens()
 }
@@ -118,7 +118,7 @@ func (o *user) Delete(ctx context.Context) {
 	node := o.GetNode()
 	o.Trace("%s", node.GetID())
 
-	if err := user_service.DeleteUser(ctx, o.forgejoUser, true); err != nil {
+	if err := user_service.DeleteUser(ctx, nil, o.forgejoUser, true); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,357 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"code.gitea.io/gitea/models/forgefed"
+	"code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/process"
+	"code.gitea.io/gitea/modules/queue"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/go-ap/jsonld"
+)
+
+// deliveryQueue posts the persisted deliveries to the remote inboxes
+var deliveryQueue *queue.WorkerPoolQueue[int64]
+
+func initDeliveryQueue() error {
+	deliveryQueue = queue.CreateUniqueQueue(graceful.GetManager().ShutdownContext(), "federation_delivery", deliveryHandler)
+	if deliveryQueue == nil {
+		return fmt.Errorf("unable to create federation_delivery queue")
+	}
+	go graceful.GetManager().RunWithCancel(deliveryQueue)
+
+	return nil
+}
+
+// enqueueDelivery queues a persisted delivery, deliveries not queued are picked up by the retry cron task
+func enqueueDelivery(id int64) {
+	if deliveryQueue == nil {
+		return
+	}
+	if err := deliveryQueue.Push(id); err != nil && err != queue.ErrAlreadyInQueue {
+		log.Error("Unable to push delivery %d to the queue: %v", id, err)
+	}
+}
+
+func deliveryHandler(items ...int64) []int64 {
+	ctx := graceful.GetManager().HammerContext()
+
+	for _, id := range items {
+		if err := attemptDelivery(ctx, id); err != nil {
+			log.Error("Delivery %d failed: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// deliveryError is returned when a remote inbox did not accept an activity
+type deliveryError struct {
+	InboxURI   string
+	StatusCode int
+}
+
+func (err deliveryError) Error() string {
+	return fmt.Sprintf("delivery to %s failed with status %d", err.InboxURI, err.StatusCode)
+}
+
+// isPermanent is true if the inbox refused the activity, retrying won't help
+func (err deliveryError) isPermanent() bool {
+	return err.StatusCode >= 400 && err.StatusCode < 500 &&
+		err.StatusCode != http.StatusRequestTimeout && err.StatusCode != http.StatusTooManyRequests
+}
+
+// Deliver persists an activity signed by the signer for each of the inboxes and queues it.
+// Inboxes of a host advertising a shared inbox receive the activity once.
+func Deliver(ctx context.Context, signer *user.User, activity any, inboxes ...string) error {
+	if !setting.Federation.Enabled || len(inboxes) == 0 {
+		return nil
+	}
+
+	payload, err := jsonld.WithContext(jsonld.IRI(ap.ActivityBaseURI)).Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	targets, err := batchInboxes(ctx, inboxes)
+	if err != nil {
+		return err
+	}
+
+	now := timeutil.TimeStampNow()
+	for _, target := range targets {
+		d := &forgefed.Delivery{
+			FederationHostID: target.federationHostID,
+			InboxURI:         target.inboxURI,
+			SignerID:         signer.ID,
+			Payload:          string(payload),
+			NextAttempt:      now,
+		}
+		if err := forgefed.CreateDelivery(ctx, d); err != nil {
+			return err
+		}
+		enqueueDelivery(d.ID)
+	}
+	return nil
+}
+
+type deliveryTarget struct {
+	federationHostID int64
+	inboxURI         string
+}
+
+// batchInboxes replaces the inboxes of a host by its shared inbox if several of them receive the same activity
+func batchInboxes(ctx context.Context, inboxes []string) ([]deliveryTarget, error) {
+	hosts := make(map[string]*forgefed.FederationHost)
+	inboxesByHost := make(map[string][]string)
+	hostOrder := make([]string, 0, len(inboxes))
+	for _, inbox := range inboxes {
+		inboxURL, err := url.Parse(inbox)
+		if err != nil {
+			return nil, err
+		}
+		hostname := inboxURL.Hostname()
+		if _, ok := inboxesByHost[hostname]; !ok {
+			host, err := forgefed.FindFederationHostByFqdn(ctx, hostname)
+			if err != nil {
+				return nil, err
+			}
+			hosts[hostname] = host
+			hostOrder = append(hostOrder, hostname)
+		}
+		inboxesByHost[hostname] = append(inboxesByHost[hostname], inbox)
+	}
+
+	targets := make([]deliveryTarget, 0, len(inboxes))
+	for _, hostname := range hostOrder {
+		host := hosts[hostname]
+		if (host != nil && host.IsBlocked()) || (host == nil && setting.Federation.AllowlistMode) {
+			log.Debug("Federation: not delivering to blocked host %s", hostname)
+			continue
+		}
+		var hostID int64
+		if host != nil {
+			hostID = host.ID
+		}
+
+		hostInboxes := inboxesByHost[hostname]
+		if host != nil && host.SharedInboxURI != "" && len(hostInboxes) > 1 && validateActorEndpoint(host.HostFqdn, host.SharedInboxURI) == nil {
+			targets = append(targets, deliveryTarget{federationHostID: hostID, inboxURI: host.SharedInboxURI})
+			continue
+		}
+		seen := make(map[string]bool, len(hostInboxes))
+		for _, inbox := range hostInboxes {
+			if seen[inbox] {
+				continue
+			}
+			seen[inbox] = true
+			targets = append(targets, deliveryTarget{federationHostID: hostID, inboxURI: inbox})
+		}
+	}
+	return targets, nil
+}
+
+// attemptDelivery posts a delivery, backing off its host and rescheduling it on failure
+func attemptDelivery(ctx context.Context, id int64) error {
+	d, err := forgefed.GetDelivery(ctx, id)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	now := timeutil.TimeStampNow()
+	if d.NextAttempt > now {
+		return nil
+	}
+
+	var host *forgefed.FederationHost
+	if d.FederationHostID != 0 {
+		if host, err = forgefed.GetFederationHost(ctx, d.FederationHostID); err != nil {
+			return err
+		}
+		if next := host.NextDeliveryAllowed(); next > now {
+			d.NextAttempt = next
+			return forgefed.UpdateDeliveryAttempt(ctx, d)
+		}
+	}
+
+	signer, err := user.GetPossibleUserByID(ctx, d.SignerID)
+	if err != nil {
+		if user.IsErrUserNotExist(err) {
+			log.Warn("Dropping delivery %d to %s: signer %d does not exist", d.ID, d.InboxURI, d.SignerID)
+			return forgefed.DeleteDelivery(ctx, d.ID)
+		}
+		return err
+	}
+	client, err := newSignerClient(ctx, signer)
+	if err != nil {
+		return err
+	}
+
+	postErr := postPayload(client, d.InboxURI, []byte(d.Payload))
+
+	var refused deliveryError
+	isRefused := errors.As(postErr, &refused)
+	if host != nil {
+		// a host answering with a client error is up
+		if postErr == nil || isRefused && refused.isPermanent() {
+			host.RecordDeliverySuccess(now)
+		} else {
+			host.RecordDeliveryFailure(now)
+		}
+		if err := forgefed.UpdateFederationHostHealth(ctx, host); err != nil {
+			log.Error("UpdateFederationHostHealth: %v", err)
+		}
+	}
+
+	if postErr == nil {
+		return forgefed.DeleteDelivery(ctx, d.ID)
+	}
+
+	d.RecordFailure(now, postErr)
+	if isRefused && refused.isPermanent() || d.Attempts >= setting.Federation.MaxDeliveryAttempts {
+		log.Warn("Giving up delivery %d to %s after %d attempts: %v", d.ID, d.InboxURI, d.Attempts, postErr)
+		return forgefed.DeleteDelivery(ctx, d.ID)
+	}
+	log.Info("Delivery %d to %s failed, retrying after %v: %v", d.ID, d.InboxURI, d.NextAttempt, postErr)
+	return forgefed.UpdateDeliveryAttempt(ctx, d)
+}
+
+// EnqueueDueDeliveries queues the deliveries whose next attempt is due, e.g. after a failure or a restart
+func EnqueueDueDeliveries(ctx context.Context) error {
+	ctx, _, finished := process.GetManager().AddContext(ctx, "Federation: Enqueue due deliveries")
+	defer finished()
+
+	now := timeutil.TimeStampNow()
+	lowerID := int64(0)
+	for {
+		ids, err := forgefed.FindDueDeliveryIDs(ctx, now, lowerID, 100)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		lowerID = ids[len(ids)-1]
+
+		for _, id := range ids {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			enqueueDelivery(id)
+		}
+	}
+}
+
+// recordSharedInbox remembers the shared inbox advertised by a person of a federation host
+func recordSharedInbox(ctx context.Context, federationHostID int64, person *fm.ForgePerson) {
+	if person.Endpoints == nil || person.Endpoints.SharedInbox == nil {
+		return
+	}
+	host, err := forgefed.GetFederationHost(ctx, federationHostID)
+	if err != nil {
+		log.Error("GetFederationHost: %v", err)
+		return
+	}
+	sharedInbox := person.Endpoints.SharedInbox.GetLink().String()
+	if host.SharedInboxURI == sharedInbox {
+		return
+	}
+	if err := validateActorEndpoint(host.HostFqdn, sharedInbox); err != nil {
+		log.Warn("Ignoring the shared inbox of %s: %v", host.HostFqdn, err)
+		return
+	}
+	host.SharedInboxURI = sharedInbox
+	if err := forgefed.UpdateFederationHostSharedInbox(ctx, host); err != nil {
+		log.Error("UpdateFederationHostSharedInbox: %v", err)
+	}
+}
+
+// KeyID returns the id of the public key the user signs requests with
+func KeyID(signer *user.User) string {
+	if signer.ID == user.APActorUserID {
+		return user.APActorUserAPActorID() + "#main-key"
+	}
+	return signer.APActorID() + "#main-key"
+}
+
+func newSignerClient(ctx context.Context, signer *user.User) (activitypub.APClient, error) {
+	clientFactory, err := activitypub.GetClientFactory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return clientFactory.WithKeys(ctx, signer, KeyID(signer))
+}
+
+func postPayload(client activitypub.APClient, inboxURI string, payload []byte) error {
+	resp, err := client.Post(payload, inboxURI)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return deliveryError{InboxURI: inboxURI, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// RotateKey replaces the keys the user signs requests with.
+// The followers of a user are sent the new public key, the instance actor key is fetched again by remote instances.
+func RotateKey(ctx context.Context, signer *user.User) error {
+	publicKeyPem, err := activitypub.RotateKeyPair(ctx, signer)
+	if err != nil {
+		return err
+	}
+	log.Info("Rotated the ActivityPub key of %s", signer.Name)
+
+	if signer.ID == user.APActorUserID {
+		return nil
+	}
+
+	followers, err := user.FindFederatedFollowers(ctx, signer.ID)
+	if err != nil {
+		return err
+	}
+	inboxes := make([]string, 0, len(followers))
+	for _, follower := range followers {
+		if follower.InboxURI != "" {
+			inboxes = append(inboxes, follower.InboxURI)
+		}
+	}
+
+	person := ap.PersonNew(ap.IRI(signer.APActorID()))
+	person.PreferredUsername = ap.DefaultNaturalLanguageValue(signer.Name)
+	person.Inbox = ap.IRI(signer.APActorID() + "/inbox")
+	person.Outbox = ap.IRI(signer.APActorID() + "/outbox")
+	person.PublicKey.ID = ap.IRI(KeyID(signer))
+	person.PublicKey.Owner = person.ID
+	person.PublicKey.PublicKeyPem = publicKeyPem
+
+	update := ap.UpdateNew(ap.IRI(fmt.Sprintf("%s#updates/keys/%d", signer.APActorID(), timeutil.TimeStampNow())), person)
+	update.Actor = person.ID
+	update.To = ap.ItemCollection{ap.PublicNS}
+
+	return Deliver(ctx, signer, update, inboxes...)
+}
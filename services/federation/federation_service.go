@@ -5,6 +5,7 @@ package federation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -19,6 +20,7 @@ import (
 	fm "code.gitea.io/gitea/modules/forgefed"
 	"code.gitea.io/gitea/modules/log"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
 	"code.gitea.io/gitea/modules/validation"
 
 	"github.com/google/uuid"
@@ -74,6 +76,9 @@ func ProcessLikeActivity(ctx context.Context, form any, repositoryID int64) (int
 	} else {
 		user, _, err = CreateUserFromAP(ctx, actorID, federationHost.ID)
 		if err != nil {
+			if errors.Is(err, util.ErrInvalidArgument) {
+				return http.StatusNotAcceptable, "Invalid actor", err
+			}
 			return http.StatusInternalServerError, "Error creating federatedUser", err
 		}
 		log.Info("Created federatedUser from ap: %v", user)
@@ -97,29 +102,33 @@ func ProcessLikeActivity(ctx context.Context, form any, repositoryID int64) (int
 	return 0, "", nil
 }
 
-func CreateFederationHostFromAP(ctx context.Context, actorID fm.ActorID) (*forgefed.FederationHost, error) {
+func fetchNodeInfo(ctx context.Context, actorID fm.ActorID) (forgefed.NodeInfo, error) {
 	actionsUser := user.NewActionsUser()
 	clientFactory, err := activitypub.GetClientFactory(ctx)
 	if err != nil {
-		return nil, err
+		return forgefed.NodeInfo{}, err
 	}
 	client, err := clientFactory.WithKeys(ctx, actionsUser, "no idea where to get key material.")
 	if err != nil {
-		return nil, err
+		return forgefed.NodeInfo{}, err
 	}
 	body, err := client.GetBody(actorID.AsWellKnownNodeInfoURI())
 	if err != nil {
-		return nil, err
+		return forgefed.NodeInfo{}, err
 	}
 	nodeInfoWellKnown, err := forgefed.NewNodeInfoWellKnown(body)
 	if err != nil {
-		return nil, err
+		return forgefed.NodeInfo{}, err
 	}
 	body, err = client.GetBody(nodeInfoWellKnown.Href)
 	if err != nil {
-		return nil, err
+		return forgefed.NodeInfo{}, err
 	}
-	nodeInfo, err := forgefed.NewNodeInfo(body)
+	return forgefed.NewNodeInfo(body)
+}
+
+func CreateFederationHostFromAP(ctx context.Context, actorID fm.ActorID) (*forgefed.FederationHost, error) {
+	nodeInfo, err := fetchNodeInfo(ctx, actorID)
 	if err != nil {
 		return nil, err
 	}
@@ -150,36 +159,64 @@ func GetFederationHostForURI(ctx context.Context, actorURI string) (*forgefed.Fe
 			return nil, err
 		}
 		federationHost = result
+	} else if !federationHost.HasNodeInfo() {
+		// an admin set a policy for the host before it federated with us
+		nodeInfo, err := fetchNodeInfo(ctx, rawActorID)
+		if err != nil {
+			return nil, err
+		}
+		federationHost.NodeInfo = nodeInfo
+		if err := forgefed.UpdateFederationHost(ctx, federationHost); err != nil {
+			return nil, err
+		}
 	}
 	return federationHost, nil
 }
 
-func CreateUserFromAP(ctx context.Context, personID fm.PersonID, federationHostID int64) (*user.User, *user.FederatedUser, error) {
+func fetchPerson(ctx context.Context, personID fm.PersonID) (*fm.ForgePerson, error) {
 	// ToDo: Do we get a publicKeyId from server, repo or owner or repo?
 	actionsUser := user.NewActionsUser()
 	clientFactory, err := activitypub.GetClientFactory(ctx)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	client, err := clientFactory.WithKeys(ctx, actionsUser, "no idea where to get key material.")
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	body, err := client.GetBody(personID.AsURI())
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	person := fm.ForgePerson{}
 	err = person.UnmarshalJSON(body)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	if res, err := validation.IsValid(person); !res {
-		return nil, nil, err
+		return nil, err
 	}
 	log.Info("Fetched valid person:%q", person)
+	return &person, nil
+}
+
+// validateActorEndpoint checks that an endpoint advertised by a remote actor is on the host of the actor,
+// so that the actor cannot make this instance send requests to any other host
+func validateActorEndpoint(host, endpoint string) error {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Hostname() != host {
+		return util.NewInvalidArgumentErrorf("endpoint %s is not on %s", endpoint, host)
+	}
+	return nil
+}
+
+func CreateUserFromAP(ctx context.Context, personID fm.PersonID, federationHostID int64) (*user.User, *user.FederatedUser, error) {
+	person, err := fetchPerson(ctx, personID)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	localFqdn, err := url.ParseRequestURI(setting.AppURL)
 	if err != nil {
@@ -213,10 +250,18 @@ func CreateUserFromAP(ctx context.Context, personID fm.PersonID, federationHostI
 		ExternalID:       personID.ID,
 		FederationHostID: federationHostID,
 	}
+	if person.Inbox != nil {
+		inboxURI := person.Inbox.GetLink().String()
+		if err := validateActorEndpoint(personID.Host, inboxURI); err != nil {
+			return nil, nil, err
+		}
+		federatedUser.InboxURI = inboxURI
+	}
 	err = user.CreateFederatedUser(ctx, &newUser, &federatedUser)
 	if err != nil {
 		return nil, nil, err
 	}
+	recordSharedInbox(ctx, federationHostID, person)
 	log.Info("Created federatedUser:%q", federatedUser)
 
 	return &newUser, &federatedUser, nil
@@ -270,25 +315,11 @@ func SendLikeActivities(ctx context.Context, doer user.User, repoID int64) error
 		likeActivityList = append(likeActivityList, likeActivity)
 	}
 
-	apclientFactory, err := activitypub.GetClientFactory(ctx)
-	if err != nil {
-		return err
-	}
-	apclient, err := apclientFactory.WithKeys(ctx, &doer, doer.APActorID())
-	if err != nil {
-		return err
-	}
 	for i, activity := range likeActivityList {
 		activity.StartTime = activity.StartTime.Add(time.Duration(i) * time.Second)
-		json, err := activity.MarshalJSON()
-		if err != nil {
+		if err := Deliver(ctx, &doer, activity, fmt.Sprintf("%v/inbox/", activity.Object)); err != nil {
 			return err
 		}
-
-		_, err = apclient.Post(json, fmt.Sprintf("%v/inbox/", activity.Object))
-		if err != nil {
-			log.Error("error %v while sending activity: %q", err, activity)
-		}
 	}
 
 	return nil
@@ -0,0 +1,84 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"net/url"
+
+	"code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// RemoteRepository is a repository hosted on another federated instance
+type RemoteRepository struct {
+	ID               fm.RepositoryID
+	FederationHostID int64
+	Name             string
+	Description      string
+	InboxURI         string
+	CloneURI         string
+}
+
+// FetchRemoteRepository fetches the Repository actor of another instance.
+// The repository has to be clonable over http(s) from the instance hosting it.
+func FetchRemoteRepository(ctx context.Context, uri string) (*RemoteRepository, error) {
+	federationHost, err := GetFederationHostForURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if federationHost.IsBlocked() {
+		return nil, util.NewPermissionDeniedErrorf("federation with %s is blocked", federationHost.HostFqdn)
+	}
+	repositoryID, err := fm.NewRepositoryID(uri, string(federationHost.NodeInfo.SoftwareName))
+	if err != nil {
+		return nil, util.NewInvalidArgumentErrorf("invalid repository %q: %v", uri, err)
+	}
+
+	actionsUser := user.NewActionsUser()
+	clientFactory, err := activitypub.GetClientFactory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientFactory.WithKeys(ctx, actionsUser, "no idea where to get key material.")
+	if err != nil {
+		return nil, err
+	}
+	body, err := client.GetBody(repositoryID.AsURI())
+	if err != nil {
+		return nil, err
+	}
+	repository := fm.Repository{}
+	if err := repository.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+
+	if repository.CloneURI == nil {
+		return nil, util.NewInvalidArgumentErrorf("repository %s cannot be cloned", uri)
+	}
+	cloneURI := repository.CloneURI.GetLink().String()
+	cloneURL, err := url.Parse(cloneURI)
+	if err != nil || (cloneURL.Scheme != "http" && cloneURL.Scheme != "https") || cloneURL.Hostname() != repositoryID.Host {
+		return nil, util.NewInvalidArgumentErrorf("repository %s is not cloned from %s", uri, cloneURI)
+	}
+
+	inboxURI := repositoryID.AsURI() + "/inbox"
+	if repository.Inbox != nil {
+		inboxURI = repository.Inbox.GetLink().String()
+		if err := validateActorEndpoint(repositoryID.Host, inboxURI); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RemoteRepository{
+		ID:               repositoryID,
+		FederationHostID: federationHost.ID,
+		Name:             repository.Name.String(),
+		Description:      repository.Summary.String(),
+		InboxURI:         inboxURI,
+		CloneURI:         cloneURI,
+	}, nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unit"
+	"code.gitea.io/gitea/models/user"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/validation"
+	issue_service "code.gitea.io/gitea/services/issue"
+
+	ap "github.com/go-ap/activitypub"
+)
+
+// ProcessRepositoryInbox handles an activity sent to the inbox of a repository.
+// signerID is the actor owning the key the request was signed with.
+// Like activities star the repository, Create activities of Tickets and Notes open and comment issues,
+// Offer activities of merge requests open pull requests.
+func ProcessRepositoryInbox(ctx context.Context, repository *repo_model.Repository, signerID string, body []byte) (int, string, error) {
+	activity := ap.Activity{}
+	if err := activity.UnmarshalJSON(body); err != nil {
+		return http.StatusBadRequest, "Invalid activity", err
+	}
+	if activity.Actor == nil {
+		return http.StatusNotAcceptable, "Invalid activity", fmt.Errorf("activity has no actor")
+	}
+	if actorURI := activity.Actor.GetLink().String(); actorURI != signerID {
+		return http.StatusForbidden, "Invalid actor", fmt.Errorf("activity of %q was signed by %q", actorURI, signerID)
+	}
+
+	switch activity.Type {
+	case ap.LikeType:
+		like := &fm.ForgeLike{}
+		if err := like.UnmarshalJSON(body); err != nil {
+			return http.StatusBadRequest, "Invalid activity", err
+		}
+		return ProcessLikeActivity(ctx, like, repository.ID)
+	case ap.CreateType:
+		create := &fm.ForgeCreate{}
+		if err := create.UnmarshalJSON(body); err != nil {
+			return http.StatusBadRequest, "Invalid activity", err
+		}
+		return ProcessCreateActivity(ctx, create, repository)
+	case ap.OfferType:
+		offer := &fm.ForgeOffer{}
+		if err := offer.UnmarshalJSON(body); err != nil {
+			return http.StatusBadRequest, "Invalid activity", err
+		}
+		return ProcessOfferActivity(ctx, offer, repository)
+	default:
+		return http.StatusNotAcceptable, "Unsupported activity", fmt.Errorf("unsupported activity type %q", activity.Type)
+	}
+}
+
+// ProcessCreateActivity opens an issue for a created Ticket or comments on an issue for a created Note.
+// The remote author is represented by a federated user.
+func ProcessCreateActivity(ctx context.Context, create *fm.ForgeCreate, repository *repo_model.Repository) (int, string, error) {
+	if res, err := validation.IsValid(create); !res {
+		return http.StatusNotAcceptable, "Invalid activity", err
+	}
+
+	if repository.IsPrivate || repository.IsArchived {
+		return http.StatusForbidden, "Repository not writable", fmt.Errorf("repository %d does not accept issues", repository.ID)
+	}
+	if !repository.UnitEnabled(ctx, unit.TypeIssues) {
+		return http.StatusForbidden, "Issues disabled", fmt.Errorf("issues of repository %d are disabled", repository.ID)
+	}
+
+	// tickets and notes are found again by their id, an actor can only create them on its own host
+	actorURI := create.Actor.GetLink().String()
+	if objectID := create.Object.GetID().String(); !isOnHostOf(objectID, actorURI) {
+		return http.StatusNotAcceptable, "Invalid object", fmt.Errorf("object %s is not hosted by %s", objectID, actorURI)
+	}
+
+	if status, title, err := refuseSilencedHost(ctx, actorURI); err != nil {
+		return status, title, err
+	}
+
+	remoteUser, federatedUser, err := getOrCreateFederatedUser(ctx, actorURI)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			return http.StatusNotAcceptable, "Invalid actor", err
+		}
+		return http.StatusInternalServerError, "Error getting federatedUser", err
+	}
+
+	if ticket := create.Ticket(); ticket != nil {
+		return createIssueFromTicket(ctx, repository, remoteUser, federatedUser, ticket)
+	}
+	return createCommentFromNote(ctx, repository, remoteUser, federatedUser, create.Note())
+}
+
+// isOnHostOf tells whether an object id is on the same host as an actor
+func isOnHostOf(objectID, actorURI string) bool {
+	actorURL, err := url.Parse(actorURI)
+	if err != nil {
+		return false
+	}
+	objectURL, err := url.Parse(objectID)
+	return err == nil && objectURL.Host != "" && objectURL.Host == actorURL.Host
+}
+
+// refuseSilencedHost refuses content created by actors of silenced federation hosts
+func refuseSilencedHost(ctx context.Context, actorURI string) (int, string, error) {
+	federationHost, err := GetFederationHostForURI(ctx, actorURI)
+	if err != nil {
+		return http.StatusInternalServerError, "Wrong FederationHost", err
+	}
+	if federationHost.IsSilenced() {
+		return http.StatusForbidden, "Instance is silenced", fmt.Errorf("content of %s is refused", federationHost.HostFqdn)
+	}
+	return 0, "", nil
+}
+
+func createIssueFromTicket(ctx context.Context, repository *repo_model.Repository, remoteUser *user.User, federatedUser *user.FederatedUser, ticket *fm.ForgeTicket) (int, string, error) {
+	if ticket.Context.GetLink().String() != repository.APActorID() {
+		return http.StatusNotAcceptable, "Invalid context", fmt.Errorf("ticket is not addressed to %s", repository.APActorID())
+	}
+
+	// the same activity may be delivered several times
+	if _, err := issues_model.GetFederatedIssueByObjectURI(ctx, ticket.ID.String()); err == nil {
+		return 0, "", nil
+	}
+
+	issue := &issues_model.Issue{
+		RepoID:   repository.ID,
+		Repo:     repository,
+		Title:    ticket.Title(),
+		PosterID: remoteUser.ID,
+		Poster:   remoteUser,
+		Content:  fm.Body(&ticket.Object),
+	}
+	if err := issue_service.NewIssue(ctx, repository, issue, nil, nil, nil); err != nil {
+		if errors.Is(err, user.ErrBlockedByUser) {
+			return http.StatusForbidden, "Blocked", err
+		}
+		return http.StatusInternalServerError, "Error creating issue", err
+	}
+
+	if err := issues_model.CreateFederatedIssue(ctx, &issues_model.FederatedIssue{
+		IssueID:          issue.ID,
+		FederationHostID: federatedUser.FederationHostID,
+		ObjectURI:        ticket.ID.String(),
+	}); err != nil {
+		return http.StatusInternalServerError, "Error storing federated issue", err
+	}
+	log.Info("Created issue %s#%d from ticket %s", repository.FullName(), issue.Index, ticket.ID)
+
+	return 0, "", nil
+}
+
+func createCommentFromNote(ctx context.Context, repository *repo_model.Repository, remoteUser *user.User, federatedUser *user.FederatedUser, note *ap.Object) (int, string, error) {
+	if _, err := issues_model.GetFederatedIssueByObjectURI(ctx, note.ID.String()); err == nil {
+		return 0, "", nil
+	}
+
+	ticketURI := note.Context
+	if note.InReplyTo != nil {
+		ticketURI = note.InReplyTo
+	}
+	issue, err := getIssueByTicketURI(ctx, repository, ticketURI.GetLink().String())
+	if err != nil {
+		return http.StatusNotFound, "Unknown ticket", err
+	}
+	if issue.IsLocked {
+		return http.StatusForbidden, "Issue locked", fmt.Errorf("issue %d is locked", issue.ID)
+	}
+
+	comment, err := issue_service.CreateIssueComment(ctx, remoteUser, repository, issue, fm.Body(note), nil)
+	if err != nil {
+		if errors.Is(err, user.ErrBlockedByUser) {
+			return http.StatusForbidden, "Blocked", err
+		}
+		return http.StatusInternalServerError, "Error creating comment", err
+	}
+
+	if err := issues_model.CreateFederatedIssue(ctx, &issues_model.FederatedIssue{
+		IssueID:          issue.ID,
+		CommentID:        comment.ID,
+		FederationHostID: federatedUser.FederationHostID,
+		ObjectURI:        note.ID.String(),
+	}); err != nil {
+		return http.StatusInternalServerError, "Error storing federated comment", err
+	}
+	log.Info("Created comment %d on issue %s#%d from note %s", comment.ID, repository.FullName(), issue.Index, note.ID)
+
+	return 0, "", nil
+}
+
+// TicketID returns the id of the Ticket representing a local issue
+func TicketID(repository *repo_model.Repository, issue *issues_model.Issue) string {
+	return fmt.Sprintf("%s/issues/%d", repository.APActorID(), issue.Index)
+}
+
+// getIssueByTicketURI finds the issue of the repository created from a remote Ticket or represented by a local Ticket
+func getIssueByTicketURI(ctx context.Context, repository *repo_model.Repository, uri string) (*issues_model.Issue, error) {
+	if index, found := strings.CutPrefix(uri, repository.APActorID()+"/issues/"); found {
+		idx, err := strconv.ParseInt(index, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return issues_model.GetIssueByIndex(ctx, repository.ID, idx)
+	}
+
+	fi, err := issues_model.GetFederatedIssueByObjectURI(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	issue, err := issues_model.GetIssueByID(ctx, fi.IssueID)
+	if err != nil {
+		return nil, err
+	}
+	if issue.RepoID != repository.ID {
+		return nil, fmt.Errorf("ticket %s does not belong to repository %d", uri, repository.ID)
+	}
+	return issue, nil
+}
+
+// IssueToTicket converts a local issue into a Ticket
+func IssueToTicket(ctx context.Context, repository *repo_model.Repository, issue *issues_model.Issue) (*fm.ForgeTicket, error) {
+	if err := issue.LoadPoster(ctx); err != nil {
+		return nil, err
+	}
+
+	ticket := &fm.ForgeTicket{Object: *ap.ObjectNew(fm.TicketType)}
+	ticket.ID = ap.IRI(TicketID(repository, issue))
+	ticket.AttributedTo = ap.IRI(issue.Poster.APActorID())
+	if issue.Poster.IsRemote() && issue.Poster.NormalizedFederatedURI != "" {
+		ticket.AttributedTo = ap.IRI(issue.Poster.NormalizedFederatedURI)
+	}
+	ticket.Context = ap.IRI(repository.APActorID())
+	ticket.Summary = ap.DefaultNaturalLanguageValue(issue.Title)
+	ticket.Content = ap.DefaultNaturalLanguageValue(html.EscapeString(issue.Content))
+	ticket.Source = ap.Source{Content: ap.DefaultNaturalLanguageValue(issue.Content), MediaType: "text/markdown"}
+	ticket.URL = ap.IRI(issue.HTMLURL())
+	ticket.Published = issue.CreatedUnix.AsTime()
+	return ticket, nil
+}
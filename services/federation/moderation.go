@@ -0,0 +1,71 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"code.gitea.io/gitea/models/forgefed"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// NormalizeHostFqdn accepts a host name or the URL of an instance and returns the host name
+func NormalizeHostFqdn(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if strings.Contains(input, "://") {
+		u, err := url.Parse(input)
+		if err != nil {
+			return "", util.NewInvalidArgumentErrorf("invalid instance URL %q", input)
+		}
+		input = u.Hostname()
+	}
+	if input == "" || strings.ContainsAny(input, "/:@ ") {
+		return "", util.NewInvalidArgumentErrorf("invalid host name %q", input)
+	}
+	return strings.ToLower(input), nil
+}
+
+// SetFederationHostPolicy sets how the instance federates with a host.
+// Hosts not federated with yet are created without node info, so they can be moderated up front.
+// Activities waiting for delivery to a host being blocked are dropped.
+func SetFederationHostPolicy(ctx context.Context, hostFqdn string, policy forgefed.FederationHostPolicy) (*forgefed.FederationHost, error) {
+	hostFqdn, err := NormalizeHostFqdn(hostFqdn)
+	if err != nil {
+		return nil, err
+	}
+	host, err := forgefed.FindFederationHostByFqdn(ctx, hostFqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case host == nil && policy == forgefed.FederationHostPolicyNone:
+		return nil, nil
+	case host == nil:
+		host = &forgefed.FederationHost{HostFqdn: hostFqdn, Policy: policy}
+		if err := forgefed.CreateFederationHost(ctx, host); err != nil {
+			return nil, err
+		}
+	case policy == forgefed.FederationHostPolicyNone && !host.HasNodeInfo():
+		// the host only existed for its policy
+		if err := forgefed.DeleteFederationHost(ctx, host.ID); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	default:
+		host.Policy = policy
+		if err := forgefed.UpdateFederationHostPolicy(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	if host.IsBlocked() {
+		if err := forgefed.DeleteDeliveriesByHost(ctx, host.ID); err != nil {
+			return nil, err
+		}
+	}
+	return host, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"fmt"
+	"html"
+
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	notify_service "code.gitea.io/gitea/services/notify"
+
+	ap "github.com/go-ap/activitypub"
+)
+
+type federationNotifier struct {
+	notify_service.NullNotifier
+}
+
+var _ notify_service.Notifier = &federationNotifier{}
+
+// Init registers the notifier and starts the delivery queue
+func Init() error {
+	notify_service.RegisterNotifier(NewNotifier())
+
+	if err := initDeliveryQueue(); err != nil {
+		return err
+	}
+	return initMergeRequestQueue()
+}
+
+// NewNotifier creates a notifier informing the home instances of remote issue authors
+func NewNotifier() notify_service.Notifier {
+	return &federationNotifier{}
+}
+
+// federatedIssueAuthor returns the Ticket an issue was created from and the inbox of its remote author
+func federatedIssueAuthor(ctx context.Context, issue *issues_model.Issue) (*issues_model.FederatedIssue, *user_model.User, string) {
+	if !setting.Federation.Enabled {
+		return nil, nil, ""
+	}
+	fi, err := issues_model.GetFederatedIssueByIssueID(ctx, issue.ID)
+	if err != nil {
+		return nil, nil, ""
+	}
+	if err := issue.LoadPoster(ctx); err != nil {
+		log.Error("LoadPoster: %v", err)
+		return nil, nil, ""
+	}
+	federatedUser, err := user_model.GetFederatedUserByUserID(ctx, issue.PosterID)
+	if err != nil {
+		log.Error("GetFederatedUserByUserID: %v", err)
+		return nil, nil, ""
+	}
+	if federatedUser == nil || federatedUser.InboxURI == "" {
+		return nil, nil, ""
+	}
+	return fi, issue.Poster, federatedUser.InboxURI
+}
+
+func (n *federationNotifier) CreateIssueComment(ctx context.Context, doer *user_model.User, repo *repo_model.Repository,
+	issue *issues_model.Issue, comment *issues_model.Comment, mentions []*user_model.User,
+) {
+	if doer.IsRemote() {
+		return
+	}
+	fi, author, inbox := federatedIssueAuthor(ctx, issue)
+	if fi == nil {
+		return
+	}
+
+	note := ap.ObjectNew(ap.NoteType)
+	note.ID = ap.IRI(fmt.Sprintf("%s#comment-%d", TicketID(repo, issue), comment.ID))
+	note.AttributedTo = ap.IRI(doer.APActorID())
+	note.Context = ap.IRI(fi.ObjectURI)
+	note.InReplyTo = ap.IRI(fi.ObjectURI)
+	note.Content = ap.DefaultNaturalLanguageValue(html.EscapeString(comment.Content))
+	note.Source = ap.Source{Content: ap.DefaultNaturalLanguageValue(comment.Content), MediaType: "text/markdown"}
+	note.URL = ap.IRI(comment.HTMLURL(ctx))
+	note.Published = comment.CreatedUnix.AsTime()
+	note.To = ap.ItemCollection{ap.IRI(author.NormalizedFederatedURI)}
+
+	create := ap.CreateNew(ap.IRI(note.ID.String()+"/create"), note)
+	create.Actor = note.AttributedTo
+	create.To = note.To
+
+	if err := Deliver(ctx, doer, create, inbox); err != nil {
+		log.Error("Delivering to %s failed: %v", inbox, err)
+	}
+}
+
+func (n *federationNotifier) IssueChangeStatus(ctx context.Context, doer *user_model.User, commitID string, issue *issues_model.Issue, actionComment *issues_model.Comment, closeOrReopen bool) {
+	if doer.IsRemote() {
+		return
+	}
+	fi, author, inbox := federatedIssueAuthor(ctx, issue)
+	if fi == nil {
+		return
+	}
+	if err := issue.LoadRepo(ctx); err != nil {
+		log.Error("LoadRepo: %v", err)
+		return
+	}
+
+	id := TicketID(issue.Repo, issue)
+	if actionComment != nil {
+		id = fmt.Sprintf("%s#comment-%d", id, actionComment.ID)
+	}
+
+	resolve := &ap.Activity{
+		ID:     ap.IRI(id + "/resolve"),
+		Type:   fm.ResolveType,
+		Actor:  ap.IRI(doer.APActorID()),
+		Object: ap.IRI(fi.ObjectURI),
+		To:     ap.ItemCollection{ap.IRI(author.NormalizedFederatedURI)},
+	}
+	var activity ap.ObjectOrLink = resolve
+	if !closeOrReopen {
+		undo := ap.UndoNew(ap.IRI(id+"/undo"), resolve)
+		undo.Actor = resolve.Actor
+		undo.To = resolve.To
+		activity = undo
+	}
+
+	if err := Deliver(ctx, doer, activity, inbox); err != nil {
+		log.Error("Delivering to %s failed: %v", inbox, err)
+	}
+}
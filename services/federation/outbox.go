@@ -0,0 +1,184 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+
+	activities_model "code.gitea.io/gitea/models/activities"
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/translation"
+	"code.gitea.io/gitea/modules/util"
+
+	ap "github.com/go-ap/activitypub"
+)
+
+// GetOutboxActions returns a page of the public actions performed by the user
+func GetOutboxActions(ctx context.Context, u *user.User, page int) (activities_model.ActionList, int64, error) {
+	return activities_model.GetFeeds(ctx, activities_model.GetFeedsOptions{
+		ListOptions: db.ListOptions{
+			Page:     page,
+			PageSize: setting.API.DefaultPagingNum,
+		},
+		RequestedUser:   u,
+		OnlyPerformedBy: true,
+	})
+}
+
+// GetOutboxAction returns a public action performed by the user
+func GetOutboxAction(ctx context.Context, u *user.User, actionID int64) (*activities_model.Action, error) {
+	act := &activities_model.Action{}
+	has, err := db.GetEngine(ctx).ID(actionID).Get(act)
+	if err != nil {
+		return nil, err
+	}
+	if !has || act.UserID != u.ID || act.ActUserID != u.ID || !isPublicAction(ctx, act) {
+		return nil, util.NewNotExistErrorf("action does not exist")
+	}
+	return act, nil
+}
+
+// isPublicAction checks if everybody can see the action, as the outbox only lists these
+func isPublicAction(ctx context.Context, act *activities_model.Action) bool {
+	if act.IsPrivate || act.IsDeleted {
+		return false
+	}
+	act.LoadActUser(ctx)
+	if act.ActUser.KeepActivityPrivate || act.ActUser.Visibility != structs.VisibleTypePublic {
+		return false
+	}
+	if act.Repo == nil {
+		repo, err := repo_model.GetRepositoryByID(ctx, act.RepoID)
+		if err != nil {
+			return false
+		}
+		act.Repo = repo
+	}
+	if act.Repo.IsPrivate {
+		return false
+	}
+	if err := act.Repo.LoadOwner(ctx); err != nil {
+		return false
+	}
+	return act.Repo.Owner.Visibility == structs.VisibleTypePublic
+}
+
+// OutboxActivityID returns the id of the activity publishing the action
+func OutboxActivityID(act *activities_model.Action) string {
+	return fmt.Sprintf("%s/outbox/%d", act.ActUser.APActorID(), act.ID)
+}
+
+// ActionToActivity converts an action into a Create activity of a Note describing it
+func ActionToActivity(ctx context.Context, act *activities_model.Action) *ap.Activity {
+	act.LoadActUser(ctx)
+	actor := act.ActUser.APActorID()
+	id := OutboxActivityID(act)
+
+	content, link := actionContent(ctx, act)
+
+	note := ap.ObjectNew(ap.NoteType)
+	note.ID = ap.IRI(id + "#note")
+	note.AttributedTo = ap.IRI(actor)
+	note.Content = ap.DefaultNaturalLanguageValue(content)
+	note.URL = ap.IRI(link)
+	note.Published = act.CreatedUnix.AsTime()
+	note.To = ap.ItemCollection{ap.PublicNS}
+
+	create := ap.CreateNew(ap.IRI(id), note)
+	create.Actor = ap.IRI(actor)
+	create.Published = note.Published
+	create.To = note.To
+	return create
+}
+
+// actionContent describes the action in HTML, like the feeds of the web UI do, and returns the link to its subject
+func actionContent(ctx context.Context, act *activities_model.Action) (string, string) {
+	locale := translation.NewLocale("en-US")
+	repoLink := act.GetRepoAbsoluteLink(ctx)
+	repoPath := act.ShortRepoPath(ctx)
+
+	link := repoLink
+	var title string
+	switch act.OpType {
+	case activities_model.ActionCreateRepo:
+		title = string(locale.Tr("action.create_repo", repoLink, repoPath))
+	case activities_model.ActionRenameRepo:
+		title = string(locale.Tr("action.rename_repo", act.GetContent(), repoLink, repoPath))
+	case activities_model.ActionTransferRepo:
+		title = string(locale.Tr("action.transfer_repo", act.GetContent(), repoLink, repoPath))
+	case activities_model.ActionCommitRepo:
+		link = repoLink + "/src/branch/" + util.PathEscapeSegments(act.GetBranch())
+		if len(act.Content) != 0 {
+			title = string(locale.Tr("action.commit_repo", repoLink, link, act.GetBranch(), repoPath))
+		} else {
+			title = string(locale.Tr("action.create_branch", repoLink, link, act.GetBranch(), repoPath))
+		}
+	case activities_model.ActionPushTag:
+		link = repoLink + "/src/tag/" + util.PathEscapeSegments(act.GetTag())
+		title = string(locale.Tr("action.push_tag", repoLink, link, act.GetTag(), repoPath))
+	case activities_model.ActionPublishRelease:
+		link = repoLink + "/releases/tag/" + util.PathEscapeSegments(act.GetBranch())
+		title = string(locale.Tr("action.publish_release", repoLink, link, repoPath, act.Content))
+	case activities_model.ActionStarRepo:
+		title = string(locale.Tr("action.starred_repo", repoLink, act.GetRepoPath(ctx)))
+	case activities_model.ActionWatchRepo:
+		title = string(locale.Tr("action.watched_repo", repoLink, act.GetRepoPath(ctx)))
+	case activities_model.ActionCreateIssue, activities_model.ActionCommentIssue,
+		activities_model.ActionCloseIssue, activities_model.ActionReopenIssue:
+		link = repoLink + "/issues/" + url.PathEscape(act.GetIssueInfos()[0])
+		title = string(locale.Tr("action."+act.OpType.String(), link, act.GetIssueInfos()[0], repoPath))
+	case activities_model.ActionCreatePullRequest, activities_model.ActionCommentPull,
+		activities_model.ActionMergePullRequest, activities_model.ActionAutoMergePullRequest,
+		activities_model.ActionClosePullRequest, activities_model.ActionReopenPullRequest,
+		activities_model.ActionApprovePullRequest, activities_model.ActionRejectPullRequest:
+		link = repoLink + "/pulls/" + url.PathEscape(act.GetIssueInfos()[0])
+		title = string(locale.Tr("action."+act.OpType.String(), link, act.GetIssueInfos()[0], repoPath))
+	default:
+		title = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(repoLink), html.EscapeString(repoPath))
+	}
+
+	actor := fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(act.ActUser.HTMLURL()), html.EscapeString(act.ActUser.GetDisplayName()))
+	return actor + " " + title, link
+}
+
+// DeliverActions publishes the public actions to the remote followers of their actioners.
+// The activities are sent in the background.
+func DeliverActions(ctx context.Context, acts ...*activities_model.Action) {
+	if !setting.Federation.Enabled {
+		return
+	}
+
+	for _, act := range acts {
+		if !isPublicAction(ctx, act) {
+			continue
+		}
+
+		followers, err := user.FindFederatedFollowers(ctx, act.ActUserID)
+		if err != nil {
+			log.Error("FindFederatedFollowers: %v", err)
+			continue
+		}
+		inboxes := make([]string, 0, len(followers))
+		for _, follower := range followers {
+			if follower.InboxURI != "" {
+				inboxes = append(inboxes, follower.InboxURI)
+			}
+		}
+		if len(inboxes) == 0 {
+			continue
+		}
+
+		if err := Deliver(ctx, act.ActUser, ActionToActivity(ctx, act), inboxes...); err != nil {
+			log.Error("Delivering action %d failed: %v", act.ID, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"code.gitea.io/gitea/models/user"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/util"
+
+	ap "github.com/go-ap/activitypub"
+)
+
+// ProcessPersonInbox handles an activity sent to the inbox of a local user.
+// signerID is the actor owning the key the request was signed with.
+// Follow, Undo of a Follow and Accept activities are processed, others are ignored.
+func ProcessPersonInbox(ctx context.Context, localUser *user.User, signerID string, body []byte) (int, string, error) {
+	activity := ap.Activity{}
+	if err := activity.UnmarshalJSON(body); err != nil {
+		return http.StatusBadRequest, "Invalid activity", err
+	}
+	if activity.Actor == nil {
+		return http.StatusNotAcceptable, "Invalid activity", fmt.Errorf("activity has no actor")
+	}
+	actorURI := activity.Actor.GetLink().String()
+	if actorURI != signerID {
+		return http.StatusForbidden, "Invalid actor", fmt.Errorf("activity of %q was signed by %q", actorURI, signerID)
+	}
+
+	switch activity.Type {
+	case ap.FollowType:
+		return processFollow(ctx, localUser, &activity)
+	case ap.UndoType:
+		return processUndoFollow(ctx, localUser, &activity)
+	case ap.AcceptType:
+		// local users don't follow remote users yet, nothing to confirm
+		log.Info("Accept of %q received by %s", actorURI, localUser.Name)
+	default:
+		log.Info("Ignoring activity of type %q received by %s", activity.Type, localUser.Name)
+	}
+	return 0, "", nil
+}
+
+func processFollow(ctx context.Context, localUser *user.User, follow *ap.Activity) (int, string, error) {
+	if follow.Object == nil || follow.Object.GetLink().String() != localUser.APActorID() {
+		return http.StatusNotAcceptable, "Invalid object", fmt.Errorf("follow is not addressed to %s", localUser.APActorID())
+	}
+
+	remoteUser, federatedUser, err := getOrCreateFederatedUser(ctx, follow.Actor.GetLink().String())
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			return http.StatusNotAcceptable, "Invalid actor", err
+		}
+		return http.StatusInternalServerError, "Error getting federatedUser", err
+	}
+
+	if err := user.FollowUser(ctx, remoteUser.ID, localUser.ID); err != nil {
+		if errors.Is(err, user.ErrBlockedByUser) {
+			return http.StatusForbidden, "Blocked", err
+		}
+		return http.StatusInternalServerError, "Error following", err
+	}
+	log.Info("%s follows %s", remoteUser.Name, localUser.Name)
+
+	accept := ap.AcceptNew(ap.IRI(fmt.Sprintf("%s#accepts/follows/%d", localUser.APActorID(), remoteUser.ID)), follow)
+	accept.Actor = ap.IRI(localUser.APActorID())
+	accept.To = ap.ItemCollection{follow.Actor.GetLink()}
+
+	if err := Deliver(ctx, localUser, accept, federatedUser.InboxURI); err != nil {
+		return http.StatusInternalServerError, "Error sending Accept", err
+	}
+
+	return 0, "", nil
+}
+
+func processUndoFollow(ctx context.Context, localUser *user.User, undo *ap.Activity) (int, string, error) {
+	follow, err := ap.ToActivity(undo.Object)
+	if err != nil || follow.Type != ap.FollowType {
+		log.Info("Ignoring Undo of an unsupported object received by %s", localUser.Name)
+		return 0, "", nil
+	}
+	if follow.Actor == nil || follow.Actor.GetLink() != undo.Actor.GetLink() {
+		return http.StatusForbidden, "Invalid actor", fmt.Errorf("undo of a follow by another actor")
+	}
+
+	federationHost, err := GetFederationHostForURI(ctx, undo.Actor.GetLink().String())
+	if err != nil {
+		return http.StatusInternalServerError, "Wrong FederationHost", err
+	}
+	personID, err := fm.NewPersonID(undo.Actor.GetLink().String(), string(federationHost.NodeInfo.SoftwareName))
+	if err != nil {
+		return http.StatusNotAcceptable, "Invalid PersonID", err
+	}
+	remoteUser, _, err := user.FindFederatedUser(ctx, personID.ID, federationHost.ID)
+	if err != nil {
+		return http.StatusInternalServerError, "Searching for user failed", err
+	}
+	if remoteUser == nil {
+		return 0, "", nil
+	}
+
+	if err := user.UnfollowUser(ctx, remoteUser.ID, localUser.ID); err != nil {
+		return http.StatusInternalServerError, "Error unfollowing", err
+	}
+	log.Info("%s unfollowed %s", remoteUser.Name, localUser.Name)
+
+	return 0, "", nil
+}
+
+// getOrCreateFederatedUser finds the user of a remote actor and makes sure its inbox is known
+func getOrCreateFederatedUser(ctx context.Context, actorURI string) (*user.User, *user.FederatedUser, error) {
+	federationHost, err := GetFederationHostForURI(ctx, actorURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	personID, err := fm.NewPersonID(actorURI, string(federationHost.NodeInfo.SoftwareName))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remoteUser, federatedUser, err := user.FindFederatedUser(ctx, personID.ID, federationHost.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if remoteUser == nil {
+		return CreateUserFromAP(ctx, personID, federationHost.ID)
+	}
+
+	// users created before the inbox was stored
+	if federatedUser.InboxURI == "" {
+		person, err := fetchPerson(ctx, personID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if person.Inbox == nil {
+			return nil, nil, fmt.Errorf("person %s has no inbox", actorURI)
+		}
+		inboxURI := person.Inbox.GetLink().String()
+		if err := validateActorEndpoint(personID.Host, inboxURI); err != nil {
+			return nil, nil, err
+		}
+		federatedUser.InboxURI = inboxURI
+		if err := user.UpdateFederatedUserInboxURI(ctx, federatedUser); err != nil {
+			return nil, nil, err
+		}
+	}
+	return remoteUser, federatedUser, nil
+}
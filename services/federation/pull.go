@@ -0,0 +1,426 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/models/forgefed"
+	git_model "code.gitea.io/gitea/models/git"
+	issues_model "code.gitea.io/gitea/models/issues"
+	quota_model "code.gitea.io/gitea/models/quota"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unit"
+	"code.gitea.io/gitea/models/user"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/queue"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/sync"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/validation"
+	pull_service "code.gitea.io/gitea/services/pull"
+
+	ap "github.com/go-ap/activitypub"
+)
+
+// mergeRequestOffer is a merge request offered from a fork on another instance, waiting for its branch to be fetched
+type mergeRequestOffer struct {
+	FederatedIssueID int64
+	RepoID           int64
+	PosterID         int64
+	CloneURI         string
+	HeadBranch       string
+	BaseBranch       string
+	Title            string
+	Content          string
+}
+
+// mergeRequestQueue fetches the branches of the offered merge requests and opens their pull requests
+var mergeRequestQueue *queue.WorkerPoolQueue[*mergeRequestOffer]
+
+// mergeRequestWorkingPool prevents a merge request from being processed concurrently
+var mergeRequestWorkingPool = sync.NewExclusivePool()
+
+// errMergeRequestTooLarge is returned when the objects fetched for a merge request exceed the size limit
+var errMergeRequestTooLarge = errors.New("merge request is too large")
+
+func initMergeRequestQueue() error {
+	mergeRequestQueue = queue.CreateUniqueQueue(graceful.GetManager().ShutdownContext(), "federation_merge_request", mergeRequestHandler)
+	if mergeRequestQueue == nil {
+		return fmt.Errorf("unable to create federation_merge_request queue")
+	}
+	go graceful.GetManager().RunWithCancel(mergeRequestQueue)
+
+	return nil
+}
+
+func mergeRequestHandler(items ...*mergeRequestOffer) []*mergeRequestOffer {
+	ctx := graceful.GetManager().ShutdownContext()
+
+	for _, offer := range items {
+		if err := processMergeRequestOffer(ctx, offer); err != nil {
+			log.Error("Unable to open a pull request for merge request %d: %v", offer.FederatedIssueID, err)
+		}
+	}
+	return nil
+}
+
+// ProcessOfferActivity accepts a merge request offered from a fork on another instance.
+// The merge request is reserved before any git work, so that an Offer delivered several times is only processed
+// once, and queued: its branch is fetched from the fork and the pull request is created like an AGit flow one.
+func ProcessOfferActivity(ctx context.Context, offer *fm.ForgeOffer, repository *repo_model.Repository) (int, string, error) {
+	if res, err := validation.IsValid(offer); !res {
+		return http.StatusNotAcceptable, "Invalid activity", err
+	}
+	mr := offer.MergeRequest()
+	if mr.Context.GetLink().String() != repository.APActorID() {
+		return http.StatusNotAcceptable, "Invalid context", fmt.Errorf("merge request is not addressed to %s", repository.APActorID())
+	}
+
+	if repository.IsPrivate || repository.IsArchived {
+		return http.StatusForbidden, "Repository not writable", fmt.Errorf("repository %d does not accept pull requests", repository.ID)
+	}
+	if !repository.UnitEnabled(ctx, unit.TypePullRequests) {
+		return http.StatusForbidden, "Pull requests disabled", fmt.Errorf("pull requests of repository %d are disabled", repository.ID)
+	}
+
+	actorURI := offer.Actor.GetLink().String()
+	if !isOnHostOf(mr.ID.String(), actorURI) {
+		return http.StatusNotAcceptable, "Invalid object", fmt.Errorf("merge request %s is not hosted by %s", mr.ID, actorURI)
+	}
+	if status, title, err := refuseSilencedHost(ctx, actorURI); err != nil {
+		return status, title, err
+	}
+
+	// the same activity may be delivered several times
+	if _, err := issues_model.GetFederatedIssueByObjectURI(ctx, mr.ID.String()); err == nil {
+		return 0, "", nil
+	}
+
+	baseBranch := mr.Target.BranchName()
+	exists, err := branchExists(ctx, repository.ID, baseBranch)
+	if err != nil {
+		return http.StatusInternalServerError, "Error checking branch", err
+	}
+	if !exists {
+		return http.StatusNotFound, "Unknown branch", fmt.Errorf("branch %q does not exist in repository %d", baseBranch, repository.ID)
+	}
+	headBranch := mr.Origin.BranchName()
+	if !git.IsValidRefPattern(headBranch) {
+		return http.StatusNotAcceptable, "Invalid branch", fmt.Errorf("invalid branch name %q", headBranch)
+	}
+
+	remoteUser, federatedUser, err := getOrCreateFederatedUser(ctx, actorURI)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidArgument) {
+			return http.StatusNotAcceptable, "Invalid actor", err
+		}
+		return http.StatusInternalServerError, "Error getting federatedUser", err
+	}
+	actorID, err := fm.NewActorID(actorURI)
+	if err != nil {
+		return http.StatusNotAcceptable, "Invalid actor", err
+	}
+
+	// the fork is hosted on the instance of its author
+	origin, err := FetchRemoteRepository(ctx, mr.Origin.Context.GetLink().String())
+	if err != nil {
+		return http.StatusNotAcceptable, "Invalid origin repository", err
+	}
+	if origin.ID.Host != actorID.Host {
+		return http.StatusNotAcceptable, "Invalid origin repository", fmt.Errorf("repository %s is not hosted by %s", origin.ID.AsURI(), actorID.Host)
+	}
+
+	fi := &issues_model.FederatedIssue{
+		FederationHostID: federatedUser.FederationHostID,
+		ObjectURI:        mr.ID.String(),
+	}
+	reserved, err := issues_model.ReserveFederatedIssue(ctx, fi)
+	if err != nil {
+		return http.StatusInternalServerError, "Error storing federated pull request", err
+	}
+	if !reserved {
+		return 0, "", nil
+	}
+
+	if err := mergeRequestQueue.Push(&mergeRequestOffer{
+		FederatedIssueID: fi.ID,
+		RepoID:           repository.ID,
+		PosterID:         remoteUser.ID,
+		CloneURI:         origin.CloneURI,
+		HeadBranch:       headBranch,
+		BaseBranch:       baseBranch,
+		Title:            mr.Title(),
+		Content:          fm.Body(&mr.Object),
+	}); err != nil && err != queue.ErrAlreadyInQueue {
+		if err := issues_model.DeleteFederatedIssueByID(ctx, fi.ID); err != nil {
+			log.Error("Unable to remove the reservation of merge request %s: %v", mr.ID, err)
+		}
+		return http.StatusInternalServerError, "Error queuing pull request", err
+	}
+
+	return 0, "", nil
+}
+
+// processMergeRequestOffer fetches the branch of a merge request and opens its pull request.
+// The reservation of the merge request is removed if the pull request cannot be opened.
+func processMergeRequestOffer(ctx context.Context, offer *mergeRequestOffer) (err error) {
+	lockKey := strconv.FormatInt(offer.FederatedIssueID, 10)
+	mergeRequestWorkingPool.CheckIn(lockKey)
+	defer mergeRequestWorkingPool.CheckOut(lockKey)
+
+	fi, err := issues_model.GetFederatedIssueByID(ctx, offer.FederatedIssueID)
+	if errors.Is(err, util.ErrNotExist) {
+		// the merge request was given up
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.IssueID != 0 {
+		// the pull request was already opened
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			if err := issues_model.DeleteFederatedIssueByID(ctx, fi.ID); err != nil {
+				log.Error("Unable to remove the reservation of merge request %s: %v", fi.ObjectURI, err)
+			}
+		}
+	}()
+
+	repository, err := repo_model.GetRepositoryByID(ctx, offer.RepoID)
+	if err != nil {
+		return err
+	}
+	poster, err := user.GetUserByID(ctx, offer.PosterID)
+	if err != nil {
+		return err
+	}
+	headBranch := poster.Name + "/" + offer.HeadBranch
+
+	ok, err := quota_model.EvaluateForUser(ctx, repository.OwnerID, quota_model.LimitSubjectSizeReposAll)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("the owner of %s is over quota", repository.FullName())
+	}
+	if _, err := issues_model.GetUnmergedPullRequest(ctx, repository.ID, repository.ID, headBranch, offer.BaseBranch, issues_model.PullRequestFlowAGit); err == nil {
+		return fmt.Errorf("a pull request from %s into %s exists already", headBranch, offer.BaseBranch)
+	} else if !issues_model.IsErrPullRequestNotExist(err) {
+		return err
+	}
+
+	headCommitID, cleanup, err := fetchMergeRequestBranch(ctx, repository, offer.CloneURI, offer.HeadBranch, setting.Federation.MaxMergeRequestSize)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	issue := &issues_model.Issue{
+		RepoID:   repository.ID,
+		Repo:     repository,
+		Title:    offer.Title,
+		PosterID: poster.ID,
+		Poster:   poster,
+		IsPull:   true,
+		Content:  offer.Content,
+	}
+	pr := &issues_model.PullRequest{
+		HeadRepoID:   repository.ID,
+		BaseRepoID:   repository.ID,
+		HeadBranch:   headBranch,
+		HeadCommitID: headCommitID,
+		BaseBranch:   offer.BaseBranch,
+		HeadRepo:     repository,
+		BaseRepo:     repository,
+		Type:         issues_model.PullRequestGitea,
+		Flow:         issues_model.PullRequestFlowAGit,
+	}
+	if err := pull_service.NewPullRequest(ctx, repository, issue, nil, nil, pr, nil); err != nil {
+		return err
+	}
+
+	if err := fi.SetIssueID(ctx, issue.ID); err != nil {
+		log.Error("Unable to link merge request %s to pull request %s#%d: %v", fi.ObjectURI, repository.FullName(), issue.Index, err)
+		return nil
+	}
+	if err := repo_module.UpdateRepoSize(ctx, repository); err != nil {
+		log.Error("Unable to update the size of %s: %v", repository.FullName(), err)
+	}
+	log.Info("Created pull request %s#%d from merge request %s", repository.FullName(), issue.Index, fi.ObjectURI)
+
+	return nil
+}
+
+func branchExists(ctx context.Context, repoID int64, name string) (bool, error) {
+	branch, err := git_model.GetBranch(ctx, repoID, name)
+	if git_model.IsErrBranchNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return !branch.IsDeleted, nil
+}
+
+// fetchMergeRequestBranch fetches a branch of a remote fork into a temporary reference of the repository.
+// The branch is first fetched into a quarantine repository borrowing the objects of the repository, so that
+// only the missing objects are downloaded and nothing is written to the repository if they exceed maxSize.
+// The reference has to be kept until the pull request points to the fetched commit. Once it is removed,
+// the objects of a pull request which could not be created are unreachable and pruned by the next gc.
+func fetchMergeRequestBranch(ctx context.Context, repository *repo_model.Repository, cloneURI, branch string, maxSize int64) (string, func(), error) {
+	if !git.IsValidRefPattern(branch) {
+		return "", nil, fmt.Errorf("invalid branch name %q", branch)
+	}
+	repoPath := repository.RepoPath()
+
+	quarantinePath, err := repo_module.CreateTemporaryPath("federated-merge-request")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err := repo_module.RemoveTemporaryPath(quarantinePath); err != nil {
+			log.Error("Unable to remove temporary directory %s: %v", quarantinePath, err)
+		}
+	}()
+	if err := git.InitRepository(ctx, quarantinePath, true, repository.ObjectFormatName); err != nil {
+		return "", nil, err
+	}
+	if err := os.WriteFile(filepath.Join(quarantinePath, "objects", "info", "alternates"), []byte(filepath.Join(repoPath, "objects")+"\n"), 0o644); err != nil {
+		return "", nil, err
+	}
+
+	// only the fetched objects count towards the limit
+	objectsPath := filepath.Join(quarantinePath, "objects")
+	initialSize, err := repo_module.GetDirectorySize(objectsPath)
+	if err != nil {
+		return "", nil, err
+	}
+	fetchedSize := func() (int64, error) {
+		size, err := repo_module.GetDirectorySize(objectsPath)
+		return size - initialSize, err
+	}
+
+	fetchCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-fetchCtx.Done():
+				return
+			case <-ticker.C:
+				if size, err := fetchedSize(); err == nil && size > maxSize {
+					cancel(errMergeRequestTooLarge)
+					return
+				}
+			}
+		}
+	}()
+
+	const quarantineRef = "refs/merge-request"
+	if _, _, err := git.NewCommand(fetchCtx, "fetch", "--no-tags").AddDynamicArguments(cloneURI, "+"+git.BranchPrefix+branch+":"+quarantineRef).
+		SetDescription(fmt.Sprintf("fetchMergeRequestBranch: %s from %s", branch, cloneURI)).
+		RunStdString(&git.RunOpts{Dir: quarantinePath, Timeout: 10 * time.Minute}); err != nil {
+		if errors.Is(context.Cause(fetchCtx), errMergeRequestTooLarge) {
+			return "", nil, fmt.Errorf("%w: more than %d bytes fetched", errMergeRequestTooLarge, maxSize)
+		}
+		return "", nil, fmt.Errorf("git fetch: %w", err)
+	}
+	if size, err := fetchedSize(); err != nil {
+		return "", nil, err
+	} else if size > maxSize {
+		return "", nil, fmt.Errorf("%w: %d bytes fetched, the limit is %d", errMergeRequestTooLarge, size, maxSize)
+	}
+
+	suffix, err := util.CryptoRandomString(16)
+	if err != nil {
+		return "", nil, err
+	}
+	tmpRef := "refs/federated/" + suffix
+	if _, _, err := git.NewCommand(ctx, "fetch", "--no-tags").AddDynamicArguments(quarantinePath, "+"+quarantineRef+":"+tmpRef).
+		RunStdString(&git.RunOpts{Dir: repoPath}); err != nil {
+		return "", nil, fmt.Errorf("git fetch: %w", err)
+	}
+	cleanup := func() {
+		if _, _, err := git.NewCommand(ctx, "update-ref", "-d").AddDynamicArguments(tmpRef).RunStdString(&git.RunOpts{Dir: repoPath}); err != nil {
+			log.Error("Unable to remove %s from %s: %v", tmpRef, repository.FullName(), err)
+		}
+	}
+
+	commitID, _, err := git.NewCommand(ctx, "rev-parse").AddDynamicArguments(tmpRef).RunStdString(&git.RunOpts{Dir: repoPath})
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("git rev-parse: %w", err)
+	}
+	return strings.TrimSpace(commitID), cleanup, nil
+}
+
+// SendMergeRequest offers a branch of a federated fork to be merged into the repository the fork was created from.
+// It returns the id of the offered Ticket.
+func SendMergeRequest(ctx context.Context, doer *user.User, fork *repo_model.Repository, headBranch, baseBranch, title, body string) (string, error) {
+	federatedFork, err := repo_model.GetFederatedForkByRepoID(ctx, fork.ID)
+	if err != nil {
+		return "", err
+	}
+	if federatedFork == nil {
+		return "", util.NewInvalidArgumentErrorf("%s is not a fork of a federated repository", fork.FullName())
+	}
+	federationHost, err := forgefed.GetFederationHost(ctx, federatedFork.FederationHostID)
+	if err != nil {
+		return "", err
+	}
+	if federationHost.IsBlocked() {
+		return "", util.NewPermissionDeniedErrorf("federation with %s is blocked", federationHost.HostFqdn)
+	}
+
+	exists, err := branchExists(ctx, fork.ID, headBranch)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", util.NewNotExistErrorf("branch %q does not exist", headBranch)
+	}
+
+	suffix, err := util.CryptoRandomString(16)
+	if err != nil {
+		return "", err
+	}
+	mr := fm.ForgeMergeRequest{
+		ForgeTicket: fm.ForgeTicket{Object: *ap.ObjectNew(fm.TicketType)},
+		Origin:      fm.NewForgeBranch(fork.APActorID(), headBranch),
+		Target:      fm.NewForgeBranch(federatedFork.URI, baseBranch),
+	}
+	mr.ID = ap.IRI(fmt.Sprintf("%s/merge-requests/%s", fork.APActorID(), suffix))
+	mr.AttributedTo = ap.IRI(doer.APActorID())
+	mr.Context = ap.IRI(federatedFork.URI)
+	mr.Summary = ap.DefaultNaturalLanguageValue(title)
+	mr.Content = ap.DefaultNaturalLanguageValue(html.EscapeString(body))
+	mr.Source = ap.Source{Content: ap.DefaultNaturalLanguageValue(body), MediaType: "text/markdown"}
+	mr.URL = ap.IRI(fork.HTMLURL() + "/src/branch/" + util.PathEscapeSegments(headBranch))
+	mr.Published = time.Now()
+
+	offer, err := fm.NewForgeOffer(doer.APActorID(), mr)
+	if err != nil {
+		return "", util.NewInvalidArgumentErrorf("invalid merge request: %v", err)
+	}
+	if err := Deliver(ctx, doer, offer, federatedFork.InboxURI); err != nil {
+		return "", err
+	}
+	return mr.ID.String(), nil
+}
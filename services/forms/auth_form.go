@@ -15,7 +15,7 @@ import (
 // AuthenticationForm form for authentication
 type AuthenticationForm struct {
 	ID                            int64
-	Type                          int    `binding:"Range(2,7)"`
+	Type                          int    `binding:"Range(2,9)"`
 	Name                          string `binding:"Required;MaxSize(30)"`
 	Host                          string
 	Port                          int
@@ -83,6 +83,17 @@ type AuthenticationForm struct {
 	SSPIDefaultLanguage           string
 	GroupTeamMap                  string `binding:"ValidGroupTeamMap"`
 	GroupTeamMapRemoval           bool
+	SAMLMetadata                  string
+	SAMLMetadataURL               string `binding:"ValidUrl"`
+	SAMLServiceProviderEntityID   string
+	SAMLAttributeUsername         string
+	SAMLAttributeEmail            string
+	SAMLAttributeFullName         string
+	SAMLGroupAttribute            string
+	SAMLAdminGroup                string
+	SAMLRestrictedGroup           string
+	SAMLGroupTeamMap              string `binding:"ValidGroupTeamMap"`
+	SAMLGroupTeamMapRemoval       bool
 }
 
 // Validate validates fields
@@ -528,6 +528,20 @@ type UpdateAllowEditsForm struct {
 	AllowMaintainerEdit bool
 }
 
+// ResolvePullConflictsForm form for resolving the conflicts of a PR in the browser,
+// the resolution of every conflicted file is submitted in additional indexed fields
+type ResolvePullConflictsForm struct {
+	HeadCommitID string `binding:"Required"`
+	BaseCommitID string `binding:"Required"`
+	Message      string
+}
+
+// Validate validates the fields
+func (f *ResolvePullConflictsForm) Validate(req *http.Request, errs binding.Errors) binding.Errors {
+	ctx := context.GetValidateContext(req)
+	return middleware.Validate(errs, ctx.Data, f, ctx.Locale)
+}
+
 // __________       .__
 // \______   \ ____ |  |   ____ _____    ______ ____
 //  |       _// __ \|  | _/ __ \\__  \  /  ___// __ \
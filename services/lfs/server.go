@@ -556,6 +556,11 @@ func authenticate(ctx *context.Context, repository *repo_model.Repository, autho
 		return accessMode <= perm.AccessModeWrite
 	}
 
+	// an access token restricted to some repositories gives no access to the others
+	if !context.IsRepoAllowedByToken(ctx.Data, repository) {
+		return false
+	}
+
 	// ctx.IsSigned is unnecessary here, this will be checked in perm.CanAccess
 	perm, err := access_model.GetUserRepoPermission(ctx, repository, ctx.Doer)
 	if err != nil {
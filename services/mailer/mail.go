@@ -45,6 +45,7 @@ const (
 	mailAuth2faDisabled        base.TplName = "auth/2fa_disabled"
 	mailAuthRemovedSecurityKey base.TplName = "auth/removed_security_key"
 	mailAuthTOTPEnrolled       base.TplName = "auth/totp_enrolled"
+	mailAuthAccessTokenExpiry  base.TplName = "auth/access_token_expiry"
 
 	mailNotifyCollaborator base.TplName = "notify/collaborator"
 
@@ -730,3 +731,33 @@ func SendTOTPEnrolled(ctx context.Context, u *user_model.User) error {
 	SendAsync(msg)
 	return nil
 }
+
+// SendAccessTokenExpiry informs the user that one of their access tokens is about to expire.
+func SendAccessTokenExpiry(ctx context.Context, u *user_model.User, t *auth_model.AccessToken) error {
+	if setting.MailService == nil {
+		return nil
+	}
+	locale := translation.NewLocale(u.Language)
+
+	data := map[string]any{
+		"locale":          locale,
+		"TokenName":       t.Name,
+		"ExpiresAt":       t.ExpiresUnix.FormatInLocation("2006-01-02 15:04 MST", setting.DefaultUILocation),
+		"ApplicationsURL": setting.AppURL + "user/settings/applications",
+		"DisplayName":     u.DisplayName(),
+		"Username":        u.Name,
+		"Language":        locale.Language(),
+	}
+
+	var content bytes.Buffer
+
+	if err := bodyTemplates.ExecuteTemplate(&content, string(mailAuthAccessTokenExpiry), data); err != nil {
+		return err
+	}
+
+	msg := NewMessage(u.EmailTo(), locale.TrString("mail.access_token_expiry.subject"), content.String())
+	msg.Info = fmt.Sprintf("UID: %d, access token expiry notification", u.ID)
+
+	SendAsync(msg)
+	return nil
+}
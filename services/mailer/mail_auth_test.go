@@ -5,13 +5,17 @@ package mailer_test
 
 import (
 	"testing"
+	"time"
 
+	auth_model "code.gitea.io/gitea/models/auth"
 	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/models/unittest"
 	user_model "code.gitea.io/gitea/models/user"
 	"code.gitea.io/gitea/modules/optional"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
 	"code.gitea.io/gitea/modules/translation"
+	auth_service "code.gitea.io/gitea/services/auth"
 	"code.gitea.io/gitea/services/mailer"
 	user_service "code.gitea.io/gitea/services/user"
 
@@ -60,3 +64,29 @@ func TestPrimaryMailChange(t *testing.T) {
 
 	require.NoError(t, user_service.MakeEmailAddressPrimary(db.DefaultContext, user, firstEmail, false))
 }
+
+func TestAccessTokenExpiryMail(t *testing.T) {
+	defer require.NoError(t, unittest.PrepareTestDatabase())
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+	token := &auth_model.AccessToken{UID: user.ID, Name: "expiring token", ExpiresUnix: timeutil.TimeStampNow().Add(3600)}
+	require.NoError(t, auth_model.NewAccessToken(db.DefaultContext, token))
+
+	called := false
+	defer mailer.MockMailSettings(func(msgs ...*mailer.Message) {
+		assert.False(t, called)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, user.EmailTo(), msgs[0].To)
+		assert.EqualValues(t, translation.NewLocale("en-US").Tr("mail.access_token_expiry.subject"), msgs[0].Subject)
+		assert.Contains(t, msgs[0].Body, token.Name)
+		mailer.AssertTranslatedLocale(t, msgs[0].Body, "mail.access_token_expiry.text_1", "mail.access_token_expiry.text_2")
+		called = true
+	})()
+
+	require.NoError(t, auth_service.NotifyExpiringAccessTokens(db.DefaultContext, 7*24*time.Hour))
+	assert.True(t, called)
+
+	// the owner is only notified once
+	require.NoError(t, auth_service.NotifyExpiringAccessTokens(db.DefaultContext, 7*24*time.Hour))
+	unittest.AssertExistsAndLoadBean(t, &auth_model.AccessToken{ID: token.ID, ExpiryNotified: true})
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"code.gitea.io/gitea/models"
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
 	org_model "code.gitea.io/gitea/models/organization"
 	packages_model "code.gitea.io/gitea/models/packages"
@@ -15,26 +16,28 @@ import (
 	user_model "code.gitea.io/gitea/models/user"
 	"code.gitea.io/gitea/modules/storage"
 	"code.gitea.io/gitea/modules/util"
+	audit_service "code.gitea.io/gitea/services/audit"
 	repo_service "code.gitea.io/gitea/services/repository"
 )
 
 // DeleteOrganization completely and permanently deletes everything of organization.
-func DeleteOrganization(ctx context.Context, org *org_model.Organization, purge bool) error {
-	ctx, commiter, err := db.TxContext(ctx)
+// The doer is nil when the organization is deleted by the system.
+func DeleteOrganization(ctx context.Context, doer *user_model.User, org *org_model.Organization, purge bool) error {
+	txCtx, commiter, err := db.TxContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer commiter.Close()
 
 	if purge {
-		err := repo_service.DeleteOwnerRepositoriesDirectly(ctx, org.AsUser())
+		err := repo_service.DeleteOwnerRepositoriesDirectly(txCtx, org.AsUser())
 		if err != nil {
 			return err
 		}
 	}
 
 	// Check ownership of repository.
-	count, err := repo_model.CountRepositories(ctx, repo_model.CountRepositoryOptions{OwnerID: org.ID})
+	count, err := repo_model.CountRepositories(txCtx, repo_model.CountRepositoryOptions{OwnerID: org.ID})
 	if err != nil {
 		return fmt.Errorf("GetRepositoryCount: %w", err)
 	} else if count > 0 {
@@ -42,19 +45,20 @@ func DeleteOrganization(ctx context.Context, org *org_model.Organization, purge
 	}
 
 	// Check ownership of packages.
-	if ownsPackages, err := packages_model.HasOwnerPackages(ctx, org.ID); err != nil {
+	if ownsPackages, err := packages_model.HasOwnerPackages(txCtx, org.ID); err != nil {
 		return fmt.Errorf("HasOwnerPackages: %w", err)
 	} else if ownsPackages {
 		return models.ErrUserOwnPackages{UID: org.ID}
 	}
 
-	if err := org_model.DeleteOrganization(ctx, org); err != nil {
+	if err := org_model.DeleteOrganization(txCtx, org); err != nil {
 		return fmt.Errorf("DeleteOrganization: %w", err)
 	}
 
 	if err := commiter.Commit(); err != nil {
 		return err
 	}
+	audit_service.Record(ctx, audit_model.OrganizationDelete, doer, nil, org, "Deleted organization %s.", org.Name)
 
 	// FIXME: system notice
 	// Note: There are something just cannot be roll back,
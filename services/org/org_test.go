@@ -23,17 +23,17 @@ func TestMain(m *testing.M) {
 func TestDeleteOrganization(t *testing.T) {
 	require.NoError(t, unittest.PrepareTestDatabase())
 	org := unittest.AssertExistsAndLoadBean(t, &organization.Organization{ID: 6})
-	require.NoError(t, DeleteOrganization(db.DefaultContext, org, false))
+	require.NoError(t, DeleteOrganization(db.DefaultContext, nil, org, false))
 	unittest.AssertNotExistsBean(t, &organization.Organization{ID: 6})
 	unittest.AssertNotExistsBean(t, &organization.OrgUser{OrgID: 6})
 	unittest.AssertNotExistsBean(t, &organization.Team{OrgID: 6})
 
 	org = unittest.AssertExistsAndLoadBean(t, &organization.Organization{ID: 3})
-	err := DeleteOrganization(db.DefaultContext, org, false)
+	err := DeleteOrganization(db.DefaultContext, nil, org, false)
 	require.Error(t, err)
 	assert.True(t, models.IsErrUserOwnRepos(err))
 
 	user := unittest.AssertExistsAndLoadBean(t, &organization.Organization{ID: 5})
-	require.Error(t, DeleteOrganization(db.DefaultContext, user, false))
+	require.Error(t, DeleteOrganization(db.DefaultContext, nil, user, false))
 	unittest.CheckConsistencyFor(t, &user_model.User{}, &organization.Team{})
 }
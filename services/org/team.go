@@ -0,0 +1,78 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package org
+
+import (
+	"context"
+
+	"code.gitea.io/gitea/models"
+	audit_model "code.gitea.io/gitea/models/audit"
+	org_model "code.gitea.io/gitea/models/organization"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	audit_service "code.gitea.io/gitea/services/audit"
+)
+
+// NewTeam creates a team in its organization.
+func NewTeam(ctx context.Context, doer *user_model.User, t *org_model.Team) error {
+	if err := models.NewTeam(ctx, t); err != nil {
+		return err
+	}
+	recordTeamEvent(ctx, audit_model.OrganizationTeamAdd, doer, t, t, "Created team %s with %s access.", t.Name, t.AccessMode)
+	return nil
+}
+
+// UpdateTeam updates the settings of a team.
+func UpdateTeam(ctx context.Context, doer *user_model.User, t *org_model.Team, authChanged, includeAllChanged bool) error {
+	if err := models.UpdateTeam(ctx, t, authChanged, includeAllChanged); err != nil {
+		return err
+	}
+	recordTeamEvent(ctx, audit_model.OrganizationTeamUpdate, doer, t, t, "Updated team %s, it has %s access.", t.Name, t.AccessMode)
+	return nil
+}
+
+// DeleteTeam deletes a team of an organization.
+func DeleteTeam(ctx context.Context, doer *user_model.User, t *org_model.Team) error {
+	if err := models.DeleteTeam(ctx, t); err != nil {
+		return err
+	}
+	recordTeamEvent(ctx, audit_model.OrganizationTeamRemove, doer, t, t, "Removed team %s.", t.Name)
+	return nil
+}
+
+// AddTeamMember adds a user to a team, the user becomes a member of the organization if needed.
+func AddTeamMember(ctx context.Context, doer *user_model.User, t *org_model.Team, u *user_model.User) error {
+	if err := models.AddTeamMember(ctx, t, u.ID); err != nil {
+		return err
+	}
+	recordTeamEvent(ctx, audit_model.OrganizationTeamMemberAdd, doer, t, u, "Added %s to team %s.", u.Name, t.Name)
+	return nil
+}
+
+// RemoveTeamMember removes a user from a team, and from the organization if it was their last team.
+func RemoveTeamMember(ctx context.Context, doer *user_model.User, t *org_model.Team, u *user_model.User) error {
+	if err := models.RemoveTeamMember(ctx, t, u.ID); err != nil {
+		return err
+	}
+	recordTeamEvent(ctx, audit_model.OrganizationTeamMemberRemove, doer, t, u, "Removed %s from team %s.", u.Name, t.Name)
+	return nil
+}
+
+// RemoveOrgUser removes a user from an organization and all of its teams.
+func RemoveOrgUser(ctx context.Context, doer *user_model.User, org *org_model.Organization, u *user_model.User) error {
+	if err := models.RemoveOrgUser(ctx, org.ID, u.ID); err != nil {
+		return err
+	}
+	audit_service.Record(ctx, audit_model.OrganizationMemberRemove, doer, org, u, "Removed %s from organization %s.", u.Name, org.Name)
+	return nil
+}
+
+func recordTeamEvent(ctx context.Context, action audit_model.Action, doer *user_model.User, t *org_model.Team, target any, format string, v ...any) {
+	org, err := org_model.GetOrgByID(ctx, t.OrgID)
+	if err != nil {
+		log.Error("GetOrgByID: %v", err)
+		return
+	}
+	audit_service.Record(ctx, action, doer, org, target, format, v...)
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+
+	actions_model "code.gitea.io/gitea/models/actions"
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/json"
+	attestation_module "code.gitea.io/gitea/modules/packages/attestation"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+)
+
+var (
+	ErrUnsignedAttestation = util.NewInvalidArgumentErrorf("attestation must be a signed Sigstore bundle")
+	ErrSubjectMismatch     = util.NewInvalidArgumentErrorf("attestation subject does not match a file of the package version")
+)
+
+// Create stores an uploaded attestation for the package version.
+// Only signed attestations whose subject matches a file of the package version are accepted. If a Sigstore
+// trusted root is configured, the certificate of the signer must be issued by one of its certificate authorities
+// and the attestation is stored as verified, otherwise it is stored as unverified.
+func Create(ctx context.Context, doer *user_model.User, pd *packages_model.PackageDescriptor, content []byte) (*packages_model.PackageAttestation, error) {
+	a, err := attestation_module.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	if a.Signer == nil || a.Signer.Subject == "" {
+		return nil, ErrUnsignedAttestation
+	}
+	if !MatchesVersion(a.Statement, pd) {
+		return nil, ErrSubjectMismatch
+	}
+
+	trustedRoot, err := loadTrustedRoot()
+	if err != nil {
+		return nil, err
+	}
+	if trustedRoot != nil {
+		if err := trustedRoot.Verify(a); err != nil {
+			return nil, err
+		}
+	}
+
+	pa := newPackageAttestation(pd, doer, content, a)
+	pa.Source = packages_model.AttestationSourceUpload
+	pa.Verified = trustedRoot != nil
+	pa.SignerIdentity = a.Signer.Subject
+	pa.SignerIssuer = a.Signer.Issuer
+
+	if err := packages_model.InsertAttestation(ctx, pa); err != nil {
+		return nil, err
+	}
+	return pa, nil
+}
+
+// CreateFromActionsTask records the provenance of a package version published by an Actions task
+func CreateFromActionsTask(ctx context.Context, task *actions_model.ActionTask, pd *packages_model.PackageDescriptor) (*packages_model.PackageAttestation, error) {
+	if err := task.LoadAttributes(ctx); err != nil {
+		return nil, err
+	}
+	run := task.Job.Run
+
+	subjects := make([]*attestation_module.Subject, 0, len(pd.Files))
+	for _, pfd := range pd.Files {
+		subjects = append(subjects, &attestation_module.Subject{
+			Name:   pfd.File.Name,
+			Digest: map[string]string{"sha256": pfd.Blob.HashSHA256},
+		})
+	}
+	if len(subjects) == 0 {
+		return nil, nil
+	}
+
+	repoURL := run.Repo.HTMLURL()
+	statement := &attestation_module.Statement{
+		Type:          attestation_module.StatementTypeV1,
+		Subject:       subjects,
+		PredicateType: attestation_module.PredicateTypeSLSAProvenanceV1,
+		Predicate: map[string]any{
+			"buildDefinition": map[string]any{
+				"buildType": "https://forgejo.org/actions/workflow@v1",
+				"externalParameters": map[string]any{
+					"workflow": map[string]any{
+						"ref":        run.Ref,
+						"repository": repoURL,
+						"path":       run.WorkflowID,
+					},
+				},
+				"internalParameters": map[string]any{
+					"event": run.TriggerEvent,
+				},
+				"resolvedDependencies": []map[string]any{
+					{
+						"uri":    fmt.Sprintf("git+%s@%s", repoURL, run.Ref),
+						"digest": map[string]string{"gitCommit": task.CommitSHA},
+					},
+				},
+			},
+			"runDetails": map[string]any{
+				"builder": map[string]any{
+					"id": setting.AppURL,
+				},
+				"metadata": map[string]any{
+					"invocationId": fmt.Sprintf("%s/jobs/%d", run.HTMLURL(), task.Job.ID),
+					"startedOn":    task.Started.AsTime().UTC(),
+				},
+			},
+		},
+	}
+
+	content, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &attestation_module.Attestation{
+		Format:     attestation_module.FormatStatement,
+		Statement:  statement,
+		Provenance: attestation_module.ParseProvenance(statement),
+	}
+
+	pa := newPackageAttestation(pd, user_model.NewActionsUser(), content, a)
+	pa.Source = packages_model.AttestationSourceActions
+	pa.Verified = true
+
+	if err := packages_model.InsertAttestation(ctx, pa); err != nil {
+		return nil, err
+	}
+	return pa, nil
+}
+
+// loadTrustedRoot reads the configured Sigstore trusted root, it is read for every upload so it can be updated
+// without a restart. Nil is returned if none is configured.
+func loadTrustedRoot() (*attestation_module.TrustedRoot, error) {
+	if setting.Packages.AttestationTrustedRoot == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(setting.Packages.AttestationTrustedRoot)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the attestation trusted root: %w", err)
+	}
+	trustedRoot, err := attestation_module.ParseTrustedRoot(content)
+	if err != nil {
+		// a broken configuration is not the fault of the uploader
+		return nil, fmt.Errorf("%s: %v", setting.Packages.AttestationTrustedRoot, err)
+	}
+	return trustedRoot, nil
+}
+
+func newPackageAttestation(pd *packages_model.PackageDescriptor, creator *user_model.User, content []byte, a *attestation_module.Attestation) *packages_model.PackageAttestation {
+	sum := sha256.Sum256(content)
+
+	return &packages_model.PackageAttestation{
+		VersionID:        pd.Version.ID,
+		ContentSHA256:    hex.EncodeToString(sum[:]),
+		Content:          string(content),
+		Format:           a.Format,
+		PredicateType:    a.Statement.PredicateType,
+		BuilderID:        a.Provenance.BuilderID,
+		SourceRepository: a.Provenance.SourceRepository,
+		SourceRef:        a.Provenance.SourceRef,
+		CommitSHA:        a.Provenance.CommitSHA,
+		Workflow:         a.Provenance.Workflow,
+		RunURL:           a.Provenance.RunURL,
+		CreatorID:        creator.ID,
+	}
+}
+
+// MatchesVersion checks if a subject of the statement is a file of the package version.
+// Only collision resistant digests are compared.
+func MatchesVersion(s *attestation_module.Statement, pd *packages_model.PackageDescriptor) bool {
+	digests := s.Digests()
+	for _, pfd := range pd.Files {
+		for _, d := range []string{
+			"sha256:" + pfd.Blob.HashSHA256,
+			"sha512:" + pfd.Blob.HashSHA512,
+		} {
+			if slices.Contains(digests, d) {
+				return true
+			}
+		}
+	}
+	return false
+}
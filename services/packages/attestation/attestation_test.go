@@ -0,0 +1,41 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"testing"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	attestation_module "code.gitea.io/gitea/modules/packages/attestation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesVersion(t *testing.T) {
+	pd := &packages_model.PackageDescriptor{
+		Files: []*packages_model.PackageFileDescriptor{
+			{
+				Blob: &packages_model.PackageBlob{
+					HashMD5:    "md5-digest",
+					HashSHA1:   "sha1-digest",
+					HashSHA256: "sha256-digest",
+					HashSHA512: "sha512-digest",
+				},
+			},
+		},
+	}
+
+	statement := func(algorithm, digest string) *attestation_module.Statement {
+		return &attestation_module.Statement{
+			Subject: []*attestation_module.Subject{{Digest: map[string]string{algorithm: digest}}},
+		}
+	}
+
+	assert.True(t, MatchesVersion(statement("sha256", "sha256-digest"), pd))
+	assert.True(t, MatchesVersion(statement("SHA512", "SHA512-DIGEST"), pd))
+	assert.False(t, MatchesVersion(statement("sha256", "other-digest"), pd))
+	// collision prone digests do not bind an attestation to a file
+	assert.False(t, MatchesVersion(statement("sha1", "sha1-digest"), pd))
+	assert.False(t, MatchesVersion(statement("md5", "md5-digest"), pd))
+}
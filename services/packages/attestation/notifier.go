@@ -0,0 +1,63 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package attestation
+
+import (
+	"context"
+
+	actions_model "code.gitea.io/gitea/models/actions"
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/web/middleware"
+	notify_service "code.gitea.io/gitea/services/notify"
+)
+
+type attestationNotifier struct {
+	notify_service.NullNotifier
+}
+
+var _ notify_service.Notifier = &attestationNotifier{}
+
+// Init checks the configured Sigstore trusted root and registers the notifier which records the provenance
+// of packages published by Actions
+func Init() error {
+	if _, err := loadTrustedRoot(); err != nil {
+		return err
+	}
+
+	notify_service.RegisterNotifier(NewNotifier())
+
+	return nil
+}
+
+// NewNotifier creates a new attestationNotifier notifier
+func NewNotifier() notify_service.Notifier {
+	return &attestationNotifier{}
+}
+
+func (*attestationNotifier) PackageCreate(ctx context.Context, doer *user_model.User, pd *packages_model.PackageDescriptor) {
+	if !doer.IsActions() {
+		return
+	}
+
+	data := middleware.GetContextData(ctx)
+	if isActionsToken, _ := data["IsActionsToken"].(bool); !isActionsToken {
+		return
+	}
+	taskID, ok := data["ActionsTaskID"].(int64)
+	if !ok {
+		return
+	}
+
+	task, err := actions_model.GetTaskByID(ctx, taskID)
+	if err != nil {
+		log.Error("GetTaskByID [%d]: %v", taskID, err)
+		return
+	}
+
+	if _, err := CreateFromActionsTask(ctx, task, pd); err != nil {
+		log.Error("CreateFromActionsTask [%d]: %v", pd.Version.ID, err)
+	}
+}
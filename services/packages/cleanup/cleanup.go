@@ -20,6 +20,7 @@ import (
 	cargo_service "code.gitea.io/gitea/services/packages/cargo"
 	container_service "code.gitea.io/gitea/services/packages/container"
 	debian_service "code.gitea.io/gitea/services/packages/debian"
+	nix_service "code.gitea.io/gitea/services/packages/nix"
 	rpm_service "code.gitea.io/gitea/services/packages/rpm"
 )
 
@@ -159,6 +160,10 @@ func CleanupExpiredData(outerCtx context.Context, olderThan time.Duration) error
 		return err
 	}
 
+	if err := nix_service.Cleanup(ctx, olderThan); err != nil {
+		return err
+	}
+
 	pIDs, err := packages_model.FindUnreferencedPackages(ctx)
 	if err != nil {
 		return err
@@ -24,6 +24,9 @@ func Cleanup(ctx context.Context, olderThan time.Duration) error {
 	if err := CleanupSHA256(ctx, olderThan); err != nil {
 		return err
 	}
+	if _, _, err := collectGarbage(ctx, 0, olderThan); err != nil {
+		return err
+	}
 	return cleanupExpiredUploadedBlobs(ctx, olderThan)
 }
 
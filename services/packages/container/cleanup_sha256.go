@@ -49,6 +49,21 @@ func cleanupSHA256(outerCtx context.Context, olderThan time.Duration) error {
 	// image creations
 	old := timeutil.TimeStamp(time.Now().Add(-olderThan).Unix())
 
+	// Referrers are not part of an index manifest but are kept as long as
+	// their subject exists. They are left to the garbage collection.
+	var referrerIDs []int64
+	if err := db.GetEngine(ctx).
+		Table("package_property").
+		Select("ref_id").
+		Where("ref_type = ? AND name = ?", packages.PropertyTypeVersion, container_module.PropertyManifestSubject).
+		Find(&referrerIDs); err != nil {
+		return err
+	}
+	isReferrer := make(map[int64]bool, len(referrerIDs))
+	for _, id := range referrerIDs {
+		isReferrer[id] = true
+	}
+
 	log.Debug("Look for all package_version.version that start with sha256:")
 
 	// Iterate over all container versions in ascending order and store
@@ -72,7 +87,9 @@ func cleanupSHA256(outerCtx context.Context, olderThan time.Duration) error {
 		Iterate(new(packages.PackageVersion), func(_ int, bean any) error {
 			v := bean.(*packages.PackageVersion)
 			if strings.HasPrefix(v.LowerVersion, "sha256:") {
-				shaToPackageVersion[v.LowerVersion] = packageVersion{id: v.ID, created: v.CreatedUnix}
+				if !isReferrer[v.ID] {
+					shaToPackageVersion[v.LowerVersion] = packageVersion{id: v.ID, created: v.CreatedUnix}
+				}
 				foundAtLeastOneSHA256 = true
 			} else if strings.Contains(v.MetadataJSON, `"manifests":[{`) {
 				var metadata container_module.Metadata
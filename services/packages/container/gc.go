@@ -0,0 +1,198 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package container
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	container_model "code.gitea.io/gitea/models/packages/container"
+	"code.gitea.io/gitea/modules/log"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	"code.gitea.io/gitea/modules/timeutil"
+	packages_service "code.gitea.io/gitea/services/packages"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+var ErrDryRunInTransaction = errors.New("a dry-run of the garbage collection can't be nested in a transaction")
+
+const gcBlobBatchSize = 500
+
+// GarbageCollectionResult describes what the garbage collection removed or would remove
+type GarbageCollectionResult struct {
+	Manifests int
+	Blobs     int
+	Size      int64
+}
+
+// CollectGarbage removes all untagged manifests which are neither part of a tagged manifest list
+// nor refer to a kept manifest and all blobs only used by them.
+// Untagged manifests created less than olderThan ago are kept.
+// A dry-run reports the result without removing anything.
+func CollectGarbage(ctx context.Context, ownerID int64, olderThan time.Duration, dryRun bool) (*GarbageCollectionResult, error) {
+	if dryRun && db.InTransaction(ctx) {
+		return nil, ErrDryRunInTransaction
+	}
+
+	ctx, committer, err := db.TxContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer committer.Close()
+
+	result, pbs, err := collectGarbage(ctx, ownerID, olderThan)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		// the deferred close rolls back the removal
+		return result, nil
+	}
+
+	for _, pb := range pbs {
+		if err := packages_model.DeleteBlobByID(ctx, pb.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := committer.Commit(); err != nil {
+		return nil, err
+	}
+
+	contentStore := packages_module.NewContentStore()
+	for _, pb := range pbs {
+		if err := contentStore.Delete(packages_module.BlobHash256Key(pb.HashSHA256)); err != nil {
+			log.Error("Error deleting package blob [%v]: %v", pb.ID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// collectGarbage removes the unreachable manifests and returns the blobs which are no longer referenced
+func collectGarbage(ctx context.Context, ownerID int64, olderThan time.Duration) (*GarbageCollectionResult, []*packages_model.PackageBlob, error) {
+	old := timeutil.TimeStamp(time.Now().Add(-olderThan).Unix())
+
+	ps, err := container_model.GetImages(ctx, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &GarbageCollectionResult{}
+	blobIDs := make([]int64, 0, 10)
+	seenBlobs := make(map[int64]bool)
+
+	for _, p := range ps {
+		manifests, err := container_model.GetImageManifests(ctx, p.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, im := range findUnreachableManifests(manifests, old) {
+			pv, err := packages_model.GetVersionByID(ctx, im.VersionID)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			pfs, err := packages_model.GetFilesByVersionID(ctx, pv.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, pf := range pfs {
+				if !seenBlobs[pf.BlobID] {
+					seenBlobs[pf.BlobID] = true
+					blobIDs = append(blobIDs, pf.BlobID)
+				}
+			}
+
+			log.Trace("Removing unreachable manifest %s of image %s", im.Version, p.LowerName)
+
+			if err := packages_service.DeletePackageVersionAndReferences(ctx, pv); err != nil {
+				return nil, nil, err
+			}
+
+			result.Manifests++
+		}
+	}
+
+	pbs := make([]*packages_model.PackageBlob, 0, len(blobIDs))
+	for len(blobIDs) > 0 {
+		upper := min(len(blobIDs), gcBlobBatchSize)
+
+		unreferenced, err := packages_model.FindUnreferencedBlobsByIDs(ctx, blobIDs[:upper])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pb := range unreferenced {
+			result.Blobs++
+			result.Size += pb.Size
+		}
+		pbs = append(pbs, unreferenced...)
+
+		blobIDs = blobIDs[upper:]
+	}
+
+	log.Debug("Container garbage collection: %d manifest(s) and %d blob(s) with %d bytes are unreachable", result.Manifests, result.Blobs, result.Size)
+
+	return result, pbs, nil
+}
+
+// findUnreachableManifests marks all manifests reachable from a tag, an untagged manifest created after old
+// or through a manifest list or as referrer of a reachable manifest. The unmarked manifests are returned.
+func findUnreachableManifests(manifests []*container_model.ImageManifest, old timeutil.TimeStamp) []*container_model.ImageManifest {
+	manifestDigest := func(im *container_model.ImageManifest) string {
+		if im.Digest == "" && digest.Digest(im.Version).Validate() == nil {
+			return im.Version
+		}
+		return im.Digest
+	}
+
+	byDigest := make(map[string][]*container_model.ImageManifest)
+	referrers := make(map[string][]*container_model.ImageManifest)
+	queue := make([]*container_model.ImageManifest, 0, len(manifests))
+	for _, im := range manifests {
+		if d := manifestDigest(im); d != "" {
+			byDigest[d] = append(byDigest[d], im)
+		}
+		if im.Subject != "" {
+			referrers[im.Subject] = append(referrers[im.Subject], im)
+		}
+		if im.IsTagged || digest.Digest(im.Version).Validate() != nil || im.CreatedUnix >= old {
+			queue = append(queue, im)
+		}
+	}
+
+	marked := make(map[int64]bool, len(manifests))
+	for len(queue) > 0 {
+		im := queue[0]
+		queue = queue[1:]
+
+		if marked[im.VersionID] {
+			continue
+		}
+		marked[im.VersionID] = true
+
+		for _, ref := range im.References {
+			queue = append(queue, byDigest[ref]...)
+		}
+		if d := manifestDigest(im); d != "" {
+			// a tagged manifest keeps the untagged version of the same digest and its referrers
+			queue = append(queue, byDigest[d]...)
+			queue = append(queue, referrers[d]...)
+		}
+	}
+
+	unreachable := make([]*container_model.ImageManifest, 0, len(manifests)-len(marked))
+	for _, im := range manifests {
+		if !marked[im.VersionID] {
+			unreachable = append(unreachable, im)
+		}
+	}
+	return unreachable
+}
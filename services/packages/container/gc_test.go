@@ -0,0 +1,51 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package container
+
+import (
+	"testing"
+
+	container_model "code.gitea.io/gitea/models/packages/container"
+	"code.gitea.io/gitea/modules/timeutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindUnreachableManifests(t *testing.T) {
+	digest := func(c string) string {
+		return "sha256:" + c + "000000000000000000000000000000000000000000000000000000000000000"
+	}
+
+	old := timeutil.TimeStamp(100)
+
+	manifests := []*container_model.ImageManifest{
+		// tagged manifest list with two manifests
+		{VersionID: 1, Version: digest("1"), Digest: digest("1"), CreatedUnix: 10},
+		{VersionID: 2, Version: digest("2"), Digest: digest("2"), CreatedUnix: 10},
+		{VersionID: 3, Version: "latest", Digest: digest("3"), IsTagged: true, References: []string{digest("1"), digest("2")}, CreatedUnix: 10},
+		// signature of a manifest of the list and a referrer of the signature
+		{VersionID: 4, Version: digest("4"), Digest: digest("4"), Subject: digest("1"), CreatedUnix: 10},
+		{VersionID: 5, Version: digest("5"), Digest: digest("5"), Subject: digest("4"), CreatedUnix: 10},
+		// untagged manifest list and its manifest
+		{VersionID: 6, Version: digest("6"), Digest: digest("6"), CreatedUnix: 10},
+		{VersionID: 7, Version: digest("7"), Digest: digest("7"), References: []string{digest("6")}, CreatedUnix: 10},
+		// referrer of a removed manifest
+		{VersionID: 8, Version: digest("8"), Digest: digest("8"), Subject: digest("6"), CreatedUnix: 10},
+		// recently uploaded manifest
+		{VersionID: 9, Version: digest("9"), Digest: digest("9"), CreatedUnix: 200},
+		// untagged version of a tagged manifest
+		{VersionID: 10, Version: "v1", Digest: digest("a"), IsTagged: true, CreatedUnix: 10},
+		{VersionID: 11, Version: digest("a"), CreatedUnix: 10},
+	}
+
+	unreachable := findUnreachableManifests(manifests, old)
+
+	ids := make([]int64, 0, len(unreachable))
+	for _, im := range unreachable {
+		ids = append(ids, im.VersionID)
+	}
+	assert.ElementsMatch(t, []int64{6, 7, 8}, ids)
+
+	assert.Empty(t, findUnreachableManifests(manifests, timeutil.TimeStamp(0)))
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package debian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	debian_model "code.gitea.io/gitea/models/packages/debian"
+	debian_module "code.gitea.io/gitea/modules/packages/debian"
+	"code.gitea.io/gitea/modules/util"
+	packages_service "code.gitea.io/gitea/services/packages"
+)
+
+var ErrDistributionNotExist = util.NewNotExistErrorf("distribution does not exist")
+
+// PromotePackage identifies a package version to promote
+type PromotePackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// CreateSnapshot freezes the repository files of the distribution and the packages they list
+func CreateSnapshot(ctx context.Context, ownerID int64, name, distribution string) (*packages_service.Snapshot, error) {
+	return packages_service.CreateSnapshot(ctx, ownerID, packages_model.TypeDebian, debian_module.RepositoryPackage, name, distribution, func(ctx context.Context, snapshotVersion *packages_model.PackageVersion) error {
+		repoVersion, err := GetOrCreateRepositoryVersion(ctx, ownerID)
+		if err != nil {
+			return err
+		}
+
+		pfs, _, err := packages_model.SearchFiles(ctx, &packages_model.PackageFileSearchOptions{
+			VersionID: repoVersion.ID,
+			Properties: map[string]string{
+				debian_module.PropertyDistribution: distribution,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if len(pfs) == 0 {
+			return ErrDistributionNotExist
+		}
+
+		if err := debian_model.SearchPackages(ctx, &debian_model.PackageSearchOptions{
+			OwnerID:      ownerID,
+			Distribution: distribution,
+		}, func(pfd *packages_model.PackageFileDescriptor) {
+			pfs = append(pfs, pfd.File)
+		}); err != nil {
+			return err
+		}
+
+		for _, pf := range pfs {
+			if _, err := packages_service.CopyPackageFile(ctx, snapshotVersion, pf, pf.CompositeKey, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSnapshot gets the snapshot and the internal package version holding its files
+func GetSnapshot(ctx context.Context, ownerID int64, name string) (*packages_service.Snapshot, *packages_model.PackageVersion, error) {
+	return packages_service.GetSnapshot(ctx, ownerID, packages_model.TypeDebian, debian_module.RepositoryPackage, name)
+}
+
+// GetSnapshots gets all snapshots of the repository
+func GetSnapshots(ctx context.Context, ownerID int64) ([]*packages_service.Snapshot, error) {
+	return packages_service.GetSnapshots(ctx, ownerID, packages_model.TypeDebian, debian_module.RepositoryPackage)
+}
+
+// DeleteSnapshot deletes the snapshot
+func DeleteSnapshot(ctx context.Context, ownerID int64, name string) error {
+	return packages_service.DeleteSnapshot(ctx, ownerID, packages_model.TypeDebian, debian_module.RepositoryPackage, name)
+}
+
+// Promote adds the package versions of the source distribution to the target distribution.
+// The packages keep their component. Packages which are already part of the target distribution are skipped.
+func Promote(ctx context.Context, ownerID int64, from, to string, packages []*PromotePackage) error {
+	if from == "" || to == "" || from == to {
+		return util.NewInvalidArgumentErrorf("invalid distributions")
+	}
+	if len(packages) == 0 {
+		return util.NewInvalidArgumentErrorf("no packages to promote")
+	}
+
+	type indexKey struct {
+		Component    string
+		Architecture string
+	}
+	indices := make(map[indexKey]bool)
+
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		for _, p := range packages {
+			pv, err := packages_model.GetVersionByNameAndVersion(ctx, ownerID, packages_model.TypeDebian, p.Name, p.Version)
+			if err != nil {
+				if errors.Is(err, util.ErrNotExist) {
+					return util.NewNotExistErrorf("package %s %s does not exist", p.Name, p.Version)
+				}
+				return err
+			}
+
+			pfs, err := packages_model.GetFilesByVersionID(ctx, pv.ID)
+			if err != nil {
+				return err
+			}
+
+			pfds, err := packages_model.GetPackageFileDescriptors(ctx, pfs)
+			if err != nil {
+				return err
+			}
+
+			found := false
+			for _, pfd := range pfds {
+				if !pfd.File.IsLead || pfd.Properties.GetByName(debian_module.PropertyDistribution) != from {
+					continue
+				}
+				found = true
+
+				component := pfd.Properties.GetByName(debian_module.PropertyComponent)
+				architecture := pfd.Properties.GetByName(debian_module.PropertyArchitecture)
+
+				_, err := packages_service.CopyPackageFile(ctx, pv, pfd.File, fmt.Sprintf("%s|%s", to, component), map[string]string{
+					debian_module.PropertyDistribution: to,
+				})
+				if err != nil {
+					if err == packages_model.ErrDuplicatePackageFile {
+						continue
+					}
+					return err
+				}
+
+				indices[indexKey{component, architecture}] = true
+			}
+			if !found {
+				return util.NewNotExistErrorf("package %s %s is not part of distribution %s", p.Name, p.Version, from)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for key := range indices {
+		if err := BuildSpecificRepositoryFiles(ctx, ownerID, to, key.Component, key.Architecture); err != nil {
+			return fmt.Errorf("failed to build repository files [%s/%s/%s]: %w", to, key.Component, key.Architecture, err)
+		}
+	}
+	return nil
+}
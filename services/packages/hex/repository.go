@@ -0,0 +1,259 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package hex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"sort"
+	"time"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/optional"
+	hex_module "code.gitea.io/gitea/modules/packages/hex"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/hashicorp/go-version"
+)
+
+// RepositoryName returns the name of the repository of the owner.
+// The clients verify that the registry resources belong to the repository they added under this name.
+func RepositoryName(owner *user_model.User) string {
+	return owner.LowerName
+}
+
+// GetOrCreateKeyPair gets or creates the RSA keys used to sign the registry resources
+func GetOrCreateKeyPair(ctx context.Context, ownerID int64) (string, string, error) {
+	priv, err := user_model.GetSetting(ctx, ownerID, hex_module.SettingKeyPrivate)
+	if err != nil && !errors.Is(err, util.ErrNotExist) {
+		return "", "", err
+	}
+
+	pub, err := user_model.GetSetting(ctx, ownerID, hex_module.SettingKeyPublic)
+	if err != nil && !errors.Is(err, util.ErrNotExist) {
+		return "", "", err
+	}
+
+	if priv == "" || pub == "" {
+		priv, pub, err = util.GenerateKeyPair(4096)
+		if err != nil {
+			return "", "", err
+		}
+
+		if err := user_model.SetUserSetting(ctx, ownerID, hex_module.SettingKeyPrivate, priv); err != nil {
+			return "", "", err
+		}
+
+		if err := user_model.SetUserSetting(ctx, ownerID, hex_module.SettingKeyPublic, pub); err != nil {
+			return "", "", err
+		}
+	}
+
+	return priv, pub, nil
+}
+
+// GetRetirement returns the retirement status of the version, nil if it is not retired
+func GetRetirement(pd *packages_model.PackageDescriptor) *hex_module.Retirement {
+	value := pd.VersionProperties.GetByName(hex_module.PropertyRetirement)
+	if value == "" {
+		return nil
+	}
+	var r hex_module.Retirement
+	if err := json.Unmarshal([]byte(value), &r); err != nil {
+		return nil
+	}
+	return &r
+}
+
+// BuildNames builds the signed /names resource listing the packages of the owner
+func BuildNames(ctx context.Context, owner *user_model.User) ([]byte, error) {
+	packages, err := getPackages(ctx, owner.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]*hex_module.NamesPackage, 0, len(packages))
+	for _, p := range packages {
+		var updated time.Time
+		for _, pd := range p.versions {
+			if t := pd.Version.CreatedUnix.AsTime(); t.After(updated) {
+				updated = t
+			}
+		}
+		names = append(names, &hex_module.NamesPackage{
+			Name:      p.name,
+			UpdatedAt: updated,
+		})
+	}
+
+	return sign(ctx, owner.ID, hex_module.EncodeNames(RepositoryName(owner), names))
+}
+
+// BuildVersions builds the signed /versions resource listing the versions of the packages of the owner
+func BuildVersions(ctx context.Context, owner *user_model.User) ([]byte, error) {
+	packages, err := getPackages(ctx, owner.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*hex_module.VersionsPackage, 0, len(packages))
+	for _, p := range packages {
+		vp := &hex_module.VersionsPackage{
+			Name:     p.name,
+			Versions: make([]string, 0, len(p.versions)),
+		}
+		for i, pd := range p.versions {
+			vp.Versions = append(vp.Versions, pd.Version.Version)
+			if GetRetirement(pd) != nil {
+				vp.Retired = append(vp.Retired, int32(i))
+			}
+		}
+		versions = append(versions, vp)
+	}
+
+	return sign(ctx, owner.ID, hex_module.EncodeVersions(RepositoryName(owner), versions))
+}
+
+// GetPackageVersions returns the versions of the package in semver order
+func GetPackageVersions(ctx context.Context, ownerID int64, name string) ([]*packages_model.PackageDescriptor, error) {
+	packages, err := getPackages(ctx, ownerID, name)
+	if err != nil || len(packages) == 0 {
+		return nil, err
+	}
+	return packages[0].versions, nil
+}
+
+// BuildPackage builds the signed /packages/<name> resource listing the releases of the package.
+// It returns nil if the package does not exist.
+func BuildPackage(ctx context.Context, owner *user_model.User, name string) ([]byte, error) {
+	pds, err := GetPackageVersions(ctx, owner.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(pds) == 0 {
+		return nil, nil
+	}
+
+	releases := make([]*hex_module.Release, 0, len(pds))
+	for _, pd := range pds {
+		metadata := pd.Metadata.(*hex_module.Metadata)
+
+		innerChecksum, err := hex.DecodeString(metadata.InnerChecksum)
+		if err != nil {
+			return nil, err
+		}
+		outerChecksum, err := hex.DecodeString(pd.Files[0].Blob.HashSHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		releases = append(releases, &hex_module.Release{
+			Version:       pd.Version.Version,
+			InnerChecksum: innerChecksum,
+			OuterChecksum: outerChecksum,
+			Dependencies:  metadata.Requirements,
+			Retirement:    GetRetirement(pd),
+		})
+	}
+
+	return sign(ctx, owner.ID, hex_module.EncodePackage(RepositoryName(owner), pds[0].Package.Name, releases))
+}
+
+type hexPackage struct {
+	name     string
+	versions []*packages_model.PackageDescriptor
+}
+
+// getPackages returns the packages of the owner, or only the named one, with their versions in semver order
+func getPackages(ctx context.Context, ownerID int64, name string) ([]*hexPackage, error) {
+	pvs, _, err := packages_model.SearchVersions(ctx, &packages_model.PackageSearchOptions{
+		OwnerID:    ownerID,
+		Type:       packages_model.TypeHex,
+		Name:       packages_model.SearchValue{Value: name, ExactMatch: true},
+		IsInternal: optional.Some(false),
+		HasFiles:   optional.Some(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pds, err := packages_model.GetPackageDescriptors(ctx, pvs)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*hexPackage)
+	packages := make([]*hexPackage, 0, 10)
+	for _, pd := range pds {
+		if len(pd.Files) == 0 {
+			continue
+		}
+		p, ok := byName[pd.Package.Name]
+		if !ok {
+			p = &hexPackage{name: pd.Package.Name}
+			byName[p.name] = p
+			packages = append(packages, p)
+		}
+		p.versions = append(p.versions, pd)
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].name < packages[j].name
+	})
+	for _, p := range packages {
+		sort.SliceStable(p.versions, func(i, j int) bool {
+			return compareVersions(p.versions[i].Version.Version, p.versions[j].Version.Version) < 0
+		})
+	}
+
+	return packages, nil
+}
+
+func compareVersions(a, b string) int {
+	va, errA := version.NewSemver(a)
+	vb, errB := version.NewSemver(b)
+	if errA != nil || errB != nil {
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	}
+	return va.Compare(vb)
+}
+
+// sign signs the payload with the private key of the owner
+func sign(ctx context.Context, ownerID int64, payload []byte) ([]byte, error) {
+	priv, _, err := GetOrCreateKeyPair(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(priv))
+	if block == nil {
+		return nil, errors.New("failed to decode private key pem")
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha512.Sum512(payload)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA512, h[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return hex_module.EncodeSigned(payload, signature)
+}
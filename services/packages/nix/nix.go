@@ -0,0 +1,304 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package nix
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/optional"
+	packages_module "code.gitea.io/gitea/modules/packages"
+	nix_module "code.gitea.io/gitea/modules/packages/nix"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	packages_service "code.gitea.io/gitea/services/packages"
+)
+
+var (
+	ErrNarNotUploaded   = util.NewInvalidArgumentErrorf("the NAR file referenced by the narinfo has not been uploaded")
+	ErrNarHashMismatch  = util.NewInvalidArgumentErrorf("the NAR file does not match its hash")
+	ErrInvalidNarFile   = util.NewInvalidArgumentErrorf("NAR file name is invalid")
+	ErrStorePathMissing = util.NewNotExistErrorf("store path does not exist")
+)
+
+// GetOrCreateUploadVersion gets or creates the internal package which holds NAR files until their narinfo is uploaded
+func GetOrCreateUploadVersion(ctx context.Context, ownerID int64) (*packages_model.PackageVersion, error) {
+	return packages_service.GetOrCreateInternalPackageVersion(ctx, ownerID, packages_model.TypeNix, nix_module.UploadPackage, nix_module.UploadVersion)
+}
+
+// GetOrCreateKeyPair gets or creates the ed25519 keys used to sign the narinfo files.
+// The key name is fixed when the keys are created so that changing the domain does not invalidate trusted keys.
+func GetOrCreateKeyPair(ctx context.Context, owner *user_model.User) (string, string, error) {
+	priv, err := user_model.GetSetting(ctx, owner.ID, nix_module.SettingKeyPrivate)
+	if err != nil && !errors.Is(err, util.ErrNotExist) {
+		return "", "", err
+	}
+
+	pub, err := user_model.GetSetting(ctx, owner.ID, nix_module.SettingKeyPublic)
+	if err != nil && !errors.Is(err, util.ErrNotExist) {
+		return "", "", err
+	}
+
+	if priv == "" || pub == "" {
+		priv, pub, err = nix_module.GenerateKeyPair(fmt.Sprintf("%s-%s-1", setting.Domain, owner.LowerName))
+		if err != nil {
+			return "", "", err
+		}
+
+		if err := user_model.SetUserSetting(ctx, owner.ID, nix_module.SettingKeyPrivate, priv); err != nil {
+			return "", "", err
+		}
+
+		if err := user_model.SetUserSetting(ctx, owner.ID, nix_module.SettingKeyPublic, pub); err != nil {
+			return "", "", err
+		}
+	}
+
+	return priv, pub, nil
+}
+
+// GetStorePath gets the package version of the store path with the hash
+func GetStorePath(ctx context.Context, ownerID int64, hash string) (*packages_model.PackageVersion, error) {
+	pvs, _, err := packages_model.SearchVersions(ctx, &packages_model.PackageSearchOptions{
+		OwnerID: ownerID,
+		Type:    packages_model.TypeNix,
+		Version: packages_model.SearchValue{
+			ExactMatch: true,
+			Value:      hash,
+		},
+		IsInternal: optional.Some(false),
+		Paginator:  db.NewAbsoluteListOptions(0, 1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pvs) == 0 {
+		return nil, ErrStorePathMissing
+	}
+	return pvs[0], nil
+}
+
+// GetNarFile gets a NAR file which belongs to a store path
+func GetNarFile(ctx context.Context, ownerID int64, filename string) (*packages_model.PackageFile, error) {
+	pvs, _, err := packages_model.SearchVersions(ctx, &packages_model.PackageSearchOptions{
+		OwnerID:         ownerID,
+		Type:            packages_model.TypeNix,
+		IsInternal:      optional.Some(false),
+		HasFileWithName: filename,
+		Paginator:       db.NewAbsoluteListOptions(0, 1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pvs) == 0 {
+		return nil, packages_model.ErrPackageFileNotExist
+	}
+	return packages_model.GetFileForVersionByName(ctx, pvs[0].ID, filename, "")
+}
+
+// SignNarInfo returns the narinfo of the store path signed with the key of the owner.
+// Signatures of other keys which were uploaded with the narinfo are kept.
+func SignNarInfo(ctx context.Context, owner *user_model.User, pd *packages_model.PackageDescriptor) (*nix_module.NarInfo, error) {
+	priv, _, err := GetOrCreateKeyPair(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	ni := *pd.Metadata.(*nix_module.NarInfo)
+
+	sig, err := nix_module.Sign(priv, &ni)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := make([]string, 0, len(ni.Sigs)+1)
+	for _, s := range ni.Sigs {
+		if nix_module.KeyName(s) != nix_module.KeyName(priv) {
+			sigs = append(sigs, s)
+		}
+	}
+	ni.Sigs = append(sigs, sig)
+
+	return &ni, nil
+}
+
+// UploadNar stores a NAR file until the narinfo referencing it gets uploaded
+func UploadNar(ctx context.Context, doer, owner *user_model.User, filename string, buf *packages_module.HashedBuffer) error {
+	fileHash, ok := nix_module.ParseNarFilename(filename)
+	if !ok {
+		return ErrInvalidNarFile
+	}
+
+	_, _, hashSHA256, _ := buf.Sums()
+	if nix_module.EncodeNix32(hashSHA256) != fileHash {
+		return ErrNarHashMismatch
+	}
+
+	if err := packages_service.CheckSizeQuotaExceeded(ctx, doer, owner, packages_model.TypeNix, buf.Size()); err != nil {
+		return err
+	}
+
+	pv, err := GetOrCreateUploadVersion(ctx, owner.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = packages_service.AddFileToPackageVersionInternal(
+		ctx,
+		pv,
+		&packages_service.PackageFileCreationInfo{
+			PackageFileInfo: packages_service.PackageFileInfo{
+				Filename: filename,
+			},
+			Creator:           doer,
+			Data:              buf,
+			OverwriteExisting: true,
+		},
+	)
+	return err
+}
+
+// CreateStorePath creates the package version of the store path described by the narinfo.
+// The NAR file must have been uploaded before or belong to another store path already.
+func CreateStorePath(ctx context.Context, doer, owner *user_model.User, ni *nix_module.NarInfo) (*packages_model.PackageVersion, error) {
+	hash, name, err := nix_module.SplitStorePath(ni.StorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	filename, ok := strings.CutPrefix(ni.URL, "nar/")
+	if !ok {
+		return nil, ErrInvalidNarFile
+	}
+	if _, ok := nix_module.ParseNarFilename(filename); !ok {
+		return nil, ErrInvalidNarFile
+	}
+
+	uploadVersion, err := GetOrCreateUploadVersion(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := true
+	nar, err := packages_model.GetFileForVersionByName(ctx, uploadVersion.ID, filename, "")
+	if errors.Is(err, packages_model.ErrPackageFileNotExist) {
+		staged = false
+		nar, err = GetNarFile(ctx, owner.ID, filename)
+		if errors.Is(err, packages_model.ErrPackageFileNotExist) {
+			return nil, ErrNarNotUploaded
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pb, err := packages_model.GetBlobByID(ctx, nar.BlobID)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := hex.DecodeString(pb.HashSHA256)
+	if err != nil {
+		return nil, err
+	}
+	fileHash := "sha256:" + nix_module.EncodeNix32(sum)
+	if (ni.FileHash != "" && ni.FileHash != fileHash) || (ni.FileSize != 0 && ni.FileSize != pb.Size) {
+		return nil, ErrNarHashMismatch
+	}
+	ni.FileHash = fileHash
+	ni.FileSize = pb.Size
+
+	var narInfo bytes.Buffer
+	if _, err := ni.WriteTo(&narInfo); err != nil {
+		return nil, err
+	}
+	buf, err := packages_module.CreateHashedBufferFromReader(&narInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Close()
+
+	var pv *packages_model.PackageVersion
+	err = db.WithTx(ctx, func(ctx context.Context) error {
+		pv, _, err = packages_service.CreatePackageAndAddFile(
+			ctx,
+			&packages_service.PackageCreationInfo{
+				PackageInfo: packages_service.PackageInfo{
+					Owner:       owner,
+					PackageType: packages_model.TypeNix,
+					Name:        name,
+					Version:     hash,
+				},
+				Creator:  doer,
+				Metadata: ni,
+			},
+			&packages_service.PackageFileCreationInfo{
+				PackageFileInfo: packages_service.PackageFileInfo{
+					Filename: hash + ".narinfo",
+				},
+				Creator: doer,
+				Data:    buf,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err := packages_model.TryInsertFile(ctx, &packages_model.PackageFile{
+			VersionID: pv.ID,
+			BlobID:    nar.BlobID,
+			Name:      filename,
+			LowerName: strings.ToLower(filename),
+			IsLead:    true,
+		}); err != nil {
+			return err
+		}
+
+		if staged {
+			return packages_model.DeleteFileByID(ctx, nar.ID)
+		}
+		return nil
+	})
+	return pv, err
+}
+
+// Cleanup removes uploaded NAR files which were never referenced by a narinfo
+func Cleanup(ctx context.Context, olderThan time.Duration) error {
+	pvs, _, err := packages_model.SearchVersions(ctx, &packages_model.PackageSearchOptions{
+		Type: packages_model.TypeNix,
+		Name: packages_model.SearchValue{
+			ExactMatch: true,
+			Value:      nix_module.UploadPackage,
+		},
+		IsInternal: optional.Some(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, pv := range pvs {
+		pfs, _, err := packages_model.SearchFiles(ctx, &packages_model.PackageFileSearchOptions{
+			VersionID: pv.ID,
+			OlderThan: olderThan,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, pf := range pfs {
+			if err := packages_service.DeletePackageFile(ctx, pf); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/hostmatcher"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/proxy"
+	"code.gitea.io/gitea/modules/setting"
+	gitea_sync "code.gitea.io/gitea/modules/sync"
+	"code.gitea.io/gitea/modules/util"
+	packages_service "code.gitea.io/gitea/services/packages"
+)
+
+var (
+	// ErrUpstreamNotExist indicates the upstream registry does not have the requested content
+	ErrUpstreamNotExist = util.NewNotExistErrorf("content does not exist in the upstream registry")
+	// ErrInvalidUpstreamURL indicates the url of the upstream registry is invalid or not allowed
+	ErrInvalidUpstreamURL = util.NewInvalidArgumentErrorf("upstream registry url is invalid or not allowed")
+)
+
+var (
+	httpClient     *http.Client
+	allowedHosts   *hostmatcher.HostMatchList
+	httpClientOnce sync.Once
+
+	fetchLocks = gitea_sync.NewExclusivePool()
+)
+
+func initHTTPClient() {
+	httpClientOnce.Do(func() {
+		allowedHosts = hostmatcher.ParseHostMatchList("packages.PROXY_ALLOWED_HOST_LIST", setting.Packages.ProxyAllowedHostList)
+		httpClient = &http.Client{
+			Timeout: setting.Packages.ProxyTimeout,
+			Transport: &http.Transport{
+				Proxy:       proxy.Proxy(),
+				DialContext: hostmatcher.NewDialContext("packages proxy", allowedHosts, nil, setting.Proxy.ProxyURLFixed),
+			},
+		}
+	})
+}
+
+// ValidateUpstreamURL checks that the url can be used as upstream registry.
+// Host names are checked against the allowed host list when they get resolved.
+func ValidateUpstreamURL(upstream string) error {
+	initHTTPClient()
+
+	u, err := url.Parse(upstream)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrInvalidUpstreamURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !allowedHosts.MatchHostOrIP(u.Hostname(), ip) {
+		return ErrInvalidUpstreamURL
+	}
+	return nil
+}
+
+// Client fetches content from the upstream registry of a package proxy
+type Client struct {
+	Proxy *packages_model.PackageProxy
+
+	base     *url.URL
+	password string
+	token    string
+}
+
+// GetClient returns a client for the enabled proxy of the owner for the package type.
+// It returns nil if the owner did not configure an upstream registry for the type.
+func GetClient(ctx context.Context, owner *user_model.User, packageType packages_model.Type) (*Client, error) {
+	pp, err := packages_model.GetEnabledProxyByOwnerAndType(ctx, owner.ID, packageType)
+	if err != nil {
+		if errors.Is(err, util.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return NewClient(pp)
+}
+
+// NewClient creates a client for the upstream registry of the package proxy
+func NewClient(pp *packages_model.PackageProxy) (*Client, error) {
+	initHTTPClient()
+
+	base, err := url.Parse(strings.TrimSuffix(pp.URL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	password, err := pp.Password()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		Proxy:    pp,
+		base:     base,
+		password: password,
+	}, nil
+}
+
+// URL returns the absolute url of a path of the upstream registry
+func (c *Client) URL(path string) string {
+	return c.base.String() + "/" + strings.TrimPrefix(path, "/")
+}
+
+// ResolveURL resolves a reference found in a document of the upstream registry
+func (c *Client) ResolveURL(document, ref string) (string, error) {
+	base, err := url.Parse(document)
+	if err != nil {
+		return "", err
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// Get requests the url from the upstream registry. The caller must close the body of the response.
+// ErrUpstreamNotExist is returned if the upstream registry responds with 404.
+func (c *Client) Get(ctx context.Context, rawURL string, accept ...string) (*http.Response, error) {
+	resp, err := c.do(ctx, rawURL, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+			return nil, fmt.Errorf("upstream registry %s: unauthorized", c.base.Host)
+		}
+		if err := c.fetchToken(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(ctx, rawURL, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrUpstreamNotExist
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+		return nil, fmt.Errorf("upstream registry %s: unexpected status %d", c.base.Host, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, rawURL string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accept {
+		req.Header.Add("Accept", a)
+	}
+	// Only send the credentials to the upstream registry, not to the hosts it links to
+	if req.URL.Host == c.base.Host {
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.Proxy.Username != "" {
+			req.SetBasicAuth(c.Proxy.Username, c.password)
+		}
+	}
+	return httpClient.Do(req)
+}
+
+// fetchToken requests a token from the authorization service announced by a Bearer challenge
+// https://distribution.github.io/distribution/spec/auth/token/
+func (c *Client) fetchToken(ctx context.Context, challenge string) error {
+	params := parseChallenge(challenge[len("bearer "):])
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || (realm.Scheme != "http" && realm.Scheme != "https") {
+		return fmt.Errorf("upstream registry %s: invalid authentication realm", c.base.Host)
+	}
+	q := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value, ok := params[key]; ok {
+			q.Set(key, value)
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.Proxy.Username != "" {
+		req.SetBasicAuth(c.Proxy.Username, c.password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upstream registry %s: authentication failed with status %d", c.base.Host, resp.StatusCode)
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return err
+	}
+	c.token = result.Token
+	if c.token == "" {
+		c.token = result.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("upstream registry %s: authentication returned no token", c.base.Host)
+	}
+	return nil
+}
+
+// parseChallenge parses the comma separated key="value" parameters of a WWW-Authenticate challenge
+func parseChallenge(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				break
+			}
+			value, s = rest[1:end+1], rest[end+2:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+// Lock serializes the fetches of the same content to not download it multiple times
+func (c *Client) Lock(key string) func() {
+	identity := fmt.Sprintf("%d/%s/%s", c.Proxy.OwnerID, c.Proxy.Type, key)
+	fetchLocks.CheckIn(identity)
+	return func() {
+		fetchLocks.CheckOut(identity)
+	}
+}
+
+// Cache stores a file fetched from the upstream registry as a regular package file.
+// The version is marked as cached, apart from that it behaves like an uploaded one,
+// which means the cleanup rules of the owner apply to it.
+func (c *Client) Cache(ctx context.Context, pvci *packages_service.PackageCreationInfo, pfci *packages_service.PackageFileCreationInfo) (*packages_model.PackageVersion, error) {
+	if pvci.VersionProperties == nil {
+		pvci.VersionProperties = make(map[string]string, 1)
+	}
+	pvci.VersionProperties[packages_model.PropertyProxyUpstream] = c.Proxy.URL
+
+	pv, _, err := packages_service.CreatePackageOrAddFileToExisting(ctx, pvci, pfci)
+	if err != nil {
+		if err == packages_model.ErrDuplicatePackageFile {
+			return packages_model.GetVersionByNameAndVersion(ctx, pvci.Owner.ID, pvci.PackageType, pvci.Name, pvci.Version)
+		}
+		return nil, err
+	}
+
+	log.Debug("Cached %s package %s %s of %s from %s", pvci.PackageType, pvci.Name, pvci.Version, pvci.Owner.Name, c.base.Host)
+
+	return pv, nil
+}
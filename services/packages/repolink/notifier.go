@@ -0,0 +1,37 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repolink
+
+import (
+	"context"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	notify_service "code.gitea.io/gitea/services/notify"
+)
+
+type repolinkNotifier struct {
+	notify_service.NullNotifier
+}
+
+var _ notify_service.Notifier = &repolinkNotifier{}
+
+// Init registers the notifier which links new packages to their repository
+func Init() error {
+	notify_service.RegisterNotifier(NewNotifier())
+
+	return nil
+}
+
+// NewNotifier creates a new repolinkNotifier notifier
+func NewNotifier() notify_service.Notifier {
+	return &repolinkNotifier{}
+}
+
+func (*repolinkNotifier) PackageCreate(ctx context.Context, _ *user_model.User, pd *packages_model.PackageDescriptor) {
+	if err := LinkRepository(ctx, pd); err != nil {
+		log.Error("LinkRepository [%d]: %v", pd.Package.ID, err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repolink
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	actions_model "code.gitea.io/gitea/models/actions"
+	packages_model "code.gitea.io/gitea/models/packages"
+	repo_model "code.gitea.io/gitea/models/repo"
+	giturl "code.gitea.io/gitea/modules/git/url"
+	cargo_module "code.gitea.io/gitea/modules/packages/cargo"
+	container_module "code.gitea.io/gitea/modules/packages/container"
+	npm_module "code.gitea.io/gitea/modules/packages/npm"
+	pypi_module "code.gitea.io/gitea/modules/packages/pypi"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/web/middleware"
+)
+
+// LinkRepository links the package to the repository it was published from if it is not linked yet
+func LinkRepository(ctx context.Context, pd *packages_model.PackageDescriptor) error {
+	if pd.Package.RepoID != 0 {
+		return nil
+	}
+
+	repo, err := InferRepository(ctx, pd)
+	if err != nil || repo == nil {
+		return err
+	}
+
+	if err := packages_model.SetRepositoryLink(ctx, pd.Package.ID, repo.ID); err != nil {
+		return err
+	}
+	pd.Package.RepoID = repo.ID
+	pd.Repository = repo
+	return nil
+}
+
+// InferRepository finds the repository of the package owner the package version was published from.
+// The repository of the Actions task which published the package takes precedence over
+// the repository URLs declared in the package metadata.
+func InferRepository(ctx context.Context, pd *packages_model.PackageDescriptor) (*repo_model.Repository, error) {
+	if taskID := actionsTaskID(ctx); taskID != 0 {
+		task, err := actions_model.GetTaskByID(ctx, taskID)
+		if err != nil {
+			return nil, err
+		}
+		if task.OwnerID == pd.Owner.ID {
+			return repo_model.GetRepositoryByID(ctx, task.RepoID)
+		}
+	}
+
+	for _, u := range RepositoryURLs(pd) {
+		owner, name, ok := ParseRepositoryURL(u)
+		if !ok || !strings.EqualFold(owner, pd.Owner.Name) {
+			continue
+		}
+
+		repo, err := repo_model.GetRepositoryByOwnerAndName(ctx, owner, name)
+		if err != nil {
+			if repo_model.IsErrRepoNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return repo, nil
+	}
+	return nil, nil
+}
+
+func actionsTaskID(ctx context.Context) int64 {
+	data := middleware.GetContextData(ctx)
+	if isActionsToken, _ := data["IsActionsToken"].(bool); !isActionsToken {
+		return 0
+	}
+	taskID, _ := data["ActionsTaskID"].(int64)
+	return taskID
+}
+
+// RepositoryURLs returns the source repository and project URLs declared in the package metadata
+func RepositoryURLs(pd *packages_model.PackageDescriptor) []string {
+	var urls []string
+	switch m := pd.Metadata.(type) {
+	case *cargo_module.Metadata:
+		urls = []string{m.RepositoryURL, m.ProjectURL}
+	case *container_module.Metadata:
+		urls = []string{m.RepositoryURL, m.ProjectURL}
+	case *npm_module.Metadata:
+		urls = []string{m.Repository.URL, m.ProjectURL}
+	case *pypi_module.Metadata:
+		for _, u := range m.ProjectURLs {
+			urls = append(urls, u)
+		}
+		urls = append(urls, m.ProjectURL)
+	}
+
+	result := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if u != "" {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// ParseRepositoryURL extracts the owner and repository name of an HTTP(S) or SSH URL pointing at this instance.
+// Additional path segments like /src/branch/main are ignored.
+func ParseRepositoryURL(rawURL string) (owner, name string, ok bool) {
+	u, err := giturl.Parse(strings.TrimPrefix(strings.TrimSpace(rawURL), "git+"))
+	if err != nil {
+		return "", "", false
+	}
+
+	var p string
+	switch u.Scheme {
+	case "http", "https":
+		appURL, err := url.Parse(setting.AppURL)
+		if err != nil || !strings.EqualFold(u.Host, appURL.Host) {
+			return "", "", false
+		}
+		prefix := strings.TrimSuffix(appURL.Path, "/") + "/"
+		if !strings.HasPrefix(u.Path, prefix) {
+			return "", "", false
+		}
+		p = strings.TrimPrefix(u.Path, prefix)
+	case "ssh", "git":
+		if !strings.EqualFold(u.Hostname(), setting.SSH.Domain) {
+			return "", "", false
+		}
+		p = u.Path
+	default:
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	owner = parts[0]
+	name = strings.TrimSuffix(parts[1], ".git")
+	if owner == "" || name == "" {
+		return "", "", false
+	}
+	return owner, name, true
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repolink
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepositoryURL(t *testing.T) {
+	defer test.MockVariableValue(&setting.AppURL, "https://forgejo.example.com/sub/")()
+	defer test.MockVariableValue(&setting.SSH.Domain, "forgejo.example.com")()
+
+	for rawURL, expected := range map[string][2]string{
+		"https://forgejo.example.com/sub/owner/repo":                 {"owner", "repo"},
+		"https://forgejo.example.com/sub/owner/repo.git":             {"owner", "repo"},
+		"git+https://forgejo.example.com/sub/owner/repo.git":         {"owner", "repo"},
+		"https://forgejo.example.com/sub/owner/repo/src/branch/main": {"owner", "repo"},
+		"git@forgejo.example.com:owner/repo.git":                     {"owner", "repo"},
+		"ssh://git@forgejo.example.com:2222/owner/repo.git":          {"owner", "repo"},
+		"git+ssh://git@forgejo.example.com/owner/repo.git":           {"owner", "repo"},
+		"https://forgejo.example.com/owner/repo":                     {},
+		"https://github.com/sub/owner/repo":                          {},
+		"https://forgejo.example.com/sub/owner":                      {},
+		"ftp://forgejo.example.com/sub/owner/repo":                   {},
+		"owner/repo": {},
+		"":           {},
+	} {
+		owner, name, ok := ParseRepositoryURL(rawURL)
+		assert.Equal(t, expected[0] != "", ok, rawURL)
+		assert.Equal(t, expected[0], owner, rawURL)
+		assert.Equal(t, expected[1], name, rawURL)
+	}
+}
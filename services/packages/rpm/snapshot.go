@@ -0,0 +1,138 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package rpm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	rpm_module "code.gitea.io/gitea/modules/packages/rpm"
+	"code.gitea.io/gitea/modules/util"
+	packages_service "code.gitea.io/gitea/services/packages"
+)
+
+var ErrGroupNotExist = util.NewNotExistErrorf("group does not exist")
+
+// PromotePackage identifies a package version to promote
+type PromotePackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// CreateSnapshot freezes the repository files of the group and the packages they list
+func CreateSnapshot(ctx context.Context, ownerID int64, name, group string) (*packages_service.Snapshot, error) {
+	return packages_service.CreateSnapshot(ctx, ownerID, packages_model.TypeRpm, rpm_module.RepositoryPackage, name, group, func(ctx context.Context, snapshotVersion *packages_model.PackageVersion) error {
+		repoVersion, err := GetOrCreateRepositoryVersion(ctx, ownerID)
+		if err != nil {
+			return err
+		}
+
+		pfs, _, err := packages_model.SearchFiles(ctx, &packages_model.PackageFileSearchOptions{
+			VersionID:    repoVersion.ID,
+			CompositeKey: group,
+		})
+		if err != nil {
+			return err
+		}
+
+		packageFiles, _, err := packages_model.SearchFiles(ctx, &packages_model.PackageFileSearchOptions{
+			OwnerID:      ownerID,
+			PackageType:  packages_model.TypeRpm,
+			Query:        "%.rpm",
+			CompositeKey: group,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !slices.ContainsFunc(pfs, func(pf *packages_model.PackageFile) bool { return pf.CompositeKey == group }) {
+			return ErrGroupNotExist
+		}
+
+		for _, pf := range append(pfs, packageFiles...) {
+			// the search ignores an empty composite key which is used by the root group
+			if pf.CompositeKey != group {
+				continue
+			}
+			if _, err := packages_service.CopyPackageFile(ctx, snapshotVersion, pf, pf.CompositeKey, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetSnapshot gets the snapshot and the internal package version holding its files
+func GetSnapshot(ctx context.Context, ownerID int64, name string) (*packages_service.Snapshot, *packages_model.PackageVersion, error) {
+	return packages_service.GetSnapshot(ctx, ownerID, packages_model.TypeRpm, rpm_module.RepositoryPackage, name)
+}
+
+// GetSnapshots gets all snapshots of the repository
+func GetSnapshots(ctx context.Context, ownerID int64) ([]*packages_service.Snapshot, error) {
+	return packages_service.GetSnapshots(ctx, ownerID, packages_model.TypeRpm, rpm_module.RepositoryPackage)
+}
+
+// DeleteSnapshot deletes the snapshot
+func DeleteSnapshot(ctx context.Context, ownerID int64, name string) error {
+	return packages_service.DeleteSnapshot(ctx, ownerID, packages_model.TypeRpm, rpm_module.RepositoryPackage, name)
+}
+
+// Promote adds the package versions of the source group to the target group.
+// Packages which are already part of the target group are skipped.
+func Promote(ctx context.Context, ownerID int64, from, to string, packages []*PromotePackage) error {
+	if from == to {
+		return util.NewInvalidArgumentErrorf("invalid groups")
+	}
+	if len(packages) == 0 {
+		return util.NewInvalidArgumentErrorf("no packages to promote")
+	}
+
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		for _, p := range packages {
+			pv, err := packages_model.GetVersionByNameAndVersion(ctx, ownerID, packages_model.TypeRpm, p.Name, p.Version)
+			if err != nil {
+				if errors.Is(err, util.ErrNotExist) {
+					return util.NewNotExistErrorf("package %s %s does not exist", p.Name, p.Version)
+				}
+				return err
+			}
+
+			pfs, err := packages_model.GetFilesByVersionID(ctx, pv.ID)
+			if err != nil {
+				return err
+			}
+
+			found := false
+			for _, pf := range pfs {
+				if !pf.IsLead || pf.CompositeKey != from {
+					continue
+				}
+				found = true
+
+				_, err := packages_service.CopyPackageFile(ctx, pv, pf, to, map[string]string{
+					rpm_module.PropertyGroup: to,
+				})
+				if err != nil && err != packages_model.ErrDuplicatePackageFile {
+					return err
+				}
+			}
+			if !found {
+				return util.NewNotExistErrorf("package %s %s is not part of group %s", p.Name, p.Version, from)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := BuildSpecificRepositoryFiles(ctx, ownerID, to); err != nil {
+		return fmt.Errorf("failed to build repository files [%s]: %w", to, err)
+	}
+	return nil
+}
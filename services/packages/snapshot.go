@@ -0,0 +1,189 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package packages
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/optional"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+)
+
+var (
+	ErrInvalidSnapshotName = util.NewInvalidArgumentErrorf("snapshot name is invalid")
+	ErrSnapshotExist       = util.NewAlreadyExistErrorf("snapshot already exists")
+	ErrSnapshotNotExist    = util.NewNotExistErrorf("snapshot does not exist")
+
+	snapshotNamePattern = regexp.MustCompile(`\A[a-zA-Z0-9][a-zA-Z0-9._-]{0,63}\z`)
+)
+
+// snapshotVersionPrefix prefixes the internal versions of the repository package which hold the snapshots
+const snapshotVersionPrefix = "_snapshot_"
+
+// Snapshot is an immutable copy of the index files of a repository and of the package files they list
+type Snapshot struct {
+	Name        string
+	Source      string
+	CreatedUnix timeutil.TimeStamp
+}
+
+type snapshotMetadata struct {
+	Source string `json:"source"`
+}
+
+// CreateSnapshot creates a snapshot of the source (a distribution or group) in the internal repository package.
+// The files of the snapshot are added by fill.
+func CreateSnapshot(ctx context.Context, ownerID int64, packageType packages_model.Type, repositoryPackage, name, source string, fill func(context.Context, *packages_model.PackageVersion) error) (*Snapshot, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return nil, ErrInvalidSnapshotName
+	}
+
+	var s *Snapshot
+
+	return s, db.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := packages_model.GetInternalVersionByNameAndVersion(ctx, ownerID, packageType, repositoryPackage, snapshotVersionPrefix+name); err == nil {
+			return ErrSnapshotExist
+		} else if err != packages_model.ErrPackageNotExist {
+			return err
+		}
+
+		pv, err := GetOrCreateInternalPackageVersion(ctx, ownerID, packageType, repositoryPackage, snapshotVersionPrefix+name)
+		if err != nil {
+			return err
+		}
+
+		metadataJSON, err := json.Marshal(&snapshotMetadata{Source: source})
+		if err != nil {
+			return err
+		}
+		pv.MetadataJSON = string(metadataJSON)
+		if err := packages_model.UpdateVersion(ctx, pv); err != nil {
+			return err
+		}
+
+		if err := fill(ctx, pv); err != nil {
+			return err
+		}
+
+		s, err = toSnapshot(pv)
+		return err
+	})
+}
+
+// GetSnapshot gets the snapshot and the internal package version holding its files
+func GetSnapshot(ctx context.Context, ownerID int64, packageType packages_model.Type, repositoryPackage, name string) (*Snapshot, *packages_model.PackageVersion, error) {
+	pv, err := packages_model.GetInternalVersionByNameAndVersion(ctx, ownerID, packageType, repositoryPackage, snapshotVersionPrefix+name)
+	if err != nil {
+		if err == packages_model.ErrPackageNotExist {
+			return nil, nil, ErrSnapshotNotExist
+		}
+		return nil, nil, err
+	}
+
+	s, err := toSnapshot(pv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, pv, nil
+}
+
+// GetSnapshots gets all snapshots of the repository
+func GetSnapshots(ctx context.Context, ownerID int64, packageType packages_model.Type, repositoryPackage string) ([]*Snapshot, error) {
+	pvs, _, err := packages_model.SearchVersions(ctx, &packages_model.PackageSearchOptions{
+		OwnerID: ownerID,
+		Type:    packageType,
+		Name: packages_model.SearchValue{
+			ExactMatch: true,
+			Value:      repositoryPackage,
+		},
+		IsInternal: optional.Some(true),
+		Sort:       packages_model.SortVersionAsc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0, len(pvs))
+	for _, pv := range pvs {
+		if !strings.HasPrefix(pv.LowerVersion, snapshotVersionPrefix) {
+			continue
+		}
+
+		s, err := toSnapshot(pv)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot deletes the snapshot and its files
+func DeleteSnapshot(ctx context.Context, ownerID int64, packageType packages_model.Type, repositoryPackage, name string) error {
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		_, pv, err := GetSnapshot(ctx, ownerID, packageType, repositoryPackage, name)
+		if err != nil {
+			return err
+		}
+
+		return DeletePackageVersionAndReferences(ctx, pv)
+	})
+}
+
+func toSnapshot(pv *packages_model.PackageVersion) (*Snapshot, error) {
+	var metadata snapshotMetadata
+	if err := json.Unmarshal([]byte(pv.MetadataJSON), &metadata); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Name:        strings.TrimPrefix(pv.Version, snapshotVersionPrefix),
+		Source:      metadata.Source,
+		CreatedUnix: pv.CreatedUnix,
+	}, nil
+}
+
+// CopyPackageFile adds a file to the package version which shares the blob of an existing file.
+// The properties of the existing file are copied, the given properties replace them.
+// If a file with the same name and composite key exists already, ErrDuplicatePackageFile is returned.
+func CopyPackageFile(ctx context.Context, pv *packages_model.PackageVersion, pf *packages_model.PackageFile, compositeKey string, properties map[string]string) (*packages_model.PackageFile, error) {
+	pps, err := packages_model.GetProperties(ctx, packages_model.PropertyTypeFile, pf.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := &packages_model.PackageFile{
+		VersionID:    pv.ID,
+		BlobID:       pf.BlobID,
+		Name:         pf.Name,
+		LowerName:    pf.LowerName,
+		CompositeKey: compositeKey,
+		IsLead:       pf.IsLead,
+	}
+	if copied, err = packages_model.TryInsertFile(ctx, copied); err != nil {
+		return copied, err
+	}
+
+	for _, pp := range pps {
+		if _, ok := properties[pp.Name]; ok {
+			continue
+		}
+		if _, err := packages_model.InsertProperty(ctx, packages_model.PropertyTypeFile, copied.ID, pp.Name, pp.Value); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range properties {
+		if _, err := packages_model.InsertProperty(ctx, packages_model.PropertyTypeFile, copied.ID, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package vulnerability
+
+import (
+	"regexp"
+	"strings"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	cargo_module "code.gitea.io/gitea/modules/packages/cargo"
+	goproxy_module "code.gitea.io/gitea/modules/packages/goproxy"
+	maven_module "code.gitea.io/gitea/modules/packages/maven"
+	npm_module "code.gitea.io/gitea/modules/packages/npm"
+	"code.gitea.io/gitea/modules/packages/osv"
+	pypi_module "code.gitea.io/gitea/modules/packages/pypi"
+)
+
+// Dependency is a package of an ecosystem which is matched against the advisories
+type Dependency struct {
+	Ecosystem string
+	Name      string
+	Version   string
+}
+
+// IsSupportedType checks if versions of the package type can be scanned
+func IsSupportedType(t packages_model.Type) bool {
+	switch t {
+	case packages_model.TypeCargo, packages_model.TypeGo, packages_model.TypeMaven, packages_model.TypeNpm, packages_model.TypePyPI:
+		return true
+	}
+	return false
+}
+
+// Dependencies returns the package version itself and its declared dependencies.
+// Dependencies are matched with the lowest version allowed by their requirement.
+func Dependencies(pd *packages_model.PackageDescriptor) []*Dependency {
+	var deps []*Dependency
+	add := func(ecosystem, name, version string) {
+		if name == "" || version == "" {
+			return
+		}
+		deps = append(deps, &Dependency{Ecosystem: ecosystem, Name: name, Version: version})
+	}
+
+	switch pd.Package.Type {
+	case packages_model.TypeCargo:
+		add(osv.EcosystemCratesIO, pd.Package.Name, pd.Version.Version)
+		if m, ok := pd.Metadata.(*cargo_module.Metadata); ok {
+			for _, d := range m.Dependencies {
+				add(osv.EcosystemCratesIO, d.Name, osv.LowestVersion(d.Req))
+			}
+		}
+	case packages_model.TypeGo:
+		add(osv.EcosystemGo, pd.Package.Name, strings.TrimPrefix(pd.Version.Version, "v"))
+		for _, r := range ParseGoModRequires(pd.VersionProperties.GetByName(goproxy_module.PropertyGoMod)) {
+			add(osv.EcosystemGo, r.Name, strings.TrimPrefix(r.Version, "v"))
+		}
+	case packages_model.TypeMaven:
+		if m, ok := pd.Metadata.(*maven_module.Metadata); ok {
+			if m.GroupID != "" && m.ArtifactID != "" {
+				add(osv.EcosystemMaven, m.GroupID+":"+m.ArtifactID, pd.Version.Version)
+			}
+			for _, d := range m.Dependencies {
+				if d.GroupID != "" && d.ArtifactID != "" {
+					add(osv.EcosystemMaven, d.GroupID+":"+d.ArtifactID, osv.LowestVersion(d.Version))
+				}
+			}
+		}
+	case packages_model.TypeNpm:
+		add(osv.EcosystemNpm, pd.Package.Name, pd.Version.Version)
+		if m, ok := pd.Metadata.(*npm_module.Metadata); ok {
+			for _, deps := range []map[string]string{m.Dependencies, m.OptionalDependencies} {
+				for name, req := range deps {
+					add(osv.EcosystemNpm, name, osv.LowestVersion(req))
+				}
+			}
+		}
+	case packages_model.TypePyPI:
+		add(osv.EcosystemPyPI, pd.Package.Name, pd.Version.Version)
+		if m, ok := pd.Metadata.(*pypi_module.Metadata); ok {
+			for _, r := range ParseRequiresDist(m.RequiresDist) {
+				add(osv.EcosystemPyPI, r.Name, r.Version)
+			}
+		}
+	}
+	return deps
+}
+
+var requirementNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+
+// ParseRequiresDist parses Python requirements like "requests (>=2.0) ; extra == 'socks'"
+// https://packaging.python.org/en/latest/specifications/dependency-specifiers/
+func ParseRequiresDist(requirements []string) []*Dependency {
+	deps := make([]*Dependency, 0, len(requirements))
+	for _, r := range requirements {
+		r, _, _ = strings.Cut(r, ";")
+		r = strings.TrimSpace(r)
+
+		name := requirementNamePattern.FindString(r)
+		if name == "" {
+			continue
+		}
+		deps = append(deps, &Dependency{
+			Ecosystem: osv.EcosystemPyPI,
+			Name:      name,
+			Version:   osv.LowestVersion(r[len(name):]),
+		})
+	}
+	return deps
+}
+
+// ParseGoModRequires parses the require directives of a go.mod file
+func ParseGoModRequires(gomod string) []*Dependency {
+	var deps []*Dependency
+	inBlock := false
+	for _, line := range strings.Split(gomod, "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if inBlock {
+			if fields[0] == ")" {
+				inBlock = false
+				continue
+			}
+		} else {
+			if fields[0] != "require" {
+				continue
+			}
+			fields = fields[1:]
+			if len(fields) == 1 && fields[0] == "(" {
+				inBlock = true
+				continue
+			}
+		}
+
+		if len(fields) == 2 {
+			deps = append(deps, &Dependency{
+				Ecosystem: osv.EcosystemGo,
+				Name:      strings.Trim(fields[0], `"`),
+				Version:   fields[1],
+			})
+		}
+	}
+	return deps
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package vulnerability
+
+import (
+	"testing"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	npm_module "code.gitea.io/gitea/modules/packages/npm"
+	"code.gitea.io/gitea/modules/packages/osv"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequiresDist(t *testing.T) {
+	deps := ParseRequiresDist([]string{
+		"requests (>=2.20.0)",
+		"urllib3<3,>=1.21.1",
+		"PySocks!=1.5.7,>=1.5.6; extra == 'socks'",
+		"certifi",
+		"",
+	})
+
+	assert.Equal(t, []*Dependency{
+		{Ecosystem: osv.EcosystemPyPI, Name: "requests", Version: "2.20.0"},
+		{Ecosystem: osv.EcosystemPyPI, Name: "urllib3", Version: "1.21.1"},
+		{Ecosystem: osv.EcosystemPyPI, Name: "PySocks", Version: "1.5.6"},
+		{Ecosystem: osv.EcosystemPyPI, Name: "certifi", Version: ""},
+	}, deps)
+}
+
+func TestParseGoModRequires(t *testing.T) {
+	deps := ParseGoModRequires(`module example.com/mod
+
+go 1.22
+
+require golang.org/x/text v0.3.7
+
+require (
+	github.com/stretchr/testify v1.9.0
+	// comment
+	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
+)
+
+replace example.com/other => ../other
+`)
+
+	assert.Equal(t, []*Dependency{
+		{Ecosystem: osv.EcosystemGo, Name: "golang.org/x/text", Version: "v0.3.7"},
+		{Ecosystem: osv.EcosystemGo, Name: "github.com/stretchr/testify", Version: "v1.9.0"},
+		{Ecosystem: osv.EcosystemGo, Name: "golang.org/x/net", Version: "v0.0.0-20220722155237-a158d28d115b"},
+	}, deps)
+}
+
+func TestDependencies(t *testing.T) {
+	pd := &packages_model.PackageDescriptor{
+		Package: &packages_model.Package{Type: packages_model.TypeNpm, Name: "@scope/test"},
+		Version: &packages_model.PackageVersion{Version: "1.0.0"},
+		Metadata: &npm_module.Metadata{
+			Dependencies: map[string]string{
+				"lodash": "^4.17.15",
+				"latest": "latest",
+			},
+		},
+	}
+
+	assert.ElementsMatch(t, []*Dependency{
+		{Ecosystem: osv.EcosystemNpm, Name: "@scope/test", Version: "1.0.0"},
+		{Ecosystem: osv.EcosystemNpm, Name: "lodash", Version: "4.17.15"},
+	}, Dependencies(pd))
+
+	pd.Package.Type = packages_model.TypeGeneric
+	assert.Empty(t, Dependencies(pd))
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package vulnerability
+
+import (
+	"context"
+
+	packages_model "code.gitea.io/gitea/models/packages"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	notify_service "code.gitea.io/gitea/services/notify"
+)
+
+type vulnerabilityNotifier struct {
+	notify_service.NullNotifier
+}
+
+var _ notify_service.Notifier = &vulnerabilityNotifier{}
+
+// Init registers the notifier which scans new package versions
+func Init() error {
+	notify_service.RegisterNotifier(NewNotifier())
+
+	return nil
+}
+
+// NewNotifier creates a new vulnerabilityNotifier notifier
+func NewNotifier() notify_service.Notifier {
+	return &vulnerabilityNotifier{}
+}
+
+func (*vulnerabilityNotifier) PackageCreate(ctx context.Context, _ *user_model.User, pd *packages_model.PackageDescriptor) {
+	if err := ScanVersion(ctx, pd); err != nil {
+		log.Error("ScanVersion [%d]: %v", pd.Version.ID, err)
+	}
+}
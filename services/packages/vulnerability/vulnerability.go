@@ -0,0 +1,245 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package vulnerability
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/modules/container"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/optional"
+	"code.gitea.io/gitea/modules/packages/osv"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+)
+
+var ErrInvalidArchive = util.NewInvalidArgumentErrorf("advisory archive is invalid")
+
+// ImportArchive imports the advisories of a zip archive containing OSV JSON files,
+// like the all.zip exports of https://osv.dev for an ecosystem.
+// It returns the number of imported advisories.
+func ImportArchive(ctx context.Context, r io.ReaderAt, size int64) (int, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	count := 0
+	for _, f := range zr.File {
+		select {
+		case <-ctx.Done():
+			return count, db.ErrCancelledf("While importing advisories")
+		default:
+		}
+
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(f.Name), ".json") {
+			continue
+		}
+
+		e, err := readEntry(f)
+		if err != nil {
+			if errors.Is(err, osv.ErrInvalidEntry) {
+				log.Warn("Skipping advisory %s: %v", f.Name, err)
+				continue
+			}
+			return count, err
+		}
+
+		imported, err := ImportEntry(ctx, e)
+		if err != nil {
+			return count, err
+		}
+		if imported {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func readEntry(f *zip.File) (*osv.Entry, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return osv.ParseEntry(rc)
+}
+
+// ImportEntry stores the advisory for every affected package of a supported ecosystem.
+// A withdrawn advisory is removed. It returns false if the advisory affects no supported package.
+func ImportEntry(ctx context.Context, e *osv.Entry) (bool, error) {
+	if e.Withdrawn != nil {
+		return false, packages_model.DeleteAdvisory(ctx, e.ID)
+	}
+
+	type key struct {
+		Ecosystem string
+		Name      string
+	}
+
+	grouped := make(map[key][]*osv.Affected)
+	var keys []key
+	for _, a := range e.Affected {
+		if !slices.Contains(osv.Ecosystems, a.Package.Ecosystem) {
+			continue
+		}
+		k := key{a.Package.Ecosystem, osv.NormalizeName(a.Package.Ecosystem, a.Package.Name)}
+		if _, ok := grouped[k]; !ok {
+			keys = append(keys, k)
+		}
+		grouped[k] = append(grouped[k], a)
+	}
+
+	pas := make([]*packages_model.PackageAdvisory, 0, len(keys))
+	for _, k := range keys {
+		affected := grouped[k]
+
+		severity := osv.SeverityUnknown
+		for _, a := range affected {
+			if s := e.SeverityLevel(a); osv.SeverityRank(s) > osv.SeverityRank(severity) {
+				severity = s
+			}
+		}
+
+		affectedJSON, err := json.Marshal(affected)
+		if err != nil {
+			return false, err
+		}
+
+		pas = append(pas, &packages_model.PackageAdvisory{
+			AdvisoryID:   e.ID,
+			Ecosystem:    k.Ecosystem,
+			PackageName:  k.Name,
+			Aliases:      e.Aliases,
+			Summary:      e.Summary,
+			Severity:     severity,
+			AffectedJSON: string(affectedJSON),
+			ModifiedUnix: timeutil.TimeStamp(e.Modified.Unix()),
+		})
+	}
+
+	if err := packages_model.ReplaceAdvisory(ctx, e.ID, pas); err != nil {
+		return false, err
+	}
+	return len(pas) > 0, nil
+}
+
+// ScanVersion matches the package version and its dependencies against the imported advisories
+// and replaces the stored findings.
+func ScanVersion(ctx context.Context, pd *packages_model.PackageDescriptor) error {
+	if !IsSupportedType(pd.Package.Type) {
+		return nil
+	}
+
+	type key struct {
+		AdvisoryID string
+		Name       string
+	}
+
+	seen := make(container.Set[key])
+	pvs := make([]*packages_model.PackageVulnerability, 0, 5)
+	for _, dep := range Dependencies(pd) {
+		name := osv.NormalizeName(dep.Ecosystem, dep.Name)
+
+		pas, err := packages_model.GetAdvisoriesByPackage(ctx, dep.Ecosystem, name)
+		if err != nil {
+			return err
+		}
+
+		for _, pa := range pas {
+			if seen.Contains(key{pa.AdvisoryID, name}) {
+				continue
+			}
+
+			var affected []*osv.Affected
+			if err := json.Unmarshal([]byte(pa.AffectedJSON), &affected); err != nil {
+				log.Error("Invalid affected ranges of advisory %s: %v", pa.AdvisoryID, err)
+				continue
+			}
+
+			if !slices.ContainsFunc(affected, func(a *osv.Affected) bool { return a.IsAffected(dep.Version) }) {
+				continue
+			}
+
+			seen.Add(key{pa.AdvisoryID, name})
+			pvs = append(pvs, &packages_model.PackageVulnerability{
+				VersionID:      pd.Version.ID,
+				AdvisoryID:     pa.AdvisoryID,
+				Ecosystem:      dep.Ecosystem,
+				PackageName:    name,
+				PackageVersion: dep.Version,
+				Severity:       pa.Severity,
+				Summary:        pa.Summary,
+			})
+		}
+	}
+
+	return packages_model.ReplaceVulnerabilities(ctx, pd.Version.ID, pvs)
+}
+
+// ScanAll rescans all package versions of the supported types
+func ScanAll(ctx context.Context) error {
+	for _, t := range packages_model.TypeList {
+		if !IsSupportedType(t) {
+			continue
+		}
+
+		pvs, _, err := packages_model.SearchVersions(ctx, &packages_model.PackageSearchOptions{
+			Type:       t,
+			IsInternal: optional.Some(false),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, pv := range pvs {
+			select {
+			case <-ctx.Done():
+				return db.ErrCancelledf("While scanning package vulnerabilities")
+			default:
+			}
+
+			pd, err := packages_model.GetPackageDescriptor(ctx, pv)
+			if err != nil {
+				return err
+			}
+			if err := ScanVersion(ctx, pd); err != nil {
+				return fmt.Errorf("ScanVersion [%d]: %w", pv.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetVulnerabilities gets the findings of the package version, the most severe first
+func GetVulnerabilities(ctx context.Context, versionID int64) ([]*packages_model.PackageVulnerability, error) {
+	pvs, err := packages_model.GetVulnerabilitiesByVersionID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(pvs, func(a, b *packages_model.PackageVulnerability) int {
+		return osv.SeverityRank(b.Severity) - osv.SeverityRank(a.Severity)
+	})
+	return pvs, nil
+}
+
+// IsDownloadBlocked checks if downloads of the package version are refused because of critical advisories
+func IsDownloadBlocked(ctx context.Context, versionID int64) (bool, error) {
+	if !setting.Packages.BlockCriticalVulnerabilities {
+		return false, nil
+	}
+	return packages_model.HasVulnerabilitiesWithSeverity(ctx, versionID, osv.SeverityCritical)
+}
@@ -399,6 +399,10 @@ func Init() error {
 	}
 
 	go graceful.GetManager().RunWithCancel(prPatchCheckerQueue)
+
+	if err := initStackRebaseQueue(); err != nil {
+		return err
+	}
 	go graceful.GetManager().RunWithShutdownContext(InitializePullRequests)
 	return nil
 }
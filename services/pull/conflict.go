@@ -0,0 +1,563 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package pull
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.gitea.io/gitea/models"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// ConflictSide selects which version of a conflicting hunk or file is used as resolution.
+// "Ours" is always the head branch of the pull request and "theirs" the base branch.
+type ConflictSide string
+
+const (
+	ConflictSideOurs   ConflictSide = "ours"
+	ConflictSideTheirs ConflictSide = "theirs"
+	ConflictSideBoth   ConflictSide = "both" // ours followed by theirs, only valid for hunks
+)
+
+const (
+	conflictMarkerOurs   = "<<<<<<< ours"
+	conflictMarkerBase   = "||||||| base"
+	conflictMarkerSplit  = "======="
+	conflictMarkerTheirs = ">>>>>>> theirs"
+)
+
+// ErrUnresolvedConflicts represents an attempt to resolve conflicts which left some files unresolved
+type ErrUnresolvedConflicts struct {
+	Paths []string
+}
+
+// IsErrUnresolvedConflicts checks if an error is a ErrUnresolvedConflicts.
+func IsErrUnresolvedConflicts(err error) bool {
+	_, ok := err.(ErrUnresolvedConflicts)
+	return ok
+}
+
+func (err ErrUnresolvedConflicts) Error() string {
+	return fmt.Sprintf("conflicts are not resolved [paths: %s]", strings.Join(err.Paths, ", "))
+}
+
+func (err ErrUnresolvedConflicts) Unwrap() error {
+	return util.ErrInvalidArgument
+}
+
+// ConflictHunk is a section of a conflicted file, either content both sides agree on or a conflict
+type ConflictHunk struct {
+	Conflict bool
+	Index    int // position among the conflicting hunks of the file
+	Common   string
+	Ours     string
+	Base     string
+	Theirs   string
+}
+
+// ConflictedFile is a file which could not be merged automatically
+type ConflictedFile struct {
+	Path string
+	// Editable is false when the conflict can only be resolved by choosing a complete side:
+	// binary or too large files, deletions, symbolic links, submodules and mode changes
+	Editable    bool
+	OursExist   bool
+	TheirsExist bool
+	Hunks       []*ConflictHunk
+}
+
+// NumConflicts returns the number of conflicting hunks in the file
+func (f *ConflictedFile) NumConflicts() int {
+	n := 0
+	for _, hunk := range f.Hunks {
+		if hunk.Conflict {
+			n++
+		}
+	}
+	return n
+}
+
+// Resolve assembles the content of the file using one choice per conflicting hunk
+func (f *ConflictedFile) Resolve(choices []ConflictSide) (string, error) {
+	if !f.Editable {
+		return "", util.NewInvalidArgumentErrorf("%s can not be resolved by hunks", f.Path)
+	}
+	if len(choices) != f.NumConflicts() {
+		return "", util.NewInvalidArgumentErrorf("%s has %d conflicts but %d choices were given", f.Path, f.NumConflicts(), len(choices))
+	}
+
+	var sb strings.Builder
+	i := 0
+	for _, hunk := range f.Hunks {
+		if !hunk.Conflict {
+			sb.WriteString(hunk.Common)
+			continue
+		}
+		switch choices[i] {
+		case ConflictSideOurs:
+			sb.WriteString(hunk.Ours)
+		case ConflictSideTheirs:
+			sb.WriteString(hunk.Theirs)
+		case ConflictSideBoth:
+			sb.WriteString(hunk.Ours)
+			sb.WriteString(hunk.Theirs)
+		default:
+			return "", util.NewInvalidArgumentErrorf("invalid choice %q for conflict %d of %s", choices[i], i+1, f.Path)
+		}
+		i++
+	}
+	return sb.String(), nil
+}
+
+// MarkedContent returns the content of the file with the conflicts highlighted by conflict markers
+func (f *ConflictedFile) MarkedContent() string {
+	var sb strings.Builder
+	for _, hunk := range f.Hunks {
+		if !hunk.Conflict {
+			sb.WriteString(hunk.Common)
+			continue
+		}
+		sb.WriteString(conflictMarkerOurs + "\n")
+		sb.WriteString(hunk.Ours)
+		sb.WriteString(conflictMarkerSplit + "\n")
+		sb.WriteString(hunk.Theirs)
+		sb.WriteString(conflictMarkerTheirs + "\n")
+	}
+	return sb.String()
+}
+
+// HasConflictMarkers checks whether the content still contains the conflict markers of MarkedContent
+func HasConflictMarkers(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == conflictMarkerOurs || line == conflictMarkerTheirs {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConflictHunks splits the output of `git merge-file -p --diff3` in to hunks
+func parseConflictHunks(merged string) []*ConflictHunk {
+	const (
+		stateCommon = iota
+		stateOurs
+		stateBase
+		stateTheirs
+	)
+
+	hunks := make([]*ConflictHunk, 0, 3)
+	current := &ConflictHunk{}
+	state := stateCommon
+	conflicts := 0
+	for _, line := range strings.SplitAfter(merged, "\n") {
+		if line == "" {
+			continue
+		}
+		marker := strings.TrimRight(line, "\r\n")
+		switch {
+		case state == stateCommon && marker == conflictMarkerOurs:
+			if current.Common != "" {
+				hunks = append(hunks, current)
+			}
+			current = &ConflictHunk{Conflict: true, Index: conflicts}
+			conflicts++
+			state = stateOurs
+		case state == stateOurs && marker == conflictMarkerBase:
+			state = stateBase
+		case (state == stateOurs || state == stateBase) && marker == conflictMarkerSplit:
+			state = stateTheirs
+		case state == stateTheirs && marker == conflictMarkerTheirs:
+			hunks = append(hunks, current)
+			current = &ConflictHunk{}
+			state = stateCommon
+		case state == stateOurs:
+			current.Ours += line
+		case state == stateBase:
+			current.Base += line
+		case state == stateTheirs:
+			current.Theirs += line
+		default:
+			current.Common += line
+		}
+	}
+	if current.Conflict || current.Common != "" {
+		hunks = append(hunks, current)
+	}
+	return hunks
+}
+
+// ConflictResolution is the resolution of one conflicted file. If Side is set, the complete
+// version of that side is used (which may be a deletion), otherwise the file is set to Content.
+type ConflictResolution struct {
+	Path    string
+	Side    ConflictSide
+	Content string
+}
+
+// PullConflicts are the conflicts between the head and the base branch of a pull request
+type PullConflicts struct {
+	HeadCommitID string
+	BaseCommitID string
+	Files        []*ConflictedFile
+}
+
+// GetFile returns the conflicted file with the given path or nil
+func (c *PullConflicts) GetFile(path string) *ConflictedFile {
+	for _, f := range c.Files {
+		if f.Path == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// createTemporaryRepoForConflicts creates a temporary repository in which the base branch of the pull request
+// has been merged into its head branch, leaving the conflicts unresolved in the index
+func createTemporaryRepoForConflicts(ctx context.Context, pr *issues_model.PullRequest, doer *user_model.User) (mergeCtx *mergeContext, reversePR *issues_model.PullRequest, cancel context.CancelFunc, err error) {
+	if pr.Flow == issues_model.PullRequestFlowAGit {
+		return nil, nil, nil, fmt.Errorf("resolving conflicts of agit flow pull requests is unsupported")
+	}
+	if err := pr.LoadBaseRepo(ctx); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to load BaseRepo for PR[%d]: %w", pr.ID, err)
+	}
+	if err := pr.LoadHeadRepo(ctx); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to load HeadRepo for PR[%d]: %w", pr.ID, err)
+	}
+	if pr.HeadRepo == nil {
+		return nil, nil, nil, repo_model.ErrRepoNotExist{ID: pr.HeadRepoID}
+	}
+
+	// as for updating the pull request, merge the base branch into the head branch
+	reversePR = &issues_model.PullRequest{
+		ID: pr.ID,
+
+		HeadRepoID: pr.BaseRepoID,
+		HeadRepo:   pr.BaseRepo,
+		HeadBranch: pr.BaseBranch,
+
+		BaseRepoID: pr.HeadRepoID,
+		BaseRepo:   pr.HeadRepo,
+		BaseBranch: pr.HeadBranch,
+	}
+
+	mergeCtx, cancel, err = createTemporaryRepoForMerge(ctx, reversePR, doer, "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cmd := git.NewCommand(ctx, "merge", "--no-ff", "--no-commit").AddDynamicArguments(trackingBranch)
+	if err := runMergeCommand(mergeCtx, repo_model.MergeStyleMerge, cmd); err != nil && !models.IsErrMergeConflicts(err) {
+		cancel()
+		return nil, nil, nil, err
+	}
+	mergeCtx.outbuf.Reset()
+	mergeCtx.errbuf.Reset()
+
+	return mergeCtx, reversePR, cancel, nil
+}
+
+// collectUnmergedFiles lists the files left unmerged in the index of the temporary repository
+func collectUnmergedFiles(ctx context.Context, tmpBasePath string) ([]*unmergedFile, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	unmerged := make(chan *unmergedFile)
+	go unmergedFiles(ctx, tmpBasePath, unmerged)
+	defer func() {
+		cancel()
+		for range unmerged {
+			// empty the unmerged channel
+		}
+	}()
+
+	files := make([]*unmergedFile, 0, 5)
+	for file := range unmerged {
+		if file == nil {
+			break
+		}
+		if file.err != nil {
+			return nil, file.err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (u *unmergedFile) path() string {
+	for _, stage := range []*lsFileLine{u.stage2, u.stage3, u.stage1} {
+		if stage != nil {
+			return stage.path
+		}
+	}
+	return ""
+}
+
+func isRegularFileMode(mode string) bool {
+	return mode == "100644" || mode == "100755"
+}
+
+// readConflictBlob returns the content of a stage of an unmerged file, it returns false if the content
+// is not suitable to be edited in the browser
+func readConflictBlob(gitRepo *git.Repository, stage *lsFileLine) ([]byte, bool, error) {
+	if stage == nil {
+		return nil, true, nil
+	}
+	if !isRegularFileMode(stage.mode) {
+		return nil, false, nil
+	}
+	blob, err := gitRepo.GetBlob(stage.sha)
+	if err != nil {
+		return nil, false, err
+	}
+	if blob.Size() > setting.UI.MaxDisplayFileSize {
+		return nil, false, nil
+	}
+	content, err := blob.GetBlobContent(setting.UI.MaxDisplayFileSize)
+	if err != nil {
+		return nil, false, err
+	}
+	if strings.IndexByte(content, 0) >= 0 {
+		return nil, false, nil
+	}
+	return []byte(content), true, nil
+}
+
+// toConflictedFile splits an unmerged file into hunks using git merge-file
+func toConflictedFile(ctx context.Context, gitRepo *git.Repository, tmpBasePath string, file *unmergedFile) (*ConflictedFile, error) {
+	conflicted := &ConflictedFile{
+		Path:        file.path(),
+		OursExist:   file.stage2 != nil,
+		TheirsExist: file.stage3 != nil,
+	}
+
+	if file.stage2 == nil || file.stage3 == nil || file.stage2.mode != file.stage3.mode {
+		return conflicted, nil
+	}
+
+	contents := make([][]byte, 0, 3)
+	for _, stage := range []*lsFileLine{file.stage2, file.stage1, file.stage3} {
+		content, editable, err := readConflictBlob(gitRepo, stage)
+		if err != nil {
+			return nil, err
+		}
+		if !editable {
+			return conflicted, nil
+		}
+		contents = append(contents, content)
+	}
+
+	// git merge-file works on files, so write the three versions to the temporary repository
+	filenames := make([]string, 0, 3)
+	defer func() {
+		for _, filename := range filenames {
+			_ = util.Remove(filename)
+		}
+	}()
+	for _, content := range contents {
+		f, err := os.CreateTemp(tmpBasePath, ".conflict_*")
+		if err != nil {
+			return nil, fmt.Errorf("unable to create temporary file for %s: %w", conflicted.Path, err)
+		}
+		filenames = append(filenames, f.Name())
+		_, err = f.Write(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to write temporary file for %s: %w", conflicted.Path, err)
+		}
+	}
+
+	// merge-file exits with the number of conflicts, so only a missing output is an error
+	stdout, _, err := git.NewCommand(ctx, "merge-file", "-p", "--diff3", "-L", "ours", "-L", "base", "-L", "theirs").
+		AddDynamicArguments(filenames...).RunStdString(&git.RunOpts{Dir: tmpBasePath})
+	if err != nil && stdout == "" && (len(contents[0]) > 0 || len(contents[2]) > 0) {
+		return nil, fmt.Errorf("git merge-file %s: %w", conflicted.Path, err)
+	}
+
+	conflicted.Editable = true
+	conflicted.Hunks = parseConflictHunks(stdout)
+	return conflicted, nil
+}
+
+// GetConflicts returns the files which conflict when merging the base branch of the pull request into its head branch
+func GetConflicts(ctx context.Context, pr *issues_model.PullRequest, doer *user_model.User) (*PullConflicts, error) {
+	mergeCtx, _, cancel, err := createTemporaryRepoForConflicts(ctx, pr, doer)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	conflicts := &PullConflicts{}
+	if conflicts.HeadCommitID, err = git.GetFullCommitID(ctx, mergeCtx.tmpBasePath, baseBranch); err != nil {
+		return nil, fmt.Errorf("unable to get head commit ID of %v: %w", pr, err)
+	}
+	if conflicts.BaseCommitID, err = git.GetFullCommitID(ctx, mergeCtx.tmpBasePath, trackingBranch); err != nil {
+		return nil, fmt.Errorf("unable to get base commit ID of %v: %w", pr, err)
+	}
+
+	files, err := collectUnmergedFiles(ctx, mergeCtx.tmpBasePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return conflicts, nil
+	}
+
+	gitRepo, err := git.OpenRepository(ctx, mergeCtx.tmpBasePath)
+	if err != nil {
+		return nil, fmt.Errorf("OpenRepository: %w", err)
+	}
+	defer gitRepo.Close()
+
+	conflicts.Files = make([]*ConflictedFile, 0, len(files))
+	for _, file := range files {
+		conflicted, err := toConflictedFile(ctx, gitRepo, mergeCtx.tmpBasePath, file)
+		if err != nil {
+			return nil, err
+		}
+		conflicts.Files = append(conflicts.Files, conflicted)
+	}
+	return conflicts, nil
+}
+
+// ResolveConflicts merges the base branch of the pull request into its head branch using the given resolutions
+// for the conflicted files and pushes the merge commit to the head branch.
+// The expected commit IDs guard against the branches having changed since the conflicts were presented.
+func ResolveConflicts(ctx context.Context, pr *issues_model.PullRequest, doer *user_model.User, expectedHeadCommitID, expectedBaseCommitID string, resolutions []*ConflictResolution, message string) error {
+	pullWorkingPool.CheckIn(fmt.Sprint(pr.ID))
+	defer pullWorkingPool.CheckOut(fmt.Sprint(pr.ID))
+
+	mergeCtx, reversePR, cancel, err := createTemporaryRepoForConflicts(ctx, pr, doer)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	for branch, expected := range map[string]string{baseBranch: expectedHeadCommitID, trackingBranch: expectedBaseCommitID} {
+		commitID, err := git.GetFullCommitID(ctx, mergeCtx.tmpBasePath, branch)
+		if err != nil {
+			return fmt.Errorf("unable to get commit ID of %s in %v: %w", branch, pr, err)
+		}
+		if commitID != expected {
+			return models.ErrSHADoesNotMatch{
+				GivenSHA:   expected,
+				CurrentSHA: commitID,
+			}
+		}
+	}
+
+	files, err := collectUnmergedFiles(ctx, mergeCtx.tmpBasePath)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return util.NewInvalidArgumentErrorf("%v has no conflicts to resolve", pr)
+	}
+
+	unmergedByPath := make(map[string]*unmergedFile, len(files))
+	for _, file := range files {
+		unmergedByPath[file.path()] = file
+	}
+
+	gitRepo, err := git.OpenRepository(ctx, mergeCtx.tmpBasePath)
+	if err != nil {
+		return fmt.Errorf("OpenRepository: %w", err)
+	}
+	defer gitRepo.Close()
+
+	var filesToRemove []string
+	var filesToAdd []git.IndexObjectInfo
+	for _, resolution := range resolutions {
+		file, ok := unmergedByPath[resolution.Path]
+		if !ok {
+			return util.NewInvalidArgumentErrorf("%s is not conflicted", resolution.Path)
+		}
+		delete(unmergedByPath, resolution.Path)
+
+		var stage *lsFileLine
+		switch resolution.Side {
+		case ConflictSideOurs:
+			stage = file.stage2
+		case ConflictSideTheirs:
+			stage = file.stage3
+		case "":
+			mode := "100644"
+			if file.stage2 != nil {
+				mode = file.stage2.mode
+			} else if file.stage3 != nil {
+				mode = file.stage3.mode
+			}
+			if !isRegularFileMode(mode) {
+				return util.NewInvalidArgumentErrorf("%s can not be resolved by its content", resolution.Path)
+			}
+
+			hash, _, runErr := git.NewCommand(ctx, "hash-object", "-w", "--stdin", "--path").AddDynamicArguments(resolution.Path).
+				RunStdString(&git.RunOpts{Dir: mergeCtx.tmpBasePath, Stdin: strings.NewReader(resolution.Content)})
+			if runErr != nil {
+				return fmt.Errorf("git hash-object %s: %w", resolution.Path, runErr)
+			}
+			objectID, err := git.NewIDFromString(strings.TrimSpace(hash))
+			if err != nil {
+				return err
+			}
+			filesToAdd = append(filesToAdd, git.IndexObjectInfo{Mode: mode, Object: objectID, Filename: resolution.Path})
+			continue
+		default:
+			return util.NewInvalidArgumentErrorf("invalid side %q for %s", resolution.Side, resolution.Path)
+		}
+
+		if stage == nil {
+			filesToRemove = append(filesToRemove, resolution.Path)
+			continue
+		}
+		filesToAdd = append(filesToAdd, git.IndexObjectInfo{Mode: stage.mode, Object: git.MustIDFromString(stage.sha), Filename: stage.path})
+	}
+
+	if len(unmergedByPath) > 0 {
+		paths := make([]string, 0, len(unmergedByPath))
+		for path := range unmergedByPath {
+			paths = append(paths, path)
+		}
+		return ErrUnresolvedConflicts{Paths: paths}
+	}
+
+	// Add and remove files in one command, as this is slow with many files otherwise
+	if err := gitRepo.RemoveFilesFromIndex(filesToRemove...); err != nil {
+		return err
+	}
+	if err := gitRepo.AddObjectsToIndex(filesToAdd...); err != nil {
+		return err
+	}
+
+	if stdout, _, err := git.NewCommand(ctx, "ls-files", "-u").RunStdString(&git.RunOpts{Dir: mergeCtx.tmpBasePath}); err != nil {
+		return fmt.Errorf("git ls-files -u: %w", err)
+	} else if strings.TrimSpace(stdout) != "" {
+		log.Error("%-v still has unmerged files after resolving conflicts:\n%s", pr, stdout)
+		return fmt.Errorf("unmerged files are left in %v after resolving conflicts", pr)
+	}
+
+	if err := commitAndSignNoAuthor(mergeCtx, message); err != nil {
+		log.Error("%-v Unable to commit conflict resolution: %v", pr, err)
+		return err
+	}
+
+	defer func() {
+		AddTestPullRequestTask(ctx, doer, reversePR.HeadRepo.ID, reversePR.HeadBranch, false, "", "", 0)
+	}()
+
+	_, err = pushMergedBase(ctx, mergeCtx, reversePR, doer, repo_module.PushTriggerPRUpdateWithBase)
+	return err
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package pull
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConflictHunks(t *testing.T) {
+	merged := "a\nb\n<<<<<<< ours\nhead\n||||||| base\noriginal\n=======\ntarget\n>>>>>>> theirs\nc\n<<<<<<< ours\n=======\nadded\n>>>>>>> theirs\n"
+
+	hunks := parseConflictHunks(merged)
+	require.Len(t, hunks, 4)
+	assert.Equal(t, &ConflictHunk{Common: "a\nb\n"}, hunks[0])
+	assert.Equal(t, &ConflictHunk{Conflict: true, Ours: "head\n", Base: "original\n", Theirs: "target\n"}, hunks[1])
+	assert.Equal(t, &ConflictHunk{Common: "c\n"}, hunks[2])
+	assert.Equal(t, &ConflictHunk{Conflict: true, Index: 1, Theirs: "added\n"}, hunks[3])
+
+	assert.Equal(t, []*ConflictHunk{{Common: "no conflict"}}, parseConflictHunks("no conflict"))
+	assert.Empty(t, parseConflictHunks(""))
+}
+
+func TestConflictedFileResolve(t *testing.T) {
+	file := &ConflictedFile{
+		Path:     "README.md",
+		Editable: true,
+		Hunks:    parseConflictHunks("a\n<<<<<<< ours\nb\n||||||| base\n=======\nc\n>>>>>>> theirs\nd\n<<<<<<< ours\ne\n||||||| base\n=======\nf\n>>>>>>> theirs\n"),
+	}
+	assert.Equal(t, 2, file.NumConflicts())
+
+	content, err := file.Resolve([]ConflictSide{ConflictSideOurs, ConflictSideTheirs})
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\nd\nf\n", content)
+
+	content, err = file.Resolve([]ConflictSide{ConflictSideBoth, ConflictSideOurs})
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\nd\ne\n", content)
+
+	_, err = file.Resolve([]ConflictSide{ConflictSideOurs})
+	require.Error(t, err)
+	_, err = file.Resolve([]ConflictSide{ConflictSideOurs, "none"})
+	require.Error(t, err)
+
+	marked := file.MarkedContent()
+	assert.Equal(t, "a\n<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\nd\n<<<<<<< ours\ne\n=======\nf\n>>>>>>> theirs\n", marked)
+	assert.True(t, HasConflictMarkers(marked))
+	assert.False(t, HasConflictMarkers("a\nb\nd\nf\n"))
+
+	_, err = (&ConflictedFile{Path: "image.png"}).Resolve(nil)
+	require.Error(t, err)
+}
@@ -229,6 +229,10 @@ func Merge(ctx context.Context, pr *issues_model.PullRequest, doer *user_model.U
 	// Reset cached commit count
 	cache.Remove(pr.Issue.Repo.GetCommitsCountCacheKey(pr.BaseBranch, true))
 
+	if err := RetargetChildrenOnMerge(ctx, doer, pr, mergeStyle); err != nil {
+		log.Error("Unable to retarget the children of %-v: %v", pr, err)
+	}
+
 	return handleCloseCrossReferences(ctx, pr, doer)
 }
 
@@ -291,6 +295,12 @@ func doMergeAndPush(ctx context.Context, pr *issues_model.PullRequest, doer *use
 		return "", models.ErrInvalidMergeStyle{ID: pr.BaseRepo.ID, Style: mergeStyle}
 	}
 
+	return pushMergedBase(ctx, mergeCtx, pr, doer, pushTrigger)
+}
+
+// pushMergedBase pushes the base branch of the temporary repository of mergeCtx, which is expected
+// to contain a completed merge, back to the base branch of the pull request and returns the new commit ID
+func pushMergedBase(ctx context.Context, mergeCtx *mergeContext, pr *issues_model.PullRequest, doer *user_model.User, pushTrigger repo_module.PushTrigger) (string, error) {
 	// OK we should cache our current head and origin/headbranch
 	mergeHeadSHA, err := git.GetFullCommitID(ctx, mergeCtx.tmpBasePath, "HEAD")
 	if err != nil {
@@ -234,8 +234,9 @@ func getDiffTree(ctx context.Context, repoPath, baseBranch, headBranch string, o
 }
 
 // rebaseTrackingOnToBase checks out the tracking branch as staging and rebases it on to the base branch
+// leaving out the commits reachable from upstream if it is not empty
 // if there is a conflict it will return a models.ErrRebaseConflicts
-func rebaseTrackingOnToBase(ctx *mergeContext, mergeStyle repo_model.MergeStyle) error {
+func rebaseTrackingOnToBase(ctx *mergeContext, mergeStyle repo_model.MergeStyle, upstream string) error {
 	// Checkout head branch
 	if err := git.NewCommand(ctx, "checkout", "-b").AddDynamicArguments(stagingBranch, trackingBranch).
 		Run(ctx.RunOpts()); err != nil {
@@ -244,9 +245,14 @@ func rebaseTrackingOnToBase(ctx *mergeContext, mergeStyle repo_model.MergeStyle)
 	ctx.outbuf.Reset()
 	ctx.errbuf.Reset()
 
-	// Rebase before merging
-	if err := git.NewCommand(ctx, "rebase").AddDynamicArguments(baseBranch).
-		Run(ctx.RunOpts()); err != nil {
+	// Rebase before merging, only the commits after upstream if it is given
+	cmd := git.NewCommand(ctx, "rebase")
+	if upstream != "" {
+		cmd.AddArguments("--onto").AddDynamicArguments(baseBranch, upstream)
+	} else {
+		cmd.AddDynamicArguments(baseBranch)
+	}
+	if err := cmd.Run(ctx.RunOpts()); err != nil {
 		// Rebase will leave a REBASE_HEAD file in .git if there is a conflict
 		if _, statErr := os.Stat(filepath.Join(ctx.tmpBasePath, ".git", "REBASE_HEAD")); statErr == nil {
 			var commitSha string
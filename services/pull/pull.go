@@ -577,41 +577,72 @@ func (errs errlist) Error() string {
 	return ""
 }
 
-// RetargetChildrenOnMerge retarget children pull requests on merge if possible
-func RetargetChildrenOnMerge(ctx context.Context, doer *user_model.User, pr *issues_model.PullRequest) error {
-	if setting.Repository.PullRequest.RetargetChildrenOnMerge && pr.BaseRepoID == pr.HeadRepoID {
-		return RetargetBranchPulls(ctx, doer, pr.HeadRepoID, pr.HeadBranch, pr.BaseBranch)
+// RetargetChildrenOnMerge retarget children pull requests on merge if possible. As squashing rewrites
+// the commits of the merged pull request, the children are then queued to be rebased on their new target
+// branch, leaving out the commits they shared with it. mergeStyle is empty when it is not known, e.g.
+// when the head branch of a merged pull request is deleted.
+func RetargetChildrenOnMerge(ctx context.Context, doer *user_model.User, pr *issues_model.PullRequest, mergeStyle repo_model.MergeStyle) error {
+	if !setting.Repository.PullRequest.RetargetChildrenOnMerge || pr.BaseRepoID != pr.HeadRepoID {
+		return nil
 	}
-	return nil
+
+	var headCommitID string
+	if mergeStyle == repo_model.MergeStyleSquash {
+		if err := pr.LoadBaseRepo(ctx); err != nil {
+			return err
+		}
+		gitRepo, closer, err := gitrepo.RepositoryFromContextOrOpen(ctx, pr.BaseRepo)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+		if headCommitID, err = gitRepo.GetRefCommitID(pr.GetGitRefName()); err != nil {
+			return err
+		}
+	}
+
+	retargeted, err := RetargetBranchPulls(ctx, doer, pr.HeadRepoID, pr.HeadBranch, pr.BaseBranch)
+	if headCommitID != "" {
+		for _, child := range retargeted {
+			queueStackRebase(child, doer, headCommitID)
+		}
+	}
+	return err
 }
 
 // RetargetBranchPulls change target branch for all pull requests whose base branch is the branch
+// and returns the pull requests which were retargeted.
 // Both branch and targetBranch must be in the same repo (for security reasons)
-func RetargetBranchPulls(ctx context.Context, doer *user_model.User, repoID int64, branch, targetBranch string) error {
+func RetargetBranchPulls(ctx context.Context, doer *user_model.User, repoID int64, branch, targetBranch string) ([]*issues_model.PullRequest, error) {
 	prs, err := issues_model.GetUnmergedPullRequestsByBaseInfo(ctx, repoID, branch)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := issues_model.PullRequestList(prs).LoadAttributes(ctx); err != nil {
-		return err
+		return nil, err
 	}
 
+	retargeted := make([]*issues_model.PullRequest, 0, len(prs))
 	var errs errlist
 	for _, pr := range prs {
 		if err = pr.Issue.LoadRepo(ctx); err != nil {
 			errs = append(errs, err)
-		} else if err = ChangeTargetBranch(ctx, pr, doer, targetBranch); err != nil &&
-			!issues_model.IsErrIssueIsClosed(err) && !models.IsErrPullRequestHasMerged(err) &&
-			!issues_model.IsErrPullRequestAlreadyExists(err) {
-			errs = append(errs, err)
+		} else if err = ChangeTargetBranch(ctx, pr, doer, targetBranch); err != nil {
+			if !issues_model.IsErrIssueIsClosed(err) && !models.IsErrPullRequestHasMerged(err) &&
+				!issues_model.IsErrPullRequestAlreadyExists(err) {
+				errs = append(errs, err)
+			}
+		} else {
+			notify_service.PullRequestChangeTargetBranch(ctx, doer, pr, branch)
+			retargeted = append(retargeted, pr)
 		}
 	}
 
 	if len(errs) > 0 {
-		return errs
+		return retargeted, errs
 	}
-	return nil
+	return retargeted, nil
 }
 
 // CloseBranchPulls close all the pull requests who's head branch is the branch
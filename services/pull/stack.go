@@ -0,0 +1,232 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package pull
+
+import (
+	"context"
+	"fmt"
+
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/gitrepo"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/queue"
+)
+
+// maxStackDepth limits how many pull requests of a stack are followed in each direction
+const maxStackDepth = 20
+
+// StackedPullRequest is a pull request in a stack of pull requests, where each pull request
+// targets the head branch of the one below it
+type StackedPullRequest struct {
+	PullRequest *issues_model.PullRequest
+	Depth       int
+	IsCurrent   bool
+}
+
+// getStackParent returns the open pull request whose head branch is the base branch of pr, if any
+func getStackParent(ctx context.Context, pr *issues_model.PullRequest) (*issues_model.PullRequest, error) {
+	prs, err := issues_model.GetUnmergedPullRequestsByHeadInfo(ctx, pr.BaseRepoID, pr.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range prs {
+		if parent.BaseRepoID == pr.BaseRepoID && parent.ID != pr.ID {
+			return parent, nil
+		}
+	}
+	return nil, nil
+}
+
+// getStackChildren returns the open pull requests targeting the head branch of pr
+func getStackChildren(ctx context.Context, pr *issues_model.PullRequest) ([]*issues_model.PullRequest, error) {
+	if pr.HeadRepoID != pr.BaseRepoID {
+		return nil, nil
+	}
+	prs, err := issues_model.GetUnmergedPullRequestsByBaseInfo(ctx, pr.BaseRepoID, pr.HeadBranch)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]*issues_model.PullRequest, 0, len(prs))
+	for _, child := range prs {
+		if child.ID != pr.ID {
+			children = append(children, child)
+		}
+	}
+	return children, nil
+}
+
+// GetPullRequestStack returns the stack the pull request belongs to, from the bottom pull request
+// targeting a regular branch to the pull requests stacked on top of pr. It returns nil if pr is not
+// part of a stack.
+func GetPullRequestStack(ctx context.Context, pr *issues_model.PullRequest) ([]*StackedPullRequest, error) {
+	visited := map[int64]bool{pr.ID: true}
+
+	var ancestors []*issues_model.PullRequest
+	for current := pr; len(ancestors) < maxStackDepth; {
+		parent, err := getStackParent(ctx, current)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || visited[parent.ID] {
+			break
+		}
+		visited[parent.ID] = true
+		ancestors = append(ancestors, parent)
+		current = parent
+	}
+
+	stack := make([]*StackedPullRequest, 0, len(ancestors)+1)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		stack = append(stack, &StackedPullRequest{PullRequest: ancestors[i], Depth: len(ancestors) - 1 - i})
+	}
+	stack = append(stack, &StackedPullRequest{PullRequest: pr, Depth: len(ancestors), IsCurrent: true})
+
+	var addChildren func(parent *issues_model.PullRequest, depth int) error
+	addChildren = func(parent *issues_model.PullRequest, depth int) error {
+		if depth-len(ancestors) > maxStackDepth {
+			return nil
+		}
+		children, err := getStackChildren(ctx, parent)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			stack = append(stack, &StackedPullRequest{PullRequest: child, Depth: depth})
+			if err := addChildren(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := addChildren(pr, len(ancestors)+1); err != nil {
+		return nil, err
+	}
+
+	if len(stack) == 1 {
+		return nil, nil
+	}
+
+	prs := make(issues_model.PullRequestList, 0, len(stack))
+	for _, stacked := range stack {
+		prs = append(prs, stacked.PullRequest)
+	}
+	issues, err := prs.LoadIssues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := issues.LoadRepositories(ctx); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+// stackRebaseRequest is a request to rebase a pull request which was stacked on a squashed pull request
+type stackRebaseRequest struct {
+	PullID             int64
+	DoerID             int64
+	ParentHeadCommitID string
+}
+
+// prStackRebaseQueue represents a queue to rebase the pull requests stacked on a squashed pull request
+var prStackRebaseQueue *queue.WorkerPoolQueue[*stackRebaseRequest]
+
+func handleStackRebaseRequests(items ...*stackRebaseRequest) []*stackRebaseRequest {
+	ctx := graceful.GetManager().ShutdownContext()
+	for _, req := range items {
+		pr, err := issues_model.GetPullRequestByID(ctx, req.PullID)
+		if err != nil {
+			if !issues_model.IsErrPullRequestNotExist(err) {
+				log.Error("Unable to get the stacked pull request %d: %v", req.PullID, err)
+			}
+			continue
+		}
+		if err := pr.LoadIssue(ctx); err != nil {
+			log.Error("Unable to load the issue of %-v: %v", pr, err)
+			continue
+		}
+		if pr.HasMerged || pr.Issue.IsClosed {
+			continue
+		}
+		doer, err := user_model.GetPossibleUserByID(ctx, req.DoerID)
+		if err != nil {
+			log.Error("Unable to get the user %d rebasing %-v: %v", req.DoerID, pr, err)
+			continue
+		}
+		if err := rebaseStackedPullRequest(ctx, doer, pr, req.ParentHeadCommitID); err != nil {
+			log.Warn("Unable to rebase %-v on %s: %v", pr, pr.BaseBranch, err)
+		}
+	}
+	return nil
+}
+
+func initStackRebaseQueue() error {
+	prStackRebaseQueue = queue.CreateUniqueQueue(graceful.GetManager().ShutdownContext(), "pr_stack_rebase", handleStackRebaseRequests)
+	if prStackRebaseQueue == nil {
+		return fmt.Errorf("unable to create pr_stack_rebase queue")
+	}
+	go graceful.GetManager().RunWithCancel(prStackRebaseQueue)
+	return nil
+}
+
+// queueStackRebase queues the rebase of pr, which was stacked on the pull request whose head was parentHeadCommitID
+func queueStackRebase(pr *issues_model.PullRequest, doer *user_model.User, parentHeadCommitID string) {
+	err := prStackRebaseQueue.Push(&stackRebaseRequest{
+		PullID:             pr.ID,
+		DoerID:             doer.ID,
+		ParentHeadCommitID: parentHeadCommitID,
+	})
+	if err != nil && err != queue.ErrAlreadyInQueue {
+		log.Error("Error adding %-v to the stacked pull requests rebase queue: %v", pr, err)
+	}
+}
+
+// rebaseStackedPullRequest rebases the commits of pr which are not reachable from parentHeadCommitID
+// on its base branch, if the doer is allowed to do so
+func rebaseStackedPullRequest(ctx context.Context, doer *user_model.User, pr *issues_model.PullRequest, parentHeadCommitID string) error {
+	if err := pr.LoadHeadRepo(ctx); err != nil {
+		return err
+	} else if pr.HeadRepo == nil {
+		return repo_model.ErrRepoNotExist{ID: pr.HeadRepoID}
+	}
+
+	_, allowedUpdateByRebase, err := IsUserAllowedToUpdate(ctx, pr, doer)
+	if err != nil {
+		return err
+	}
+	if !allowedUpdateByRebase {
+		log.Debug("%-v is not allowed to rebase %-v", doer, pr)
+		return nil
+	}
+
+	if err := pr.LoadBaseRepo(ctx); err != nil {
+		return err
+	}
+	gitRepo, closer, err := gitrepo.RepositoryFromContextOrOpen(ctx, pr.BaseRepo)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	// the commits shared with the squashed pull request end at the merge base of both heads
+	upstream, _, err := gitRepo.GetMergeBase("", pr.GetGitRefName(), parentHeadCommitID)
+	if err != nil {
+		return fmt.Errorf("unable to get the merge base of %v and %s: %w", pr, parentHeadCommitID, err)
+	}
+
+	pullWorkingPool.CheckIn(fmt.Sprint(pr.ID))
+	defer pullWorkingPool.CheckOut(fmt.Sprint(pr.ID))
+
+	defer func() {
+		AddTestPullRequestTask(ctx, doer, pr.BaseRepo.ID, pr.BaseBranch, false, "", "", 0)
+	}()
+
+	return updateHeadByRebaseOnToBase(ctx, pr, doer, upstream)
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package pull
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/models/db"
+	issues_model "code.gitea.io/gitea/models/issues"
+	"code.gitea.io/gitea/models/unittest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPullRequestStack(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	// pull request 5 targets branch2, the head branch of pull request 2
+	pr2 := unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{ID: 2})
+	pr5 := unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{ID: 5})
+
+	stack, err := GetPullRequestStack(db.DefaultContext, pr5)
+	require.NoError(t, err)
+	require.Len(t, stack, 2)
+	assert.EqualValues(t, 2, stack[0].PullRequest.ID)
+	assert.Equal(t, 0, stack[0].Depth)
+	assert.False(t, stack[0].IsCurrent)
+	assert.EqualValues(t, 5, stack[1].PullRequest.ID)
+	assert.Equal(t, 1, stack[1].Depth)
+	assert.True(t, stack[1].IsCurrent)
+	assert.NotNil(t, stack[0].PullRequest.Issue)
+
+	stack, err = GetPullRequestStack(db.DefaultContext, pr2)
+	require.NoError(t, err)
+	require.Len(t, stack, 2)
+	assert.True(t, stack[0].IsCurrent)
+	assert.EqualValues(t, 5, stack[1].PullRequest.ID)
+
+	// pull request 4 is not stacked
+	pr4 := unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{ID: 4})
+	stack, err = GetPullRequestStack(db.DefaultContext, pr4)
+	require.NoError(t, err)
+	assert.Nil(t, stack)
+}
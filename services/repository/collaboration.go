@@ -8,45 +8,78 @@ import (
 	"context"
 
 	"code.gitea.io/gitea/models"
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/perm"
 	access_model "code.gitea.io/gitea/models/perm/access"
 	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	audit_service "code.gitea.io/gitea/services/audit"
 )
 
+// AddCollaborator adds a user as collaborator of a repository.
+func AddCollaborator(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, u *user_model.User) error {
+	if err := repo_module.AddCollaborator(ctx, repo, u); err != nil {
+		return err
+	}
+	audit_service.Record(ctx, audit_model.RepositoryCollaboratorAdd, doer, repo, u, "Added %s as collaborator of %s.", u.Name, repo.FullName())
+	return nil
+}
+
+// ChangeCollaborationAccessMode sets the access mode of a collaborator of a repository.
+func ChangeCollaborationAccessMode(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, u *user_model.User, mode perm.AccessMode) error {
+	if err := repo_model.ChangeCollaborationAccessMode(ctx, repo, u.ID, mode); err != nil {
+		return err
+	}
+	audit_service.Record(ctx, audit_model.RepositoryCollaboratorAccess, doer, repo, u, "Changed the access of %s to %s to %s.", u.Name, repo.FullName(), mode)
+	return nil
+}
+
 // DeleteCollaboration removes collaboration relation between the user and repository.
-func DeleteCollaboration(ctx context.Context, repo *repo_model.Repository, uid int64) (err error) {
+func DeleteCollaboration(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, uid int64) (err error) {
 	collaboration := &repo_model.Collaboration{
 		RepoID: repo.ID,
 		UserID: uid,
 	}
 
-	ctx, committer, err := db.TxContext(ctx)
+	dbCtx, committer, err := db.TxContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer committer.Close()
 
-	if has, err := db.GetEngine(ctx).Delete(collaboration); err != nil {
+	if has, err := db.GetEngine(dbCtx).Delete(collaboration); err != nil {
 		return err
 	} else if has == 0 {
 		return committer.Commit()
 	}
-	if err = access_model.RecalculateAccesses(ctx, repo); err != nil {
+	if err = access_model.RecalculateAccesses(dbCtx, repo); err != nil {
 		return err
 	}
 
-	if err = repo_model.WatchRepo(ctx, uid, repo.ID, false); err != nil {
+	if err = repo_model.WatchRepo(dbCtx, uid, repo.ID, false); err != nil {
 		return err
 	}
 
-	if err = models.ReconsiderWatches(ctx, repo, uid); err != nil {
+	if err = models.ReconsiderWatches(dbCtx, repo, uid); err != nil {
 		return err
 	}
 
 	// Unassign a user from any issue (s)he has been assigned to in the repository
-	if err := models.ReconsiderRepoIssuesAssignee(ctx, repo, uid); err != nil {
+	if err := models.ReconsiderRepoIssuesAssignee(dbCtx, repo, uid); err != nil {
+		return err
+	}
+
+	if err := committer.Commit(); err != nil {
 		return err
 	}
 
-	return committer.Commit()
+	if collaborator, err := user_model.GetPossibleUserByID(ctx, uid); err != nil {
+		log.Error("GetPossibleUserByID: %v", err)
+	} else {
+		audit_service.Record(ctx, audit_model.RepositoryCollaboratorRemove, doer, repo, collaborator, "Removed %s as collaborator of %s.", collaborator.Name, repo.FullName())
+	}
+	return nil
 }
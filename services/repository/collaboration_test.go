@@ -6,6 +6,7 @@ package repository
 import (
 	"testing"
 
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
 	repo_model "code.gitea.io/gitea/models/repo"
 	"code.gitea.io/gitea/models/unittest"
@@ -18,11 +19,14 @@ func TestRepository_DeleteCollaboration(t *testing.T) {
 
 	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 4})
 	require.NoError(t, repo.LoadOwner(db.DefaultContext))
-	require.NoError(t, DeleteCollaboration(db.DefaultContext, repo, 4))
+	require.NoError(t, DeleteCollaboration(db.DefaultContext, repo.Owner, repo, 4))
 	unittest.AssertNotExistsBean(t, &repo_model.Collaboration{RepoID: repo.ID, UserID: 4})
 
-	require.NoError(t, DeleteCollaboration(db.DefaultContext, repo, 4))
+	require.NoError(t, DeleteCollaboration(db.DefaultContext, repo.Owner, repo, 4))
 	unittest.AssertNotExistsBean(t, &repo_model.Collaboration{RepoID: repo.ID, UserID: 4})
 
+	// only the removal is recorded
+	unittest.AssertCount(t, &audit_model.Event{Action: audit_model.RepositoryCollaboratorRemove, RepoID: repo.ID, TargetID: 4}, 1)
+
 	unittest.CheckConsistencyFor(t, &repo_model.Repository{ID: repo.ID})
 }
@@ -0,0 +1,153 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/gitrepo"
+	"code.gitea.io/gitea/modules/log"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	"code.gitea.io/gitea/modules/util"
+	federation_service "code.gitea.io/gitea/services/federation"
+)
+
+// ForkFederatedRepoOptions contains the options to fork a repository of another federated instance
+type ForkFederatedRepoOptions struct {
+	// URI of the Repository actor to fork
+	URI  string
+	Name string
+}
+
+// ForkFederatedRepository clones a repository of another federated instance into a local repository.
+// The local repository is linked to the remote one, so pull requests can be offered to it.
+func ForkFederatedRepository(ctx context.Context, doer, owner *user_model.User, opts ForkFederatedRepoOptions) (*repo_model.Repository, error) {
+	if !doer.IsAdmin && !owner.CanForkRepo() {
+		return nil, repo_model.ErrReachLimitOfRepo{
+			Limit: owner.MaxRepoCreation,
+		}
+	}
+
+	remote, err := federation_service.FetchRemoteRepository(ctx, opts.URI)
+	if err != nil {
+		return nil, err
+	}
+	name := opts.Name
+	if name == "" {
+		name = remote.Name
+	}
+
+	// the network transfer happens before the repository is created
+	tmpPath, err := repo_module.CreateTemporaryPath("federated-fork")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := repo_module.RemoveTemporaryPath(tmpPath); err != nil {
+			log.Error("ForkFederatedRepository: RemoveTemporaryPath: %s", err)
+		}
+	}()
+	if stdout, _, err := git.NewCommand(ctx, "clone", "--bare").AddDynamicArguments(remote.CloneURI, tmpPath).
+		SetDescription(fmt.Sprintf("ForkFederatedRepository(git clone): %s", remote.CloneURI)).
+		RunStdBytes(&git.RunOpts{Timeout: 10 * time.Minute}); err != nil {
+		log.Error("Fork federated repository (git clone) failed for %s:\nStdout: %s\nError: %v", remote.CloneURI, stdout, err)
+		return nil, fmt.Errorf("git clone: %w", err)
+	}
+
+	tmpRepo, err := git.OpenRepository(ctx, tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	objectFormat, err := tmpRepo.GetObjectFormat()
+	tmpRepo.Close()
+	if err != nil {
+		return nil, err
+	}
+	defaultBranch, err := git.GetDefaultBranch(ctx, tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &repo_model.Repository{
+		OwnerID:          owner.ID,
+		Owner:            owner,
+		OwnerName:        owner.Name,
+		Name:             name,
+		LowerName:        strings.ToLower(name),
+		Description:      remote.Description,
+		DefaultBranch:    defaultBranch,
+		IsPrivate:        owner.Visibility.IsPrivate(),
+		ObjectFormatName: objectFormat.Name(),
+	}
+
+	needsRollback := false
+	err = db.WithTx(ctx, func(txCtx context.Context) error {
+		if err := repo_module.CreateRepositoryByExample(txCtx, doer, owner, repo, false, true); err != nil {
+			return err
+		}
+
+		fork, err := repo_model.NewFederatedFork(repo.ID, remote.ID.ID, remote.FederationHostID, remote.ID.AsURI(), remote.InboxURI)
+		if err != nil {
+			return err
+		}
+		if err := repo_model.CreateFederatedFork(txCtx, &fork); err != nil {
+			return err
+		}
+
+		needsRollback = true
+
+		repoPath := repo_model.RepoPath(owner.Name, repo.Name)
+		if stdout, _, err := git.NewCommand(txCtx, "clone", "--bare").AddDynamicArguments(tmpPath, repoPath).
+			SetDescription(fmt.Sprintf("ForkFederatedRepository(git clone): %s to %s", remote.CloneURI, repo.FullName())).
+			RunStdBytes(&git.RunOpts{Timeout: 10 * time.Minute}); err != nil {
+			log.Error("Fork federated repository (git clone) failed for %v:\nStdout: %s\nError: %v", repo, stdout, err)
+			return fmt.Errorf("git clone: %w", err)
+		}
+
+		if err := repo_module.CheckDaemonExportOK(txCtx, repo); err != nil {
+			return fmt.Errorf("checkDaemonExportOK: %w", err)
+		}
+
+		if stdout, _, err := git.NewCommand(txCtx, "update-server-info").
+			SetDescription(fmt.Sprintf("ForkFederatedRepository(git update-server-info): %s", repo.FullName())).
+			RunStdString(&git.RunOpts{Dir: repoPath}); err != nil {
+			log.Error("Fork federated repository (git update-server-info) failed for %v:\nStdout: %s\nError: %v", repo, stdout, err)
+			return fmt.Errorf("git update-server-info: %w", err)
+		}
+
+		if err := repo_module.CreateDelegateHooks(repoPath); err != nil {
+			return fmt.Errorf("createDelegateHooks: %w", err)
+		}
+
+		gitRepo, err := gitrepo.OpenRepository(txCtx, repo)
+		if err != nil {
+			return fmt.Errorf("OpenRepository: %w", err)
+		}
+		defer gitRepo.Close()
+
+		_, err = repo_module.SyncRepoBranchesWithRepo(txCtx, repo, gitRepo, doer.ID)
+		return err
+	})
+	if err != nil {
+		if needsRollback {
+			if errDelete := util.RemoveAll(repo_model.RepoPath(owner.Name, repo.Name)); errDelete != nil {
+				log.Error("Failed to remove federated fork: %v", errDelete)
+			}
+		}
+		return nil, err
+	}
+
+	if err := repo_module.UpdateRepoSize(ctx, repo); err != nil {
+		log.Error("Failed to update size for repository: %v", err)
+	}
+
+	return repo, nil
+}
@@ -19,6 +19,43 @@ import (
 
 // CherryPick cherrypicks or reverts a commit to the given repository
 func CherryPick(ctx context.Context, repo *repo_model.Repository, doer *user_model.User, revert bool, opts *ApplyDiffPatchOptions) (*structs.FileResponse, error) {
+	return applyThreeWayMerge(ctx, repo, doer, opts, func(t *TemporaryUploadRepository) (base, right string, err error) {
+		commit, err := t.GetCommit(strings.TrimSpace(opts.Content))
+		if err != nil {
+			return "", "", err
+		}
+		parent, err := commit.ParentID(0)
+		if err != nil {
+			parent = git.ObjectFormatFromName(repo.ObjectFormatName).EmptyTree()
+		}
+
+		base, right = parent.String(), commit.ID.String()
+
+		if revert {
+			right, base = base, right
+		}
+		return base, right, nil
+	})
+}
+
+// RevertRange reverts all the changes made between fromCommitID (exclusive) and toCommitID (inclusive)
+// in a single commit to the given repository
+func RevertRange(ctx context.Context, repo *repo_model.Repository, doer *user_model.User, fromCommitID, toCommitID string, opts *ApplyDiffPatchOptions) (*structs.FileResponse, error) {
+	return applyThreeWayMerge(ctx, repo, doer, opts, func(t *TemporaryUploadRepository) (base, right string, err error) {
+		from, err := t.GetCommit(fromCommitID)
+		if err != nil {
+			return "", "", err
+		}
+		to, err := t.GetCommit(toCommitID)
+		if err != nil {
+			return "", "", err
+		}
+		return to.ID.String(), from.ID.String(), nil
+	})
+}
+
+// applyThreeWayMerge applies the changes from base to right on top of opts.OldBranch and commits them to opts.NewBranch
+func applyThreeWayMerge(ctx context.Context, repo *repo_model.Repository, doer *user_model.User, opts *ApplyDiffPatchOptions, getBaseAndRight func(t *TemporaryUploadRepository) (base, right string, err error)) (*structs.FileResponse, error) {
 	if err := opts.Validate(ctx, repo, doer); err != nil {
 		return nil, err
 	}
@@ -64,20 +101,10 @@ func CherryPick(ctx context.Context, repo *repo_model.Repository, doer *user_mod
 		}
 	}
 
-	commit, err = t.GetCommit(strings.TrimSpace(opts.Content))
+	base, right, err := getBaseAndRight(t)
 	if err != nil {
 		return nil, err
 	}
-	parent, err := commit.ParentID(0)
-	if err != nil {
-		parent = git.ObjectFormatFromName(repo.ObjectFormatName).EmptyTree()
-	}
-
-	base, right := parent.String(), commit.ID.String()
-
-	if revert {
-		right, base = base, right
-	}
 
 	description := fmt.Sprintf("CherryPick %s onto %s", right, opts.OldBranch)
 	conflict, _, err := pull.AttemptThreeWayMerge(ctx,
@@ -87,7 +114,7 @@ func CherryPick(ctx context.Context, repo *repo_model.Repository, doer *user_mod
 	}
 
 	if conflict {
-		return nil, fmt.Errorf("failed to merge due to conflicts")
+		return nil, models.ErrMergeConflicts{Err: fmt.Errorf("failed to merge due to conflicts")}
 	}
 
 	treeHash, err := t.WriteTree()
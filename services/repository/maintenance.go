@@ -0,0 +1,197 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	system_model "code.gitea.io/gitea/models/system"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/log"
+	repo_module "code.gitea.io/gitea/modules/repository"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/sync"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/modules/util"
+
+	"xorm.io/builder"
+)
+
+// The incremental maintenance tasks, in the order they are run
+const (
+	MaintenanceTaskPruneLooseObjects = "prune-loose-objects"
+	MaintenanceTaskGeometricRepack   = "geometric-repack"
+	MaintenanceTaskMultiPackIndex    = "multi-pack-index"
+	MaintenanceTaskCommitGraph       = "commit-graph"
+)
+
+// MaintenanceOptions are the thresholds deciding which maintenance tasks are needed by a repository
+type MaintenanceOptions struct {
+	// LooseObjectsThreshold is the number of loose objects above which they are pruned and packed
+	LooseObjectsThreshold int64
+	// PacksThreshold is the number of packs above which they are repacked
+	PacksThreshold int64
+	// PruneExpire is the age of the unreachable loose objects which are pruned
+	PruneExpire string
+	Timeout     time.Duration
+}
+
+// DefaultMaintenanceOptions returns the maintenance thresholds configured for the instance
+func DefaultMaintenanceOptions() MaintenanceOptions {
+	return MaintenanceOptions{
+		LooseObjectsThreshold: setting.Git.Maintenance.LooseObjectsThreshold,
+		PacksThreshold:        setting.Git.Maintenance.PacksThreshold,
+		PruneExpire:           setting.Git.Maintenance.PruneExpire,
+		Timeout:               setting.Git.Maintenance.Timeout,
+	}
+}
+
+// maintenanceLocker prevents the maintenance of a repository from running twice at the same time
+var maintenanceLocker = sync.NewExclusivePool()
+
+// planMaintenance returns the tasks needed by a repository according to its measured state
+func planMaintenance(stats *git.ObjectStats, hasCommitGraph, hasMultiPackIndex bool, opts MaintenanceOptions) []string {
+	var tasks []string
+	repack := stats.LooseObjects >= opts.LooseObjectsThreshold || stats.Packs >= opts.PacksThreshold
+	if stats.LooseObjects >= opts.LooseObjectsThreshold {
+		tasks = append(tasks, MaintenanceTaskPruneLooseObjects)
+	}
+	if repack {
+		tasks = append(tasks, MaintenanceTaskGeometricRepack)
+	} else if stats.Packs > 1 && !hasMultiPackIndex {
+		tasks = append(tasks, MaintenanceTaskMultiPackIndex)
+	}
+	// packing new objects usually means new commits, which are missing from the commit-graph
+	if repack || !hasCommitGraph {
+		tasks = append(tasks, MaintenanceTaskCommitGraph)
+	}
+	return tasks
+}
+
+// MaintainRepos runs the maintenance tasks needed by each repository
+func MaintainRepos(ctx context.Context, opts MaintenanceOptions) error {
+	log.Trace("Doing: MaintainRepos")
+
+	if err := db.Iterate(
+		ctx,
+		builder.Eq{"is_empty": false},
+		func(ctx context.Context, repo *repo_model.Repository) error {
+			select {
+			case <-ctx.Done():
+				return db.ErrCancelledf("before maintenance of %s", repo.FullName())
+			default:
+			}
+			// the error is logged and stored in the maintenance state of the repository
+			_, _ = MaintainRepo(ctx, repo, opts, false)
+			return nil
+		},
+	); err != nil {
+		return err
+	}
+
+	log.Trace("Finished: MaintainRepos")
+	return nil
+}
+
+// MaintainRepo measures the state of a repository and runs the maintenance tasks it needs,
+// force runs all the tasks which can improve the repository whatever the thresholds.
+func MaintainRepo(ctx context.Context, repo *repo_model.Repository, opts MaintenanceOptions, force bool) (*repo_model.RepoMaintenance, error) {
+	maintenanceLocker.CheckIn(strconv.FormatInt(repo.ID, 10))
+	defer maintenanceLocker.CheckOut(strconv.FormatInt(repo.ID, 10))
+
+	m, err := repo_model.GetRepoMaintenance(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath := repo.RepoPath()
+	stats, err := git.CountObjects(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if force {
+		opts.LooseObjectsThreshold = 1
+		opts.PacksThreshold = 2
+	}
+	tasks := planMaintenance(stats, git.HasCommitGraph(repoPath), git.HasMultiPackIndex(repoPath), opts)
+
+	var runErr error
+	ran := make([]string, 0, len(tasks))
+	defer setRunningMaintenanceTask(repo.ID, "")
+	for _, task := range tasks {
+		log.Trace("Running maintenance task %s on %-v", task, repo)
+		setRunningMaintenanceTask(repo.ID, task)
+		switch task {
+		case MaintenanceTaskPruneLooseObjects:
+			runErr = git.PruneLooseObjects(ctx, repoPath, opts.PruneExpire, opts.Timeout)
+		case MaintenanceTaskGeometricRepack:
+			runErr = git.RepackGeometric(ctx, repoPath, opts.Timeout)
+		case MaintenanceTaskMultiPackIndex:
+			runErr = git.WriteMultiPackIndex(ctx, repoPath, opts.Timeout)
+		case MaintenanceTaskCommitGraph:
+			runErr = git.WriteCommitGraph(ctx, repoPath)
+		}
+		if runErr != nil {
+			break
+		}
+		ran = append(ran, task)
+	}
+
+	if len(ran) > 0 {
+		if err := repo_module.UpdateRepoSize(ctx, repo); err != nil {
+			log.Error("Updating size as part of maintenance failed for %-v: %v", repo, err)
+		}
+		// measure again so that the page shows the state left by the maintenance
+		if stats, err = git.CountObjects(ctx, repoPath); err != nil {
+			return nil, err
+		}
+	}
+
+	m.LooseObjects = stats.LooseObjects
+	m.LooseSize = stats.LooseSize
+	m.Packs = stats.Packs
+	m.PackSize = stats.PackSize
+	m.HasCommitGraph = git.HasCommitGraph(repoPath)
+	m.HasMultiPackIndex = git.HasMultiPackIndex(repoPath)
+	m.LastTasks = strings.Join(ran, ",")
+	m.LastError = ""
+	m.LastRunUnix = timeutil.TimeStampNow()
+	if runErr != nil {
+		log.Error("Repository maintenance failed for %-v: %v", repo, runErr)
+		m.LastError = runErr.Error()
+		if err := system_model.CreateRepositoryNotice("Repository maintenance failed for %s: %v", repo.FullName(), runErr); err != nil {
+			log.Error("CreateRepositoryNotice: %v", err)
+		}
+	}
+	if err := repo_model.SaveRepoMaintenance(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, runErr
+}
+
+// SetRepoAllowedFilters restricts the object filters allowed for the partial clones of a repository,
+// an empty list allows the filters enabled for the instance
+func SetRepoAllowedFilters(ctx context.Context, repo *repo_model.Repository, filters []string) error {
+	for _, filter := range filters {
+		if !git.IsValidUploadPackFilter(filter) {
+			return util.NewInvalidArgumentErrorf("unknown object filter %q", filter)
+		}
+	}
+
+	m, err := repo_model.GetRepoMaintenance(ctx, repo.ID)
+	if err != nil {
+		return err
+	}
+	if err := git.SetUploadPackFilters(ctx, repo.RepoPath(), filters); err != nil {
+		return fmt.Errorf("SetUploadPackFilters: %w", err)
+	}
+	m.SetAllowedFilters(filters)
+	return repo_model.SaveRepoMaintenance(ctx, m)
+}
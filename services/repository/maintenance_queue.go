@@ -0,0 +1,93 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repository
+
+import (
+	"errors"
+	"sync"
+
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/modules/graceful"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/queue"
+)
+
+// MaintenanceRequest is a request to run all the maintenance tasks which can improve a repository
+type MaintenanceRequest struct {
+	RepoID int64
+}
+
+// MaintenanceStatus is the state of the maintenance requested for a repository
+type MaintenanceStatus struct {
+	Queued  bool
+	Running bool
+	// Task is the maintenance task which is running
+	Task string
+}
+
+var maintenanceQueue *queue.WorkerPoolQueue[*MaintenanceRequest]
+
+// runningMaintenances are the tasks run by the maintenances in progress, by repository
+var (
+	runningMaintenances   = make(map[int64]string)
+	runningMaintenancesMu sync.Mutex
+)
+
+func setRunningMaintenanceTask(repoID int64, task string) {
+	runningMaintenancesMu.Lock()
+	defer runningMaintenancesMu.Unlock()
+	if task == "" {
+		delete(runningMaintenances, repoID)
+	} else {
+		runningMaintenances[repoID] = task
+	}
+}
+
+func handleMaintenanceRequests(items ...*MaintenanceRequest) []*MaintenanceRequest {
+	ctx := graceful.GetManager().ShutdownContext()
+	for _, req := range items {
+		repo, err := repo_model.GetRepositoryByID(ctx, req.RepoID)
+		if err != nil {
+			if !repo_model.IsErrRepoNotExist(err) {
+				log.Error("Unable to load repository %d for its maintenance: %v", req.RepoID, err)
+			}
+			continue
+		}
+		// the error is logged and stored in the maintenance state of the repository
+		_, _ = MaintainRepo(ctx, repo, DefaultMaintenanceOptions(), true)
+	}
+	return nil
+}
+
+func initMaintenanceQueue() error {
+	maintenanceQueue = queue.CreateUniqueQueue(graceful.GetManager().ShutdownContext(), "repo_maintenance", handleMaintenanceRequests)
+	if maintenanceQueue == nil {
+		return errors.New("unable to create repo_maintenance queue")
+	}
+	go graceful.GetManager().RunWithCancel(maintenanceQueue)
+	return nil
+}
+
+// QueueRepoMaintenance queues the maintenance of a repository, nothing happens if it is already queued
+func QueueRepoMaintenance(repo *repo_model.Repository) error {
+	req := &MaintenanceRequest{RepoID: repo.ID}
+	has, err := maintenanceQueue.Has(req)
+	if err != nil || has {
+		return err
+	}
+	return maintenanceQueue.Push(req)
+}
+
+// GetRepoMaintenanceStatus returns whether the maintenance of a repository is queued or running
+func GetRepoMaintenanceStatus(repo *repo_model.Repository) (*MaintenanceStatus, error) {
+	status := &MaintenanceStatus{}
+
+	runningMaintenancesMu.Lock()
+	status.Task, status.Running = runningMaintenances[repo.ID]
+	runningMaintenancesMu.Unlock()
+
+	var err error
+	status.Queued, err = maintenanceQueue.Has(&MaintenanceRequest{RepoID: repo.ID})
+	return status, err
+}
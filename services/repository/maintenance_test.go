@@ -0,0 +1,87 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repository
+
+import (
+	"testing"
+
+	"code.gitea.io/gitea/models/db"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanMaintenance(t *testing.T) {
+	opts := MaintenanceOptions{LooseObjectsThreshold: 100, PacksThreshold: 5}
+
+	assert.Empty(t, planMaintenance(&git.ObjectStats{LooseObjects: 10, Packs: 1}, true, false, opts))
+	assert.Equal(t, []string{MaintenanceTaskCommitGraph},
+		planMaintenance(&git.ObjectStats{LooseObjects: 10, Packs: 1}, false, false, opts))
+	assert.Equal(t, []string{MaintenanceTaskMultiPackIndex},
+		planMaintenance(&git.ObjectStats{Packs: 3}, true, false, opts))
+	assert.Empty(t, planMaintenance(&git.ObjectStats{Packs: 3}, true, true, opts))
+	assert.Equal(t, []string{MaintenanceTaskGeometricRepack, MaintenanceTaskCommitGraph},
+		planMaintenance(&git.ObjectStats{Packs: 5}, true, true, opts))
+	assert.Equal(t, []string{MaintenanceTaskPruneLooseObjects, MaintenanceTaskGeometricRepack, MaintenanceTaskCommitGraph},
+		planMaintenance(&git.ObjectStats{LooseObjects: 100, Packs: 1}, true, true, opts))
+}
+
+func TestMaintainRepo(t *testing.T) {
+	unittest.PrepareTestEnv(t)
+	if git.CheckGitVersionAtLeast("2.32") != nil {
+		t.Skip("git too old")
+	}
+
+	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1})
+	m, err := MaintainRepo(db.DefaultContext, repo, DefaultMaintenanceOptions(), true)
+	require.NoError(t, err)
+	assert.NotZero(t, m.LastRunUnix)
+	assert.Empty(t, m.LastError)
+	assert.Contains(t, m.GetLastTasks(), MaintenanceTaskCommitGraph)
+	assert.True(t, m.HasCommitGraph)
+	assert.Zero(t, m.LooseObjects)
+
+	saved, err := repo_model.GetRepoMaintenance(db.DefaultContext, repo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, m.ID, saved.ID)
+	assert.Equal(t, m.LastTasks, saved.LastTasks)
+}
+
+func TestHandleMaintenanceRequests(t *testing.T) {
+	unittest.PrepareTestEnv(t)
+	if git.CheckGitVersionAtLeast("2.32") != nil {
+		t.Skip("git too old")
+	}
+
+	assert.Empty(t, handleMaintenanceRequests(&MaintenanceRequest{RepoID: 1}, &MaintenanceRequest{RepoID: unittest.NonexistentID}))
+	m, err := repo_model.GetRepoMaintenance(db.DefaultContext, 1)
+	require.NoError(t, err)
+	assert.NotZero(t, m.LastRunUnix)
+	assert.Empty(t, runningMaintenances)
+}
+
+func TestSetRepoAllowedFilters(t *testing.T) {
+	unittest.PrepareTestEnv(t)
+
+	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1})
+	err := SetRepoAllowedFilters(db.DefaultContext, repo, []string{"blob:none", "unknown"})
+	require.ErrorIs(t, err, util.ErrInvalidArgument)
+
+	if git.CheckGitVersionAtLeast("2.29") != nil {
+		t.Skip("git too old")
+	}
+	require.NoError(t, SetRepoAllowedFilters(db.DefaultContext, repo, []string{"blob:none", "tree"}))
+	m, err := repo_model.GetRepoMaintenance(db.DefaultContext, repo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blob:none", "tree"}, m.GetAllowedFilters())
+
+	require.NoError(t, SetRepoAllowedFilters(db.DefaultContext, repo, nil))
+	m, err = repo_model.GetRepoMaintenance(db.DefaultContext, repo.ID)
+	require.NoError(t, err)
+	assert.Nil(t, m.GetAllowedFilters())
+}
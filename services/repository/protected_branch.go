@@ -0,0 +1,37 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repository
+
+import (
+	"context"
+
+	audit_model "code.gitea.io/gitea/models/audit"
+	git_model "code.gitea.io/gitea/models/git"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	audit_service "code.gitea.io/gitea/services/audit"
+)
+
+// UpdateProtectBranch creates the branch protection rule of a repository, or updates it if it already exists.
+func UpdateProtectBranch(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, protectBranch *git_model.ProtectedBranch, opts git_model.WhitelistOptions) error {
+	isNewRule := protectBranch.ID == 0
+	if err := git_model.UpdateProtectBranch(ctx, repo, protectBranch, opts); err != nil {
+		return err
+	}
+	if isNewRule {
+		audit_service.Record(ctx, audit_model.RepositoryBranchProtectionAdd, doer, repo, protectBranch, "Added branch protection %s to %s.", protectBranch.RuleName, repo.FullName())
+	} else {
+		audit_service.Record(ctx, audit_model.RepositoryBranchProtectionUpdate, doer, repo, protectBranch, "Updated branch protection %s of %s.", protectBranch.RuleName, repo.FullName())
+	}
+	return nil
+}
+
+// DeleteProtectedBranch deletes a branch protection rule of a repository.
+func DeleteProtectedBranch(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, rule *git_model.ProtectedBranch) error {
+	if err := git_model.DeleteProtectedBranch(ctx, repo, rule.ID); err != nil {
+		return err
+	}
+	audit_service.Record(ctx, audit_model.RepositoryBranchProtectionRemove, doer, repo, rule, "Removed branch protection %s from %s.", rule.RuleName, repo.FullName())
+	return nil
+}
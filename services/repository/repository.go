@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/models/git"
 	issues_model "code.gitea.io/gitea/models/issues"
@@ -22,6 +23,7 @@ import (
 	repo_module "code.gitea.io/gitea/modules/repository"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/structs"
+	audit_service "code.gitea.io/gitea/services/audit"
 	federation_service "code.gitea.io/gitea/services/federation"
 	notify_service "code.gitea.io/gitea/services/notify"
 	pull_service "code.gitea.io/gitea/services/pull"
@@ -67,6 +69,7 @@ func DeleteRepository(ctx context.Context, doer *user_model.User, repo *repo_mod
 	if err := DeleteRepositoryDirectly(ctx, doer, repo.ID); err != nil {
 		return err
 	}
+	audit_service.Record(ctx, audit_model.RepositoryDelete, doer, repo, repo, "Deleted repository %s.", repo.FullName())
 
 	if err := federation_service.DeleteFollowingRepos(ctx, repo.ID); err != nil {
 		return err
@@ -110,6 +113,9 @@ func Init(ctx context.Context) error {
 	if err := initPushQueue(); err != nil {
 		return err
 	}
+	if err := initMaintenanceQueue(); err != nil {
+		return err
+	}
 	return initBranchSyncQueue(graceful.GetManager().ShutdownContext())
 }
 
@@ -128,6 +134,21 @@ func UpdateRepository(ctx context.Context, repo *repo_model.Repository, visibili
 	return committer.Commit()
 }
 
+// UpdateRepositorySettings updates the settings of a repository changed by the doer.
+func UpdateRepositorySettings(ctx context.Context, doer *user_model.User, repo *repo_model.Repository, visibilityChanged bool) error {
+	if err := UpdateRepository(ctx, repo, visibilityChanged); err != nil {
+		return err
+	}
+	if visibilityChanged {
+		visibility := "public"
+		if repo.IsPrivate {
+			visibility = "private"
+		}
+		audit_service.Record(ctx, audit_model.RepositoryVisibility, doer, repo, repo, "Changed visibility of %s to %s.", repo.FullName(), visibility)
+	}
+	return nil
+}
+
 // LinkedRepository returns the linked repo if any
 func LinkedRepository(ctx context.Context, a *repo_model.Attachment) (*repo_model.Repository, unit.Type, error) {
 	if a.IssueID != 0 {
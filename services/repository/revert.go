@@ -0,0 +1,168 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	issues_model "code.gitea.io/gitea/models/issues"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/gitrepo"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/util"
+	pull_service "code.gitea.io/gitea/services/pull"
+	files_service "code.gitea.io/gitea/services/repository/files"
+)
+
+// ErrPullRequestNotMerged represents an attempt to revert a pull request which has not been merged
+type ErrPullRequestNotMerged struct {
+	ID int64
+}
+
+// IsErrPullRequestNotMerged checks if an error is a ErrPullRequestNotMerged.
+func IsErrPullRequestNotMerged(err error) bool {
+	_, ok := err.(ErrPullRequestNotMerged)
+	return ok
+}
+
+func (err ErrPullRequestNotMerged) Error() string {
+	return fmt.Sprintf("pull request has not been merged [id: %d]", err.ID)
+}
+
+func (err ErrPullRequestNotMerged) Unwrap() error {
+	return util.ErrInvalidArgument
+}
+
+// getPreMergeCommitID returns the commit the base branch pointed to before the pull request was merged.
+func getPreMergeCommitID(gitRepo *git.Repository, pr *issues_model.PullRequest) (string, error) {
+	merged, err := gitRepo.GetCommit(pr.MergedCommitID)
+	if err != nil {
+		return "", err
+	}
+	if merged.ParentCount() == 0 {
+		return "", fmt.Errorf("merged commit %s of %v has no parent", pr.MergedCommitID, pr)
+	}
+	firstParent, err := merged.ParentID(0)
+	if err != nil {
+		return "", err
+	}
+
+	// merge and rebase-merge styles (and most manual merges) create a merge commit
+	if merged.ParentCount() > 1 {
+		return firstParent.String(), nil
+	}
+
+	// Otherwise the pull request was squashed, rebased or fast-forwarded. Rebased commits keep the
+	// author and author date of the original commits, so count how many of them are on the base branch.
+	headCommitID, err := gitRepo.GetRefCommitID(pr.GetGitRefName())
+	if err != nil {
+		log.Warn("Unable to get head commit of %-v, assuming it was squashed: %v", pr, err)
+		return firstParent.String(), nil
+	}
+	commits, err := gitRepo.CommitsBetweenIDs(headCommitID, pr.MergeBase)
+	if err != nil {
+		return "", err
+	}
+	authored := make(map[string]bool, len(commits))
+	for _, commit := range commits {
+		authored[fmt.Sprintf("%s %d %s", commit.Author.Email, commit.Author.When.Unix(), commit.Summary())] = true
+	}
+
+	current := merged
+	for range commits {
+		if !authored[fmt.Sprintf("%s %d %s", current.Author.Email, current.Author.When.Unix(), current.Summary())] {
+			break
+		}
+		if current.ParentCount() == 0 {
+			return "", fmt.Errorf("commits of %v reach the root commit", pr)
+		}
+		if current, err = current.Parent(0); err != nil {
+			return "", err
+		}
+	}
+	if current == merged {
+		// a squash commit does not match any of the original commits
+		return firstParent.String(), nil
+	}
+	return current.ID.String(), nil
+}
+
+// getRevertBranchName returns a name for the branch reverting the pull request which does not exist yet
+func getRevertBranchName(gitRepo *git.Repository, pr *issues_model.PullRequest) string {
+	name := fmt.Sprintf("revert-pr-%d", pr.Index)
+	for i := 1; gitRepo.IsBranchExist(name); i++ {
+		name = fmt.Sprintf("revert-pr-%d-%d", pr.Index, i)
+	}
+	return name
+}
+
+// RevertPullRequest creates a branch reverting all the changes merged by the pull request and opens
+// a pull request to merge it into the base branch of the original one.
+func RevertPullRequest(ctx context.Context, doer *user_model.User, pr *issues_model.PullRequest) (*issues_model.PullRequest, error) {
+	if !pr.HasMerged || pr.MergedCommitID == "" {
+		return nil, ErrPullRequestNotMerged{ID: pr.ID}
+	}
+	if err := pr.LoadIssue(ctx); err != nil {
+		return nil, err
+	}
+	if err := pr.LoadBaseRepo(ctx); err != nil {
+		return nil, err
+	}
+	repo := pr.BaseRepo
+
+	gitRepo, closer, err := gitrepo.RepositoryFromContextOrOpen(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	preMergeCommitID, err := getPreMergeCommitID(gitRepo, pr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the commit before %v was merged: %w", pr, err)
+	}
+	baseCommitID, err := gitRepo.GetBranchCommitID(pr.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	title := fmt.Sprintf("Revert \"%s\"", pr.Issue.Title)
+	branchName := getRevertBranchName(gitRepo, pr)
+	if _, err := files_service.RevertRange(ctx, repo, doer, preMergeCommitID, pr.MergedCommitID, &files_service.ApplyDiffPatchOptions{
+		LastCommitID: baseCommitID,
+		OldBranch:    pr.BaseBranch,
+		NewBranch:    branchName,
+		Message:      fmt.Sprintf("%s\n\nThis reverts pull request #%d, merged as commit %s.", title, pr.Index, pr.MergedCommitID),
+	}); err != nil {
+		return nil, err
+	}
+
+	revertIssue := &issues_model.Issue{
+		RepoID:   repo.ID,
+		Title:    title,
+		PosterID: doer.ID,
+		Poster:   doer,
+		IsPull:   true,
+		Content:  fmt.Sprintf("Reverts #%d", pr.Index),
+	}
+	revertPR := &issues_model.PullRequest{
+		HeadRepoID: repo.ID,
+		BaseRepoID: repo.ID,
+		HeadBranch: branchName,
+		BaseBranch: pr.BaseBranch,
+		HeadRepo:   repo,
+		BaseRepo:   repo,
+		MergeBase:  baseCommitID,
+		Type:       issues_model.PullRequestGitea,
+	}
+	if err := pull_service.NewPullRequest(ctx, repo, revertIssue, nil, nil, revertPR, nil); err != nil {
+		// do not leave the revert branch behind, a new one would be created by the next attempt
+		if err := DeleteBranch(ctx, doer, repo, gitRepo, branchName); err != nil {
+			log.Error("Unable to delete the revert branch %s of %-v: %v", branchName, repo, err)
+		}
+		return nil, err
+	}
+	return revertPR, nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/container"
+	scim_module "code.gitea.io/gitea/modules/scim"
+	"code.gitea.io/gitea/modules/util"
+)
+
+func toSCIMGroup(ctx context.Context, g *auth_model.SCIMGroup, withMembers bool) (*scim_module.Group, error) {
+	created, lastModified := g.CreatedUnix.AsTime(), g.UpdatedUnix.AsTime()
+	sg := &scim_module.Group{
+		Schemas:     []string{scim_module.SchemaGroup},
+		ID:          strconv.FormatInt(g.ID, 10),
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Meta: &scim_module.Meta{
+			ResourceType: "Group",
+			Created:      &created,
+			LastModified: &lastModified,
+			Location:     Location("Groups", g.ID),
+		},
+	}
+	if !withMembers {
+		return sg, nil
+	}
+	ids, err := auth_model.GetSCIMGroupMemberIDs(ctx, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := user_model.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range members {
+		sg.Members = append(sg.Members, scim_module.MultiValue{
+			Value:   strconv.FormatInt(u.ID, 10),
+			Display: u.LoginName,
+			Ref:     Location("Users", u.ID),
+		})
+	}
+	return sg, nil
+}
+
+// GetGroup returns a SCIM group of the authentication source
+func GetGroup(ctx context.Context, source *auth_model.Source, id string, withMembers bool) (*scim_module.Group, error) {
+	gid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	g, err := auth_model.GetSCIMGroupByID(ctx, source.ID, gid)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMGroup(ctx, g, withMembers)
+}
+
+// FindGroups returns a page of the SCIM groups of the authentication source, startIndex is 1-based.
+// The only supported filter is on the displayName.
+func FindGroups(ctx context.Context, source *auth_model.Source, filter string, startIndex, count int, withMembers bool) (*scim_module.ListResponse, error) {
+	opts := auth_model.FindSCIMGroupsOptions{SourceID: source.ID}
+	if filter != "" {
+		f, err := scim_module.ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if f.Attribute != "displayname" {
+			return nil, &scim_module.InvalidError{ScimType: scim_module.ErrorTypeInvalidFilter, Detail: "groups can only be filtered by displayName"}
+		}
+		if f.Value == "" {
+			return scim_module.NewListResponse([]*scim_module.Group{}, 0, 0, startIndex), nil
+		}
+		opts.DisplayName = f.Value
+	}
+
+	groups, total, err := auth_model.FindSCIMGroups(ctx, opts, db.NewAbsoluteListOptions(startIndex-1, count))
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]*scim_module.Group, 0, len(groups))
+	for _, g := range groups {
+		sg, err := toSCIMGroup(ctx, g, withMembers)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, sg)
+	}
+	return scim_module.NewListResponse(resources, int64(len(resources)), total, startIndex), nil
+}
+
+// CreateGroup creates a SCIM group in the authentication source, its members join the teams the group is mapped to
+func CreateGroup(ctx context.Context, source *auth_model.Source, sg *scim_module.Group) (*scim_module.Group, error) {
+	if sg.DisplayName == "" {
+		return nil, util.NewInvalidArgumentErrorf("displayName is required")
+	}
+	g := &auth_model.SCIMGroup{
+		SourceID:    source.ID,
+		DisplayName: sg.DisplayName,
+		ExternalID:  sg.ExternalID,
+	}
+	if err := db.WithTx(ctx, func(ctx context.Context) error {
+		if err := auth_model.CreateSCIMGroup(ctx, g); err != nil {
+			return err
+		}
+		return setGroupMembers(ctx, source, g, sg.Members, false)
+	}); err != nil {
+		return nil, err
+	}
+	return toSCIMGroup(ctx, g, true)
+}
+
+// ReplaceGroup updates a SCIM group of the authentication source with the attributes and the members of sg
+func ReplaceGroup(ctx context.Context, source *auth_model.Source, id string, sg *scim_module.Group) (*scim_module.Group, error) {
+	gid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	g, err := auth_model.GetSCIMGroupByID(ctx, source.ID, gid)
+	if err != nil {
+		return nil, err
+	}
+	if err := updateGroup(ctx, source, g, sg); err != nil {
+		return nil, err
+	}
+	return toSCIMGroup(ctx, g, true)
+}
+
+// PatchGroup applies the operations of a patch to a SCIM group of the authentication source
+func PatchGroup(ctx context.Context, source *auth_model.Source, id string, ops []scim_module.Operation) (*scim_module.Group, error) {
+	gid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	g, err := auth_model.GetSCIMGroupByID(ctx, source.ID, gid)
+	if err != nil {
+		return nil, err
+	}
+	sg, err := toSCIMGroup(ctx, g, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := scim_module.ApplyGroupPatch(sg, ops); err != nil {
+		return nil, err
+	}
+	if err := updateGroup(ctx, source, g, sg); err != nil {
+		return nil, err
+	}
+	return toSCIMGroup(ctx, g, true)
+}
+
+func updateGroup(ctx context.Context, source *auth_model.Source, g *auth_model.SCIMGroup, sg *scim_module.Group) error {
+	if sg.DisplayName == "" {
+		return util.NewInvalidArgumentErrorf("displayName is required")
+	}
+	renamed := sg.DisplayName != g.DisplayName
+	g.DisplayName, g.ExternalID = sg.DisplayName, sg.ExternalID
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if err := auth_model.UpdateSCIMGroup(ctx, g); err != nil {
+			return err
+		}
+		return setGroupMembers(ctx, source, g, sg.Members, renamed)
+	})
+}
+
+// setGroupMembers sets the members of a SCIM group and synchronizes the teams of the users who joined or left it,
+// or of all its members when the group was renamed since the teams are mapped by name
+func setGroupMembers(ctx context.Context, source *auth_model.Source, g *auth_model.SCIMGroup, members []scim_module.MultiValue, renamed bool) error {
+	users := make(map[int64]*user_model.User, len(members))
+	for _, member := range members {
+		u, err := getSourceUser(ctx, source, member.Value)
+		if err != nil {
+			if errors.Is(err, util.ErrNotExist) {
+				return util.NewInvalidArgumentErrorf("member %q is not a user of the authentication source", member.Value)
+			}
+			return err
+		}
+		users[u.ID] = u
+	}
+	ids, err := auth_model.GetSCIMGroupMemberIDs(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+	previous := container.SetOf(ids...)
+
+	for _, id := range ids {
+		if _, ok := users[id]; ok {
+			continue
+		}
+		if err := auth_model.RemoveSCIMGroupMember(ctx, g.ID, id); err != nil {
+			return err
+		}
+		u, err := user_model.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := syncUserTeams(ctx, source, u); err != nil {
+			return err
+		}
+	}
+	for id, u := range users {
+		if previous.Contains(id) {
+			if !renamed {
+				continue
+			}
+		} else if err := auth_model.AddSCIMGroupMember(ctx, g.ID, id); err != nil {
+			return err
+		}
+		if err := syncUserTeams(ctx, source, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteGroup deletes a SCIM group of the authentication source, its members leave the teams the group is mapped to
+func DeleteGroup(ctx context.Context, source *auth_model.Source, id string) error {
+	gid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	g, err := auth_model.GetSCIMGroupByID(ctx, source.ID, gid)
+	if err != nil {
+		return err
+	}
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if err := setGroupMembers(ctx, source, g, nil, false); err != nil {
+			return err
+		}
+		return auth_model.DeleteSCIMGroup(ctx, g)
+	})
+}
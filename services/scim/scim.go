@@ -0,0 +1,82 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+// Package scim provisions the users of an authentication source and maps the groups of the
+// identity provider to organization teams, as requested through the SCIM 2.0 API
+package scim
+
+import (
+	"context"
+	"strconv"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	user_model "code.gitea.io/gitea/models/user"
+	auth_module "code.gitea.io/gitea/modules/auth"
+	"code.gitea.io/gitea/modules/container"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/modules/validation"
+	source_service "code.gitea.io/gitea/services/auth/source"
+)
+
+// Location returns the URL of a resource of the SCIM API
+func Location(resourceType string, id int64) string {
+	return setting.AppURL + "scim/v2/" + resourceType + "/" + strconv.FormatInt(id, 10)
+}
+
+func parseID(id string) (int64, error) {
+	i, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, util.NewNotExistErrorf("resource %q does not exist", id)
+	}
+	return i, nil
+}
+
+// getSourceUser returns a user provisioned in the authentication source
+func getSourceUser(ctx context.Context, source *auth_model.Source, id string) (*user_model.User, error) {
+	uid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	u, err := user_model.GetUserByID(ctx, uid)
+	if err != nil {
+		if user_model.IsErrUserNotExist(err) {
+			return nil, util.NewNotExistErrorf("user %q does not exist", id)
+		}
+		return nil, err
+	}
+	if u.LoginType != source.Type || u.LoginSource != source.ID {
+		return nil, util.NewNotExistErrorf("user %q does not exist", id)
+	}
+	return u, nil
+}
+
+// syncUserTeams synchronizes the teams of a user with the SCIM groups they are a member of.
+// The identity provider is authoritative, the user is removed from the mapped teams of the groups they left.
+func syncUserTeams(ctx context.Context, source *auth_model.Source, u *user_model.User) error {
+	mapper, ok := source.Cfg.(auth_model.GroupTeamMapper)
+	if !ok || mapper.GetGroupTeamMap() == "" {
+		return nil
+	}
+	groupTeamMapping, err := auth_module.UnmarshalGroupTeamMapping(mapper.GetGroupTeamMap())
+	if err != nil {
+		return err
+	}
+	groups, _, err := auth_model.FindSCIMGroups(ctx, auth_model.FindSCIMGroupsOptions{SourceID: source.ID, MemberID: u.ID}, nil)
+	if err != nil {
+		return err
+	}
+	names := make(container.Set[string], len(groups))
+	for _, g := range groups {
+		names.Add(g.DisplayName)
+	}
+	return source_service.SyncGroupsToTeams(ctx, u, names, groupTeamMapping, true)
+}
+
+// invalidEmailError turns the errors of the validation of an email address into invalid argument errors
+func invalidEmailError(err error) error {
+	if validation.IsErrEmailCharIsNotSupported(err) || validation.IsErrEmailInvalid(err) {
+		return util.NewInvalidArgumentErrorf("%v", err)
+	}
+	return err
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package scim
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/optional"
+	scim_module "code.gitea.io/gitea/modules/scim"
+	"code.gitea.io/gitea/modules/util"
+	user_service "code.gitea.io/gitea/services/user"
+)
+
+func toSCIMUser(ctx context.Context, source *auth_model.Source, u *user_model.User) (*scim_module.User, error) {
+	active := !u.ProhibitLogin
+	created, lastModified := u.CreatedUnix.AsTime(), u.UpdatedUnix.AsTime()
+	su := &scim_module.User{
+		Schemas:     []string{scim_module.SchemaUser},
+		ID:          strconv.FormatInt(u.ID, 10),
+		UserName:    u.LoginName,
+		DisplayName: u.FullName,
+		Active:      &active,
+		Emails:      []scim_module.MultiValue{{Value: u.Email, Primary: true}},
+		Meta: &scim_module.Meta{
+			ResourceType: "User",
+			Created:      &created,
+			LastModified: &lastModified,
+			Location:     Location("Users", u.ID),
+		},
+	}
+	if u.FullName != "" {
+		su.Name = &scim_module.Name{Formatted: u.FullName}
+	}
+	groups, _, err := auth_model.FindSCIMGroups(ctx, auth_model.FindSCIMGroupsOptions{SourceID: source.ID, MemberID: u.ID}, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		su.Groups = append(su.Groups, scim_module.MultiValue{
+			Value:   strconv.FormatInt(g.ID, 10),
+			Display: g.DisplayName,
+			Ref:     Location("Groups", g.ID),
+		})
+	}
+	return su, nil
+}
+
+// GetUser returns a user provisioned in the authentication source
+func GetUser(ctx context.Context, source *auth_model.Source, id string) (*scim_module.User, error) {
+	u, err := getSourceUser(ctx, source, id)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMUser(ctx, source, u)
+}
+
+// FindUsers returns a page of the users provisioned in the authentication source, startIndex is 1-based.
+// The only supported filter is on the userName.
+func FindUsers(ctx context.Context, source *auth_model.Source, filter string, startIndex, count int) (*scim_module.ListResponse, error) {
+	var loginName string
+	if filter != "" {
+		f, err := scim_module.ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		if f.Attribute != "username" {
+			return nil, &scim_module.InvalidError{ScimType: scim_module.ErrorTypeInvalidFilter, Detail: "users can only be filtered by userName"}
+		}
+		if f.Value == "" {
+			return scim_module.NewListResponse([]*scim_module.User{}, 0, 0, startIndex), nil
+		}
+		loginName = f.Value
+	}
+
+	users, total, err := user_model.FindUsersBySource(ctx, source, loginName, db.NewAbsoluteListOptions(startIndex-1, count))
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]*scim_module.User, 0, len(users))
+	for _, u := range users {
+		su, err := toSCIMUser(ctx, source, u)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, su)
+	}
+	return scim_module.NewListResponse(resources, int64(len(resources)), total, startIndex), nil
+}
+
+// CreateUser provisions a user in the authentication source.
+// Their login name is the userName, and so is their username unless it is an email address, then its local part is used.
+func CreateUser(ctx context.Context, source *auth_model.Source, su *scim_module.User) (*scim_module.User, error) {
+	if su.UserName == "" {
+		return nil, util.NewInvalidArgumentErrorf("userName is required")
+	}
+	if err := checkLoginNameAvailable(ctx, source, su.UserName); err != nil {
+		return nil, err
+	}
+
+	name := su.UserName
+	if local, _, ok := strings.Cut(name, "@"); ok {
+		name = local
+	}
+	email := su.PrimaryEmail()
+	if email == "" {
+		email = fmt.Sprintf("%s@localhost.local", name)
+	}
+	u := &user_model.User{
+		LowerName:     strings.ToLower(name),
+		Name:          name,
+		FullName:      su.FullName(),
+		Email:         email,
+		LoginType:     source.Type,
+		LoginSource:   source.ID,
+		LoginName:     su.UserName,
+		ProhibitLogin: !su.IsActive(),
+	}
+	overwriteDefault := &user_model.CreateUserOverwriteOptions{
+		IsActive: optional.Some(true),
+	}
+	if err := user_model.CreateUser(ctx, u, overwriteDefault); err != nil {
+		return nil, invalidEmailError(err)
+	}
+	return toSCIMUser(ctx, source, u)
+}
+
+func checkLoginNameAvailable(ctx context.Context, source *auth_model.Source, loginName string) error {
+	_, err := user_model.GetUserBySourceAndLoginName(ctx, source, loginName)
+	if err == nil {
+		return util.NewAlreadyExistErrorf("user %q already exists", loginName)
+	} else if !user_model.IsErrUserNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ReplaceUser updates a user provisioned in the authentication source with the attributes of su.
+// Changing the userName changes the login name of the user but does not rename them.
+func ReplaceUser(ctx context.Context, source *auth_model.Source, id string, su *scim_module.User) (*scim_module.User, error) {
+	u, err := getSourceUser(ctx, source, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := updateUser(ctx, source, u, su); err != nil {
+		return nil, err
+	}
+	return toSCIMUser(ctx, source, u)
+}
+
+// PatchUser applies the operations of a patch to a user provisioned in the authentication source
+func PatchUser(ctx context.Context, source *auth_model.Source, id string, ops []scim_module.Operation) (*scim_module.User, error) {
+	u, err := getSourceUser(ctx, source, id)
+	if err != nil {
+		return nil, err
+	}
+	su, err := toSCIMUser(ctx, source, u)
+	if err != nil {
+		return nil, err
+	}
+	if err := scim_module.ApplyUserPatch(su, ops); err != nil {
+		return nil, err
+	}
+	if err := updateUser(ctx, source, u, su); err != nil {
+		return nil, err
+	}
+	return toSCIMUser(ctx, source, u)
+}
+
+func updateUser(ctx context.Context, source *auth_model.Source, u *user_model.User, su *scim_module.User) error {
+	if su.UserName == "" {
+		return util.NewInvalidArgumentErrorf("userName is required")
+	}
+	authOpts := &user_service.UpdateAuthOptions{}
+	if su.UserName != u.LoginName {
+		if err := checkLoginNameAvailable(ctx, source, su.UserName); err != nil {
+			return err
+		}
+		authOpts.LoginName = optional.Some(su.UserName)
+	}
+	if prohibitLogin := !su.IsActive(); prohibitLogin != u.ProhibitLogin {
+		authOpts.ProhibitLogin = optional.Some(prohibitLogin)
+	}
+	if authOpts.LoginName.Has() || authOpts.ProhibitLogin.Has() {
+		if err := user_service.UpdateAuth(ctx, u, authOpts); err != nil {
+			return err
+		}
+	}
+
+	if fullName := su.FullName(); fullName != u.FullName {
+		if err := user_service.UpdateUser(ctx, nil, u, &user_service.UpdateOptions{FullName: optional.Some(fullName)}); err != nil {
+			return err
+		}
+	}
+
+	if email := su.PrimaryEmail(); email != "" {
+		if err := user_service.AdminAddOrSetPrimaryEmailAddress(ctx, u, email); err != nil {
+			return invalidEmailError(err)
+		}
+	}
+	return nil
+}
+
+// DeprovisionUser prohibits a user provisioned in the authentication source from signing in,
+// and removes them from the SCIM groups and their mapped teams. The user is not deleted.
+func DeprovisionUser(ctx context.Context, source *auth_model.Source, id string) error {
+	u, err := getSourceUser(ctx, source, id)
+	if err != nil {
+		return err
+	}
+	return db.WithTx(ctx, func(ctx context.Context) error {
+		if !u.ProhibitLogin {
+			if err := user_service.UpdateAuth(ctx, u, &user_service.UpdateAuthOptions{ProhibitLogin: optional.Some(true)}); err != nil {
+				return err
+			}
+		}
+		groups, _, err := auth_model.FindSCIMGroups(ctx, auth_model.FindSCIMGroupsOptions{SourceID: source.ID, MemberID: u.ID}, nil)
+		if err != nil {
+			return err
+		}
+		for _, g := range groups {
+			if err := auth_model.RemoveSCIMGroupMember(ctx, g.ID, u.ID); err != nil {
+				return err
+			}
+		}
+		return syncUserTeams(ctx, source, u)
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package user
+
+import (
+	"context"
+	"fmt"
+
+	"code.gitea.io/gitea/models/forgefed"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/log"
+	"code.gitea.io/gitea/modules/util"
+)
+
+// PurgeFederationHost defederates from a blocked host: the users federated from it are purged
+// together with their issues and comments, and every other reference to the host is dropped.
+// The host itself is kept so it stays blocked.
+func PurgeFederationHost(ctx context.Context, doer *user_model.User, host *forgefed.FederationHost) error {
+	if !host.IsBlocked() {
+		return util.NewInvalidArgumentErrorf("federation host %s is not blocked", host.HostFqdn)
+	}
+
+	federatedUsers, err := user_model.FindFederatedUsersByHostID(ctx, host.ID)
+	if err != nil {
+		return err
+	}
+	for _, federatedUser := range federatedUsers {
+		u, err := user_model.GetUserByID(ctx, federatedUser.UserID)
+		if err != nil {
+			if user_model.IsErrUserNotExist(err) {
+				if err := user_model.DeleteFederatedUser(ctx, federatedUser.UserID); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if err := DeleteUser(ctx, doer, u, true); err != nil {
+			return fmt.Errorf("DeleteUser %d: %w", u.ID, err)
+		}
+	}
+
+	if err := issues_model.DeleteFederatedIssuesByHostID(ctx, host.ID); err != nil {
+		return err
+	}
+	if err := repo_model.DeleteFollowingReposByHostID(ctx, host.ID); err != nil {
+		return err
+	}
+	if err := forgefed.DeleteDeliveriesByHost(ctx, host.ID); err != nil {
+		return err
+	}
+	log.Info("Purged %d federated users of %s", len(federatedUsers), host.HostFqdn)
+	return nil
+}
@@ -8,12 +8,14 @@ import (
 	"fmt"
 
 	"code.gitea.io/gitea/models"
+	audit_model "code.gitea.io/gitea/models/audit"
 	auth_model "code.gitea.io/gitea/models/auth"
 	user_model "code.gitea.io/gitea/models/user"
 	password_module "code.gitea.io/gitea/modules/auth/password"
 	"code.gitea.io/gitea/modules/optional"
 	"code.gitea.io/gitea/modules/setting"
 	"code.gitea.io/gitea/modules/structs"
+	audit_service "code.gitea.io/gitea/services/audit"
 	"code.gitea.io/gitea/services/mailer"
 )
 
@@ -42,8 +44,12 @@ type UpdateOptions struct {
 	EnableRepoUnitHints          optional.Option[bool]
 }
 
-func UpdateUser(ctx context.Context, u *user_model.User, opts *UpdateOptions) error {
+// UpdateUser updates the fields of a user that are set in opts,
+// changes of the admin and restricted flags are recorded in the audit log.
+// The doer is nil when the user is updated by the system, e.g. by an authentication source.
+func UpdateUser(ctx context.Context, doer, u *user_model.User, opts *UpdateOptions) error {
 	cols := make([]string, 0, 20)
+	wasAdmin, wasRestricted := u.IsAdmin, u.IsRestricted
 
 	if opts.KeepEmailPrivate.Has() {
 		u.KeepEmailPrivate = opts.KeepEmailPrivate.Value()
@@ -171,7 +177,25 @@ func UpdateUser(ctx context.Context, u *user_model.User, opts *UpdateOptions) er
 		cols = append(cols, "last_login_unix")
 	}
 
-	return user_model.UpdateUserCols(ctx, u, cols...)
+	if err := user_model.UpdateUserCols(ctx, u, cols...); err != nil {
+		return err
+	}
+
+	if u.IsAdmin != wasAdmin {
+		if u.IsAdmin {
+			audit_service.Record(ctx, audit_model.UserAdmin, doer, nil, u, "Granted administrator rights to %s.", u.Name)
+		} else {
+			audit_service.Record(ctx, audit_model.UserAdmin, doer, nil, u, "Revoked administrator rights of %s.", u.Name)
+		}
+	}
+	if u.IsRestricted != wasRestricted {
+		if u.IsRestricted {
+			audit_service.Record(ctx, audit_model.UserRestricted, doer, nil, u, "Restricted %s.", u.Name)
+		} else {
+			audit_service.Record(ctx, audit_model.UserRestricted, doer, nil, u, "Lifted the restriction of %s.", u.Name)
+		}
+	}
+	return nil
 }
 
 type UpdateAuthOptions struct {
@@ -6,6 +6,7 @@ package user
 import (
 	"testing"
 
+	audit_model "code.gitea.io/gitea/models/audit"
 	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/models/unittest"
 	user_model "code.gitea.io/gitea/models/user"
@@ -22,7 +23,7 @@ func TestUpdateUser(t *testing.T) {
 
 	admin := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
 
-	require.Error(t, UpdateUser(db.DefaultContext, admin, &UpdateOptions{
+	require.Error(t, UpdateUser(db.DefaultContext, admin, admin, &UpdateOptions{
 		IsAdmin: optional.Some(false),
 	}))
 
@@ -49,7 +50,7 @@ func TestUpdateUser(t *testing.T) {
 		EmailNotificationsPreference: optional.Some("disabled"),
 		SetLastLogin:                 true,
 	}
-	require.NoError(t, UpdateUser(db.DefaultContext, user, opts))
+	require.NoError(t, UpdateUser(db.DefaultContext, admin, user, opts))
 
 	assert.Equal(t, opts.KeepEmailPrivate.Value(), user.KeepEmailPrivate)
 	assert.Equal(t, opts.FullName.Value(), user.FullName)
@@ -91,6 +92,38 @@ func TestUpdateUser(t *testing.T) {
 	assert.Equal(t, opts.EmailNotificationsPreference.Value(), user.EmailNotificationsPreference)
 }
 
+func TestUpdateUserAuditLog(t *testing.T) {
+	require.NoError(t, unittest.PrepareTestDatabase())
+
+	admin := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 28})
+
+	require.NoError(t, UpdateUser(db.DefaultContext, admin, user, &UpdateOptions{
+		IsAdmin:      optional.Some(true),
+		IsRestricted: optional.Some(true),
+	}))
+	unittest.AssertExistsAndLoadBean(t, &audit_model.Event{Action: audit_model.UserAdmin, ActorID: admin.ID, TargetID: user.ID, Message: "Granted administrator rights to user28."})
+	unittest.AssertExistsAndLoadBean(t, &audit_model.Event{Action: audit_model.UserRestricted, ActorID: admin.ID, TargetID: user.ID, Message: "Restricted user28."})
+
+	// unchanged flags and other fields are not recorded
+	require.NoError(t, UpdateUser(db.DefaultContext, admin, user, &UpdateOptions{
+		FullName:     optional.Some("Changed Name"),
+		IsAdmin:      optional.Some(true),
+		IsRestricted: optional.Some(true),
+	}))
+	unittest.AssertCount(t, &audit_model.Event{TargetID: user.ID}, 2)
+
+	// changes made by the system, e.g. an authentication source, have no actor
+	require.NoError(t, UpdateUser(db.DefaultContext, nil, user, &UpdateOptions{
+		IsAdmin:      optional.Some(false),
+		IsRestricted: optional.Some(false),
+	}))
+	event := unittest.AssertExistsAndLoadBean(t, &audit_model.Event{Action: audit_model.UserAdmin, TargetID: user.ID, Message: "Revoked administrator rights of user28."})
+	assert.Zero(t, event.ActorID)
+	event = unittest.AssertExistsAndLoadBean(t, &audit_model.Event{Action: audit_model.UserRestricted, TargetID: user.ID, Message: "Lifted the restriction of user28."})
+	assert.Zero(t, event.ActorID)
+}
+
 func TestUpdateAuth(t *testing.T) {
 	require.NoError(t, unittest.PrepareTestDatabase())
 
@@ -35,7 +35,7 @@ func TestDeleteUser(t *testing.T) {
 		ownedRepos := make([]*repo_model.Repository, 0, 10)
 		require.NoError(t, db.GetEngine(db.DefaultContext).Find(&ownedRepos, &repo_model.Repository{OwnerID: userID}))
 		if len(ownedRepos) > 0 {
-			err := DeleteUser(db.DefaultContext, user, false)
+			err := DeleteUser(db.DefaultContext, nil, user, false)
 			require.Error(t, err)
 			assert.True(t, models.IsErrUserOwnRepos(err))
 			return
@@ -49,7 +49,7 @@ func TestDeleteUser(t *testing.T) {
 				return
 			}
 		}
-		require.NoError(t, DeleteUser(db.DefaultContext, user, false))
+		require.NoError(t, DeleteUser(db.DefaultContext, nil, user, false))
 		unittest.AssertNotExistsBean(t, &user_model.User{ID: userID})
 		unittest.CheckConsistencyFor(t, &user_model.User{}, &repo_model.Repository{})
 	}
@@ -59,7 +59,7 @@ func TestDeleteUser(t *testing.T) {
 	test(11)
 
 	org := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 3})
-	require.Error(t, DeleteUser(db.DefaultContext, org, false))
+	require.Error(t, DeleteUser(db.DefaultContext, nil, org, false))
 }
 
 func TestPurgeUser(t *testing.T) {
@@ -67,7 +67,7 @@ func TestPurgeUser(t *testing.T) {
 		require.NoError(t, unittest.PrepareTestDatabase())
 		user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: userID})
 
-		err := DeleteUser(db.DefaultContext, user, true)
+		err := DeleteUser(db.DefaultContext, nil, user, true)
 		require.NoError(t, err)
 
 		unittest.AssertNotExistsBean(t, &user_model.User{ID: userID})
@@ -79,7 +79,7 @@ func TestPurgeUser(t *testing.T) {
 	test(11)
 
 	org := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 3})
-	require.Error(t, DeleteUser(db.DefaultContext, org, false))
+	require.Error(t, DeleteUser(db.DefaultContext, nil, org, false))
 }
 
 func TestCreateUser(t *testing.T) {
@@ -94,7 +94,7 @@ func TestCreateUser(t *testing.T) {
 
 	require.NoError(t, user_model.CreateUser(db.DefaultContext, user))
 
-	require.NoError(t, DeleteUser(db.DefaultContext, user, false))
+	require.NoError(t, DeleteUser(db.DefaultContext, nil, user, false))
 }
 
 func TestRenameUser(t *testing.T) {
@@ -182,7 +182,7 @@ func TestCreateUser_Issue5882(t *testing.T) {
 
 		assert.Equal(t, !u.AllowCreateOrganization, v.disableOrgCreation)
 
-		require.NoError(t, DeleteUser(db.DefaultContext, v.user, false))
+		require.NoError(t, DeleteUser(db.DefaultContext, nil, v.user, false))
 	}
 }
 
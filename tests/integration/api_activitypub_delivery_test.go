@@ -0,0 +1,233 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/forgefed"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/routers"
+	federation_service "code.gitea.io/gitea/services/federation"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityPubDelivery(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
+	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
+
+	var publicKeyPem string
+	var mu sync.Mutex
+	var received []ap.Activity
+	// the inbox is unavailable until the test brings it up
+	inboxUp := false
+
+	federatedRoutes := http.NewServeMux()
+	federatedRoutes.HandleFunc("/.well-known/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(res, `{"links":[{"href":"http://%s/api/v1/nodeinfo","rel":"http://nodeinfo.diaspora.software/ns/schema/2.1"}]}`, req.Host)
+		})
+	federatedRoutes.HandleFunc("/api/v1/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(res, `{"version":"2.1","software":{"name":"forgejo","version":"1.20.0+dev-3183-g976d79044",`+
+				`"repository":"https://codeberg.org/forgejo/forgejo.git","homepage":"https://forgejo.org/"},`+
+				`"protocols":["activitypub"],"services":{"inbound":[],"outbound":["rss2.0"]},`+
+				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15", fakeFederatedPerson(t, "follower", &publicKeyPem))
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15/inbox",
+		func(res http.ResponseWriter, req *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !inboxUp {
+				res.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			activity := ap.Activity{}
+			require.NoError(t, activity.UnmarshalJSON(body))
+			received = append(received, activity)
+			res.WriteHeader(http.StatusAccepted)
+		})
+	// a person advertising a shared inbox on another host
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/16",
+		func(res http.ResponseWriter, req *http.Request) {
+			person := ap.PersonNew(ap.IRI(fmt.Sprintf("http://%s%s", req.Host, req.URL.Path)))
+			person.PreferredUsername = ap.DefaultNaturalLanguageValue("shared")
+			person.Inbox = ap.IRI(person.ID.String() + "/inbox")
+			person.Endpoints = &ap.Endpoints{SharedInbox: ap.IRI("http://169.254.169.254/latest/meta-data")}
+			person.PublicKey.ID = ap.IRI(person.ID.String() + "#main-key")
+			person.PublicKey.Owner = person.ID
+			person.PublicKey.PublicKeyPem = publicKeyPem
+			body, err := person.MarshalJSON()
+			require.NoError(t, err)
+			res.Write(body)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/16/inbox",
+		func(res http.ResponseWriter, req *http.Request) {
+			res.WriteHeader(http.StatusAccepted)
+		})
+	federatedRoutes.HandleFunc("/",
+		func(res http.ResponseWriter, req *http.Request) {
+			t.Errorf("Unhandled request: %q", req.URL.EscapedPath())
+		})
+	federatedSrv := httptest.NewServer(federatedRoutes)
+	defer federatedSrv.Close()
+
+	receivedOfType := func(typ ap.ActivityVocabularyType) []ap.Activity {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var result []ap.Activity
+		for _, activity := range received {
+			if activity.Type == typ {
+				result = append(result, activity)
+			}
+		}
+		return result
+	}
+
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+		user2 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+		var err error
+		publicKeyPem, err = activitypub.GetPublicKey(db.DefaultContext, user1)
+		require.NoError(t, err)
+
+		remoteActor := federatedSrv.URL + "/api/v1/activitypub/user-id/15"
+		cf, err := activitypub.GetClientFactory(db.DefaultContext)
+		require.NoError(t, err)
+		c, err := cf.WithKeys(db.DefaultContext, user1, remoteActor+"#main-key")
+		require.NoError(t, err)
+
+		localActor := u.JoinPath("/api/v1/activitypub/user-id/2").String()
+		follow := fmt.Sprintf(`{"id":"%s/follows/1","type":"Follow","actor":"%s","object":"%s"}`, remoteActor, remoteActor, localActor)
+
+		t.Run("FailedDelivery", func(t *testing.T) {
+			resp, err := c.Post([]byte(follow), localActor+"/inbox")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			// the Accept is kept for a retry and the host is backed off
+			assert.Eventually(t, func() bool {
+				host := unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{HostFqdn: "127.0.0.1"})
+				return host.ConsecutiveFailures == 1
+			}, 10*time.Second, 100*time.Millisecond)
+
+			delivery := unittest.AssertExistsAndLoadBean(t, &forgefed.Delivery{InboxURI: remoteActor + "/inbox"})
+			assert.Equal(t, 1, delivery.Attempts)
+			assert.Contains(t, delivery.LastError, "503")
+			assert.Equal(t, user2.ID, delivery.SignerID)
+			assert.Greater(t, delivery.NextAttempt, delivery.CreatedUnix)
+		})
+
+		t.Run("Retry", func(t *testing.T) {
+			mu.Lock()
+			inboxUp = true
+			mu.Unlock()
+
+			// due deliveries are picked up by the cron task, make the retry and the host due now
+			_, err := db.GetEngine(db.DefaultContext).Table("forgejo_federation_delivery").Where("id > 0").Update(map[string]any{"next_attempt": 0})
+			require.NoError(t, err)
+			_, err = db.GetEngine(db.DefaultContext).Table("federation_host").Where("host_fqdn = ?", "127.0.0.1").Update(map[string]any{"last_failure": 0})
+			require.NoError(t, err)
+			require.NoError(t, federation_service.EnqueueDueDeliveries(db.DefaultContext))
+
+			assert.Eventually(t, func() bool {
+				return len(receivedOfType(ap.AcceptType)) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+			assert.Eventually(t, func() bool {
+				return unittest.GetCount(t, &forgefed.Delivery{}) == 0
+			}, 10*time.Second, 100*time.Millisecond)
+
+			host := unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{HostFqdn: "127.0.0.1"})
+			assert.True(t, host.IsHealthy())
+			assert.Positive(t, host.LastSuccess)
+		})
+
+		t.Run("AdminPanel", func(t *testing.T) {
+			session := loginUser(t, user1.Name)
+			resp := session.MakeRequest(t, NewRequest(t, "GET", "/admin/federation"), http.StatusOK)
+			assert.Contains(t, resp.Body.String(), "127.0.0.1")
+		})
+
+		t.Run("RotateUserKey", func(t *testing.T) {
+			oldKey, err := activitypub.GetPublicKey(db.DefaultContext, user2)
+			require.NoError(t, err)
+
+			session := loginUser(t, user2.Name)
+			req := NewRequestWithValues(t, "POST", "/user/settings/security/federation_key", map[string]string{
+				"_csrf": GetCSRF(t, session, "/user/settings/security"),
+			})
+			session.MakeRequest(t, req, http.StatusSeeOther)
+
+			newKey, err := activitypub.GetPublicKey(db.DefaultContext, user2)
+			require.NoError(t, err)
+			assert.NotEqual(t, oldKey, newKey)
+
+			// the followers are sent the new key
+			assert.Eventually(t, func() bool {
+				return len(receivedOfType(ap.UpdateType)) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+			person, err := ap.ToActor(receivedOfType(ap.UpdateType)[0].Object)
+			require.NoError(t, err)
+			assert.Equal(t, localActor, person.ID.String())
+			assert.Equal(t, newKey, person.PublicKey.PublicKeyPem)
+		})
+
+		t.Run("RotateInstanceKey", func(t *testing.T) {
+			oldKey, err := activitypub.GetPublicKey(db.DefaultContext, user_model.NewAPActorUser())
+			require.NoError(t, err)
+
+			session := loginUser(t, user1.Name)
+			req := NewRequestWithValues(t, "POST", "/admin/federation/rotate-key", map[string]string{
+				"_csrf": GetCSRF(t, session, "/admin/federation"),
+			})
+			session.MakeRequest(t, req, http.StatusSeeOther)
+
+			newKey, err := activitypub.GetPublicKey(db.DefaultContext, user_model.NewAPActorUser())
+			require.NoError(t, err)
+			assert.NotEqual(t, oldKey, newKey)
+
+			resp := MakeRequest(t, NewRequest(t, "GET", "/api/v1/activitypub/actor"), http.StatusOK)
+			actor := ap.Actor{}
+			require.NoError(t, actor.UnmarshalJSON(resp.Body.Bytes()))
+			assert.Equal(t, newKey, actor.PublicKey.PublicKeyPem)
+		})
+
+		t.Run("SharedInboxOnAnotherHost", func(t *testing.T) {
+			otherActor := federatedSrv.URL + "/api/v1/activitypub/user-id/16"
+			c, err := cf.WithKeys(db.DefaultContext, user1, otherActor+"#main-key")
+			require.NoError(t, err)
+
+			follow := fmt.Sprintf(`{"id":"%s/follows/1","type":"Follow","actor":"%s","object":"%s"}`, otherActor, otherActor, localActor)
+			resp, err := c.Post([]byte(follow), localActor+"/inbox")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			unittest.AssertExistsAndLoadBean(t, &user_model.FederatedUser{ExternalID: "16"})
+			host := unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{HostFqdn: "127.0.0.1"})
+			assert.Empty(t, host.SharedInboxURI)
+		})
+	})
+}
@@ -0,0 +1,297 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/modules/util"
+	"code.gitea.io/gitea/routers"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityPubFederatedPullRequest(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
+	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
+
+	var publicKeyPem string
+	var gitRoot string
+	var mu sync.Mutex
+	var received []fm.ForgeOffer
+
+	gitPath, err := exec.LookPath(git.GitExecutable)
+	require.NoError(t, err)
+
+	federatedRoutes := http.NewServeMux()
+	federatedRoutes.HandleFunc("/.well-known/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(res, `{"links":[{"href":"http://%s/api/v1/nodeinfo","rel":"http://nodeinfo.diaspora.software/ns/schema/2.1"}]}`, req.Host)
+		})
+	federatedRoutes.HandleFunc("/api/v1/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(res, `{"version":"2.1","software":{"name":"forgejo","version":"1.20.0+dev-3183-g976d79044",`+
+				`"repository":"https://codeberg.org/forgejo/forgejo.git","homepage":"https://forgejo.org/"},`+
+				`"protocols":["activitypub"],"services":{"inbound":[],"outbound":["rss2.0"]},`+
+				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15", fakeFederatedPerson(t, "contributor", &publicKeyPem))
+	federatedRoutes.HandleFunc("/api/v1/activitypub/repository-id/2",
+		func(res http.ResponseWriter, req *http.Request) {
+			repository := fm.RepositoryNew(ap.IRI(fmt.Sprintf("http://%s%s", req.Host, req.URL.Path)))
+			repository.Name = ap.DefaultNaturalLanguageValue("repo1")
+			repository.Summary = ap.DefaultNaturalLanguageValue("A federated repository")
+			repository.Inbox = ap.IRI(repository.ID.String() + "/inbox")
+			repository.CloneURI = ap.IRI(fmt.Sprintf("http://%s/git/repo1.git", req.Host))
+			body, err := repository.MarshalJSON()
+			require.NoError(t, err)
+			res.Write(body)
+		})
+	// a repository advertising an inbox on another host
+	federatedRoutes.HandleFunc("/api/v1/activitypub/repository-id/3",
+		func(res http.ResponseWriter, req *http.Request) {
+			repository := fm.RepositoryNew(ap.IRI(fmt.Sprintf("http://%s%s", req.Host, req.URL.Path)))
+			repository.Name = ap.DefaultNaturalLanguageValue("repo3")
+			repository.Inbox = ap.IRI("http://169.254.169.254/latest/meta-data")
+			repository.CloneURI = ap.IRI(fmt.Sprintf("http://%s/git/repo1.git", req.Host))
+			body, err := repository.MarshalJSON()
+			require.NoError(t, err)
+			res.Write(body)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/repository-id/2/inbox",
+		func(res http.ResponseWriter, req *http.Request) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			offer := fm.ForgeOffer{}
+			require.NoError(t, offer.UnmarshalJSON(body))
+
+			mu.Lock()
+			received = append(received, offer)
+			mu.Unlock()
+			res.WriteHeader(http.StatusAccepted)
+		})
+	federatedRoutes.HandleFunc("/git/",
+		func(res http.ResponseWriter, req *http.Request) {
+			handler := &cgi.Handler{
+				Path: gitPath,
+				Args: []string{"http-backend"},
+				Root: "/git",
+				Env:  []string{"GIT_PROJECT_ROOT=" + gitRoot, "GIT_HTTP_EXPORT_ALL=1"},
+			}
+			handler.ServeHTTP(res, req)
+		})
+	federatedRoutes.HandleFunc("/",
+		func(res http.ResponseWriter, req *http.Request) {
+			t.Errorf("Unhandled request: %q", req.URL.EscapedPath())
+		})
+	federatedSrv := httptest.NewServer(federatedRoutes)
+	defer federatedSrv.Close()
+
+	receivedOffers := func() []fm.ForgeOffer {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]fm.ForgeOffer{}, received...)
+	}
+
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+		user2 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+		repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1, OwnerID: user2.ID})
+
+		publicKeyPem, err = activitypub.GetPublicKey(db.DefaultContext, user1)
+		require.NoError(t, err)
+
+		// the federated repository is a copy of repo1 with an additional branch
+		workPath := t.TempDir()
+		require.NoError(t, git.Clone(git.DefaultContext, repo.RepoPath(), workPath, git.CloneRepoOptions{}))
+		doGitCreateBranch(workPath, "feature")(t)
+		doGitAddSomeCommits(workPath, "feature")(t)
+		gitRoot = t.TempDir()
+		_, _, err = git.NewCommand(git.DefaultContext, "clone", "--bare").AddDynamicArguments(workPath, filepath.Join(gitRoot, "repo1.git")).RunStdString(&git.RunOpts{})
+		require.NoError(t, err)
+		featureCommitID, _, err := git.NewCommand(git.DefaultContext, "rev-parse", "feature").RunStdString(&git.RunOpts{Dir: workPath})
+		require.NoError(t, err)
+
+		remoteActor := federatedSrv.URL + "/api/v1/activitypub/user-id/15"
+		remoteRepo := federatedSrv.URL + "/api/v1/activitypub/repository-id/2"
+
+		t.Run("ReceiveOffer", func(t *testing.T) {
+			cf, err := activitypub.GetClientFactory(db.DefaultContext)
+			require.NoError(t, err)
+			c, err := cf.WithKeys(db.DefaultContext, user1, remoteActor+"#main-key")
+			require.NoError(t, err)
+
+			repoActor := u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%d", repo.ID)).String()
+			mrID := remoteRepo + "/merge-requests/1"
+			offer := fmt.Sprintf(`{"type":"Offer","actor":"%s","target":"%s","object":{"type":"Ticket","id":"%s",`+
+				`"attributedTo":"%s","context":"%s","summary":"Add a feature","content":"Please merge",`+
+				`"attachment":{"type":"Offer","origin":{"type":"Branch","context":"%s","ref":"refs/heads/feature"},`+
+				`"target":{"type":"Branch","context":"%s","ref":"refs/heads/master"}}}}`,
+				remoteActor, repoActor, mrID, remoteActor, repoActor, remoteRepo, repoActor)
+
+			// delivered twice, the pull request is only created once
+			for i := 0; i < 2; i++ {
+				resp, err := c.Post([]byte(offer), repoActor+"/inbox")
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			}
+
+			// the branch is fetched and the pull request opened by a queue
+			var federatedIssue *issues_model.FederatedIssue
+			assert.Eventually(t, func() bool {
+				federatedIssue, err = issues_model.GetFederatedIssueByObjectURI(db.DefaultContext, mrID)
+				return err == nil && federatedIssue.IssueID != 0
+			}, 10*time.Second, 100*time.Millisecond)
+			issue := unittest.AssertExistsAndLoadBean(t, &issues_model.Issue{ID: federatedIssue.IssueID, RepoID: repo.ID, IsPull: true})
+			assert.Equal(t, "Add a feature", issue.Title)
+
+			federatedUser := unittest.AssertExistsAndLoadBean(t, &user_model.FederatedUser{ExternalID: "15"})
+			assert.Equal(t, federatedUser.UserID, issue.PosterID)
+
+			pr := unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{IssueID: issue.ID})
+			assert.Equal(t, issues_model.PullRequestFlowAGit, pr.Flow)
+			assert.Equal(t, "master", pr.BaseBranch)
+			assert.Equal(t, strings.TrimSpace(featureCommitID), pr.HeadCommitID)
+
+			// merge requests larger than the limit are given up
+			func() {
+				defer test.MockVariableValue(&setting.Federation.MaxMergeRequestSize, 1)()
+				tooLargeID := remoteRepo + "/merge-requests/3"
+				tooLarge := fmt.Sprintf(`{"type":"Offer","actor":"%s","target":"%s","object":{"type":"Ticket","id":"%s",`+
+					`"attributedTo":"%s","context":"%s","summary":"Too large","content":"Too large",`+
+					`"attachment":{"type":"Offer","origin":{"type":"Branch","context":"%s","ref":"refs/heads/feature"},`+
+					`"target":{"type":"Branch","context":"%s","ref":"refs/heads/branch2"}}}}`,
+					remoteActor, repoActor, tooLargeID, remoteActor, repoActor, remoteRepo, repoActor)
+				resp, err := c.Post([]byte(tooLarge), repoActor+"/inbox")
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+				assert.Eventually(t, func() bool {
+					_, err := issues_model.GetFederatedIssueByObjectURI(db.DefaultContext, tooLargeID)
+					return errors.Is(err, util.ErrNotExist)
+				}, 10*time.Second, 100*time.Millisecond)
+				unittest.AssertNotExistsBean(t, &issues_model.Issue{RepoID: repo.ID, Title: "Too large"})
+			}()
+
+			// unknown base branches are refused
+			offer = fmt.Sprintf(`{"type":"Offer","actor":"%s","target":"%s","object":{"type":"Ticket","id":"%s/merge-requests/2",`+
+				`"attributedTo":"%s","context":"%s","summary":"Lost","content":"Lost",`+
+				`"attachment":{"type":"Offer","origin":{"type":"Branch","context":"%s","ref":"refs/heads/feature"},`+
+				`"target":{"type":"Branch","context":"%s","ref":"refs/heads/unknown"}}}}`,
+				remoteActor, repoActor, remoteRepo, remoteActor, repoActor, remoteRepo, repoActor)
+			resp, err := c.Post([]byte(offer), repoActor+"/inbox")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+			// merge requests are found again by their id, which must be on the host of the actor
+			otherID := u.JoinPath("/api/v1/activitypub/repository-id/1/merge-requests/1").String()
+			offer = fmt.Sprintf(`{"type":"Offer","actor":"%s","target":"%s","object":{"type":"Ticket","id":"%s",`+
+				`"attributedTo":"%s","context":"%s","summary":"Hijacked","content":"Hijacked",`+
+				`"attachment":{"type":"Offer","origin":{"type":"Branch","context":"%s","ref":"refs/heads/feature"},`+
+				`"target":{"type":"Branch","context":"%s","ref":"refs/heads/master"}}}}`,
+				remoteActor, repoActor, otherID, remoteActor, repoActor, remoteRepo, repoActor)
+			resp, err = c.Post([]byte(offer), repoActor+"/inbox")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+			unittest.AssertNotExistsBean(t, &issues_model.FederatedIssue{ObjectURI: otherID})
+		})
+
+		t.Run("ForkWithInboxOnAnotherHost", func(t *testing.T) {
+			token := getUserToken(t, user2.Name, auth_model.AccessTokenScopeWriteRepository)
+
+			name := "federated-repo3"
+			req := NewRequestWithJSON(t, "POST", "/api/v1/repos/federated-fork", &api.CreateFederatedForkOption{
+				Repository: federatedSrv.URL + "/api/v1/activitypub/repository-id/3",
+				Name:       &name,
+			}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusUnprocessableEntity)
+			unittest.AssertNotExistsBean(t, &repo_model.Repository{OwnerID: user2.ID, LowerName: name})
+		})
+
+		t.Run("ForkAndOffer", func(t *testing.T) {
+			token := getUserToken(t, user2.Name, auth_model.AccessTokenScopeWriteRepository)
+
+			name := "federated-repo1"
+			req := NewRequestWithJSON(t, "POST", "/api/v1/repos/federated-fork", &api.CreateFederatedForkOption{
+				Repository: remoteRepo,
+				Name:       &name,
+			}).AddTokenAuth(token)
+			resp := MakeRequest(t, req, http.StatusAccepted)
+			var apiRepo api.Repository
+			DecodeJSON(t, resp, &apiRepo)
+			assert.Equal(t, "A federated repository", apiRepo.Description)
+			assert.False(t, apiRepo.Fork)
+
+			fork := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: apiRepo.ID})
+			unittest.AssertExistsAndLoadBean(t, &repo_model.FederatedFork{RepoID: fork.ID, URI: remoteRepo})
+
+			// the fork is announced as such
+			req = NewRequest(t, "GET", fmt.Sprintf("/api/v1/activitypub/repository-id/%d", fork.ID))
+			resp = MakeRequest(t, req, http.StatusOK)
+			assert.Contains(t, resp.Body.String(), remoteRepo)
+
+			req = NewRequestWithJSON(t, "POST", fmt.Sprintf("/api/v1/repos/%s/federated-pulls", fork.FullName()), &api.CreateFederatedPullRequestOption{
+				Head:  "unknown",
+				Base:  "master",
+				Title: "Add a feature",
+			}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusNotFound)
+
+			req = NewRequestWithJSON(t, "POST", fmt.Sprintf("/api/v1/repos/%s/federated-pulls", repo.FullName()), &api.CreateFederatedPullRequestOption{
+				Head:  "master",
+				Base:  "master",
+				Title: "Not a federated fork",
+			}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusUnprocessableEntity)
+
+			req = NewRequestWithJSON(t, "POST", fmt.Sprintf("/api/v1/repos/%s/federated-pulls", fork.FullName()), &api.CreateFederatedPullRequestOption{
+				Head:  "feature",
+				Base:  "master",
+				Title: "Add a feature",
+				Body:  "Please merge",
+			}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusAccepted)
+
+			assert.Eventually(t, func() bool {
+				return len(receivedOffers()) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+
+			offer := receivedOffers()[0]
+			assert.Equal(t, user2.APActorID(), offer.Actor.GetLink().String())
+			mr := offer.MergeRequest()
+			require.NotNil(t, mr)
+			assert.Equal(t, "Add a feature", mr.Title())
+			assert.Equal(t, "feature", mr.Origin.BranchName())
+			assert.Equal(t, fork.APActorID(), mr.Origin.Context.GetLink().String())
+			assert.Equal(t, "master", mr.Target.BranchName())
+			assert.Equal(t, remoteRepo, mr.Context.GetLink().String())
+		})
+	})
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/forgefed"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/routers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityPubFederationModeration(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
+	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
+
+	var publicKeyPem string
+
+	federatedRoutes := http.NewServeMux()
+	federatedRoutes.HandleFunc("/.well-known/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(res, `{"links":[{"href":"http://%s/api/v1/nodeinfo","rel":"http://nodeinfo.diaspora.software/ns/schema/2.1"}]}`, req.Host)
+		})
+	federatedRoutes.HandleFunc("/api/v1/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(res, `{"version":"2.1","software":{"name":"forgejo","version":"1.20.0+dev-3183-g976d79044",`+
+				`"repository":"https://codeberg.org/forgejo/forgejo.git","homepage":"https://forgejo.org/"},`+
+				`"protocols":["activitypub"],"services":{"inbound":[],"outbound":["rss2.0"]},`+
+				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15", fakeFederatedPerson(t, "spammer", &publicKeyPem))
+	federatedRoutes.HandleFunc("/",
+		func(res http.ResponseWriter, req *http.Request) {
+			t.Errorf("Unhandled request: %q", req.URL.EscapedPath())
+		})
+	federatedSrv := httptest.NewServer(federatedRoutes)
+	defer federatedSrv.Close()
+
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+		repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1})
+
+		var err error
+		publicKeyPem, err = activitypub.GetPublicKey(db.DefaultContext, user1)
+		require.NoError(t, err)
+
+		remoteActor := federatedSrv.URL + "/api/v1/activitypub/user-id/15"
+		cf, err := activitypub.GetClientFactory(db.DefaultContext)
+		require.NoError(t, err)
+		c, err := cf.WithKeys(db.DefaultContext, user1, remoteActor+"#main-key")
+		require.NoError(t, err)
+
+		repoActor := u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%d", repo.ID)).String()
+		inboxURL := repoActor + "/inbox"
+
+		createTicket := func(t *testing.T, number int) int {
+			t.Helper()
+			create := fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Ticket","id":"%s/tickets/%d","attributedTo":"%s",`+
+				`"context":"%s","summary":"Buy now","content":"Cheap"}}`, remoteActor, remoteActor, number, remoteActor, repoActor)
+			resp, err := c.Post([]byte(create), inboxURL)
+			require.NoError(t, err)
+			return resp.StatusCode
+		}
+		like := func(t *testing.T) int {
+			t.Helper()
+			activity := fmt.Sprintf(`{"type":"Like","startTime":"%s","actor":"%s","object":"%s"}`,
+				time.Now().Format(time.RFC3339), remoteActor, repoActor)
+			resp, err := c.Post([]byte(activity), inboxURL)
+			require.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		session := loginUser(t, user1.Name)
+		setPolicy := func(t *testing.T, host, policy string) {
+			t.Helper()
+			req := NewRequestWithValues(t, "POST", "/admin/federation/policy", map[string]string{
+				"_csrf":  GetCSRF(t, session, "/admin/federation"),
+				"host":   host,
+				"policy": policy,
+			})
+			session.MakeRequest(t, req, http.StatusSeeOther)
+		}
+
+		assert.Equal(t, http.StatusNoContent, createTicket(t, 1))
+		federationHost := unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{HostFqdn: "127.0.0.1"})
+
+		t.Run("UnknownHost", func(t *testing.T) {
+			setPolicy(t, "https://Blocked.Example.com/some/path", "block")
+			host := unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{HostFqdn: "blocked.example.com"})
+			assert.Equal(t, forgefed.FederationHostPolicyBlock, host.Policy)
+
+			resp := session.MakeRequest(t, NewRequest(t, "GET", "/admin/federation"), http.StatusOK)
+			assert.Contains(t, resp.Body.String(), "blocked.example.com")
+
+			// the host only existed for its policy
+			setPolicy(t, "blocked.example.com", "none")
+			unittest.AssertNotExistsBean(t, &forgefed.FederationHost{HostFqdn: "blocked.example.com"})
+		})
+
+		t.Run("Silence", func(t *testing.T) {
+			setPolicy(t, federatedSrv.URL, "silence")
+			assert.Equal(t, forgefed.FederationHostPolicySilence,
+				unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{ID: federationHost.ID}).Policy)
+
+			assert.Equal(t, http.StatusForbidden, createTicket(t, 2))
+			unittest.AssertNotExistsBean(t, &issues_model.FederatedIssue{ObjectURI: remoteActor + "/tickets/2"})
+			assert.Equal(t, http.StatusNoContent, like(t))
+		})
+
+		t.Run("AllowlistMode", func(t *testing.T) {
+			defer test.MockVariableValue(&setting.Federation.AllowlistMode, true)()
+
+			setPolicy(t, "127.0.0.1", "none")
+			assert.Equal(t, http.StatusForbidden, like(t))
+
+			setPolicy(t, "127.0.0.1", "allow")
+			assert.Equal(t, http.StatusNoContent, createTicket(t, 3))
+		})
+
+		t.Run("BlockAndPurge", func(t *testing.T) {
+			federatedUser := unittest.AssertExistsAndLoadBean(t, &user_model.FederatedUser{ExternalID: "15", FederationHostID: federationHost.ID})
+			ticket := unittest.AssertExistsAndLoadBean(t, &issues_model.FederatedIssue{ObjectURI: remoteActor + "/tickets/1"})
+
+			// only blocked hosts are purged
+			req := NewRequestWithValues(t, "POST", fmt.Sprintf("/admin/federation/%d/purge", federationHost.ID), map[string]string{
+				"_csrf": GetCSRF(t, session, "/admin/federation"),
+			})
+			session.MakeRequest(t, req, http.StatusSeeOther)
+			unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: federatedUser.UserID})
+
+			setPolicy(t, "127.0.0.1", "block")
+			assert.Equal(t, http.StatusForbidden, createTicket(t, 4))
+			assert.Equal(t, http.StatusForbidden, like(t))
+
+			req = NewRequestWithValues(t, "POST", fmt.Sprintf("/admin/federation/%d/purge", federationHost.ID), map[string]string{
+				"_csrf": GetCSRF(t, session, "/admin/federation"),
+			})
+			session.MakeRequest(t, req, http.StatusSeeOther)
+
+			unittest.AssertNotExistsBean(t, &user_model.User{ID: federatedUser.UserID})
+			unittest.AssertNotExistsBean(t, &user_model.FederatedUser{ID: federatedUser.ID})
+			unittest.AssertNotExistsBean(t, &issues_model.Issue{ID: ticket.IssueID})
+			unittest.AssertNotExistsBean(t, &issues_model.FederatedIssue{ID: ticket.ID})
+			// the host stays blocked
+			assert.Equal(t, forgefed.FederationHostPolicyBlock,
+				unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{ID: federationHost.ID}).Policy)
+		})
+	})
+}
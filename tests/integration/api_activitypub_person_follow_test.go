@@ -0,0 +1,230 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/forgefed"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	"code.gitea.io/gitea/modules/json"
+	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/routers"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityPubPersonFollow(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
+	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
+
+	// the remote person signs with the keys of user1, its public key is set once the instance runs
+	var publicKeyPem string
+	var mu sync.Mutex
+	var received []ap.Activity
+
+	federatedRoutes := http.NewServeMux()
+	federatedRoutes.HandleFunc("/.well-known/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(res, `{"links":[{"href":"http://%s/api/v1/nodeinfo","rel":"http://nodeinfo.diaspora.software/ns/schema/2.1"}]}`, req.Host)
+		})
+	federatedRoutes.HandleFunc("/api/v1/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(res, `{"version":"2.1","software":{"name":"forgejo","version":"1.20.0+dev-3183-g976d79044",`+
+				`"repository":"https://codeberg.org/forgejo/forgejo.git","homepage":"https://forgejo.org/"},`+
+				`"protocols":["activitypub"],"services":{"inbound":[],"outbound":["rss2.0"]},`+
+				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15",
+		func(res http.ResponseWriter, req *http.Request) {
+			person := ap.PersonNew(ap.IRI(fmt.Sprintf("http://%s/api/v1/activitypub/user-id/15", req.Host)))
+			person.PreferredUsername = ap.DefaultNaturalLanguageValue("follower")
+			person.Inbox = ap.IRI(person.ID.String() + "/inbox")
+			person.PublicKey.ID = ap.IRI(person.ID.String() + "#main-key")
+			person.PublicKey.Owner = person.ID
+			person.PublicKey.PublicKeyPem = publicKeyPem
+			body, err := person.MarshalJSON()
+			require.NoError(t, err)
+			res.Write(body)
+		})
+	// a person advertising an inbox on another host
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/16",
+		func(res http.ResponseWriter, req *http.Request) {
+			person := ap.PersonNew(ap.IRI(fmt.Sprintf("http://%s/api/v1/activitypub/user-id/16", req.Host)))
+			person.PreferredUsername = ap.DefaultNaturalLanguageValue("redirected")
+			person.Inbox = ap.IRI("http://169.254.169.254/latest/meta-data")
+			person.PublicKey.ID = ap.IRI(person.ID.String() + "#main-key")
+			person.PublicKey.Owner = person.ID
+			person.PublicKey.PublicKeyPem = publicKeyPem
+			body, err := person.MarshalJSON()
+			require.NoError(t, err)
+			res.Write(body)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15/inbox",
+		func(res http.ResponseWriter, req *http.Request) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			activity := ap.Activity{}
+			require.NoError(t, activity.UnmarshalJSON(body))
+
+			mu.Lock()
+			received = append(received, activity)
+			mu.Unlock()
+			res.WriteHeader(http.StatusAccepted)
+		})
+	federatedRoutes.HandleFunc("/",
+		func(res http.ResponseWriter, req *http.Request) {
+			t.Errorf("Unhandled request: %q", req.URL.EscapedPath())
+		})
+	federatedSrv := httptest.NewServer(federatedRoutes)
+	defer federatedSrv.Close()
+
+	receivedOfType := func(typ ap.ActivityVocabularyType) []ap.Activity {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var result []ap.Activity
+		for _, activity := range received {
+			if activity.Type == typ {
+				result = append(result, activity)
+			}
+		}
+		return result
+	}
+
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+		user2 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+		var err error
+		publicKeyPem, err = activitypub.GetPublicKey(db.DefaultContext, user1)
+		require.NoError(t, err)
+
+		remoteActor := federatedSrv.URL + "/api/v1/activitypub/user-id/15"
+		cf, err := activitypub.GetClientFactory(db.DefaultContext)
+		require.NoError(t, err)
+		c, err := cf.WithKeys(db.DefaultContext, user1, remoteActor+"#main-key")
+		require.NoError(t, err)
+
+		localActor := u.JoinPath("/api/v1/activitypub/user-id/2").String()
+		inboxURL := localActor + "/inbox"
+		follow := fmt.Sprintf(`{"id":"%s/follows/1","type":"Follow","actor":"%s","object":"%s"}`, remoteActor, remoteActor, localActor)
+
+		t.Run("FollowOfAnotherActor", func(t *testing.T) {
+			other := strings.Replace(follow, `"actor":"`+remoteActor, `"actor":"`+u.JoinPath("/api/v1/activitypub/user-id/1").String(), 1)
+			resp, err := c.Post([]byte(other), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+
+		t.Run("FollowWithInboxOnAnotherHost", func(t *testing.T) {
+			otherActor := federatedSrv.URL + "/api/v1/activitypub/user-id/16"
+			c, err := cf.WithKeys(db.DefaultContext, user1, otherActor+"#main-key")
+			require.NoError(t, err)
+
+			other := fmt.Sprintf(`{"id":"%s/follows/1","type":"Follow","actor":"%s","object":"%s"}`, otherActor, otherActor, localActor)
+			resp, err := c.Post([]byte(other), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+			unittest.AssertNotExistsBean(t, &user_model.FederatedUser{ExternalID: "16"})
+		})
+
+		t.Run("Follow", func(t *testing.T) {
+			resp, err := c.Post([]byte(follow), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			federationHost := unittest.AssertExistsAndLoadBean(t, &forgefed.FederationHost{HostFqdn: "127.0.0.1"})
+			federatedUser := unittest.AssertExistsAndLoadBean(t, &user_model.FederatedUser{ExternalID: "15", FederationHostID: federationHost.ID})
+			assert.Equal(t, remoteActor+"/inbox", federatedUser.InboxURI)
+			unittest.AssertExistsAndLoadBean(t, &user_model.Follow{UserID: federatedUser.UserID, FollowID: user2.ID})
+
+			assert.Eventually(t, func() bool {
+				return len(receivedOfType(ap.AcceptType)) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+			accepts := receivedOfType(ap.AcceptType)
+			assert.Equal(t, localActor, accepts[0].Actor.GetLink().String())
+			assert.Equal(t, remoteActor+"/follows/1", accepts[0].Object.GetLink().String())
+		})
+
+		t.Run("Deliver", func(t *testing.T) {
+			token := getUserToken(t, user2.Name, auth_model.AccessTokenScopeWriteRepository)
+			req := NewRequestWithJSON(t, "POST", "/api/v1/user/repos", &api.CreateRepoOption{Name: "federated-repo"}).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			assert.Eventually(t, func() bool {
+				return len(receivedOfType(ap.CreateType)) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+
+			create := receivedOfType(ap.CreateType)[0]
+			assert.Equal(t, localActor, create.Actor.GetLink().String())
+			assert.True(t, strings.HasPrefix(create.ID.String(), localActor+"/outbox/"))
+
+			req = NewRequest(t, "GET", "/"+strings.TrimPrefix(create.ID.String(), setting.AppURL))
+			resp := MakeRequest(t, req, http.StatusOK)
+			assert.Contains(t, resp.Body.String(), "federated-repo")
+		})
+
+		t.Run("Outbox", func(t *testing.T) {
+			req := NewRequest(t, "GET", "/api/v1/activitypub/user-id/2/outbox")
+			resp := MakeRequest(t, req, http.StatusOK)
+
+			var outbox map[string]any
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &outbox))
+			assert.Equal(t, "OrderedCollection", outbox["type"])
+			assert.Equal(t, localActor+"/outbox?page=1", outbox["first"])
+			assert.Positive(t, outbox["totalItems"])
+
+			req = NewRequest(t, "GET", "/api/v1/activitypub/user-id/2/outbox?page=1")
+			resp = MakeRequest(t, req, http.StatusOK)
+
+			page := ap.OrderedCollectionPage{}
+			require.NoError(t, page.UnmarshalJSON(resp.Body.Bytes()))
+			assert.Equal(t, ap.OrderedCollectionPageType, page.Type)
+			require.NotEmpty(t, page.OrderedItems)
+
+			create, err := ap.ToActivity(page.OrderedItems[0])
+			require.NoError(t, err)
+			assert.Equal(t, ap.CreateType, create.Type)
+			assert.Equal(t, receivedOfType(ap.CreateType)[0].ID, create.ID)
+
+			note, err := ap.ToObject(create.Object)
+			require.NoError(t, err)
+			assert.Equal(t, ap.NoteType, note.Type)
+			assert.Contains(t, note.Content.String(), "created repository")
+
+			// unknown activities
+			req = NewRequest(t, "GET", "/api/v1/activitypub/user-id/2/outbox/999999")
+			MakeRequest(t, req, http.StatusNotFound)
+		})
+
+		t.Run("Undo", func(t *testing.T) {
+			undo := fmt.Sprintf(`{"type":"Undo","actor":"%s","object":%s}`, remoteActor, follow)
+			resp, err := c.Post([]byte(undo), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			federatedUser := unittest.AssertExistsAndLoadBean(t, &user_model.FederatedUser{ExternalID: "15"})
+			unittest.AssertNotExistsBean(t, &user_model.Follow{UserID: federatedUser.UserID, FollowID: user2.ID})
+		})
+	})
+}
@@ -25,6 +25,7 @@ import (
 
 func TestActivityPubPerson(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 	defer tests.PrepareTestEnv(t)()
 
@@ -57,6 +58,7 @@ func TestActivityPubPerson(t *testing.T) {
 
 func TestActivityPubMissingPerson(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 	defer tests.PrepareTestEnv(t)()
 
@@ -67,6 +69,7 @@ func TestActivityPubMissingPerson(t *testing.T) {
 
 func TestActivityPubPersonInbox(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 
 	onGiteaRun(t, func(t *testing.T, u *url.URL) {
@@ -80,8 +83,9 @@ func TestActivityPubPersonInbox(t *testing.T) {
 		require.NoError(t, err)
 		user2inboxurl := u.JoinPath("/api/v1/activitypub/user-id/2/inbox").String()
 
-		// Signed request succeeds
-		resp, err := c.Post([]byte{}, user2inboxurl)
+		// Signed request succeeds, unsupported activities are ignored
+		activity := []byte(fmt.Sprintf(`{"type":"Like","actor":"%s"}`, u.JoinPath("/api/v1/activitypub/user-id/1").String()))
+		resp, err := c.Post(activity, user2inboxurl)
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
 
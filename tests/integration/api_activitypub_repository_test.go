@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,12 +23,14 @@ import (
 	"code.gitea.io/gitea/routers"
 	"code.gitea.io/gitea/tests"
 
+	ap "github.com/go-ap/activitypub"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestActivityPubRepository(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 	defer tests.PrepareTestEnv(t)()
 
@@ -45,6 +48,7 @@ func TestActivityPubRepository(t *testing.T) {
 
 func TestActivityPubMissingRepository(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 	defer tests.PrepareTestEnv(t)()
 
@@ -56,6 +60,7 @@ func TestActivityPubMissingRepository(t *testing.T) {
 
 func TestActivityPubRepositoryInboxValid(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 
 	federatedRoutes := http.NewServeMux()
@@ -73,36 +78,10 @@ func TestActivityPubRepositoryInboxValid(t *testing.T) {
 				`"protocols":["activitypub"],"services":{"inbound":[],"outbound":["rss2.0"]},`+
 				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
 		})
-	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15",
-		func(res http.ResponseWriter, req *http.Request) {
-			// curl -H "Accept: application/json" https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/2
-			fmt.Fprint(res, `{"@context":["https://www.w3.org/ns/activitystreams","https://w3id.org/security/v1"],`+
-				`"id":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/15","type":"Person",`+
-				`"icon":{"type":"Image","mediaType":"image/png","url":"https://federated-repo.prod.meissa.de/avatars/1bb05d9a5f6675ed0272af9ea193063c"},`+
-				`"url":"https://federated-repo.prod.meissa.de/stargoose1","inbox":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/15/inbox",`+
-				`"outbox":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/15/outbox","preferredUsername":"stargoose1",`+
-				`"publicKey":{"id":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/15#main-key","owner":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/15",`+
-				`"publicKeyPem":"-----BEGIN PUBLIC KEY-----\nMIIBojANBgkqhkiG9w0BAQEFAAOCAY8AMIIBigKCAYEA18H5s7N6ItZUAh9tneII\nIuZdTTa3cZlLa/9ejWAHTkcp3WLW+/zbsumlMrWYfBy2/yTm56qasWt38iY4D6ul\n`+
-				`CPiwhAqX3REvVq8tM79a2CEqZn9ka6vuXoDgBg/sBf/BUWqf7orkjUXwk/U0Egjf\nk5jcurF4vqf1u+rlAHH37dvSBaDjNj6Qnj4OP12bjfaY/yvs7+jue/eNXFHjzN4E\n`+
-				`T2H4B/yeKTJ4UuAwTlLaNbZJul2baLlHelJPAsxiYaziVuV5P+IGWckY6RSerRaZ\nAkc4mmGGtjAyfN9aewe+lNVfwS7ElFx546PlLgdQgjmeSwLX8FWxbPE5A/PmaXCs\n`+
-				`nx+nou+3dD7NluULLtdd7K+2x02trObKXCAzmi5/Dc+yKTzpFqEz+hLNCz7TImP/\ncK//NV9Q+X67J9O27baH9R9ZF4zMw8rv2Pg0WLSw1z7lLXwlgIsDapeMCsrxkVO4\n`+
-				`LXX5AQ1xQNtlssnVoUBqBrvZsX2jUUKUocvZqMGuE4hfAgMBAAE=\n-----END PUBLIC KEY-----\n"}}`)
-		})
-	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/30",
-		func(res http.ResponseWriter, req *http.Request) {
-			// curl -H "Accept: application/json" https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/3
-			fmt.Fprint(res, `{"@context":["https://www.w3.org/ns/activitystreams","https://w3id.org/security/v1"],`+
-				`"id":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/30","type":"Person",`+
-				`"icon":{"type":"Image","mediaType":"image/png","url":"https://federated-repo.prod.meissa.de/avatars/9c03f03d1c1f13f21976a22489326fe1"},`+
-				`"url":"https://federated-repo.prod.meissa.de/stargoose2","inbox":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/30/inbox",`+
-				`"outbox":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/30/outbox","preferredUsername":"stargoose2",`+
-				`"publicKey":{"id":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/30#main-key","owner":"https://federated-repo.prod.meissa.de/api/v1/activitypub/user-id/30",`+
-				`"publicKeyPem":"-----BEGIN PUBLIC KEY-----\nMIIBojANBgkqhkiG9w0BAQEFAAOCAY8AMIIBigKCAYEAyv5NytsfqpWXSrwuk8a3\n0W1zE13QJioXb/e3opgN2CfKZkdm3hb+4+mGKoU/rCqegnL9/AO0Aw+R8fCHXx44\n`+
-				`iNkdVpdY8Dzq+tQ9IetPWbyVIBvSzGgvpqfS05JuVPsy8cBX9wByODjr5kq7k1/v\nY1G7E3uh0a/XJc+mZutwGC3gPgR93NSrqsvTPN4wdhCCu9uj02S8OBoKuSYaPkU+\n`+
-				`tZ4CEDpnclAOw/eNiH4x2irMvVtruEgtlTA5K2I4YJrmtGLidus47FCyc8/zEKUh\nAeiD8KWDvqsQgOhUwcQgRxAnYVCoMD9cnE+WFFRHTuQecNlmdNFs3Cr0yKcWjDde\n`+
-				`trvnehW7LfPveGb0tHRHPuVAJpncTOidUR5h/7pqMyvKHzuAHWomm9rEaGUxd/7a\nL1CFjAf39+QIEgu0Anj8mIc7CTiz+DQhDz+0jBOsQ0iDXc5GeBz7X9Xv4Jp966nq\n`+
-				`MUR0GQGXvfZQN9IqMO+WoUVy10Ddhns1EWGlA0x4fecnAgMBAAE=\n-----END PUBLIC KEY-----\n"}}`)
-		})
+	// the remote persons sign with the keys of user1, its public key is set once the instance runs
+	var publicKeyPem string
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15", fakeFederatedPerson(t, "stargoose1", &publicKeyPem))
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/30", fakeFederatedPerson(t, "stargoose2", &publicKeyPem))
 	federatedRoutes.HandleFunc("/",
 		func(res http.ResponseWriter, req *http.Request) {
 			t.Errorf("Unhandled request: %q", req.URL.EscapedPath())
@@ -111,13 +90,19 @@ func TestActivityPubRepositoryInboxValid(t *testing.T) {
 	defer federatedSrv.Close()
 
 	onGiteaRun(t, func(t *testing.T, u *url.URL) {
-		actionsUser := user.NewActionsUser()
+		user1 := unittest.AssertExistsAndLoadBean(t, &user.User{ID: 1})
 		repositoryID := 2
 		timeNow := time.Now().UTC()
 
+		var err error
+		publicKeyPem, err = activitypub.GetPublicKey(db.DefaultContext, user1)
+		require.NoError(t, err)
+
 		cf, err := activitypub.GetClientFactory(db.DefaultContext)
 		require.NoError(t, err)
-		c, err := cf.WithKeys(db.DefaultContext, actionsUser, "not used")
+		c, err := cf.WithKeys(db.DefaultContext, user1, federatedSrv.URL+"/api/v1/activitypub/user-id/15#main-key")
+		require.NoError(t, err)
+		c30, err := cf.WithKeys(db.DefaultContext, user1, federatedSrv.URL+"/api/v1/activitypub/user-id/30#main-key")
 		require.NoError(t, err)
 		repoInboxURL := u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%d/inbox", repositoryID)).String()
 
@@ -148,7 +133,7 @@ func TestActivityPubRepositoryInboxValid(t *testing.T) {
 			timeNow.Add(time.Second).Format(time.RFC3339),
 			federatedSrv.URL, u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%d", repositoryID)).String()))
 		t.Logf("activity: %s", activity2)
-		resp, err = c.Post(activity2, repoInboxURL)
+		resp, err = c30.Post(activity2, repoInboxURL)
 
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
@@ -168,7 +153,7 @@ func TestActivityPubRepositoryInboxValid(t *testing.T) {
 			timeNow.Add(time.Second*2).Format(time.RFC3339),
 			federatedSrv.URL, u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%d", otherRepositoryID)).String()))
 		t.Logf("activity: %s", activity3)
-		resp, err = c.Post(activity3, otherRepoInboxURL)
+		resp, err = c30.Post(activity3, otherRepoInboxURL)
 
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
@@ -177,7 +162,7 @@ func TestActivityPubRepositoryInboxValid(t *testing.T) {
 		unittest.AssertExistsAndLoadBean(t, &user.User{ID: federatedUser.UserID})
 
 		// Replay activity2.
-		resp, err = c.Post(activity2, repoInboxURL)
+		resp, err = c30.Post(activity2, repoInboxURL)
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
 	})
@@ -185,14 +170,15 @@ func TestActivityPubRepositoryInboxValid(t *testing.T) {
 
 func TestActivityPubRepositoryInboxInvalid(t *testing.T) {
 	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
 
 	onGiteaRun(t, func(t *testing.T, u *url.URL) {
-		actionsUser := user.NewActionsUser()
+		user1 := unittest.AssertExistsAndLoadBean(t, &user.User{ID: 1})
 		repositoryID := 2
 		cf, err := activitypub.GetClientFactory(db.DefaultContext)
 		require.NoError(t, err)
-		c, err := cf.WithKeys(db.DefaultContext, actionsUser, "not used")
+		c, err := cf.WithKeys(db.DefaultContext, user1, u.JoinPath("/api/v1/activitypub/user-id/1").String()+"#main-key")
 		require.NoError(t, err)
 
 		repoInboxURL := u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%v/inbox", repositoryID)).String()
@@ -202,3 +188,28 @@ func TestActivityPubRepositoryInboxInvalid(t *testing.T) {
 		assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
 	})
 }
+
+func TestActivityPubRepositoryInboxUnsigned(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
+	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
+	defer tests.PrepareTestEnv(t)()
+
+	req := NewRequestWithBody(t, "POST", "/api/v1/activitypub/repository-id/2/inbox", strings.NewReader(`{"type":"Like"}`))
+	MakeRequest(t, req, http.StatusBadRequest)
+}
+
+// fakeFederatedPerson serves a remote person using the given public key, its id is derived from the requested host
+func fakeFederatedPerson(t *testing.T, name string, publicKeyPem *string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		person := ap.PersonNew(ap.IRI(fmt.Sprintf("http://%s%s", req.Host, req.URL.Path)))
+		person.PreferredUsername = ap.DefaultNaturalLanguageValue(name)
+		person.Inbox = ap.IRI(person.ID.String() + "/inbox")
+		person.PublicKey.ID = ap.IRI(person.ID.String() + "#main-key")
+		person.PublicKey.Owner = person.ID
+		person.PublicKey.PublicKeyPem = *publicKeyPem
+		body, err := person.MarshalJSON()
+		require.NoError(t, err)
+		res.Write(body)
+	}
+}
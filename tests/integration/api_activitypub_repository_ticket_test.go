@@ -0,0 +1,221 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/activitypub"
+	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/routers"
+
+	ap "github.com/go-ap/activitypub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityPubRepositoryTicket(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.Enabled, true)()
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
+	defer test.MockVariableValue(&testWebRoutes, routers.NormalRoutes())()
+
+	var publicKeyPem string
+	var mu sync.Mutex
+	var received []ap.Activity
+
+	federatedRoutes := http.NewServeMux()
+	federatedRoutes.HandleFunc("/.well-known/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(res, `{"links":[{"href":"http://%s/api/v1/nodeinfo","rel":"http://nodeinfo.diaspora.software/ns/schema/2.1"}]}`, req.Host)
+		})
+	federatedRoutes.HandleFunc("/api/v1/nodeinfo",
+		func(res http.ResponseWriter, req *http.Request) {
+			fmt.Fprint(res, `{"version":"2.1","software":{"name":"forgejo","version":"1.20.0+dev-3183-g976d79044",`+
+				`"repository":"https://codeberg.org/forgejo/forgejo.git","homepage":"https://forgejo.org/"},`+
+				`"protocols":["activitypub"],"services":{"inbound":[],"outbound":["rss2.0"]},`+
+				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
+		})
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15", fakeFederatedPerson(t, "reporter", &publicKeyPem))
+	federatedRoutes.HandleFunc("/api/v1/activitypub/user-id/15/inbox",
+		func(res http.ResponseWriter, req *http.Request) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			activity := ap.Activity{}
+			require.NoError(t, activity.UnmarshalJSON(body))
+
+			mu.Lock()
+			received = append(received, activity)
+			mu.Unlock()
+			res.WriteHeader(http.StatusAccepted)
+		})
+	federatedRoutes.HandleFunc("/",
+		func(res http.ResponseWriter, req *http.Request) {
+			t.Errorf("Unhandled request: %q", req.URL.EscapedPath())
+		})
+	federatedSrv := httptest.NewServer(federatedRoutes)
+	defer federatedSrv.Close()
+
+	receivedOfType := func(typ ap.ActivityVocabularyType) []ap.Activity {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var result []ap.Activity
+		for _, activity := range received {
+			if activity.Type == typ {
+				result = append(result, activity)
+			}
+		}
+		return result
+	}
+
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+		user2 := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+		repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1, OwnerID: user2.ID})
+
+		var err error
+		publicKeyPem, err = activitypub.GetPublicKey(db.DefaultContext, user1)
+		require.NoError(t, err)
+
+		remoteActor := federatedSrv.URL + "/api/v1/activitypub/user-id/15"
+		cf, err := activitypub.GetClientFactory(db.DefaultContext)
+		require.NoError(t, err)
+		c, err := cf.WithKeys(db.DefaultContext, user1, remoteActor+"#main-key")
+		require.NoError(t, err)
+
+		repoActor := u.JoinPath(fmt.Sprintf("/api/v1/activitypub/repository-id/%d", repo.ID)).String()
+		inboxURL := repoActor + "/inbox"
+		ticketID := remoteActor + "/tickets/1"
+
+		var issue *issues_model.Issue
+
+		t.Run("CreateTicket", func(t *testing.T) {
+			create := fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Ticket","id":"%s","attributedTo":"%s",`+
+				`"context":"%s","summary":"Federated bug","content":"<p>It is <em>broken</em></p>",`+
+				`"source":{"mediaType":"text/markdown","content":"It is *broken*"}}}`, remoteActor, ticketID, remoteActor, repoActor)
+
+			// delivered twice, the issue is only created once
+			for i := 0; i < 2; i++ {
+				resp, err := c.Post([]byte(create), inboxURL)
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			}
+
+			federatedIssue := unittest.AssertExistsAndLoadBean(t, &issues_model.FederatedIssue{ObjectURI: ticketID})
+			issue = unittest.AssertExistsAndLoadBean(t, &issues_model.Issue{ID: federatedIssue.IssueID, RepoID: repo.ID})
+			assert.Equal(t, "Federated bug", issue.Title)
+			assert.Equal(t, "It is *broken*", issue.Content)
+
+			federatedUser := unittest.AssertExistsAndLoadBean(t, &user_model.FederatedUser{ExternalID: "15"})
+			assert.Equal(t, federatedUser.UserID, issue.PosterID)
+		})
+
+		t.Run("GetTicket", func(t *testing.T) {
+			req := NewRequest(t, "GET", fmt.Sprintf("/api/v1/activitypub/repository-id/%d/issues/%d", repo.ID, issue.Index))
+			resp := MakeRequest(t, req, http.StatusOK)
+
+			ticket := ap.Object{}
+			require.NoError(t, ticket.UnmarshalJSON(resp.Body.Bytes()))
+			assert.EqualValues(t, "Ticket", ticket.Type)
+			assert.Equal(t, remoteActor, ticket.AttributedTo.GetLink().String())
+			assert.Equal(t, "Federated bug", ticket.Summary.String())
+
+			req = NewRequest(t, "GET", fmt.Sprintf("/api/v1/activitypub/repository-id/%d/issues/999999", repo.ID))
+			MakeRequest(t, req, http.StatusNotFound)
+		})
+
+		t.Run("CreateNote", func(t *testing.T) {
+			noteID := remoteActor + "/notes/1"
+			create := fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Note","id":"%s","attributedTo":"%s",`+
+				`"inReplyTo":"%s","content":"Still broken"}}`, remoteActor, noteID, remoteActor, ticketID)
+			resp, err := c.Post([]byte(create), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+			federatedComment := unittest.AssertExistsAndLoadBean(t, &issues_model.FederatedIssue{ObjectURI: noteID, IssueID: issue.ID})
+			comment := unittest.AssertExistsAndLoadBean(t, &issues_model.Comment{ID: federatedComment.CommentID, IssueID: issue.ID})
+			assert.Equal(t, "Still broken", comment.Content)
+			assert.Equal(t, issue.PosterID, comment.PosterID)
+
+			// notes on unknown tickets
+			create = fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Note","id":"%s/notes/2","attributedTo":"%s",`+
+				`"inReplyTo":"%s/tickets/999","content":"Lost"}}`, remoteActor, remoteActor, remoteActor, remoteActor)
+			resp, err = c.Post([]byte(create), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+
+		t.Run("AttributedToAnotherActor", func(t *testing.T) {
+			other := u.JoinPath("/api/v1/activitypub/user-id/1").String()
+			create := fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Note","id":"%s/notes/3","attributedTo":"%s",`+
+				`"inReplyTo":"%s","content":"Not mine"}}`, remoteActor, remoteActor, other, ticketID)
+			resp, err := c.Post([]byte(create), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+		})
+
+		t.Run("ObjectOnAnotherHost", func(t *testing.T) {
+			// the id of a ticket of another instance
+			otherTicketID := u.JoinPath("/api/v1/activitypub/repository-id/1/issues/1").String()
+			create := fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Ticket","id":"%s","attributedTo":"%s",`+
+				`"context":"%s","summary":"Hijacked","content":"Not mine"}}`, remoteActor, otherTicketID, remoteActor, repoActor)
+			resp, err := c.Post([]byte(create), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+			unittest.AssertNotExistsBean(t, &issues_model.FederatedIssue{ObjectURI: otherTicketID})
+
+			otherNoteID := u.JoinPath("/api/v1/activitypub/user-id/1/notes/1").String()
+			create = fmt.Sprintf(`{"type":"Create","actor":"%s","object":{"type":"Note","id":"%s","attributedTo":"%s",`+
+				`"inReplyTo":"%s","content":"Not mine"}}`, remoteActor, otherNoteID, remoteActor, ticketID)
+			resp, err = c.Post([]byte(create), inboxURL)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+			unittest.AssertNotExistsBean(t, &issues_model.FederatedIssue{ObjectURI: otherNoteID})
+		})
+
+		t.Run("NotifyAuthor", func(t *testing.T) {
+			token := getUserToken(t, user2.Name, auth_model.AccessTokenScopeWriteIssue)
+			req := NewRequestWithJSON(t, "POST", fmt.Sprintf("/api/v1/repos/%s/issues/%d/comments", repo.FullName(), issue.Index),
+				&api.CreateIssueCommentOption{Body: "Fixed it"}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			assert.Eventually(t, func() bool {
+				return len(receivedOfType(ap.CreateType)) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+
+			create := receivedOfType(ap.CreateType)[0]
+			assert.Equal(t, user2.APActorID(), create.Actor.GetLink().String())
+			note, err := ap.ToObject(create.Object)
+			require.NoError(t, err)
+			assert.Equal(t, ap.NoteType, note.Type)
+			assert.Equal(t, ticketID, note.InReplyTo.GetLink().String())
+			assert.Equal(t, "Fixed it", note.Source.Content.String())
+
+			closed := "closed"
+			req = NewRequestWithJSON(t, "PATCH", fmt.Sprintf("/api/v1/repos/%s/issues/%d", repo.FullName(), issue.Index),
+				&api.EditIssueOption{State: &closed}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			assert.Eventually(t, func() bool {
+				return len(receivedOfType("Resolve")) == 1
+			}, 10*time.Second, 100*time.Millisecond)
+			assert.Equal(t, ticketID, receivedOfType("Resolve")[0].Object.GetLink().String())
+		})
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	audit_model "code.gitea.io/gitea/models/audit"
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/perm"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	api "code.gitea.io/gitea/modules/structs"
+	auth_service "code.gitea.io/gitea/services/auth"
+	user_service "code.gitea.io/gitea/services/user"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIAuditEvents(t *testing.T) {
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		adminToken := getUserToken(t, "user1", auth_model.AccessTokenScopeReadAdmin, auth_model.AccessTokenScopeReadUser)
+		ownerCtx := NewAPITestContext(t, "user2", "repo1", auth_model.AccessTokenScopeWriteRepository, auth_model.AccessTokenScopeWriteOrganization)
+
+		listEvents := func(t *testing.T, link, token string) []*api.AuditEvent {
+			t.Helper()
+			req := NewRequest(t, "GET", link).AddTokenAuth(token)
+			resp := MakeRequest(t, req, http.StatusOK)
+			var events []*api.AuditEvent
+			DecodeJSON(t, resp, &events)
+			return events
+		}
+
+		t.Run("AccessToken", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			events := listEvents(t, "/api/v1/admin/audit-events?action=user_access_token_add&actor=user2", adminToken)
+			require.NotEmpty(t, events)
+			assert.Equal(t, "user2", events[0].ActorName)
+			assert.EqualValues(t, 2, events[0].OwnerID)
+			assert.EqualValues(t, audit_model.TargetAccessToken, events[0].TargetType)
+		})
+
+		t.Run("Collaborator", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			t.Run("AddCollaborator", doAPIAddCollaborator(ownerCtx, "user4", perm.AccessModeRead))
+
+			events := listEvents(t, "/api/v1/admin/audit-events?action=repository_collaborator_add", adminToken)
+			require.Len(t, events, 1)
+			assert.Equal(t, "user2", events[0].ActorName)
+			assert.EqualValues(t, 1, events[0].RepoID)
+			assert.EqualValues(t, audit_model.TargetUser, events[0].TargetType)
+			assert.EqualValues(t, 4, events[0].TargetID)
+			assert.Equal(t, "user4", events[0].TargetName)
+
+			events = listEvents(t, "/api/v1/admin/audit-events?target_type=user&target_id=4", adminToken)
+			require.Len(t, events, 1)
+			assert.Equal(t, "repository_collaborator_add", events[0].Action)
+		})
+
+		// the events are recorded by the services, actions taken from the command line or by the system are audited too
+		t.Run("WithoutDoer", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			apiCreateUser(t, "auditee")
+			auditee := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "auditee"})
+
+			token, err := auth_service.CreateAccessToken(db.DefaultContext, nil, auditee, &auth_service.AccessTokenOptions{
+				Name:  "cli",
+				Scope: auth_model.AccessTokenScopeReadUser,
+			})
+			require.NoError(t, err)
+
+			events := listEvents(t, fmt.Sprintf("/api/v1/admin/audit-events?target_type=access_token&target_id=%d", token.ID), adminToken)
+			require.Len(t, events, 1)
+			assert.Equal(t, "user_access_token_add", events[0].Action)
+			assert.Empty(t, events[0].ActorName)
+			assert.EqualValues(t, auditee.ID, events[0].OwnerID)
+
+			require.NoError(t, user_service.DeleteUser(db.DefaultContext, nil, auditee, false))
+
+			events = listEvents(t, fmt.Sprintf("/api/v1/admin/audit-events?action=user_delete&target_type=user&target_id=%d", auditee.ID), adminToken)
+			require.Len(t, events, 1)
+			assert.Empty(t, events[0].ActorName)
+			assert.Equal(t, "auditee", events[0].TargetName)
+		})
+
+		t.Run("Impersonation", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "GET", "/api/v1/user?sudo=user2").AddTokenAuth(adminToken)
+			MakeRequest(t, req, http.StatusOK)
+
+			events := listEvents(t, "/api/v1/admin/audit-events?action=user_impersonation", adminToken)
+			require.Len(t, events, 1)
+			assert.Equal(t, "user1", events[0].ActorName)
+			assert.Equal(t, "user2", events[0].TargetName)
+			assert.Contains(t, events[0].Message, "/api/v1/user")
+		})
+
+		t.Run("Organization", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequestWithJSON(t, "POST", "/api/v1/orgs/org3/teams", &api.CreateTeamOption{
+				Name:       "auditors",
+				Permission: "read",
+				Units:      []string{"repo.code"},
+			}).AddTokenAuth(ownerCtx.Token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			events := listEvents(t, "/api/v1/orgs/org3/audit-events", ownerCtx.Token)
+			require.Len(t, events, 1)
+			assert.Equal(t, "organization_team_add", events[0].Action)
+			assert.Equal(t, "auditors", events[0].TargetName)
+			assert.EqualValues(t, 3, events[0].OwnerID)
+
+			// only owners can read the audit log of an organization
+			memberToken := getUserToken(t, "user4", auth_model.AccessTokenScopeReadOrganization)
+			req = NewRequest(t, "GET", "/api/v1/orgs/org3/audit-events").AddTokenAuth(memberToken)
+			MakeRequest(t, req, http.StatusForbidden)
+		})
+
+		t.Run("Filters", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			since := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+			assert.Empty(t, listEvents(t, "/api/v1/admin/audit-events?since="+since, adminToken))
+
+			before := url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339))
+			assert.Empty(t, listEvents(t, "/api/v1/admin/audit-events?before="+before, adminToken))
+
+			req := NewRequest(t, "GET", "/api/v1/admin/audit-events?action=repository_launch").AddTokenAuth(adminToken)
+			MakeRequest(t, req, http.StatusUnprocessableEntity)
+
+			req = NewRequest(t, "GET", "/api/v1/admin/audit-events?actor=nobody").AddTokenAuth(adminToken)
+			MakeRequest(t, req, http.StatusUnprocessableEntity)
+		})
+
+		t.Run("AdminOnly", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			token := getUserToken(t, "user2", auth_model.AccessTokenScopeReadAdmin)
+			req := NewRequest(t, "GET", "/api/v1/admin/audit-events").AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusForbidden)
+		})
+	})
+}
@@ -0,0 +1,346 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/json"
+	attestation_module "code.gitea.io/gitea/modules/packages/attestation"
+	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageAttestation(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	token := getUserToken(t, user.Name, auth_model.AccessTokenScopeWritePackage)
+
+	packageName := "attested-package"
+	packageVersion := "1.0.0"
+	filename := "attested.bin"
+	content := []byte{1, 2, 3, 4}
+	contentSum := sha256.Sum256(content)
+
+	root := fmt.Sprintf("/api/v1/packages/%s/generic/%s/%s/attestations", user.Name, packageName, packageVersion)
+
+	req := NewRequestWithBody(t, "PUT", fmt.Sprintf("/api/packages/%s/generic/%s/%s/%s", user.Name, packageName, packageVersion, filename), bytes.NewReader(content)).
+		AddTokenAuth(token)
+	MakeRequest(t, req, http.StatusCreated)
+
+	statement := func(digest string) string {
+		return `{
+			"_type": "https://in-toto.io/Statement/v1",
+			"subject": [{"name": "` + filename + `", "digest": {"sha256": "` + digest + `"}}],
+			"predicateType": "https://slsa.dev/provenance/v1",
+			"predicate": {
+				"buildDefinition": {
+					"buildType": "https://example.com/workflow@v1",
+					"externalParameters": {"workflow": {"ref": "refs/tags/v1.0.0", "repository": "https://example.com/owner/repo", "path": ".forgejo/workflows/release.yml"}},
+					"resolvedDependencies": [{"uri": "git+https://example.com/owner/repo@refs/tags/v1.0.0", "digest": {"gitCommit": "0123456789abcdef0123456789abcdef01234567"}}]
+				},
+				"runDetails": {
+					"builder": {"id": "https://example.com/builder"},
+					"metadata": {"invocationId": "https://example.com/owner/repo/actions/runs/1"}
+				}
+			}
+		}`
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	san, _ := url.Parse("https://example.com/owner/repo/.forgejo/workflows/release.yml@refs/tags/v1.0.0")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(10 * time.Minute),
+		URIs:         []*url.URL{san},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	bundle := func(t *testing.T, statement string) []byte {
+		digest := sha256.Sum256(attestation_module.PAE(attestation_module.PayloadTypeInToto, []byte(statement)))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		b, err := json.Marshal(map[string]any{
+			"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+			"verificationMaterial": map[string]any{
+				"certificate": map[string]any{"rawBytes": base64.StdEncoding.EncodeToString(cert)},
+			},
+			"dsseEnvelope": map[string]any{
+				"payloadType": attestation_module.PayloadTypeInToto,
+				"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+				"signatures":  []map[string]any{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+			},
+		})
+		require.NoError(t, err)
+		return b
+	}
+
+	var attestationID int64
+
+	t.Run("Upload", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		upload := func(content []byte, expectedStatus int) *http.Response {
+			req := NewRequestWithBody(t, "POST", root, bytes.NewReader(content)).
+				AddTokenAuth(token)
+			return MakeRequest(t, req, expectedStatus).Result()
+		}
+
+		upload([]byte("invalid"), http.StatusBadRequest)
+		upload([]byte(statement(hex.EncodeToString(contentSum[:]))), http.StatusBadRequest)
+		upload(bundle(t, statement("0000")), http.StatusBadRequest)
+
+		valid := bundle(t, statement(hex.EncodeToString(contentSum[:])))
+		tampered := bytes.Replace(valid, []byte(`"sig":"`), []byte(`"sig":"AA`), 1)
+		upload(tampered, http.StatusBadRequest)
+
+		req := NewRequestWithBody(t, "POST", root, bytes.NewReader(valid)).
+			AddTokenAuth(getUserToken(t, "user4", auth_model.AccessTokenScopeWritePackage))
+		MakeRequest(t, req, http.StatusForbidden)
+
+		req = NewRequestWithBody(t, "POST", root, bytes.NewReader(valid)).
+			AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusCreated)
+
+		var pa *api.PackageAttestation
+		DecodeJSON(t, resp, &pa)
+		attestationID = pa.ID
+		assert.Equal(t, attestation_module.FormatSigstoreBundle, pa.Format)
+		assert.Equal(t, packages_model.AttestationSourceUpload, pa.Source)
+		assert.False(t, pa.Verified)
+		assert.True(t, pa.Signed)
+		assert.Equal(t, san.String(), pa.SignerIdentity)
+		assert.Equal(t, ".forgejo/workflows/release.yml", pa.Workflow)
+		assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", pa.CommitSHA)
+		assert.Equal(t, "https://example.com/owner/repo", pa.SourceRepository)
+
+		upload(valid, http.StatusConflict)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", root).AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var pas []*api.PackageAttestation
+		DecodeJSON(t, resp, &pas)
+		require.Len(t, pas, 1)
+		assert.Equal(t, attestationID, pas[0].ID)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("%s/%d", root, attestationID)).AddTokenAuth(token)
+		resp = MakeRequest(t, req, http.StatusOK)
+		_, err := attestation_module.Parse(resp.Body.Bytes())
+		require.NoError(t, err)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("%s/%d", root, attestationID+1000)).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("View", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("/%s/-/packages/generic/%s/%s", user.Name, packageName, packageVersion))
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		htmlDoc := NewHTMLParser(t, resp.Body)
+		htmlDoc.AssertElement(t, `a[href="https://example.com/owner/repo/actions/runs/1"]`, true)
+		// the certificate of an uploaded attestation is not verified without a trusted root
+		htmlDoc.AssertElement(t, ".octicon-unverified", true)
+		htmlDoc.AssertElement(t, ".octicon-verified", false)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "DELETE", fmt.Sprintf("%s/%d", root, attestationID)).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		unittest.AssertNotExistsBean(t, &packages_model.PackageAttestation{ID: attestationID})
+
+		req = NewRequest(t, "DELETE", fmt.Sprintf("%s/%d", root, attestationID)).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("TrustedRoot", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		// a certificate authority and a transparency log like Fulcio and Rekor
+		caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		caTemplate := &x509.Certificate{
+			SerialNumber:          big.NewInt(2),
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+		require.NoError(t, err)
+		caCert, err := x509.ParseCertificate(caDER)
+		require.NoError(t, err)
+
+		rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+		require.NoError(t, err)
+		logID := sha256.Sum256(rekorDER)
+
+		trustedRoot, err := json.Marshal(map[string]any{
+			"tlogs": []map[string]any{{
+				"publicKey": map[string]any{"rawBytes": base64.StdEncoding.EncodeToString(rekorDER)},
+				"logId":     map[string]any{"keyId": base64.StdEncoding.EncodeToString(logID[:])},
+			}},
+			"certificateAuthorities": []map[string]any{{
+				"certChain": map[string]any{"certificates": []map[string]any{{"rawBytes": base64.StdEncoding.EncodeToString(caDER)}}},
+			}},
+		})
+		require.NoError(t, err)
+		trustedRootPath := filepath.Join(t.TempDir(), "trusted_root.json")
+		require.NoError(t, os.WriteFile(trustedRootPath, trustedRoot, 0o644))
+		defer test.MockVariableValue(&setting.Packages.AttestationTrustedRoot, trustedRootPath)()
+
+		signerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		signerTemplate := &x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(10 * time.Minute),
+			URIs:         []*url.URL{san},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}
+		signerCert, err := x509.CreateCertificate(rand.Reader, signerTemplate, caCert, &signerKey.PublicKey, caKey)
+		require.NoError(t, err)
+
+		payload := statement(hex.EncodeToString(contentSum[:]))
+		digest := sha256.Sum256(attestation_module.PAE(attestation_module.PayloadTypeInToto, []byte(payload)))
+		sig, err := ecdsa.SignASN1(rand.Reader, signerKey, digest[:])
+		require.NoError(t, err)
+
+		// the entry of the signature in the transparency log and its signed entry timestamp
+		payloadHash := sha256.Sum256([]byte(payload))
+		body, err := json.Marshal(map[string]any{
+			"apiVersion": "0.0.1",
+			"kind":       "dsse",
+			"spec": map[string]any{
+				"payloadHash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+				"signatures": []map[string]any{{
+					"signature": base64.StdEncoding.EncodeToString(sig),
+					"verifier":  base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signerCert})),
+				}},
+			},
+		})
+		require.NoError(t, err)
+		integratedTime := time.Now().Unix()
+		setDigest := sha256.Sum256([]byte(fmt.Sprintf(`{"body":"%s","integratedTime":%d,"logID":"%s","logIndex":%d}`,
+			base64.StdEncoding.EncodeToString(body), integratedTime, hex.EncodeToString(logID[:]), 7)))
+		set, err := ecdsa.SignASN1(rand.Reader, rekorKey, setDigest[:])
+		require.NoError(t, err)
+
+		logged, err := json.Marshal(map[string]any{
+			"mediaType": "application/vnd.dev.sigstore.bundle.v0.3+json",
+			"verificationMaterial": map[string]any{
+				"certificate": map[string]any{"rawBytes": base64.StdEncoding.EncodeToString(signerCert)},
+				"tlogEntries": []map[string]any{{
+					"logIndex":          "7",
+					"logId":             map[string]any{"keyId": base64.StdEncoding.EncodeToString(logID[:])},
+					"kindVersion":       map[string]any{"kind": "dsse", "version": "0.0.1"},
+					"integratedTime":    fmt.Sprint(integratedTime),
+					"inclusionPromise":  map[string]any{"signedEntryTimestamp": base64.StdEncoding.EncodeToString(set)},
+					"canonicalizedBody": base64.StdEncoding.EncodeToString(body),
+				}},
+			},
+			"dsseEnvelope": map[string]any{
+				"payloadType": attestation_module.PayloadTypeInToto,
+				"payload":     base64.StdEncoding.EncodeToString([]byte(payload)),
+				"signatures":  []map[string]any{{"sig": base64.StdEncoding.EncodeToString(sig)}},
+			},
+		})
+		require.NoError(t, err)
+
+		// the self-signed certificate is not issued by the trusted certificate authority
+		req := NewRequestWithBody(t, "POST", root, bytes.NewReader(bundle(t, payload))).
+			AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "POST", root, bytes.NewReader(logged)).
+			AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusCreated)
+
+		var pa *api.PackageAttestation
+		DecodeJSON(t, resp, &pa)
+		assert.Equal(t, packages_model.AttestationSourceUpload, pa.Source)
+		assert.True(t, pa.Verified)
+		assert.True(t, pa.Signed)
+		assert.Equal(t, san.String(), pa.SignerIdentity)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("/%s/-/packages/generic/%s/%s", user.Name, packageName, packageVersion))
+		resp = MakeRequest(t, req, http.StatusOK)
+
+		htmlDoc := NewHTMLParser(t, resp.Body)
+		htmlDoc.AssertElement(t, ".octicon-verified", true)
+		htmlDoc.AssertElement(t, ".octicon-unverified", false)
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		actionsToken := NewActionsUserTestContext(t, user.Name, "repo1").Token
+
+		req := NewRequestWithBody(t, "PUT", fmt.Sprintf("/api/packages/%s/generic/%s/2.0.0/%s", user.Name, packageName, filename), bytes.NewReader(content)).
+			AddTokenAuth(actionsToken)
+		MakeRequest(t, req, http.StatusCreated)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("/api/v1/packages/%s/generic/%s/2.0.0/attestations", user.Name, packageName)).
+			AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var pas []*api.PackageAttestation
+		DecodeJSON(t, resp, &pas)
+		require.Len(t, pas, 1)
+		assert.Equal(t, packages_model.AttestationSourceActions, pas[0].Source)
+		assert.True(t, pas[0].Verified)
+		assert.False(t, pas[0].Signed)
+		assert.Equal(t, "artifact.yaml", pas[0].Workflow)
+		assert.Equal(t, "c2d72f548424103f01ee1dc02889c1e2bff816b0", pas[0].CommitSHA)
+		assert.Equal(t, "refs/heads/master", pas[0].SourceRef)
+
+		req = NewRequestWithBody(t, "PUT", fmt.Sprintf("/api/packages/%s/generic/%s/3.0.0/%s", "user4", packageName, filename), bytes.NewReader(content)).
+			AddTokenAuth(actionsToken)
+		MakeRequest(t, req, http.StatusUnauthorized)
+	})
+}
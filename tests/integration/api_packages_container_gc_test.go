@@ -0,0 +1,167 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+	packages_container "code.gitea.io/gitea/services/packages/container"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/opencontainers/go-digest"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackagesContainerGarbageCollection(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+	defer test.MockVariableValue(&setting.Packages.Storage.Type, setting.LocalStorageType)()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	url := fmt.Sprintf("%sv2/%s/gc-test", setting.AppURL, user.Name)
+
+	uploadBlob := func(t *testing.T, content string) oci.Descriptor {
+		t.Helper()
+
+		d := digest.FromString(content)
+		req := NewRequestWithBody(t, "POST", fmt.Sprintf("%s/blobs/uploads?digest=%s", url, d), strings.NewReader(content)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusCreated)
+
+		return oci.Descriptor{MediaType: "application/vnd.oci.image.layer.v1.tar", Digest: d, Size: int64(len(content))}
+	}
+
+	uploadManifest := func(t *testing.T, reference, content, mediaType string) oci.Descriptor {
+		t.Helper()
+
+		d := digest.FromString(content)
+		if reference == "" {
+			reference = d.String()
+		}
+		req := NewRequestWithBody(t, "PUT", fmt.Sprintf("%s/manifests/%s", url, reference), strings.NewReader(content)).
+			AddBasicAuth(user.Name).
+			SetHeader("Content-Type", mediaType)
+		resp := MakeRequest(t, req, http.StatusCreated)
+		assert.Equal(t, d.String(), resp.Header().Get("Docker-Content-Digest"))
+
+		return oci.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}
+	}
+
+	imageManifest := func(config, layer oci.Descriptor, subject *oci.Descriptor) string {
+		content := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"mediaType":"%s","digest":"%s","size":%d},"layers":[{"mediaType":"%s","digest":"%s","size":%d}]`,
+			oci.MediaTypeImageManifest, oci.MediaTypeImageConfig, config.Digest, config.Size, layer.MediaType, layer.Digest, layer.Size)
+		if subject != nil {
+			content += fmt.Sprintf(`,"subject":{"mediaType":"%s","digest":"%s","size":%d}`, subject.MediaType, subject.Digest, subject.Size)
+		}
+		return content + "}"
+	}
+
+	assertManifest := func(t *testing.T, d digest.Digest, expectedStatus int) {
+		t.Helper()
+
+		req := NewRequest(t, "HEAD", fmt.Sprintf("%s/manifests/%s", url, d)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, expectedStatus)
+	}
+
+	config := uploadBlob(t, `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layer := uploadBlob(t, "shared layer")
+	signatureLayer := uploadBlob(t, "signature")
+	orphanLayer := uploadBlob(t, "orphan layer")
+	orphanSignatureLayer := uploadBlob(t, "orphan signature")
+
+	// tagged manifest list with a signed manifest
+	manifest := uploadManifest(t, "", imageManifest(config, layer, nil), oci.MediaTypeImageManifest)
+	signature := uploadManifest(t, "", imageManifest(config, signatureLayer, &manifest), oci.MediaTypeImageManifest)
+	index := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","manifests":[{"mediaType":"%s","digest":"%s","size":%d,"platform":{"os":"linux","architecture":"amd64"}}]}`,
+		oci.MediaTypeImageIndex, manifest.MediaType, manifest.Digest, manifest.Size)
+	uploadManifest(t, "v1", index, oci.MediaTypeImageIndex)
+
+	// untagged signed manifest
+	orphanContent := imageManifest(config, orphanLayer, nil)
+	orphan := uploadManifest(t, "", orphanContent, oci.MediaTypeImageManifest)
+	orphanSignatureContent := imageManifest(config, orphanSignatureLayer, &orphan)
+	orphanSignature := uploadManifest(t, "", orphanSignatureContent, oci.MediaTypeImageManifest)
+
+	expectedSize := int64(len(orphanContent)+len(orphanSignatureContent)) + orphanLayer.Size + orphanSignatureLayer.Size
+
+	t.Run("DryRun", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		result, err := packages_container.CollectGarbage(db.DefaultContext, user.ID, -time.Hour, true)
+		require.NoError(t, err)
+		assert.Equal(t, &packages_container.GarbageCollectionResult{Manifests: 2, Blobs: 4, Size: expectedSize}, result)
+
+		assertManifest(t, orphan.Digest, http.StatusOK)
+		assertManifest(t, orphanSignature.Digest, http.StatusOK)
+	})
+
+	t.Run("Collect", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		result, err := packages_container.CollectGarbage(db.DefaultContext, user.ID, -time.Hour, false)
+		require.NoError(t, err)
+		assert.Equal(t, &packages_container.GarbageCollectionResult{Manifests: 2, Blobs: 4, Size: expectedSize}, result)
+
+		assertManifest(t, orphan.Digest, http.StatusNotFound)
+		assertManifest(t, orphanSignature.Digest, http.StatusNotFound)
+		assertManifest(t, manifest.Digest, http.StatusOK)
+		assertManifest(t, signature.Digest, http.StatusOK)
+
+		result, err = packages_container.CollectGarbage(db.DefaultContext, user.ID, -time.Hour, false)
+		require.NoError(t, err)
+		assert.Zero(t, result.Manifests)
+	})
+
+	t.Run("RecentlyUploaded", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		uploadBlob(t, "orphan layer")
+		uploadManifest(t, "", orphanContent, oci.MediaTypeImageManifest)
+
+		result, err := packages_container.CollectGarbage(db.DefaultContext, user.ID, time.Hour, false)
+		require.NoError(t, err)
+		assert.Zero(t, result.Manifests)
+
+		assertManifest(t, orphan.Digest, http.StatusOK)
+	})
+
+	t.Run("Untagged", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "DELETE", fmt.Sprintf("%s/manifests/v1", url)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusAccepted)
+
+		result, err := packages_container.CollectGarbage(db.DefaultContext, user.ID, -time.Hour, false)
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Manifests)
+
+		assertManifest(t, manifest.Digest, http.StatusNotFound)
+		assertManifest(t, signature.Digest, http.StatusNotFound)
+		assertManifest(t, orphan.Digest, http.StatusNotFound)
+	})
+
+	t.Run("AdminDryRun", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		session := loginUser(t, "user1")
+		req := NewRequestWithValues(t, "POST", "/admin/packages/container-gc", map[string]string{
+			"_csrf":   GetCSRF(t, session, "/admin/packages"),
+			"dry_run": "true",
+		})
+		session.MakeRequest(t, req, http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/opencontainers/go-digest"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackagesContainerReferrers(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	imageURL := fmt.Sprintf("%sv2/%s/referrers-test", setting.AppURL, user.Name)
+
+	uploadBlob := func(t *testing.T, content string) oci.Descriptor {
+		t.Helper()
+
+		d := digest.FromString(content)
+		req := NewRequestWithBody(t, "POST", fmt.Sprintf("%s/blobs/uploads?digest=%s", imageURL, d), strings.NewReader(content)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusCreated)
+
+		return oci.Descriptor{MediaType: "application/vnd.oci.image.layer.v1.tar", Digest: d, Size: int64(len(content))}
+	}
+
+	config := uploadBlob(t, `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layer := uploadBlob(t, "layer")
+	empty := uploadBlob(t, "{}")
+
+	manifestContent := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"mediaType":"%s","digest":"%s","size":%d},"layers":[{"mediaType":"%s","digest":"%s","size":%d}]}`,
+		oci.MediaTypeImageManifest, oci.MediaTypeImageConfig, config.Digest, config.Size, layer.MediaType, layer.Digest, layer.Size)
+	manifestDigest := digest.FromString(manifestContent)
+
+	req := NewRequestWithBody(t, "PUT", fmt.Sprintf("%s/manifests/latest", imageURL), strings.NewReader(manifestContent)).
+		AddBasicAuth(user.Name).
+		SetHeader("Content-Type", oci.MediaTypeImageManifest)
+	resp := MakeRequest(t, req, http.StatusCreated)
+	assert.Empty(t, resp.Header().Get("OCI-Subject"))
+
+	artifact := func(artifactType, annotation string) string {
+		layer := uploadBlob(t, annotation)
+		return fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","artifactType":"%s","config":{"mediaType":"%s","digest":"%s","size":%d},"layers":[{"mediaType":"%s","digest":"%s","size":%d}],"subject":{"mediaType":"%s","digest":"%s","size":%d},"annotations":{"test":"%s"}}`,
+			oci.MediaTypeImageManifest, artifactType, oci.MediaTypeEmptyJSON, empty.Digest, empty.Size, artifactType, layer.Digest, layer.Size, oci.MediaTypeImageManifest, manifestDigest, len(manifestContent), annotation)
+	}
+
+	signatureType := "application/vnd.dev.cosign.artifact.sig.v1+json"
+	sbomType := "application/spdx+json"
+
+	uploadArtifact := func(t *testing.T, content string) digest.Digest {
+		t.Helper()
+
+		d := digest.FromString(content)
+		req := NewRequestWithBody(t, "PUT", fmt.Sprintf("%s/manifests/%s", imageURL, d), strings.NewReader(content)).
+			AddBasicAuth(user.Name).
+			SetHeader("Content-Type", oci.MediaTypeImageManifest)
+		resp := MakeRequest(t, req, http.StatusCreated)
+		assert.Equal(t, manifestDigest.String(), resp.Header().Get("OCI-Subject"))
+		return d
+	}
+
+	signatureContent := artifact(signatureType, "signature")
+	signatureDigest := uploadArtifact(t, signatureContent)
+	sbomDigest := uploadArtifact(t, artifact(sbomType, "sbom"))
+
+	t.Run("List", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("%s/referrers/%s", imageURL, manifestDigest)).
+			AddBasicAuth(user.Name)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		assert.Equal(t, oci.MediaTypeImageIndex, resp.Header().Get("Content-Type"))
+		assert.Empty(t, resp.Header().Get("OCI-Filters-Applied"))
+
+		var index oci.Index
+		DecodeJSON(t, resp, &index)
+		assert.Equal(t, 2, index.SchemaVersion)
+		assert.Equal(t, oci.MediaTypeImageIndex, index.MediaType)
+		require.Len(t, index.Manifests, 2)
+		assert.Equal(t, oci.Descriptor{
+			MediaType:    oci.MediaTypeImageManifest,
+			ArtifactType: signatureType,
+			Digest:       signatureDigest,
+			Size:         int64(len(signatureContent)),
+			Annotations:  map[string]string{"test": "signature"},
+		}, index.Manifests[0])
+		assert.Equal(t, sbomDigest, index.Manifests[1].Digest)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("%s/referrers/%s?artifactType=%s", imageURL, manifestDigest, url.QueryEscape(sbomType))).
+			AddBasicAuth(user.Name)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		assert.Equal(t, "artifactType", resp.Header().Get("OCI-Filters-Applied"))
+
+		var index oci.Index
+		DecodeJSON(t, resp, &index)
+		require.Len(t, index.Manifests, 1)
+		assert.Equal(t, sbomDigest, index.Manifests[0].Digest)
+	})
+
+	t.Run("NoReferrers", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("%s/referrers/%s", imageURL, signatureDigest)).
+			AddBasicAuth(user.Name)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var index oci.Index
+		DecodeJSON(t, resp, &index)
+		assert.Empty(t, index.Manifests)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("%s/referrers/invalid", imageURL)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("%sv2/%s/unknown/referrers/%s", setting.AppURL, user.Name, manifestDigest)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("PackagePage", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("/%s/-/packages/container/referrers-test/latest", user.Name))
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Contains(t, resp.Body.String(), signatureDigest.String())
+		assert.Contains(t, resp.Body.String(), sbomType)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("/%s/-/packages/container/referrers-test/%s", user.Name, signatureDigest))
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Contains(t, resp.Body.String(), manifestDigest.String())
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/blakesmith/ar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageDebianSnapshot(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	packageName := "gitea"
+	architecture := "amd64"
+
+	createArchive := func(version string) []byte {
+		control := fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\nDescription: Package Description\n", packageName, version, architecture)
+
+		var cbuf bytes.Buffer
+		zw := gzip.NewWriter(&cbuf)
+		tw := tar.NewWriter(zw)
+		tw.WriteHeader(&tar.Header{
+			Name: "control",
+			Mode: 0o600,
+			Size: int64(len(control)),
+		})
+		tw.Write([]byte(control))
+		tw.Close()
+		zw.Close()
+
+		var buf bytes.Buffer
+		aw := ar.NewWriter(&buf)
+		aw.WriteGlobalHeader()
+		aw.WriteHeader(&ar.Header{
+			Name: "control.tar.gz",
+			Mode: 0o600,
+			Size: int64(cbuf.Len()),
+		})
+		aw.Write(cbuf.Bytes())
+		return buf.Bytes()
+	}
+
+	rootURL := fmt.Sprintf("/api/packages/%s/debian", user.Name)
+	snapshotURL := rootURL + "/-/snapshots/release-1"
+
+	upload := func(t *testing.T, distribution string, content []byte) {
+		t.Helper()
+
+		req := NewRequestWithBody(t, "PUT", fmt.Sprintf("%s/pool/%s/main/upload", rootURL, distribution), bytes.NewReader(content)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusCreated)
+	}
+
+	packagesIndex := func(t *testing.T, baseURL, distribution string) string {
+		t.Helper()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("%s/dists/%s/main/binary-%s/Packages", baseURL, distribution, architecture))
+		return MakeRequest(t, req, http.StatusOK).Body.String()
+	}
+
+	content := createArchive("1.0")
+	upload(t, "testing", content)
+
+	t.Run("CreateSnapshot", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "PUT", snapshotURL+"?distribution=testing")
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequest(t, "PUT", snapshotURL).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequest(t, "PUT", rootURL+"/-/snapshots/-invalid?distribution=testing").
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequest(t, "PUT", snapshotURL+"?distribution=unknown").
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusNotFound)
+
+		req = NewRequest(t, "PUT", snapshotURL+"?distribution=testing").
+			AddBasicAuth(user.Name)
+		resp := MakeRequest(t, req, http.StatusCreated)
+
+		var snapshot struct {
+			Name         string `json:"name"`
+			Distribution string `json:"distribution"`
+		}
+		DecodeJSON(t, resp, &snapshot)
+		assert.Equal(t, "release-1", snapshot.Name)
+		assert.Equal(t, "testing", snapshot.Distribution)
+
+		req = NewRequest(t, "PUT", snapshotURL+"?distribution=testing").
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusConflict)
+
+		req = NewRequest(t, "GET", rootURL+"/-/snapshots")
+		resp = MakeRequest(t, req, http.StatusOK)
+
+		var snapshots []struct {
+			Name string `json:"name"`
+		}
+		DecodeJSON(t, resp, &snapshots)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, "release-1", snapshots[0].Name)
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		promote := func(from, to, version string) *RequestWrapper {
+			return NewRequestWithJSON(t, "POST", rootURL+"/-/promote", map[string]any{
+				"from": from,
+				"to":   to,
+				"packages": []map[string]string{
+					{"name": packageName, "version": version},
+				},
+			})
+		}
+
+		MakeRequest(t, promote("testing", "stable", "1.0"), http.StatusUnauthorized)
+		MakeRequest(t, promote("testing", "testing", "1.0").AddBasicAuth(user.Name), http.StatusBadRequest)
+		MakeRequest(t, promote("testing", "stable", "9.9").AddBasicAuth(user.Name), http.StatusNotFound)
+		MakeRequest(t, promote("unknown", "stable", "1.0").AddBasicAuth(user.Name), http.StatusNotFound)
+		MakeRequest(t, promote("testing", "stable", "1.0").AddBasicAuth(user.Name), http.StatusNoContent)
+		MakeRequest(t, promote("testing", "stable", "1.0").AddBasicAuth(user.Name), http.StatusNoContent)
+
+		assert.Contains(t, packagesIndex(t, rootURL, "stable"), "Filename: pool/stable/main/gitea_1.0_amd64.deb")
+
+		req := NewRequest(t, "GET", rootURL+"/pool/stable/main/gitea_1.0_amd64.deb")
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.Bytes())
+	})
+
+	t.Run("ServeSnapshot", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		upload(t, "testing", createArchive("1.1"))
+
+		assert.Contains(t, packagesIndex(t, rootURL, "testing"), "Version: 1.1")
+
+		index := packagesIndex(t, snapshotURL, "testing")
+		assert.Contains(t, index, "Filename: pool/testing/main/gitea_1.0_amd64.deb")
+		assert.NotContains(t, index, "Version: 1.1")
+
+		req := NewRequest(t, "GET", snapshotURL+"/dists/testing/Release")
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Contains(t, resp.Body.String(), "Codename: testing")
+
+		req = NewRequest(t, "GET", snapshotURL+"/dists/stable/Release")
+		MakeRequest(t, req, http.StatusNotFound)
+
+		req = NewRequest(t, "DELETE", fmt.Sprintf("%s/pool/testing/main/%s/1.0/%s", rootURL, packageName, architecture)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		req = NewRequest(t, "GET", rootURL+"/pool/testing/main/gitea_1.0_amd64.deb")
+		MakeRequest(t, req, http.StatusNotFound)
+
+		req = NewRequest(t, "GET", snapshotURL+"/pool/testing/main/gitea_1.0_amd64.deb")
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.Bytes())
+	})
+
+	t.Run("DeleteSnapshot", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "DELETE", snapshotURL)
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequest(t, "DELETE", snapshotURL).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		req = NewRequest(t, "DELETE", snapshotURL).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusNotFound)
+
+		req = NewRequest(t, "GET", snapshotURL+"/dists/testing/Release")
+		MakeRequest(t, req, http.StatusNotFound)
+
+		// the promoted package is still available
+		req = NewRequest(t, "GET", rootURL+"/pool/stable/main/gitea_1.0_amd64.deb")
+		MakeRequest(t, req, http.StatusOK)
+	})
+}
@@ -0,0 +1,314 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	hex_module "code.gitea.io/gitea/modules/packages/hex"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestPackageHex(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+	token := getUserToken(t, user.Name, auth_model.AccessTokenScopeWritePackage)
+
+	packageName := "test_package"
+	packageVersion := "1.0.1"
+	packageDescription := "Test Description"
+
+	createPackage := func(version, contents string) []byte {
+		metadata := fmt.Sprintf(`{<<"app">>,<<"test_package">>}.
+{<<"build_tools">>,[<<"mix">>]}.
+{<<"description">>,<<"%s">>}.
+{<<"licenses">>,[<<"MIT">>]}.
+{<<"links">>,[{<<"Forgejo">>,<<"https://forgejo.org/">>}]}.
+{<<"name">>,<<"%s">>}.
+{<<"requirements">>,[{<<"jason">>,[{<<"app">>,<<"jason">>},{<<"optional">>,false},{<<"requirement">>,<<"~> 1.4">>},{<<"repository">>,<<"hexpm">>}]}]}.
+{<<"version">>,<<"%s">>}.
+`, packageDescription, packageName, version)
+
+		checksum := sha256.Sum256([]byte("3" + metadata + contents))
+
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, f := range []struct{ Name, Content string }{
+			{"VERSION", "3"},
+			{"CHECKSUM", strings.ToUpper(hex.EncodeToString(checksum[:]))},
+			{"metadata.config", metadata},
+			{"contents.tar.gz", contents},
+		} {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: f.Name, Mode: 0o600, Size: int64(len(f.Content)), Typeflag: tar.TypeReg}))
+			_, err := tw.Write([]byte(f.Content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		return buf.Bytes()
+	}
+
+	root := fmt.Sprintf("/api/packages/%s/hex", user.Name)
+
+	// readSigned verifies the signature of a registry resource and returns its payload
+	readSigned := func(t *testing.T, url string) []byte {
+		req := NewRequest(t, "GET", url).SetHeader("Authorization", token)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		zr, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+
+		fields := parseProtobuf(t, data)
+		payload, signature := fields[1][0], fields[2][0]
+
+		req = NewRequest(t, "GET", root+"/public_key").SetHeader("Authorization", token)
+		resp = MakeRequest(t, req, http.StatusOK)
+
+		block, _ := pem.Decode(resp.Body.Bytes())
+		require.NotNil(t, block)
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		require.NoError(t, err)
+
+		h := sha512.Sum512(payload)
+		require.NoError(t, rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA512, h[:], signature))
+
+		return payload
+	}
+
+	content := createPackage(packageVersion, "contents")
+
+	t.Run("Upload", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequestWithBody(t, "POST", root+"/api/publish", bytes.NewReader(content))
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		// packages are not accessible to a token restricted to repositories
+		restricted := createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-hex",
+			"scopes":       []string{"write:repository"},
+			"repositories": []string{"user2/repo1"},
+		}, http.StatusCreated)
+		req = NewRequestWithBody(t, "POST", root+"/api/publish", bytes.NewReader(content)).
+			SetHeader("Authorization", restricted.Token)
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequestWithBody(t, "POST", root+"/api/publish", bytes.NewReader([]byte("invalid"))).
+			SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "POST", root+"/api/publish", bytes.NewReader(content)).
+			SetHeader("Authorization", token).
+			SetHeader("Accept", hex_module.ContentTypeTerm)
+		resp := MakeRequest(t, req, http.StatusCreated)
+		assert.Equal(t, hex_module.ContentTypeTerm, resp.Header().Get("Content-Type"))
+
+		v, err := hex_module.UnmarshalTerm(resp.Body.Bytes())
+		require.NoError(t, err)
+		release := v.(map[string]any)
+		assert.Equal(t, packageVersion, release["version"])
+		checksum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(checksum[:]), release["checksum"])
+
+		pvs, err := packages.GetVersionsByPackageType(db.DefaultContext, user.ID, packages.TypeHex)
+		require.NoError(t, err)
+		require.Len(t, pvs, 1)
+
+		pd, err := packages.GetPackageDescriptor(db.DefaultContext, pvs[0])
+		require.NoError(t, err)
+		assert.Equal(t, packageName, pd.Package.Name)
+		assert.Equal(t, packageVersion, pd.Version.Version)
+		metadata := pd.Metadata.(*hex_module.Metadata)
+		assert.Equal(t, packageDescription, metadata.Description)
+		assert.Equal(t, []string{"MIT"}, metadata.Licenses)
+		require.Len(t, metadata.Requirements, 1)
+		assert.Equal(t, "jason", metadata.Requirements[0].Name)
+		require.Len(t, pd.Files, 1)
+		assert.Equal(t, fmt.Sprintf("%s-%s.tar", packageName, packageVersion), pd.Files[0].File.Name)
+
+		req = NewRequestWithBody(t, "POST", root+"/api/publish", bytes.NewReader(content)).
+			SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusConflict)
+
+		req = NewRequestWithBody(t, "POST", root+"/api/packages/other/releases", bytes.NewReader(content)).
+			SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusUnprocessableEntity)
+
+		content = createPackage(packageVersion, "replaced")
+
+		req = NewRequestWithBody(t, "POST", root+"/api/publish?replace=true", bytes.NewReader(content)).
+			SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusCreated)
+
+		pvs, err = packages.GetVersionsByPackageType(db.DefaultContext, user.ID, packages.TypeHex)
+		require.NoError(t, err)
+		assert.Len(t, pvs, 1)
+
+		req = NewRequestWithBody(t, "POST", root+"/api/packages/"+packageName+"/releases", bytes.NewReader(createPackage("1.0.10", "newer"))).
+			SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusCreated)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("%s/tarballs/%s-%s.tar", root, packageName, packageVersion))
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.Bytes())
+
+		req = NewRequest(t, "GET", fmt.Sprintf("%s/tarballs/%s-2.0.0.tar", root, packageName))
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("Registry", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		names := parseProtobuf(t, readSigned(t, root+"/names"))
+		assert.Equal(t, user.LowerName, string(names[2][0]))
+		require.Len(t, names[1], 1)
+		assert.Equal(t, packageName, string(parseProtobuf(t, names[1][0])[1][0]))
+
+		versions := parseProtobuf(t, readSigned(t, root+"/versions"))
+		require.Len(t, versions[1], 1)
+		p := parseProtobuf(t, versions[1][0])
+		assert.Equal(t, [][]byte{[]byte(packageVersion), []byte("1.0.10")}, p[2])
+
+		pkg := parseProtobuf(t, readSigned(t, root+"/packages/"+packageName))
+		assert.Equal(t, packageName, string(pkg[2][0]))
+		assert.Equal(t, user.LowerName, string(pkg[3][0]))
+		require.Len(t, pkg[1], 2)
+		release := parseProtobuf(t, pkg[1][0])
+		assert.Equal(t, packageVersion, string(release[1][0]))
+		checksum := sha256.Sum256(content)
+		assert.Equal(t, checksum[:], release[5][0])
+		dependency := parseProtobuf(t, release[3][0])
+		assert.Equal(t, "jason", string(dependency[1][0]))
+		assert.Equal(t, "~> 1.4", string(dependency[2][0]))
+		assert.Equal(t, "hexpm", string(dependency[5][0]))
+
+		req := NewRequest(t, "GET", root+"/packages/unknown").SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("API", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", root+"/api/packages/"+packageName)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var info struct {
+			Name     string `json:"name"`
+			Releases []struct {
+				Version string `json:"version"`
+			} `json:"releases"`
+		}
+		DecodeJSON(t, resp, &info)
+		assert.Equal(t, packageName, info.Name)
+		require.Len(t, info.Releases, 2)
+		assert.Equal(t, "1.0.10", info.Releases[0].Version)
+
+		req = NewRequest(t, "GET", root+"/api/packages/"+packageName+"/releases/"+packageVersion)
+		MakeRequest(t, req, http.StatusOK)
+	})
+
+	t.Run("Retire", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		url := fmt.Sprintf("%s/api/packages/%s/releases/%s/retire", root, packageName, packageVersion)
+
+		body, err := hex_module.MarshalTerm(map[string]any{"reason": "security", "message": "CVE"})
+		require.NoError(t, err)
+
+		req := NewRequestWithBody(t, "POST", url, bytes.NewReader(body)).
+			SetHeader("Authorization", token).
+			SetHeader("Content-Type", hex_module.ContentTypeTerm)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		versions := parseProtobuf(t, readSigned(t, root+"/versions"))
+		p := parseProtobuf(t, versions[1][0])
+		require.Len(t, p[3], 1)
+		assert.Equal(t, []byte{0}, p[3][0])
+
+		req = NewRequestWithBody(t, "POST", url, strings.NewReader(`{"reason":"unknown"}`)).
+			SetHeader("Authorization", token).
+			SetHeader("Content-Type", "application/json")
+		MakeRequest(t, req, http.StatusUnprocessableEntity)
+
+		req = NewRequest(t, "DELETE", url).SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		versions = parseProtobuf(t, readSigned(t, root+"/versions"))
+		p = parseProtobuf(t, versions[1][0])
+		assert.Empty(t, p[3])
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		url := fmt.Sprintf("%s/api/packages/%s/releases/%s", root, packageName, packageVersion)
+
+		req := NewRequest(t, "DELETE", url)
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequest(t, "DELETE", url).SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		req = NewRequest(t, "DELETE", url).SetHeader("Authorization", token)
+		MakeRequest(t, req, http.StatusNotFound)
+
+		pvs, err := packages.GetVersionsByPackageType(db.DefaultContext, user.ID, packages.TypeHex)
+		require.NoError(t, err)
+		assert.Len(t, pvs, 1)
+	})
+}
+
+// parseProtobuf returns the length delimited fields of a protobuf message by their number
+func parseProtobuf(t *testing.T, data []byte) map[protowire.Number][][]byte {
+	t.Helper()
+
+	fields := make(map[protowire.Number][][]byte)
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		require.GreaterOrEqual(t, n, 0)
+		data = data[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			require.GreaterOrEqual(t, n, 0)
+			fields[num] = append(fields[num], v)
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			require.GreaterOrEqual(t, n, 0)
+			data = data[n:]
+		}
+	}
+	return fields
+}
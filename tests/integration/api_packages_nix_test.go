@@ -0,0 +1,195 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	nix_module "code.gitea.io/gitea/modules/packages/nix"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageNix(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	root := fmt.Sprintf("/api/packages/%s/nix", user.Name)
+
+	narContent := []byte("nix-archive-1 test content")
+	sum := sha256.Sum256(narContent)
+	narFilename := nix_module.EncodeNix32(sum[:]) + ".nar"
+
+	storeHash := "0c0x3b3vbsa2y4bmj3hvxm3jrvzq2bpl"
+	storePath := nix_module.StoreDir + "/" + storeHash + "-hello-2.12.1"
+	otherHash := "3n58xw4373jp0ljirf06d8077j15pc4j"
+	otherPath := nix_module.StoreDir + "/" + otherHash + "-hello-copy"
+
+	narInfo := func(storePath, url string) string {
+		return fmt.Sprintf(`StorePath: %s
+URL: %s
+Compression: none
+NarHash: sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad
+NarSize: %d
+References: %s
+System: x86_64-linux
+`, storePath, url, len(narContent), strings.TrimPrefix(storePath, nix_module.StoreDir+"/"))
+	}
+
+	t.Run("CacheInfo", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", root+"/nix-cache-info")
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, "text/x-nix-cache-info", resp.Header().Get("Content-Type"))
+		assert.Contains(t, resp.Body.String(), "StoreDir: /nix/store\n")
+	})
+
+	t.Run("UploadNar", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		url := root + "/nar/" + narFilename
+
+		req := NewRequestWithBody(t, "PUT", url, bytes.NewReader(narContent))
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequestWithBody(t, "PUT", root+"/nar/invalid.nar", bytes.NewReader(narContent)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "PUT", url, bytes.NewReader([]byte("other content"))).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "PUT", url, bytes.NewReader(narContent)).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusCreated)
+
+		// The NAR is not available until a narinfo references it
+		req = NewRequest(t, "HEAD", url)
+		MakeRequest(t, req, http.StatusNotFound)
+
+		pvs, err := packages.GetVersionsByPackageType(db.DefaultContext, user.ID, packages.TypeNix)
+		require.NoError(t, err)
+		assert.Empty(t, pvs)
+	})
+
+	t.Run("UploadNarInfo", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		url := fmt.Sprintf("%s/%s.narinfo", root, storeHash)
+
+		req := NewRequestWithBody(t, "PUT", url, strings.NewReader(narInfo(storePath, "nar/"+narFilename)))
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequestWithBody(t, "PUT", url, strings.NewReader("invalid")).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "PUT", url, strings.NewReader(narInfo(otherPath, "nar/"+narFilename))).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "PUT", url, strings.NewReader(narInfo(storePath, "nar/1w1fff338fvdw53sqgamddn1b2xgds473pv6y13gizdbqjv4i5p3.nar"))).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		req = NewRequestWithBody(t, "PUT", url, strings.NewReader(narInfo(storePath, "nar/"+narFilename))).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusCreated)
+
+		pvs, err := packages.GetVersionsByPackageType(db.DefaultContext, user.ID, packages.TypeNix)
+		require.NoError(t, err)
+		require.Len(t, pvs, 1)
+
+		pd, err := packages.GetPackageDescriptor(db.DefaultContext, pvs[0])
+		require.NoError(t, err)
+		assert.Equal(t, "hello-2.12.1", pd.Package.Name)
+		assert.Equal(t, storeHash, pd.Version.Version)
+		ni := pd.Metadata.(*nix_module.NarInfo)
+		assert.Equal(t, storePath, ni.StorePath)
+		assert.Equal(t, "x86_64-linux", ni.System)
+		assert.EqualValues(t, len(narContent), ni.FileSize)
+		require.Len(t, pd.Files, 2)
+
+		// Uploading the same store path again is a no-op
+		req = NewRequestWithBody(t, "PUT", url, strings.NewReader(narInfo(storePath, "nar/"+narFilename))).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusOK)
+
+		// The NAR of an existing store path can be referenced without uploading it again
+		req = NewRequestWithBody(t, "PUT", fmt.Sprintf("%s/%s.narinfo", root, otherHash), strings.NewReader(narInfo(otherPath, "nar/"+narFilename))).
+			AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusCreated)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", root+"/public-key")
+		resp := MakeRequest(t, req, http.StatusOK)
+		publicKey := resp.Body.String()
+		assert.True(t, strings.HasSuffix(nix_module.KeyName(publicKey), "-"+user.LowerName+"-1"))
+
+		req = NewRequest(t, "GET", fmt.Sprintf("%s/%s.narinfo", root, storeHash))
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, "text/x-nix-narinfo", resp.Header().Get("Content-Type"))
+
+		ni, err := nix_module.ParseNarInfo(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, storePath, ni.StorePath)
+		assert.Equal(t, "nar/"+narFilename, ni.URL)
+		assert.Equal(t, "sha256:"+strings.TrimSuffix(narFilename, ".nar"), ni.FileHash)
+		require.Len(t, ni.Sigs, 1)
+		assert.True(t, nix_module.Verify(publicKey, ni))
+
+		req = NewRequest(t, "HEAD", fmt.Sprintf("%s/%s.narinfo", root, storeHash))
+		MakeRequest(t, req, http.StatusOK)
+
+		req = NewRequest(t, "GET", root+"/00000000000000000000000000000000.narinfo")
+		MakeRequest(t, req, http.StatusNotFound)
+
+		req = NewRequest(t, "HEAD", root+"/nar/"+narFilename)
+		MakeRequest(t, req, http.StatusOK)
+
+		req = NewRequest(t, "GET", root+"/nar/"+narFilename)
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, narContent, resp.Body.Bytes())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		url := fmt.Sprintf("%s/%s.narinfo", root, storeHash)
+
+		req := NewRequest(t, "DELETE", url)
+		MakeRequest(t, req, http.StatusUnauthorized)
+
+		req = NewRequest(t, "DELETE", url).AddBasicAuth(user.Name)
+		MakeRequest(t, req, http.StatusNoContent)
+
+		req = NewRequest(t, "GET", url)
+		MakeRequest(t, req, http.StatusNotFound)
+
+		// The NAR is still referenced by the other store path
+		req = NewRequest(t, "GET", root+"/nar/"+narFilename)
+		MakeRequest(t, req, http.StatusOK)
+
+		pvs, err := packages.GetVersionsByPackageType(db.DefaultContext, user.ID, packages.TypeNix)
+		require.NoError(t, err)
+		assert.Len(t, pvs, 1)
+	})
+}
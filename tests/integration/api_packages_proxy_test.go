@@ -0,0 +1,301 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/packages"
+	container_model "code.gitea.io/gitea/models/packages/container"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/tests"
+
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageProxy(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+	defer test.MockVariableValue(&setting.Packages.ProxyAllowedHostList, "loopback")()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	content := "test"
+	hashSHA256 := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	npmSHA1 := sha1.Sum([]byte(content))
+	npmSHA512 := sha512.Sum512([]byte(content))
+
+	sha256Digest := func(s string) string {
+		return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(s)))
+	}
+	containerConfig := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+	containerConfigDigest := sha256Digest(containerConfig)
+	containerLayerDigest := sha256Digest(content)
+	containerManifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"mediaType":"%s","digest":"%s","size":%d},"layers":[{"mediaType":"%s","digest":"%s","size":%d}]}`,
+		oci.MediaTypeImageManifest, oci.MediaTypeImageConfig, containerConfigDigest, len(containerConfig), oci.MediaTypeImageLayerGzip, containerLayerDigest, len(content))
+	containerManifestDigest := sha256Digest(containerManifest)
+	containerIndex := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","manifests":[{"mediaType":"%s","digest":"%s","size":%d,"platform":{"os":"linux","architecture":"amd64"}}]}`,
+		oci.MediaTypeImageIndex, oci.MediaTypeImageManifest, containerManifestDigest, len(containerManifest))
+	const upstreamToken = "upstream-token"
+
+	var upstreamRequests, upstreamTokenRequests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequests.Add(1)
+
+		// the container registry requires a token from its authorization service
+		if strings.HasPrefix(r.URL.Path, "/container/v2/") && r.Header.Get("Authorization") != "Bearer "+upstreamToken {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/container/token",service="registry",scope="repository:test-image:pull"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/pypi/simple/test-package/":
+			fmt.Fprintf(w, `<html><body><a href="/files/test_package-1.0.1.tar.gz#sha256=%s" data-requires-python="&gt;=3.8">test_package-1.0.1.tar.gz</a></body></html>`, hashSHA256)
+		case "/files/test_package-1.0.1.tar.gz":
+			fmt.Fprint(w, content)
+		case "/maven/com/gitea/test-project/1.0.1/test-project-1.0.1.jar":
+			fmt.Fprint(w, content)
+		case "/npm/test-package":
+			fmt.Fprintf(w, `{"name":"test-package","dist-tags":{"latest":"1.0.2"},"versions":{`+
+				`"1.0.1":{"name":"test-package","version":"1.0.1","dist":{"integrity":"sha512-%s","tarball":"http://%s/npm/test-package/-/test-package-1.0.1.tgz"}},`+
+				`"1.0.2":{"name":"test-package","version":"1.0.2","dist":{"shasum":"%s","tarball":"http://%s/npm/test-package/-/test-package-1.0.2.tgz"}},`+
+				`"1.0.3":{"name":"test-package","version":"1.0.3","dist":{"integrity":"sha512-%s","tarball":"http://%s/npm/test-package/-/test-package-1.0.3.tgz"}}}}`,
+				base64.StdEncoding.EncodeToString(npmSHA512[:]), r.Host, hex.EncodeToString(npmSHA1[:]), r.Host, base64.StdEncoding.EncodeToString(npmSHA512[:]), r.Host)
+		case "/npm/test-package/-/test-package-1.0.1.tgz", "/npm/test-package/-/test-package-1.0.2.tgz":
+			fmt.Fprint(w, content)
+		case "/npm/test-package/-/test-package-1.0.3.tgz":
+			// does not match the integrity of the version
+			fmt.Fprint(w, "tampered")
+		case "/container/token":
+			upstreamTokenRequests.Add(1)
+			assert.Equal(t, "registry", r.URL.Query().Get("service"))
+			assert.Equal(t, "repository:test-image:pull", r.URL.Query().Get("scope"))
+			fmt.Fprintf(w, `{"token":"%s"}`, upstreamToken)
+		case "/container/v2/test-image/manifests/latest":
+			w.Header().Set("Content-Type", oci.MediaTypeImageIndex)
+			fmt.Fprint(w, containerIndex)
+		case "/container/v2/test-image/manifests/" + containerManifestDigest:
+			w.Header().Set("Content-Type", oci.MediaTypeImageManifest)
+			fmt.Fprint(w, containerManifest)
+		case "/container/v2/test-image/blobs/" + containerConfigDigest:
+			fmt.Fprint(w, containerConfig)
+		case "/container/v2/test-image/blobs/" + containerLayerDigest:
+			fmt.Fprint(w, content)
+		case "/container/v2/bad-image/manifests/latest":
+			w.Header().Set("Content-Type", oci.MediaTypeImageManifest)
+			fmt.Fprint(w, containerManifest)
+		case "/container/v2/bad-image/blobs/" + containerConfigDigest:
+			// does not match the digest of the blob
+			fmt.Fprint(w, "tampered")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	addProxy := func(t *testing.T, packageType packages.Type, path string) {
+		_, err := packages.InsertProxy(db.DefaultContext, &packages.PackageProxy{
+			OwnerID: user.ID,
+			Type:    packageType,
+			Enabled: true,
+			URL:     upstream.URL + path,
+		})
+		require.NoError(t, err)
+	}
+
+	assertCachedVersion := func(t *testing.T, packageType packages.Type, name, version string) {
+		pv, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packageType, name, version)
+		require.NoError(t, err)
+
+		pvps, err := packages.GetProperties(db.DefaultContext, packages.PropertyTypeVersion, pv.ID)
+		require.NoError(t, err)
+		require.Len(t, pvps, 1)
+		assert.Equal(t, packages.PropertyProxyUpstream, pvps[0].Name)
+		assert.True(t, strings.HasPrefix(pvps[0].Value, upstream.URL))
+	}
+
+	t.Run("PyPI", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		root := fmt.Sprintf("/api/packages/%s/pypi", user.Name)
+
+		req := NewRequest(t, "GET", root+"/simple/test-package")
+		MakeRequest(t, req, http.StatusNotFound)
+
+		addProxy(t, packages.TypePyPI, "/pypi")
+
+		req = NewRequest(t, "GET", root+"/simple/test-package")
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		body := resp.Body.String()
+		assert.Contains(t, body, fmt.Sprintf(`%s/files/test-package/1.0.1/test_package-1.0.1.tar.gz#sha256=%s`, root, hashSHA256))
+		assert.Contains(t, body, `data-requires-python="&gt;=3.8"`)
+
+		req = NewRequest(t, "GET", root+"/files/test-package/1.0.1/test_package-1.0.1.tar.gz")
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.String())
+
+		assertCachedVersion(t, packages.TypePyPI, "test-package", "1.0.1")
+
+		req = NewRequest(t, "GET", root+"/files/test-package/1.0.2/test_package-1.0.2.tar.gz")
+		MakeRequest(t, req, http.StatusNotFound)
+
+		// The cached file is served without asking the upstream registry
+		count := upstreamRequests.Load()
+		req = NewRequest(t, "GET", root+"/files/test-package/1.0.1/test_package-1.0.1.tar.gz")
+		MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, count, upstreamRequests.Load())
+	})
+
+	t.Run("Maven", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		root := fmt.Sprintf("/api/packages/%s/maven/com/gitea/test-project", user.Name)
+
+		addProxy(t, packages.TypeMaven, "/maven")
+
+		req := NewRequest(t, "GET", root+"/1.0.1/test-project-1.0.1.jar")
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.String())
+
+		assertCachedVersion(t, packages.TypeMaven, "com.gitea-test-project", "1.0.1")
+
+		req = NewRequest(t, "GET", root+"/1.0.1/test-project-1.0.1.pom")
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("Npm", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		root := fmt.Sprintf("/api/packages/%s/npm", user.Name)
+
+		addProxy(t, packages.TypeNpm, "/npm")
+
+		req := NewRequest(t, "GET", root+"/test-package")
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var metadata map[string]any
+		DecodeJSON(t, resp, &metadata)
+		assert.Equal(t, "test-package", metadata["name"])
+		versions := metadata["versions"].(map[string]any)
+		require.Len(t, versions, 3)
+		dist := versions["1.0.1"].(map[string]any)["dist"].(map[string]any)
+		assert.Equal(t, setting.AppURL+"api/packages/"+user.Name+"/npm/test-package/-/1.0.1/test-package-1.0.1.tgz", dist["tarball"])
+
+		req = NewRequest(t, "GET", root+"/test-package/-/1.0.1/test-package-1.0.1.tgz")
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.String())
+		assertCachedVersion(t, packages.TypeNpm, "test-package", "1.0.1")
+
+		// tarballs larger than the npm size limit are not cached
+		func() {
+			defer test.MockVariableValue(&setting.Packages.LimitSizeNpm, int64(len(content)-1))()
+
+			req = NewRequest(t, "GET", root+"/test-package/-/1.0.2/test-package-1.0.2.tgz")
+			MakeRequest(t, req, http.StatusBadGateway)
+			_, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packages.TypeNpm, "test-package", "1.0.2")
+			require.ErrorIs(t, err, packages.ErrPackageNotExist)
+		}()
+
+		// versions without integrity are verified with their shasum
+		req = NewRequest(t, "GET", root+"/test-package/-/1.0.2/test-package-1.0.2.tgz")
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, content, resp.Body.String())
+		assertCachedVersion(t, packages.TypeNpm, "test-package", "1.0.2")
+
+		// tarballs not matching their integrity are not cached
+		req = NewRequest(t, "GET", root+"/test-package/-/1.0.3/test-package-1.0.3.tgz")
+		MakeRequest(t, req, http.StatusBadGateway)
+		_, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packages.TypeNpm, "test-package", "1.0.3")
+		require.ErrorIs(t, err, packages.ErrPackageNotExist)
+
+		req = NewRequest(t, "GET", root+"/test-package/-/1.0.4/test-package-1.0.4.tgz")
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("Container", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		root := fmt.Sprintf("%sv2/%s", setting.AppURL, user.Name)
+
+		req := NewRequest(t, "GET", fmt.Sprintf("%sv2/token", setting.AppURL))
+		resp := MakeRequest(t, req, http.StatusOK)
+		var tokenResponse struct {
+			Token string `json:"token"`
+		}
+		DecodeJSON(t, resp, &tokenResponse)
+		token := "Bearer " + tokenResponse.Token
+
+		addProxy(t, packages.TypeContainer, "/container")
+
+		// the index, its manifests and their blobs are cached at once
+		req = NewRequest(t, "GET", root+"/test-image/manifests/latest").AddTokenAuth(token)
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, containerIndex, resp.Body.String())
+		assert.Equal(t, oci.MediaTypeImageIndex, resp.Header().Get("Content-Type"))
+		assert.EqualValues(t, 1, upstreamTokenRequests.Load())
+
+		pv, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packages.TypeContainer, "test-image", "latest")
+		require.NoError(t, err)
+		pvps, err := packages.GetPropertiesByName(db.DefaultContext, packages.PropertyTypeVersion, pv.ID, packages.PropertyProxyUpstream)
+		require.NoError(t, err)
+		require.Len(t, pvps, 1)
+		assert.True(t, strings.HasPrefix(pvps[0].Value, upstream.URL))
+
+		count := upstreamRequests.Load()
+		req = NewRequest(t, "GET", root+"/test-image/manifests/"+containerManifestDigest).AddTokenAuth(token)
+		resp = MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, containerManifest, resp.Body.String())
+		for digest, blob := range map[string]string{containerConfigDigest: containerConfig, containerLayerDigest: content} {
+			req = NewRequest(t, "GET", root+"/test-image/blobs/"+digest).AddTokenAuth(token)
+			resp = MakeRequest(t, req, http.StatusOK)
+			assert.Equal(t, blob, resp.Body.String())
+		}
+		assert.Equal(t, count, upstreamRequests.Load())
+
+		// blobs not matching their digest are not cached
+		req = NewRequest(t, "GET", root+"/bad-image/manifests/latest").AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusInternalServerError)
+		_, err = container_model.GetContainerBlob(db.DefaultContext, &container_model.BlobSearchOptions{
+			OwnerID: user.ID,
+			Image:   "bad-image",
+			Digest:  containerConfigDigest,
+		})
+		require.ErrorIs(t, err, container_model.ErrContainerBlobNotExist)
+
+		req = NewRequest(t, "GET", root+"/test-image/manifests/unknown").AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		pps, err := packages.GetProxiesByOwner(db.DefaultContext, user.ID)
+		require.NoError(t, err)
+		for _, pp := range pps {
+			pp.Enabled = false
+			require.NoError(t, packages.UpdateProxy(db.DefaultContext, pp))
+		}
+
+		req := NewRequest(t, "GET", fmt.Sprintf("/api/packages/%s/maven/com/gitea/test-project/1.0.2/test-project-1.0.2.jar", user.Name))
+		MakeRequest(t, req, http.StatusNotFound)
+	})
+}
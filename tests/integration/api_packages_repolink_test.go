@@ -0,0 +1,117 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageRepositoryLink(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1, OwnerID: user.ID})
+
+	token := getUserToken(t, user.Name, auth_model.AccessTokenScopeWritePackage)
+
+	getPackage := func(t *testing.T, packageType packages_model.Type, name string) *packages_model.Package {
+		p, err := packages_model.GetPackageByName(db.DefaultContext, user.ID, packageType, name)
+		require.NoError(t, err)
+		return p
+	}
+
+	uploadNpm := func(t *testing.T, name, version, repositoryURL string) {
+		upload := `{
+			"_id": "` + name + `",
+			"name": "` + name + `",
+			"dist-tags": {"latest": "` + version + `"},
+			"versions": {
+				"` + version + `": {
+					"name": "` + name + `",
+					"version": "` + version + `",
+					"dist": {
+						"integrity": "sha512-yA4FJsVhetynGfOC1jFf79BuS+jrHbm0fhh+aHzCQkOaOBXKf9oBnC4a6DnLLnEsHQDRLYd00cwj8sCXpC+wIg==",
+						"shasum": "aaa7eaf852a948b0aa05afeda35b1badca155d90"
+					},
+					"repository": {"type": "git", "url": "` + repositoryURL + `"}
+				}
+			},
+			"_attachments": {
+				"package-` + version + `.tgz": {
+					"data": "H4sIAAAAAAAA/ytITM5OTE/VL4DQelnF+XkMVAYGBgZmJiYK2MRBwNDcSIHB2NTMwNDQzMwAqA7IMDUxA9LUdgg2UFpcklgEdAql5kD8ogCnhwio5lJQUMpLzE1VslJQcihOzi9I1S9JLS7RhSYIJR2QgrLUouLM/DyQGkM9Az1D3YIiqExKanFyUWZBCVQ2BKhVwQVJDKwosbQkI78IJO/tZ+LsbRykxFXLNdA+HwWjYBSMgpENACgAbtAACAAA"
+				}
+			}
+		}`
+
+		req := NewRequestWithBody(t, "PUT", fmt.Sprintf("/api/packages/%s/npm/%s", user.Name, url.QueryEscape(name)), strings.NewReader(upload)).
+			AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusCreated)
+	}
+
+	t.Run("Metadata", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		uploadNpm(t, "@scope/linked", "1.0.0", "git+"+setting.AppURL+repo.FullName()+".git")
+		assert.EqualValues(t, repo.ID, getPackage(t, packages_model.TypeNpm, "@scope/linked").RepoID)
+
+		uploadNpm(t, "@scope/other-owner", "1.0.0", setting.AppURL+"user3/repo3.git")
+		assert.EqualValues(t, 0, getPackage(t, packages_model.TypeNpm, "@scope/other-owner").RepoID)
+
+		uploadNpm(t, "@scope/external", "1.0.0", "https://example.com/"+repo.FullName()+".git")
+		assert.EqualValues(t, 0, getPackage(t, packages_model.TypeNpm, "@scope/external").RepoID)
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		actionsToken := NewActionsUserTestContext(t, user.Name, repo.Name).Token
+
+		req := NewRequestWithBody(t, "PUT", fmt.Sprintf("/api/packages/%s/generic/release-asset/1.1/asset.bin", user.Name), bytes.NewReader([]byte{1, 2, 3})).
+			AddTokenAuth(actionsToken)
+		MakeRequest(t, req, http.StatusCreated)
+
+		assert.EqualValues(t, repo.ID, getPackage(t, packages_model.TypeGeneric, "release-asset").RepoID)
+	})
+
+	t.Run("ManualLinkIsKept", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		uploadNpm(t, "@scope/linked", "1.0.1", setting.AppURL+"user2/repo2.git")
+		assert.EqualValues(t, repo.ID, getPackage(t, packages_model.TypeNpm, "@scope/linked").RepoID)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("/%s/releases/tag/v1.1", repo.FullName()))
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		htmlDoc := NewHTMLParser(t, resp.Body)
+		htmlDoc.AssertElement(t, fmt.Sprintf(`#release-list a[href="/%s/-/packages/generic/release-asset/1.1"]`, user.Name), true)
+		htmlDoc.AssertElement(t, `#release-list a[href$="/-/packages/npm/%40scope%2Flinked/1.0.0"]`, false)
+
+		req = NewRequest(t, "GET", fmt.Sprintf("/%s/releases", repo.FullName()))
+		resp = MakeRequest(t, req, http.StatusOK)
+
+		htmlDoc = NewHTMLParser(t, resp.Body)
+		assert.Equal(t, 1, htmlDoc.Find(`#release-list a[href$="/-/packages/generic/release-asset/1.1"]`).Length())
+	})
+}
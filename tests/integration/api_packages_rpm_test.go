@@ -417,6 +417,98 @@ gpgkey=%sapi/packages/%s/rpm/repository.key`,
 				})
 			})
 
+			t.Run("SnapshotAndPromote", func(t *testing.T) {
+				defer tests.PrintCurrentTest(t)()
+
+				snapshotURL := rootURL + "/-/snapshots/release"
+				promotedGroup := strings.Trim(group+"/promoted", "/")
+				promotedURL := rootURL + "/" + promotedGroup
+
+				req := NewRequest(t, "PUT", snapshotURL+"?group="+group)
+				MakeRequest(t, req, http.StatusUnauthorized)
+
+				req = NewRequest(t, "PUT", rootURL+"/-/snapshots/release?group=unknown").
+					AddBasicAuth(user.Name)
+				MakeRequest(t, req, http.StatusNotFound)
+
+				req = NewRequest(t, "PUT", snapshotURL+"?group="+group).
+					AddBasicAuth(user.Name)
+				resp := MakeRequest(t, req, http.StatusCreated)
+
+				var snapshot struct {
+					Name  string `json:"name"`
+					Group string `json:"group"`
+				}
+				DecodeJSON(t, resp, &snapshot)
+				assert.Equal(t, "release", snapshot.Name)
+				assert.Equal(t, group, snapshot.Group)
+
+				req = NewRequest(t, "PUT", snapshotURL+"?group="+group).
+					AddBasicAuth(user.Name)
+				MakeRequest(t, req, http.StatusConflict)
+
+				req = NewRequest(t, "GET", rootURL+"/-/snapshots")
+				resp = MakeRequest(t, req, http.StatusOK)
+				assert.Contains(t, resp.Body.String(), `"name":"release"`)
+
+				req = NewRequest(t, "GET", snapshotURL+"/snapshot.repo")
+				resp = MakeRequest(t, req, http.StatusOK)
+				assert.Contains(t, resp.Body.String(), "baseurl="+util.URLJoin(setting.AppURL, snapshotURL))
+
+				req = NewRequest(t, "HEAD", snapshotURL+"/repodata/repomd.xml")
+				MakeRequest(t, req, http.StatusOK)
+
+				req = NewRequest(t, "GET", snapshotURL+"/repodata/primary.xml.gz")
+				MakeRequest(t, req, http.StatusOK)
+
+				packageURL := fmt.Sprintf("/package/%s/%s/%s/%s-%s.%s.rpm", packageName, packageVersion, packageArchitecture, packageName, packageVersion, packageArchitecture)
+
+				req = NewRequest(t, "GET", snapshotURL+packageURL)
+				resp = MakeRequest(t, req, http.StatusOK)
+				assert.Equal(t, content, resp.Body.Bytes())
+
+				promote := func(from, to string) *RequestWrapper {
+					return NewRequestWithJSON(t, "POST", rootURL+"/-/promote", map[string]any{
+						"from": from,
+						"to":   to,
+						"packages": []map[string]string{
+							{"name": packageName, "version": packageVersion},
+						},
+					})
+				}
+
+				MakeRequest(t, promote(group, promotedGroup), http.StatusUnauthorized)
+				MakeRequest(t, promote(group, group).AddBasicAuth(user.Name), http.StatusBadRequest)
+				MakeRequest(t, promote("unknown", promotedGroup).AddBasicAuth(user.Name), http.StatusNotFound)
+				MakeRequest(t, promote(group, promotedGroup).AddBasicAuth(user.Name), http.StatusNoContent)
+				MakeRequest(t, promote(group, promotedGroup).AddBasicAuth(user.Name), http.StatusNoContent)
+
+				req = NewRequest(t, "GET", promotedURL+"/repodata/repomd.xml")
+				MakeRequest(t, req, http.StatusOK)
+
+				req = NewRequest(t, "GET", promotedURL+packageURL)
+				resp = MakeRequest(t, req, http.StatusOK)
+				assert.Equal(t, content, resp.Body.Bytes())
+
+				// The snapshot is not affected by changes of the group
+				req = NewRequest(t, "DELETE", fmt.Sprintf("%s/package/%s/%s/%s", promotedURL, packageName, packageVersion, packageArchitecture)).
+					AddBasicAuth(user.Name)
+				MakeRequest(t, req, http.StatusNoContent)
+
+				req = NewRequest(t, "GET", snapshotURL+packageURL)
+				MakeRequest(t, req, http.StatusOK)
+
+				req = NewRequest(t, "DELETE", snapshotURL)
+				MakeRequest(t, req, http.StatusUnauthorized)
+
+				req = NewRequest(t, "DELETE", snapshotURL).
+					AddBasicAuth(user.Name)
+				MakeRequest(t, req, http.StatusNoContent)
+
+				req = NewRequest(t, "GET", snapshotURL+"/repodata/repomd.xml")
+				MakeRequest(t, req, http.StatusNotFound)
+			})
+
 			t.Run("Delete", func(t *testing.T) {
 				defer tests.PrintCurrentTest(t)()
 
@@ -0,0 +1,311 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	terraform_module "code.gitea.io/gitea/modules/packages/terraform"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageTerraform(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	token := "Bearer " + getUserToken(t, user.Name, auth_model.AccessTokenScopeWritePackage)
+
+	root := fmt.Sprintf("/api/packages/%s/terraform", user.Name)
+
+	t.Run("ServiceDiscovery", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", "/.well-known/terraform.json")
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var result map[string]string
+		DecodeJSON(t, resp, &result)
+		assert.Equal(t, setting.AppSubURL+"/api/packages/-/terraform/modules/v1/", result["modules.v1"])
+		assert.Equal(t, setting.AppSubURL+"/api/packages/-/terraform/providers/v1/", result["providers.v1"])
+	})
+
+	t.Run("Module", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		readme := "# Consul\n\nDeploys a Consul cluster."
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		archive := tar.NewWriter(zw)
+		archive.WriteHeader(&tar.Header{
+			Name: "README.md",
+			Mode: 0o600,
+			Size: int64(len(readme)),
+		})
+		archive.Write([]byte(readme))
+		archive.Close()
+		zw.Close()
+		content := buf.Bytes()
+
+		moduleURL := fmt.Sprintf("%s/modules/consul/aws", root)
+		registryURL := fmt.Sprintf("/api/packages/-/terraform/modules/v1/%s/consul/aws", user.Name)
+
+		t.Run("Upload", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequestWithBody(t, "PUT", moduleURL+"/1.0.0", bytes.NewReader(content))
+			MakeRequest(t, req, http.StatusUnauthorized)
+
+			req = NewRequestWithBody(t, "PUT", moduleURL+"/invalid", bytes.NewReader(content)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusBadRequest)
+
+			req = NewRequestWithBody(t, "PUT", moduleURL+"/1.0.0", bytes.NewReader([]byte("not a tarball"))).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusBadRequest)
+
+			req = NewRequestWithBody(t, "PUT", moduleURL+"/1.0.0", bytes.NewReader(content)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			pv, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packages.TypeTerraform, "consul/aws", "1.0.0")
+			require.NoError(t, err)
+			pd, err := packages.GetPackageDescriptor(db.DefaultContext, pv)
+			require.NoError(t, err)
+			assert.IsType(t, &terraform_module.Metadata{}, pd.Metadata)
+			metadata := pd.Metadata.(*terraform_module.Metadata)
+			assert.Equal(t, terraform_module.KindModule, metadata.Kind)
+			assert.Equal(t, "Deploys a Consul cluster.", metadata.Description)
+			assert.Len(t, pd.Files, 1)
+			assert.Equal(t, terraform_module.ModuleArchiveFilename, pd.Files[0].File.Name)
+
+			req = NewRequestWithBody(t, "PUT", moduleURL+"/1.0.0", bytes.NewReader(content)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusConflict)
+
+			req = NewRequestWithBody(t, "PUT", moduleURL+"/1.1.0", bytes.NewReader(content)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+		})
+
+		t.Run("ListVersions", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "GET", registryURL+"/versions")
+			resp := MakeRequest(t, req, http.StatusOK)
+
+			var result struct {
+				Modules []struct {
+					Versions []struct {
+						Version string `json:"version"`
+					} `json:"versions"`
+				} `json:"modules"`
+			}
+			DecodeJSON(t, resp, &result)
+			require.Len(t, result.Modules, 1)
+			require.Len(t, result.Modules[0].Versions, 2)
+			assert.Equal(t, "1.0.0", result.Modules[0].Versions[0].Version)
+			assert.Equal(t, "1.1.0", result.Modules[0].Versions[1].Version)
+
+			req = NewRequest(t, "GET", fmt.Sprintf("/api/packages/-/terraform/modules/v1/%s/unknown/aws/versions", user.Name))
+			MakeRequest(t, req, http.StatusNotFound)
+		})
+
+		t.Run("Download", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "GET", registryURL+"/1.0.0/download")
+			resp := MakeRequest(t, req, http.StatusNoContent)
+			location := resp.Header().Get("X-Terraform-Get")
+			assert.Equal(t, fmt.Sprintf("%sapi/packages/%s/terraform/modules/consul/aws/1.0.0?archive=tar.gz", setting.AppURL, user.Name), location)
+
+			req = NewRequest(t, "GET", strings.TrimPrefix(location, setting.AppURL[:len(setting.AppURL)-1]))
+			resp = MakeRequest(t, req, http.StatusOK)
+			assert.Equal(t, content, resp.Body.Bytes())
+
+			req = NewRequest(t, "GET", registryURL+"/2.0.0/download")
+			MakeRequest(t, req, http.StatusNotFound)
+		})
+
+		t.Run("Delete", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "DELETE", moduleURL+"/1.1.0")
+			MakeRequest(t, req, http.StatusUnauthorized)
+
+			req = NewRequest(t, "DELETE", moduleURL+"/1.1.0").
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusNoContent)
+
+			_, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packages.TypeTerraform, "consul/aws", "1.1.0")
+			require.ErrorIs(t, err, packages.ErrPackageNotExist)
+		})
+	})
+
+	t.Run("Provider", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		createArchive := func(filename string) []byte {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			w, _ := zw.Create(filename)
+			w.Write([]byte(filename))
+			zw.Close()
+			return buf.Bytes()
+		}
+		linuxContent := createArchive("terraform-provider-random_v1.2.0")
+		darwinContent := createArchive("terraform-provider-random")
+
+		providerURL := fmt.Sprintf("%s/providers/random/1.2.0", root)
+		registryURL := fmt.Sprintf("/api/packages/-/terraform/providers/v1/%s/random", user.Name)
+
+		t.Run("Upload", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequestWithBody(t, "PUT", providerURL+"/linux/amd64", bytes.NewReader(linuxContent))
+			MakeRequest(t, req, http.StatusUnauthorized)
+
+			req = NewRequestWithBody(t, "PUT", providerURL+"/linux/amd64", bytes.NewReader(createArchive("other"))).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusBadRequest)
+
+			req = NewRequestWithBody(t, "PUT", providerURL+"/linux/amd64?protocols=5.0,6.0", bytes.NewReader(linuxContent)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			req = NewRequestWithBody(t, "PUT", providerURL+"/darwin/arm64", bytes.NewReader(darwinContent)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			req = NewRequestWithBody(t, "PUT", providerURL+"/darwin/arm64", bytes.NewReader(darwinContent)).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusConflict)
+
+			// the protocols are those of the version, which were set by the first upload
+			req = NewRequestWithBody(t, "PUT", providerURL+"/windows/amd64?protocols=6.0", bytes.NewReader(createArchive("terraform-provider-random.exe"))).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusBadRequest)
+
+			pv, err := packages.GetVersionByNameAndVersion(db.DefaultContext, user.ID, packages.TypeTerraform, "random", "1.2.0")
+			require.NoError(t, err)
+			pd, err := packages.GetPackageDescriptor(db.DefaultContext, pv)
+			require.NoError(t, err)
+			metadata := pd.Metadata.(*terraform_module.Metadata)
+			assert.Equal(t, terraform_module.KindProvider, metadata.Kind)
+			assert.Equal(t, []string{"5.0", "6.0"}, metadata.Protocols)
+			require.Len(t, pd.Files, 2)
+			for _, pfd := range pd.Files {
+				assert.Equal(t, pfd.File.Name == "terraform-provider-random_1.2.0_linux_amd64.zip", pfd.File.IsLead)
+			}
+		})
+
+		t.Run("ListVersions", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "GET", registryURL+"/versions")
+			resp := MakeRequest(t, req, http.StatusOK)
+
+			var result struct {
+				Versions []struct {
+					Version   string   `json:"version"`
+					Protocols []string `json:"protocols"`
+					Platforms []struct {
+						OS   string `json:"os"`
+						Arch string `json:"arch"`
+					} `json:"platforms"`
+				} `json:"versions"`
+			}
+			DecodeJSON(t, resp, &result)
+			require.Len(t, result.Versions, 1)
+			assert.Equal(t, "1.2.0", result.Versions[0].Version)
+			assert.Equal(t, []string{"5.0", "6.0"}, result.Versions[0].Protocols)
+			assert.Len(t, result.Versions[0].Platforms, 2)
+		})
+
+		t.Run("Download", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "GET", registryURL+"/1.2.0/download/linux/amd64")
+			resp := MakeRequest(t, req, http.StatusOK)
+
+			var result struct {
+				Protocols           []string `json:"protocols"`
+				OS                  string   `json:"os"`
+				Arch                string   `json:"arch"`
+				Filename            string   `json:"filename"`
+				DownloadURL         string   `json:"download_url"`
+				SHASumsURL          string   `json:"shasums_url"`
+				SHASumsSignatureURL string   `json:"shasums_signature_url"`
+				SHASum              string   `json:"shasum"`
+				SigningKeys         struct {
+					GPGPublicKeys []struct {
+						KeyID      string `json:"key_id"`
+						ASCIIArmor string `json:"ascii_armor"`
+					} `json:"gpg_public_keys"`
+				} `json:"signing_keys"`
+			}
+			DecodeJSON(t, resp, &result)
+
+			linuxSum := sha256.Sum256(linuxContent)
+			darwinSum := sha256.Sum256(darwinContent)
+			assert.Equal(t, "linux", result.OS)
+			assert.Equal(t, "amd64", result.Arch)
+			assert.Equal(t, "terraform-provider-random_1.2.0_linux_amd64.zip", result.Filename)
+			assert.Equal(t, hex.EncodeToString(linuxSum[:]), result.SHASum)
+			require.Len(t, result.SigningKeys.GPGPublicKeys, 1)
+
+			appURL := setting.AppURL[:len(setting.AppURL)-1]
+
+			req = NewRequest(t, "GET", strings.TrimPrefix(result.DownloadURL, appURL))
+			resp = MakeRequest(t, req, http.StatusOK)
+			assert.Equal(t, linuxContent, resp.Body.Bytes())
+
+			req = NewRequest(t, "GET", strings.TrimPrefix(result.SHASumsURL, appURL))
+			resp = MakeRequest(t, req, http.StatusOK)
+			shasums := resp.Body.Bytes()
+			assert.Equal(t, fmt.Sprintf("%x  terraform-provider-random_1.2.0_darwin_arm64.zip\n%x  terraform-provider-random_1.2.0_linux_amd64.zip\n", darwinSum, linuxSum), string(shasums))
+
+			req = NewRequest(t, "GET", strings.TrimPrefix(result.SHASumsSignatureURL, appURL))
+			resp = MakeRequest(t, req, http.StatusOK)
+
+			keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(result.SigningKeys.GPGPublicKeys[0].ASCIIArmor))
+			require.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("%016X", keyring[0].PrimaryKey.KeyId), result.SigningKeys.GPGPublicKeys[0].KeyID)
+			_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(shasums), resp.Body, nil)
+			require.NoError(t, err)
+
+			req = NewRequest(t, "GET", registryURL+"/1.2.0/download/windows/amd64")
+			MakeRequest(t, req, http.StatusNotFound)
+		})
+
+		t.Run("Delete", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			req := NewRequest(t, "DELETE", providerURL).
+				AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusNoContent)
+
+			req = NewRequest(t, "GET", registryURL+"/versions")
+			MakeRequest(t, req, http.StatusNotFound)
+		})
+	})
+}
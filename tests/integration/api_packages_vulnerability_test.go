@@ -0,0 +1,209 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	packages_model "code.gitea.io/gitea/models/packages"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageVulnerability(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	admin := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 1})
+	user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+
+	token := getUserToken(t, user.Name, auth_model.AccessTokenScopeWritePackage)
+
+	packageName := "@scope/vulnerable-package"
+	packageVersion := "1.0.0"
+	filename := "vulnerable-package-" + packageVersion + ".tgz"
+	root := fmt.Sprintf("/api/packages/%s/npm/%s", user.Name, url.QueryEscape(packageName))
+
+	advisories := map[string]string{
+		"GHSA-crit-crit-crit.json": `{
+			"id": "GHSA-crit-crit-crit",
+			"modified": "2024-01-01T00:00:00Z",
+			"summary": "Remote code execution in lodash",
+			"affected": [{"package": {"ecosystem": "npm", "name": "lodash"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]}],
+			"database_specific": {"severity": "CRITICAL"}
+		}`,
+		"GHSA-low0-low0-low0.json": `{
+			"id": "GHSA-low0-low0-low0",
+			"modified": "2024-01-01T00:00:00Z",
+			"summary": "Information disclosure",
+			"affected": [{"package": {"ecosystem": "npm", "name": "@scope/vulnerable-package"}, "versions": ["1.0.0"]}],
+			"severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N"}]
+		}`,
+		"GHSA-gone-gone-gone.json": `{
+			"id": "GHSA-gone-gone-gone",
+			"modified": "2024-01-01T00:00:00Z",
+			"withdrawn": "2024-01-02T00:00:00Z",
+			"affected": [{"package": {"ecosystem": "npm", "name": "lodash"}, "versions": ["4.17.15"]}]
+		}`,
+		"GHSA-debi-debi-debi.json": `{
+			"id": "GHSA-debi-debi-debi",
+			"modified": "2024-01-01T00:00:00Z",
+			"affected": [{"package": {"ecosystem": "Debian:12", "name": "lodash"}, "versions": ["4.17.15"]}]
+		}`,
+	}
+
+	t.Run("ImportAdvisories", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		for name, content := range advisories {
+			w, err := zw.Create("advisories/" + name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+
+		upload := func(t *testing.T, content []byte) {
+			session := loginUser(t, admin.Name)
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			require.NoError(t, writer.WriteField("_csrf", GetCSRF(t, session, "/admin/packages")))
+			part, err := writer.CreateFormFile("file", "all.zip")
+			require.NoError(t, err)
+			_, err = part.Write(content)
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			req := NewRequestWithBody(t, "POST", "/admin/packages/advisories", body)
+			req.Header.Add("Content-Type", writer.FormDataContentType())
+			session.MakeRequest(t, req, http.StatusSeeOther)
+		}
+
+		upload(t, []byte("not a zip archive"))
+
+		count, err := packages_model.CountAdvisories(db.DefaultContext)
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, count)
+
+		upload(t, archive.Bytes())
+
+		count, err = packages_model.CountAdvisories(db.DefaultContext)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+
+		pas, err := packages_model.GetAdvisoriesByPackage(db.DefaultContext, "npm", "lodash")
+		require.NoError(t, err)
+		require.Len(t, pas, 1)
+		assert.Equal(t, "GHSA-crit-crit-crit", pas[0].AdvisoryID)
+		assert.Equal(t, "critical", pas[0].Severity)
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		upload := `{
+			"_id": "` + packageName + `",
+			"name": "` + packageName + `",
+			"dist-tags": {"latest": "` + packageVersion + `"},
+			"versions": {
+				"` + packageVersion + `": {
+					"name": "` + packageName + `",
+					"version": "` + packageVersion + `",
+					"dependencies": {"lodash": "^4.17.15", "left-pad": "^1.3.0"},
+					"dist": {
+						"integrity": "sha512-yA4FJsVhetynGfOC1jFf79BuS+jrHbm0fhh+aHzCQkOaOBXKf9oBnC4a6DnLLnEsHQDRLYd00cwj8sCXpC+wIg==",
+						"shasum": "aaa7eaf852a948b0aa05afeda35b1badca155d90"
+					}
+				}
+			},
+			"_attachments": {
+				"` + filename + `": {
+					"data": "H4sIAAAAAAAA/ytITM5OTE/VL4DQelnF+XkMVAYGBgZmJiYK2MRBwNDcSIHB2NTMwNDQzMwAqA7IMDUxA9LUdgg2UFpcklgEdAql5kD8ogCnhwio5lJQUMpLzE1VslJQcihOzi9I1S9JLS7RhSYIJR2QgrLUouLM/DyQGkM9Az1D3YIiqExKanFyUWZBCVQ2BKhVwQVJDKwosbQkI78IJO/tZ+LsbRykxFXLNdA+HwWjYBSMgpENACgAbtAACAAA"
+				}
+			}
+		}`
+
+		req := NewRequestWithBody(t, "PUT", root, strings.NewReader(upload)).
+			AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusCreated)
+	})
+
+	t.Run("API", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("/api/v1/packages/%s/npm/%s/%s/vulnerabilities", user.Name, url.PathEscape(packageName), packageVersion)).
+			AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		var vulnerabilities []*api.PackageVulnerability
+		DecodeJSON(t, resp, &vulnerabilities)
+		require.Len(t, vulnerabilities, 2)
+
+		assert.Equal(t, "GHSA-crit-crit-crit", vulnerabilities[0].AdvisoryID)
+		assert.Equal(t, "critical", vulnerabilities[0].Severity)
+		assert.Equal(t, "lodash", vulnerabilities[0].PackageName)
+		assert.Equal(t, "4.17.15", vulnerabilities[0].PackageVersion)
+		assert.Equal(t, "https://osv.dev/vulnerability/GHSA-crit-crit-crit", vulnerabilities[0].URL)
+
+		assert.Equal(t, "GHSA-low0-low0-low0", vulnerabilities[1].AdvisoryID)
+		assert.Equal(t, "low", vulnerabilities[1].Severity)
+		assert.Equal(t, packageName, vulnerabilities[1].PackageName)
+	})
+
+	t.Run("View", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", fmt.Sprintf("/%s/-/packages/npm/%s/%s", user.Name, url.PathEscape(packageName), packageVersion))
+		resp := MakeRequest(t, req, http.StatusOK)
+
+		htmlDoc := NewHTMLParser(t, resp.Body)
+		htmlDoc.AssertElement(t, `a[href="https://osv.dev/vulnerability/GHSA-crit-crit-crit"]`, true)
+		htmlDoc.AssertElement(t, `a[href="https://osv.dev/vulnerability/GHSA-low0-low0-low0"]`, true)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		url := fmt.Sprintf("%s/-/%s/%s", root, packageVersion, filename)
+
+		req := NewRequest(t, "GET", url).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusOK)
+
+		defer test.MockVariableValue(&setting.Packages.BlockCriticalVulnerabilities, true)()
+
+		req = NewRequest(t, "GET", url).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusForbidden)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		pvs, err := packages_model.GetVersionsByPackageType(db.DefaultContext, user.ID, packages_model.TypeNpm)
+		require.NoError(t, err)
+		require.Len(t, pvs, 1)
+
+		req := NewRequest(t, "DELETE", root+"/-rev/dummy").AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusOK)
+
+		unittest.AssertNotExistsBean(t, &packages_model.PackageVulnerability{VersionID: pvs[0].ID})
+	})
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/organization"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/scim"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateSCIMToken(t *testing.T, source *auth_model.Source) string {
+	t.Helper()
+	session := loginUser(t, "user1")
+	link := fmt.Sprintf("/admin/auths/%d", source.ID)
+	req := NewRequestWithValues(t, "POST", link+"/scim_token", map[string]string{
+		"_csrf": GetCSRF(t, session, link),
+	})
+	session.MakeRequest(t, req, http.StatusSeeOther)
+
+	resp := session.MakeRequest(t, NewRequest(t, "GET", link), http.StatusOK)
+	token := NewHTMLParser(t, resp.Body).doc.Find(".ui.info p").Text()
+	require.Len(t, token, 40)
+	return token
+}
+
+func TestAPISCIM(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	payload := authSourcePayloadGitHub("scim-github")
+	payload["oauth2_group_team_map"] = `{"developers": {"org3": ["team1"]}}`
+	source := addAuthSource(t, payload)
+	token := generateSCIMToken(t, source)
+
+	var userID, groupID string
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		MakeRequest(t, NewRequest(t, "GET", "/scim/v2/Users"), http.StatusUnauthorized)
+		resp := MakeRequest(t, NewRequest(t, "GET", "/scim/v2/Users").AddTokenAuth("0123456789012345678901234567890123456789"), http.StatusUnauthorized)
+		assert.Equal(t, scim.ContentType+";charset=utf-8", resp.Header().Get("Content-Type"))
+		var scimErr scim.Error
+		DecodeJSON(t, resp, &scimErr)
+		assert.Equal(t, "401", scimErr.Status)
+	})
+
+	t.Run("CreateUser", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequestWithJSON(t, "POST", "/scim/v2/Users", &scim.User{
+			Schemas:  []string{scim.SchemaUser},
+			UserName: "scim-alice@example.com",
+			Name:     &scim.Name{GivenName: "Alice", FamilyName: "Liddell"},
+			Emails:   []scim.MultiValue{{Value: "scim-alice@example.com", Primary: true}},
+		}).AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusCreated)
+		var su scim.User
+		DecodeJSON(t, resp, &su)
+		userID = su.ID
+		assert.Equal(t, su.Meta.Location, resp.Header().Get("Location"))
+		assert.Equal(t, "scim-alice@example.com", su.UserName)
+		assert.True(t, su.IsActive())
+
+		u := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "scim-alice"})
+		assert.Equal(t, userID, fmt.Sprint(u.ID))
+		assert.Equal(t, "Alice Liddell", u.FullName)
+		assert.Equal(t, source.ID, u.LoginSource)
+		assert.Equal(t, "scim-alice@example.com", u.LoginName)
+
+		MakeRequest(t, req, http.StatusConflict)
+	})
+
+	t.Run("FindUsers", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "scim-alice@example.com"`)).AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusOK)
+		var list struct {
+			TotalResults int64
+			Resources    []scim.User
+		}
+		DecodeJSON(t, resp, &list)
+		assert.EqualValues(t, 1, list.TotalResults)
+		require.Len(t, list.Resources, 1)
+		assert.Equal(t, userID, list.Resources[0].ID)
+
+		req = NewRequest(t, "GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName sw "scim"`)).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusBadRequest)
+
+		// users of other sources are not visible
+		MakeRequest(t, NewRequest(t, "GET", "/scim/v2/Users/2").AddTokenAuth(token), http.StatusNotFound)
+
+		t.Run("Count", func(t *testing.T) {
+			defer test.MockVariableValue(&setting.API.MaxResponseItems, 1)()
+
+			req := NewRequestWithJSON(t, "POST", "/scim/v2/Users", &scim.User{
+				Schemas:  []string{scim.SchemaUser},
+				UserName: "scim-bob@example.com",
+				Emails:   []scim.MultiValue{{Value: "scim-bob@example.com", Primary: true}},
+			}).AddTokenAuth(token)
+			MakeRequest(t, req, http.StatusCreated)
+
+			// the count is limited like in the API
+			req = NewRequest(t, "GET", "/scim/v2/Users?count=100").AddTokenAuth(token)
+			resp := MakeRequest(t, req, http.StatusOK)
+			var list struct {
+				TotalResults int64
+				ItemsPerPage int
+				Resources    []scim.User
+			}
+			DecodeJSON(t, resp, &list)
+			assert.EqualValues(t, 2, list.TotalResults)
+			assert.Equal(t, 1, list.ItemsPerPage)
+			assert.Len(t, list.Resources, 1)
+		})
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequestWithJSON(t, "POST", "/scim/v2/Groups", &scim.Group{
+			Schemas:     []string{scim.SchemaGroup},
+			DisplayName: "developers",
+			Members:     []scim.MultiValue{{Value: userID}},
+		}).AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusCreated)
+		var sg scim.Group
+		DecodeJSON(t, resp, &sg)
+		groupID = sg.ID
+		require.Len(t, sg.Members, 1)
+
+		u := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "scim-alice"})
+		unittest.AssertExistsAndLoadBean(t, &organization.TeamUser{TeamID: 2, UID: u.ID})
+
+		resp = MakeRequest(t, NewRequest(t, "GET", "/scim/v2/Users/"+userID).AddTokenAuth(token), http.StatusOK)
+		var su scim.User
+		DecodeJSON(t, resp, &su)
+		require.Len(t, su.Groups, 1)
+		assert.Equal(t, "developers", su.Groups[0].Display)
+
+		req = NewRequestWithJSON(t, "PATCH", "/scim/v2/Groups/"+groupID, &scim.PatchOp{
+			Schemas:    []string{scim.SchemaPatchOp},
+			Operations: []scim.Operation{{Op: "remove", Path: fmt.Sprintf("members[value eq %q]", userID)}},
+		}).AddTokenAuth(token)
+		resp = MakeRequest(t, req, http.StatusOK)
+		DecodeJSON(t, resp, &sg)
+		assert.Empty(t, sg.Members)
+		unittest.AssertNotExistsBean(t, &organization.TeamUser{TeamID: 2, UID: u.ID})
+
+		req = NewRequestWithJSON(t, "PATCH", "/scim/v2/Groups/"+groupID, &scim.PatchOp{
+			Schemas:    []string{scim.SchemaPatchOp},
+			Operations: []scim.Operation{{Op: "add", Path: "members", Value: []map[string]string{{"value": userID}}}},
+		}).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusOK)
+		unittest.AssertExistsAndLoadBean(t, &organization.TeamUser{TeamID: 2, UID: u.ID})
+
+		req = NewRequestWithJSON(t, "PATCH", "/scim/v2/Groups/"+groupID, &scim.PatchOp{
+			Schemas:    []string{scim.SchemaPatchOp},
+			Operations: []scim.Operation{{Op: "add", Path: "members", Value: []map[string]string{{"value": "2"}}}},
+		}).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusBadRequest)
+	})
+
+	t.Run("Deactivate", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequestWithJSON(t, "PATCH", "/scim/v2/Users/"+userID, &scim.PatchOp{
+			Schemas:    []string{scim.SchemaPatchOp},
+			Operations: []scim.Operation{{Op: "Replace", Value: map[string]any{"active": "False"}}},
+		}).AddTokenAuth(token)
+		resp := MakeRequest(t, req, http.StatusOK)
+		var su scim.User
+		DecodeJSON(t, resp, &su)
+		assert.False(t, su.IsActive())
+		u := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "scim-alice"})
+		assert.True(t, u.ProhibitLogin)
+
+		req = NewRequestWithJSON(t, "PATCH", "/scim/v2/Users/"+userID, &scim.PatchOp{
+			Schemas:    []string{scim.SchemaPatchOp},
+			Operations: []scim.Operation{{Op: "replace", Path: "active", Value: true}},
+		}).AddTokenAuth(token)
+		MakeRequest(t, req, http.StatusOK)
+		u = unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "scim-alice"})
+		assert.False(t, u.ProhibitLogin)
+	})
+
+	t.Run("Deprovision", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		MakeRequest(t, NewRequest(t, "DELETE", "/scim/v2/Users/"+userID).AddTokenAuth(token), http.StatusNoContent)
+		u := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "scim-alice"})
+		assert.True(t, u.ProhibitLogin)
+		unittest.AssertNotExistsBean(t, &organization.TeamUser{TeamID: 2, UID: u.ID})
+		unittest.AssertNotExistsBean(t, &auth_model.SCIMGroupMember{UserID: u.ID})
+
+		MakeRequest(t, NewRequest(t, "DELETE", "/scim/v2/Groups/"+groupID).AddTokenAuth(token), http.StatusNoContent)
+		MakeRequest(t, NewRequest(t, "GET", "/scim/v2/Groups/"+groupID).AddTokenAuth(token), http.StatusNotFound)
+	})
+}
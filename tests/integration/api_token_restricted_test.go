@@ -0,0 +1,216 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/lfs"
+	"code.gitea.io/gitea/modules/setting"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createRestrictedAccessToken(t *testing.T, payload map[string]any, expectedStatus int) *api.AccessToken {
+	t.Helper()
+	req := NewRequestWithJSON(t, "POST", "/api/v1/users/user2/tokens", payload).AddBasicAuth("user2")
+	resp := MakeRequest(t, req, expectedStatus)
+	if expectedStatus != http.StatusCreated {
+		return nil
+	}
+	var token api.AccessToken
+	DecodeJSON(t, resp, &token)
+	return &token
+}
+
+func TestAPIRepoRestrictedToken(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	t.Run("Repositories", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		token := createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-repositories",
+			"scopes":       []string{"write:repository", "read:issue"},
+			"repositories": []string{"user2/repo1"},
+		}, http.StatusCreated)
+		assert.Equal(t, []string{"user2/repo1"}, token.Repositories)
+
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/user2/repo1").AddTokenAuth(token.Token), http.StatusOK)
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/user2/repo1/issues").AddTokenAuth(token.Token), http.StatusOK)
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/user2/repo2").AddTokenAuth(token.Token), http.StatusNotFound)
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/org3/repo3").AddTokenAuth(token.Token), http.StatusNotFound)
+
+		// routes which are not the ones of a repository are forbidden
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/search").AddTokenAuth(token.Token), http.StatusForbidden)
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/issues/search").AddTokenAuth(token.Token), http.StatusForbidden)
+	})
+
+	t.Run("LFS", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+		defer test.MockVariableValue(&setting.LFS.StartServer, true)()
+
+		token := createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-lfs",
+			"scopes":       []string{"write:repository"},
+			"repositories": []string{"user2/repo1"},
+		}, http.StatusCreated)
+
+		listLocks := func(repo string, expectedStatus int) {
+			t.Helper()
+			req := NewRequestf(t, "GET", "/%s.git/info/lfs/locks", repo).
+				SetHeader("Accept", lfs.MediaType).
+				AddTokenAuth(token.Token)
+			MakeRequest(t, req, expectedStatus)
+		}
+		batch := func(repo string, expectedStatus int) {
+			t.Helper()
+			req := NewRequestWithJSON(t, "POST", "/"+repo+".git/info/lfs/objects/batch", &lfs.BatchRequest{
+				Operation: "upload",
+				Objects:   []lfs.Pointer{{Oid: "fb8f7d8435968c4f82a726a92395be4d16f2f63116caf36c8ad35c60831ab041", Size: 6}},
+			}).
+				SetHeader("Accept", lfs.AcceptHeader).
+				SetHeader("Content-Type", lfs.MediaType).
+				AddTokenAuth(token.Token)
+			MakeRequest(t, req, expectedStatus)
+		}
+
+		listLocks("user2/repo1", http.StatusOK)
+		batch("user2/repo1", http.StatusOK)
+
+		// user2 can write to repo2, but the token is not allowed to
+		listLocks("user2/repo2", http.StatusUnauthorized)
+		batch("user2/repo2", http.StatusUnauthorized)
+	})
+
+	t.Run("Organization", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		token := createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-organization",
+			"scopes":       []string{"read:repository"},
+			"organization": "org3",
+		}, http.StatusCreated)
+		assert.Equal(t, "org3", token.Organization)
+
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/org3/repo3").AddTokenAuth(token.Token), http.StatusOK)
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/repos/user2/repo1").AddTokenAuth(token.Token), http.StatusNotFound)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-all",
+			"scopes":       []string{"all"},
+			"repositories": []string{"user2/repo1"},
+		}, http.StatusBadRequest)
+		createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-user",
+			"scopes":       []string{"read:repository", "read:user"},
+			"repositories": []string{"user2/repo1"},
+		}, http.StatusBadRequest)
+		createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-missing",
+			"scopes":       []string{"read:repository"},
+			"repositories": []string{"user2/missing"},
+		}, http.StatusBadRequest)
+		createRestrictedAccessToken(t, map[string]any{
+			"name":         "restricted-not-member",
+			"scopes":       []string{"read:repository"},
+			"organization": "org6",
+		}, http.StatusBadRequest)
+	})
+}
+
+func TestAPIAccessTokenExpiry(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	t.Run("Expired", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		expiresAt := time.Now().Add(time.Hour)
+		token := createRestrictedAccessToken(t, map[string]any{
+			"name":       "expiring",
+			"scopes":     []string{"read:user"},
+			"expires_at": expiresAt,
+		}, http.StatusCreated)
+		require.NotNil(t, token.ExpiresAt)
+		assert.Equal(t, expiresAt.Unix(), token.ExpiresAt.Unix())
+
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/user").AddTokenAuth(token.Token), http.StatusOK)
+
+		_, err := db.GetEngine(db.DefaultContext).ID(token.ID).Cols("expires_unix").
+			Update(&auth_model.AccessToken{ExpiresUnix: timeutil.TimeStampNow().Add(-60)})
+		require.NoError(t, err)
+
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/user").AddTokenAuth(token.Token), http.StatusUnauthorized)
+	})
+
+	t.Run("InThePast", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		createRestrictedAccessToken(t, map[string]any{
+			"name":       "expired",
+			"scopes":     []string{"read:user"},
+			"expires_at": time.Now().Add(-time.Hour),
+		}, http.StatusBadRequest)
+	})
+
+	t.Run("MaxLifetime", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+		defer test.MockVariableValue(&setting.AccessTokenMaxLifetime, 24*time.Hour)()
+
+		createRestrictedAccessToken(t, map[string]any{
+			"name":   "no-expiry",
+			"scopes": []string{"read:user"},
+		}, http.StatusBadRequest)
+		createRestrictedAccessToken(t, map[string]any{
+			"name":       "too-late",
+			"scopes":     []string{"read:user"},
+			"expires_at": time.Now().Add(48 * time.Hour),
+		}, http.StatusBadRequest)
+		createRestrictedAccessToken(t, map[string]any{
+			"name":       "within-lifetime",
+			"scopes":     []string{"read:user"},
+			"expires_at": time.Now().Add(12 * time.Hour),
+		}, http.StatusCreated)
+	})
+
+	t.Run("LastUsed", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+		token := createRestrictedAccessToken(t, map[string]any{
+			"name":   "last-used",
+			"scopes": []string{"read:user"},
+		}, http.StatusCreated)
+		assert.Nil(t, token.LastUsedAt)
+
+		_, err := db.GetEngine(db.DefaultContext).ID(token.ID).Cols("created_unix", "updated_unix").NoAutoTime().
+			Update(&auth_model.AccessToken{CreatedUnix: timeutil.TimeStampNow().Add(-3600), UpdatedUnix: timeutil.TimeStampNow().Add(-3600)})
+		require.NoError(t, err)
+		MakeRequest(t, NewRequest(t, "GET", "/api/v1/user").AddTokenAuth(token.Token), http.StatusOK)
+
+		var tokens []*api.AccessToken
+		resp := MakeRequest(t, NewRequest(t, "GET", "/api/v1/users/user2/tokens").AddBasicAuth(user.Name), http.StatusOK)
+		DecodeJSON(t, resp, &tokens)
+		for _, listed := range tokens {
+			if listed.ID == token.ID {
+				assert.NotNil(t, listed.LastUsedAt)
+			}
+		}
+	})
+}
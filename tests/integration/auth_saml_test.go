@@ -0,0 +1,256 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const samlTestIDPEntityID = "https://idp.example.com/metadata"
+
+type samlTestIDP struct {
+	key  *rsa.PrivateKey
+	cert []byte
+}
+
+func newSAMLTestIDP(t *testing.T) *samlTestIDP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &samlTestIDP{key: key, cert: cert}
+}
+
+func (idp *samlTestIDP) metadata() string {
+	return `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="` + samlTestIDPEntityID + `">` +
+		`<md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` +
+		`<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data>` +
+		`<ds:X509Certificate>` + base64.StdEncoding.EncodeToString(idp.cert) + `</ds:X509Certificate>` +
+		`</ds:X509Data></ds:KeyInfo></md:KeyDescriptor>` +
+		`<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>` +
+		`</md:IDPSSODescriptor></md:EntityDescriptor>`
+}
+
+// response returns a response with a signed assertion. The assertion is written in its canonical form,
+// so that it can be signed without canonicalizing it.
+func (idp *samlTestIDP) response(t *testing.T, sourceName, requestID, nameID string, attributes map[string]string) string {
+	now := time.Now().UTC()
+	acsURL := setting.AppURL + "user/saml/" + sourceName + "/acs"
+	metadataURL := setting.AppURL + "user/saml/" + sourceName + "/metadata"
+
+	var attributeStatement string
+	for name, value := range attributes {
+		attributeStatement += `<saml:Attribute Name="` + name + `"><saml:AttributeValue>` + value + `</saml:AttributeValue></saml:Attribute>`
+	}
+	content := `<saml:Issuer>` + samlTestIDPEntityID + `</saml:Issuer>` +
+		`<saml:Subject><saml:NameID>` + nameID + `</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">` +
+		`<saml:SubjectConfirmationData InResponseTo="` + requestID + `" NotOnOrAfter="` + now.Add(5*time.Minute).Format(time.RFC3339) + `" Recipient="` + acsURL + `"></saml:SubjectConfirmationData>` +
+		`</saml:SubjectConfirmation></saml:Subject>` +
+		`<saml:Conditions NotBefore="` + now.Add(-time.Minute).Format(time.RFC3339) + `" NotOnOrAfter="` + now.Add(5*time.Minute).Format(time.RFC3339) + `">` +
+		`<saml:AudienceRestriction><saml:Audience>` + metadataURL + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+		`<saml:AttributeStatement>` + attributeStatement + `</saml:AttributeStatement>`
+	start := `<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="assertion-1" IssueInstant="` + now.Format(time.RFC3339) + `" Version="2.0">`
+	end := `</saml:Assertion>`
+
+	digest := sha256.Sum256([]byte(start + content + end))
+	signedInfo := `<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"></ds:SignatureMethod>` +
+		`<ds:Reference URI="#assertion-1"><ds:Transforms>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"></ds:Transform>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"></ds:Transform></ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference>`
+	hashed := sha256.Sum256([]byte(`<ds:SignedInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">` + signedInfo + `</ds:SignedInfo>`))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+
+	assertion := start +
+		`<ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignedInfo>` + signedInfo + `</ds:SignedInfo>` +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(signature) + `</ds:SignatureValue></ds:Signature>` +
+		content + end
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="response-1" Version="2.0" IssueInstant="` + now.Format(time.RFC3339) + `" Destination="` + acsURL + `" InResponseTo="` + requestID + `">` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		assertion + `</samlp:Response>`
+	return base64.StdEncoding.EncodeToString([]byte(response))
+}
+
+// samlRequestID returns the ID of the authentication request the user is redirected with
+func samlRequestID(t *testing.T, location string) string {
+	u, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", u.Host)
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	request, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(t, err)
+	m := regexp.MustCompile(` ID="([^"]+)"`).FindSubmatch(request)
+	require.NotNil(t, m)
+	return string(m[1])
+}
+
+// postSAMLResponse posts a response like the browser of the user would, without the session cookie,
+// and returns where the user is sent to consume it
+func postSAMLResponse(t *testing.T, sourceName, response string) string {
+	req := NewRequestWithValues(t, "POST", "/user/saml/"+sourceName+"/acs", map[string]string{
+		"SAMLResponse": response,
+	})
+	resp := MakeRequest(t, req, http.StatusSeeOther)
+	location, err := url.Parse(test.RedirectURL(resp))
+	require.NoError(t, err)
+	return location.RequestURI()
+}
+
+func TestSAMLSignIn(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	idp := newSAMLTestIDP(t)
+	sourceName := "saml-idp"
+	source := addAuthSource(t, map[string]string{
+		"type":                     fmt.Sprintf("%d", auth_model.SAML),
+		"name":                     sourceName,
+		"is_active":                "on",
+		"saml_metadata":            idp.metadata(),
+		"saml_attribute_username":  "uid",
+		"saml_attribute_email":     "mail",
+		"saml_attribute_full_name": "cn",
+		"saml_group_attribute":     "groups",
+		"saml_admin_group":         "admins",
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequest(t, "GET", "/user/saml/"+sourceName+"/metadata")
+		resp := MakeRequest(t, req, http.StatusOK)
+		assert.Equal(t, "application/samlmetadata+xml", resp.Header().Get("Content-Type"))
+		assert.Contains(t, resp.Body.String(), `entityID="`+setting.AppURL+"user/saml/"+sourceName+`/metadata"`)
+		assert.Contains(t, resp.Body.String(), `Location="`+setting.AppURL+"user/saml/"+sourceName+`/acs"`)
+	})
+
+	t.Run("LoginPage", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		resp := MakeRequest(t, NewRequest(t, "GET", "/user/login"), http.StatusOK)
+		doc := NewHTMLParser(t, resp.Body)
+		doc.AssertElement(t, `a.saml-login-link[href="/user/saml/`+sourceName+`"]`, true)
+	})
+
+	t.Run("SignIn", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		session := emptyTestSession(t)
+		resp := session.MakeRequest(t, NewRequest(t, "GET", "/user/saml/"+sourceName), http.StatusFound)
+		requestID := samlRequestID(t, resp.Header().Get("Location"))
+
+		response := idp.response(t, sourceName, requestID, "saml-name-id", map[string]string{
+			"uid":    "saml-user",
+			"mail":   "saml-user@example.com",
+			"cn":     "SAML User",
+			"groups": "admins",
+		})
+		acs := postSAMLResponse(t, sourceName, response)
+		resp = session.MakeRequest(t, NewRequest(t, "GET", acs), http.StatusSeeOther)
+		assert.Equal(t, "/", test.RedirectURL(resp))
+
+		user := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "saml-user"})
+		assert.Equal(t, auth_model.SAML, user.LoginType)
+		assert.Equal(t, source.ID, user.LoginSource)
+		assert.Equal(t, "saml-name-id", user.LoginName)
+		assert.Equal(t, "saml-user@example.com", user.Email)
+		assert.Equal(t, "SAML User", user.FullName)
+		assert.True(t, user.IsAdmin)
+
+		// the same response cannot be used twice
+		acs = postSAMLResponse(t, sourceName, response)
+		resp = session.MakeRequest(t, NewRequest(t, "GET", acs), http.StatusSeeOther)
+		assert.Equal(t, "/user/login", test.RedirectURL(resp))
+	})
+
+	t.Run("ExistingUser", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		session := emptyTestSession(t)
+		resp := session.MakeRequest(t, NewRequest(t, "GET", "/user/saml/"+sourceName), http.StatusFound)
+		requestID := samlRequestID(t, resp.Header().Get("Location"))
+
+		// the user is known by the NameID, and is no longer an administrator
+		response := idp.response(t, sourceName, requestID, "saml-name-id", map[string]string{
+			"uid": "renamed-saml-user",
+		})
+		resp = session.MakeRequest(t, NewRequest(t, "GET", postSAMLResponse(t, sourceName, response)), http.StatusSeeOther)
+		assert.Equal(t, "/", test.RedirectURL(resp))
+
+		user := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "saml-user"})
+		assert.False(t, user.IsAdmin)
+		unittest.AssertNotExistsBean(t, &user_model.User{Name: "renamed-saml-user"})
+	})
+
+	t.Run("OtherSession", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		session := emptyTestSession(t)
+		resp := session.MakeRequest(t, NewRequest(t, "GET", "/user/saml/"+sourceName), http.StatusFound)
+		requestID := samlRequestID(t, resp.Header().Get("Location"))
+
+		// a response answering the request of someone else does not sign in
+		response := idp.response(t, sourceName, requestID, "saml-name-id", nil)
+		resp = emptyTestSession(t).MakeRequest(t, NewRequest(t, "GET", postSAMLResponse(t, sourceName, response)), http.StatusSeeOther)
+		assert.Equal(t, "/user/login", test.RedirectURL(resp))
+	})
+
+	t.Run("UnknownSource", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		MakeRequest(t, NewRequest(t, "GET", "/user/saml/unknown/metadata"), http.StatusNotFound)
+		MakeRequest(t, NewRequest(t, "GET", "/user/saml/unknown"), http.StatusNotFound)
+	})
+}
+
+func TestSAMLInvalidMetadata(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	session := loginUser(t, "user1")
+	req := NewRequestWithValues(t, "POST", "/admin/auths/new", map[string]string{
+		"_csrf":         GetCSRF(t, session, "/admin/auths/new"),
+		"type":          fmt.Sprintf("%d", auth_model.SAML),
+		"name":          "saml-invalid",
+		"is_active":     "on",
+		"saml_metadata": `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="x"/>`,
+	})
+	session.MakeRequest(t, req, http.StatusOK)
+	unittest.AssertNotExistsBean(t, &auth_model.Source{Name: "saml-invalid"})
+}
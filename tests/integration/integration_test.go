@@ -381,7 +381,7 @@ func createUser(ctx context.Context, t testing.TB, user *user_model.User) func()
 	}
 
 	return func() {
-		require.NoError(t, user_service.DeleteUser(ctx, user, true))
+		require.NoError(t, user_service.DeleteUser(ctx, nil, user, true))
 	}
 }
 
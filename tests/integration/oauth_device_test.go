@@ -0,0 +1,201 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	"code.gitea.io/gitea/models/unittest"
+	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/timeutil"
+	"code.gitea.io/gitea/routers/web/auth"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	deviceClientID     = "da7da3ba-9a13-4167-856f-3899de0b0138"
+	deviceClientSecret = "4MK8Na6R55smdCY0WuCCumZ6hjRPnGY5saWVRHHjJiA="
+)
+
+func requestDeviceAuthorization(t *testing.T) *auth.DeviceAuthorizationResponse {
+	t.Helper()
+	req := NewRequestWithValues(t, "POST", "/login/oauth/device_authorization", map[string]string{
+		"client_id":     deviceClientID,
+		"client_secret": deviceClientSecret,
+		"scope":         "openid profile",
+	})
+	resp := MakeRequest(t, req, http.StatusOK)
+	parsed := new(auth.DeviceAuthorizationResponse)
+	DecodeJSON(t, resp, parsed)
+	return parsed
+}
+
+func assertDeviceCodeError(t *testing.T, deviceCode, errorCode string) {
+	t.Helper()
+	req := NewRequestWithValues(t, "POST", "/login/oauth/access_token", map[string]string{
+		"grant_type":    "urn:ietf:params:oauth:grant-type:device_code",
+		"client_id":     deviceClientID,
+		"client_secret": deviceClientSecret,
+		"device_code":   deviceCode,
+	})
+	resp := MakeRequest(t, req, http.StatusBadRequest)
+	parsedError := new(auth.AccessTokenError)
+	DecodeJSON(t, resp, parsedError)
+	assert.Equal(t, errorCode, string(parsedError.ErrorCode))
+}
+
+func submitDeviceUserCode(t *testing.T, session *TestSession, userCode, granted string) {
+	t.Helper()
+	req := NewRequest(t, "GET", "/login/device?user_code="+url.QueryEscape(userCode))
+	resp := session.MakeRequest(t, req, http.StatusOK)
+	htmlDoc := NewHTMLParser(t, resp.Body)
+	assert.Equal(t, userCode, htmlDoc.GetInputValueByName("user_code"))
+
+	req = NewRequestWithValues(t, "POST", "/login/device", map[string]string{
+		"_csrf":     htmlDoc.GetCSRF(),
+		"user_code": userCode,
+	})
+	resp = session.MakeRequest(t, req, http.StatusOK)
+	htmlDoc = NewHTMLParser(t, resp.Body)
+	htmlDoc.AssertElement(t, "#authorize-device", true)
+
+	req = NewRequestWithValues(t, "POST", "/login/device/grant", map[string]string{
+		"_csrf":     htmlDoc.GetCSRF(),
+		"user_code": userCode,
+		"granted":   granted,
+	})
+	session.MakeRequest(t, req, http.StatusSeeOther)
+}
+
+func TestOAuthDeviceAuthorization(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+
+	t.Run("Discovery", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		resp := MakeRequest(t, NewRequest(t, "GET", "/.well-known/openid-configuration"), http.StatusOK)
+		var discovery map[string]any
+		DecodeJSON(t, resp, &discovery)
+		assert.Equal(t, setting.AppURL+"login/oauth/device_authorization", discovery["device_authorization_endpoint"])
+		assert.Contains(t, discovery["grant_types_supported"], "urn:ietf:params:oauth:grant-type:device_code")
+	})
+
+	t.Run("Approved", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		d := requestDeviceAuthorization(t)
+		assert.Equal(t, setting.AppURL+"login/device", d.VerificationURI)
+		assert.Equal(t, d.VerificationURI+"?user_code="+d.UserCode, d.VerificationURIComplete)
+		assert.Equal(t, setting.OAuth2.DeviceCodeExpirationTime, d.ExpiresIn)
+		assert.Equal(t, setting.OAuth2.DeviceCodePollingInterval, d.Interval)
+
+		assertDeviceCodeError(t, d.DeviceCode, "authorization_pending")
+		assertDeviceCodeError(t, d.DeviceCode, "slow_down")
+
+		session := loginUser(t, "user4")
+		submitDeviceUserCode(t, session, d.UserCode, "true")
+		unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Grant{UserID: 4, ApplicationID: 1, Scope: "openid profile"})
+
+		req := NewRequestWithValues(t, "POST", "/login/oauth/access_token", map[string]string{
+			"grant_type":    "urn:ietf:params:oauth:grant-type:device_code",
+			"client_id":     deviceClientID,
+			"client_secret": deviceClientSecret,
+			"device_code":   d.DeviceCode,
+		})
+		resp := MakeRequest(t, req, http.StatusOK)
+		parsed := new(auth.AccessTokenResponse)
+		DecodeJSON(t, resp, parsed)
+		assert.Greater(t, len(parsed.AccessToken), 10)
+		assert.Greater(t, len(parsed.RefreshToken), 10)
+
+		MakeRequest(t, NewRequest(t, "GET", "/login/oauth/userinfo").AddTokenAuth(parsed.AccessToken), http.StatusOK)
+
+		// the device code cannot be used twice
+		assertDeviceCodeError(t, d.DeviceCode, "invalid_grant")
+	})
+
+	t.Run("ExistingGrant", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		// user5 already granted the application the "openid profile email" scope
+		d := requestDeviceAuthorization(t)
+		session := loginUser(t, "user5")
+		submitDeviceUserCode(t, session, d.UserCode, "true")
+		unittest.AssertExistsAndLoadBean(t, &auth_model.OAuth2Grant{ID: 3, UserID: 5, Scope: "openid profile"})
+
+		req := NewRequestWithValues(t, "POST", "/login/oauth/access_token", map[string]string{
+			"grant_type":    "urn:ietf:params:oauth:grant-type:device_code",
+			"client_id":     deviceClientID,
+			"client_secret": deviceClientSecret,
+			"device_code":   d.DeviceCode,
+		})
+		MakeRequest(t, req, http.StatusOK)
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		d := requestDeviceAuthorization(t)
+		session := loginUser(t, "user4")
+		submitDeviceUserCode(t, session, d.UserCode, "false")
+
+		assertDeviceCodeError(t, d.DeviceCode, "access_denied")
+		assertDeviceCodeError(t, d.DeviceCode, "invalid_grant")
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		d := requestDeviceAuthorization(t)
+		_, err := db.GetEngine(db.DefaultContext).Where("device_code = ?", d.DeviceCode).Cols("valid_until").
+			Update(&auth_model.OAuth2DeviceAuthorization{ValidUntil: timeutil.TimeStampNow().Add(-60)})
+		require.NoError(t, err)
+
+		assertDeviceCodeError(t, d.DeviceCode, "expired_token")
+
+		session := loginUser(t, "user4")
+		req := NewRequestWithValues(t, "POST", "/login/device", map[string]string{
+			"_csrf":     GetCSRF(t, session, "/login/device"),
+			"user_code": d.UserCode,
+		})
+		resp := session.MakeRequest(t, req, http.StatusOK)
+		NewHTMLParser(t, resp.Body).AssertElement(t, "#authorize-device", false)
+	})
+
+	t.Run("InvalidClient", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		req := NewRequestWithValues(t, "POST", "/login/oauth/device_authorization", map[string]string{
+			"client_id":     deviceClientID,
+			"client_secret": "wrong",
+		})
+		resp := MakeRequest(t, req, http.StatusBadRequest)
+		parsedError := new(auth.AccessTokenError)
+		DecodeJSON(t, resp, parsedError)
+		assert.Equal(t, "invalid_client", string(parsedError.ErrorCode))
+
+		d := requestDeviceAuthorization(t)
+		req = NewRequestWithValues(t, "POST", "/login/oauth/access_token", map[string]string{
+			"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+			"client_id":   "ce5a1322-42a7-11ed-b878-0242ac120002",
+			"device_code": d.DeviceCode,
+		})
+		resp = MakeRequest(t, req, http.StatusBadRequest)
+		DecodeJSON(t, resp, parsedError)
+		assert.Equal(t, "invalid_grant", string(parsedError.ErrorCode))
+	})
+
+	t.Run("RequiresSignIn", func(t *testing.T) {
+		defer tests.PrintCurrentTest(t)()
+
+		MakeRequest(t, NewRequest(t, "GET", "/login/device"), http.StatusSeeOther)
+	})
+}
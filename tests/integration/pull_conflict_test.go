@@ -0,0 +1,146 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"code.gitea.io/gitea/models"
+	auth_model "code.gitea.io/gitea/models/auth"
+	"code.gitea.io/gitea/models/db"
+	git_model "code.gitea.io/gitea/models/git"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/gitrepo"
+	api "code.gitea.io/gitea/modules/structs"
+	"code.gitea.io/gitea/modules/test"
+	pull_service "code.gitea.io/gitea/services/pull"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullResolveConflicts(t *testing.T) {
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		session := loginUser(t, "user1")
+		testRepoFork(t, session, "user2", "repo1", "user1", "repo1")
+		testEditFileToNewBranch(t, session, "user1", "repo1", "master", "conflict", "README.md", "Hello, World (Edited Once)\n")
+		testEditFileToNewBranch(t, session, "user1", "repo1", "master", "base", "README.md", "Hello, World (Edited Twice)\n")
+
+		token := getTokenForLoggedInUser(t, session, auth_model.AccessTokenScopeWriteRepository)
+		req := NewRequestWithJSON(t, http.MethodPost, "/api/v1/repos/user1/repo1/pulls", &api.CreatePullRequestOption{
+			Head:  "conflict",
+			Base:  "base",
+			Title: "create a conflicting pr",
+		}).AddTokenAuth(token)
+		resp := session.MakeRequest(t, req, http.StatusCreated)
+		var apiPull api.PullRequest
+		DecodeJSON(t, resp, &apiPull)
+
+		user1 := unittest.AssertExistsAndLoadBean(t, &user_model.User{Name: "user1"})
+		repo1 := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{OwnerID: user1.ID, Name: "repo1"})
+		pr := unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{ID: apiPull.ID})
+
+		pullLink := fmt.Sprintf("/user1/repo1/pulls/%d", apiPull.Index)
+		conflictsLink := pullLink + "/conflicts"
+
+		headCommit := func(t *testing.T) *git.Commit {
+			t.Helper()
+			gitRepo, err := gitrepo.OpenRepository(db.DefaultContext, repo1)
+			require.NoError(t, err)
+			defer gitRepo.Close()
+			commit, err := gitRepo.GetBranchCommit("conflict")
+			require.NoError(t, err)
+			return commit
+		}
+
+		getConflicts := func(t *testing.T) *pull_service.PullConflicts {
+			t.Helper()
+			conflicts, err := pull_service.GetConflicts(db.DefaultContext, pr, user1)
+			require.NoError(t, err)
+			return conflicts
+		}
+
+		resolve := func(t *testing.T, conflicts *pull_service.PullConflicts, content string) string {
+			t.Helper()
+			req := NewRequestWithValues(t, "POST", conflictsLink, map[string]string{
+				"_csrf":          GetCSRF(t, session, pullLink),
+				"head_commit_id": conflicts.HeadCommitID,
+				"base_commit_id": conflicts.BaseCommitID,
+				"path_0":         "README.md",
+				"mode_0":         "edit",
+				"content_0":      content,
+			})
+			resp := session.MakeRequest(t, req, http.StatusSeeOther)
+			return test.RedirectURL(resp)
+		}
+
+		t.Run("Conflicts", func(t *testing.T) {
+			req := NewRequest(t, "GET", conflictsLink)
+			session.MakeRequest(t, req, http.StatusOK)
+
+			conflicts := getConflicts(t)
+			require.Len(t, conflicts.Files, 1)
+			assert.Equal(t, "README.md", conflicts.Files[0].Path)
+			assert.Equal(t, headCommit(t).ID.String(), conflicts.HeadCommitID)
+		})
+
+		t.Run("ProtectedHeadBranch", func(t *testing.T) {
+			rule := &git_model.ProtectedBranch{RepoID: repo1.ID, RuleName: "conflict"}
+			require.NoError(t, git_model.UpdateProtectBranch(db.DefaultContext, repo1, rule, git_model.WhitelistOptions{}))
+			defer func() {
+				require.NoError(t, git_model.DeleteProtectedBranch(db.DefaultContext, repo1, rule.ID))
+			}()
+
+			conflicts := getConflicts(t)
+			assert.Equal(t, pullLink, resolve(t, conflicts, "Hello, World (Resolved)\n"))
+			assert.Equal(t, conflicts.HeadCommitID, headCommit(t).ID.String())
+		})
+
+		t.Run("HeadBranchMoved", func(t *testing.T) {
+			conflicts := getConflicts(t)
+			testEditFile(t, session, "user1", "repo1", "conflict", "README.md", "Hello, World (Edited Thrice)\n")
+			moved := headCommit(t)
+			require.NotEqual(t, conflicts.HeadCommitID, moved.ID.String())
+
+			assert.Equal(t, conflictsLink, resolve(t, conflicts, "Hello, World (Resolved)\n"))
+			assert.Equal(t, moved.ID.String(), headCommit(t).ID.String())
+
+			err := pull_service.ResolveConflicts(db.DefaultContext, pr, user1, conflicts.HeadCommitID, conflicts.BaseCommitID,
+				[]*pull_service.ConflictResolution{{Path: "README.md", Side: pull_service.ConflictSideOurs}}, "Resolve")
+			assert.True(t, models.IsErrSHADoesNotMatch(err))
+			assert.Equal(t, moved.ID.String(), headCommit(t).ID.String())
+		})
+
+		t.Run("Resolve", func(t *testing.T) {
+			conflicts := getConflicts(t)
+			assert.Equal(t, pullLink, resolve(t, conflicts, "Hello, World (Resolved)\n"))
+
+			// the base branch is merged into the head branch with the resolved content
+			commit := headCommit(t)
+			require.Equal(t, 2, commit.ParentCount())
+			parent, err := commit.ParentID(0)
+			require.NoError(t, err)
+			assert.Equal(t, conflicts.HeadCommitID, parent.String())
+			parent, err = commit.ParentID(1)
+			require.NoError(t, err)
+			assert.Equal(t, conflicts.BaseCommitID, parent.String())
+			assert.Equal(t, "Merge branch 'base' into conflict", commit.Summary())
+
+			blob, err := commit.GetBlobByPath("README.md")
+			require.NoError(t, err)
+			content, err := blob.GetBlobContent(1024)
+			require.NoError(t, err)
+			assert.Equal(t, "Hello, World (Resolved)\n", content)
+
+			assert.Empty(t, getConflicts(t).Files)
+		})
+	})
+}
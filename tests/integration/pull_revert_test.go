@@ -0,0 +1,134 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	pull_service "code.gitea.io/gitea/services/pull"
+	repo_service "code.gitea.io/gitea/services/repository"
+	files_service "code.gitea.io/gitea/services/repository/files"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commitRevertTestFile(t *testing.T, user *user_model.User, repo *repo_model.Repository, oldBranch, newBranch, operation, treePath, content string) {
+	t.Helper()
+	_, err := files_service.ChangeRepoFiles(git.DefaultContext, repo, user, &files_service.ChangeRepoFilesOptions{
+		Files: []*files_service.ChangeRepoFile{
+			{
+				Operation:     operation,
+				TreePath:      treePath,
+				ContentReader: strings.NewReader(content),
+			},
+		},
+		Message:   operation + " " + treePath,
+		OldBranch: oldBranch,
+		NewBranch: newBranch,
+		Author: &files_service.IdentityOptions{
+			Name:  user.Name,
+			Email: user.Email,
+		},
+		Committer: &files_service.IdentityOptions{
+			Name:  user.Name,
+			Email: user.Email,
+		},
+		Dates: &files_service.CommitDateOptions{
+			Author:    time.Now(),
+			Committer: time.Now(),
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestRevertPullRequest(t *testing.T) {
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+		repo, _, f := tests.CreateDeclarativeRepo(t, user, "revert-pr", nil, nil, nil)
+		defer f()
+
+		for _, style := range []repo_model.MergeStyle{
+			repo_model.MergeStyleMerge,
+			repo_model.MergeStyleRebase,
+			repo_model.MergeStyleRebaseMerge,
+			repo_model.MergeStyleSquash,
+			repo_model.MergeStyleFastForwardOnly,
+		} {
+			t.Run(string(style), func(t *testing.T) {
+				defer tests.PrintCurrentTest(t)()
+
+				// the pull request adds a file in two commits
+				branch := "pr-" + string(style)
+				prFile := string(style) + ".txt"
+				commitRevertTestFile(t, user, repo, "main", branch, "create", prFile, "first")
+				commitRevertTestFile(t, user, repo, branch, branch, "update", prFile, "second")
+
+				pr := &issues_model.PullRequest{
+					HeadRepoID: repo.ID,
+					BaseRepoID: repo.ID,
+					HeadBranch: branch,
+					BaseBranch: "main",
+					HeadRepo:   repo,
+					BaseRepo:   repo,
+					Type:       issues_model.PullRequestGitea,
+				}
+				require.NoError(t, pull_service.NewPullRequest(db.DefaultContext, repo, &issues_model.Issue{
+					RepoID:   repo.ID,
+					Title:    "Add " + prFile,
+					PosterID: user.ID,
+					Poster:   user,
+					IsPull:   true,
+				}, nil, nil, pr, nil))
+
+				// the base branch moves on, except when it must be fast-forwarded
+				mainFile := "main-" + string(style) + ".txt"
+				if style != repo_model.MergeStyleFastForwardOnly {
+					commitRevertTestFile(t, user, repo, "main", "main", "create", mainFile, "main")
+				}
+
+				gitRepo, err := git.OpenRepository(db.DefaultContext, repo.RepoPath())
+				require.NoError(t, err)
+				defer gitRepo.Close()
+
+				require.NoError(t, pull_service.Merge(db.DefaultContext, pr, user, gitRepo, style, "", "merge "+branch, false))
+				pr = unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{ID: pr.ID})
+				require.True(t, pr.HasMerged)
+
+				revertPR, err := repo_service.RevertPullRequest(db.DefaultContext, user, pr)
+				require.NoError(t, err)
+				assert.Equal(t, "main", revertPR.BaseBranch)
+
+				revertCommit, err := gitRepo.GetBranchCommit(revertPR.HeadBranch)
+				require.NoError(t, err)
+				_, err = revertCommit.GetTreeEntryByPath(prFile)
+				assert.True(t, git.IsErrNotExist(err), "the file added by the pull request must be reverted")
+				if style != repo_model.MergeStyleFastForwardOnly {
+					_, err = revertCommit.GetTreeEntryByPath(mainFile)
+					require.NoError(t, err, "the changes of the base branch must be kept")
+				}
+				_, err = revertCommit.GetTreeEntryByPath("README.md")
+				require.NoError(t, err)
+			})
+		}
+
+		t.Run("NotMerged", func(t *testing.T) {
+			defer tests.PrintCurrentTest(t)()
+
+			pr := createPullRequest(t, user, repo, "not-merged")
+			_, err := repo_service.RevertPullRequest(db.DefaultContext, user, pr)
+			assert.True(t, repo_service.IsErrPullRequestNotMerged(err))
+		})
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The Forgejo Authors. All rights reserved.
+// SPDX-License-Identifier: MIT
+
+package integration
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"code.gitea.io/gitea/models/db"
+	issues_model "code.gitea.io/gitea/models/issues"
+	repo_model "code.gitea.io/gitea/models/repo"
+	"code.gitea.io/gitea/models/unittest"
+	user_model "code.gitea.io/gitea/models/user"
+	"code.gitea.io/gitea/modules/git"
+	"code.gitea.io/gitea/modules/queue"
+	pull_service "code.gitea.io/gitea/services/pull"
+	"code.gitea.io/gitea/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createStackedPullRequest(t *testing.T, user *user_model.User, repo *repo_model.Repository, base, head string) *issues_model.PullRequest {
+	t.Helper()
+	pr := &issues_model.PullRequest{
+		HeadRepoID: repo.ID,
+		BaseRepoID: repo.ID,
+		HeadBranch: head,
+		BaseBranch: base,
+		HeadRepo:   repo,
+		BaseRepo:   repo,
+		Type:       issues_model.PullRequestGitea,
+	}
+	require.NoError(t, pull_service.NewPullRequest(db.DefaultContext, repo, &issues_model.Issue{
+		RepoID:   repo.ID,
+		Title:    "Merge " + head + " into " + base,
+		PosterID: user.ID,
+		Poster:   user,
+		IsPull:   true,
+	}, nil, nil, pr, nil))
+	return pr
+}
+
+func TestPullStackRetargetOnMerge(t *testing.T) {
+	onGiteaRun(t, func(t *testing.T, u *url.URL) {
+		user := unittest.AssertExistsAndLoadBean(t, &user_model.User{ID: 2})
+		repo, _, f := tests.CreateDeclarativeRepo(t, user, "stacked-prs", nil, nil, nil)
+		defer f()
+
+		gitRepo, err := git.OpenRepository(db.DefaultContext, repo.RepoPath())
+		require.NoError(t, err)
+		defer gitRepo.Close()
+
+		for _, style := range []repo_model.MergeStyle{repo_model.MergeStyleMerge, repo_model.MergeStyleSquash} {
+			t.Run(string(style), func(t *testing.T) {
+				defer tests.PrintCurrentTest(t)()
+
+				// the child pull request is stacked on the parent pull request, which adds two commits
+				parentBranch := "parent-" + string(style)
+				childBranch := "child-" + string(style)
+				commitRevertTestFile(t, user, repo, "main", parentBranch, "create", parentBranch+".txt", "first")
+				commitRevertTestFile(t, user, repo, parentBranch, parentBranch, "update", parentBranch+".txt", "second")
+				commitRevertTestFile(t, user, repo, parentBranch, childBranch, "create", childBranch+".txt", "child")
+				parent := createStackedPullRequest(t, user, repo, "main", parentBranch)
+				child := createStackedPullRequest(t, user, repo, parentBranch, childBranch)
+				childHeadCommitID, err := gitRepo.GetBranchCommitID(childBranch)
+				require.NoError(t, err)
+
+				require.NoError(t, pull_service.Merge(db.DefaultContext, parent, user, gitRepo, style, "", "merge "+parentBranch, false))
+				require.NoError(t, queue.GetManager().FlushAll(context.Background(), 10*time.Second))
+
+				child = unittest.AssertExistsAndLoadBean(t, &issues_model.PullRequest{ID: child.ID})
+				assert.Equal(t, "main", child.BaseBranch)
+				unittest.AssertExistsAndLoadBean(t, &issues_model.Comment{
+					IssueID: child.IssueID,
+					Type:    issues_model.CommentTypeChangeTargetBranch,
+					OldRef:  parentBranch,
+					NewRef:  "main",
+				})
+
+				mainCommitID, err := gitRepo.GetBranchCommitID("main")
+				require.NoError(t, err)
+				if style != repo_model.MergeStyleSquash {
+					// the commits of the parent are in the base branch, the child is left alone
+					newChildHeadCommitID, err := gitRepo.GetBranchCommitID(childBranch)
+					require.NoError(t, err)
+					assert.Equal(t, childHeadCommitID, newChildHeadCommitID)
+					return
+				}
+
+				// the child is rebased on the squashed commit, without the commits of the parent
+				assert.Eventually(t, func() bool {
+					newChildHeadCommitID, err := gitRepo.GetBranchCommitID(childBranch)
+					return err == nil && newChildHeadCommitID != childHeadCommitID
+				}, 10*time.Second, 100*time.Millisecond)
+				childHead, err := gitRepo.GetBranchCommit(childBranch)
+				require.NoError(t, err)
+				require.Equal(t, 1, childHead.ParentCount())
+				parentCommitID, err := childHead.ParentID(0)
+				require.NoError(t, err)
+				assert.Equal(t, mainCommitID, parentCommitID.String())
+				_, err = childHead.GetTreeEntryByPath(childBranch + ".txt")
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"code.gitea.io/gitea/models/db"
 	"code.gitea.io/gitea/models/forgefed"
@@ -19,8 +21,10 @@ import (
 	"code.gitea.io/gitea/models/unittest"
 	user_model "code.gitea.io/gitea/models/user"
 	fm "code.gitea.io/gitea/modules/forgefed"
+	"code.gitea.io/gitea/modules/git"
 	"code.gitea.io/gitea/modules/optional"
 	"code.gitea.io/gitea/modules/setting"
+	"code.gitea.io/gitea/modules/test"
 	"code.gitea.io/gitea/modules/validation"
 	gitea_context "code.gitea.io/gitea/services/context"
 	repo_service "code.gitea.io/gitea/services/repository"
@@ -64,12 +68,12 @@ func TestRepoAddMoreUnitsHighlighting(t *testing.T) {
 	setUserHints := func(t *testing.T, hints bool) func() {
 		saved := user.EnableRepoUnitHints
 
-		require.NoError(t, user_service.UpdateUser(db.DefaultContext, user, &user_service.UpdateOptions{
+		require.NoError(t, user_service.UpdateUser(db.DefaultContext, user, user, &user_service.UpdateOptions{
 			EnableRepoUnitHints: optional.Some(hints),
 		}))
 
 		return func() {
-			require.NoError(t, user_service.UpdateUser(db.DefaultContext, user, &user_service.UpdateOptions{
+			require.NoError(t, user_service.UpdateUser(db.DefaultContext, user, user, &user_service.UpdateOptions{
 				EnableRepoUnitHints: optional.Some(saved),
 			}))
 		}
@@ -272,6 +276,7 @@ func TestProtectedBranch(t *testing.T) {
 }
 
 func TestRepoFollowing(t *testing.T) {
+	defer test.MockVariableValue(&setting.Federation.AllowedHostList, "loopback")()
 	setting.Federation.Enabled = true
 	defer tests.PrepareTestEnv(t)()
 	defer func() {
@@ -296,7 +301,7 @@ func TestRepoFollowing(t *testing.T) {
 				`"openRegistrations":true,"usage":{"users":{"total":14,"activeHalfyear":2}},"metadata":{}}`)
 			fmt.Fprint(res, responseBody)
 		})
-	repo1InboxReceivedLike := false
+	var repo1InboxReceivedLike atomic.Bool
 	federatedRoutes.HandleFunc("/api/v1/activitypub/repository-id/1/inbox/",
 		func(res http.ResponseWriter, req *http.Request) {
 			if req.Method != "POST" {
@@ -324,7 +329,7 @@ func TestRepoFollowing(t *testing.T) {
 				t.Errorf("Activity is not a like for this repo")
 			}
 
-			repo1InboxReceivedLike = true
+			repo1InboxReceivedLike.Store(true)
 		})
 	federatedRoutes.HandleFunc("/",
 		func(res http.ResponseWriter, req *http.Request) {
@@ -363,8 +368,32 @@ func TestRepoFollowing(t *testing.T) {
 		req := NewRequestWithValues(t, "POST", link, map[string]string{
 			"_csrf": GetCSRF(t, session, repoLink),
 		})
-		assert.False(t, repo1InboxReceivedLike)
+		assert.False(t, repo1InboxReceivedLike.Load())
 		session.MakeRequest(t, req, http.StatusOK)
-		assert.True(t, repo1InboxReceivedLike)
+		// the Like is delivered by the federation queue
+		assert.Eventually(t, repo1InboxReceivedLike.Load, 10*time.Second, 100*time.Millisecond)
 	})
 }
+
+func TestRepoMaintenanceRun(t *testing.T) {
+	defer tests.PrepareTestEnv(t)()
+	if git.CheckGitVersionAtLeast("2.32") != nil {
+		t.Skip("git too old")
+	}
+
+	repo := unittest.AssertExistsAndLoadBean(t, &repo_model.Repository{ID: 1})
+	session := loginUser(t, "user2")
+	req := NewRequestWithValues(t, "POST", "/user2/repo1/settings/maintenance/run", map[string]string{
+		"_csrf": GetCSRF(t, session, "/user2/repo1/settings/maintenance"),
+	})
+	session.MakeRequest(t, req, http.StatusSeeOther)
+
+	// the maintenance runs in the background
+	assert.Eventually(t, func() bool {
+		m, err := repo_model.GetRepoMaintenance(db.DefaultContext, repo.ID)
+		require.NoError(t, err)
+		return m.LastRunUnix != 0
+	}, 30*time.Second, 100*time.Millisecond)
+
+	session.MakeRequest(t, NewRequest(t, "GET", "/user2/repo1/settings/maintenance"), http.StatusOK)
+}
@@ -119,7 +119,7 @@ export function initAdminCommon() {
   // New authentication
   if (document.querySelector('.admin.new.authentication')) {
     document.getElementById('auth_type')?.addEventListener('change', function () {
-      hideElem('.ldap, .dldap, .smtp, .pam, .oauth2, .has-tls, .search-page-size, .sspi');
+      hideElem('.ldap, .dldap, .smtp, .pam, .oauth2, .has-tls, .search-page-size, .sspi, .saml');
 
       for (const input of document.querySelectorAll('.ldap input[required], .binddnrequired input[required], .dldap input[required], .smtp input[required], .pam input[required], .oauth2 input[required], .has-tls input[required], .sspi input[required]')) {
         input.removeAttribute('required');
@@ -168,6 +168,9 @@ export function initAdminCommon() {
             input.setAttribute('required', 'required');
           }
           break;
+        case '9': // SAML
+          showElem('.saml');
+          break;
       }
       if (authType === '2' || authType === '5') {
         onSecurityProtocolChange();
@@ -205,6 +208,8 @@ export function initAdminCommon() {
     $('#auth_name').on('input', function () {
       // appSubUrl is either empty or is a path that starts with `/` and doesn't have a trailing slash.
       document.getElementById('oauth2-callback-url').textContent = `${window.location.origin}${appSubUrl}/user/oauth2/${encodeURIComponent(this.value)}/callback`;
+      const samlMetadataURL = document.getElementById('saml-metadata-url');
+      if (samlMetadataURL) samlMetadataURL.textContent = `${window.location.origin}${appSubUrl}/user/saml/${encodeURIComponent(this.value)}/metadata`;
     }).trigger('input');
   }
 